@@ -36,6 +36,7 @@ const (
 var (
 	_ indexer.DistributionScanner = (*DistributionScanner)(nil)
 	_ indexer.VersionedScanner    = (*DistributionScanner)(nil)
+	_ indexer.PathScanner         = (*DistributionScanner)(nil)
 
 	issueRegexp     = regexp.MustCompile(`Alpine Linux ([[:digit:]]+\.[[:digit:]]+)`)
 	edgeIssueRegexp = regexp.MustCompile(`Alpine Linux [[:digit:]]+\.\w+ \(edge\)`)
@@ -54,6 +55,11 @@ func (*DistributionScanner) Version() string { return scannerVersion }
 // Kind implements scanner.VersionedScanner.
 func (*DistributionScanner) Kind() string { return scannerKind }
 
+// Paths implements [indexer.PathScanner].
+func (*DistributionScanner) Paths() []string {
+	return []string{osrelease.Path, osrelease.FallbackPath, issuePath}
+}
+
 // Scan will inspect the layer for an os-release or issue file
 // and perform a regex match for keywords indicating the associated alpine release
 //
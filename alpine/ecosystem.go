@@ -11,6 +11,7 @@ import (
 // NewEcosystem provides the set of scanners and coalescers for the alpine ecosystem
 func NewEcosystem(ctx context.Context) *indexer.Ecosystem {
 	return &indexer.Ecosystem{
+		Name: "alpine",
 		PackageScanners: func(ctx context.Context) ([]indexer.PackageScanner, error) {
 			return []indexer.PackageScanner{&apk.Scanner{}}, nil
 		},
@@ -23,6 +23,7 @@ const (
 var (
 	_ indexer.VersionedScanner = (*Scanner)(nil)
 	_ indexer.PackageScanner   = (*Scanner)(nil)
+	_ indexer.PathScanner      = (*Scanner)(nil)
 )
 
 // Scanner scans for packages in an apk database.
@@ -39,6 +40,9 @@ func (*Scanner) Version() string { return version }
 // Kind implements indexer.VersionedScanner.
 func (*Scanner) Kind() string { return kind }
 
+// Paths implements [indexer.PathScanner].
+func (*Scanner) Paths() []string { return []string{installedFile} }
+
 const installedFile = "lib/apk/db/installed"
 
 // Scan examines a layer for an apk installation database, and extracts
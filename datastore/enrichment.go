@@ -21,3 +21,11 @@ type EnrichmentUpdater interface {
 type Enrichment interface {
 	GetEnrichment(ctx context.Context, kind string, tags []string) ([]driver.EnrichmentRecord, error)
 }
+
+// EnrichmentExporter is implemented by stores that can report every record of
+// an enrichment update operation.
+type EnrichmentExporter interface {
+	// GetEnrichmentRecords returns the records inserted by the enrichment
+	// update operation "ref".
+	GetEnrichmentRecords(ctx context.Context, ref uuid.UUID) ([]driver.EnrichmentRecord, error)
+}
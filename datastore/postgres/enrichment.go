@@ -237,3 +237,43 @@ WHERE
 	err = rows.Err()
 	return res, err
 }
+
+// GetEnrichmentRecords implements [datastore.EnrichmentExporter].
+func (s *MatcherStore) GetEnrichmentRecords(ctx context.Context, ref uuid.UUID) ([]driver.EnrichmentRecord, error) {
+	const query = `
+SELECT
+	e.tags, e.data
+FROM
+	enrichment AS e,
+	uo_enrich AS uo,
+	update_operation AS op
+WHERE
+	op.ref = $1
+	AND op.kind = 'enrichment'
+	AND uo.uo = op.id
+	AND uo.enrich = e.id
+ORDER BY
+	e.id;`
+	ctx = zlog.ContextWithValues(ctx, "component", "datastore/postgres/GetEnrichmentRecords")
+	rows, err := s.pool.Query(ctx, query, ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query enrichments: %w", err)
+	}
+	defer rows.Close()
+	var res []driver.EnrichmentRecord
+	for rows.Next() {
+		var r driver.EnrichmentRecord
+		if err := rows.Scan(&r.Tags, &r.Enrichment); err != nil {
+			return nil, fmt.Errorf("failed to scan enrichment: %w", err)
+		}
+		res = append(res, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read enrichments: %w", err)
+	}
+	zlog.Debug(ctx).
+		Stringer("ref", ref).
+		Int("count", len(res)).
+		Msg("read enrichments")
+	return res, nil
+}
@@ -89,7 +89,8 @@ func (s *MatcherStore) Get(ctx context.Context, records []*claircore.IndexRecord
 			}
 
 			var id int64
-			err := rows.Scan(
+			var d vulnDetails
+			dest := []interface{}{
 				&id,
 				&v.Name,
 				&v.Description,
@@ -116,7 +117,11 @@ func (s *MatcherStore) Get(ctx context.Context, records []*claircore.IndexRecord
 				&v.Repo.URI,
 				&v.FixedInVersion,
 				&v.Updater,
-			)
+			}
+			err := rows.Scan(append(dest, d.dest(v)...)...)
+			if err == nil {
+				err = d.apply(v)
+			}
 			v.ID = strconv.FormatInt(id, 10)
 			if err != nil {
 				res.Close()
@@ -94,7 +94,16 @@ WHERE ROW ('vulnerability') = ALL (SELECT kind FROM update_operation WHERE ref =
 		repo_name,
 		repo_key,
 		repo_uri,
-		fixed_in_version
+		fixed_in_version,
+		cvss_v2_vector,
+		cvss_v2_score,
+		cvss_v3_vector,
+		cvss_v3_score,
+		cvss_v4_vector,
+		cvss_v4_score,
+		affected_cpes,
+		fix_state,
+		cvss
 	FROM vuln
 	WHERE
 		vuln.id IN (
@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -86,11 +87,13 @@ func (s *IndexerStore) IndexPackages(ctx context.Context, pkgs []*claircore.Pack
 				 WHERE layer.hash = $14
 			 )
 		INSERT
-		INTO package_scanartifact (layer_id, package_db, repository_hint, filepath, package_id, source_id, scanner_id)
+		INTO package_scanartifact (layer_id, package_db, repository_hint, filepath, files, elf, package_id, source_id, scanner_id)
 		VALUES ((SELECT layer_id FROM layer),
 				$15,
 				$16,
 				$17,
+				$18,
+				$19,
 				(SELECT package_id FROM binary_package),
 				(SELECT source_id FROM source_package),
 				(SELECT scanner_id FROM scanner))
@@ -162,6 +165,14 @@ func (s *IndexerStore) IndexPackages(ctx context.Context, pkgs []*claircore.Pack
 			skipCt++
 			continue
 		}
+		var elf []byte
+		if pkg.ELF != nil {
+			var err error
+			elf, err = json.Marshal(pkg.ELF)
+			if err != nil {
+				return fmt.Errorf("failed to marshal ELF info for %v: %w", pkg, err)
+			}
+		}
 		err := mBatcher.Queue(
 			ctx,
 			insertPackageScanArtifactWithStmt.SQL,
@@ -182,6 +193,8 @@ func (s *IndexerStore) IndexPackages(ctx context.Context, pkgs []*claircore.Pack
 			pkg.PackageDB,
 			pkg.RepositoryHint,
 			pkg.Filepath,
+			pkg.Files,
+			elf,
 		)
 		if err != nil {
 			return fmt.Errorf("batch insert failed for package_scanartifact %v: %w", pkg, err)
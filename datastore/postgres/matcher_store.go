@@ -49,13 +49,21 @@ func NewMatcherStore(pool *pgxpool.Pool) *MatcherStore {
 }
 
 var (
-	_ datastore.Updater       = (*MatcherStore)(nil)
-	_ datastore.Vulnerability = (*MatcherStore)(nil)
+	_ datastore.Updater            = (*MatcherStore)(nil)
+	_ datastore.Vulnerability      = (*MatcherStore)(nil)
+	_ datastore.EnrichmentExporter = (*MatcherStore)(nil)
 )
 
 // DeleteUpdateOperations implements vulnstore.Updater.
+//
+// If any operations are deleted, the latest operations are recomputed, so a
+// deleted operation that was an updater's latest is replaced by the previous
+// one.
 func (s *MatcherStore) DeleteUpdateOperations(ctx context.Context, id ...uuid.UUID) (int64, error) {
-	const query = `DELETE FROM update_operation WHERE ref = ANY($1::uuid[]);`
+	const (
+		query       = `DELETE FROM update_operation WHERE ref = ANY($1::uuid[]);`
+		refreshView = `REFRESH MATERIALIZED VIEW CONCURRENTLY latest_update_operations;`
+	)
 	ctx = zlog.ContextWithValues(ctx, "component", "internal/vulnstore/postgres/deleteUpdateOperations")
 	if len(id) == 0 {
 		return 0, nil
@@ -71,6 +79,11 @@ func (s *MatcherStore) DeleteUpdateOperations(ctx context.Context, id ...uuid.UU
 	if err != nil {
 		return 0, fmt.Errorf("failed to delete: %w", err)
 	}
+	if tag.RowsAffected() != 0 {
+		if _, err := s.pool.Exec(ctx, refreshView); err != nil {
+			return tag.RowsAffected(), fmt.Errorf("could not refresh latest_update_operations: %w", err)
+		}
+	}
 	return tag.RowsAffected(), nil
 }
 
@@ -0,0 +1,2 @@
+-- The files a package owns, as found by the package scanner.
+ALTER TABLE package_scanartifact ADD COLUMN IF NOT EXISTS files text[];
@@ -0,0 +1,2 @@
+-- Details about ELF binaries, as found by the executable inventory scanner.
+ALTER TABLE package_scanartifact ADD COLUMN IF NOT EXISTS elf jsonb;
@@ -0,0 +1,11 @@
+-- Vulnerability details that only some updaters report: CVSS vectors and
+-- scores, the advisory's product CPEs, and the vendor's fix state.
+ALTER TABLE vuln
+	ADD COLUMN IF NOT EXISTS cvss_v2_vector TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS cvss_v2_score  DOUBLE PRECISION NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS cvss_v3_vector TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS cvss_v3_score  DOUBLE PRECISION NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS cvss_v4_vector TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS cvss_v4_score  DOUBLE PRECISION NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS affected_cpes  TEXT[] NOT NULL DEFAULT '{}',
+	ADD COLUMN IF NOT EXISTS fix_state      TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+-- Every CVSS vector of a vulnerability, for updaters that report more than
+-- one of each version, as a JSON array of claircore.CVSS objects.
+ALTER TABLE vuln
+	ADD COLUMN IF NOT EXISTS cvss JSONB NOT NULL DEFAULT '[]';
@@ -57,6 +57,14 @@ var IndexerMigrations = []migrate.Migration{
 		ID: 7,
 		Up: runFile("indexer/07-index-manifest_index.sql"),
 	},
+	{
+		ID: 8,
+		Up: runFile("indexer/08-package-files.sql"),
+	},
+	{
+		ID: 9,
+		Up: runFile("indexer/09-package-elf.sql"),
+	},
 }
 
 var MatcherMigrations = []migrate.Migration{
@@ -108,4 +116,12 @@ var MatcherMigrations = []migrate.Migration{
 		ID: 12,
 		Up: runFile("matcher/12-add-latest_update_operation-index.sql"),
 	},
+	{
+		ID: 13,
+		Up: runFile("matcher/13-vuln-details.sql"),
+	},
+	{
+		ID: 14,
+		Up: runFile("matcher/14-vuln-cvss.sql"),
+	},
 }
@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strconv"
@@ -66,7 +67,9 @@ SELECT
 	source_package.arch,
 	package_scanartifact.package_db,
 	package_scanartifact.repository_hint,
-	package_scanartifact.filepath
+	package_scanartifact.filepath,
+	package_scanartifact.files,
+	package_scanartifact.elf
 FROM
 	package_scanartifact
 	LEFT JOIN package ON
@@ -122,6 +125,8 @@ WHERE
 		var id, srcID int64
 		var nKind, fPath *string
 		var nVer pgtype.Int4Array
+		var files pgtype.TextArray
+		var elf pgtype.JSONB
 		err := rows.Scan(
 			&id,
 			&pkg.Name,
@@ -142,6 +147,8 @@ WHERE
 			&pkg.PackageDB,
 			&pkg.RepositoryHint,
 			&fPath,
+			&files,
+			&elf,
 		)
 		pkg.ID = strconv.FormatInt(id, 10)
 		spkg.ID = strconv.FormatInt(srcID, 10)
@@ -157,6 +164,17 @@ WHERE
 		if fPath != nil {
 			pkg.Filepath = *fPath
 		}
+		if files.Status == pgtype.Present {
+			if err := files.AssignTo(&pkg.Files); err != nil {
+				return nil, fmt.Errorf("failed to scan package files: %w", err)
+			}
+		}
+		if elf.Status == pgtype.Present {
+			pkg.ELF = new(claircore.ELFInfo)
+			if err := json.Unmarshal(elf.Bytes, pkg.ELF); err != nil {
+				return nil, fmt.Errorf("failed to scan package ELF info: %w", err)
+			}
+		}
 		// nest source package
 		pkg.Source = &spkg
 
@@ -95,7 +95,7 @@ func buildGetQuery(record *claircore.IndexRecord, opts *datastore.GetOpts) (stri
 	}
 	exps = append(exps, goqu.I("latest_update_operations.kind").Eq("vulnerability"))
 
-	query := psql.Select(
+	cols := []interface{}{
 		"vuln.id",
 		"name",
 		"description",
@@ -122,7 +122,11 @@ func buildGetQuery(record *claircore.IndexRecord, opts *datastore.GetOpts) (stri
 		"repo_uri",
 		"fixed_in_version",
 		"vuln.updater",
-	).From("vuln").
+	}
+	for _, c := range detailColumns {
+		cols = append(cols, c)
+	}
+	query := psql.Select(cols...).From("vuln").
 		Join(goqu.I("uo_vuln"), goqu.On(goqu.Ex{"vuln.id": goqu.I("uo_vuln.vuln")})).
 		Join(goqu.I("latest_update_operations"), goqu.On(goqu.Ex{"latest_update_operations.id": goqu.I("uo_vuln.uo")})).
 		Where(exps...)
@@ -1,8 +1,11 @@
 package postgres
 
 import (
+	"encoding/json"
 	"strconv"
 
+	"github.com/jackc/pgtype"
+
 	"github.com/quay/claircore"
 )
 
@@ -12,7 +15,8 @@ type scanner interface {
 
 func scanVulnerability(v *claircore.Vulnerability, row scanner) error {
 	var id uint64
-	if err := row.Scan(
+	var d vulnDetails
+	dest := []interface{}{
 		&id,
 		&v.Name,
 		&v.Updater,
@@ -39,9 +43,72 @@ func scanVulnerability(v *claircore.Vulnerability, row scanner) error {
 		&v.Repo.Key,
 		&v.Repo.URI,
 		&v.FixedInVersion,
-	); err != nil {
+	}
+	if err := row.Scan(append(dest, d.dest(v)...)...); err != nil {
+		return err
+	}
+	if err := d.apply(v); err != nil {
 		return err
 	}
 	v.ID = strconv.FormatUint(id, 10)
 	return nil
 }
+
+// DetailColumns are the columns of the vuln table holding the Vulnerability
+// members only some updaters populate, in the order expected by
+// vulnDetails.dest.
+var detailColumns = []string{
+	"cvss_v2_vector",
+	"cvss_v2_score",
+	"cvss_v3_vector",
+	"cvss_v3_score",
+	"cvss_v4_vector",
+	"cvss_v4_score",
+	"affected_cpes",
+	"fix_state",
+	"cvss",
+}
+
+// VulnDetails holds the detail columns that can't be scanned directly into a
+// Vulnerability.
+type vulnDetails struct {
+	cpes     pgtype.TextArray
+	fixState string
+	cvss     []byte
+}
+
+// Dest returns the scan destinations for the detailColumns.
+func (d *vulnDetails) dest(v *claircore.Vulnerability) []interface{} {
+	return []interface{}{
+		&v.CVSSv2Vector,
+		&v.CVSSv2Score,
+		&v.CVSSv3Vector,
+		&v.CVSSv3Score,
+		&v.CVSSv4Vector,
+		&v.CVSSv4Score,
+		&d.cpes,
+		&d.fixState,
+		&d.cvss,
+	}
+}
+
+// Apply copies the scanned values into "v".
+func (d *vulnDetails) apply(v *claircore.Vulnerability) error {
+	v.FixState = claircore.FixState(d.fixState)
+	v.AffectedCPEs = nil
+	if d.cpes.Status == pgtype.Present && len(d.cpes.Elements) != 0 {
+		if err := d.cpes.AssignTo(&v.AffectedCPEs); err != nil {
+			return err
+		}
+	}
+	v.CVSS = nil
+	if len(d.cvss) != 0 {
+		if err := json.Unmarshal(d.cvss, &v.CVSS); err != nil {
+			return err
+		}
+		if len(v.CVSS) == 0 {
+			v.CVSS = nil
+		}
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"crypto/md5"
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
@@ -62,14 +63,18 @@ func (s *MatcherStore) UpdateVulnerabilities(ctx context.Context, updater string
 			package_name, package_version, package_module, package_arch, package_kind,
 			dist_id, dist_name, dist_version, dist_version_code_name, dist_version_id, dist_arch, dist_cpe, dist_pretty_name,
 			repo_name, repo_key, repo_uri,
-			fixed_in_version, arch_operation, version_kind, vulnerable_range
+			fixed_in_version, arch_operation, version_kind, vulnerable_range,
+			cvss_v2_vector, cvss_v2_score, cvss_v3_vector, cvss_v3_score, cvss_v4_vector, cvss_v4_score,
+			affected_cpes, fix_state, cvss
 		) VALUES (
 		  $1, $2,
 		  $3, $4, $5, $6, $7, $8, $9,
 		  $10, $11, $12, $13, $14,
 		  $15, $16, $17, $18, $19, $20, $21, $22,
 		  $23, $24, $25,
-		  $26, $27, $28, VersionRange($29, $30)
+		  $26, $27, $28, VersionRange($29, $30),
+		  $31, $32, $33, $34, $35, $36,
+		  $37, $38, $39
 		)
 		ON CONFLICT (hash_kind, hash) DO NOTHING;`
 		// Assoc associates an update operation and a vulnerability. It fails
@@ -140,6 +145,18 @@ func (s *MatcherStore) UpdateVulnerabilities(ctx context.Context, updater string
 		}
 		hashKind, hash := md5Vuln(vuln)
 		vKind, vrLower, vrUpper := rangefmt(vuln.Range)
+		cpes := vuln.AffectedCPEs
+		if cpes == nil {
+			cpes = []string{}
+		}
+		cvss := []byte(`[]`)
+		if len(vuln.CVSS) != 0 {
+			b, err := json.Marshal(vuln.CVSS)
+			if err != nil {
+				return uuid.Nil, fmt.Errorf("failed to encode CVSS vectors: %w", err)
+			}
+			cvss = b
+		}
 
 		err := mBatcher.Queue(ctx, insert,
 			hashKind, hash,
@@ -148,6 +165,8 @@ func (s *MatcherStore) UpdateVulnerabilities(ctx context.Context, updater string
 			dist.DID, dist.Name, dist.Version, dist.VersionCodeName, dist.VersionID, dist.Arch, dist.CPE, dist.PrettyName,
 			repo.Name, repo.Key, repo.URI,
 			vuln.FixedInVersion, vuln.ArchOperation, vKind, vrLower, vrUpper,
+			vuln.CVSSv2Vector, vuln.CVSSv2Score, vuln.CVSSv3Vector, vuln.CVSSv3Score, vuln.CVSSv4Vector, vuln.CVSSv4Score,
+			cpes, string(vuln.FixState), string(cvss),
 		)
 		if err != nil {
 			return uuid.Nil, fmt.Errorf("failed to queue vulnerability: %w", err)
@@ -219,6 +238,31 @@ func md5Vuln(v *claircore.Vulnerability) (string, []byte) {
 		b.WriteString(l)
 		b.WriteString(u)
 	}
+	// The details only some updaters report are only hashed if present, so
+	// that the hashes of vulnerabilities without them are unchanged.
+	for _, p := range []struct {
+		vec   string
+		score float64
+	}{
+		{v.CVSSv2Vector, v.CVSSv2Score},
+		{v.CVSSv3Vector, v.CVSSv3Score},
+		{v.CVSSv4Vector, v.CVSSv4Score},
+	} {
+		if p.vec != "" || p.score != 0 {
+			b.WriteString(p.vec)
+			b.WriteString(strconv.FormatFloat(p.score, 'g', -1, 64))
+		}
+	}
+	for _, c := range v.AffectedCPEs {
+		b.WriteString(c)
+	}
+	for _, c := range v.CVSS {
+		b.WriteString(c.Version)
+		b.WriteString(c.Vector)
+		b.WriteString(strconv.FormatFloat(c.Score, 'g', -1, 64))
+		b.WriteString(strconv.FormatBool(c.Authoritative))
+	}
+	b.WriteString(string(v.FixState))
 	s := md5.Sum(b.Bytes())
 	return "md5", s[:]
 }
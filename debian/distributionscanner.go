@@ -21,6 +21,7 @@ import (
 var (
 	_ indexer.DistributionScanner = (*DistributionScanner)(nil)
 	_ indexer.VersionedScanner    = (*DistributionScanner)(nil)
+	_ indexer.PathScanner         = (*DistributionScanner)(nil)
 )
 
 // DistributionScanner attempts to discover if a layer
@@ -36,6 +37,11 @@ func (*DistributionScanner) Version() string { return "3" }
 // Kind implements [indexer.VersionedScanner].
 func (*DistributionScanner) Kind() string { return "distribution" }
 
+// Paths implements [indexer.PathScanner].
+func (*DistributionScanner) Paths() []string {
+	return []string{osrelease.Path, osrelease.FallbackPath}
+}
+
 // Scan implements [indexer.DistributionScanner].
 func (ds *DistributionScanner) Scan(ctx context.Context, l *claircore.Layer) ([]*claircore.Distribution, error) {
 	defer trace.StartRegion(ctx, "Scanner.Scan").End()
@@ -26,6 +26,7 @@ const (
 var (
 	_ indexer.VersionedScanner = (*Scanner)(nil)
 	_ indexer.PackageScanner   = (*Scanner)(nil)
+	_ indexer.PathScanner      = (*DistrolessScanner)(nil)
 )
 
 // DistrolessScanner implements the scanner.PackageScanner interface.
@@ -45,6 +46,11 @@ func (ps *DistrolessScanner) Version() string { return distrolessVersion }
 // Kind implements scanner.VersionedScanner.
 func (ps *DistrolessScanner) Kind() string { return distrolessKind }
 
+// Paths implements indexer.PathScanner.
+func (ps *DistrolessScanner) Paths() []string {
+	return []string{`**/status.d/*`}
+}
+
 // Scan attempts to find a dpkg database files in the layer and read all
 // of the installed packages it can find. These files are found in the
 // dpkg/status.d directory.
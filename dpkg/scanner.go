@@ -24,12 +24,13 @@ import (
 const (
 	name    = "dpkg"
 	kind    = "package"
-	version = "5"
+	version = "6"
 )
 
 var (
 	_ indexer.VersionedScanner = (*Scanner)(nil)
 	_ indexer.PackageScanner   = (*Scanner)(nil)
+	_ indexer.PathScanner      = (*Scanner)(nil)
 )
 
 // Scanner implements the scanner.PackageScanner interface.
@@ -49,6 +50,11 @@ func (ps *Scanner) Version() string { return version }
 // Kind implements scanner.VersionedScanner.
 func (ps *Scanner) Kind() string { return kind }
 
+// Paths implements indexer.PathScanner.
+func (ps *Scanner) Paths() []string {
+	return []string{`**/status`, `**/info/*.md5sums`, `**/info/*.list`}
+}
+
 // Scan attempts to find a dpkg database within the layer and read all of the
 // installed packages it can find in the "status" file.
 //
@@ -203,6 +209,9 @@ func (ps *Scanner) Scan(ctx context.Context, layer *claircore.Layer) ([]*clairco
 			}
 			p.RepositoryHint = hex.EncodeToString(hash.Sum(nil))
 		}
+		if err := packageFiles(ctx, sys, filepath.Join(p, "info"), found); err != nil {
+			return nil, err
+		}
 		zlog.Debug(ctx).
 			Int("count", len(found)).
 			Msg("found packages")
@@ -210,3 +219,49 @@ func (ps *Scanner) Scan(ctx context.Context, layer *claircore.Layer) ([]*clairco
 
 	return pkgs, nil
 }
+
+// PackageFiles populates the Files member of the packages in "found" from the
+// "*.list" files in the dpkg info directory "dir".
+//
+// The lists include the directories a package creates, which are omitted.
+func packageFiles(ctx context.Context, sys fs.FS, dir string, found map[string]*claircore.Package) error {
+	const suffix = ".list"
+	ls, err := fs.Glob(sys, filepath.Join(dir, "*"+suffix))
+	if err != nil {
+		return fmt.Errorf("dpkg: unable to find file lists: %w", err)
+	}
+	for _, n := range ls {
+		k := strings.TrimSuffix(filepath.Base(n), suffix)
+		if i := strings.IndexRune(k, ':'); i != -1 {
+			k = k[:i]
+		}
+		p, ok := found[k]
+		if !ok {
+			continue
+		}
+		f, err := sys.Open(n)
+		if err != nil {
+			return fmt.Errorf("dpkg: unable to open file %q: %w", n, err)
+		}
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			l := strings.TrimPrefix(s.Text(), "/")
+			if l == "" || l == "." {
+				continue
+			}
+			if fi, err := fs.Stat(sys, l); err == nil && fi.IsDir() {
+				continue
+			}
+			p.Files = append(p.Files, l)
+		}
+		f.Close()
+		if err := s.Err(); err != nil {
+			zlog.Warn(ctx).
+				Err(err).
+				Str("package", n).
+				Msg("unable to read package file list")
+			p.Files = nil
+		}
+	}
+	return nil
+}
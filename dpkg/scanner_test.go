@@ -10,6 +10,7 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/quay/zlog"
 
 	"github.com/quay/claircore"
@@ -807,8 +808,10 @@ func TestScanner(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if !cmp.Equal(got, want) {
-		t.Fatal(cmp.Diff(got, want))
+	// File lists are checked in TestPackageFiles.
+	opt := cmpopts.IgnoreFields(claircore.Package{}, "Files")
+	if !cmp.Equal(got, want, opt) {
+		t.Fatal(cmp.Diff(got, want, opt))
 	}
 }
 
@@ -905,6 +908,84 @@ Version: 1
 	}
 }
 
+func TestPackageFiles(t *testing.T) {
+	t.Parallel()
+	mod := test.Modtime(t, "scanner_test.go")
+	layerfile := test.GenerateFixture(t, `packagefiles.layer`, mod, packageFilesSetup)
+	ctx := zlog.Test(context.Background(), t)
+	var l claircore.Layer
+	var s Scanner
+
+	f, err := os.Open(layerfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := l.Init(ctx, &test.AnyDescription, f); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := l.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	ps, err := s.Scan(ctx, &l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(ps), 1; got != want {
+		t.Fatalf("checking length, got: %d, want: %d", got, want)
+	}
+	want := []string{"usr/bin/hello", "usr/share/doc/hello/copyright"}
+	if got := ps[0].Files; !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(got, want))
+	}
+}
+
+// PackageFilesSetup crafts a layer with a file list for one package.
+func packageFilesSetup(t testing.TB, f *os.File) {
+	w := tar.NewWriter(f)
+	defer func() {
+		if err := w.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+	for _, n := range []string{
+		"usr/",
+		"usr/bin/",
+		"usr/share/",
+		"usr/share/doc/",
+		"usr/share/doc/hello/",
+		"var/lib/dpkg/",
+		"var/lib/dpkg/info/",
+	} {
+		if err := w.WriteHeader(&tar.Header{
+			Name:     n,
+			Typeflag: tar.TypeDir,
+		}); err != nil {
+			t.Error(err)
+		}
+	}
+	for _, f := range []struct{ Name, Contents string }{
+		{"usr/bin/hello", "#!/bin/sh\n"},
+		{"usr/share/doc/hello/copyright", "none\n"},
+		{"var/lib/dpkg/info/hello:amd64.list", "/.\n/usr\n/usr/bin\n/usr/bin/hello\n/usr/share/doc/hello\n/usr/share/doc/hello/copyright\n"},
+		{"var/lib/dpkg/info/other.list", "/usr/bin/other\n"},
+		{"var/lib/dpkg/status", "Package: hello\nStatus: install ok installed\nArchitecture: amd64\nVersion: 1\n\n"},
+	} {
+		if err := w.WriteHeader(&tar.Header{
+			Name: f.Name,
+			Size: int64(len(f.Contents)),
+		}); err != nil {
+			t.Error(err)
+		}
+		if _, err := io.WriteString(w, f.Contents); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
 // This is a giant status file because texlive was installed.
 func TestGiantStatus(t *testing.T) {
 	t.Parallel()
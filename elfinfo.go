@@ -0,0 +1,19 @@
+package claircore
+
+// ELFInfo describes an ELF executable or shared object.
+//
+// It's reported by scanners that inventory the binaries in a layer, so that
+// they can be matched against fingerprints of known-vulnerable libraries or
+// correlated with what's observed at runtime.
+type ELFInfo struct {
+	// Type is "executable" or "shared_object". Position-independent
+	// executables are reported as executables.
+	Type string `json:"type"`
+	// SONAME is the shared object name (DT_SONAME), if any.
+	SONAME string `json:"soname,omitempty"`
+	// Interpreter is the program interpreter (PT_INTERP), if any.
+	Interpreter string `json:"interpreter,omitempty"`
+	// Needed is the sonames of the shared objects the file depends on
+	// (DT_NEEDED), in order.
+	Needed []string `json:"needed,omitempty"`
+}
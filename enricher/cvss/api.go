@@ -0,0 +1,64 @@
+package cvss
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/quay/zlog"
+)
+
+const (
+	// PageSize is the maximum number of results the API returns per request.
+	pageSize = 2000
+	// MaxRange is the longest time range the API allows for
+	// "lastModStartDate" and "lastModEndDate".
+	maxRange = 120 * 24 * time.Hour
+
+	// TimeFormat is the layout of dates the API accepts.
+	timeFormat = `2006-01-02T15:04:05.000-07:00`
+)
+
+// Query requests all pages of results from the CVE API, calling "f" for each.
+// The Enricher's client waits between requests as needed for the API's rate
+// limits.
+// The "lastMod" window is used if "start" is not the zero Time.
+//
+// Only one page is requested if "one" is set, which is useful for getting
+// just the result count.
+func (e *Enricher) query(ctx context.Context, start, end time.Time, one bool, f func(*apiResponse) error) (total int, err error) {
+	ctx = zlog.ContextWithValues(ctx, "component", "enricher/cvss/Enricher/query")
+	v := make(url.Values)
+	if !start.IsZero() {
+		v.Set("lastModStartDate", start.UTC().Format(timeFormat))
+		v.Set("lastModEndDate", end.UTC().Format(timeFormat))
+	}
+	sz := e.pageSize
+	if one {
+		sz = 1
+	}
+	v.Set("resultsPerPage", strconv.Itoa(sz))
+	for idx := 0; ; {
+		v.Set("startIndex", strconv.Itoa(idx))
+		var res apiResponse
+		if err := e.api.Get(ctx, v, &res); err != nil {
+			return 0, fmt.Errorf("cvss: %w", err)
+		}
+		zlog.Debug(ctx).
+			Int("start", res.StartIndex).
+			Int("count", len(res.Vulnerabilities)).
+			Int("total", res.TotalResults).
+			Msg("fetched page")
+		if err := f(&res); err != nil {
+			return 0, err
+		}
+		total = res.TotalResults
+		idx += len(res.Vulnerabilities)
+		if one || len(res.Vulnerabilities) == 0 || idx >= total {
+			break
+		}
+	}
+	return total, nil
+}
@@ -1,16 +1,20 @@
 // Package cvss provides a cvss enricher.
+//
+// CVSS v3 data is fetched from the NVD's CVE API. The first update fetches
+// every CVE, which takes some time without an API key because of the API's
+// rate limits. Later updates only fetch the CVEs modified since the previous
+// one and merge them into a snapshot kept in a local cache directory.
 package cvss
 
 import (
-	"bytes"
-	"compress/gzip"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"net/url"
+	"os"
+	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
@@ -19,158 +23,196 @@ import (
 	"github.com/quay/zlog"
 
 	"github.com/quay/claircore"
+	"github.com/quay/claircore/enricher"
 	"github.com/quay/claircore/libvuln/driver"
+	"github.com/quay/claircore/pkg/nvd"
 	"github.com/quay/claircore/pkg/tmp"
 )
 
 var (
 	_ driver.Enricher          = (*Enricher)(nil)
 	_ driver.EnrichmentUpdater = (*Enricher)(nil)
-
-	defaultFeed *url.URL
 )
 
 const (
 	// Type is the type of data returned from the Enricher's Enrich method.
 	Type = `message/vnd.clair.map.vulnerability; enricher=clair.cvss schema=https://csrc.nist.gov/schema/nvd/feed/1.1/cvss-v3.x.json`
-	// DefaultFeeds is the default place to look for CVE feeds.
-	//
-	// The enricher expects the structure to mirror that found here: files
-	// organized by year, prefixed with `nvdcve-1.1-` and with `.meta` and
-	// `.json.gz` extensions.
+	// DefaultAPI is the default NVD CVE API endpoint.
 	//
 	//doc:url updater
+	DefaultAPI = `https://services.nvd.nist.gov/rest/json/cves/2.0`
+	// DefaultFeeds is the location of the retired NVD JSON 1.1 feeds.
+	//
+	// Deprecated: The NVD no longer publishes these feeds, and the Enricher
+	// uses DefaultAPI instead.
 	DefaultFeeds = `https://nvd.nist.gov/feeds/json/cve/1.1/`
 
 	// This appears above and must be the same.
 	name = `clair.cvss`
 
-	// First year for the yearly CVE feeds: https://nvd.nist.gov/vuln/data-feeds
-	firstYear = 2002
+	// These are the names of the files in the cache directory.
+	snapshotFile    = `cvss.json`
+	fingerprintFile = `fingerprint`
 )
 
-func init() {
-	var err error
-	defaultFeed, err = url.Parse(DefaultFeeds)
-	if err != nil {
-		panic(err)
-	}
+// Record is the CVSS v3 data for a CVE, as reported by the NVD.
+//
+// The fields follow the CVSS v3.x JSON schema.
+type Record struct {
+	// Version is the CVSS version, like "3.1".
+	Version string `json:"version"`
+	// VectorString is the CVSS vector.
+	VectorString string `json:"vectorString"`
+	// BaseScore is the CVSS base score.
+	BaseScore float64 `json:"baseScore"`
+	// BaseSeverity is the qualitative severity, like "HIGH".
+	BaseSeverity string `json:"baseSeverity"`
+
+	// These are the base metrics, like "NETWORK" or "LOW".
+	AttackVector          string `json:"attackVector,omitempty"`
+	AttackComplexity      string `json:"attackComplexity,omitempty"`
+	PrivilegesRequired    string `json:"privilegesRequired,omitempty"`
+	UserInteraction       string `json:"userInteraction,omitempty"`
+	Scope                 string `json:"scope,omitempty"`
+	ConfidentialityImpact string `json:"confidentialityImpact,omitempty"`
+	IntegrityImpact       string `json:"integrityImpact,omitempty"`
+	AvailabilityImpact    string `json:"availabilityImpact,omitempty"`
 }
 
+// Records provides typed access to the enrichments reported by the Enricher.
+var Records = enricher.Register[Record](Type)
+
 // Enricher provides CVSS data as enrichments to a VulnerabilityReport.
 //
 // Configure must be called before any other methods.
 type Enricher struct {
 	driver.NoopUpdater
-	c    *http.Client
-	feed *url.URL
+	api      *nvd.Client
+	cacheDir string
+
+	// This is only changed in tests.
+	pageSize int
 }
 
 // Config is the configuration for Enricher.
 type Config struct {
+	// URL is the NVD CVE API endpoint. DefaultAPI is used if not provided.
+	URL *string `json:"url" yaml:"url"`
+	// APIKey is an NVD API key, which raises the API's rate limits. See
+	// https://nvd.nist.gov/developers/request-an-api-key
+	APIKey *string `json:"api_key" yaml:"api_key"`
+	// CacheDir is the directory used to keep a snapshot of the fetched data,
+	// which allows updates to only fetch recent changes. If not provided, a
+	// directory in the user's cache directory is used. If set to the empty
+	// string, every update fetches all data.
+	CacheDir *string `json:"cache_dir" yaml:"cache_dir"`
+	// FeedRoot was the location of the NVD JSON 1.1 feeds.
+	//
+	// Deprecated: The feeds have been retired and this is ignored. Use URL to
+	// point at a mirror of the API.
 	FeedRoot *string `json:"feed_root" yaml:"feed_root"`
 }
 
 // Configure implements driver.Configurable.
 func (e *Enricher) Configure(ctx context.Context, f driver.ConfigUnmarshaler, c *http.Client) error {
+	ctx = zlog.ContextWithValues(ctx, "component", "enricher/cvss/Enricher/Configure")
 	var cfg Config
-	e.c = c
 	if err := f(&cfg); err != nil {
 		return err
 	}
 	if cfg.FeedRoot != nil {
-		if !strings.HasSuffix(*cfg.FeedRoot, "/") {
-			return fmt.Errorf("URL missing trailing slash: %q", *cfg.FeedRoot)
-		}
-		u, err := url.Parse(*cfg.FeedRoot)
+		zlog.Warn(ctx).
+			Str("feed_root", *cfg.FeedRoot).
+			Msg(`"feed_root" is no longer supported and is ignored`)
+	}
+	u := DefaultAPI
+	if cfg.URL != nil {
+		u = *cfg.URL
+	}
+	var key string
+	if cfg.APIKey != nil {
+		key = *cfg.APIKey
+	}
+	var err error
+	e.api, err = nvd.NewClient(c, u, key)
+	if err != nil {
+		return fmt.Errorf("cvss: %w", err)
+	}
+	e.pageSize = pageSize
+
+	switch {
+	case cfg.CacheDir != nil:
+		e.cacheDir = *cfg.CacheDir
+	default:
+		d, err := os.UserCacheDir()
 		if err != nil {
-			return err
+			zlog.Info(ctx).
+				Err(err).
+				Msg("no cache directory, incremental updates disabled")
+			e.cacheDir = ""
+			break
 		}
-		e.feed = u
-	} else {
-		var err error
-		e.feed, err = defaultFeed.Parse(".")
-		if err != nil {
-			panic("programmer error: " + err.Error())
+		e.cacheDir = filepath.Join(d, "claircore", "cvss")
+	}
+	if e.cacheDir != "" {
+		if err := os.MkdirAll(e.cacheDir, 0o755); err != nil {
+			zlog.Warn(ctx).
+				Err(err).
+				Str("dir", e.cacheDir).
+				Msg("unable to create cache directory, incremental updates disabled")
+			e.cacheDir = ""
 		}
 	}
 	return nil
 }
 
-func metafileURL(root *url.URL, yr int) (*url.URL, error) {
-	return root.Parse(fmt.Sprintf("nvdcve-1.1-%d.meta", yr))
-}
-
-func gzURL(root *url.URL, yr int) (*url.URL, error) {
-	return root.Parse(fmt.Sprintf("nvdcve-1.1-%d.json.gz", yr))
-}
-
 // Name implements driver.Enricher and driver.EnrichmentUpdater.
 func (*Enricher) Name() string { return name }
 
+// Fingerprint is the Enricher's state between updates.
+type fingerprint struct {
+	// LastModified is the time the data was last fetched as of.
+	LastModified time.Time `json:"last_modified"`
+}
+
 // FetchEnrichment implements driver.EnrichmentUpdater.
+//
+// If the previous update was recent enough and its snapshot is in the cache
+// directory, only the CVEs modified since are fetched. Otherwise, all CVEs are
+// fetched.
 func (e *Enricher) FetchEnrichment(ctx context.Context, hint driver.Fingerprint) (io.ReadCloser, driver.Fingerprint, error) {
 	ctx = zlog.ContextWithValues(ctx, "component", "enricher/cvss/Enricher/FetchEnrichment")
 
-	// year → sha256
-	prev := make(map[int]string)
+	var prev fingerprint
 	if err := json.Unmarshal([]byte(hint), &prev); err != nil && hint != "" {
 		return nil, driver.Fingerprint(""), err
 	}
-	cur := make(map[int]string, len(prev))
-	yrs := make([]int, 0)
-
-	for y, lim := firstYear, time.Now().Year(); y <= lim; y++ {
-		yrs = append(yrs, y)
-		u, err := metafileURL(e.feed, y)
-		if err != nil {
-			return nil, hint, err
-		}
-		zlog.Debug(ctx).
-			Int("year", y).
-			Stringer("url", u).
-			Msg("fetching meta file")
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
-		if err != nil {
-			return nil, hint, err
-		}
-		res, err := e.c.Do(req)
-		if err != nil {
-			return nil, hint, err
-		}
-		var buf bytes.Buffer
-		_, err = io.Copy(&buf, res.Body)
-		res.Body.Close() // Don't defer because we're in a loop.
+	// Fingerprints from the JSON 1.1 feeds have no LastModified, so are
+	// treated like no fingerprint.
+	now := time.Now()
+	incremental := !prev.LastModified.IsZero() && now.Sub(prev.LastModified) < maxRange
+	var snap *os.File
+	if incremental {
+		snap = e.openSnapshot(ctx, hint)
+	}
+	if snap != nil {
+		defer snap.Close()
+	}
+	if incremental && snap == nil {
+		// Without a snapshot everything needs to be fetched, but it's
+		// possible nothing has changed.
+		total, err := e.query(ctx, prev.LastModified, now, true, func(*apiResponse) error { return nil })
 		if err != nil {
 			return nil, hint, err
 		}
-		var mf metafile
-		if err := mf.Parse(&buf); err != nil {
-			return nil, hint, err
+		if total == 0 {
+			return nil, hint, driver.Unchanged
 		}
-		zlog.Debug(ctx).
-			Int("year", y).
-			Stringer("url", u).
-			Time("mod", mf.LastModified).
-			Msg("parsed meta file")
-		cur[y] = strings.ToUpper(mf.SHA256)
-	}
-
-	doFetch := false
-	for _, y := range yrs {
-		if prev[y] != cur[y] {
-			zlog.Info(ctx).
-				Int("year", y).
-				Msg("change detected")
-			doFetch = true
-			break
-		}
-	}
-	if !doFetch {
-		return nil, hint, driver.Unchanged
 	}
 
-	out, err := tmp.NewFile("", "cvss.")
+	// The spool is created in the cache directory so it can be renamed into
+	// place.
+	out, err := tmp.NewFile(e.cacheDir, "cvss.")
 	if err != nil {
 		return nil, hint, err
 	}
@@ -182,52 +224,113 @@ func (e *Enricher) FetchEnrichment(ctx context.Context, hint driver.Fingerprint)
 			}
 		}
 	}()
-	// Doing this serially is slower, but much less complicated than using an
-	// ErrGroup or the like.
-	//
-	// It may become an issue in 25-30 years.
-	for _, y := range yrs {
-		u, err := gzURL(e.feed, y)
-		if err != nil {
-			return nil, hint, fmt.Errorf("bad URL: %w", err)
-		}
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
-		if err != nil {
-			return nil, hint, fmt.Errorf("unable to create request: %w", err)
-		}
-		zlog.Debug(ctx).
-			Int("year", y).
-			Stringer("url", u).
-			Msg("requesting json")
-		res, err := e.c.Do(req)
+	w := newRecordWriter(out)
+	switch {
+	case snap != nil:
+		changes := make(map[string]json.RawMessage)
+		total, err := e.query(ctx, prev.LastModified, now, false, func(res *apiResponse) error {
+			for i := range res.Vulnerabilities {
+				c := &res.Vulnerabilities[i].CVE
+				changes[c.ID] = c.CVSS()
+			}
+			return nil
+		})
 		if err != nil {
-			return nil, hint, fmt.Errorf("unable to do request: %w", err)
+			return nil, hint, err
 		}
-		gz, err := gzip.NewReader(res.Body)
-		if err != nil {
-			res.Body.Close()
-			return nil, hint, fmt.Errorf("unable to create gzip reader: %w", err)
+		if total == 0 {
+			return nil, hint, driver.Unchanged
 		}
-		f, err := newItemFeed(y, gz)
-		gz.Close()
-		res.Body.Close()
-		if err != nil {
-			return nil, hint, fmt.Errorf("unable to process item feed: %w", err)
+		zlog.Info(ctx).
+			Time("since", prev.LastModified).
+			Int("count", len(changes)).
+			Msg("fetched changes")
+		if err := w.Merge(ctx, snap, changes); err != nil {
+			return nil, hint, fmt.Errorf("cvss: unable to merge changes: %w", err)
 		}
-		if err := f.WriteCVSS(ctx, out); err != nil {
-			return nil, hint, fmt.Errorf("unable to write item feed: %w", err)
+	default:
+		zlog.Info(ctx).
+			Msg("fetching all CVEs")
+		if _, err := e.query(ctx, time.Time{}, now, false, func(res *apiResponse) error {
+			for i := range res.Vulnerabilities {
+				if err := w.WriteCVE(&res.Vulnerabilities[i].CVE); err != nil {
+					return fmt.Errorf("cvss: unable to write record: %w", err)
+				}
+			}
+			return nil
+		}); err != nil {
+			return nil, hint, err
 		}
 	}
+	zlog.Debug(ctx).
+		Uint("skip", w.skip).
+		Uint("wrote", w.wrote).
+		Msg("wrote cvss items")
 	if _, err := out.Seek(0, io.SeekStart); err != nil {
-		return nil, hint, fmt.Errorf("unable to reset item feed: %w", err)
+		return nil, hint, fmt.Errorf("cvss: unable to reset spool: %w", err)
 	}
-	success = true
 
-	nh, err := json.Marshal(cur)
+	nh, err := json.Marshal(fingerprint{LastModified: now.UTC()})
 	if err != nil {
 		panic(fmt.Errorf("unable to serialize new hint: %w", err))
 	}
-	return out, driver.Fingerprint(nh), nil
+	rc, err := e.commit(out, nh)
+	if err != nil {
+		// The snapshot is an optimization, so don't fail the update.
+		zlog.Warn(ctx).
+			Err(err).
+			Msg("unable to save snapshot")
+	}
+	success = true
+	return rc, driver.Fingerprint(nh), nil
+}
+
+// OpenSnapshot opens the snapshot in the cache directory, if there is one and
+// it's from the update with the fingerprint "fp".
+func (e *Enricher) openSnapshot(ctx context.Context, fp driver.Fingerprint) *os.File {
+	if e.cacheDir == "" {
+		return nil
+	}
+	b, err := os.ReadFile(filepath.Join(e.cacheDir, fingerprintFile))
+	switch {
+	case errors.Is(err, nil):
+	case errors.Is(err, os.ErrNotExist):
+		return nil
+	default:
+		zlog.Warn(ctx).Err(err).Msg("unable to read snapshot fingerprint")
+		return nil
+	}
+	if driver.Fingerprint(b) != fp {
+		zlog.Debug(ctx).
+			Msg("snapshot out of date")
+		return nil
+	}
+	f, err := os.Open(filepath.Join(e.cacheDir, snapshotFile))
+	if err != nil {
+		zlog.Warn(ctx).Err(err).Msg("unable to open snapshot")
+		return nil
+	}
+	return f
+}
+
+// Commit saves the spool "f" as the snapshot for the fingerprint "fp", and
+// returns the ReadCloser to hand back to the caller. Nothing is saved if
+// there's no cache directory.
+func (e *Enricher) commit(f *tmp.File, fp []byte) (io.ReadCloser, error) {
+	if e.cacheDir == "" {
+		return f, nil
+	}
+	// Remove the fingerprint first, so that a partial commit leaves an
+	// out-of-date snapshot.
+	fpf := filepath.Join(e.cacheDir, fingerprintFile)
+	if err := os.Remove(fpf); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return f, err
+	}
+	if err := os.Rename(f.Name(), filepath.Join(e.cacheDir, snapshotFile)); err != nil {
+		return f, err
+	}
+	// The spool is now the snapshot, so it must not be removed on close.
+	return f.File, os.WriteFile(fpf, fp, 0o644)
 }
 
 // ParseEnrichment implements driver.EnrichmentUpdater.
@@ -244,6 +347,8 @@ func (e *Enricher) ParseEnrichment(ctx context.Context, rc io.ReadCloser) ([]dri
 		ret = append(ret, driver.EnrichmentRecord{})
 		err = dec.Decode(&ret[len(ret)-1])
 	}
+	// The last element is always the failed decode.
+	ret = ret[:len(ret)-1]
 	zlog.Debug(ctx).
 		Int("count", len(ret)).
 		Msg("decoded enrichments")
@@ -1,26 +1,26 @@
 package cvss
 
 import (
-	"compress/gzip"
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
-	"path"
-	"path/filepath"
-	"strings"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/quay/zlog"
+	"golang.org/x/time/rate"
 
 	"github.com/quay/claircore"
 	"github.com/quay/claircore/libvuln/driver"
+	"github.com/quay/claircore/pkg/fetch"
+	"github.com/quay/claircore/pkg/nvd"
 )
 
 func TestConfigure(t *testing.T) {
@@ -35,30 +35,31 @@ func TestConfigure(t *testing.T) {
 			Config: func(i interface{}) error {
 				cfg := i.(*Config)
 				s := "http://example.com/"
-				cfg.FeedRoot = &s
+				k := "key"
+				d := ""
+				cfg.URL = &s
+				cfg.APIKey = &k
+				cfg.CacheDir = &d
 				return nil
 			},
 		},
 		{
-			Name:   "UnmarshalError",
-			Config: func(_ interface{}) error { return errors.New("expected error") },
-			Check: func(t *testing.T, err error) {
-				if err == nil {
-					t.Error("expected unmarshal error")
-				}
-			},
-		},
-		{
-			Name: "TrailingSlash",
+			Name: "FeedRoot",
 			Config: func(i interface{}) error {
 				cfg := i.(*Config)
-				s := "http://example.com"
+				s := "http://example.com/"
+				d := ""
 				cfg.FeedRoot = &s
+				cfg.CacheDir = &d
 				return nil
 			},
+		},
+		{
+			Name:   "UnmarshalError",
+			Config: func(_ interface{}) error { return errors.New("expected error") },
 			Check: func(t *testing.T, err error) {
 				if err == nil {
-					t.Error("expected trailing slash error")
+					t.Error("expected unmarshal error")
 				}
 			},
 		},
@@ -67,7 +68,7 @@ func TestConfigure(t *testing.T) {
 			Config: func(i interface{}) error {
 				cfg := i.(*Config)
 				s := "http://[notaurl:/"
-				cfg.FeedRoot = &s
+				cfg.URL = &s
 				return nil
 			},
 			Check: func(t *testing.T, err error) {
@@ -96,7 +97,7 @@ func (tc configTestcase) Run(ctx context.Context) func(*testing.T) {
 		if f == nil {
 			f = noopConfig
 		}
-		err := e.Configure(ctx, f, nil)
+		err := e.Configure(ctx, f, http.DefaultClient)
 		if tc.Check == nil {
 			if err != nil {
 				t.Errorf("unexpected err: %v", err)
@@ -112,10 +113,22 @@ func noopConfig(_ interface{}) error { return nil }
 func TestFetch(t *testing.T) {
 	t.Parallel()
 	ctx := zlog.Test(context.Background(), t)
-	srv := mockServer(t)
+	srv := newMockServer(t)
 	tt := []fetchTestcase{
 		{
 			Name: "Initial",
+			Check: func(t *testing.T, rc io.ReadCloser, fp driver.Fingerprint, err error) {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				var h fingerprint
+				if err := json.Unmarshal([]byte(fp), &h); err != nil {
+					t.Error(err)
+				}
+				if h.LastModified.IsZero() {
+					t.Errorf("bad fingerprint: %q", fp)
+				}
+			},
 		},
 		{
 			Name: "InvalidHint",
@@ -133,21 +146,25 @@ func TestFetch(t *testing.T) {
 				}
 			},
 		},
+		{
+			// Fingerprints from the JSON 1.1 feeds cause everything to be
+			// fetched.
+			Name: "LegacyHint",
+			Hint: `{"2021":"708083B92E47F0B25C7DD68B89ECD9EF3F2EF91403F511AE13195A596F02E02E"}`,
+			Check: func(t *testing.T, rc io.ReadCloser, _ driver.Fingerprint, err error) {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				if rc == nil {
+					t.Error("got nil ReadCloser")
+				}
+			},
+		},
 		{
 			Name: "Unchanged",
 			Hint: func() string {
-				// This is copied out of the metafile in testdata:
-				const h = `708083B92E47F0B25C7DD68B89ECD9EF3F2EF91403F511AE13195A596F02E02E`
-				var b strings.Builder
-				b.WriteByte('{')
-				for y, lim := firstYear, time.Now().Year(); y <= lim; y++ {
-					fmt.Fprintf(&b, `"%d":%q`, y, h)
-					if y != lim {
-						b.WriteByte(',')
-					}
-				}
-				b.WriteByte('}')
-				return b.String()
+				b, _ := json.Marshal(fingerprint{LastModified: time.Now().Add(-time.Hour)})
+				return string(b)
 			}(),
 			Check: func(t *testing.T, rc io.ReadCloser, _ driver.Fingerprint, err error) {
 				if rc != nil {
@@ -172,22 +189,10 @@ type fetchTestcase struct {
 	Hint  string
 }
 
-func (tc fetchTestcase) Run(ctx context.Context, srv *httptest.Server) func(*testing.T) {
-	e := &Enricher{}
+func (tc fetchTestcase) Run(ctx context.Context, srv *mockServer) func(*testing.T) {
 	return func(t *testing.T) {
 		ctx := zlog.Test(ctx, t)
-		f := func(i interface{}) error {
-			cfg, ok := i.(*Config)
-			if !ok {
-				t.Fatal("assertion failed")
-			}
-			u := srv.URL + "/"
-			cfg.FeedRoot = &u
-			return nil
-		}
-		if err := e.Configure(ctx, f, srv.Client()); err != nil {
-			t.Errorf("unexpected error: %v", err)
-		}
+		e := srv.Enricher(ctx, t, "")
 		rc, fp, err := e.FetchEnrichment(ctx, driver.Fingerprint(tc.Hint))
 		if rc != nil {
 			defer rc.Close()
@@ -202,43 +207,228 @@ func (tc fetchTestcase) Run(ctx context.Context, srv *httptest.Server) func(*tes
 	}
 }
 
-func mockServer(t *testing.T) *httptest.Server {
-	const root = `testdata/`
-	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		switch path.Ext(r.URL.Path) {
-		case ".gz": // return the gzipped feed
-			f, err := os.Open(filepath.Join(root, "feed.json"))
-			if err != nil {
-				t.Errorf("open failed: %v", err)
-				w.WriteHeader(http.StatusInternalServerError)
-				break
-			}
-			defer f.Close()
-			gz := gzip.NewWriter(w)
-			defer gz.Close()
-			if _, err := io.Copy(gz, f); err != nil {
-				t.Errorf("write error: %v", err)
-				w.WriteHeader(http.StatusInternalServerError)
-				break
+func TestIncremental(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	srv := newMockServer(t)
+	e := srv.Enricher(ctx, t, t.TempDir())
+
+	rc, fp, err := e.FetchEnrichment(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := e.ParseEnrichment(ctx, rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(rs), 218; got != want {
+		t.Errorf("got: %d records, want: %d records", got, want)
+	}
+
+	// Change one CVE's score, reject one, and add one, just after the
+	// previous fetch.
+	var h fingerprint
+	if err := json.Unmarshal([]byte(fp), &h); err != nil {
+		t.Fatal(err)
+	}
+	mod := h.LastModified.Add(time.Millisecond).Format("2006-01-02T15:04:05.000")
+	const (
+		changed  = "CVE-2021-0498"
+		rejected = "CVE-2021-32555"
+		added    = "CVE-2021-99999"
+	)
+	srv.Update(func(items []apiItem) []apiItem {
+		var add apiCVE
+		for i := range items {
+			c := &items[i].CVE
+			switch c.ID {
+			case changed:
+				c.LastModified = mod
+				c.Metrics.V31[0].CVSSData = json.RawMessage(`{"version":"3.1","baseScore":1.0}`)
+				add = *c
+			case rejected:
+				c.LastModified = mod
+				c.VulnStatus = "Rejected"
 			}
-		case ".meta": // return the metafile
-			http.ServeFile(w, r, filepath.Join(root, "feed.meta"))
-		default:
-			t.Errorf("unknown request path: %q", r.URL.Path)
+		}
+		add.ID = added
+		return append(items, apiItem{CVE: add})
+	})
+	time.Sleep(2 * time.Millisecond)
+
+	prev := srv.Requests()
+	rc, nfp, err := e.FetchEnrichment(ctx, fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nfp == fp {
+		t.Error("fingerprint didn't change")
+	}
+	rs, err = e.ParseEnrichment(ctx, rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := srv.Requests()-prev, 1; got != want {
+		t.Errorf("got: %d requests, want: %d requests", got, want)
+	}
+	if got, want := len(rs), 218; got != want {
+		t.Errorf("got: %d records, want: %d records", got, want)
+	}
+	got := make(map[string]string)
+	for _, r := range rs {
+		got[r.Tags[0]] = string(r.Enrichment)
+	}
+	if _, ok := got[rejected]; ok {
+		t.Errorf("found record for rejected CVE %s", rejected)
+	}
+	for _, id := range []string{changed, added} {
+		if got, want := got[id], `{"version":"3.1","baseScore":1.0}`; got != want {
+			t.Errorf("%s: got: %s, want: %s", id, got, want)
+		}
+	}
+}
+
+// MockServer serves the CVEs in testdata, like the CVE API. The first request
+// is answered as if the API is throttling requests.
+type mockServer struct {
+	*httptest.Server
+	mu    sync.Mutex
+	items []apiItem
+	reqs  int
+}
+
+func newMockServer(t *testing.T) *mockServer {
+	t.Helper()
+	b, err := os.ReadFile("testdata/cves.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res struct {
+		Vulnerabilities []apiItem `json:"vulnerabilities"`
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		t.Fatal(err)
+	}
+	m := &mockServer{items: res.Vulnerabilities}
+	m.Server = httptest.NewServer(m)
+	t.Cleanup(m.Close)
+	return m
+}
+
+func (m *mockServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reqs++
+	if m.reqs == 1 {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if r.Header.Get("apiKey") != "key" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	q := r.URL.Query()
+	var start time.Time
+	if s := q.Get("lastModStartDate"); s != "" {
+		var err error
+		if start, err = time.Parse(timeFormat, s); err != nil {
 			w.WriteHeader(http.StatusBadRequest)
+			return
 		}
-	}))
-	t.Cleanup(srv.Close)
-	return srv
+		if _, err := time.Parse(timeFormat, q.Get("lastModEndDate")); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	var sel []apiItem
+	for _, i := range m.items {
+		if !start.IsZero() {
+			mod, err := time.Parse("2006-01-02T15:04:05.000", i.CVE.LastModified)
+			if err != nil || mod.Before(start) {
+				continue
+			}
+		}
+		sel = append(sel, i)
+	}
+	idx, _ := strconv.Atoi(q.Get("startIndex"))
+	sz, _ := strconv.Atoi(q.Get("resultsPerPage"))
+	res := apiResponse{
+		ResultsPerPage: sz,
+		StartIndex:     idx,
+		TotalResults:   len(sel),
+	}
+	if idx < len(sel) {
+		sel = sel[idx:]
+		if len(sel) > sz {
+			sel = sel[:sz]
+		}
+		res.Vulnerabilities = sel
+	}
+	if err := json.NewEncoder(w).Encode(&res); err != nil {
+		panic(err)
+	}
+}
+
+// Update replaces the served CVEs with the result of "f".
+func (m *mockServer) Update(f func([]apiItem) []apiItem) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items = f(m.items)
+}
+
+// Requests reports the number of requests served.
+func (m *mockServer) Requests() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.reqs
+}
+
+// Enricher returns an Enricher configured to use the server, with small pages
+// and no pauses.
+func (m *mockServer) Enricher(ctx context.Context, t *testing.T, cache string) *Enricher {
+	t.Helper()
+	e := &Enricher{}
+	f := func(i interface{}) error {
+		cfg, ok := i.(*Config)
+		if !ok {
+			t.Fatal("assertion failed")
+		}
+		u := m.URL + "/rest/json/cves/2.0"
+		k := "key"
+		cfg.URL = &u
+		cfg.APIKey = &k
+		cfg.CacheDir = &cache
+		return nil
+	}
+	if err := e.Configure(ctx, f, m.Client()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var err error
+	e.api, err = nvd.NewClient(m.Client(), m.URL+"/rest/json/cves/2.0", "key",
+		fetch.WithRateLimit(rate.Inf, 1),
+		fetch.WithBackoff(time.Millisecond, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.pageSize = 100
+	return e
 }
 
 func TestParse(t *testing.T) {
 	t.Parallel()
 	ctx := zlog.Test(context.Background(), t)
-	srv := mockServer(t)
+	srv := newMockServer(t)
 	tt := []parseTestcase{
 		{
 			Name: "OK",
+			Check: func(t *testing.T, rs []driver.EnrichmentRecord, err error) {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				if got, want := len(rs), 218; got != want {
+					t.Errorf("got: %d records, want: %d records", got, want)
+				}
+			},
 		},
 	}
 	for _, tc := range tt {
@@ -251,25 +441,13 @@ type parseTestcase struct {
 	Name  string
 }
 
-func (tc parseTestcase) Run(ctx context.Context, srv *httptest.Server) func(*testing.T) {
-	e := &Enricher{}
+func (tc parseTestcase) Run(ctx context.Context, srv *mockServer) func(*testing.T) {
 	return func(t *testing.T) {
 		ctx := zlog.Test(ctx, t)
-		f := func(i interface{}) error {
-			cfg, ok := i.(*Config)
-			if !ok {
-				t.Fatal("assertion failed")
-			}
-			u := srv.URL + "/"
-			cfg.FeedRoot = &u
-			return nil
-		}
-		if err := e.Configure(ctx, f, srv.Client()); err != nil {
-			t.Errorf("unexpected error: %v", err)
-		}
+		e := srv.Enricher(ctx, t, "")
 		rc, _, err := e.FetchEnrichment(ctx, "")
 		if err != nil {
-			t.Errorf("unexpected error: %v", err)
+			t.Fatalf("unexpected error: %v", err)
 		}
 		defer rc.Close()
 		rs, err := e.ParseEnrichment(ctx, rc)
@@ -286,15 +464,15 @@ func (tc parseTestcase) Run(ctx context.Context, srv *httptest.Server) func(*tes
 func TestEnrich(t *testing.T) {
 	t.Parallel()
 	ctx := zlog.Test(context.Background(), t)
-	feedIn, err := os.Open("testdata/feed.json")
+	b, err := os.ReadFile("testdata/cves.json")
 	if err != nil {
 		t.Fatal(err)
 	}
-	f, err := newItemFeed(2021, feedIn)
-	if err != nil {
+	var feed apiResponse
+	if err := json.Unmarshal(b, &feed); err != nil {
 		t.Error(err)
 	}
-	g := &fakeGetter{itemFeed: f}
+	g := &fakeGetter{items: feed.Vulnerabilities}
 	r := &claircore.VulnerabilityReport{
 		Vulnerabilities: map[string]*claircore.Vulnerability{
 			"-1": {
@@ -359,16 +537,17 @@ func TestEnrich(t *testing.T) {
 }
 
 type fakeGetter struct {
-	*itemFeed
-	res []driver.EnrichmentRecord
+	items []apiItem
+	res   []driver.EnrichmentRecord
 }
 
 func (f *fakeGetter) GetEnrichment(ctx context.Context, tags []string) ([]driver.EnrichmentRecord, error) {
 	id := tags[0]
-	for _, cve := range f.items {
-		if cve.CVE.Meta.ID == id && cve.Impact.V3.CVSS != nil {
+	for i := range f.items {
+		c := &f.items[i].CVE
+		if cvss := c.CVSS(); c.ID == id && cvss != nil {
 			r := []driver.EnrichmentRecord{
-				{Tags: tags, Enrichment: cve.Impact.V3.CVSS},
+				{Tags: tags, Enrichment: cvss},
 			}
 			f.res = r
 			return r, nil
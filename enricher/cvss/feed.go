@@ -1,8 +1,10 @@
 package cvss
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 
 	"github.com/quay/zlog"
@@ -10,75 +12,141 @@ import (
 	"github.com/quay/claircore/libvuln/driver"
 )
 
-type cveFeed struct {
-	Count int             `json:"CVE_data_numberOfCVEs,string"`
-	Items json.RawMessage `json:"CVE_Items"`
+// ApiResponse is a page of results from the NVD CVE API.
+//
+// See https://nvd.nist.gov/developers/vulnerabilities for the full schema.
+type apiResponse struct {
+	ResultsPerPage  int       `json:"resultsPerPage"`
+	StartIndex      int       `json:"startIndex"`
+	TotalResults    int       `json:"totalResults"`
+	Vulnerabilities []apiItem `json:"vulnerabilities"`
 }
 
-// This is an envelope type so we can get at the cvssv3 object way in there.
-type cve struct {
-	CVE struct {
-		Meta struct {
-			ID string `json:"ID"`
-		} `json:"CVE_data_meta"`
-	} `json:"cve"`
-	Impact struct {
-		V3 struct {
-			CVSS json.RawMessage `json:"cvssV3"`
-		} `json:"baseMetricV3"`
-	} `json:"impact"`
+type apiItem struct {
+	CVE apiCVE `json:"cve"`
 }
 
-type itemFeed struct {
-	year  int
-	items []cve
+// This is an envelope type so we can get at the CVSS v3 objects in there.
+type apiCVE struct {
+	ID           string `json:"id"`
+	LastModified string `json:"lastModified"`
+	VulnStatus   string `json:"vulnStatus"`
+	Metrics      struct {
+		V31 []cvssMetric `json:"cvssMetricV31"`
+		V30 []cvssMetric `json:"cvssMetricV30"`
+	} `json:"metrics"`
 }
 
-func newItemFeed(year int, r io.Reader) (*itemFeed, error) {
-	var feed cveFeed
-	err := json.NewDecoder(r).Decode(&feed)
-	if err != nil {
-		return nil, err
+type cvssMetric struct {
+	Source   string          `json:"source"`
+	Type     string          `json:"type"`
+	CVSSData json.RawMessage `json:"cvssData"`
+}
+
+// CVSS reports the CVSS v3 object for the CVE, or nil if there isn't one or
+// the CVE has been rejected.
+//
+// CVSS v3.1 is preferred over v3.0, and the "Primary" score, which is the one
+// from the NVD, is preferred over secondary scores from CNAs.
+func (c *apiCVE) CVSS() json.RawMessage {
+	if c.VulnStatus == "Rejected" {
+		return nil
 	}
-	items := make([]cve, 0, feed.Count)
-	err = json.Unmarshal(feed.Items, &items)
-	if err != nil {
-		return nil, err
+	for _, ms := range [][]cvssMetric{c.Metrics.V31, c.Metrics.V30} {
+		for _, m := range ms {
+			if m.Type == "Primary" {
+				return m.CVSSData
+			}
+		}
+		if len(ms) != 0 {
+			return ms[0].CVSSData
+		}
 	}
-	return &itemFeed{
-		year:  year,
-		items: items,
-	}, nil
+	return nil
 }
 
 // Enricher data is written as a series of objects instead of a slice (JSON
 // array) of objects to avoid needing to construct the slice and buffer the
 // entire serialization in memory.
 
-func (f *itemFeed) WriteCVSS(ctx context.Context, w io.Writer) error {
-	ctx = zlog.ContextWithValues(ctx, "component", "enricher/cvss/itemFeed/WriteCVSS")
+// RecordWriter writes EnrichmentRecords for CVEs, skipping any CVE it's
+// already seen.
+type recordWriter struct {
+	enc         *json.Encoder
+	seen        map[string]struct{}
+	skip, wrote uint
+}
+
+func newRecordWriter(w io.Writer) *recordWriter {
+	return &recordWriter{
+		enc:  json.NewEncoder(w),
+		seen: make(map[string]struct{}),
+	}
+}
+
+// WriteCVE writes the record for the CVE, if it has a CVSS v3 object.
+func (w *recordWriter) WriteCVE(c *apiCVE) error {
+	return w.write(c.ID, c.CVSS())
+}
+
+func (w *recordWriter) write(id string, cvss json.RawMessage) error {
+	if _, ok := w.seen[id]; ok {
+		return nil
+	}
+	w.seen[id] = struct{}{}
+	if cvss == nil {
+		w.skip++
+		return nil
+	}
 	// Use records directly because our parse step doesn't actually parse
 	// anything -- the Fetch step rips out the relevant JSON.
-	var skip, wrote uint
-	enc := json.NewEncoder(w)
-	for _, c := range f.items {
-		if c.Impact.V3.CVSS == nil {
-			skip++
+	r := driver.EnrichmentRecord{
+		Tags:       []string{id},
+		Enrichment: cvss,
+	}
+	if err := w.enc.Encode(&r); err != nil {
+		return err
+	}
+	w.wrote++
+	return nil
+}
+
+// Merge copies the records from a previously written file, replacing the
+// records for any CVE in "changes", then writes the records for the rest of
+// "changes".
+func (w *recordWriter) Merge(ctx context.Context, prev io.Reader, changes map[string]json.RawMessage) error {
+	ctx = zlog.ContextWithValues(ctx, "component", "enricher/cvss/recordWriter/Merge")
+	var replaced uint
+	dec := json.NewDecoder(bufio.NewReader(prev))
+	for {
+		var r driver.EnrichmentRecord
+		err := dec.Decode(&r)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if len(r.Tags) == 0 {
 			continue
 		}
-		r := driver.EnrichmentRecord{
-			Tags:       []string{c.CVE.Meta.ID},
-			Enrichment: c.Impact.V3.CVSS,
+		id := r.Tags[0]
+		if c, ok := changes[id]; ok {
+			r.Enrichment = c
+			replaced++
 		}
-		if err := enc.Encode(&r); err != nil {
+		if err := w.write(id, r.Enrichment); err != nil {
+			return err
+		}
+	}
+	for id, c := range changes {
+		if err := w.write(id, c); err != nil {
 			return err
 		}
-		wrote++
 	}
 	zlog.Debug(ctx).
-		Int("year", f.year).
-		Uint("skip", skip).
-		Uint("wrote", wrote).
-		Msg("wrote cvss items")
+		Uint("replaced", replaced).
+		Int("changes", len(changes)).
+		Msg("merged changes")
 	return nil
 }
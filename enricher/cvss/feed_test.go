@@ -2,28 +2,28 @@ package cvss
 
 import (
 	"bytes"
+	"encoding/json"
 	"os"
 	"testing"
-
-	"github.com/quay/zlog"
 )
 
 func TestFeedIngest(t *testing.T) {
-	ctx := zlog.Test(nil, t)
-	in, err := os.Open("testdata/feed.json")
+	b, err := os.ReadFile("testdata/cves.json")
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer in.Close()
-	f, err := newItemFeed(1970, in)
-	if err != nil {
+	var res apiResponse
+	if err := json.Unmarshal(b, &res); err != nil {
 		t.Error(err)
 	}
 	var out bytes.Buffer
-	if err := f.WriteCVSS(ctx, &out); err != nil {
-		t.Error(err)
+	w := newRecordWriter(&out)
+	for i := range res.Vulnerabilities {
+		if err := w.WriteCVE(&res.Vulnerabilities[i].CVE); err != nil {
+			t.Error(err)
+		}
 	}
-	b := out.Bytes()
+	b = out.Bytes()
 	c := bytes.IndexByte(b, '\n')
 	if c == -1 {
 		t.Error("no lines?")
@@ -0,0 +1,138 @@
+// Package enricher provides typed access to the enrichments in a
+// VulnerabilityReport.
+//
+// The Enrichments member of a VulnerabilityReport maps an enrichment type to
+// JSON messages, where each message is an object mapping vulnerability IDs to
+// arrays of records. Enricher packages register their type and record type
+// with [Register], so that callers can use the returned [Kind] to get
+// structured data instead of decoding the messages themselves. Registering a
+// type twice panics, so enrichers can't collide.
+package enricher
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/quay/claircore"
+)
+
+// Kind is a registered enrichment type, with records of type T.
+type Kind[T any] struct {
+	typ string
+}
+
+// Decoder is the type-erased part of Kind, used by the registry.
+type decoder interface {
+	decode(*claircore.VulnerabilityReport, string) ([]interface{}, error)
+}
+
+var registry = struct {
+	sync.RWMutex
+	m map[string]decoder
+}{
+	m: make(map[string]decoder),
+}
+
+// Register registers the enrichment type "typ", whose records are of type T.
+//
+// Register is meant to be called from package initialization. It panics if
+// the type has already been registered.
+func Register[T any](typ string) *Kind[T] {
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.m[typ]; ok {
+		panic(fmt.Sprintf("enricher: duplicate registration for type %q", typ))
+	}
+	k := &Kind[T]{typ: typ}
+	registry.m[typ] = k
+	return k
+}
+
+// Type reports the enrichment type.
+func (k *Kind[T]) Type() string { return k.typ }
+
+// Get reports all the records of this type in the VulnerabilityReport, keyed
+// by vulnerability ID.
+//
+// A nil map is returned if the report has no enrichments of this type.
+func (k *Kind[T]) Get(vr *claircore.VulnerabilityReport) (map[string][]T, error) {
+	msgs := vr.Enrichments[k.typ]
+	if len(msgs) == 0 {
+		return nil, nil
+	}
+	ret := make(map[string][]T)
+	for _, msg := range msgs {
+		var m map[string][]T
+		if err := json.Unmarshal(msg, &m); err != nil {
+			return nil, fmt.Errorf("enricher: unable to decode %q enrichment: %w", k.typ, err)
+		}
+		for id, rs := range m {
+			ret[id] = append(ret[id], rs...)
+		}
+	}
+	return ret, nil
+}
+
+// Vulnerability reports the records of this type for the vulnerability "id"
+// in the VulnerabilityReport.
+//
+// Every message is decoded on every call, so use Get when looking up many
+// vulnerabilities.
+func (k *Kind[T]) Vulnerability(vr *claircore.VulnerabilityReport, id string) ([]T, error) {
+	m, err := k.Get(vr)
+	if err != nil {
+		return nil, err
+	}
+	return m[id], nil
+}
+
+func (k *Kind[T]) decode(vr *claircore.VulnerabilityReport, id string) ([]interface{}, error) {
+	rs, err := k.Vulnerability(vr, id)
+	if err != nil || len(rs) == 0 {
+		return nil, err
+	}
+	ret := make([]interface{}, len(rs))
+	for i := range rs {
+		ret[i] = rs[i]
+	}
+	return ret, nil
+}
+
+// Types reports the registered enrichment types, sorted.
+func Types() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	ret := make([]string, 0, len(registry.m))
+	for t := range registry.m {
+		ret = append(ret, t)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Vulnerability reports all the records of registered types for the
+// vulnerability "id" in the VulnerabilityReport, keyed by enrichment type.
+// The values are of the record types passed to Register.
+//
+// Enrichments of types that aren't registered are skipped.
+func Vulnerability(vr *claircore.VulnerabilityReport, id string) (map[string][]interface{}, error) {
+	registry.RLock()
+	defer registry.RUnlock()
+	ret := make(map[string][]interface{})
+	for typ := range vr.Enrichments {
+		d, ok := registry.m[typ]
+		if !ok {
+			continue
+		}
+		rs, err := d.decode(vr, id)
+		if err != nil {
+			return nil, err
+		}
+		if len(rs) != 0 {
+			ret[typ] = rs
+		}
+	}
+	return ret, nil
+}
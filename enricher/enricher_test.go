@@ -0,0 +1,91 @@
+package enricher
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/quay/claircore"
+)
+
+type testRecord struct {
+	Score float64 `json:"score"`
+}
+
+const testType = `message/vnd.clair.map.vulnerability; enricher=test`
+
+var testKind = Register[testRecord](testType)
+
+func TestKind(t *testing.T) {
+	vr := &claircore.VulnerabilityReport{
+		Enrichments: map[string][]json.RawMessage{
+			testType: {
+				json.RawMessage(`{"1":[{"score":1}],"2":[{"score":2}]}`),
+				json.RawMessage(`{"1":[{"score":3}]}`),
+			},
+			"unregistered": {json.RawMessage(`{"1":["?"]}`)},
+		},
+	}
+
+	got, err := testKind.Get(vr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]testRecord{
+		"1": {{Score: 1}, {Score: 3}},
+		"2": {{Score: 2}},
+	}
+	if !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(got, want))
+	}
+
+	rs, err := testKind.Vulnerability(vr, "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(rs, want["2"]) {
+		t.Error(cmp.Diff(rs, want["2"]))
+	}
+
+	all, err := Vulnerability(vr, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantAll := map[string][]interface{}{
+		testType: {testRecord{Score: 1}, testRecord{Score: 3}},
+	}
+	if !cmp.Equal(all, wantAll) {
+		t.Error(cmp.Diff(all, wantAll))
+	}
+
+	t.Run("Missing", func(t *testing.T) {
+		got, err := testKind.Get(&claircore.VulnerabilityReport{})
+		if err != nil || got != nil {
+			t.Errorf("got (%v, %v), want (nil, nil)", got, err)
+		}
+	})
+	t.Run("Malformed", func(t *testing.T) {
+		vr := &claircore.VulnerabilityReport{
+			Enrichments: map[string][]json.RawMessage{
+				testType: {json.RawMessage(`[]`)},
+			},
+		}
+		if _, err := testKind.Get(vr); err == nil {
+			t.Error("expected decode error")
+		}
+	})
+	t.Run("Duplicate", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic")
+			}
+		}()
+		Register[testRecord](testType)
+	})
+	t.Run("Types", func(t *testing.T) {
+		if got, want := Types(), []string{testType}; !cmp.Equal(got, want) {
+			t.Error(cmp.Diff(got, want))
+		}
+	})
+}
@@ -0,0 +1,262 @@
+// Package epss provides an enricher for Exploit Prediction Scoring System
+// (EPSS) scores.
+//
+// EPSS scores estimate the probability that a CVE will be exploited in the
+// next 30 days. FIRST publishes the scores for every CVE daily, as a single
+// CSV file, which this package reads with
+// [github.com/quay/claircore/pkg/epss.Feed] and stores as enrichments keyed by
+// CVE. See https://www.first.org/epss/ for details.
+package epss
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/enricher"
+	"github.com/quay/claircore/enricher/internal/common"
+	"github.com/quay/claircore/internal/zreader"
+	"github.com/quay/claircore/libvuln/driver"
+	epssdata "github.com/quay/claircore/pkg/epss"
+	"github.com/quay/claircore/pkg/tmp"
+)
+
+var (
+	_ driver.Enricher          = (*Enricher)(nil)
+	_ driver.EnrichmentUpdater = (*Enricher)(nil)
+)
+
+const (
+	// Type is the type of data returned from the Enricher's Enrich method.
+	//
+	// The data is a JSON object mapping vulnerability IDs to arrays of
+	// [Record].
+	Type = `message/vnd.clair.map.vulnerability; enricher=clair.epss schema=https://github.com/quay/claircore/enricher/epss#Record`
+	// DefaultFeed is the default location of the EPSS scores.
+	//
+	// The enricher expects a (possibly compressed) CSV file with "cve",
+	// "epss", and "percentile" columns, optionally preceded by a comment line
+	// with the model version and score date.
+	//
+	//doc:url updater
+	DefaultFeed = `https://epss.cyentia.com/epss_scores-current.csv.gz`
+
+	// This appears above and must be the same.
+	name = `clair.epss`
+)
+
+// Record is the EPSS score for a CVE.
+type Record struct {
+	// CVE is the CVE that was scored, like "CVE-2024-0001".
+	CVE string `json:"cve"`
+	// EPSS is the probability of exploitation in the next 30 days, in the
+	// range [0, 1].
+	EPSS float64 `json:"epss"`
+	// Percentile is the proportion of all scored CVEs with the same or a lower
+	// probability, in the range [0, 1].
+	Percentile float64 `json:"percentile"`
+	// ModelVersion is the version of the EPSS model, if provided.
+	ModelVersion string `json:"modelVersion,omitempty"`
+	// Date is the day the score was computed, like "2024-01-01", if provided.
+	Date string `json:"date,omitempty"`
+}
+
+// Records gets the EPSS scores of the vulnerabilities in a VulnerabilityReport.
+var Records = enricher.Register[Record](Type)
+
+// Enricher provides EPSS scores as enrichments to a VulnerabilityReport.
+//
+// Configure must be called before any other methods.
+type Enricher struct {
+	driver.NoopUpdater
+	c    *http.Client
+	feed *url.URL
+}
+
+// Config is the configuration for Enricher.
+type Config struct {
+	// URL is the location of the EPSS CSV file. DefaultFeed is used if
+	// not provided.
+	URL *string `json:"url" yaml:"url"`
+}
+
+// Configure implements driver.Configurable.
+func (e *Enricher) Configure(ctx context.Context, f driver.ConfigUnmarshaler, c *http.Client) error {
+	var cfg Config
+	e.c = c
+	if err := f(&cfg); err != nil {
+		return err
+	}
+	u := DefaultFeed
+	if cfg.URL != nil {
+		u = *cfg.URL
+	}
+	var err error
+	e.feed, err = url.Parse(u)
+	if err != nil {
+		return fmt.Errorf("epss: bad URL: %w", err)
+	}
+	return nil
+}
+
+// Name implements driver.Enricher and driver.EnrichmentUpdater.
+func (*Enricher) Name() string { return name }
+
+// FetchEnrichment implements driver.EnrichmentUpdater.
+//
+// The returned fingerprint is the file's comment line, which names the model
+// version and score date. If the file has no comment line, it's always
+// considered changed.
+func (e *Enricher) FetchEnrichment(ctx context.Context, hint driver.Fingerprint) (io.ReadCloser, driver.Fingerprint, error) {
+	ctx = zlog.ContextWithValues(ctx, "component", "enricher/epss/Enricher/FetchEnrichment")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.feed.String(), nil)
+	if err != nil {
+		return nil, hint, fmt.Errorf("epss: unable to create request: %w", err)
+	}
+	res, err := e.c.Do(req)
+	if err != nil {
+		return nil, hint, fmt.Errorf("epss: unable to do request: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, hint, fmt.Errorf("epss: unexpected response for %q: %v", e.feed, res.Status)
+	}
+	zr, err := zreader.Reader(res.Body)
+	if err != nil {
+		return nil, hint, fmt.Errorf("epss: unable to decompress %q: %w", e.feed, err)
+	}
+	defer zr.Close()
+	feed, err := epssdata.NewFeed(zr)
+	if err != nil {
+		return nil, hint, fmt.Errorf("epss: unable to read %q: %w", e.feed, err)
+	}
+	fp := driver.Fingerprint(feed.Comment)
+	if fp != "" {
+		zlog.Debug(ctx).
+			Str("model", feed.ModelVersion).
+			Time("date", feed.Date).
+			Msg("found metadata")
+		if fp == hint {
+			return nil, hint, driver.Unchanged
+		}
+	}
+
+	out, err := tmp.NewFile("", "epss.")
+	if err != nil {
+		return nil, hint, err
+	}
+	var success bool
+	defer func() {
+		if !success {
+			if err := out.Close(); err != nil {
+				zlog.Warn(ctx).Err(err).Msg("unable to close spool")
+			}
+		}
+	}()
+	ct, err := writeRecords(out, feed)
+	if err != nil {
+		return nil, hint, fmt.Errorf("epss: unable to read %q: %w", e.feed, err)
+	}
+	if _, err := out.Seek(0, io.SeekStart); err != nil {
+		return nil, hint, fmt.Errorf("epss: unable to reset spool: %w", err)
+	}
+	zlog.Info(ctx).
+		Int("count", ct).
+		Msg("processed scores")
+	success = true
+	return out, fp, nil
+}
+
+// WriteRecords writes an EnrichmentRecord for every score in "feed" to "w",
+// reporting the number written.
+func writeRecords(w io.Writer, feed *epssdata.Feed) (int, error) {
+	enc := json.NewEncoder(w)
+	ct := 0
+	for {
+		id, s, err := feed.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return ct, err
+		}
+		rec := Record{
+			CVE:          id,
+			EPSS:         s.Probability,
+			Percentile:   s.Percentile,
+			ModelVersion: feed.ModelVersion,
+		}
+		if !s.Date.IsZero() {
+			rec.Date = s.Date.Format("2006-01-02")
+		}
+		b, err := json.Marshal(&rec)
+		if err != nil {
+			return ct, err
+		}
+		if err := enc.Encode(driver.EnrichmentRecord{
+			Tags:       []string{rec.CVE},
+			Enrichment: b,
+		}); err != nil {
+			return ct, fmt.Errorf("unable to write record: %w", err)
+		}
+		ct++
+	}
+	return ct, nil
+}
+
+// ParseEnrichment implements driver.EnrichmentUpdater.
+func (e *Enricher) ParseEnrichment(ctx context.Context, rc io.ReadCloser) ([]driver.EnrichmentRecord, error) {
+	ctx = zlog.ContextWithValues(ctx, "component", "enricher/epss/Enricher/ParseEnrichment")
+	return common.ParseEnrichment(ctx, rc)
+}
+
+// Enrich implements driver.Enricher.
+func (e *Enricher) Enrich(ctx context.Context, g driver.EnrichmentGetter, r *claircore.VulnerabilityReport) (string, []json.RawMessage, error) {
+	ctx = zlog.ContextWithValues(ctx, "component", "enricher/epss/Enricher/Enrich")
+
+	// We return any EPSS scores for CVEs mentioned in the free-form parts of
+	// the vulnerability.
+	m := make(map[string][]json.RawMessage)
+	erCache := make(map[string][]driver.EnrichmentRecord)
+	for id, v := range r.Vulnerabilities {
+		ts := common.CVEs(v)
+		if len(ts) == 0 {
+			continue
+		}
+		cveKey := strings.Join(ts, "_")
+		rec, ok := erCache[cveKey]
+		if !ok {
+			var err error
+			rec, err = g.GetEnrichment(ctx, ts)
+			if err != nil {
+				return "", nil, err
+			}
+			erCache[cveKey] = rec
+		}
+		zlog.Debug(ctx).
+			Str("vuln", v.Name).
+			Strs("cve", ts).
+			Int("count", len(rec)).
+			Msg("found records")
+		for _, r := range rec {
+			m[id] = append(m[id], r.Enrichment)
+		}
+	}
+	if len(m) == 0 {
+		return Type, nil, nil
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return Type, nil, err
+	}
+	return Type, []json.RawMessage{b}, nil
+}
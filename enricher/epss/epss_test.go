@@ -0,0 +1,137 @@
+package epss
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/libvuln/driver"
+)
+
+const (
+	scoresHeader = `#model_version:v2023.03.01,score_date:2024-01-01T00:00:00+0000`
+	scores       = scoresHeader + `
+cve,epss,percentile
+CVE-2023-0001,0.00043,0.0768
+CVE-2023-0002,0.97565,0.99985
+CVE-2024-0003,0.00219,0.59637
+`
+)
+
+func TestEnricher(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(scores)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write(buf.Bytes())
+	}))
+	defer srv.Close()
+
+	e := &Enricher{}
+	u := srv.URL + "/epss_scores-current.csv.gz"
+	if err := e.Configure(ctx, func(i interface{}) error {
+		i.(*Config).URL = &u
+		return nil
+	}, srv.Client()); err != nil {
+		t.Fatal(err)
+	}
+
+	rc, fp, err := e.FetchEnrichment(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fp, driver.Fingerprint(scoresHeader[1:]); got != want {
+		t.Errorf("got fingerprint %q, want %q", got, want)
+	}
+	rs, err := e.ParseEnrichment(ctx, rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]Record)
+	for _, r := range rs {
+		var rec Record
+		if err := json.Unmarshal(r.Enrichment, &rec); err != nil {
+			t.Fatal(err)
+		}
+		if !cmp.Equal(r.Tags, []string{rec.CVE}) {
+			t.Errorf("unexpected tags: %v", r.Tags)
+		}
+		got[rec.CVE] = rec
+	}
+	want := map[string]Record{
+		"CVE-2023-0001": {CVE: "CVE-2023-0001", EPSS: 0.00043, Percentile: 0.0768, ModelVersion: "v2023.03.01", Date: "2024-01-01"},
+		"CVE-2023-0002": {CVE: "CVE-2023-0002", EPSS: 0.97565, Percentile: 0.99985, ModelVersion: "v2023.03.01", Date: "2024-01-01"},
+		"CVE-2024-0003": {CVE: "CVE-2024-0003", EPSS: 0.00219, Percentile: 0.59637, ModelVersion: "v2023.03.01", Date: "2024-01-01"},
+	}
+	if !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(got, want))
+	}
+
+	if _, _, err := e.FetchEnrichment(ctx, fp); !errors.Is(err, driver.Unchanged) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	t.Run("Enrich", func(t *testing.T) {
+		ctx := zlog.Test(ctx, t)
+		g := &fakeGetter{m: make(map[string][]driver.EnrichmentRecord)}
+		for _, r := range rs {
+			g.m[r.Tags[0]] = append(g.m[r.Tags[0]], r)
+		}
+		vr := &claircore.VulnerabilityReport{
+			Vulnerabilities: map[string]*claircore.Vulnerability{
+				"1": {Name: "RHSA-2024:0001", Links: "https://access.redhat.com/security/cve/cve_2024_0003"},
+				"2": {Name: "CVE-2023-0001", Description: "See also CVE-2023-0002."},
+				"3": {Name: "GHSA-xxxx-xxxx-xxxx"},
+			},
+		}
+		typ, msgs, err := e.Enrich(ctx, g, vr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ != Type {
+			t.Errorf("got type %q, want %q", typ, Type)
+		}
+		if len(msgs) != 1 {
+			t.Fatalf("got %d messages, want 1", len(msgs))
+		}
+		var got map[string][]Record
+		if err := json.Unmarshal(msgs[0], &got); err != nil {
+			t.Fatal(err)
+		}
+		want := map[string][]Record{
+			"1": {want["CVE-2024-0003"]},
+			"2": {want["CVE-2023-0001"], want["CVE-2023-0002"]},
+		}
+		if !cmp.Equal(got, want) {
+			t.Error(cmp.Diff(got, want))
+		}
+	})
+}
+
+type fakeGetter struct {
+	m map[string][]driver.EnrichmentRecord
+}
+
+func (g *fakeGetter) GetEnrichment(_ context.Context, tags []string) ([]driver.EnrichmentRecord, error) {
+	var ret []driver.EnrichmentRecord
+	for _, t := range tags {
+		ret = append(ret, g.m[t]...)
+	}
+	return ret, nil
+}
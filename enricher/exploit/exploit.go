@@ -0,0 +1,347 @@
+// Package exploit provides an enricher for the availability of public
+// exploits.
+//
+// CVEs are mapped to the exploits published in the Exploit Database and the
+// modules of the Metasploit Framework, and summarized with an exploit
+// maturity, in the spirit of CVSS's "Exploit Code Maturity" metric.
+package exploit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/enricher"
+	"github.com/quay/claircore/enricher/internal/common"
+	"github.com/quay/claircore/libvuln/driver"
+	"github.com/quay/claircore/pkg/tmp"
+)
+
+var (
+	_ driver.Enricher          = (*Enricher)(nil)
+	_ driver.EnrichmentUpdater = (*Enricher)(nil)
+)
+
+const (
+	// Type is the type of data returned from the Enricher's Enrich method.
+	//
+	// The data is a JSON object mapping vulnerability IDs to arrays of
+	// [Record].
+	Type = `message/vnd.clair.map.vulnerability; enricher=clair.exploit schema=https://github.com/quay/claircore/enricher/exploit#Record`
+	// DefaultExploitDB is the default location of the Exploit Database index.
+	//
+	//doc:url updater
+	DefaultExploitDB = `https://gitlab.com/exploit-database/exploitdb/-/raw/main/files_exploits.csv`
+	// DefaultMetasploit is the default location of the Metasploit Framework
+	// module metadata.
+	//
+	//doc:url updater
+	DefaultMetasploit = `https://raw.githubusercontent.com/rapid7/metasploit-framework/master/db/modules_metadata_base.json`
+
+	// This appears above and must be the same.
+	name = `clair.exploit`
+)
+
+// Maturity is the maturity of the known exploits for a CVE.
+type Maturity string
+
+// These are the known Maturity values, from least to most mature.
+const (
+	// MaturityProofOfConcept means there's published exploit code that hasn't
+	// been verified to work.
+	MaturityProofOfConcept Maturity = "proof-of-concept"
+	// MaturityFunctional means there's published exploit code that has been
+	// verified to work.
+	MaturityFunctional Maturity = "functional"
+	// MaturityHigh means there's an exploit packaged in an exploitation
+	// framework, usable without specialized knowledge.
+	MaturityHigh Maturity = "high"
+)
+
+// Rank orders Maturity values. Unknown values have a rank of 0.
+func (m Maturity) rank() int {
+	switch m {
+	case MaturityProofOfConcept:
+		return 1
+	case MaturityFunctional:
+		return 2
+	case MaturityHigh:
+		return 3
+	}
+	return 0
+}
+
+// Record is the summary of the known exploits for a CVE.
+type Record struct {
+	// CVE is the CVE the exploits target.
+	CVE string `json:"cve"`
+	// Maturity is the highest maturity of the known exploits.
+	Maturity Maturity `json:"maturity"`
+	// Exploits is the list of known exploits.
+	Exploits []Exploit `json:"exploits"`
+}
+
+// Records gets the known exploits of the vulnerabilities in a VulnerabilityReport.
+var Records = enricher.Register[Record](Type)
+
+// Exploit is a single public exploit.
+type Exploit struct {
+	// Source is where the exploit is published: "exploit-db" or
+	// "metasploit".
+	Source string `json:"source"`
+	// ID is the exploit's identifier within its source: the EDB-ID for the
+	// Exploit Database, and the module's full name for Metasploit.
+	ID string `json:"id"`
+	// Name is the exploit's title.
+	Name string `json:"name,omitempty"`
+	// URL is a link to the exploit.
+	URL string `json:"url,omitempty"`
+	// Published is the day the exploit was published, like "2024-01-01", if
+	// known.
+	Published string `json:"published,omitempty"`
+	// Maturity is the maturity of this exploit.
+	Maturity Maturity `json:"maturity"`
+}
+
+// Enricher provides public exploit information as enrichments to a
+// VulnerabilityReport.
+//
+// Configure must be called before any other methods.
+type Enricher struct {
+	driver.NoopUpdater
+	c          *http.Client
+	exploitDB  *url.URL
+	metasploit *url.URL
+}
+
+// Config is the configuration for Enricher.
+type Config struct {
+	// ExploitDBURL is the location of the Exploit Database's
+	// "files_exploits.csv" index. DefaultExploitDB is used if not provided.
+	ExploitDBURL *string `json:"exploitdb_url" yaml:"exploitdb_url"`
+	// MetasploitURL is the location of the Metasploit Framework's
+	// "modules_metadata_base.json" file. DefaultMetasploit is used if not
+	// provided.
+	MetasploitURL *string `json:"metasploit_url" yaml:"metasploit_url"`
+}
+
+// Configure implements driver.Configurable.
+func (e *Enricher) Configure(ctx context.Context, f driver.ConfigUnmarshaler, c *http.Client) error {
+	var cfg Config
+	e.c = c
+	if err := f(&cfg); err != nil {
+		return err
+	}
+	var err error
+	u := DefaultExploitDB
+	if cfg.ExploitDBURL != nil {
+		u = *cfg.ExploitDBURL
+	}
+	e.exploitDB, err = url.Parse(u)
+	if err != nil {
+		return fmt.Errorf("exploit: bad Exploit Database URL: %w", err)
+	}
+	u = DefaultMetasploit
+	if cfg.MetasploitURL != nil {
+		u = *cfg.MetasploitURL
+	}
+	e.metasploit, err = url.Parse(u)
+	if err != nil {
+		return fmt.Errorf("exploit: bad Metasploit URL: %w", err)
+	}
+	return nil
+}
+
+// Name implements driver.Enricher and driver.EnrichmentUpdater.
+func (*Enricher) Name() string { return name }
+
+// Source is a feed of exploits.
+type source struct {
+	Name  string
+	URL   *url.URL
+	Parse func(io.Reader, func(cve string, x Exploit)) error
+}
+
+// FetchEnrichment implements driver.EnrichmentUpdater.
+//
+// The returned fingerprint records the digests of the fetched feeds.
+func (e *Enricher) FetchEnrichment(ctx context.Context, hint driver.Fingerprint) (io.ReadCloser, driver.Fingerprint, error) {
+	ctx = zlog.ContextWithValues(ctx, "component", "enricher/exploit/Enricher/FetchEnrichment")
+
+	// source → sha256
+	prev := make(map[string]string)
+	if err := json.Unmarshal([]byte(hint), &prev); err != nil && hint != "" {
+		return nil, hint, fmt.Errorf("exploit: bad fingerprint: %w", err)
+	}
+	cur := make(map[string]string, 2)
+	recs := make(map[string]*Record)
+	add := func(cve string, x Exploit) {
+		r, ok := recs[cve]
+		if !ok {
+			r = &Record{CVE: cve}
+			recs[cve] = r
+		}
+		r.Exploits = append(r.Exploits, x)
+		if x.Maturity.rank() > r.Maturity.rank() {
+			r.Maturity = x.Maturity
+		}
+	}
+	for _, s := range []source{
+		{Name: sourceExploitDB, URL: e.exploitDB, Parse: parseExploitDB},
+		{Name: sourceMetasploit, URL: e.metasploit, Parse: parseMetasploit},
+	} {
+		sum, err := e.fetch(ctx, s, add)
+		if err != nil {
+			return nil, hint, err
+		}
+		cur[s.Name] = sum
+	}
+
+	changed := false
+	for k, v := range cur {
+		if prev[k] != v {
+			zlog.Info(ctx).
+				Str("source", k).
+				Msg("change detected")
+			changed = true
+		}
+	}
+	if !changed {
+		return nil, hint, driver.Unchanged
+	}
+	fp, err := json.Marshal(cur)
+	if err != nil {
+		return nil, hint, fmt.Errorf("exploit: unable to encode fingerprint: %w", err)
+	}
+
+	out, err := tmp.NewFile("", "exploit.")
+	if err != nil {
+		return nil, hint, err
+	}
+	var success bool
+	defer func() {
+		if !success {
+			if err := out.Close(); err != nil {
+				zlog.Warn(ctx).Err(err).Msg("unable to close spool")
+			}
+		}
+	}()
+	cves := make([]string, 0, len(recs))
+	for cve := range recs {
+		cves = append(cves, cve)
+	}
+	sort.Strings(cves)
+	enc := json.NewEncoder(out)
+	for _, cve := range cves {
+		b, err := json.Marshal(recs[cve])
+		if err != nil {
+			return nil, hint, fmt.Errorf("exploit: unable to encode record: %w", err)
+		}
+		if err := enc.Encode(driver.EnrichmentRecord{
+			Tags:       []string{cve},
+			Enrichment: b,
+		}); err != nil {
+			return nil, hint, fmt.Errorf("exploit: unable to write record: %w", err)
+		}
+	}
+	if _, err := out.Seek(0, io.SeekStart); err != nil {
+		return nil, hint, fmt.Errorf("exploit: unable to reset spool: %w", err)
+	}
+	zlog.Info(ctx).
+		Int("count", len(cves)).
+		Msg("processed exploits")
+	success = true
+	return out, driver.Fingerprint(fp), nil
+}
+
+// Fetch downloads and parses the feed for the source, reporting the digest of
+// its contents.
+func (e *Enricher) fetch(ctx context.Context, s source, add func(string, Exploit)) (string, error) {
+	zlog.Debug(ctx).
+		Str("source", s.Name).
+		Stringer("url", s.URL).
+		Msg("fetching feed")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("exploit: unable to create request: %w", err)
+	}
+	res, err := e.c.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("exploit: unable to do request: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("exploit: unexpected response for %q: %v", s.URL, res.Status)
+	}
+	h := sha256.New()
+	if err := s.Parse(io.TeeReader(res.Body, h), add); err != nil {
+		return "", fmt.Errorf("exploit: unable to parse %q: %w", s.URL, err)
+	}
+	// Make sure the digest covers the whole response.
+	if _, err := io.Copy(h, res.Body); err != nil {
+		return "", fmt.Errorf("exploit: unable to read %q: %w", s.URL, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ParseEnrichment implements driver.EnrichmentUpdater.
+func (e *Enricher) ParseEnrichment(ctx context.Context, rc io.ReadCloser) ([]driver.EnrichmentRecord, error) {
+	ctx = zlog.ContextWithValues(ctx, "component", "enricher/exploit/Enricher/ParseEnrichment")
+	return common.ParseEnrichment(ctx, rc)
+}
+
+// Enrich implements driver.Enricher.
+//
+// Only vulnerabilities with a CVE that has known exploits appear in the
+// returned enrichment.
+func (e *Enricher) Enrich(ctx context.Context, g driver.EnrichmentGetter, r *claircore.VulnerabilityReport) (string, []json.RawMessage, error) {
+	ctx = zlog.ContextWithValues(ctx, "component", "enricher/exploit/Enricher/Enrich")
+
+	m := make(map[string][]json.RawMessage)
+	erCache := make(map[string][]driver.EnrichmentRecord)
+	for id, v := range r.Vulnerabilities {
+		ts := common.CVEs(v)
+		if len(ts) == 0 {
+			continue
+		}
+		cveKey := strings.Join(ts, "_")
+		rec, ok := erCache[cveKey]
+		if !ok {
+			var err error
+			rec, err = g.GetEnrichment(ctx, ts)
+			if err != nil {
+				return "", nil, err
+			}
+			erCache[cveKey] = rec
+		}
+		if len(rec) == 0 {
+			continue
+		}
+		zlog.Debug(ctx).
+			Str("vuln", v.Name).
+			Strs("cve", ts).
+			Int("count", len(rec)).
+			Msg("found exploits")
+		for _, r := range rec {
+			m[id] = append(m[id], r.Enrichment)
+		}
+	}
+	if len(m) == 0 {
+		return Type, nil, nil
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return Type, nil, err
+	}
+	return Type, []json.RawMessage{b}, nil
+}
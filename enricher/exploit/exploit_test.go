@@ -0,0 +1,199 @@
+package exploit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/libvuln/driver"
+)
+
+// The feeds are trimmed to a few entries, and the Metasploit metadata to the
+// fields that are read.
+const (
+	exploitDBFeed = `id,file,description,date_published,author,type,platform,port,date_added,date_updated,verified,codes,tags,aliases,screenshot_url,application_url,source_url
+50590,exploits/java/remote/50590.py,"Apache Log4j2 2.14.1 - Information Disclosure",2021-12-14,leonjza,remote,java,,2021-12-14,2021-12-14,1,CVE-2021-44228,,,,,
+51183,exploits/multiple/webapps/51183.txt,"Example 1.0 - Remote Code Execution (RCE)",2023-01-10,anon,webapps,multiple,,2023-01-10,2023-01-10,0,CVE-2023-0001;OSVDB-1234,,,,,
+1,exploits/windows/remote/1.c,"Example - Old Exploit",2003-03-23,anon,remote,windows,80,2003-03-23,2003-03-23,1,,,,,,
+`
+	metasploitFeed = `{
+  "exploit/multi/http/log4shell_header_injection": {
+    "name": "Log4Shell HTTP Header Injection",
+    "fullname": "exploit/multi/http/log4shell_header_injection",
+    "type": "exploit",
+    "disclosure_date": "2021-12-09",
+    "references": ["CVE-2021-44228", "CVE-2021-45046", "URL-https://logging.apache.org/log4j/2.x/security.html"]
+  },
+  "auxiliary/scanner/http/example": {
+    "name": "Example Scanner",
+    "fullname": "auxiliary/scanner/http/example",
+    "type": "auxiliary",
+    "disclosure_date": null,
+    "references": ["CVE-2023-0001"]
+  },
+  "post/multi/gather/env": {
+    "name": "Multi Gather Generic Operating System Environment Settings",
+    "fullname": "post/multi/gather/env",
+    "type": "post",
+    "disclosure_date": null,
+    "references": []
+  }
+}`
+)
+
+func TestEnricher(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/files_exploits.csv", func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, exploitDBFeed)
+	})
+	mux.HandleFunc("/modules_metadata_base.json", func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, metasploitFeed)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	e := &Enricher{}
+	edb := srv.URL + "/files_exploits.csv"
+	msf := srv.URL + "/modules_metadata_base.json"
+	if err := e.Configure(ctx, func(i interface{}) error {
+		cfg := i.(*Config)
+		cfg.ExploitDBURL = &edb
+		cfg.MetasploitURL = &msf
+		return nil
+	}, srv.Client()); err != nil {
+		t.Fatal(err)
+	}
+
+	rc, fp, err := e.FetchEnrichment(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := e.ParseEnrichment(ctx, rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]Record)
+	for _, r := range rs {
+		var rec Record
+		if err := json.Unmarshal(r.Enrichment, &rec); err != nil {
+			t.Fatal(err)
+		}
+		if !cmp.Equal(r.Tags, []string{rec.CVE}) {
+			t.Errorf("unexpected tags: %v", r.Tags)
+		}
+		got[rec.CVE] = rec
+	}
+	log4shell := Exploit{
+		Source:    "metasploit",
+		ID:        "exploit/multi/http/log4shell_header_injection",
+		Name:      "Log4Shell HTTP Header Injection",
+		URL:       "https://www.rapid7.com/db/modules/exploit/multi/http/log4shell_header_injection/",
+		Published: "2021-12-09",
+		Maturity:  MaturityHigh,
+	}
+	want := map[string]Record{
+		"CVE-2021-44228": {
+			CVE:      "CVE-2021-44228",
+			Maturity: MaturityHigh,
+			Exploits: []Exploit{
+				{
+					Source:    "exploit-db",
+					ID:        "50590",
+					Name:      "Apache Log4j2 2.14.1 - Information Disclosure",
+					URL:       "https://www.exploit-db.com/exploits/50590",
+					Published: "2021-12-14",
+					Maturity:  MaturityFunctional,
+				},
+				log4shell,
+			},
+		},
+		"CVE-2021-45046": {
+			CVE:      "CVE-2021-45046",
+			Maturity: MaturityHigh,
+			Exploits: []Exploit{log4shell},
+		},
+		"CVE-2023-0001": {
+			CVE:      "CVE-2023-0001",
+			Maturity: MaturityFunctional,
+			Exploits: []Exploit{
+				{
+					Source:    "exploit-db",
+					ID:        "51183",
+					Name:      "Example 1.0 - Remote Code Execution (RCE)",
+					URL:       "https://www.exploit-db.com/exploits/51183",
+					Published: "2023-01-10",
+					Maturity:  MaturityProofOfConcept,
+				},
+				{
+					Source:   "metasploit",
+					ID:       "auxiliary/scanner/http/example",
+					Name:     "Example Scanner",
+					URL:      "https://www.rapid7.com/db/modules/auxiliary/scanner/http/example/",
+					Maturity: MaturityFunctional,
+				},
+			},
+		},
+	}
+	if !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(got, want))
+	}
+
+	if _, _, err := e.FetchEnrichment(ctx, fp); !errors.Is(err, driver.Unchanged) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	t.Run("Enrich", func(t *testing.T) {
+		ctx := zlog.Test(ctx, t)
+		g := &fakeGetter{m: make(map[string][]driver.EnrichmentRecord)}
+		for _, r := range rs {
+			g.m[r.Tags[0]] = append(g.m[r.Tags[0]], r)
+		}
+		vr := &claircore.VulnerabilityReport{
+			Vulnerabilities: map[string]*claircore.Vulnerability{
+				"1": {Name: "GHSA-jfh8-c2jp-5v3q", Links: "https://nvd.nist.gov/vuln/detail/CVE-2021-44228"},
+				"2": {Name: "CVE-2023-0002"},
+				"3": {Name: "RHSA-2024:0001"},
+			},
+		}
+		typ, msgs, err := e.Enrich(ctx, g, vr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ != Type {
+			t.Errorf("got type %q, want %q", typ, Type)
+		}
+		if len(msgs) != 1 {
+			t.Fatalf("got %d messages, want 1", len(msgs))
+		}
+		var got map[string][]Record
+		if err := json.Unmarshal(msgs[0], &got); err != nil {
+			t.Fatal(err)
+		}
+		want := map[string][]Record{"1": {want["CVE-2021-44228"]}}
+		if !cmp.Equal(got, want) {
+			t.Error(cmp.Diff(got, want))
+		}
+	})
+}
+
+type fakeGetter struct {
+	m map[string][]driver.EnrichmentRecord
+}
+
+func (g *fakeGetter) GetEnrichment(_ context.Context, tags []string) ([]driver.EnrichmentRecord, error) {
+	var ret []driver.EnrichmentRecord
+	for _, t := range tags {
+		ret = append(ret, g.m[t]...)
+	}
+	return ret, nil
+}
@@ -0,0 +1,144 @@
+package exploit
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/quay/claircore/enricher/internal/common"
+)
+
+// These are the names of the exploit sources.
+const (
+	sourceExploitDB  = `exploit-db`
+	sourceMetasploit = `metasploit`
+)
+
+// ParseExploitDB reads the Exploit Database's "files_exploits.csv" index,
+// calling "add" for every CVE of every exploit.
+//
+// Verified exploits are considered functional, unverified ones proofs of
+// concept.
+func parseExploitDB(r io.Reader, add func(string, Exploit)) error {
+	rd := csv.NewReader(r)
+	rd.ReuseRecord = true
+	hdr, err := rd.Read()
+	if err != nil {
+		return fmt.Errorf("unable to read header: %w", err)
+	}
+	col := map[string]int{
+		"id":             -1,
+		"description":    -1,
+		"date_published": -1,
+		"verified":       -1,
+		"codes":          -1,
+	}
+	for i, h := range hdr {
+		if _, ok := col[h]; ok {
+			col[h] = i
+		}
+	}
+	for k, i := range col {
+		if i == -1 {
+			return fmt.Errorf("missing column %q", k)
+		}
+	}
+	rd.FieldsPerRecord = len(hdr)
+	for {
+		row, err := rd.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		codes := row[col["codes"]]
+		if codes == "" {
+			continue
+		}
+		x := Exploit{
+			Source:    sourceExploitDB,
+			ID:        row[col["id"]],
+			Name:      row[col["description"]],
+			URL:       "https://www.exploit-db.com/exploits/" + row[col["id"]],
+			Published: row[col["date_published"]],
+			Maturity:  MaturityProofOfConcept,
+		}
+		if row[col["verified"]] == "1" {
+			x.Maturity = MaturityFunctional
+		}
+		for _, c := range strings.Split(codes, ";") {
+			if common.CVERegexp.MatchString(c) {
+				add(common.CanonicalCVE(strings.TrimSpace(c)), x)
+			}
+		}
+	}
+	return nil
+}
+
+// MetasploitModule is the subset of a module's metadata that's used.
+type metasploitModule struct {
+	Name           string   `json:"name"`
+	FullName       string   `json:"fullname"`
+	Type           string   `json:"type"`
+	DisclosureDate string   `json:"disclosure_date"`
+	References     []string `json:"references"`
+}
+
+// ParseMetasploit reads the Metasploit Framework's
+// "modules_metadata_base.json" file, calling "add" for every CVE referenced
+// by every module.
+//
+// Exploit modules are considered high maturity, and other modules (like
+// auxiliary scanners) functional.
+func parseMetasploit(r io.Reader, add func(string, Exploit)) error {
+	// The file is a large object keyed by module path, so it's decoded one
+	// module at a time.
+	dec := json.NewDecoder(r)
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '{' {
+		return fmt.Errorf("unexpected token: %v", tok)
+	}
+	for dec.More() {
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+		var m metasploitModule
+		if err := dec.Decode(&m); err != nil {
+			return err
+		}
+		var cves []string
+		for _, ref := range m.References {
+			if common.CVERegexp.MatchString(ref) {
+				cves = append(cves, common.CanonicalCVE(ref))
+			}
+		}
+		if len(cves) == 0 {
+			continue
+		}
+		x := Exploit{
+			Source:    sourceMetasploit,
+			ID:        m.FullName,
+			Name:      m.Name,
+			URL:       "https://www.rapid7.com/db/modules/" + m.FullName + "/",
+			Published: m.DisclosureDate,
+			Maturity:  MaturityFunctional,
+		}
+		if m.Type == "exploit" {
+			x.Maturity = MaturityHigh
+		}
+		for _, c := range cves {
+			add(c, x)
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,351 @@
+// Package ghsa provides an enricher for GitHub Security Advisories.
+//
+// GitHub advisories carry CVSS vectors, CWE IDs, and per-ecosystem fixed
+// versions, which complement the data in distribution security databases.
+// The advisories are fetched with the [github.com/quay/claircore/pkg/ghsa]
+// client and stored as enrichments keyed by GHSA ID and by every CVE alias.
+//
+// The GitHub GraphQL API requires authentication, so the Enricher does nothing
+// unless it's configured with a token.
+package ghsa
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/enricher"
+	"github.com/quay/claircore/enricher/internal/common"
+	"github.com/quay/claircore/libvuln/driver"
+	"github.com/quay/claircore/pkg/ghsa"
+	"github.com/quay/claircore/pkg/tmp"
+)
+
+var (
+	_ driver.Enricher          = (*Enricher)(nil)
+	_ driver.EnrichmentUpdater = (*Enricher)(nil)
+)
+
+const (
+	// Type is the type of data returned from the Enricher's Enrich method.
+	//
+	// The data is a JSON object mapping vulnerability IDs to arrays of
+	// [Record].
+	Type = `message/vnd.clair.map.vulnerability; enricher=github.ghsa schema=https://github.com/quay/claircore/enricher/ghsa#Record`
+
+	// This appears above and must be the same.
+	name = `github.ghsa`
+)
+
+// Record is a GitHub Security Advisory.
+type Record struct {
+	// ID is the GHSA ID, like "GHSA-jfh8-c2jp-5v3q".
+	ID string `json:"id"`
+	// Aliases are the advisory's other identifiers, like CVE IDs.
+	Aliases []string `json:"aliases,omitempty"`
+	Summary string   `json:"summary,omitempty"`
+	// Severity is one of "LOW", "MODERATE", "HIGH", or "CRITICAL".
+	Severity  string    `json:"severity,omitempty"`
+	Permalink string    `json:"permalink,omitempty"`
+	Published time.Time `json:"published"`
+	Updated   time.Time `json:"updated"`
+	// CVSS holds the advisory's CVSS vectors. The v3 vector is first.
+	CVSS []CVSS `json:"cvss,omitempty"`
+	// CWEs holds CWE IDs, like "CWE-502".
+	CWEs     []string   `json:"cwes,omitempty"`
+	Affected []Affected `json:"affected,omitempty"`
+}
+
+// CVSS is a CVSS vector and its base score.
+type CVSS struct {
+	Vector string  `json:"vector"`
+	Score  float64 `json:"score"`
+}
+
+// Affected is a package range affected by an advisory.
+type Affected struct {
+	// Ecosystem is the GitHub name of the package ecosystem, like "MAVEN".
+	Ecosystem string `json:"ecosystem"`
+	Package   string `json:"package"`
+	// VulnerableRange is the range of affected versions, like ">= 2.0.0, <
+	// 2.15.0".
+	VulnerableRange string `json:"vulnerable_range"`
+	// FirstPatched is the first fixed version, if there is one.
+	FirstPatched string `json:"first_patched,omitempty"`
+}
+
+// Records gets the GitHub Security Advisories of the vulnerabilities in a
+// VulnerabilityReport.
+var Records = enricher.Register[Record](Type)
+
+// NewRecord converts an advisory from the client into a Record.
+func newRecord(a *ghsa.Advisory) *Record {
+	r := Record{
+		ID:        a.ID,
+		Aliases:   a.Aliases,
+		Summary:   a.Summary,
+		Severity:  a.Severity,
+		Permalink: a.Permalink,
+		Published: a.Published,
+		Updated:   a.Updated,
+		CWEs:      a.CWEs,
+	}
+	for _, c := range a.CVSS {
+		r.CVSS = append(r.CVSS, CVSS{Vector: c.Vector, Score: c.Score})
+	}
+	for _, af := range a.Affected {
+		r.Affected = append(r.Affected, Affected{
+			Ecosystem:       af.Ecosystem,
+			Package:         af.Package,
+			VulnerableRange: af.VulnerableRange,
+			FirstPatched:    af.FirstPatched,
+		})
+	}
+	return &r
+}
+
+// Enricher provides GitHub Security Advisories as enrichments to a
+// VulnerabilityReport.
+//
+// Configure must be called before any other methods.
+type Enricher struct {
+	driver.NoopUpdater
+	client     *ghsa.Client
+	ecosystems []string
+}
+
+// Config is the configuration for Enricher.
+type Config struct {
+	// Token is the GitHub token used to authenticate. If empty, the contents
+	// of TokenFile are used. If neither is provided, the Enricher is
+	// disabled.
+	Token     string `json:"token" yaml:"token"`
+	TokenFile string `json:"token_file" yaml:"token_file"`
+	// URL is the GraphQL endpoint. [ghsa.DefaultURL] is used if not provided.
+	URL *string `json:"url" yaml:"url"`
+	// Ecosystems restricts the advisories fetched to the listed GitHub
+	// ecosystems, like "MAVEN" or "PIP". All ecosystems are fetched if
+	// empty.
+	Ecosystems []string `json:"ecosystems" yaml:"ecosystems"`
+}
+
+// Configure implements driver.Configurable.
+func (e *Enricher) Configure(ctx context.Context, f driver.ConfigUnmarshaler, c *http.Client) error {
+	ctx = zlog.ContextWithValues(ctx, "component", "enricher/ghsa/Enricher/Configure")
+	var cfg Config
+	if err := f(&cfg); err != nil {
+		return err
+	}
+	tok := cfg.Token
+	if tok == "" && cfg.TokenFile != "" {
+		b, err := os.ReadFile(cfg.TokenFile)
+		if err != nil {
+			return fmt.Errorf("ghsa: unable to read token: %w", err)
+		}
+		tok = strings.TrimSpace(string(b))
+	}
+	e.client = nil
+	if tok == "" {
+		zlog.Info(ctx).Msg("no token configured, disabled")
+		return nil
+	}
+	var opts []ghsa.Option
+	if cfg.URL != nil {
+		opts = append(opts, ghsa.WithURL(*cfg.URL))
+	}
+	var err error
+	e.client, err = ghsa.NewClient(c, tok, opts...)
+	if err != nil {
+		return err
+	}
+	e.ecosystems = nil
+	for _, eco := range cfg.Ecosystems {
+		e.ecosystems = append(e.ecosystems, strings.ToUpper(eco))
+	}
+	sort.Strings(e.ecosystems)
+	return nil
+}
+
+// Name implements driver.Enricher and driver.EnrichmentUpdater.
+func (*Enricher) Name() string { return name }
+
+// FetchEnrichment implements driver.EnrichmentUpdater.
+//
+// The returned fingerprint is the time of the most recent update to any
+// advisory. If the hint is a previous fingerprint, only advisories updated
+// since then are requested to check if anything changed, and the full set is
+// only fetched if something did.
+func (e *Enricher) FetchEnrichment(ctx context.Context, hint driver.Fingerprint) (io.ReadCloser, driver.Fingerprint, error) {
+	ctx = zlog.ContextWithValues(ctx, "component", "enricher/ghsa/Enricher/FetchEnrichment")
+	if e.client == nil {
+		zlog.Debug(ctx).Msg("disabled")
+		return nil, hint, driver.Unchanged
+	}
+	if since, err := time.Parse(time.RFC3339Nano, string(hint)); err == nil {
+		as, err := e.list(ctx, since)
+		if err != nil {
+			return nil, hint, err
+		}
+		changed := false
+		for _, a := range as {
+			if a.Updated.After(since) {
+				changed = true
+				break
+			}
+		}
+		if !changed {
+			return nil, hint, driver.Unchanged
+		}
+	}
+	as, err := e.list(ctx, time.Time{})
+	if err != nil {
+		return nil, hint, err
+	}
+
+	out, err := tmp.NewFile("", "ghsa.")
+	if err != nil {
+		return nil, hint, err
+	}
+	var success bool
+	defer func() {
+		if !success {
+			if err := out.Close(); err != nil {
+				zlog.Warn(ctx).Err(err).Msg("unable to close spool")
+			}
+		}
+	}()
+	var latest time.Time
+	var ct int
+	enc := json.NewEncoder(out)
+	for _, a := range as {
+		if a.Updated.After(latest) {
+			latest = a.Updated
+		}
+		if !a.Withdrawn.IsZero() {
+			continue
+		}
+		b, err := json.Marshal(newRecord(a))
+		if err != nil {
+			return nil, hint, fmt.Errorf("ghsa: unable to encode record: %w", err)
+		}
+		tags := []string{a.ID}
+		for _, alias := range a.Aliases {
+			tags = append(tags, strings.ToUpper(alias))
+		}
+		if err := enc.Encode(driver.EnrichmentRecord{
+			Tags:       tags,
+			Enrichment: b,
+		}); err != nil {
+			return nil, hint, fmt.Errorf("ghsa: unable to write record: %w", err)
+		}
+		ct++
+	}
+	if _, err := out.Seek(0, io.SeekStart); err != nil {
+		return nil, hint, fmt.Errorf("ghsa: unable to reset spool: %w", err)
+	}
+	zlog.Info(ctx).
+		Int("count", ct).
+		Msg("processed advisories")
+	success = true
+	return out, driver.Fingerprint(latest.UTC().Format(time.RFC3339Nano)), nil
+}
+
+// List returns the advisories for the configured ecosystems updated since
+// "since".
+func (e *Enricher) list(ctx context.Context, since time.Time) ([]*ghsa.Advisory, error) {
+	if len(e.ecosystems) == 0 {
+		return e.client.ListAdvisories(ctx, "", since)
+	}
+	var out []*ghsa.Advisory
+	for _, eco := range e.ecosystems {
+		as, err := e.client.ListAdvisories(ctx, eco, since)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, as...)
+	}
+	return out, nil
+}
+
+// ParseEnrichment implements driver.EnrichmentUpdater.
+func (e *Enricher) ParseEnrichment(ctx context.Context, rc io.ReadCloser) ([]driver.EnrichmentRecord, error) {
+	ctx = zlog.ContextWithValues(ctx, "component", "enricher/ghsa/Enricher/ParseEnrichment")
+	return common.ParseEnrichment(ctx, rc)
+}
+
+var ghsaRegexp = regexp.MustCompile(`(?i:ghsa)(?:-[0-9a-zA-Z]{4}){3}`)
+
+// Enrich implements driver.Enricher.
+//
+// Vulnerabilities are matched to advisories by any GHSA or CVE ID in their
+// name, description, or links. Only vulnerabilities with a matching advisory
+// appear in the returned enrichment.
+func (e *Enricher) Enrich(ctx context.Context, g driver.EnrichmentGetter, r *claircore.VulnerabilityReport) (string, []json.RawMessage, error) {
+	ctx = zlog.ContextWithValues(ctx, "component", "enricher/ghsa/Enricher/Enrich")
+
+	m := make(map[string][]json.RawMessage)
+	erCache := make(map[string][]driver.EnrichmentRecord)
+	for id, v := range r.Vulnerabilities {
+		t := make(map[string]struct{})
+		for _, c := range common.CVEs(v) {
+			t[c] = struct{}{}
+		}
+		for _, elem := range []string{
+			v.Description,
+			v.Name,
+			v.Links,
+		} {
+			for _, m := range ghsaRegexp.FindAllString(elem, -1) {
+				// GHSA IDs are upper-case prefixed, lower-case otherwise.
+				t["GHSA"+strings.ToLower(m[4:])] = struct{}{}
+			}
+		}
+		if len(t) == 0 {
+			continue
+		}
+		ts := make([]string, 0, len(t))
+		for m := range t {
+			ts = append(ts, m)
+		}
+		sort.Strings(ts)
+		key := strings.Join(ts, "_")
+		rec, ok := erCache[key]
+		if !ok {
+			var err error
+			rec, err = g.GetEnrichment(ctx, ts)
+			if err != nil {
+				return "", nil, err
+			}
+			erCache[key] = rec
+		}
+		// A record is tagged with several IDs, so it may be returned for
+		// more than one of them.
+		seen := make(map[string]struct{}, len(rec))
+		for _, r := range rec {
+			k := string(r.Enrichment)
+			if _, ok := seen[k]; ok {
+				continue
+			}
+			seen[k] = struct{}{}
+			m[id] = append(m[id], r.Enrichment)
+		}
+	}
+	if len(m) == 0 {
+		return Type, nil, nil
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return Type, nil, err
+	}
+	return Type, []json.RawMessage{b}, nil
+}
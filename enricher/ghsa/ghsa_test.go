@@ -0,0 +1,162 @@
+package ghsa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/libvuln/driver"
+)
+
+// The testdata pages are copied from pkg/ghsa. The second page is only served
+// for the cursor at the end of the first.
+func listServer(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("Authorization"), "Bearer test-token"; got != want {
+			http.Error(w, `{"message":"Bad credentials"}`, http.StatusUnauthorized)
+			return
+		}
+		var q struct {
+			Variables map[string]any `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+			t.Error(err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		name := "list-1.json"
+		if _, ok := q.Variables["cursor"].(string); ok {
+			name = "list-2.json"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		http.ServeFile(w, r, filepath.Join("testdata", name))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestEnricher(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	srv := listServer(t)
+
+	t.Run("Disabled", func(t *testing.T) {
+		ctx := zlog.Test(ctx, t)
+		e := &Enricher{}
+		if err := e.Configure(ctx, func(interface{}) error { return nil }, srv.Client()); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := e.FetchEnrichment(ctx, ""); !errors.Is(err, driver.Unchanged) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	e := &Enricher{}
+	if err := e.Configure(ctx, func(i interface{}) error {
+		cfg := i.(*Config)
+		cfg.Token = "test-token"
+		cfg.URL = &srv.URL
+		return nil
+	}, srv.Client()); err != nil {
+		t.Fatal(err)
+	}
+
+	rc, fp, err := e.FetchEnrichment(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fp, driver.Fingerprint("2024-02-01T00:00:00Z"); got != want {
+		t.Errorf("got fingerprint %q, want %q", got, want)
+	}
+	rs, err := e.ParseEnrichment(ctx, rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The withdrawn advisory is skipped.
+	got := make(map[string][]string)
+	for _, r := range rs {
+		var rec Record
+		if err := json.Unmarshal(r.Enrichment, &rec); err != nil {
+			t.Fatal(err)
+		}
+		got[rec.ID] = r.Tags
+	}
+	want := map[string][]string{
+		"GHSA-xxxx-0001-0001": {"GHSA-xxxx-0001-0001", "CVE-2024-0001"},
+		"GHSA-xxxx-0002-0002": {"GHSA-xxxx-0002-0002"},
+	}
+	if !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(got, want))
+	}
+
+	// Nothing in the recorded pages is newer than the fingerprint.
+	if _, _, err := e.FetchEnrichment(ctx, fp); !errors.Is(err, driver.Unchanged) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	t.Run("Enrich", func(t *testing.T) {
+		ctx := zlog.Test(ctx, t)
+		g := &fakeGetter{m: make(map[string][]driver.EnrichmentRecord)}
+		for _, r := range rs {
+			for _, tag := range r.Tags {
+				g.m[tag] = append(g.m[tag], r)
+			}
+		}
+		vr := &claircore.VulnerabilityReport{
+			Vulnerabilities: map[string]*claircore.Vulnerability{
+				"1": {Name: "RHSA-2024:0001", Links: "https://access.redhat.com/security/cve/CVE-2024-0001 https://github.com/advisories/GHSA-xxxx-0001-0001"},
+				"2": {Name: "GHSA-XXXX-0002-0002"},
+				"3": {Name: "CVE-2023-0002"},
+			},
+		}
+		typ, msgs, err := e.Enrich(ctx, g, vr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ != Type {
+			t.Errorf("got type %q, want %q", typ, Type)
+		}
+		if len(msgs) != 1 {
+			t.Fatalf("got %d messages, want 1", len(msgs))
+		}
+		var res map[string][]Record
+		if err := json.Unmarshal(msgs[0], &res); err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string][]string)
+		for id, rs := range res {
+			for _, r := range rs {
+				got[id] = append(got[id], r.ID)
+			}
+			sort.Strings(got[id])
+		}
+		want := map[string][]string{
+			"1": {"GHSA-xxxx-0001-0001"},
+			"2": {"GHSA-xxxx-0002-0002"},
+		}
+		if !cmp.Equal(got, want) {
+			t.Error(cmp.Diff(got, want))
+		}
+	})
+}
+
+type fakeGetter struct {
+	m map[string][]driver.EnrichmentRecord
+}
+
+func (g *fakeGetter) GetEnrichment(_ context.Context, tags []string) ([]driver.EnrichmentRecord, error) {
+	var ret []driver.EnrichmentRecord
+	for _, t := range tags {
+		ret = append(ret, g.m[t]...)
+	}
+	return ret, nil
+}
@@ -0,0 +1,79 @@
+// Package common holds helpers shared by the enrichers that look up records
+// by CVE ID.
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/libvuln/driver"
+)
+
+// CVERegexp matches CVE IDs.
+//
+// This is the pattern used by the NVD CVSS enricher: a slightly more relaxed
+// version of the validation pattern in the NVD JSON schema, allowing for "CVE"
+// to be case insensitive and for dashes and underscores between the segments.
+var CVERegexp = regexp.MustCompile(`(?i:cve)[-_][0-9]{4}[-_][0-9]{4,}`)
+
+// CanonicalCVE returns the canonical form of a CVE ID matched by CVERegexp,
+// like "CVE-2024-1234".
+func CanonicalCVE(s string) string {
+	return strings.ToUpper(strings.ReplaceAll(s, "_", "-"))
+}
+
+// CVEs returns the canonical form of every CVE ID mentioned in the name,
+// description, or links of the vulnerability, sorted and without duplicates.
+func CVEs(v *claircore.Vulnerability) []string {
+	t := make(map[string]struct{})
+	for _, elem := range []string{
+		v.Description,
+		v.Name,
+		v.Links,
+	} {
+		for _, m := range CVERegexp.FindAllString(elem, -1) {
+			t[CanonicalCVE(m)] = struct{}{}
+		}
+	}
+	if len(t) == 0 {
+		return nil
+	}
+	ts := make([]string, 0, len(t))
+	for m := range t {
+		ts = append(ts, m)
+	}
+	sort.Strings(ts)
+	return ts
+}
+
+// ParseEnrichment decodes the stream of JSON-encoded records in "rc", for
+// enrichers whose FetchEnrichment method writes the records themselves. The
+// ReadCloser is closed before returning.
+func ParseEnrichment(ctx context.Context, rc io.ReadCloser) ([]driver.EnrichmentRecord, error) {
+	defer rc.Close()
+	dec := json.NewDecoder(rc)
+	var ret []driver.EnrichmentRecord
+	for {
+		var r driver.EnrichmentRecord
+		err := dec.Decode(&r)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, r)
+	}
+	zlog.Debug(ctx).
+		Int("count", len(ret)).
+		Msg("decoded enrichments")
+	return ret, nil
+}
@@ -0,0 +1,52 @@
+package common
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/libvuln/driver"
+)
+
+func TestCVEs(t *testing.T) {
+	v := &claircore.Vulnerability{
+		Name:        "RHSA-2024:0001: cve_2024_0002 and CVE-2024-0001",
+		Description: "See cve-2024-10001.",
+		Links:       "https://example.com/CVE-2024-0001",
+	}
+	got := CVEs(v)
+	want := []string{"CVE-2024-0001", "CVE-2024-0002", "CVE-2024-10001"}
+	if !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(got, want))
+	}
+	if got := CVEs(&claircore.Vulnerability{Name: "GHSA-xxxx-xxxx-xxxx"}); got != nil {
+		t.Errorf("got %q, want nil", got)
+	}
+}
+
+func TestParseEnrichment(t *testing.T) {
+	ctx := zlog.Test(context.Background(), t)
+	const in = `{"tags":["CVE-2024-0001"],"enrichment":{"a":1}}
+{"tags":["CVE-2024-0002"],"enrichment":{"a":2}}
+`
+	got, err := ParseEnrichment(ctx, io.NopCloser(strings.NewReader(in)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []driver.EnrichmentRecord{
+		{Tags: []string{"CVE-2024-0001"}, Enrichment: []byte(`{"a":1}`)},
+		{Tags: []string{"CVE-2024-0002"}, Enrichment: []byte(`{"a":2}`)},
+	}
+	if !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(got, want))
+	}
+
+	if _, err := ParseEnrichment(ctx, io.NopCloser(strings.NewReader(`{"tags":`))); err == nil {
+		t.Error("expected error")
+	}
+}
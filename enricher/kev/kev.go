@@ -0,0 +1,240 @@
+// Package kev provides an enricher for the CISA Known Exploited
+// Vulnerabilities (KEV) catalog.
+//
+// The catalog lists vulnerabilities with evidence of active exploitation,
+// along with the date CISA added them and the date federal agencies must
+// remediate them by. See
+// https://www.cisa.gov/known-exploited-vulnerabilities-catalog for details.
+package kev
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/enricher"
+	"github.com/quay/claircore/enricher/internal/common"
+	"github.com/quay/claircore/libvuln/driver"
+	"github.com/quay/claircore/pkg/tmp"
+)
+
+var (
+	_ driver.Enricher          = (*Enricher)(nil)
+	_ driver.EnrichmentUpdater = (*Enricher)(nil)
+)
+
+const (
+	// Type is the type of data returned from the Enricher's Enrich method.
+	//
+	// The data is a JSON object mapping vulnerability IDs to arrays of
+	// [Record].
+	Type = `message/vnd.clair.map.vulnerability; enricher=cisa.kev schema=https://github.com/quay/claircore/enricher/kev#Record`
+	// DefaultFeed is the default location of the KEV catalog.
+	//
+	//doc:url updater
+	DefaultFeed = `https://www.cisa.gov/sites/default/files/feeds/known_exploited_vulnerabilities.json`
+
+	// This appears above and must be the same.
+	name = `cisa.kev`
+)
+
+// Record is a KEV catalog entry.
+//
+// The field names follow the catalog's schema.
+type Record struct {
+	// CVE is the CVE of the exploited vulnerability.
+	CVE string `json:"cveID"`
+	// VendorProject is the vendor or project of the affected product.
+	VendorProject string `json:"vendorProject,omitempty"`
+	// Product is the affected product.
+	Product string `json:"product,omitempty"`
+	// VulnerabilityName is CISA's name for the vulnerability.
+	VulnerabilityName string `json:"vulnerabilityName,omitempty"`
+	// DateAdded is the day the vulnerability was added to the catalog, like
+	// "2024-01-01".
+	DateAdded string `json:"dateAdded"`
+	// ShortDescription describes the vulnerability.
+	ShortDescription string `json:"shortDescription,omitempty"`
+	// RequiredAction is the remediation CISA requires.
+	RequiredAction string `json:"requiredAction,omitempty"`
+	// DueDate is the day the required action must be taken by, like
+	// "2024-01-22".
+	DueDate string `json:"dueDate"`
+	// KnownRansomwareCampaignUse is "Known" if the vulnerability is known to
+	// have been used in ransomware campaigns, and "Unknown" otherwise.
+	KnownRansomwareCampaignUse string `json:"knownRansomwareCampaignUse,omitempty"`
+}
+
+// Records gets the Known Exploited Vulnerabilities catalog entries of the vulnerabilities in a VulnerabilityReport.
+var Records = enricher.Register[Record](Type)
+
+// Catalog is the layout of the KEV feed.
+type catalog struct {
+	CatalogVersion  string   `json:"catalogVersion"`
+	DateReleased    string   `json:"dateReleased"`
+	Vulnerabilities []Record `json:"vulnerabilities"`
+}
+
+// Enricher flags vulnerabilities in the KEV catalog in a VulnerabilityReport.
+//
+// Configure must be called before any other methods.
+type Enricher struct {
+	driver.NoopUpdater
+	c    *http.Client
+	feed *url.URL
+}
+
+// Config is the configuration for Enricher.
+type Config struct {
+	// URL is the location of the KEV catalog JSON file. DefaultFeed is used
+	// if not provided.
+	URL *string `json:"url" yaml:"url"`
+}
+
+// Configure implements driver.Configurable.
+func (e *Enricher) Configure(ctx context.Context, f driver.ConfigUnmarshaler, c *http.Client) error {
+	var cfg Config
+	e.c = c
+	if err := f(&cfg); err != nil {
+		return err
+	}
+	u := DefaultFeed
+	if cfg.URL != nil {
+		u = *cfg.URL
+	}
+	var err error
+	e.feed, err = url.Parse(u)
+	if err != nil {
+		return fmt.Errorf("kev: bad URL: %w", err)
+	}
+	return nil
+}
+
+// Name implements driver.Enricher and driver.EnrichmentUpdater.
+func (*Enricher) Name() string { return name }
+
+// FetchEnrichment implements driver.EnrichmentUpdater.
+//
+// The returned fingerprint is the catalog version.
+func (e *Enricher) FetchEnrichment(ctx context.Context, hint driver.Fingerprint) (io.ReadCloser, driver.Fingerprint, error) {
+	ctx = zlog.ContextWithValues(ctx, "component", "enricher/kev/Enricher/FetchEnrichment")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.feed.String(), nil)
+	if err != nil {
+		return nil, hint, fmt.Errorf("kev: unable to create request: %w", err)
+	}
+	res, err := e.c.Do(req)
+	if err != nil {
+		return nil, hint, fmt.Errorf("kev: unable to do request: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, hint, fmt.Errorf("kev: unexpected response for %q: %v", e.feed, res.Status)
+	}
+	var cat catalog
+	if err := json.NewDecoder(res.Body).Decode(&cat); err != nil {
+		return nil, hint, fmt.Errorf("kev: unable to decode %q: %w", e.feed, err)
+	}
+	zlog.Debug(ctx).
+		Str("version", cat.CatalogVersion).
+		Str("released", cat.DateReleased).
+		Msg("found catalog")
+	fp := driver.Fingerprint(cat.CatalogVersion)
+	if fp != "" && fp == hint {
+		return nil, hint, driver.Unchanged
+	}
+
+	out, err := tmp.NewFile("", "kev.")
+	if err != nil {
+		return nil, hint, err
+	}
+	var success bool
+	defer func() {
+		if !success {
+			if err := out.Close(); err != nil {
+				zlog.Warn(ctx).Err(err).Msg("unable to close spool")
+			}
+		}
+	}()
+	enc := json.NewEncoder(out)
+	for i := range cat.Vulnerabilities {
+		r := &cat.Vulnerabilities[i]
+		r.CVE = strings.ToUpper(strings.TrimSpace(r.CVE))
+		b, err := json.Marshal(r)
+		if err != nil {
+			return nil, hint, fmt.Errorf("kev: unable to encode record: %w", err)
+		}
+		if err := enc.Encode(driver.EnrichmentRecord{
+			Tags:       []string{r.CVE},
+			Enrichment: b,
+		}); err != nil {
+			return nil, hint, fmt.Errorf("kev: unable to write record: %w", err)
+		}
+	}
+	if _, err := out.Seek(0, io.SeekStart); err != nil {
+		return nil, hint, fmt.Errorf("kev: unable to reset spool: %w", err)
+	}
+	zlog.Info(ctx).
+		Int("count", len(cat.Vulnerabilities)).
+		Msg("processed catalog")
+	success = true
+	return out, fp, nil
+}
+
+// ParseEnrichment implements driver.EnrichmentUpdater.
+func (e *Enricher) ParseEnrichment(ctx context.Context, rc io.ReadCloser) ([]driver.EnrichmentRecord, error) {
+	ctx = zlog.ContextWithValues(ctx, "component", "enricher/kev/Enricher/ParseEnrichment")
+	return common.ParseEnrichment(ctx, rc)
+}
+
+// Enrich implements driver.Enricher.
+//
+// Only vulnerabilities with a CVE in the catalog appear in the returned
+// enrichment.
+func (e *Enricher) Enrich(ctx context.Context, g driver.EnrichmentGetter, r *claircore.VulnerabilityReport) (string, []json.RawMessage, error) {
+	ctx = zlog.ContextWithValues(ctx, "component", "enricher/kev/Enricher/Enrich")
+
+	m := make(map[string][]json.RawMessage)
+	erCache := make(map[string][]driver.EnrichmentRecord)
+	for id, v := range r.Vulnerabilities {
+		ts := common.CVEs(v)
+		if len(ts) == 0 {
+			continue
+		}
+		cveKey := strings.Join(ts, "_")
+		rec, ok := erCache[cveKey]
+		if !ok {
+			var err error
+			rec, err = g.GetEnrichment(ctx, ts)
+			if err != nil {
+				return "", nil, err
+			}
+			erCache[cveKey] = rec
+		}
+		if len(rec) == 0 {
+			continue
+		}
+		zlog.Debug(ctx).
+			Str("vuln", v.Name).
+			Strs("cve", ts).
+			Msg("known exploited")
+		for _, r := range rec {
+			m[id] = append(m[id], r.Enrichment)
+		}
+	}
+	if len(m) == 0 {
+		return Type, nil, nil
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return Type, nil, err
+	}
+	return Type, []json.RawMessage{b}, nil
+}
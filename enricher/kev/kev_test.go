@@ -0,0 +1,170 @@
+package kev
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/libvuln/driver"
+)
+
+// The catalog is trimmed to two entries.
+const feed = `{
+  "title": "CISA Catalog of Known Exploited Vulnerabilities",
+  "catalogVersion": "2024.01.02",
+  "dateReleased": "2024-01-02T15:00:00.0000Z",
+  "count": 2,
+  "vulnerabilities": [
+    {
+      "cveID": "CVE-2023-0001",
+      "vendorProject": "Example",
+      "product": "libfoo",
+      "vulnerabilityName": "Example libfoo Buffer Overflow Vulnerability",
+      "dateAdded": "2024-01-02",
+      "shortDescription": "Example libfoo contains a buffer overflow.",
+      "requiredAction": "Apply updates per vendor instructions.",
+      "dueDate": "2024-01-23",
+      "knownRansomwareCampaignUse": "Unknown",
+      "notes": "https://example.com/advisory",
+      "cwes": ["CWE-120"]
+    },
+    {
+      "cveID": "CVE-2021-44228",
+      "vendorProject": "Apache",
+      "product": "Log4j2",
+      "vulnerabilityName": "Apache Log4j2 Remote Code Execution Vulnerability",
+      "dateAdded": "2021-12-10",
+      "shortDescription": "Apache Log4j2 contains a remote code execution vulnerability.",
+      "requiredAction": "Apply updates per vendor instructions.",
+      "dueDate": "2021-12-24",
+      "knownRansomwareCampaignUse": "Known",
+      "notes": "",
+      "cwes": ["CWE-917"]
+    }
+  ]
+}`
+
+func TestEnricher(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, feed)
+	}))
+	defer srv.Close()
+
+	e := &Enricher{}
+	u := srv.URL + "/known_exploited_vulnerabilities.json"
+	if err := e.Configure(ctx, func(i interface{}) error {
+		i.(*Config).URL = &u
+		return nil
+	}, srv.Client()); err != nil {
+		t.Fatal(err)
+	}
+
+	rc, fp, err := e.FetchEnrichment(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fp, driver.Fingerprint("2024.01.02"); got != want {
+		t.Errorf("got fingerprint %q, want %q", got, want)
+	}
+	rs, err := e.ParseEnrichment(ctx, rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]Record)
+	for _, r := range rs {
+		var rec Record
+		if err := json.Unmarshal(r.Enrichment, &rec); err != nil {
+			t.Fatal(err)
+		}
+		if !cmp.Equal(r.Tags, []string{rec.CVE}) {
+			t.Errorf("unexpected tags: %v", r.Tags)
+		}
+		got[rec.CVE] = rec
+	}
+	want := map[string]Record{
+		"CVE-2023-0001": {
+			CVE:                        "CVE-2023-0001",
+			VendorProject:              "Example",
+			Product:                    "libfoo",
+			VulnerabilityName:          "Example libfoo Buffer Overflow Vulnerability",
+			DateAdded:                  "2024-01-02",
+			ShortDescription:           "Example libfoo contains a buffer overflow.",
+			RequiredAction:             "Apply updates per vendor instructions.",
+			DueDate:                    "2024-01-23",
+			KnownRansomwareCampaignUse: "Unknown",
+		},
+		"CVE-2021-44228": {
+			CVE:                        "CVE-2021-44228",
+			VendorProject:              "Apache",
+			Product:                    "Log4j2",
+			VulnerabilityName:          "Apache Log4j2 Remote Code Execution Vulnerability",
+			DateAdded:                  "2021-12-10",
+			ShortDescription:           "Apache Log4j2 contains a remote code execution vulnerability.",
+			RequiredAction:             "Apply updates per vendor instructions.",
+			DueDate:                    "2021-12-24",
+			KnownRansomwareCampaignUse: "Known",
+		},
+	}
+	if !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(got, want))
+	}
+
+	if _, _, err := e.FetchEnrichment(ctx, fp); !errors.Is(err, driver.Unchanged) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	t.Run("Enrich", func(t *testing.T) {
+		ctx := zlog.Test(ctx, t)
+		g := &fakeGetter{m: make(map[string][]driver.EnrichmentRecord)}
+		for _, r := range rs {
+			g.m[r.Tags[0]] = append(g.m[r.Tags[0]], r)
+		}
+		vr := &claircore.VulnerabilityReport{
+			Vulnerabilities: map[string]*claircore.Vulnerability{
+				"1": {Name: "GHSA-jfh8-c2jp-5v3q", Links: "https://nvd.nist.gov/vuln/detail/CVE-2021-44228"},
+				"2": {Name: "CVE-2023-0002"},
+				"3": {Name: "RHSA-2024:0001"},
+			},
+		}
+		typ, msgs, err := e.Enrich(ctx, g, vr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ != Type {
+			t.Errorf("got type %q, want %q", typ, Type)
+		}
+		if len(msgs) != 1 {
+			t.Fatalf("got %d messages, want 1", len(msgs))
+		}
+		var got map[string][]Record
+		if err := json.Unmarshal(msgs[0], &got); err != nil {
+			t.Fatal(err)
+		}
+		want := map[string][]Record{"1": {want["CVE-2021-44228"]}}
+		if !cmp.Equal(got, want) {
+			t.Error(cmp.Diff(got, want))
+		}
+	})
+}
+
+type fakeGetter struct {
+	m map[string][]driver.EnrichmentRecord
+}
+
+func (g *fakeGetter) GetEnrichment(_ context.Context, tags []string) ([]driver.EnrichmentRecord, error) {
+	var ret []driver.EnrichmentRecord
+	for _, t := range tags {
+		ret = append(ret, g.m[t]...)
+	}
+	return ret, nil
+}
@@ -0,0 +1,303 @@
+// Package rhelcvss provides an enricher for Red Hat's CVSS scores.
+//
+// Red Hat scores CVEs for its own products, and these scores can differ from
+// the NVD's. The scores are published in Red Hat's VEX files, which this
+// package reads from the periodic archive of all of them.
+package rhelcvss
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/enricher"
+	"github.com/quay/claircore/enricher/internal/common"
+	"github.com/quay/claircore/internal/zreader"
+	"github.com/quay/claircore/libvuln/driver"
+	"github.com/quay/claircore/pkg/tmp"
+)
+
+var (
+	_ driver.Enricher          = (*Enricher)(nil)
+	_ driver.EnrichmentUpdater = (*Enricher)(nil)
+
+	defaultFeed *url.URL
+)
+
+const (
+	// Type is the type of data returned from the Enricher's Enrich method.
+	//
+	// The data is a JSON object mapping vulnerability IDs to arrays of
+	// [Record].
+	Type = `message/vnd.clair.map.vulnerability; enricher=rhel.cvss schema=https://github.com/quay/claircore/enricher/rhelcvss#Record`
+	// DefaultFeed is the default place to look for Red Hat VEX files.
+	//
+	// The enricher expects the directory to contain an "archive_latest.txt"
+	// file naming a compressed tar of every VEX file.
+	//
+	//doc:url updater
+	DefaultFeed = `https://access.redhat.com/security/data/csaf/v2/vex/`
+
+	// This appears above and must be the same.
+	name = `rhel.cvss`
+
+	// RepositoryKey is the key the rhel package uses for the repositories
+	// of the vulnerabilities it creates, and must be the same.
+	repositoryKey = `rhel-cpe-repository`
+)
+
+func init() {
+	var err error
+	defaultFeed, err = url.Parse(DefaultFeed)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Record is a Red Hat CVSS score for a CVE.
+//
+// There's at most one Record for each CVE and CVSS version. If Red Hat scored
+// the CVE differently for different products, the highest score is used.
+type Record struct {
+	// CVE is the CVE that was scored, like "CVE-2024-0001".
+	CVE string `json:"cve"`
+	// Version is the CVSS version, like "3.1".
+	Version string `json:"version"`
+	// BaseScore is the CVSS base score.
+	BaseScore float64 `json:"baseScore"`
+	// BaseSeverity is the qualitative severity, if provided.
+	BaseSeverity string `json:"baseSeverity,omitempty"`
+	// VectorString is the CVSS vector.
+	VectorString string `json:"vectorString"`
+}
+
+// Records gets the Red Hat CVSS scores of the vulnerabilities in a VulnerabilityReport.
+var Records = enricher.Register[Record](Type)
+
+// Enricher provides Red Hat CVSS scores as enrichments to a
+// VulnerabilityReport.
+//
+// Only vulnerabilities from the rhel package's updaters are enriched.
+//
+// Configure must be called before any other methods.
+type Enricher struct {
+	driver.NoopUpdater
+	c    *http.Client
+	feed *url.URL
+}
+
+// Config is the configuration for Enricher.
+type Config struct {
+	FeedRoot *string `json:"feed_root" yaml:"feed_root"`
+}
+
+// Configure implements driver.Configurable.
+func (e *Enricher) Configure(ctx context.Context, f driver.ConfigUnmarshaler, c *http.Client) error {
+	var cfg Config
+	e.c = c
+	if err := f(&cfg); err != nil {
+		return err
+	}
+	if cfg.FeedRoot != nil {
+		if !strings.HasSuffix(*cfg.FeedRoot, "/") {
+			return fmt.Errorf("URL missing trailing slash: %q", *cfg.FeedRoot)
+		}
+		u, err := url.Parse(*cfg.FeedRoot)
+		if err != nil {
+			return err
+		}
+		e.feed = u
+	} else {
+		var err error
+		e.feed, err = defaultFeed.Parse(".")
+		if err != nil {
+			panic("programmer error: " + err.Error())
+		}
+	}
+	return nil
+}
+
+// Name implements driver.Enricher and driver.EnrichmentUpdater.
+func (*Enricher) Name() string { return name }
+
+// FetchEnrichment implements driver.EnrichmentUpdater.
+//
+// The returned fingerprint is the name of the latest archive.
+func (e *Enricher) FetchEnrichment(ctx context.Context, hint driver.Fingerprint) (io.ReadCloser, driver.Fingerprint, error) {
+	ctx = zlog.ContextWithValues(ctx, "component", "enricher/rhelcvss/Enricher/FetchEnrichment")
+
+	u, err := e.feed.Parse("archive_latest.txt")
+	if err != nil {
+		return nil, hint, err
+	}
+	res, err := e.get(ctx, u)
+	if err != nil {
+		return nil, hint, err
+	}
+	s := bufio.NewScanner(res.Body)
+	var archive string
+	for s.Scan() && archive == "" {
+		archive = strings.TrimSpace(s.Text())
+	}
+	res.Body.Close()
+	if err := s.Err(); err != nil {
+		return nil, hint, fmt.Errorf("unable to read %q: %w", u, err)
+	}
+	if archive == "" || strings.Contains(archive, "/") {
+		return nil, hint, fmt.Errorf("bad archive name in %q: %q", u, archive)
+	}
+	zlog.Debug(ctx).
+		Str("archive", archive).
+		Msg("found latest archive")
+	if driver.Fingerprint(archive) == hint {
+		return nil, hint, driver.Unchanged
+	}
+
+	u, err = e.feed.Parse(archive)
+	if err != nil {
+		return nil, hint, fmt.Errorf("bad URL: %w", err)
+	}
+	res, err = e.get(ctx, u)
+	if err != nil {
+		return nil, hint, err
+	}
+	defer res.Body.Close()
+	zr, err := zreader.Reader(res.Body)
+	if err != nil {
+		return nil, hint, fmt.Errorf("unable to decompress %q: %w", u, err)
+	}
+	defer zr.Close()
+
+	out, err := tmp.NewFile("", "rhelcvss.")
+	if err != nil {
+		return nil, hint, err
+	}
+	var success bool
+	defer func() {
+		if !success {
+			if err := out.Close(); err != nil {
+				zlog.Warn(ctx).Err(err).Msg("unable to close spool")
+			}
+		}
+	}()
+	enc := json.NewEncoder(out)
+	var docs, recs int
+	tr := tar.NewReader(zr)
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, hint, fmt.Errorf("unable to read archive: %w", err)
+		}
+		if h.Typeflag != tar.TypeReg || path.Ext(h.Name) != ".json" {
+			continue
+		}
+		var doc vexDocument
+		if err := json.NewDecoder(tr).Decode(&doc); err != nil {
+			return nil, hint, fmt.Errorf("unable to decode %q: %w", h.Name, err)
+		}
+		docs++
+		for _, r := range doc.records() {
+			b, err := json.Marshal(r)
+			if err != nil {
+				return nil, hint, err
+			}
+			if err := enc.Encode(driver.EnrichmentRecord{
+				Tags:       []string{r.CVE},
+				Enrichment: b,
+			}); err != nil {
+				return nil, hint, fmt.Errorf("unable to write record: %w", err)
+			}
+			recs++
+		}
+	}
+	if _, err := out.Seek(0, io.SeekStart); err != nil {
+		return nil, hint, fmt.Errorf("unable to reset spool: %w", err)
+	}
+	zlog.Info(ctx).
+		Str("archive", archive).
+		Int("documents", docs).
+		Int("records", recs).
+		Msg("processed archive")
+	success = true
+	return out, driver.Fingerprint(archive), nil
+}
+
+// Get issues a GET request, returning an error for any non-200 response.
+func (e *Enricher) get(ctx context.Context, u *url.URL) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+	res, err := e.c.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to do request: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("unexpected response for %q: %v", u, res.Status)
+	}
+	return res, nil
+}
+
+// ParseEnrichment implements driver.EnrichmentUpdater.
+func (e *Enricher) ParseEnrichment(ctx context.Context, rc io.ReadCloser) ([]driver.EnrichmentRecord, error) {
+	ctx = zlog.ContextWithValues(ctx, "component", "enricher/rhelcvss/Enricher/ParseEnrichment")
+	return common.ParseEnrichment(ctx, rc)
+}
+
+// Enrich implements driver.Enricher.
+func (e *Enricher) Enrich(ctx context.Context, g driver.EnrichmentGetter, r *claircore.VulnerabilityReport) (string, []json.RawMessage, error) {
+	ctx = zlog.ContextWithValues(ctx, "component", "enricher/rhelcvss/Enricher/Enrich")
+
+	m := make(map[string][]json.RawMessage)
+	erCache := make(map[string][]driver.EnrichmentRecord)
+	for id, v := range r.Vulnerabilities {
+		if v.Repo == nil || v.Repo.Key != repositoryKey {
+			continue
+		}
+		ts := common.CVEs(v)
+		if len(ts) == 0 {
+			continue
+		}
+		cveKey := strings.Join(ts, "_")
+		rec, ok := erCache[cveKey]
+		if !ok {
+			var err error
+			rec, err = g.GetEnrichment(ctx, ts)
+			if err != nil {
+				return "", nil, err
+			}
+			erCache[cveKey] = rec
+		}
+		zlog.Debug(ctx).
+			Str("vuln", v.Name).
+			Strs("cve", ts).
+			Int("count", len(rec)).
+			Msg("found records")
+		for _, r := range rec {
+			m[id] = append(m[id], r.Enrichment)
+		}
+	}
+	if len(m) == 0 {
+		return Type, nil, nil
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return Type, nil, err
+	}
+	return Type, []json.RawMessage{b}, nil
+}
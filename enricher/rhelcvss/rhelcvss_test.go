@@ -0,0 +1,172 @@
+package rhelcvss
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/klauspost/compress/zstd"
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/libvuln/driver"
+)
+
+// The VEX documents are trimmed to the parts that are read.
+var vexDocs = map[string]string{
+	"2023/cve-2023-0001.json": `{"document":{"tracking":{"id":"CVE-2023-0001"}},"vulnerabilities":[{"cve":"CVE-2023-0001","scores":[
+		{"cvss_v3":{"version":"3.1","baseScore":5.3,"baseSeverity":"MEDIUM","vectorString":"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:L/I:N/A:N"},"products":["a"]},
+		{"cvss_v3":{"version":"3.1","baseScore":7.5,"baseSeverity":"HIGH","vectorString":"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N"},"products":["b"]},
+		{"cvss_v2":{"baseScore":4.3,"vectorString":"AV:N/AC:M/Au:N/C:N/I:N/A:P"}}
+	]}]}`,
+	"2023/cve-2023-0002.json": `{"document":{"tracking":{"id":"CVE-2023-0002"}},"vulnerabilities":[{"cve":"CVE-2023-0002","scores":[]}]}`,
+	"2024/cve-2024-0003.json": `{"document":{"tracking":{"id":"CVE-2024-0003"}},"vulnerabilities":[{"cve":"CVE-2024-0003","scores":[
+		{"cvss_v4":{"version":"4.0","baseScore":8.7,"baseSeverity":"HIGH","vectorString":"CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:H/VI:N/VA:N/SC:N/SI:N/SA:N"}}
+	]}]}`,
+}
+
+// MkArchive returns a zstd-compressed tar of the VEX documents.
+func mkArchive(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw, err := zstd.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := tar.NewWriter(zw)
+	if err := tw.WriteHeader(&tar.Header{Name: "2023/", Typeflag: tar.TypeDir, Mode: 0o755}); err != nil {
+		t.Fatal(err)
+	}
+	for n, d := range vexDocs {
+		if err := tw.WriteHeader(&tar.Header{Name: n, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(d))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestEnricher(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	const archive = "csaf_vex_2024-01-01.tar.zst"
+	b := mkArchive(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/vex/archive_latest.txt", func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, archive+"\n")
+	})
+	mux.HandleFunc("/vex/"+archive, func(w http.ResponseWriter, _ *http.Request) {
+		w.Write(b)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	e := &Enricher{}
+	root := srv.URL + "/vex/"
+	if err := e.Configure(ctx, func(i interface{}) error {
+		i.(*Config).FeedRoot = &root
+		return nil
+	}, srv.Client()); err != nil {
+		t.Fatal(err)
+	}
+
+	rc, fp, err := e.FetchEnrichment(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fp, driver.Fingerprint(archive); got != want {
+		t.Errorf("got fingerprint %q, want %q", got, want)
+	}
+	rs, err := e.ParseEnrichment(ctx, rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string][]Record)
+	for _, r := range rs {
+		var rec Record
+		if err := json.Unmarshal(r.Enrichment, &rec); err != nil {
+			t.Fatal(err)
+		}
+		if !cmp.Equal(r.Tags, []string{rec.CVE}) {
+			t.Errorf("unexpected tags: %v", r.Tags)
+		}
+		got[rec.CVE] = append(got[rec.CVE], rec)
+	}
+	want := map[string][]Record{
+		"CVE-2023-0001": {
+			{CVE: "CVE-2023-0001", Version: "2.0", BaseScore: 4.3, VectorString: "AV:N/AC:M/Au:N/C:N/I:N/A:P"},
+			{CVE: "CVE-2023-0001", Version: "3.1", BaseScore: 7.5, BaseSeverity: "HIGH", VectorString: "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N"},
+		},
+		"CVE-2024-0003": {
+			{CVE: "CVE-2024-0003", Version: "4.0", BaseScore: 8.7, BaseSeverity: "HIGH", VectorString: "CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:H/VI:N/VA:N/SC:N/SI:N/SA:N"},
+		},
+	}
+	if !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(got, want))
+	}
+
+	if _, _, err := e.FetchEnrichment(ctx, fp); !errors.Is(err, driver.Unchanged) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	t.Run("Enrich", func(t *testing.T) {
+		ctx := zlog.Test(ctx, t)
+		g := &fakeGetter{m: make(map[string][]driver.EnrichmentRecord)}
+		for _, r := range rs {
+			g.m[r.Tags[0]] = append(g.m[r.Tags[0]], r)
+		}
+		rhel := &claircore.Repository{Key: repositoryKey}
+		vr := &claircore.VulnerabilityReport{
+			Vulnerabilities: map[string]*claircore.Vulnerability{
+				"1": {Name: "RHSA-2024:0001: libfoo (Important)", Links: "https://access.redhat.com/security/cve/cve_2024_0003", Repo: rhel},
+				"2": {Name: "CVE-2023-0001", Repo: &claircore.Repository{Name: "debian"}},
+				"3": {Name: "RHSA-2024:0002", Repo: rhel},
+			},
+		}
+		typ, msgs, err := e.Enrich(ctx, g, vr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ != Type {
+			t.Errorf("got type %q, want %q", typ, Type)
+		}
+		if len(msgs) != 1 {
+			t.Fatalf("got %d messages, want 1", len(msgs))
+		}
+		var got map[string][]Record
+		if err := json.Unmarshal(msgs[0], &got); err != nil {
+			t.Fatal(err)
+		}
+		want := map[string][]Record{"1": want["CVE-2024-0003"]}
+		if !cmp.Equal(got, want) {
+			t.Error(cmp.Diff(got, want))
+		}
+	})
+}
+
+type fakeGetter struct {
+	m map[string][]driver.EnrichmentRecord
+}
+
+func (g *fakeGetter) GetEnrichment(_ context.Context, tags []string) ([]driver.EnrichmentRecord, error) {
+	var ret []driver.EnrichmentRecord
+	for _, t := range tags {
+		ret = append(ret, g.m[t]...)
+	}
+	return ret, nil
+}
@@ -0,0 +1,76 @@
+package rhelcvss
+
+import (
+	"sort"
+	"strings"
+)
+
+// VexDocument is the subset of a Red Hat VEX document that's used.
+type vexDocument struct {
+	Vulnerabilities []struct {
+		CVE    string `json:"cve"`
+		Scores []struct {
+			CVSSv4 *vexCVSS `json:"cvss_v4"`
+			CVSSv3 *vexCVSS `json:"cvss_v3"`
+			CVSSv2 *vexCVSS `json:"cvss_v2"`
+		} `json:"scores"`
+	} `json:"vulnerabilities"`
+}
+
+type vexCVSS struct {
+	Version      string  `json:"version"`
+	BaseScore    float64 `json:"baseScore"`
+	BaseSeverity string  `json:"baseSeverity"`
+	VectorString string  `json:"vectorString"`
+}
+
+// Records returns the highest-scoring Record of each CVSS version for every
+// CVE in the document, sorted by CVE and then version.
+func (d *vexDocument) records() []Record {
+	type key struct {
+		CVE   string
+		Major string
+	}
+	best := make(map[key]Record)
+	add := func(cve, dflt string, c *vexCVSS) {
+		if c == nil || c.VectorString == "" {
+			return
+		}
+		r := Record{
+			CVE:          cve,
+			Version:      c.Version,
+			BaseScore:    c.BaseScore,
+			BaseSeverity: c.BaseSeverity,
+			VectorString: c.VectorString,
+		}
+		if r.Version == "" {
+			r.Version = dflt
+		}
+		k := key{CVE: cve, Major: dflt}
+		if prev, ok := best[k]; !ok || r.BaseScore > prev.BaseScore {
+			best[k] = r
+		}
+	}
+	for _, v := range d.Vulnerabilities {
+		cve := strings.ToUpper(strings.TrimSpace(v.CVE))
+		if cve == "" {
+			continue
+		}
+		for _, s := range v.Scores {
+			add(cve, "4.0", s.CVSSv4)
+			add(cve, "3.1", s.CVSSv3)
+			add(cve, "2.0", s.CVSSv2)
+		}
+	}
+	ret := make([]Record, 0, len(best))
+	for _, r := range best {
+		ret = append(ret, r)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].CVE != ret[j].CVE {
+			return ret[i].CVE < ret[j].CVE
+		}
+		return ret[i].Version < ret[j].Version
+	})
+	return ret
+}
@@ -0,0 +1,105 @@
+package executable
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/indexer"
+)
+
+type coalescer struct{}
+
+// Coalesce implements indexer.Coalescer.
+//
+// Executables are tracked by path through the layers: a file at a path that
+// was written or whited-out in a later layer is dropped. An executable that's
+// replaced by a non-ELF file isn't reported by the Scanner, so isn't noticed.
+// Identical executables at several paths are one package with an Environment
+// per path.
+func (c *coalescer) Coalesce(ctx context.Context, ls []*indexer.LayerArtifacts) (*claircore.IndexReport, error) {
+	ir := &claircore.IndexReport{
+		Environments: map[string][]*claircore.Environment{},
+		Packages:     map[string]*claircore.Package{},
+		Repositories: map[string]*claircore.Repository{},
+	}
+	type found struct {
+		pkg   *claircore.Package
+		layer claircore.Digest
+		repo  *claircore.Repository
+	}
+	live := make(map[string]found)
+	for _, l := range ls {
+		// Whiteouts only apply to lower layers, so handle them before this
+		// layer's files.
+		for _, f := range l.Files {
+			if f.Kind != claircore.FileKindWhiteout {
+				continue
+			}
+			for p := range live {
+				if whitedOut(p, f.Path) {
+					delete(live, p)
+				}
+			}
+		}
+		var repo *claircore.Repository
+		for _, r := range l.Repos {
+			if r.Name != Repository.Name || r.URI != Repository.URI {
+				continue
+			}
+			repo = r
+			break
+		}
+		for _, pkg := range l.Pkgs {
+			if !strings.HasPrefix(pkg.PackageDB, packageDBPrefix) {
+				continue
+			}
+			// An identical file written again was introduced earlier.
+			if prev, ok := live[pkg.Filepath]; ok && prev.pkg.ID == pkg.ID {
+				continue
+			}
+			live[pkg.Filepath] = found{pkg: pkg, layer: l.Hash, repo: repo}
+		}
+	}
+
+	ps := make([]string, 0, len(live))
+	for p := range live {
+		ps = append(ps, p)
+	}
+	sort.Strings(ps)
+	for _, p := range ps {
+		f := live[p]
+		id := f.pkg.ID
+		if _, ok := ir.Packages[id]; !ok {
+			ir.Packages[id] = f.pkg
+		}
+		env := claircore.Environment{
+			PackageDB:    f.pkg.PackageDB,
+			IntroducedIn: f.layer,
+		}
+		if f.repo != nil {
+			ir.Repositories[f.repo.ID] = f.repo
+			env.RepositoryIDs = []string{f.repo.ID}
+		}
+		ir.Environments[id] = append(ir.Environments[id], &env)
+	}
+	return ir, nil
+}
+
+// WhitedOut reports whether the whiteout file "wh" removes the path "p", as
+// described in
+// https://github.com/opencontainers/image-spec/blob/main/layer.md#whiteouts
+func whitedOut(p, wh string) bool {
+	dir, base := path.Split(wh)
+	switch {
+	case base == ".wh..wh..opq":
+		// An opaque whiteout hides everything in its directory.
+		return strings.HasPrefix(p, dir)
+	case strings.HasPrefix(base, ".wh."):
+		t := dir + strings.TrimPrefix(base, ".wh.")
+		return p == t || strings.HasPrefix(p, t+"/")
+	}
+	return false
+}
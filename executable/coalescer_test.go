@@ -0,0 +1,87 @@
+package executable
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/indexer"
+)
+
+func TestCoalescer(t *testing.T) {
+	ctx := zlog.Test(context.Background(), t)
+	layer := func(n string) claircore.Digest {
+		d, err := claircore.ParseDigest("sha256:" + strings.Repeat(n, 64))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	pkg := func(id, p string) *claircore.Package {
+		return &claircore.Package{ID: id, Name: p, PackageDB: packageDBPrefix + p, Filepath: p}
+	}
+	repo := &claircore.Repository{ID: "r", Name: Repository.Name, URI: Repository.URI}
+	wh := func(p string) claircore.File {
+		return claircore.File{Path: p, Kind: claircore.FileKindWhiteout}
+	}
+	l1, l2, l3 := layer("1"), layer("2"), layer("3")
+	ls := []*indexer.LayerArtifacts{
+		{
+			Hash:  l1,
+			Repos: []*claircore.Repository{repo},
+			Pkgs: []*claircore.Package{
+				pkg("1", "usr/bin/a"),
+				pkg("2", "usr/bin/b"),
+				pkg("3", "usr/lib/c"),
+			},
+		},
+		{
+			Hash:  l2,
+			Repos: []*claircore.Repository{repo},
+			Pkgs: []*claircore.Package{
+				// Rewritten without changes.
+				pkg("1", "usr/bin/a"),
+				// Copied.
+				pkg("1", "usr/sbin/a"),
+				// Replaced.
+				pkg("4", "usr/bin/b"),
+				pkg("5", "usr/lib/d/e"),
+			},
+			Files: []claircore.File{wh("usr/lib/.wh.c")},
+		},
+		{
+			Hash:  l3,
+			Files: []claircore.File{wh("usr/lib/d/.wh..wh..opq")},
+		},
+	}
+
+	ir, err := (&coalescer{}).Coalesce(ctx, ls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := func(p string, l claircore.Digest) *claircore.Environment {
+		return &claircore.Environment{
+			PackageDB:     packageDBPrefix + p,
+			IntroducedIn:  l,
+			RepositoryIDs: []string{"r"},
+		}
+	}
+	want := &claircore.IndexReport{
+		Packages: map[string]*claircore.Package{
+			"1": ls[0].Pkgs[0],
+			"4": ls[1].Pkgs[2],
+		},
+		Environments: map[string][]*claircore.Environment{
+			"1": {env("usr/bin/a", l1), env("usr/sbin/a", l2)},
+			"4": {env("usr/bin/b", l2)},
+		},
+		Repositories: map[string]*claircore.Repository{"r": repo},
+	}
+	if !cmp.Equal(ir, want, cmp.AllowUnexported(claircore.Digest{})) {
+		t.Error(cmp.Diff(ir, want, cmp.AllowUnexported(claircore.Digest{})))
+	}
+}
@@ -0,0 +1,26 @@
+package executable
+
+import (
+	"context"
+
+	"github.com/quay/claircore/indexer"
+	"github.com/quay/claircore/whiteout"
+)
+
+// NewEcosystem provides the ecosystem for inventorying ELF executables and
+// shared objects.
+func NewEcosystem(ctx context.Context) *indexer.Ecosystem {
+	return &indexer.Ecosystem{
+		Name: "executable",
+		PackageScanners: func(context.Context) ([]indexer.PackageScanner, error) {
+			return []indexer.PackageScanner{Scanner{}}, nil
+		},
+		DistributionScanners: func(context.Context) ([]indexer.DistributionScanner, error) { return nil, nil },
+		RepositoryScanners:   func(context.Context) ([]indexer.RepositoryScanner, error) { return nil, nil },
+		// The coalescer needs whiteouts to know which files were removed.
+		FileScanners: func(context.Context) ([]indexer.FileScanner, error) {
+			return []indexer.FileScanner{&whiteout.Scanner{}}, nil
+		},
+		Coalescer: func(context.Context) (indexer.Coalescer, error) { return &coalescer{}, nil },
+	}
+}
@@ -0,0 +1,273 @@
+// Package executable implements a package scanner that inventories the ELF
+// executables and shared objects in a layer.
+//
+// Every such file is reported as a [claircore.Package] with the
+// [claircore.ELFInfo] member populated. The package's name is the file's
+// soname, if it has one, or its base name otherwise, and its version is the
+// SHA-256 digest of the file's contents. The file's path is reported in the
+// PackageDB, prefixed with "elf:".
+//
+// This ecosystem isn't enabled by default, as it reads every candidate file in
+// every layer. Use [github.com/quay/claircore/libindex.Options.WithEcosystem]
+// to add it.
+package executable
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"debug/elf"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"runtime/trace"
+	"strings"
+
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/indexer"
+)
+
+const (
+	scannerName    = `executable`
+	scannerVersion = `1`
+	scannerKind    = `package`
+
+	// PackageDBPrefix is prefixed to the path of a file to make the
+	// PackageDB of the reported package.
+	packageDBPrefix = `elf:`
+)
+
+var (
+	_ indexer.PackageScanner     = Scanner{}
+	_ indexer.DefaultRepoScanner = Scanner{}
+
+	// Repository is the repository associated with every package reported by
+	// the Scanner.
+	Repository = claircore.Repository{
+		Name: "elf",
+	}
+)
+
+// ELF type strings reported in [claircore.ELFInfo].
+const (
+	TypeExecutable   = `executable`
+	TypeSharedObject = `shared_object`
+)
+
+// Scanner records the ELF executables and shared objects in a layer.
+//
+// Candidate files are regular files that are executable by someone or that
+// have ".so" in their name. Symlinks aren't followed, so a library that's
+// reachable by several names is only reported once.
+type Scanner struct{}
+
+// Name implements [indexer.PackageScanner].
+func (Scanner) Name() string { return scannerName }
+
+// Version implements [indexer.PackageScanner].
+func (Scanner) Version() string { return scannerVersion }
+
+// Kind implements [indexer.PackageScanner].
+func (Scanner) Kind() string { return scannerKind }
+
+// DefaultRepository implements [indexer.DefaultRepoScanner].
+func (Scanner) DefaultRepository(context.Context) *claircore.Repository {
+	return &Repository
+}
+
+// Scan implements [indexer.PackageScanner].
+func (Scanner) Scan(ctx context.Context, l *claircore.Layer) ([]*claircore.Package, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	defer trace.StartRegion(ctx, "Scanner.Scan").End()
+	trace.Log(ctx, "layer", l.Hash.String())
+	ctx = zlog.ContextWithValues(ctx,
+		"component", "executable/Scanner.Scan",
+		"version", scannerVersion,
+		"layer", l.Hash.String())
+	zlog.Debug(ctx).Msg("start")
+	defer zlog.Debug(ctx).Msg("done")
+
+	sys, err := l.FS()
+	if err != nil {
+		return nil, fmt.Errorf("executable: unable to open layer: %w", err)
+	}
+
+	// The ELF parser needs an io.ReaderAt, so files are copied into a single
+	// spool file that's reused for every candidate.
+	var spool *os.File
+	defer func() {
+		if spool != nil {
+			spool.Close()
+		}
+	}()
+	var out []*claircore.Package
+	magic := make([]byte, len(elf.ELFMAG))
+	walk := func(p string, d fs.DirEntry, err error) error {
+		switch {
+		case err != nil:
+			return err
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case !d.Type().IsRegular():
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if fi.Mode().Perm()&0o111 == 0 && !strings.Contains(d.Name(), ".so") {
+			return nil
+		}
+		f, err := sys.Open(p)
+		if err != nil {
+			return fmt.Errorf("executable: unable to open %q: %w", p, err)
+		}
+		defer f.Close()
+		_, err = io.ReadFull(f, magic)
+		switch {
+		case errors.Is(err, nil):
+		case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+			return nil
+		default:
+			return fmt.Errorf("executable: unable to read %q: %w", p, err)
+		}
+		if string(magic) != elf.ELFMAG {
+			return nil
+		}
+
+		if spool == nil {
+			spool, err = os.CreateTemp("", "executable.spool.")
+			if err != nil {
+				return fmt.Errorf("executable: unable to create spool: %w", err)
+			}
+			if err := os.Remove(spool.Name()); err != nil {
+				return fmt.Errorf("executable: unable to unlink spool: %w", err)
+			}
+		}
+		if err := spool.Truncate(0); err != nil {
+			return fmt.Errorf("executable: unable to reset spool: %w", err)
+		}
+		if _, err := spool.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("executable: unable to reset spool: %w", err)
+		}
+		h := sha256.New()
+		w := io.MultiWriter(spool, h)
+		w.Write(magic) // Can't fail.
+		sz, err := io.Copy(w, f)
+		if err != nil {
+			return fmt.Errorf("executable: unable to spool %q: %w", p, err)
+		}
+		sz += int64(len(magic))
+
+		pkg, err := inspect(io.NewSectionReader(spool, 0, sz))
+		if err != nil {
+			// Corrupt or unusual files shouldn't fail the whole layer.
+			zlog.Debug(ctx).
+				Err(err).
+				Str("path", p).
+				Msg("unable to parse ELF file")
+			return nil
+		}
+		if pkg == nil {
+			return nil
+		}
+		if pkg.Name == "" {
+			pkg.Name = path.Base(p)
+		}
+		pkg.Version = fmt.Sprintf("%s:%x", claircore.SHA256, h.Sum(nil))
+		pkg.Kind = claircore.BINARY
+		pkg.PackageDB = packageDBPrefix + p
+		pkg.Filepath = p
+		out = append(out, pkg)
+		return nil
+	}
+	if err := fs.WalkDir(sys, ".", walk); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Inspect parses the ELF file in "r" and returns a partially filled Package
+// describing it. A nil Package is returned for ELF files that aren't
+// executables or shared objects.
+func inspect(r io.ReaderAt) (*claircore.Package, error) {
+	f, err := elf.NewFile(r)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var info claircore.ELFInfo
+	for _, p := range f.Progs {
+		if p.Type != elf.PT_INTERP {
+			continue
+		}
+		b, err := io.ReadAll(io.LimitReader(p.Open(), 4096))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read interpreter: %w", err)
+		}
+		info.Interpreter = string(bytes.TrimRight(b, "\x00"))
+		break
+	}
+	switch {
+	case f.Type == elf.ET_EXEC:
+		info.Type = TypeExecutable
+	case f.Type == elf.ET_DYN && info.Interpreter != "":
+		// Position-independent executable.
+		info.Type = TypeExecutable
+	case f.Type == elf.ET_DYN:
+		info.Type = TypeSharedObject
+	default:
+		return nil, nil
+	}
+	// Statically linked files have no dynamic section, which isn't an error.
+	if f.Section(".dynamic") != nil || f.SectionByType(elf.SHT_DYNAMIC) != nil {
+		info.Needed, err = f.ImportedLibraries()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read DT_NEEDED: %w", err)
+		}
+		soname, err := f.DynString(elf.DT_SONAME)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read DT_SONAME: %w", err)
+		}
+		if len(soname) != 0 {
+			info.SONAME = soname[0]
+		}
+	}
+	return &claircore.Package{
+		Name: info.SONAME,
+		Arch: arch(f),
+		ELF:  &info,
+	}, nil
+}
+
+// Arch returns an architecture name for the ELF file, using the names used by
+// "uname -m".
+func arch(f *elf.File) string {
+	switch f.Machine {
+	case elf.EM_X86_64:
+		return "x86_64"
+	case elf.EM_386:
+		return "i686"
+	case elf.EM_AARCH64:
+		return "aarch64"
+	case elf.EM_ARM:
+		return "arm"
+	case elf.EM_PPC64:
+		if f.Data == elf.ELFDATA2LSB {
+			return "ppc64le"
+		}
+		return "ppc64"
+	case elf.EM_S390:
+		return "s390x"
+	case elf.EM_RISCV:
+		return "riscv64"
+	}
+	return strings.ToLower(strings.TrimPrefix(f.Machine.String(), "EM_"))
+}
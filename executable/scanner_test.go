@@ -0,0 +1,124 @@
+package executable
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+)
+
+func TestScanner(t *testing.T) {
+	ctx := zlog.Test(context.Background(), t)
+	// Use the ELF fixtures that ship with the standard library.
+	td := filepath.Join(runtime.GOROOT(), "src", "debug", "elf", "testdata")
+	read := func(t *testing.T, n string) []byte {
+		t.Helper()
+		b, err := os.ReadFile(filepath.Join(td, n))
+		if err != nil {
+			t.Skipf("unable to read fixture: %v", err)
+		}
+		return b
+	}
+	exe := read(t, "gcc-amd64-linux-exec")
+	lib := read(t, "libtiffxx.so_")
+	digest := func(b []byte) string {
+		return fmt.Sprintf("sha256:%x", sha256.Sum256(b))
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range []struct {
+		name string
+		mode int64
+		data []byte
+	}{
+		{"usr/bin/hello", 0o755, exe},
+		{"usr/lib/libtiffxx.so.0.0.0", 0o644, lib},
+		{"usr/bin/script", 0o755, []byte("#!/bin/sh\n")},
+		{"usr/share/doc/data", 0o644, exe},
+	} {
+		h := tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     f.name,
+			Mode:     f.mode,
+			Size:     int64(len(f.data)),
+		}
+		if err := tw.WriteHeader(&h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeSymlink,
+		Name:     "usr/lib/libtiffxx.so.0",
+		Linkname: "libtiffxx.so.0.0.0",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var l claircore.Layer
+	desc := claircore.LayerDescription{
+		Digest:    "sha256:" + strings.Repeat("00c0ffee", 8),
+		MediaType: `application/vnd.oci.image.layer.v1.tar`,
+	}
+	if err := l.Init(ctx, &desc, bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := l.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	got, err := Scanner{}.Scan(ctx, &l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*claircore.Package{
+		{
+			Name:      "hello",
+			Version:   digest(exe),
+			Kind:      claircore.BINARY,
+			PackageDB: "elf:usr/bin/hello",
+			Filepath:  "usr/bin/hello",
+			Arch:      "x86_64",
+			ELF: &claircore.ELFInfo{
+				Type:        TypeExecutable,
+				Interpreter: "/lib64/ld-linux-x86-64.so.2",
+				Needed:      []string{"libc.so.6"},
+			},
+		},
+		{
+			Name:      "libtiffxx.so.6",
+			Version:   digest(lib),
+			Kind:      claircore.BINARY,
+			PackageDB: "elf:usr/lib/libtiffxx.so.0.0.0",
+			Filepath:  "usr/lib/libtiffxx.so.0.0.0",
+			Arch:      "x86_64",
+			ELF: &claircore.ELFInfo{
+				Type:   TypeSharedObject,
+				SONAME: "libtiffxx.so.6",
+				Needed: []string{"libtiff.so.6", "libstdc++.so.6", "libc.so.6"},
+			},
+		},
+	}
+	if !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(got, want))
+	}
+}
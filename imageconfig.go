@@ -0,0 +1,37 @@
+package claircore
+
+import "time"
+
+// ImageConfig is the metadata from an image's configuration blob, as
+// described by the [OCI image spec].
+//
+// Docker image configurations are also supported, as they have the same
+// layout.
+//
+// [OCI image spec]: https://github.com/opencontainers/image-spec/blob/main/config.md
+type ImageConfig struct {
+	// the time the image was created, if recorded
+	Created *time.Time `json:"created,omitempty"`
+	// the CPU architecture and operating system the image is built for
+	Architecture string `json:"architecture,omitempty"`
+	OS           string `json:"os,omitempty"`
+	// the variant of the CPU architecture, such as "v8" for arm64
+	Variant string `json:"variant,omitempty"`
+	// the user or UID, with an optional group, that processes run as
+	User string `json:"user,omitempty"`
+	// the exposed ports, in the form "port/protocol", sorted
+	ExposedPorts []string `json:"exposed_ports,omitempty"`
+	// the environment, as "KEY=value" pairs
+	Env []string `json:"env,omitempty"`
+	// the default command, split into arguments
+	Entrypoint []string `json:"entrypoint,omitempty"`
+	Cmd        []string `json:"cmd,omitempty"`
+	// the working directory for processes
+	WorkingDir string `json:"working_dir,omitempty"`
+	// the image's labels
+	Labels map[string]string `json:"labels,omitempty"`
+	// the volumes, as paths, sorted
+	Volumes []string `json:"volumes,omitempty"`
+	// the signal used to stop processes
+	StopSignal string `json:"stop_signal,omitempty"`
+}
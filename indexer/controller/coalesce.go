@@ -24,9 +24,18 @@ func coalesce(ctx context.Context, s *Controller) (State, error) {
 		default:
 		}
 		artifacts := []*indexer.LayerArtifacts{}
-		pkgScanners, _ := ecosystem.PackageScanners(gctx)
-		distScanners, _ := ecosystem.DistributionScanners(gctx)
-		repoScanners, _ := ecosystem.RepositoryScanners(gctx)
+		pkgScanners := []indexer.PackageScanner{}
+		if ecosystem.PackageScanners != nil {
+			pkgScanners, _ = ecosystem.PackageScanners(gctx)
+		}
+		distScanners := []indexer.DistributionScanner{}
+		if ecosystem.DistributionScanners != nil {
+			distScanners, _ = ecosystem.DistributionScanners(gctx)
+		}
+		repoScanners := []indexer.RepositoryScanner{}
+		if ecosystem.RepositoryScanners != nil {
+			repoScanners, _ = ecosystem.RepositoryScanners(gctx)
+		}
 		fileScanners := []indexer.FileScanner{}
 		if ecosystem.FileScanners != nil {
 			fileScanners, _ = ecosystem.FileScanners(gctx)
@@ -101,9 +110,26 @@ func coalesce(ctx context.Context, s *Controller) (State, error) {
 	for _, r := range s.Resolvers {
 		s.report = r.Resolve(ctx, s.report, s.manifest.Layers)
 	}
+	s.report.PackageFiles = packageFiles(s.report)
 	return IndexManifest, nil
 }
 
+// PackageFiles collects the files owned by the packages in the report, keyed
+// by package ID. It returns nil if no scanner reported any files.
+func packageFiles(ir *claircore.IndexReport) map[string][]string {
+	var out map[string][]string
+	for id, p := range ir.Packages {
+		if len(p.Files) == 0 {
+			continue
+		}
+		if out == nil {
+			out = make(map[string][]string)
+		}
+		out[id] = p.Files
+	}
+	return out
+}
+
 // MergeSR merges IndexReports.
 //
 // "Source" is the IndexReport that the Indexer is working on.
@@ -12,6 +12,16 @@ import (
 func fetchLayers(ctx context.Context, s *Controller) (State, error) {
 	zlog.Info(ctx).Msg("layers fetch start")
 	defer zlog.Info(ctx).Msg("layers fetch done")
+	if s.manifest.Config != nil {
+		cfg, err := fetchConfig(ctx, s)
+		if err != nil {
+			zlog.Warn(ctx).
+				Err(err).
+				Msg("image config fetch failure")
+			return Terminal, fmt.Errorf("failed to fetch image config: %w", err)
+		}
+		s.report.Config = cfg
+	}
 	toFetch, err := reduce(ctx, s.Store, s.Vscnrs, s.manifest.Layers)
 	if err != nil {
 		return Terminal, fmt.Errorf("failed to determine layers to fetch: %w", err)
@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/indexer"
+)
+
+// MaxConfigSize is the largest image configuration that will be read.
+const maxConfigSize = 8 << 20
+
+// FetchConfig fetches and parses the image configuration described by the
+// manifest.
+//
+// The Realizer is used if it implements [indexer.BlobFetcher], so that any
+// authentication it does applies. Otherwise, the configured HTTP client is
+// used.
+func fetchConfig(ctx context.Context, s *Controller) (*claircore.ImageConfig, error) {
+	desc := s.manifest.Config
+	d, err := claircore.ParseDigest(desc.Digest)
+	if err != nil {
+		return nil, err
+	}
+	var rc io.ReadCloser
+	if f, ok := s.Realizer.(indexer.BlobFetcher); ok {
+		rc, err = f.FetchBlob(ctx, desc)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		rc, err = getBlob(ctx, s.Client, desc)
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer rc.Close()
+
+	h := d.Hash()
+	b, err := io.ReadAll(io.LimitReader(io.TeeReader(rc, h), maxConfigSize+1))
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("unable to read image config: %w", err)
+	case len(b) > maxConfigSize:
+		return nil, fmt.Errorf("image config too large (over %d bytes)", maxConfigSize)
+	}
+	if got, want := h.Sum(nil), d.Checksum(); !bytes.Equal(got, want) {
+		return nil, fmt.Errorf("image config validation failed: got %q, expected %q",
+			hex.EncodeToString(got), hex.EncodeToString(want))
+	}
+	zlog.Debug(ctx).
+		Int("size", len(b)).
+		Msg("fetched image config")
+	return parseConfig(b)
+}
+
+// GetBlob fetches the described blob with "c".
+func getBlob(ctx context.Context, c *http.Client, desc *claircore.LayerDescription) (io.ReadCloser, error) {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, desc.URI, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range desc.Headers {
+		req.Header[k] = append([]string(nil), vs...)
+	}
+	res, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("unexpected status code fetching image config: %s", res.Status)
+	}
+	return res.Body, nil
+}
+
+// ParseConfig parses an OCI or Docker image configuration.
+func parseConfig(b []byte) (*claircore.ImageConfig, error) {
+	var raw struct {
+		Created      string `json:"created"`
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+		Variant      string `json:"variant"`
+		Config       struct {
+			User         string              `json:"User"`
+			ExposedPorts map[string]struct{} `json:"ExposedPorts"`
+			Env          []string            `json:"Env"`
+			Entrypoint   []string            `json:"Entrypoint"`
+			Cmd          []string            `json:"Cmd"`
+			Volumes      map[string]struct{} `json:"Volumes"`
+			WorkingDir   string              `json:"WorkingDir"`
+			Labels       map[string]string   `json:"Labels"`
+			StopSignal   string              `json:"StopSignal"`
+		} `json:"config"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("unable to parse image config: %w", err)
+	}
+	keys := func(m map[string]struct{}) []string {
+		if len(m) == 0 {
+			return nil
+		}
+		out := make([]string, 0, len(m))
+		for k := range m {
+			out = append(out, k)
+		}
+		sort.Strings(out)
+		return out
+	}
+	c := &raw.Config
+	cfg := claircore.ImageConfig{
+		Architecture: raw.Architecture,
+		OS:           raw.OS,
+		Variant:      raw.Variant,
+		User:         c.User,
+		ExposedPorts: keys(c.ExposedPorts),
+		Env:          c.Env,
+		Entrypoint:   c.Entrypoint,
+		Cmd:          c.Cmd,
+		WorkingDir:   c.WorkingDir,
+		Labels:       c.Labels,
+		Volumes:      keys(c.Volumes),
+		StopSignal:   c.StopSignal,
+	}
+	// Some builders leave the creation time empty or write garbage, which
+	// shouldn't fail the whole parse.
+	if t, err := time.Parse(time.RFC3339Nano, raw.Created); err == nil {
+		cfg.Created = &t
+	}
+	return &cfg, nil
+}
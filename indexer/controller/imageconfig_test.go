@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/indexer"
+)
+
+const testConfig = `{
+  "created": "2023-04-01T12:00:00Z",
+  "architecture": "arm64",
+  "variant": "v8",
+  "os": "linux",
+  "config": {
+    "User": "1000:1000",
+    "ExposedPorts": {"8080/tcp": {}, "53/udp": {}},
+    "Env": ["PATH=/usr/local/bin:/usr/bin", "LANG=C.UTF-8"],
+    "Entrypoint": ["/entrypoint.sh"],
+    "Cmd": ["serve", "--port", "8080"],
+    "Volumes": {"/data": {}},
+    "WorkingDir": "/srv",
+    "Labels": {"org.opencontainers.image.source": "https://example.com/repo"},
+    "StopSignal": "SIGTERM"
+  },
+  "rootfs": {"type": "layers", "diff_ids": []},
+  "history": []
+}`
+
+func TestFetchConfig(t *testing.T) {
+	ctx := zlog.Test(context.Background(), t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.oci.image.config.v1+json")
+		fmt.Fprint(w, testConfig)
+	}))
+	defer srv.Close()
+	good := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(testConfig)))
+	bad := fmt.Sprintf("sha256:%x", sha256.Sum256(nil))
+	created := time.Date(2023, 4, 1, 12, 0, 0, 0, time.UTC)
+	want := &claircore.ImageConfig{
+		Created:      &created,
+		Architecture: "arm64",
+		OS:           "linux",
+		Variant:      "v8",
+		User:         "1000:1000",
+		ExposedPorts: []string{"53/udp", "8080/tcp"},
+		Env:          []string{"PATH=/usr/local/bin:/usr/bin", "LANG=C.UTF-8"},
+		Entrypoint:   []string{"/entrypoint.sh"},
+		Cmd:          []string{"serve", "--port", "8080"},
+		WorkingDir:   "/srv",
+		Labels:       map[string]string{"org.opencontainers.image.source": "https://example.com/repo"},
+		Volumes:      []string{"/data"},
+		StopSignal:   "SIGTERM",
+	}
+
+	tt := []struct {
+		name    string
+		digest  string
+		headers map[string][]string
+		wantErr bool
+	}{
+		{name: "OK", digest: good, headers: map[string][]string{"Authorization": {"Bearer secret"}}},
+		{name: "BadDigest", digest: bad, headers: map[string][]string{"Authorization": {"Bearer secret"}}, wantErr: true},
+		{name: "Unauthorized", digest: good, wantErr: true},
+	}
+	for _, table := range tt {
+		t.Run(table.name, func(t *testing.T) {
+			ctx := zlog.Test(ctx, t)
+			s := New(&indexer.Options{Client: srv.Client()})
+			s.manifest = &claircore.Manifest{
+				Config: &claircore.LayerDescription{
+					Digest:  table.digest,
+					URI:     srv.URL + "/config",
+					Headers: table.headers,
+				},
+			}
+			got, err := fetchConfig(ctx, s)
+			if table.wantErr {
+				if err == nil {
+					t.Error("expected error")
+				}
+				t.Log(err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(got, want) {
+				t.Error(cmp.Diff(got, want))
+			}
+		})
+	}
+}
+
+func TestParseConfigCreated(t *testing.T) {
+	// Unparsable creation times are dropped rather than failing the parse.
+	got, err := parseConfig([]byte(`{"created":"","os":"linux","config":{}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Created != nil || got.OS != "linux" {
+		t.Errorf("unexpected config: %+v", got)
+	}
+}
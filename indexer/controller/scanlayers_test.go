@@ -186,3 +186,64 @@ func setupCalls[C scanRecorder, T storeRecorder, Mc mock[C], Mt mock[T]](m Mc, s
 	scan.Version().AnyTimes().Return("1")
 	return m
 }
+
+func TestScanPackageFiles(t *testing.T) {
+	ctx := zlog.Test(context.Background(), t)
+	_, descs := test.ServeLayers(t, 1)
+	ls := wart.DescriptionsToLayers(descs)
+	d, err := claircore.NewDigest("sha256", make([]byte, sha256.Size))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, keep := range []bool{false, true} {
+		t.Run(fmt.Sprintf("Keep=%v", keep), func(t *testing.T) {
+			ctx := zlog.Test(ctx, t)
+			ctrl := gomock.NewController(t)
+			store := indexer_mock.NewMockStore(ctrl)
+			pkg := indexer_mock.NewMockPackageScanner(ctrl)
+			pkg.EXPECT().Kind().AnyTimes().Return("package")
+			pkg.EXPECT().Name().AnyTimes().Return("package")
+			pkg.EXPECT().Version().AnyTimes().Return("1")
+			pkg.EXPECT().Scan(gomock.Any(), gomock.Any()).Return([]*claircore.Package{
+				{Name: "a", Files: []string{"usr/bin/a"}},
+			}, nil)
+			store.EXPECT().LayerScanned(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, nil)
+			store.EXPECT().SetLayerScanned(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+			var stored []*claircore.Package
+			store.EXPECT().IndexPackages(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, ps []*claircore.Package, _ *claircore.Layer, _ indexer.VersionedScanner) error {
+					stored = ps
+					return nil
+				})
+
+			opts := &indexer.Options{
+				Store: store,
+				Ecosystems: []*indexer.Ecosystem{{
+					Name: "test-ecosystem",
+					PackageScanners: func(context.Context) ([]indexer.PackageScanner, error) {
+						return []indexer.PackageScanner{pkg}, nil
+					},
+					DistributionScanners: func(context.Context) ([]indexer.DistributionScanner, error) { return nil, nil },
+					RepositoryScanners:   func(context.Context) ([]indexer.RepositoryScanner, error) { return nil, nil },
+				}},
+				PackageFiles: keep,
+			}
+			c := New(opts)
+			c.manifest = &claircore.Manifest{Hash: d, Layers: ls}
+			c.LayerScanner, err = indexer.NewLayerScanner(ctx, 1, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := scanLayers(ctx, c); err != nil {
+				t.Fatal(err)
+			}
+			if len(stored) != 1 {
+				t.Fatalf("got %d stored packages, want 1", len(stored))
+			}
+			if got, want := len(stored[0].Files) != 0, keep; got != want {
+				t.Errorf("got files %v, want kept: %v", stored[0].Files, want)
+			}
+		})
+	}
+}
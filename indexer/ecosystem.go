@@ -14,6 +14,9 @@ import (
 //
 // A Controller will scan layers with all scanners present in its configured
 // ecosystems.
+//
+// Any of the scanner functions may be nil, meaning the ecosystem has no
+// scanners of that kind. The Coalescer function must be provided.
 type Ecosystem struct {
 	PackageScanners      func(ctx context.Context) ([]PackageScanner, error)
 	DistributionScanners func(ctx context.Context) ([]DistributionScanner, error)
@@ -39,43 +42,49 @@ func EcosystemsToScanners(ctx context.Context, ecosystems []*Ecosystem) ([]Packa
 	}
 
 	for _, ecosystem := range ecosystems {
-		pscanners, err := ecosystem.PackageScanners(ctx)
-		if err != nil {
-			return nil, nil, nil, nil, err
-		}
-		for _, s := range pscanners {
-			n := s.Name()
-			if _, ok := seen.pkg[n]; ok {
-				continue
+		if ecosystem.PackageScanners != nil {
+			pscanners, err := ecosystem.PackageScanners(ctx)
+			if err != nil {
+				return nil, nil, nil, nil, err
+			}
+			for _, s := range pscanners {
+				n := s.Name()
+				if _, ok := seen.pkg[n]; ok {
+					continue
+				}
+				seen.pkg[n] = struct{}{}
+				ps = append(ps, s)
 			}
-			seen.pkg[n] = struct{}{}
-			ps = append(ps, s)
 		}
 
-		dscanners, err := ecosystem.DistributionScanners(ctx)
-		if err != nil {
-			return nil, nil, nil, nil, err
-		}
-		for _, s := range dscanners {
-			n := s.Name()
-			if _, ok := seen.dist[n]; ok {
-				continue
+		if ecosystem.DistributionScanners != nil {
+			dscanners, err := ecosystem.DistributionScanners(ctx)
+			if err != nil {
+				return nil, nil, nil, nil, err
+			}
+			for _, s := range dscanners {
+				n := s.Name()
+				if _, ok := seen.dist[n]; ok {
+					continue
+				}
+				seen.dist[n] = struct{}{}
+				ds = append(ds, s)
 			}
-			seen.dist[n] = struct{}{}
-			ds = append(ds, s)
 		}
 
-		rscanners, err := ecosystem.RepositoryScanners(ctx)
-		if err != nil {
-			return nil, nil, nil, nil, err
-		}
-		for _, s := range rscanners {
-			n := s.Name()
-			if _, ok := seen.repo[n]; ok {
-				continue
+		if ecosystem.RepositoryScanners != nil {
+			rscanners, err := ecosystem.RepositoryScanners(ctx)
+			if err != nil {
+				return nil, nil, nil, nil, err
+			}
+			for _, s := range rscanners {
+				n := s.Name()
+				if _, ok := seen.repo[n]; ok {
+					continue
+				}
+				seen.repo[n] = struct{}{}
+				rs = append(rs, s)
 			}
-			seen.repo[n] = struct{}{}
-			rs = append(rs, s)
 		}
 
 		if ecosystem.FileScanners != nil {
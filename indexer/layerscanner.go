@@ -18,6 +18,8 @@ type LayerScanner struct {
 
 	// Maximum allowed in-flight scanners per Scan call
 	inflight int
+	// Keep the files attributed to packages.
+	files bool
 
 	// Pre-constructed and configured scanners.
 	ps  []PackageScanner
@@ -50,6 +52,7 @@ func NewLayerScanner(ctx context.Context, concurrent int, opts *Options) (*Layer
 	return &LayerScanner{
 		store:    opts.Store,
 		inflight: concurrent,
+		files:    opts.PackageFiles,
 		ps:       configAndFilter(ctx, opts, ps),
 		ds:       configAndFilter(ctx, opts, ds),
 		rs:       configAndFilter(ctx, opts, rs),
@@ -204,6 +207,11 @@ func (ls *LayerScanner) scanLayer(ctx context.Context, l *claircore.Layer, s Ver
 	if err := result.Do(ctx, s, l); err != nil {
 		return err
 	}
+	if !ls.files {
+		for _, p := range result.pkgs {
+			p.Files = nil
+		}
+	}
 
 	if err = ls.store.SetLayerScanned(ctx, l.Hash, s); err != nil {
 		return fmt.Errorf("could not set layer scanned: %w", err)
@@ -16,4 +16,7 @@ type Options struct {
 	Ecosystems   []*Ecosystem
 	Resolvers    []Resolver
 	Vscnrs       VersionedScanners
+	// PackageFiles keeps the files scanners attribute to packages. If unset,
+	// they're discarded before the packages are stored.
+	PackageFiles bool
 }
@@ -0,0 +1,74 @@
+package indexer
+
+import (
+	"path"
+	"strings"
+)
+
+// PathScanner is an optional interface for scanners that only read the
+// contents of files whose paths match a known set of patterns.
+//
+// Scanners implementing this interface may be handed layers containing only
+// the matching files, along with the metadata (directories, links, and empty
+// files) for the rest of the layer. See [MatchPath] for the pattern syntax.
+type PathScanner interface {
+	// Paths returns the patterns for the files the scanner reads. Patterns
+	// are relative to the root of the layer.
+	Paths() []string
+}
+
+// ScannerPaths returns the patterns for all the scanners in "vs".
+//
+// The second return value is false if any scanner does not implement
+// [PathScanner], in which case the whole layer is needed.
+func ScannerPaths(vs VersionedScanners) ([]string, bool) {
+	var ps []string
+	seen := make(map[string]struct{})
+	for _, v := range vs {
+		s, ok := v.(PathScanner)
+		if !ok {
+			return nil, false
+		}
+		for _, p := range s.Paths() {
+			if _, ok := seen[p]; ok {
+				continue
+			}
+			seen[p] = struct{}{}
+			ps = append(ps, p)
+		}
+	}
+	return ps, true
+}
+
+// MatchPath reports whether "name" matches the shell pattern "pattern".
+//
+// Patterns use the syntax of [path.Match] for each path element, and an
+// element of "**" matches zero or more whole elements. Any leading "/" in
+// either argument is ignored. Malformed patterns never match.
+func MatchPath(pattern, name string) bool {
+	p := strings.Split(strings.Trim(pattern, "/"), "/")
+	n := strings.Split(strings.Trim(name, "/"), "/")
+	return matchElems(p, n)
+}
+
+// MatchElems is the recursive helper for MatchPath.
+func matchElems(p, n []string) bool {
+	for len(p) != 0 {
+		if p[0] == "**" {
+			for i := len(n); i >= 0; i-- {
+				if matchElems(p[1:], n[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(n) == 0 {
+			return false
+		}
+		if ok, err := path.Match(p[0], n[0]); err != nil || !ok {
+			return false
+		}
+		p, n = p[1:], n[1:]
+	}
+	return len(n) == 0
+}
@@ -2,6 +2,7 @@ package indexer
 
 import (
 	"context"
+	"io"
 
 	"github.com/quay/claircore"
 )
@@ -22,6 +23,15 @@ type DescriptionRealizer interface {
 	Close() error
 }
 
+// BlobFetcher is an optional interface for a [Realizer] that can fetch blobs
+// other than layers, such as image configurations, using the same transport
+// as for layers.
+type BlobFetcher interface {
+	// FetchBlob returns the contents of the described blob. The contents are
+	// returned as-is and are not checked against the digest.
+	FetchBlob(context.Context, *claircore.LayerDescription) (io.ReadCloser, error)
+}
+
 // FetchArena does coordination and global refcounting.
 type FetchArena interface {
 	Realizer(context.Context) Realizer
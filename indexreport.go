@@ -33,6 +33,11 @@ type IndexReport struct {
 	Success bool `json:"success"`
 	// an error string in the case the index did not succeed
 	Err string `json:"err"`
+	// the paths of the files owned by each package key'd by package id, if file
+	// attribution is enabled
+	PackageFiles map[string][]string `json:"package_files,omitempty"`
+	// the image configuration, if the manifest described one
+	Config *ImageConfig `json:"config,omitempty"`
 	// Files doesn't end up in the json report but needs to be available at post-coalesce
 	Files map[string]File `json:"-"`
 }
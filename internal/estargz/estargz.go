@@ -0,0 +1,186 @@
+// Package estargz reads the table of contents of eStargz layers.
+//
+// An eStargz layer is a gzip-compressed tar where every regular file's
+// contents (or every chunk of a large file) start a new gzip member, followed
+// by a gzip member holding a JSON table of contents and a fixed-size footer
+// recording the offset of the table of contents. This makes it possible to
+// read individual files with HTTP range requests.
+//
+// See https://github.com/containerd/stargz-snapshotter/blob/main/docs/estargz.md.
+package estargz
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FooterSize is the size of the footer at the end of an eStargz layer.
+const FooterSize = 51
+
+// TOCName is the name of the tar member holding the table of contents.
+const TOCName = `stargz.index.json`
+
+// MaxTOCSize is the maximum size of a decompressed table of contents that
+// ReadTOC will accept.
+const MaxTOCSize = 64 << 20
+
+// ErrFormat is returned (wrapped) for input that is not in the eStargz
+// format.
+var ErrFormat = errors.New("estargz: invalid format")
+
+// ParseFooter reports the offset of the table of contents recorded in the
+// footer "b", which must be the last FooterSize bytes of the layer.
+//
+// Some writers encode the footer's empty body in fewer bytes, so the footer
+// is located by its gzip header rather than assumed to fill "b".
+func ParseFooter(b []byte) (int64, error) {
+	if len(b) != FooterSize {
+		return 0, fmt.Errorf("%w: footer is %d bytes", ErrFormat, len(b))
+	}
+	i := bytes.Index(b, footerMagic)
+	if i == -1 {
+		return 0, fmt.Errorf("%w: missing footer header", ErrFormat)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(b[i:]))
+	if err != nil {
+		return 0, fmt.Errorf("%w: footer: %v", ErrFormat, err)
+	}
+	defer zr.Close()
+	// The offset is stored in an RFC 1952 extra subfield with the ID "SG".
+	x := zr.Header.Extra
+	const subfield = 16 + len("STARGZ")
+	if len(x) != 4+subfield || x[0] != 'S' || x[1] != 'G' ||
+		int(binary.LittleEndian.Uint16(x[2:4])) != subfield ||
+		!bytes.HasSuffix(x, []byte("STARGZ")) {
+		return 0, fmt.Errorf("%w: missing footer subfield", ErrFormat)
+	}
+	off, err := strconv.ParseInt(string(x[4:4+16]), 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: bad offset: %v", ErrFormat, err)
+	}
+	return off, nil
+}
+
+// FooterMagic is the start of a gzip header with the FEXTRA flag set.
+var footerMagic = []byte{0x1f, 0x8b, 0x08, 0x04}
+
+// TOC is an eStargz table of contents.
+type TOC struct {
+	Version int      `json:"version"`
+	Entries []*Entry `json:"entries"`
+}
+
+// Entry is an entry in a table of contents.
+//
+// Entries with the type "chunk" continue the contents of the preceding
+// regular file.
+type Entry struct {
+	Name        string            `json:"name"`
+	Type        string            `json:"type"`
+	Size        int64             `json:"size,omitempty"`
+	ModTime3339 string            `json:"modtime,omitempty"`
+	LinkName    string            `json:"linkName,omitempty"`
+	Mode        int64             `json:"mode,omitempty"`
+	UID         int               `json:"uid,omitempty"`
+	GID         int               `json:"gid,omitempty"`
+	Uname       string            `json:"userName,omitempty"`
+	Gname       string            `json:"groupName,omitempty"`
+	Offset      int64             `json:"offset,omitempty"`
+	DevMajor    int64             `json:"devMajor,omitempty"`
+	DevMinor    int64             `json:"devMinor,omitempty"`
+	Xattrs      map[string][]byte `json:"xattrs,omitempty"`
+	Digest      string            `json:"digest,omitempty"`
+	ChunkOffset int64             `json:"chunkOffset,omitempty"`
+	ChunkSize   int64             `json:"chunkSize,omitempty"`
+	ChunkDigest string            `json:"chunkDigest,omitempty"`
+}
+
+// ReadTOC reads the table of contents from "r", which should start at the
+// offset reported by ParseFooter.
+func ReadTOC(r io.Reader) (*TOC, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: table of contents: %v", ErrFormat, err)
+	}
+	defer zr.Close()
+	tr := tar.NewReader(zr)
+	h, err := tr.Next()
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("%w: table of contents: %v", ErrFormat, err)
+	case h.Name != TOCName:
+		return nil, fmt.Errorf("%w: unexpected member %q", ErrFormat, h.Name)
+	case h.Size > MaxTOCSize:
+		return nil, fmt.Errorf("estargz: table of contents too large (%d bytes)", h.Size)
+	}
+	var toc TOC
+	if err := json.NewDecoder(tr).Decode(&toc); err != nil {
+		return nil, fmt.Errorf("%w: table of contents: %v", ErrFormat, err)
+	}
+	return &toc, nil
+}
+
+// CleanName returns the entry's name relative to the layer root, without any
+// leading "./" or "/".
+func (e *Entry) CleanName() string {
+	return strings.TrimPrefix(path.Clean("/"+e.Name), "/")
+}
+
+// Header returns a tar header describing the entry.
+//
+// Chunk entries have no corresponding header and return nil.
+func (e *Entry) Header() *tar.Header {
+	h := tar.Header{
+		Name:     e.CleanName(),
+		Linkname: e.LinkName,
+		Mode:     e.Mode,
+		Uid:      e.UID,
+		Gid:      e.GID,
+		Uname:    e.Uname,
+		Gname:    e.Gname,
+		Devmajor: e.DevMajor,
+		Devminor: e.DevMinor,
+		Format:   tar.FormatPAX,
+	}
+	if t, err := time.Parse(time.RFC3339, e.ModTime3339); err == nil {
+		h.ModTime = t
+	}
+	if len(e.Xattrs) != 0 {
+		h.PAXRecords = make(map[string]string, len(e.Xattrs))
+		for k, v := range e.Xattrs {
+			h.PAXRecords["SCHILY.xattr."+k] = string(v)
+		}
+	}
+	switch e.Type {
+	case "dir":
+		h.Typeflag = tar.TypeDir
+		h.Name += "/"
+	case "reg":
+		h.Typeflag = tar.TypeReg
+		h.Size = e.Size
+	case "symlink":
+		h.Typeflag = tar.TypeSymlink
+	case "hardlink":
+		h.Typeflag = tar.TypeLink
+		h.Linkname = strings.TrimPrefix(path.Clean("/"+e.LinkName), "/")
+	case "char":
+		h.Typeflag = tar.TypeChar
+	case "block":
+		h.Typeflag = tar.TypeBlock
+	case "fifo":
+		h.Typeflag = tar.TypeFifo
+	default:
+		return nil
+	}
+	return &h
+}
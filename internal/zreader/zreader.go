@@ -59,7 +59,15 @@ type detector struct {
 // Detectors is the array of detection hooks.
 var detectors = [...]detector{
 	staticHeader(gzipHeader),
-	staticHeader(zstdHeader),
+	// Zstd streams may also start with a skippable frame, which has a range
+	// of magic numbers. Decoders ignore these frames.
+	{
+		Mask: bytes.Repeat([]byte{0xFF}, len(zstdHeader)),
+		Check: func(b []byte) bool {
+			return bytes.Equal(zstdHeader, b) ||
+				(b[0]&0xF0 == zstdSkippable[0] && bytes.Equal(zstdSkippable[1:], b[1:]))
+		},
+	},
 	// Bzip2 header is technically 2 bytes, but the other valid value for byte 3
 	// is bzip1-compat format and the fourth byte is required to in a certain
 	// range.
@@ -109,7 +117,9 @@ func staticHeader(h []byte) detector {
 var (
 	gzipHeader = []byte{0x1F, 0x8B, 0x08}
 	zstdHeader = []byte{0x28, 0xB5, 0x2F, 0xFD}
-	bzipHeader = []byte{'B', 'Z', 'h'}
+	// The low nibble of the first byte is not significant.
+	zstdSkippable = []byte{0x50, 0x2A, 0x4D, 0x18}
+	bzipHeader    = []byte{'B', 'Z', 'h'}
 )
 
 // ZlibChecksum is the checksum for zlib stream that does not have a provided
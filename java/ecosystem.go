@@ -9,6 +9,7 @@ import (
 // NewEcosystem provides the set of scanners for the java ecosystem.
 func NewEcosystem(ctx context.Context) *indexer.Ecosystem {
 	return &indexer.Ecosystem{
+		Name: "java",
 		PackageScanners: func(_ context.Context) ([]indexer.PackageScanner, error) {
 			return []indexer.PackageScanner{&Scanner{}}, nil
 		},
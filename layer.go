@@ -7,10 +7,12 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"math"
 	"os"
 	"path/filepath"
 	"runtime"
 
+	"github.com/quay/claircore/internal/zreader"
 	"github.com/quay/claircore/pkg/tarfs"
 )
 
@@ -75,9 +77,28 @@ type Layer struct {
 	init    bool // Used to track initialization.
 }
 
+// LayerLimits are resource limits for a Layer, used by [Layer.InitWithLimits].
+//
+// The zero value means no limits.
+type LayerLimits struct {
+	// MaxSize is the largest allowed size of the layer's contents once
+	// decompressed. Both the decompressed archive and the total size of the
+	// files in it are checked. Errors reported for layers over this limit
+	// match [tarfs.ErrLimit].
+	MaxSize int64
+	// MaxFileSize is the largest regular file that's present in the layer's
+	// [fs.FS]. Larger files are left out, so scanners skip them.
+	MaxFileSize int64
+}
+
 // Init initializes a Layer in-place. This is provided for flexibility when
 // constructing a slice of Layers.
 func (l *Layer) Init(ctx context.Context, desc *LayerDescription, r io.ReaderAt) error {
+	return l.InitWithLimits(ctx, desc, r, LayerLimits{})
+}
+
+// InitWithLimits is like [Layer.Init], but applies the limits in "lim".
+func (l *Layer) InitWithLimits(ctx context.Context, desc *LayerDescription, r io.ReaderAt, lim LayerLimits) error {
 	if l.init {
 		return fmt.Errorf("claircore: Init called on already initialized Layer")
 	}
@@ -100,21 +121,30 @@ func (l *Layer) Init(ctx context.Context, desc *LayerDescription, r io.ReaderAt)
 
 	switch desc.MediaType {
 	case `application/vnd.oci.image.layer.v1.tar`,
-		`application/vnd.oci.image.layer.v1.tar+gzip`,
+		`application/vnd.oci.image.layer.nondistributable.v1.tar`:
+	case `application/vnd.oci.image.layer.v1.tar+gzip`,
 		`application/vnd.oci.image.layer.v1.tar+zstd`,
-		`application/vnd.oci.image.layer.nondistributable.v1.tar`,
 		`application/vnd.oci.image.layer.nondistributable.v1.tar+gzip`,
-		`application/vnd.oci.image.layer.nondistributable.v1.tar+zstd`:
-		sys, err := tarfs.New(r)
-		switch {
-		case errors.Is(err, nil):
-		default:
-			return fmt.Errorf("claircore: layer %v: unable to create fs.FS: %w", desc.Digest, err)
+		`application/vnd.oci.image.layer.nondistributable.v1.tar+zstd`,
+		``:
+		// The contents have usually been decompressed by the fetcher, but
+		// may not have been if they came from elsewhere. Look at the first
+		// bytes to find out, which also handles a missing media type.
+		if err := l.decompress(r, lim.MaxSize); err != nil {
+			return fmt.Errorf("claircore: layer %v: %w", desc.Digest, err)
 		}
-		l.sys = sys
 	default:
 		return fmt.Errorf("claircore: layer %v: unknown MediaType %q", desc.Digest, desc.MediaType)
 	}
+	sys, err := tarfs.New(l.rd,
+		tarfs.WithMaxTotalSize(lim.MaxSize),
+		tarfs.WithMaxFileSize(lim.MaxFileSize))
+	switch {
+	case errors.Is(err, nil):
+	default:
+		return fmt.Errorf("claircore: layer %v: unable to create fs.FS: %w", desc.Digest, err)
+	}
+	l.sys = sys
 
 	_, file, line, _ := runtime.Caller(1)
 	fmsg := fmt.Sprintf("%s:%d: Layer not closed", file, line)
@@ -124,6 +154,50 @@ func (l *Layer) Init(ctx context.Context, desc *LayerDescription, r io.ReaderAt)
 	return nil
 }
 
+// Decompress sniffs the contents of "r" and, if they're gzip or zstd
+// compressed, decompresses them into an unlinked temporary file that's used
+// as the Layer's contents from then on.
+//
+// If "max" is positive, decompressing more than that many bytes is an error.
+func (l *Layer) decompress(r io.ReaderAt, max int64) error {
+	zr, kind, err := zreader.Detect(io.NewSectionReader(r, 0, math.MaxInt64))
+	switch {
+	case errors.Is(err, nil):
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		// Too short to be compressed; let tarfs report any problem.
+		return nil
+	default:
+		return fmt.Errorf("unable to detect compression: %w", err)
+	}
+	defer zr.Close()
+	switch kind {
+	case zreader.KindGzip, zreader.KindZstd:
+	default:
+		return nil
+	}
+	f, err := os.CreateTemp("", "layer.")
+	if err != nil {
+		return fmt.Errorf("unable to create decompression buffer: %w", err)
+	}
+	l.cleanup = append(l.cleanup, f)
+	if err := os.Remove(f.Name()); err != nil {
+		return fmt.Errorf("unable to unlink decompression buffer: %w", err)
+	}
+	var src io.Reader = zr
+	if max > 0 {
+		src = io.LimitReader(zr, max+1)
+	}
+	n, err := io.Copy(f, src)
+	if err != nil {
+		return fmt.Errorf("unable to decompress (%v): %w", kind, err)
+	}
+	if max > 0 && n > max {
+		return fmt.Errorf("decompressed layer exceeds %d bytes: %w", max, tarfs.ErrLimit)
+	}
+	l.rd = f
+	return nil
+}
+
 // Close releases held resources by this Layer.
 //
 // Not calling Close may cause the program to panic.
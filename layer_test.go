@@ -1,15 +1,21 @@
 package claircore_test
 
 import (
+	"archive/tar"
 	"bytes"
 	"context"
+	"errors"
 	"io"
+	"io/fs"
 	"os"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/klauspost/compress/zstd"
+
 	"github.com/quay/claircore"
+	"github.com/quay/claircore/pkg/tarfs"
 	"github.com/quay/claircore/test"
 )
 
@@ -81,6 +87,151 @@ func TestLayer(t *testing.T) {
 				t.Errorf("close error: %v", err)
 			}
 		})
+		t.Run("Compressed", func(t *testing.T) {
+			var tarBuf bytes.Buffer
+			tw := tar.NewWriter(&tarBuf)
+			if err := tw.WriteHeader(&tar.Header{Name: "file", Size: 5, Mode: 0o644}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tw.Write([]byte("hello")); err != nil {
+				t.Fatal(err)
+			}
+			if err := tw.Close(); err != nil {
+				t.Fatal(err)
+			}
+			compress := func(t *testing.T, skippable bool) []byte {
+				var buf bytes.Buffer
+				if skippable {
+					// A skippable frame with a 4 byte payload.
+					buf.Write([]byte{0x5E, 0x2A, 0x4D, 0x18, 0x04, 0x00, 0x00, 0x00, 'c', 'c', 'c', 'c'})
+				}
+				zw, err := zstd.NewWriter(&buf)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := zw.Write(tarBuf.Bytes()); err != nil {
+					t.Fatal(err)
+				}
+				if err := zw.Close(); err != nil {
+					t.Fatal(err)
+				}
+				return buf.Bytes()
+			}
+			tt := []struct {
+				Name      string
+				MediaType string
+				Skippable bool
+			}{
+				{Name: "Zstd", MediaType: `application/vnd.oci.image.layer.v1.tar+zstd`},
+				{Name: "Skippable", MediaType: `application/vnd.oci.image.layer.v1.tar+zstd`, Skippable: true},
+				{Name: "NoMediaType"},
+			}
+			for _, tc := range tt {
+				t.Run(tc.Name, func(t *testing.T) {
+					var l claircore.Layer
+					desc := claircore.LayerDescription{
+						Digest:    "sha256:" + strings.Repeat("00c0ffee", 8),
+						MediaType: tc.MediaType,
+					}
+					if err := l.Init(ctx, &desc, bytes.NewReader(compress(t, tc.Skippable))); err != nil {
+						t.Fatalf("unexpected error: %v", err)
+					}
+					t.Cleanup(func() {
+						if err := l.Close(); err != nil {
+							t.Errorf("close error: %v", err)
+						}
+					})
+					sys, err := l.FS()
+					if err != nil {
+						t.Fatal(err)
+					}
+					b, err := fs.ReadFile(sys, "file")
+					if err != nil {
+						t.Fatal(err)
+					}
+					if got, want := string(b), "hello"; got != want {
+						t.Errorf("got %q, want %q", got, want)
+					}
+				})
+			}
+		})
+		t.Run("Limits", func(t *testing.T) {
+			var tarBuf bytes.Buffer
+			tw := tar.NewWriter(&tarBuf)
+			for _, f := range []struct{ name, contents string }{
+				{"small", "hi"},
+				{"large", strings.Repeat("hello", 100)},
+			} {
+				if err := tw.WriteHeader(&tar.Header{Name: f.name, Size: int64(len(f.contents)), Mode: 0o644}); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := tw.Write([]byte(f.contents)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := tw.Close(); err != nil {
+				t.Fatal(err)
+			}
+			var zBuf bytes.Buffer
+			zw, err := zstd.NewWriter(&zBuf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := zw.Write(tarBuf.Bytes()); err != nil {
+				t.Fatal(err)
+			}
+			if err := zw.Close(); err != nil {
+				t.Fatal(err)
+			}
+			desc := claircore.LayerDescription{
+				Digest:    "sha256:" + strings.Repeat("00c0ffee", 8),
+				MediaType: `application/vnd.oci.image.layer.v1.tar+zstd`,
+			}
+
+			t.Run("FileSize", func(t *testing.T) {
+				var l claircore.Layer
+				lim := claircore.LayerLimits{MaxFileSize: 100}
+				if err := l.InitWithLimits(ctx, &desc, bytes.NewReader(zBuf.Bytes()), lim); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				t.Cleanup(func() {
+					if err := l.Close(); err != nil {
+						t.Errorf("close error: %v", err)
+					}
+				})
+				sys, err := l.FS()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := fs.Stat(sys, "small"); err != nil {
+					t.Error(err)
+				}
+				if _, err := fs.Stat(sys, "large"); !errors.Is(err, fs.ErrNotExist) {
+					t.Errorf("unexpected error: %v", err)
+				}
+			})
+			for _, tc := range []struct {
+				Name string
+				Data []byte
+				Max  int64
+			}{
+				// Caught while decompressing.
+				{Name: "Compressed", Data: zBuf.Bytes(), Max: int64(tarBuf.Len() - 1)},
+				// Caught while indexing the archive.
+				{Name: "Uncompressed", Data: tarBuf.Bytes(), Max: 500},
+			} {
+				t.Run(tc.Name, func(t *testing.T) {
+					var l claircore.Layer
+					d := desc
+					d.MediaType = ``
+					err := l.InitWithLimits(ctx, &d, bytes.NewReader(tc.Data), claircore.LayerLimits{MaxSize: tc.Max})
+					t.Logf("error: %v", err)
+					if !errors.Is(err, tarfs.ErrLimit) {
+						t.Errorf("unexpected error: %v", err)
+					}
+				})
+			}
+		})
 		t.Run("DoubleInit", func(t *testing.T) {
 			l := goodLayer(t)
 			t.Cleanup(func() {
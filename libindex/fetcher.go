@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
@@ -26,12 +27,15 @@ import (
 	"github.com/quay/claircore/indexer"
 	"github.com/quay/claircore/internal/wart"
 	"github.com/quay/claircore/internal/zreader"
+	"github.com/quay/claircore/pkg/tarfs"
 )
 
 var (
 	_ indexer.FetchArena          = (*RemoteFetchArena)(nil)
 	_ indexer.Realizer            = (*FetchProxy)(nil)
 	_ indexer.DescriptionRealizer = (*FetchProxy)(nil)
+	_ indexer.BlobFetcher         = (*FetchProxy)(nil)
+	_ limitedArena                = (*RemoteFetchArena)(nil)
 )
 
 // BUG(hank) On Linux, the [RemoteFetchArena] makes use of the O_TMPFILE flag to
@@ -51,6 +55,25 @@ type RemoteFetchArena struct {
 	// The string is a layer digest.
 	rc   sync.Map
 	root string
+	// Partial holds path patterns. If non-empty, layers in the eStargz
+	// format only have the matching files fetched.
+	partial []string
+	// Cache, if non-nil, keeps whole layers across index runs.
+	cache *LayerCache
+	// Limits is set by the Libindex using the arena.
+	limits Limits
+}
+
+// LimitedArena is implemented by FetchArenas that can enforce [Limits].
+type limitedArena interface {
+	setLimits(*Limits)
+}
+
+// SetLimits implements limitedArena.
+//
+// It must be called before the arena is used.
+func (a *RemoteFetchArena) setLimits(l *Limits) {
+	a.limits = *l
 }
 
 // NewRemoteFetchArena returns an initialized RemoteFetchArena.
@@ -62,16 +85,33 @@ func NewRemoteFetchArena(wc *http.Client, root string) *RemoteFetchArena {
 	}
 }
 
+// NewCachedRemoteFetchArena returns an initialized RemoteFetchArena that
+// consults "cache" before fetching a layer and adds fetched layers to it.
+func NewCachedRemoteFetchArena(wc *http.Client, root string, cache *LayerCache) *RemoteFetchArena {
+	a := NewRemoteFetchArena(wc, root)
+	a.cache = cache
+	return a
+}
+
+// LayerFile is the file backing a fetched layer.
+//
+// It's implemented by *tempFile and *cachedFile.
+type layerFile interface {
+	// Reopen returns a new read-only handle to the file.
+	Reopen() (*os.File, error)
+	io.Closer
+}
+
 // Rc is a reference counter.
 type rc struct {
 	sync.Mutex
-	val   *tempFile
+	val   layerFile
 	count int
 	done  func()
 }
 
 // NewRc makes an rc.
-func newRc(v *tempFile, done func()) *rc {
+func newRc(v layerFile, done func()) *rc {
 	return &rc{
 		val:  v,
 		done: done,
@@ -187,7 +227,7 @@ func (a *RemoteFetchArena) fetchInto(ctx context.Context, l *claircore.Layer, cl
 			r.Close()
 			return err
 		}
-		if err := l.Init(ctx, desc, f); err != nil {
+		if err := l.InitWithLimits(ctx, desc, f, a.limits.layerLimits()); err != nil {
 			return errors.Join(err, f.Close(), r.Close())
 		}
 		*cl = closeFunc(func() error {
@@ -238,6 +278,44 @@ func (a *RemoteFetchArena) fetchUnlinkedFile(ctx context.Context, key string, de
 		span.SetStatus(codes.Ok, "")
 		return v.(*rc), nil
 	}
+	if a.cache != nil {
+		if cf, ok := a.cache.get(ctx, key); ok {
+			span.SetAttributes(attribute.Bool("cached", true))
+			rc, err := a.track(key, cf)
+			if err != nil {
+				return nil, err
+			}
+			zlog.Debug(ctx).Msg("layer cache hit")
+			span.SetStatus(codes.Ok, "")
+			return rc, nil
+		}
+	}
+	// Partially fetched layers aren't cached, as their contents depend on the
+	// configured patterns.
+	if len(a.partial) != 0 {
+		f, err := a.fetchPartial(ctx, url, desc)
+		switch {
+		case err == nil:
+			span.SetAttributes(attribute.Bool("partial", true))
+			rc, err := a.track(key, f)
+			if err != nil {
+				return nil, err
+			}
+			zlog.Debug(ctx).Msg("partial layer fetch ok")
+			span.SetStatus(codes.Ok, "")
+			return rc, nil
+		case ctx.Err() != nil:
+			return nil, context.Cause(ctx)
+		case errors.Is(err, errNotPartial):
+			zlog.Debug(ctx).
+				Err(err).
+				Msg("layer not eligible for partial fetch")
+		default:
+			zlog.Info(ctx).
+				Err(err).
+				Msg("partial fetch failed, fetching whole layer")
+		}
+	}
 	// Otherwise, it needs to be populated.
 	f, err := openTemp(a.root)
 	if err != nil {
@@ -289,60 +367,60 @@ func (a *RemoteFetchArena) fetchUnlinkedFile(ctx context.Context, key string, de
 		return nil, fmt.Errorf("fetcher: error determining compression: %w", err)
 	}
 	defer zr.Close()
-	// Look at the content-type and optionally fix it up.
-	ct := resp.Header.Get("content-type")
-	zlog.Debug(ctx).
-		Str("content-type", ct).
-		Msg("reported content-type")
-	span.SetAttributes(attribute.String("payload.content-type", ct), attribute.Stringer("payload.compression.detected", kind))
-	if ct == "" || ct == "text/plain" || ct == "binary/octet-stream" || ct == "application/octet-stream" {
-		switch kind {
-		case zreader.KindGzip:
-			ct = "application/gzip"
-		case zreader.KindZstd:
-			ct = "application/zstd"
-		case zreader.KindNone:
-			ct = "application/x-tar"
-		default:
-			return nil, fmt.Errorf("fetcher: disallowed compression kind: %q", kind.String())
+	// The layer's media type is authoritative if it names a compression
+	// scheme. A plain tar media type may just be the default filled in for
+	// callers using the older Layer-based API, so in that case look at the
+	// content-type and optionally fix it up.
+	mt := desc.MediaType
+	wantZ, ok := layerCompression(mt)
+	if !ok || wantZ == zreader.KindNone {
+		ct := resp.Header.Get("content-type")
+		if mt, _, err := mime.ParseMediaType(ct); err == nil {
+			ct = mt
 		}
 		zlog.Debug(ctx).
 			Str("content-type", ct).
-			Msg("fixed content-type")
-		span.SetAttributes(attribute.String("payload.content-type.detected", ct))
-	}
-
-	var wantZ zreader.Compression
-	switch {
-	case ct == "application/vnd.docker.image.rootfs.diff.tar.gzip":
-		// Catch the old docker media type.
-		fallthrough
-	case ct == "application/gzip" || ct == "application/x-gzip":
-		// GHCR reports gzipped layers as the latter.
-		fallthrough
-	case strings.HasSuffix(ct, ".tar+gzip"):
-		wantZ = zreader.KindGzip
-	case ct == "application/zstd":
-		fallthrough
-	case strings.HasSuffix(ct, ".tar+zstd"):
-		wantZ = zreader.KindZstd
-	case ct == "application/x-tar":
-		fallthrough
-	case strings.HasSuffix(ct, ".tar"):
-		wantZ = zreader.KindNone
-	default:
-		return nil, fmt.Errorf("fetcher: unknown content-type %q", ct)
+			Msg("reported content-type")
+		span.SetAttributes(attribute.String("payload.content-type", ct), attribute.Stringer("payload.compression.detected", kind))
+		if ct == "" || ct == "text/plain" || ct == "binary/octet-stream" || ct == "application/octet-stream" {
+			switch kind {
+			case zreader.KindGzip:
+				ct = "application/gzip"
+			case zreader.KindZstd:
+				ct = "application/zstd"
+			case zreader.KindNone:
+				ct = "application/x-tar"
+			default:
+				return nil, fmt.Errorf("fetcher: disallowed compression kind: %q", kind.String())
+			}
+			zlog.Debug(ctx).
+				Str("content-type", ct).
+				Msg("fixed content-type")
+			span.SetAttributes(attribute.String("payload.content-type.detected", ct))
+		}
+		mt = ct
+		wantZ, ok = layerCompression(mt)
+		if !ok {
+			return nil, fmt.Errorf("fetcher: unknown content-type %q", ct)
+		}
 	}
 	if kind != wantZ {
-		return nil, fmt.Errorf("fetcher: mismatched compression (%q) and content-type (%q)", kind.String(), ct)
+		return nil, fmt.Errorf("fetcher: mismatched compression (%q) and media type (%q)", kind.String(), mt)
 	}
 
+	var src io.Reader = zr
+	if max := a.limits.MaxLayerSize; max > 0 {
+		src = io.LimitReader(zr, max+1)
+	}
 	buf := bufio.NewWriter(f)
-	n, err := io.Copy(buf, zr)
+	n, err := io.Copy(buf, src)
 	zlog.Debug(ctx).Int64("size", n).Msg("wrote file")
 	if err != nil {
 		return nil, err
 	}
+	if max := a.limits.MaxLayerSize; max > 0 && n > max {
+		return nil, fmt.Errorf("fetcher: layer exceeds %d bytes: %w", max, tarfs.ErrLimit)
+	}
 	if err := buf.Flush(); err != nil {
 		return nil, err
 	}
@@ -353,6 +431,39 @@ func (a *RemoteFetchArena) fetchUnlinkedFile(ctx context.Context, key string, de
 		return nil, err
 	}
 
+	var lf layerFile = f
+	if a.cache != nil {
+		if cf, err := a.addToCache(ctx, key, f); err == nil {
+			f.Close()
+			lf = cf
+		} else {
+			zlog.Info(ctx).
+				Err(err).
+				Msg("unable to cache layer")
+		}
+	}
+	rc, err := a.track(key, lf)
+	if err != nil {
+		return nil, err
+	}
+
+	zlog.Debug(ctx).Msg("layer fetch ok")
+	span.SetStatus(codes.Ok, "")
+	return rc, nil
+}
+
+// AddToCache copies the fetched layer "f" into the arena's cache.
+func (a *RemoteFetchArena) addToCache(ctx context.Context, key string, f *tempFile) (*cachedFile, error) {
+	rd, err := f.Reopen()
+	if err != nil {
+		return nil, err
+	}
+	defer rd.Close()
+	return a.cache.put(ctx, key, rd)
+}
+
+// Track adds the fetched file "f" to the arena under "key".
+func (a *RemoteFetchArena) track(key string, f layerFile) (*rc, error) {
 	rc := newRc(f, func() {
 		a.rc.Delete(key)
 	})
@@ -360,12 +471,33 @@ func (a *RemoteFetchArena) fetchUnlinkedFile(ctx context.Context, key string, de
 		rc.Ref().Close()
 		return nil, fmt.Errorf("fetcher: double-store for key %q", key)
 	}
-
-	zlog.Debug(ctx).Msg("layer fetch ok")
-	span.SetStatus(codes.Ok, "")
 	return rc, nil
 }
 
+// LayerCompression reports the compression scheme indicated by a layer media
+// type or HTTP content-type.
+func layerCompression(mt string) (zreader.Compression, bool) {
+	switch {
+	case mt == "application/vnd.docker.image.rootfs.diff.tar.gzip":
+		// Catch the old docker media type.
+		fallthrough
+	case mt == "application/gzip" || mt == "application/x-gzip":
+		// GHCR reports gzipped layers as the latter.
+		fallthrough
+	case strings.HasSuffix(mt, ".tar+gzip"):
+		return zreader.KindGzip, true
+	case mt == "application/zstd" || mt == "application/x-zstd":
+		fallthrough
+	case strings.HasSuffix(mt, ".tar+zstd"):
+		return zreader.KindZstd, true
+	case mt == "application/x-tar":
+		fallthrough
+	case strings.HasSuffix(mt, ".tar"):
+		return zreader.KindNone, true
+	}
+	return zreader.KindNone, false
+}
+
 // Close forgets all references in the arena.
 //
 // Any outstanding Layers may cause keys to be forgotten at unpredictable times.
@@ -417,7 +549,11 @@ func (p *FetchProxy) RealizeDescriptions(ctx context.Context, descs []claircore.
 	ctx, span := tracer.Start(ctx, "RealizeDescriptions")
 	defer span.End()
 	g, ctx := errgroup.WithContext(ctx)
-	g.SetLimit(runtime.GOMAXPROCS(0))
+	lim := p.a.limits.FetchConcurrency
+	if lim < 1 {
+		lim = runtime.GOMAXPROCS(0)
+	}
+	g.SetLimit(lim)
 	ls := make([]claircore.Layer, len(descs))
 	cleanup := make([]io.Closer, len(descs))
 
@@ -445,6 +581,32 @@ func (p *FetchProxy) RealizeDescriptions(ctx context.Context, descs []claircore.
 	return ls, nil
 }
 
+// FetchBlob implements [indexer.BlobFetcher].
+func (p *FetchProxy) FetchBlob(ctx context.Context, desc *claircore.LayerDescription) (io.ReadCloser, error) {
+	u, err := url.ParseRequestURI(desc.URI)
+	if err != nil {
+		return nil, fmt.Errorf("fetcher: failed to parse remote path uri: %w", err)
+	}
+	req := (&http.Request{
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Proto:      "HTTP/1.1",
+		Host:       u.Host,
+		Method:     http.MethodGet,
+		URL:        u,
+		Header:     http.Header(desc.Headers).Clone(),
+	}).WithContext(ctx)
+	res, err := p.a.wc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetcher: request failed: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("fetcher: unexpected status code: %s", res.Status)
+	}
+	return res.Body, nil
+}
+
 // Close marks all the files backing any returned [claircore.Layer] as unused.
 //
 // This method may delete the backing files, necessitating them being fetched by
@@ -2,10 +2,13 @@ package libindex
 
 import (
 	"archive/tar"
+	"bytes"
 	"context"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
@@ -13,13 +16,16 @@ import (
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync/atomic"
 	"testing"
 
+	"github.com/klauspost/compress/zstd"
 	"github.com/quay/zlog"
 
 	"github.com/quay/claircore"
 	"github.com/quay/claircore/internal/wart"
+	"github.com/quay/claircore/pkg/tarfs"
 	"github.com/quay/claircore/test"
 )
 
@@ -248,3 +254,150 @@ func commonLayerServer(t testing.TB, ct int) ([]claircore.LayerDescription, http
 		inner.ServeHTTP(w, r)
 	})
 }
+
+func TestFetchZstd(t *testing.T) {
+	ctx := zlog.Test(context.Background(), t)
+	var tarBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+	if err := tw.WriteHeader(&tar.Header{Name: "file", Size: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var blob bytes.Buffer
+	zw, err := zstd.NewWriter(&blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := zw.Write(tarBuf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(blob.Bytes()))
+
+	tt := []struct {
+		Name        string
+		ContentType string
+		MediaType   string
+	}{
+		{Name: "Sniffed", ContentType: "application/octet-stream", MediaType: `application/vnd.oci.image.layer.v1.tar`},
+		{Name: "ContentType", ContentType: "application/x-zstd", MediaType: `application/vnd.oci.image.layer.v1.tar`},
+		{Name: "MediaType", ContentType: "application/vnd.oci.image.layer.v1.tar", MediaType: `application/vnd.oci.image.layer.v1.tar+zstd`},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctx := zlog.Test(ctx, t)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", tc.ContentType)
+				w.Write(blob.Bytes())
+			}))
+			defer srv.Close()
+			a := NewRemoteFetchArena(srv.Client(), t.TempDir())
+			defer a.Close(ctx)
+			f := a.Realizer(ctx).(*FetchProxy)
+			ls, err := f.RealizeDescriptions(ctx, []claircore.LayerDescription{{
+				Digest:    digest,
+				URI:       srv.URL,
+				MediaType: tc.MediaType,
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			sys, err := ls[0].FS()
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := fs.ReadFile(sys, "file")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := string(b), "hello"; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestFetchLimits(t *testing.T) {
+	ctx := zlog.Test(context.Background(), t)
+	var tarBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+	for _, f := range []struct{ name, contents string }{
+		{"small", "hello"},
+		{"large", strings.Repeat("hello", 1024)},
+	} {
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Size: int64(len(f.contents))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f.contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var blob bytes.Buffer
+	zw, err := zstd.NewWriter(&blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := zw.Write(tarBuf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(blob.Bytes()))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/zstd")
+		w.Write(blob.Bytes())
+	}))
+	defer srv.Close()
+	desc := claircore.LayerDescription{
+		Digest:    digest,
+		URI:       srv.URL,
+		MediaType: `application/vnd.oci.image.layer.v1.tar+zstd`,
+	}
+
+	t.Run("FileSize", func(t *testing.T) {
+		ctx := zlog.Test(ctx, t)
+		a := NewRemoteFetchArena(srv.Client(), t.TempDir())
+		a.setLimits(&Limits{MaxFileSize: 1024})
+		defer a.Close(ctx)
+		f := a.Realizer(ctx).(*FetchProxy)
+		ls, err := f.RealizeDescriptions(ctx, []claircore.LayerDescription{desc})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		sys, err := ls[0].FS()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fs.Stat(sys, "small"); err != nil {
+			t.Error(err)
+		}
+		if _, err := fs.Stat(sys, "large"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("LayerSize", func(t *testing.T) {
+		ctx := zlog.Test(ctx, t)
+		a := NewRemoteFetchArena(srv.Client(), t.TempDir())
+		a.setLimits(&Limits{MaxLayerSize: int64(tarBuf.Len() - 1)})
+		defer a.Close(ctx)
+		f := a.Realizer(ctx).(*FetchProxy)
+		_, err := f.RealizeDescriptions(ctx, []claircore.LayerDescription{desc})
+		t.Logf("error: %v", err)
+		if !errors.Is(err, tarfs.ErrLimit) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,209 @@
+package libindex
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Keychain looks up credentials for container registries.
+type Keychain interface {
+	// Resolve returns the credentials for the registry at "host", which is a
+	// hostname with an optional port.
+	//
+	// Implementations should return (nil, nil) if there are no credentials
+	// for the registry, in which case requests are made anonymously.
+	Resolve(ctx context.Context, host string) (*Credential, error)
+}
+
+// Credential is a set of credentials for a container registry.
+type Credential struct {
+	// Username and Password are used for basic authentication, both to the
+	// registry and to its token service.
+	Username string
+	Password string
+	// IdentityToken is an OAuth2 refresh token, exchanged with the registry's
+	// token service for access tokens.
+	IdentityToken string
+	// RegistryToken is a bearer token sent to the registry as-is.
+	RegistryToken string
+}
+
+// StaticKeychain is a Keychain backed by a map of registry host to
+// credentials.
+type StaticKeychain map[string]Credential
+
+// Resolve implements [Keychain].
+func (k StaticKeychain) Resolve(_ context.Context, host string) (*Credential, error) {
+	c, ok := k[host]
+	if !ok {
+		c, ok = k[registryKey(host)]
+	}
+	if !ok {
+		return nil, nil
+	}
+	return &c, nil
+}
+
+// DockerKeychain is a Keychain backed by a Docker CLI configuration file.
+//
+// Credentials stored in the file itself, credential helpers configured with
+// "credHelpers", and the credential store configured with "credsStore" are
+// supported. Credential helpers are run as "docker-credential-<name>", and so
+// must be in $PATH.
+type DockerKeychain struct {
+	auths   map[string]dockerAuth
+	helpers map[string]string
+	store   string
+}
+
+// DockerConfig is the subset of the Docker CLI configuration file used by
+// DockerKeychain.
+type dockerConfig struct {
+	Auths       map[string]dockerAuth `json:"auths"`
+	CredHelpers map[string]string     `json:"credHelpers"`
+	CredsStore  string                `json:"credsStore"`
+}
+
+// DockerAuth is an entry in the "auths" object of a Docker CLI configuration
+// file.
+type dockerAuth struct {
+	Auth          string `json:"auth"`
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	IdentityToken string `json:"identitytoken"`
+	RegistryToken string `json:"registrytoken"`
+}
+
+// NewDockerKeychain reads the Docker CLI configuration file at "path".
+//
+// If "path" is empty, "config.json" in the directory named by $DOCKER_CONFIG
+// or in "$HOME/.docker" is used. A missing file results in a DockerKeychain
+// that has no credentials.
+func NewDockerKeychain(path string) (*DockerKeychain, error) {
+	if path == "" {
+		dir := os.Getenv("DOCKER_CONFIG")
+		if dir == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, fmt.Errorf("libindex: unable to find docker config: %w", err)
+			}
+			dir = filepath.Join(home, ".docker")
+		}
+		path = filepath.Join(dir, "config.json")
+	}
+	k := DockerKeychain{
+		auths:   make(map[string]dockerAuth),
+		helpers: make(map[string]string),
+	}
+	b, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, nil):
+	case errors.Is(err, fs.ErrNotExist):
+		return &k, nil
+	default:
+		return nil, fmt.Errorf("libindex: unable to read docker config: %w", err)
+	}
+	var cfg dockerConfig
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("libindex: unable to parse docker config %q: %w", path, err)
+	}
+	for key, a := range cfg.Auths {
+		k.auths[registryKey(key)] = a
+	}
+	for key, h := range cfg.CredHelpers {
+		k.helpers[registryKey(key)] = h
+	}
+	k.store = cfg.CredsStore
+	return &k, nil
+}
+
+// Resolve implements [Keychain].
+func (k *DockerKeychain) Resolve(ctx context.Context, host string) (*Credential, error) {
+	key := registryKey(host)
+	if h, ok := k.helpers[key]; ok {
+		return runCredentialHelper(ctx, h, host)
+	}
+	if a, ok := k.auths[key]; ok && a != (dockerAuth{}) {
+		c := Credential{
+			Username:      a.Username,
+			Password:      a.Password,
+			IdentityToken: a.IdentityToken,
+			RegistryToken: a.RegistryToken,
+		}
+		if a.Auth != "" {
+			b, err := base64.StdEncoding.DecodeString(a.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("libindex: bad auth for %q: %w", host, err)
+			}
+			var ok bool
+			c.Username, c.Password, ok = strings.Cut(string(b), ":")
+			if !ok {
+				return nil, fmt.Errorf("libindex: bad auth for %q: missing separator", host)
+			}
+		}
+		return &c, nil
+	}
+	if k.store != "" {
+		return runCredentialHelper(ctx, k.store, host)
+	}
+	return nil, nil
+}
+
+// RunCredentialHelper asks the Docker credential helper "name" for the
+// credentials for "host".
+func runCredentialHelper(ctx context.Context, name, host string) (*Credential, error) {
+	server := host
+	if registryKey(host) == dockerHub {
+		// Docker Hub credentials are stored under the legacy index address.
+		server = "https://index.docker.io/v1/"
+	}
+	cmd := exec.CommandContext(ctx, "docker-credential-"+name, "get")
+	cmd.Stdin = strings.NewReader(server)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// The helper protocol reports missing credentials on stdout.
+		if strings.Contains(stdout.String(), "credentials not found") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("libindex: credential helper %q failed: %w (stderr: %q)", name, err, stderr.String())
+	}
+	var res struct {
+		Username string
+		Secret   string
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &res); err != nil {
+		return nil, fmt.Errorf("libindex: credential helper %q: bad response: %w", name, err)
+	}
+	if res.Username == "<token>" {
+		return &Credential{IdentityToken: res.Secret}, nil
+	}
+	return &Credential{Username: res.Username, Password: res.Secret}, nil
+}
+
+// DockerHub is the key used for all the names Docker Hub goes by.
+const dockerHub = `docker.io`
+
+// RegistryKey normalizes a registry name as found in a Docker CLI
+// configuration file or request URL into a bare host.
+func registryKey(s string) string {
+	if _, rest, ok := strings.Cut(s, "://"); ok {
+		s = rest
+	}
+	s, _, _ = strings.Cut(s, "/")
+	switch s {
+	case "docker.io", "index.docker.io", "registry-1.docker.io":
+		return dockerHub
+	}
+	return s
+}
@@ -0,0 +1,280 @@
+package libindex
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quay/zlog"
+)
+
+// LayerCache is an on-disk cache of fetched layers, keyed by layer digest.
+//
+// Layers are stored decompressed, so a cache hit skips both downloading and
+// decompressing the layer. Once the total size of the cached layers exceeds
+// the configured cap, the least recently used layers are removed. Layers in
+// use by a [RemoteFetchArena] are never removed, so the cap may be exceeded
+// while they're held.
+//
+// A LayerCache may be shared by multiple arenas in one process, but the
+// directory must not be shared between processes.
+type LayerCache struct {
+	dir string
+	max int64
+
+	mu      sync.Mutex
+	size    int64
+	lru     *list.List // of *cacheEntry, most recently used first
+	entries map[string]*list.Element
+}
+
+// CacheEntry is a layer in a LayerCache.
+type cacheEntry struct {
+	key  string
+	path string
+	size int64
+	// Pins is the number of cachedFiles referencing the entry.
+	pins int
+}
+
+// NewLayerCache returns a LayerCache storing layers in "dir", which is
+// created if needed, and using at most "max" bytes.
+//
+// Layers already present in "dir" are kept, ordered by their modification
+// times.
+func NewLayerCache(ctx context.Context, dir string, max int64) (*LayerCache, error) {
+	ctx = zlog.ContextWithValues(ctx,
+		"component", "libindex/NewLayerCache",
+		"dir", dir)
+	if max <= 0 {
+		return nil, fmt.Errorf("libindex: bad cache size: %d", max)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("libindex: unable to create cache: %w", err)
+	}
+	c := LayerCache{
+		dir:     dir,
+		max:     max,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+
+	type found struct {
+		e   *cacheEntry
+		mod time.Time
+	}
+	var seen []found
+	algs, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("libindex: unable to read cache: %w", err)
+	}
+	for _, alg := range algs {
+		if !alg.IsDir() {
+			continue
+		}
+		ents, err := os.ReadDir(filepath.Join(dir, alg.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("libindex: unable to read cache: %w", err)
+		}
+		for _, ent := range ents {
+			p := filepath.Join(dir, alg.Name(), ent.Name())
+			if strings.HasPrefix(ent.Name(), ".") {
+				// Leftover from an interrupted write.
+				if err := os.Remove(p); err != nil {
+					zlog.Warn(ctx).Err(err).Str("path", p).Msg("unable to remove partial file")
+				}
+				continue
+			}
+			fi, err := ent.Info()
+			if err != nil || !fi.Mode().IsRegular() {
+				continue
+			}
+			seen = append(seen, found{
+				e: &cacheEntry{
+					key:  alg.Name() + ":" + ent.Name(),
+					path: p,
+					size: fi.Size(),
+				},
+				mod: fi.ModTime(),
+			})
+		}
+	}
+	sort.Slice(seen, func(i, j int) bool { return seen[i].mod.After(seen[j].mod) })
+	for _, f := range seen {
+		c.entries[f.e.key] = c.lru.PushBack(f.e)
+		c.size += f.e.size
+	}
+	c.mu.Lock()
+	c.evictLocked(ctx)
+	c.mu.Unlock()
+	zlog.Debug(ctx).
+		Int("layers", c.lru.Len()).
+		Int64("size", c.size).
+		Msg("opened layer cache")
+	return &c, nil
+}
+
+// Size reports the total size of the cached layers.
+func (c *LayerCache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// Contains reports whether the layer "key" is in the cache.
+func (c *LayerCache) Contains(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.entries[key]
+	return ok
+}
+
+// Path returns the file path for the layer "key".
+func (c *LayerCache) path(key string) (string, error) {
+	alg, sum, ok := strings.Cut(key, ":")
+	if !ok || alg == "" || sum == "" ||
+		strings.ContainsAny(key, `/\`) || strings.HasPrefix(sum, ".") {
+		return "", fmt.Errorf("libindex: bad cache key %q", key)
+	}
+	return filepath.Join(c.dir, alg, sum), nil
+}
+
+// Get returns the cached layer "key", if present. The returned file pins the
+// entry until it's closed.
+func (c *LayerCache) get(ctx context.Context, key string) (*cachedFile, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*cacheEntry)
+	c.lru.MoveToFront(el)
+	e.pins++
+	// Record the use so the order survives a restart. This is best-effort.
+	now := time.Now()
+	if err := os.Chtimes(e.path, now, now); err != nil {
+		zlog.Debug(ctx).Err(err).Str("path", e.path).Msg("unable to touch cached layer")
+	}
+	return &cachedFile{c: c, e: e}, true
+}
+
+// Put copies the contents of "src" into the cache as the layer "key" and
+// returns the cached layer, pinned as by get.
+//
+// Layers larger than the cache's cap are rejected.
+func (c *LayerCache) put(ctx context.Context, key string, src io.Reader) (_ *cachedFile, err error) {
+	if cf, ok := c.get(ctx, key); ok {
+		return cf, nil
+	}
+	p, err := c.path(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return nil, fmt.Errorf("libindex: unable to create cache: %w", err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(p), ".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("libindex: unable to create cache file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	// Copy at most one byte past the cap, to detect layers that are too
+	// large without writing all of them.
+	n, err := io.Copy(f, io.LimitReader(src, c.max+1))
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("libindex: unable to write cache file: %w", err)
+	case n > c.max:
+		return nil, errTooLarge
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("libindex: unable to write cache file: %w", err)
+	}
+	if err := os.Rename(f.Name(), p); err != nil {
+		return nil, fmt.Errorf("libindex: unable to write cache file: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		// Raced with another arena. The contents are the same, so use the
+		// existing entry.
+		e := el.Value.(*cacheEntry)
+		e.pins++
+		c.lru.MoveToFront(el)
+		return &cachedFile{c: c, e: e}, nil
+	}
+	e := &cacheEntry{key: key, path: p, size: n, pins: 1}
+	c.entries[key] = c.lru.PushFront(e)
+	c.size += n
+	c.evictLocked(ctx)
+	return &cachedFile{c: c, e: e}, nil
+}
+
+// ErrTooLarge is returned by put for layers that can never fit in the cache.
+var errTooLarge = errors.New("libindex: layer larger than cache")
+
+// Release unpins "e".
+func (c *LayerCache) release(ctx context.Context, e *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e.pins--
+	c.evictLocked(ctx)
+}
+
+// EvictLocked removes unpinned entries, least recently used first, until the
+// cache is under its cap. The caller must hold the lock.
+func (c *LayerCache) evictLocked(ctx context.Context) {
+	for el := c.lru.Back(); el != nil && c.size > c.max; {
+		prev := el.Prev()
+		e := el.Value.(*cacheEntry)
+		if e.pins == 0 {
+			if err := os.Remove(e.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				zlog.Warn(ctx).Err(err).Str("path", e.path).Msg("unable to remove cached layer")
+			} else {
+				zlog.Debug(ctx).Str("layer", e.key).Msg("evicted cached layer")
+			}
+			c.lru.Remove(el)
+			delete(c.entries, e.key)
+			c.size -= e.size
+		}
+		el = prev
+	}
+}
+
+// CachedFile is a layer file backed by a LayerCache entry.
+type cachedFile struct {
+	once sync.Once
+	c    *LayerCache
+	e    *cacheEntry
+}
+
+// Reopen implements layerFile.
+func (f *cachedFile) Reopen() (*os.File, error) {
+	return os.Open(f.e.path)
+}
+
+// Close implements layerFile.
+//
+// Close unpins the cache entry, allowing it to be evicted.
+func (f *cachedFile) Close() error {
+	f.once.Do(func() {
+		f.c.release(context.Background(), f.e)
+	})
+	return nil
+}
@@ -0,0 +1,146 @@
+package libindex
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+)
+
+func TestLayerCache(t *testing.T) {
+	ctx := zlog.Test(context.Background(), t)
+	// Make some uncompressed layers, each holding one file.
+	blobs := make(map[string][]byte)
+	var descs []claircore.LayerDescription
+	for i := 0; i < 3; i++ {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		contents := strings.Repeat(fmt.Sprint(i), 1024)
+		if err := tw.WriteHeader(&tar.Header{Name: "file", Size: int64(len(contents)), Mode: 0o644}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(contents)); err != nil {
+			t.Fatal(err)
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		d := fmt.Sprintf("sha256:%x", sha256.Sum256(buf.Bytes()))
+		blobs["/"+d] = buf.Bytes()
+		descs = append(descs, claircore.LayerDescription{
+			Digest:    d,
+			MediaType: `application/vnd.oci.image.layer.v1.tar`,
+		})
+	}
+	// Every layer is the same size; the cache holds two.
+	layerSize := int64(len(blobs["/"+descs[0].Digest]))
+
+	var fetches atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, ok := blobs[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fetches.Add(1)
+		w.Header().Set("Content-Type", "application/x-tar")
+		w.Write(b)
+	}))
+	defer srv.Close()
+	for i := range descs {
+		descs[i].URI = srv.URL + "/" + descs[i].Digest
+	}
+
+	dir := t.TempDir()
+	cache, err := NewLayerCache(ctx, dir, 2*layerSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	realize := func(t *testing.T, d claircore.LayerDescription, want string) {
+		t.Helper()
+		a := NewCachedRemoteFetchArena(srv.Client(), t.TempDir(), cache)
+		defer a.Close(ctx)
+		f := a.Realizer(ctx).(*FetchProxy)
+		ls, err := f.RealizeDescriptions(ctx, []claircore.LayerDescription{d})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		sys, err := ls[0].FS()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := fs.ReadFile(sys, "file")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(b[:1]); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+	checkFetches := func(t *testing.T, want int64) {
+		t.Helper()
+		if got := fetches.Load(); got != want {
+			t.Errorf("got %d fetches, want %d", got, want)
+		}
+	}
+
+	t.Run("Hit", func(t *testing.T) {
+		realize(t, descs[0], "0")
+		checkFetches(t, 1)
+		realize(t, descs[0], "0")
+		checkFetches(t, 1)
+		if !cache.Contains(descs[0].Digest) {
+			t.Error("layer not cached")
+		}
+	})
+	t.Run("Evict", func(t *testing.T) {
+		realize(t, descs[1], "1")
+		realize(t, descs[0], "0") // Make layer 1 the least recently used.
+		realize(t, descs[2], "2")
+		checkFetches(t, 3)
+		if cache.Contains(descs[1].Digest) {
+			t.Error("least recently used layer not evicted")
+		}
+		if !cache.Contains(descs[0].Digest) || !cache.Contains(descs[2].Digest) {
+			t.Error("recently used layer evicted")
+		}
+		if got, want := cache.Size(), 2*layerSize; got != want {
+			t.Errorf("got size %d, want %d", got, want)
+		}
+	})
+	t.Run("Reopen", func(t *testing.T) {
+		cache, err = NewLayerCache(ctx, dir, layerSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Shrinking the cache should keep the most recently used layer.
+		if !cache.Contains(descs[2].Digest) || cache.Contains(descs[0].Digest) {
+			t.Error("wrong layer kept")
+		}
+		realize(t, descs[2], "2")
+		checkFetches(t, 3)
+	})
+	t.Run("TooLarge", func(t *testing.T) {
+		cache, err = NewLayerCache(ctx, t.TempDir(), layerSize-1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		realize(t, descs[0], "0")
+		checkFetches(t, 4)
+		if cache.Contains(descs[0].Digest) {
+			t.Error("oversized layer cached")
+		}
+	})
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/quay/claircore/python"
 	"github.com/quay/claircore/rhel"
 	"github.com/quay/claircore/rhel/rhcc"
+	"github.com/quay/claircore/rhel/rhcos"
 	"github.com/quay/claircore/rpm"
 	"github.com/quay/claircore/ruby"
 	"github.com/quay/claircore/whiteout"
@@ -86,8 +87,15 @@ func New(ctx context.Context, opts *Options, cl *http.Client) (*Libindex, error)
 	if (opts.ScanLockRetry == 0) || (opts.ScanLockRetry < time.Second) {
 		opts.ScanLockRetry = DefaultScanLockRetry
 	}
-	if opts.LayerScanConcurrency == 0 {
-		opts.LayerScanConcurrency = DefaultLayerScanConcurrency
+	if opts.Limits.ScanConcurrency == 0 {
+		opts.Limits.ScanConcurrency = opts.LayerScanConcurrency
+	}
+	if opts.Limits.ScanConcurrency == 0 {
+		opts.Limits.ScanConcurrency = DefaultLayerScanConcurrency
+	}
+	opts.LayerScanConcurrency = opts.Limits.ScanConcurrency
+	if a, ok := opts.FetchArena.(limitedArena); ok {
+		a.setLimits(&opts.Limits)
 	}
 	if opts.ControllerFactory == nil {
 		opts.ControllerFactory = controller.New
@@ -101,10 +109,16 @@ func New(ctx context.Context, opts *Options, cl *http.Client) (*Libindex, error)
 			python.NewEcosystem(ctx),
 			java.NewEcosystem(ctx),
 			rhcc.NewEcosystem(ctx),
+			rhcos.NewEcosystem(ctx),
 			gobin.NewEcosystem(ctx),
 			ruby.NewEcosystem(ctx),
 		}
 	}
+	ecosystems, err := applyRegistrations(ctx, opts.Ecosystems, opts)
+	if err != nil {
+		return nil, err
+	}
+	opts.Ecosystems = ecosystems
 	// Add whiteout objects
 	// Always add the whiteout ecosystem
 	opts.Ecosystems = append(opts.Ecosystems, whiteout.NewEcosystem(ctx))
@@ -154,8 +168,9 @@ func New(ctx context.Context, opts *Options, cl *http.Client) (*Libindex, error)
 		Client:        l.client,
 		ScannerConfig: opts.ScannerConfig,
 		Resolvers:     opts.Resolvers,
+		PackageFiles:  opts.PackageFiles,
 	}
-	l.indexerOptions.LayerScanner, err = indexer.NewLayerScanner(ctx, opts.LayerScanConcurrency, l.indexerOptions)
+	l.indexerOptions.LayerScanner, err = indexer.NewLayerScanner(ctx, opts.Limits.ScanConcurrency, l.indexerOptions)
 	if err != nil {
 		return nil, err
 	}
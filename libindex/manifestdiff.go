@@ -0,0 +1,103 @@
+package libindex
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+)
+
+// ManifestDiff reports how the layers of two Manifests relate.
+//
+// Layers are compared by digest. Every slice is in the order the layers
+// appear in their Manifest, with duplicates removed.
+type ManifestDiff struct {
+	// Prev and Cur are the digests of the compared Manifests.
+	Prev, Cur claircore.Digest
+	// Added are the layers in Cur that are not in Prev.
+	Added []claircore.Digest
+	// Shared are the layers in both Prev and Cur.
+	Shared []claircore.Digest
+	// Removed are the layers in Prev that are not in Cur.
+	Removed []claircore.Digest
+	// Unscanned are the layers in Cur that have not been scanned by every
+	// configured scanner. These are the only layers that indexing Cur will
+	// fetch and scan.
+	//
+	// This is usually the same as Added, but may include Shared layers if
+	// the configured scanners have changed since Prev was indexed, and may
+	// omit Added layers that are present in some other indexed Manifest.
+	Unscanned []claircore.Digest
+}
+
+// DiffManifests compares the layers of the Manifests "prev" and "cur" and
+// reports which layers of "cur" still need to be fetched and scanned. Indexing
+// "cur" with Index already skips the layers that aren't Unscanned, so this is
+// only needed to find out what that will do.
+//
+// The Manifests do not need to have been indexed. If "prev" is nil, every
+// layer of "cur" is Added.
+func (l *Libindex) DiffManifests(ctx context.Context, prev, cur *claircore.Manifest) (*ManifestDiff, error) {
+	if prev == nil {
+		prev = &claircore.Manifest{}
+	}
+	ctx = zlog.ContextWithValues(ctx,
+		"component", "libindex/Libindex.DiffManifests",
+		"prev", prev.Hash.String(),
+		"cur", cur.Hash.String())
+	d := ManifestDiff{
+		Prev: prev.Hash,
+		Cur:  cur.Hash,
+	}
+	inPrev := make(map[string]struct{}, len(prev.Layers))
+	for _, layer := range prev.Layers {
+		inPrev[layer.Hash.String()] = struct{}{}
+	}
+	inCur := make(map[string]struct{}, len(cur.Layers))
+	for _, layer := range cur.Layers {
+		k := layer.Hash.String()
+		if _, ok := inCur[k]; ok {
+			continue
+		}
+		inCur[k] = struct{}{}
+		if _, ok := inPrev[k]; ok {
+			d.Shared = append(d.Shared, layer.Hash)
+		} else {
+			d.Added = append(d.Added, layer.Hash)
+		}
+		scanned, err := l.layerScanned(ctx, layer.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if !scanned {
+			d.Unscanned = append(d.Unscanned, layer.Hash)
+		}
+	}
+	for _, layer := range prev.Layers {
+		k := layer.Hash.String()
+		if _, ok := inCur[k]; ok {
+			continue
+		}
+		// Mark the layer so duplicates in "prev" are only reported once.
+		inCur[k] = struct{}{}
+		d.Removed = append(d.Removed, layer.Hash)
+	}
+	return &d, nil
+}
+
+// LayerScanned reports whether the layer has been scanned by every configured
+// scanner.
+func (l *Libindex) layerScanned(ctx context.Context, hash claircore.Digest) (bool, error) {
+	for _, scnr := range l.vscnrs {
+		ok, err := l.store.LayerScanned(ctx, hash, scnr)
+		if err != nil {
+			return false, fmt.Errorf("libindex: unable to lookup layer %v: %w", hash, err)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,76 @@
+package libindex
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/indexer"
+	mock_indexer "github.com/quay/claircore/test/mock/indexer"
+)
+
+func TestDiffManifests(t *testing.T) {
+	ctx := zlog.Test(context.Background(), t)
+	ctrl := gomock.NewController(t)
+	base, shared, old, added, known := digest("base"), digest("shared"), digest("old"), digest("added"), digest("known")
+	layers := func(ds ...claircore.Digest) []*claircore.Layer {
+		ls := make([]*claircore.Layer, len(ds))
+		for i, d := range ds {
+			ls[i] = &claircore.Layer{Hash: d}
+		}
+		return ls
+	}
+	prev := &claircore.Manifest{Hash: digest("prev"), Layers: layers(base, shared, old)}
+	cur := &claircore.Manifest{Hash: digest("cur"), Layers: layers(base, shared, added, known, added)}
+
+	// The "shared" layer was only scanned by the first scanner, as if the
+	// second had been added after "prev" was indexed.
+	s1, s2 := mock_indexer.NewMockVersionedScanner(ctrl), mock_indexer.NewMockVersionedScanner(ctrl)
+	scanned := map[string]map[indexer.VersionedScanner]bool{
+		base.String():   {s1: true, s2: true},
+		shared.String(): {s1: true},
+		known.String():  {s1: true, s2: true},
+	}
+	s := mock_indexer.NewMockStore(ctrl)
+	s.EXPECT().LayerScanned(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, d claircore.Digest, v indexer.VersionedScanner) (bool, error) {
+			return scanned[d.String()][v], nil
+		}).
+		AnyTimes()
+	li := &Libindex{store: s, vscnrs: indexer.VersionedScanners{s1, s2}}
+
+	got, err := li.DiffManifests(ctx, prev, cur)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &ManifestDiff{
+		Prev:      prev.Hash,
+		Cur:       cur.Hash,
+		Added:     []claircore.Digest{added, known},
+		Shared:    []claircore.Digest{base, shared},
+		Removed:   []claircore.Digest{old},
+		Unscanned: []claircore.Digest{shared, added},
+	}
+	if !cmp.Equal(got, want, cmp.AllowUnexported(claircore.Digest{})) {
+		t.Error(cmp.Diff(got, want, cmp.AllowUnexported(claircore.Digest{})))
+	}
+
+	t.Run("NilPrev", func(t *testing.T) {
+		got, err := li.DiffManifests(ctx, nil, cur)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := &ManifestDiff{
+			Cur:       cur.Hash,
+			Added:     []claircore.Digest{base, shared, added, known},
+			Unscanned: []claircore.Digest{shared, added},
+		}
+		if !cmp.Equal(got, want, cmp.AllowUnexported(claircore.Digest{})) {
+			t.Error(cmp.Diff(got, want, cmp.AllowUnexported(claircore.Digest{})))
+		}
+	})
+}
@@ -3,6 +3,7 @@ package libindex
 import (
 	"time"
 
+	"github.com/quay/claircore"
 	"github.com/quay/claircore/indexer"
 )
 
@@ -26,7 +27,12 @@ type Options struct {
 	// given manifest if lock is taken.
 	ScanLockRetry time.Duration
 	// LayerScanConcurrency specifies the number of layers to be scanned in parallel.
+	//
+	// Deprecated: Use Limits.ScanConcurrency. This is only consulted if that
+	// is unset.
 	LayerScanConcurrency int
+	// Limits controls the resources used while indexing.
+	Limits Limits
 	// LayerFetchOpt is unused and kept here for backwards compatibility.
 	LayerFetchOpt interface{}
 	// NoLayerValidation controls whether layers are checked to actually be
@@ -38,6 +44,9 @@ type Options struct {
 	// if nil the default factory will be used. useful for testing purposes
 	ControllerFactory ControllerFactory
 	// Ecosystems a list of ecosystems to use which define which package databases and coalescing methods we use
+	//
+	// See also [Options.WithEcosystem] and [Options.WithPackageScanner], which
+	// add to these or the defaults.
 	Ecosystems []*indexer.Ecosystem
 	// ScannerConfig holds functions that can be passed into configurable
 	// scanners. They're broken out by kind, and only used if a scanner
@@ -49,4 +58,47 @@ type Options struct {
 		Package, Dist, Repo, File map[string]func(interface{}) error
 	}
 	Resolvers []indexer.Resolver
+	// PackageFiles records the files that scanners attribute to packages and
+	// reports them in [claircore.IndexReport.PackageFiles]. Only some
+	// package scanners (rpm, dpkg, and python) find files, and the lists
+	// take up considerable space in the store.
+	//
+	// Layers are not rescanned when this changes, so layers scanned while it
+	// was unset have no files attributed.
+	PackageFiles bool
+
+	// Registered and extra hold the additions made with the With* methods.
+	registered []*registration
+	extra      []*indexer.Ecosystem
+}
+
+// Limits are resource limits for indexing. Zero values mean the defaults.
+//
+// Most scanners read whole files into memory, so the memory used while
+// scanning is roughly bounded by MaxFileSize times ScanConcurrency.
+//
+// The size limits are only applied by the FetchArena implementations in this
+// package.
+type Limits struct {
+	// ScanConcurrency is the number of scanners run in parallel for a
+	// manifest. If unset, DefaultLayerScanConcurrency is used.
+	ScanConcurrency int
+	// FetchConcurrency is the number of layers fetched in parallel for a
+	// manifest. If unset, the value of GOMAXPROCS is used.
+	FetchConcurrency int
+	// MaxLayerSize is the largest allowed size of a layer once decompressed.
+	// Indexing a manifest with a larger layer fails with an error matching
+	// [github.com/quay/claircore/pkg/tarfs.ErrLimit]. If unset, there's no limit.
+	MaxLayerSize int64
+	// MaxFileSize is the largest file that's presented to scanners. Larger
+	// files are skipped. If unset, there's no limit.
+	MaxFileSize int64
+}
+
+// LayerLimits returns the limits that apply to a single layer.
+func (l *Limits) layerLimits() claircore.LayerLimits {
+	return claircore.LayerLimits{
+		MaxSize:     l.MaxLayerSize,
+		MaxFileSize: l.MaxFileSize,
+	}
 }
@@ -0,0 +1,320 @@
+package libindex
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/indexer"
+	"github.com/quay/claircore/internal/estargz"
+	"github.com/quay/claircore/whiteout"
+)
+
+// ErrNotPartial is returned (wrapped) by fetchPartial when a layer can't be
+// partially fetched, either because it's not an eStargz layer or because the
+// server doesn't support range requests.
+var errNotPartial = errors.New("partial fetch not possible")
+
+// PartialPaths returns the path patterns needed by every scanner in the
+// provided ecosystems, for use as [RegistryOptions.PartialPaths].
+//
+// The whiteout ecosystem that [New] always adds is included. If any scanner
+// does not implement [indexer.PathScanner], it needs whole layers, so nil is
+// returned and every layer is fetched whole.
+func PartialPaths(ctx context.Context, ecosystems []*indexer.Ecosystem) ([]string, error) {
+	ctx = zlog.ContextWithValues(ctx, "component", "libindex/PartialPaths")
+	ecosystems = append(ecosystems[:len(ecosystems):len(ecosystems)], whiteout.NewEcosystem(ctx))
+	ps, ds, rs, fs, err := indexer.EcosystemsToScanners(ctx, ecosystems)
+	if err != nil {
+		return nil, err
+	}
+	vs := indexer.MergeVS(ps, ds, rs, fs)
+	paths, ok := indexer.ScannerPaths(vs)
+	if !ok {
+		var names []string
+		for _, v := range vs {
+			if _, ok := v.(indexer.PathScanner); !ok {
+				names = append(names, v.Name())
+			}
+		}
+		zlog.Info(ctx).
+			Strs("scanners", names).
+			Msg("scanners need whole layers, partial fetching disabled")
+		return nil, nil
+	}
+	return paths, nil
+}
+
+// FetchPartial fetches the files in the layer matching the arena's partial
+// patterns, using HTTP range requests against an eStargz layer.
+//
+// The returned file contains a tar with every directory, link, and empty file
+// in the layer, and the contents of matching regular files. Contents are
+// checked against the digests in the layer's table of contents, but the
+// table of contents itself can't be checked against the layer digest without
+// fetching the whole layer.
+func (a *RemoteFetchArena) fetchPartial(ctx context.Context, u *url.URL, desc *claircore.LayerDescription) (_ *tempFile, err error) {
+	// The media type can't be relied on to be accurate, so look for the
+	// footer instead.
+	res, err := a.getRange(ctx, u, desc, fmt.Sprintf("-%d", estargz.FooterSize))
+	if err != nil {
+		return nil, err
+	}
+	footer, err := io.ReadAll(io.LimitReader(res.Body, estargz.FooterSize+1))
+	res.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("fetcher: unable to read footer: %w", err)
+	}
+	size, err := rangeTotal(res.Header.Get("Content-Range"))
+	if err != nil {
+		return nil, err
+	}
+	tocOff, err := estargz.ParseFooter(footer)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errNotPartial, err)
+	}
+	if tocOff <= 0 || tocOff >= size-estargz.FooterSize {
+		return nil, fmt.Errorf("fetcher: bad table of contents offset %d", tocOff)
+	}
+
+	res, err = a.getRange(ctx, u, desc, fmt.Sprintf("%d-%d", tocOff, size-estargz.FooterSize-1))
+	if err != nil {
+		return nil, err
+	}
+	toc, err := estargz.ReadTOC(bufio.NewReader(res.Body))
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	files := partialFiles(toc, a.partial)
+
+	// Every payload extends to the next payload, or the table of contents.
+	ends := []int64{tocOff}
+	for _, e := range toc.Entries {
+		if e.Offset > 0 {
+			ends = append(ends, e.Offset)
+		}
+	}
+	sort.Slice(ends, func(i, j int) bool { return ends[i] < ends[j] })
+	end := func(off int64) int64 {
+		i := sort.Search(len(ends), func(i int) bool { return ends[i] > off })
+		if i == len(ends) {
+			return tocOff
+		}
+		return ends[i]
+	}
+
+	f, err := openTemp(a.root)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+		}
+	}()
+	buf := bufio.NewWriter(f)
+	tw := tar.NewWriter(buf)
+	var fetched int
+	for _, pf := range files {
+		if err := tw.WriteHeader(pf.Header); err != nil {
+			return nil, fmt.Errorf("fetcher: unable to write %q: %w", pf.Header.Name, err)
+		}
+		if len(pf.Chunks) == 0 {
+			continue
+		}
+		first, last := pf.Chunks[0].Offset, pf.Chunks[len(pf.Chunks)-1].Offset
+		if first <= 0 || last >= tocOff {
+			return nil, fmt.Errorf("fetcher: bad offset for %q", pf.Header.Name)
+		}
+		if err := a.copyPayload(ctx, tw, u, desc, first, end(last), pf); err != nil {
+			return nil, err
+		}
+		fetched++
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := buf.Flush(); err != nil {
+		return nil, err
+	}
+	zlog.Debug(ctx).
+		Int("entries", len(toc.Entries)).
+		Int("fetched", fetched).
+		Msg("partial fetch done")
+	return f, nil
+}
+
+// CopyPayload fetches the compressed bytes in [start, end) and writes the
+// decompressed contents of "pf" to "w".
+func (a *RemoteFetchArena) copyPayload(ctx context.Context, w io.Writer, u *url.URL, desc *claircore.LayerDescription, start, end int64, pf *partialFile) error {
+	res, err := a.getRange(ctx, u, desc, fmt.Sprintf("%d-%d", start, end-1))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	// The payload is one gzip member per chunk, which the gzip reader reads
+	// as one stream. The last member may also contain tar headers for
+	// following entries, which reading exactly the file size skips.
+	zr, err := gzip.NewReader(bufio.NewReader(res.Body))
+	if err != nil {
+		return fmt.Errorf("fetcher: %q: %w", pf.Header.Name, err)
+	}
+	defer zr.Close()
+	h := sha256.New()
+	if _, err := io.CopyN(io.MultiWriter(w, h), zr, pf.Header.Size); err != nil {
+		return fmt.Errorf("fetcher: %q: %w", pf.Header.Name, err)
+	}
+	if d := pf.Digest; d != "" {
+		want, ok := strings.CutPrefix(d, "sha256:")
+		if !ok {
+			return fmt.Errorf("fetcher: %q: unsupported digest %q", pf.Header.Name, d)
+		}
+		if got := hex.EncodeToString(h.Sum(nil)); got != want {
+			return fmt.Errorf("fetcher: %q: validation failed: got %q, expected %q", pf.Header.Name, got, want)
+		}
+	}
+	return nil
+}
+
+// GetRange makes a range request for the layer, returning the response if the
+// server returned the requested range.
+func (a *RemoteFetchArena) getRange(ctx context.Context, u *url.URL, desc *claircore.LayerDescription, rng string) (*http.Response, error) {
+	req := (&http.Request{
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Proto:      "HTTP/1.1",
+		Host:       u.Host,
+		Method:     http.MethodGet,
+		URL:        u,
+		Header:     http.Header(desc.Headers).Clone(),
+	}).WithContext(ctx)
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	req.Header.Set("Range", "bytes="+rng)
+	res, err := a.wc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetcher: request failed: %w", err)
+	}
+	switch res.StatusCode {
+	case http.StatusPartialContent:
+		return res, nil
+	case http.StatusOK, http.StatusRequestedRangeNotSatisfiable:
+		res.Body.Close()
+		return nil, fmt.Errorf("%w: range request returned %s", errNotPartial, res.Status)
+	default:
+		res.Body.Close()
+		return nil, fmt.Errorf("fetcher: unexpected status code: %s", res.Status)
+	}
+}
+
+// RangeTotal reports the complete length from a Content-Range header.
+func rangeTotal(h string) (int64, error) {
+	_, total, ok := strings.Cut(h, "/")
+	if !ok || !strings.HasPrefix(h, "bytes ") {
+		return 0, fmt.Errorf("%w: bad Content-Range %q", errNotPartial, h)
+	}
+	n, err := strconv.ParseInt(total, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: bad Content-Range %q", errNotPartial, h)
+	}
+	return n, nil
+}
+
+// PartialFile is an entry to be written to a partial layer.
+type partialFile struct {
+	Header *tar.Header
+	// Digest is the digest of the contents, if any.
+	Digest string
+	// Chunks are the table of contents entries for the contents, in order.
+	// Chunks is empty for entries with no contents.
+	Chunks []*estargz.Entry
+}
+
+// PartialFiles returns the entries to write for a partial layer: every entry
+// besides regular files with contents, and the regular files with names
+// matching "patterns" or that are the targets of matching links.
+func partialFiles(toc *estargz.TOC, patterns []string) []*partialFile {
+	var all []*partialFile
+	byName := make(map[string]*partialFile)
+	for _, e := range toc.Entries {
+		if e.Type == "chunk" {
+			if n := len(all); n != 0 && all[n-1].Header.Name == e.CleanName() && len(all[n-1].Chunks) != 0 {
+				all[n-1].Chunks = append(all[n-1].Chunks, e)
+			}
+			continue
+		}
+		h := e.Header()
+		if h == nil || e.CleanName() == "" || h.Name == "/" {
+			continue
+		}
+		pf := &partialFile{Header: h, Digest: e.Digest}
+		if h.Typeflag == tar.TypeReg && h.Size > 0 {
+			pf.Chunks = []*estargz.Entry{e}
+		}
+		all = append(all, pf)
+		byName[e.CleanName()] = pf
+	}
+
+	// Work out which contents are wanted, following links from matching
+	// names. The depth limit guards against link loops.
+	want := make(map[string]bool)
+	var mark func(name string, depth int)
+	mark = func(name string, depth int) {
+		pf, ok := byName[name]
+		if !ok || depth > 8 || want[name] {
+			return
+		}
+		want[name] = true
+		switch pf.Header.Typeflag {
+		case tar.TypeSymlink:
+			t := pf.Header.Linkname
+			if !path.IsAbs(t) {
+				t = path.Join(path.Dir(name), t)
+			}
+			mark(strings.TrimPrefix(path.Clean("/"+t), "/"), depth+1)
+		case tar.TypeLink:
+			mark(pf.Header.Linkname, depth+1)
+		}
+	}
+	for name := range byName {
+		for _, p := range patterns {
+			if indexer.MatchPath(p, name) {
+				mark(name, 0)
+				break
+			}
+		}
+	}
+
+	out := make([]*partialFile, 0, len(all))
+	written := make(map[string]bool, len(all))
+	for _, pf := range all {
+		name := strings.TrimSuffix(pf.Header.Name, "/")
+		switch {
+		case len(pf.Chunks) != 0 && !want[name]:
+			continue
+		case pf.Header.Typeflag == tar.TypeLink && !written[pf.Header.Linkname]:
+			// Don't write dangling hard links.
+			continue
+		}
+		written[name] = true
+		out = append(out, pf)
+	}
+	return out
+}
@@ -0,0 +1,312 @@
+package libindex
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/dpkg"
+	"github.com/quay/claircore/gobin"
+	"github.com/quay/claircore/indexer"
+	"github.com/quay/claircore/internal/estargz"
+)
+
+// PartialFixture is a file in a test eStargz layer.
+type partialFixture struct {
+	Name     string
+	Type     byte
+	Linkname string
+	Contents string
+}
+
+// CountingWriter tracks the number of bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// SwitchWriter forwards writes to the current gzip member.
+type switchWriter struct{ zw *gzip.Writer }
+
+func (s *switchWriter) Write(b []byte) (int, error) { return s.zw.Write(b) }
+
+// MkEstargz builds an eStargz layer containing "files".
+func mkEstargz(t *testing.T, files []partialFixture) []byte {
+	t.Helper()
+	var blob bytes.Buffer
+	cw := &countingWriter{w: &blob}
+	sw := &switchWriter{zw: gzip.NewWriter(cw)}
+	tw := tar.NewWriter(sw)
+	toc := estargz.TOC{Version: 1}
+	mtime := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, f := range files {
+		h := &tar.Header{
+			Name:     f.Name,
+			Typeflag: f.Type,
+			Linkname: f.Linkname,
+			Size:     int64(len(f.Contents)),
+			Mode:     0o644,
+			ModTime:  mtime,
+		}
+		e := &estargz.Entry{
+			Name:        f.Name,
+			Size:        h.Size,
+			LinkName:    f.Linkname,
+			Mode:        h.Mode,
+			ModTime3339: mtime.Format(time.RFC3339),
+		}
+		switch f.Type {
+		case tar.TypeDir:
+			h.Mode, e.Mode = 0o755, 0o755
+			e.Type = "dir"
+		case tar.TypeReg:
+			e.Type = "reg"
+		case tar.TypeSymlink:
+			e.Type = "symlink"
+		case tar.TypeLink:
+			e.Type = "hardlink"
+		}
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if f.Type == tar.TypeReg && f.Contents != "" {
+			// Start a new gzip member for the contents.
+			if err := sw.zw.Close(); err != nil {
+				t.Fatal(err)
+			}
+			e.Offset = cw.n
+			e.Digest = fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(f.Contents)))
+			sw.zw = gzip.NewWriter(cw)
+			if _, err := io.WriteString(tw, f.Contents); err != nil {
+				t.Fatal(err)
+			}
+		}
+		toc.Entries = append(toc.Entries, e)
+	}
+	if err := tw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Table of contents, in its own member.
+	tocOff := cw.n
+	js, err := json.Marshal(&toc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := gzip.NewWriter(cw)
+	tw = tar.NewWriter(zw)
+	if err := tw.WriteHeader(&tar.Header{Name: estargz.TOCName, Size: int64(len(js)), Mode: 0o644}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write(js); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Footer: a gzip header with the offset in an extra subfield, an empty
+	// stored block, and an empty trailer.
+	sub := fmt.Sprintf("%016xSTARGZ", tocOff)
+	footer := []byte{0x1f, 0x8b, 0x08, 0x04, 0, 0, 0, 0, 0, 0xff, byte(4 + len(sub)), 0}
+	footer = append(footer, 'S', 'G', byte(len(sub)), 0)
+	footer = append(footer, sub...)
+	footer = append(footer, 0x01, 0x00, 0x00, 0xff, 0xff)
+	footer = append(footer, make([]byte, 8)...)
+	if len(footer) != estargz.FooterSize {
+		t.Fatalf("footer is %d bytes", len(footer))
+	}
+	blob.Write(footer)
+	return blob.Bytes()
+}
+
+func TestParseFooter(t *testing.T) {
+	blob := mkEstargz(t, []partialFixture{
+		{Name: "file", Type: tar.TypeReg, Contents: "hello"},
+	})
+	off, err := estargz.ParseFooter(blob[len(blob)-estargz.FooterSize:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	toc, err := estargz.ReadTOC(bytes.NewReader(blob[off:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(toc.Entries), 1; got != want {
+		t.Errorf("got %d entries, want %d", got, want)
+	}
+
+	// A plain gzip stream isn't eStargz.
+	buf := bytes.NewBuffer(make([]byte, estargz.FooterSize))
+	zw := gzip.NewWriter(buf)
+	zw.Write(bytes.Repeat([]byte{0}, 1024))
+	zw.Close()
+	b := buf.Bytes()
+	_, err = estargz.ParseFooter(b[len(b)-estargz.FooterSize:])
+	if !errors.Is(err, estargz.ErrFormat) {
+		t.Errorf("got error %v, want %v", err, estargz.ErrFormat)
+	}
+}
+
+func TestFetchPartial(t *testing.T) {
+	ctx := zlog.Test(context.Background(), t)
+	const (
+		status = "Package: test\nStatus: install ok installed\nVersion: 1.0\n"
+		filler = "this file should not be fetched"
+	)
+	files := []partialFixture{
+		{Name: "etc/", Type: tar.TypeDir},
+		{Name: "etc/os-release", Type: tar.TypeSymlink, Linkname: "../usr/lib/os-release"},
+		{Name: "usr/", Type: tar.TypeDir},
+		{Name: "usr/lib/", Type: tar.TypeDir},
+		{Name: "usr/lib/os-release", Type: tar.TypeReg, Contents: "ID=test\n"},
+		{Name: "usr/share/big", Type: tar.TypeReg, Contents: filler},
+		{Name: "var/lib/dpkg/status", Type: tar.TypeReg, Contents: status},
+		{Name: "var/lib/dpkg/status-old", Type: tar.TypeLink, Linkname: "var/lib/dpkg/status"},
+		{Name: "var/lib/dpkg/empty", Type: tar.TypeReg},
+	}
+	blob := mkEstargz(t, files)
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(blob))
+
+	tt := []struct {
+		Name    string
+		Ranges  bool
+		Partial bool
+	}{
+		{Name: "Ranges", Ranges: true, Partial: true},
+		{Name: "NoRanges", Ranges: false, Partial: false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctx := zlog.Test(ctx, t)
+			var sent atomic.Int64
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				cw := &countingWriter{w: w}
+				defer func() { sent.Add(cw.n) }()
+				w.Header().Set("Content-Type", "application/octet-stream")
+				if !tc.Ranges {
+					cw.Write(blob)
+					return
+				}
+				http.ServeContent(&rangeWriter{ResponseWriter: w, w: cw}, r, "", time.Time{}, bytes.NewReader(blob))
+			}))
+			defer srv.Close()
+			a := NewRemoteFetchArena(srv.Client(), t.TempDir())
+			a.partial = []string{"**/status", "etc/os-release"}
+			defer a.Close(ctx)
+			f := a.Realizer(ctx).(*FetchProxy)
+			ls, err := f.RealizeDescriptions(ctx, []claircore.LayerDescription{{
+				Digest:    digest,
+				URI:       srv.URL,
+				MediaType: `application/vnd.oci.image.layer.v1.tar+gzip`,
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			sys, err := ls[0].FS()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for name, want := range map[string]string{
+				"var/lib/dpkg/status":     status,
+				"var/lib/dpkg/status-old": status,
+				"etc/os-release":          "ID=test\n",
+				"var/lib/dpkg/empty":      "",
+			} {
+				b, err := fs.ReadFile(sys, name)
+				if err != nil {
+					t.Error(err)
+					continue
+				}
+				if got := string(b); got != want {
+					t.Errorf("%s: got %q, want %q", name, got, want)
+				}
+			}
+			fi, err := fs.Stat(sys, "usr/share/big")
+			switch {
+			case tc.Partial && err == nil:
+				t.Errorf("unwanted file present: %v", fi.Name())
+			case tc.Partial && !errors.Is(err, fs.ErrNotExist):
+				t.Errorf("unexpected error: %v", err)
+			case !tc.Partial && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			}
+			if _, err := fs.Stat(sys, "usr/lib"); err != nil {
+				t.Errorf("missing directory: %v", err)
+			}
+
+			n := sent.Load()
+			t.Logf("sent %d of %d bytes", n, len(blob))
+			if got, want := n < int64(len(blob)), tc.Partial; got != want {
+				t.Errorf("sent %d of %d bytes", n, len(blob))
+			}
+		})
+	}
+}
+
+// RangeWriter is an http.ResponseWriter that sends the body through "w".
+type rangeWriter struct {
+	http.ResponseWriter
+	w io.Writer
+}
+
+func (r *rangeWriter) Write(b []byte) (int, error) { return r.w.Write(b) }
+
+func TestPartialPaths(t *testing.T) {
+	ctx := zlog.Test(context.Background(), t)
+	t.Run("PathScanners", func(t *testing.T) {
+		ctx := zlog.Test(ctx, t)
+		got, err := PartialPaths(ctx, []*indexer.Ecosystem{dpkg.NewEcosystem(ctx)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"var/lib/dpkg/status", "etc/.wh.passwd"} {
+			var ok bool
+			for _, p := range got {
+				ok = ok || indexer.MatchPath(p, name)
+			}
+			if !ok {
+				t.Errorf("%q not matched by %q", name, got)
+			}
+		}
+	})
+	t.Run("WholeLayers", func(t *testing.T) {
+		ctx := zlog.Test(ctx, t)
+		got, err := PartialPaths(ctx, []*indexer.Ecosystem{dpkg.NewEcosystem(ctx), gobin.NewEcosystem(ctx)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != nil {
+			t.Errorf("got %q, want nil", got)
+		}
+	})
+}
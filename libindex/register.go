@@ -0,0 +1,208 @@
+package libindex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/quay/claircore/indexer"
+)
+
+// Registration holds the scanners and Coalescer added to an ecosystem with
+// the With* methods of Options.
+type registration struct {
+	name      string
+	ps        []indexer.PackageScanner
+	ds        []indexer.DistributionScanner
+	rs        []indexer.RepositoryScanner
+	fs        []indexer.FileScanner
+	coalescer func(context.Context) (indexer.Coalescer, error)
+}
+
+// Registration returns the registration for the named ecosystem, creating it
+// if needed.
+func (o *Options) registration(ecosystem string) *registration {
+	for _, r := range o.registered {
+		if r.name == ecosystem {
+			return r
+		}
+	}
+	r := &registration{name: ecosystem}
+	o.registered = append(o.registered, r)
+	return r
+}
+
+// WithPackageScanner adds PackageScanners to the named ecosystem.
+//
+// If the ecosystem is one of the configured Ecosystems (or the defaults, if
+// none are configured), the scanners are used in addition to the ecosystem's
+// own. Otherwise, a new ecosystem is created, and a Coalescer must be provided
+// with [Options.WithCoalescer].
+//
+// Registered scanners must report a non-empty name and version, must report
+// the correct kind, and must not have the same name as another scanner of the
+// same kind. These are checked by [New].
+func (o *Options) WithPackageScanner(ecosystem string, s ...indexer.PackageScanner) *Options {
+	r := o.registration(ecosystem)
+	r.ps = append(r.ps, s...)
+	return o
+}
+
+// WithDistributionScanner adds DistributionScanners to the named ecosystem.
+//
+// See [Options.WithPackageScanner].
+func (o *Options) WithDistributionScanner(ecosystem string, s ...indexer.DistributionScanner) *Options {
+	r := o.registration(ecosystem)
+	r.ds = append(r.ds, s...)
+	return o
+}
+
+// WithRepositoryScanner adds RepositoryScanners to the named ecosystem.
+//
+// See [Options.WithPackageScanner].
+func (o *Options) WithRepositoryScanner(ecosystem string, s ...indexer.RepositoryScanner) *Options {
+	r := o.registration(ecosystem)
+	r.rs = append(r.rs, s...)
+	return o
+}
+
+// WithFileScanner adds FileScanners to the named ecosystem.
+//
+// See [Options.WithPackageScanner].
+func (o *Options) WithFileScanner(ecosystem string, s ...indexer.FileScanner) *Options {
+	r := o.registration(ecosystem)
+	r.fs = append(r.fs, s...)
+	return o
+}
+
+// WithCoalescer sets the function used to construct the Coalescer for the
+// named ecosystem. If the ecosystem is one of the configured Ecosystems, its
+// Coalescer is replaced.
+func (o *Options) WithCoalescer(ecosystem string, f func(context.Context) (indexer.Coalescer, error)) *Options {
+	o.registration(ecosystem).coalescer = f
+	return o
+}
+
+// WithEcosystem adds Ecosystems to the indexer, in addition to the configured
+// Ecosystems or the defaults.
+func (o *Options) WithEcosystem(e ...*indexer.Ecosystem) *Options {
+	o.extra = append(o.extra, e...)
+	return o
+}
+
+// ApplyRegistrations returns the ecosystems in "base" extended with the
+// scanners and ecosystems added via the With* methods of "o".
+//
+// The Ecosystems in "base" are not modified.
+func applyRegistrations(ctx context.Context, base []*indexer.Ecosystem, o *Options) ([]*indexer.Ecosystem, error) {
+	if len(o.registered) == 0 && len(o.extra) == 0 {
+		return base, nil
+	}
+	out := append([]*indexer.Ecosystem(nil), base...)
+	out = append(out, o.extra...)
+
+	ps, ds, rs, fs, err := indexer.EcosystemsToScanners(ctx, out)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]struct{})
+	add := func(vs ...indexer.VersionedScanner) {
+		for _, v := range vs {
+			seen[v.Kind()+"/"+v.Name()] = struct{}{}
+		}
+	}
+	var vs indexer.VersionedScanners
+	vs.PStoVS(ps)
+	add(vs...)
+	vs.DStoVS(ds)
+	add(vs...)
+	vs.RStoVS(rs)
+	add(vs...)
+	vs.FStoVS(fs)
+	add(vs...)
+	check := func(kind string, v indexer.VersionedScanner) error {
+		n := v.Name()
+		switch {
+		case n == "":
+			return fmt.Errorf("%s scanner with empty name", kind)
+		case v.Version() == "":
+			return fmt.Errorf("%s scanner %q: empty version", kind, n)
+		case v.Kind() != kind:
+			return fmt.Errorf("%s scanner %q: reports kind %q", kind, n, v.Kind())
+		}
+		k := kind + "/" + n
+		if _, ok := seen[k]; ok {
+			return fmt.Errorf("%s scanner %q already registered", kind, n)
+		}
+		seen[k] = struct{}{}
+		return nil
+	}
+
+	for _, r := range o.registered {
+		var errs []error
+		for _, s := range r.ps {
+			errs = append(errs, check("package", s))
+		}
+		for _, s := range r.ds {
+			errs = append(errs, check("distribution", s))
+		}
+		for _, s := range r.rs {
+			errs = append(errs, check("repository", s))
+		}
+		for _, s := range r.fs {
+			errs = append(errs, check("file", s))
+		}
+		if err := errors.Join(errs...); err != nil {
+			return nil, fmt.Errorf("libindex: ecosystem %q: %w", r.name, err)
+		}
+
+		i := -1
+		for j, e := range out {
+			if e.Name == r.name {
+				i = j
+				break
+			}
+		}
+		var e indexer.Ecosystem
+		if i == -1 {
+			if r.coalescer == nil {
+				return nil, fmt.Errorf("libindex: ecosystem %q: no Coalescer provided", r.name)
+			}
+			e.Name = r.name
+		} else {
+			e = *out[i]
+		}
+		e.PackageScanners = appendScanners(e.PackageScanners, r.ps)
+		e.DistributionScanners = appendScanners(e.DistributionScanners, r.ds)
+		e.RepositoryScanners = appendScanners(e.RepositoryScanners, r.rs)
+		e.FileScanners = appendScanners(e.FileScanners, r.fs)
+		if r.coalescer != nil {
+			e.Coalescer = r.coalescer
+		}
+		if i == -1 {
+			out = append(out, &e)
+		} else {
+			out[i] = &e
+		}
+	}
+	return out, nil
+}
+
+// AppendScanners returns a function that returns the scanners from "f", if
+// not nil, followed by "extra".
+func appendScanners[S indexer.VersionedScanner](f func(context.Context) ([]S, error), extra []S) func(context.Context) ([]S, error) {
+	if len(extra) == 0 {
+		return f
+	}
+	return func(ctx context.Context) ([]S, error) {
+		var ss []S
+		if f != nil {
+			var err error
+			ss, err = f(ctx)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return append(ss[:len(ss):len(ss)], extra...), nil
+	}
+}
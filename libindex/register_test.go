@@ -0,0 +1,130 @@
+package libindex
+
+import (
+	"context"
+	"testing"
+
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/dpkg"
+	"github.com/quay/claircore/indexer"
+)
+
+type fakeScanner struct {
+	name, version, kind string
+}
+
+func (s *fakeScanner) Name() string    { return s.name }
+func (s *fakeScanner) Version() string { return s.version }
+func (s *fakeScanner) Kind() string    { return s.kind }
+
+func (s *fakeScanner) Scan(context.Context, *claircore.Layer) ([]*claircore.Package, error) {
+	return nil, nil
+}
+
+type fakeCoalescer struct{}
+
+func (fakeCoalescer) Coalesce(context.Context, []*indexer.LayerArtifacts) (*claircore.IndexReport, error) {
+	return &claircore.IndexReport{}, nil
+}
+
+func newFakeCoalescer(context.Context) (indexer.Coalescer, error) {
+	return fakeCoalescer{}, nil
+}
+
+func TestRegistration(t *testing.T) {
+	ctx := zlog.Test(context.Background(), t)
+	names := func(t *testing.T, e *indexer.Ecosystem) []string {
+		t.Helper()
+		ps, err := e.PackageScanners(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []string
+		for _, s := range ps {
+			out = append(out, s.Name())
+		}
+		return out
+	}
+
+	t.Run("Extend", func(t *testing.T) {
+		base := []*indexer.Ecosystem{dpkg.NewEcosystem(ctx)}
+		var opts Options
+		opts.WithPackageScanner("dpkg", &fakeScanner{"extra", "1", "package"})
+		out, err := applyRegistrations(ctx, base, &opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out) != 1 || out[0] == base[0] {
+			t.Fatalf("unexpected ecosystems: %v", out)
+		}
+		want := names(t, base[0])
+		got := names(t, out[0])
+		if len(got) != len(want)+1 || got[len(got)-1] != "extra" {
+			t.Errorf("unexpected scanners: %v", got)
+		}
+	})
+	t.Run("New", func(t *testing.T) {
+		var opts Options
+		opts.
+			WithPackageScanner("custom", &fakeScanner{"custom", "1", "package"}).
+			WithCoalescer("custom", newFakeCoalescer)
+		out, err := applyRegistrations(ctx, []*indexer.Ecosystem{dpkg.NewEcosystem(ctx)}, &opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out) != 2 || out[1].Name != "custom" {
+			t.Fatalf("unexpected ecosystems: %v", out)
+		}
+		if got := names(t, out[1]); len(got) != 1 || got[0] != "custom" {
+			t.Errorf("unexpected scanners: %v", got)
+		}
+		ps, ds, rs, fs, err := indexer.EcosystemsToScanners(ctx, out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("%d package, %d distribution, %d repository, %d file scanners", len(ps), len(ds), len(rs), len(fs))
+	})
+
+	errTable := []struct {
+		name  string
+		setup func(*Options)
+	}{
+		{
+			name: "NoCoalescer",
+			setup: func(o *Options) {
+				o.WithPackageScanner("custom", &fakeScanner{"custom", "1", "package"})
+			},
+		},
+		{
+			name: "Duplicate",
+			setup: func(o *Options) {
+				o.WithPackageScanner("dpkg", &fakeScanner{"dpkg", "1", "package"})
+			},
+		},
+		{
+			name: "NoVersion",
+			setup: func(o *Options) {
+				o.WithPackageScanner("dpkg", &fakeScanner{"extra", "", "package"})
+			},
+		},
+		{
+			name: "WrongKind",
+			setup: func(o *Options) {
+				o.WithPackageScanner("dpkg", &fakeScanner{"extra", "1", "repository"})
+			},
+		},
+	}
+	for _, tc := range errTable {
+		t.Run(tc.name, func(t *testing.T) {
+			var opts Options
+			tc.setup(&opts)
+			_, err := applyRegistrations(ctx, []*indexer.Ecosystem{dpkg.NewEcosystem(ctx)}, &opts)
+			t.Logf("error: %v", err)
+			if err == nil {
+				t.Error("unexpected success")
+			}
+		})
+	}
+}
@@ -0,0 +1,452 @@
+package libindex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/indexer"
+	"github.com/quay/claircore/internal/wart"
+)
+
+var (
+	_ indexer.FetchArena          = (*RegistryFetchArena)(nil)
+	_ indexer.Realizer            = (*registryProxy)(nil)
+	_ indexer.DescriptionRealizer = (*registryProxy)(nil)
+	_ indexer.BlobFetcher         = (*registryProxy)(nil)
+	_ http.RoundTripper           = (*registryTransport)(nil)
+	_ limitedArena                = (*RegistryFetchArena)(nil)
+)
+
+// RegistryOptions configures a RegistryFetchArena.
+type RegistryOptions struct {
+	// Keychain supplies credentials for registries. If nil, all requests are
+	// anonymous.
+	Keychain Keychain
+	// Mirrors maps a registry host to the base URLs of its mirrors, for
+	// example "docker.io" to "https://mirror.example.com". Mirrors are tried
+	// in order before the registry itself.
+	Mirrors map[string][]string
+	// PartialPaths enables partial fetching of layers in the eStargz format.
+	// If non-empty, only the regular files in such layers with paths matching
+	// one of these patterns are fetched, using HTTP range requests. Other
+	// layers are fetched whole.
+	//
+	// The patterns must cover every file read by the configured scanners, or
+	// the scanners will miss their contents; see [PartialPaths]. SOCI indexes
+	// are not supported.
+	PartialPaths []string
+	// Cache, if non-nil, keeps fetched layers on disk across index runs.
+	// Partially fetched layers are not cached.
+	Cache *LayerCache
+}
+
+// RegistryFetchArena is a FetchArena that fetches layers directly from OCI
+// registries.
+//
+// In addition to the URIs accepted by [RemoteFetchArena], layers may have a
+// URI of the form "docker://host/repository", in which case the layer is
+// fetched from the blob endpoint of the named repository. Requests to a
+// registry's blob endpoint, whether named this way or by an "http" or
+// "https" URI, are authenticated using the configured [Keychain] and are
+// tried against the configured mirrors first.
+//
+// Other URIs, such as pre-signed URLs, are fetched as-is.
+type RegistryFetchArena struct {
+	remote *RemoteFetchArena
+}
+
+// NewRegistryFetchArena returns an initialized RegistryFetchArena.
+//
+// The passed http.Client is not modified; requests are made with a copy using
+// a wrapped Transport. A nil "opts" is equivalent to the zero value.
+func NewRegistryFetchArena(wc *http.Client, root string, opts *RegistryOptions) (*RegistryFetchArena, error) {
+	if opts == nil {
+		opts = &RegistryOptions{}
+	}
+	t := &registryTransport{
+		base:    wc.Transport,
+		kc:      opts.Keychain,
+		mirrors: make(map[string][]*url.URL, len(opts.Mirrors)),
+		tokens:  make(map[string]string),
+	}
+	if t.base == nil {
+		t.base = http.DefaultTransport
+	}
+	for host, ms := range opts.Mirrors {
+		key := registryKey(host)
+		for _, m := range ms {
+			u, err := url.Parse(m)
+			if err != nil {
+				return nil, fmt.Errorf("libindex: bad mirror for %q: %w", host, err)
+			}
+			if u.Scheme != "http" && u.Scheme != "https" {
+				return nil, fmt.Errorf("libindex: bad mirror for %q: unsupported scheme %q", host, u.Scheme)
+			}
+			t.mirrors[key] = append(t.mirrors[key], u)
+		}
+	}
+	c := *wc
+	c.Transport = t
+	remote := NewRemoteFetchArena(&c, root)
+	remote.partial = append([]string(nil), opts.PartialPaths...)
+	remote.cache = opts.Cache
+	return &RegistryFetchArena{
+		remote: remote,
+	}, nil
+}
+
+// SetLimits implements limitedArena.
+func (a *RegistryFetchArena) setLimits(l *Limits) {
+	a.remote.setLimits(l)
+}
+
+// Realizer returns an indexer.Realizer.
+//
+// The returned value also implements [indexer.DescriptionRealizer].
+func (a *RegistryFetchArena) Realizer(ctx context.Context) indexer.Realizer {
+	return &registryProxy{
+		FetchProxy: a.remote.Realizer(ctx).(*FetchProxy),
+	}
+}
+
+// Close forgets all references in the arena.
+func (a *RegistryFetchArena) Close(ctx context.Context) error {
+	return a.remote.Close(ctx)
+}
+
+// RegistryProxy rewrites registry references into blob URLs before handing
+// the descriptions to a FetchProxy.
+type registryProxy struct {
+	*FetchProxy
+}
+
+// Realize populates all the layers locally.
+func (p *registryProxy) Realize(ctx context.Context, ls []*claircore.Layer) error {
+	ds := wart.LayersToDescriptions(ls)
+	ret, err := p.RealizeDescriptions(ctx, ds)
+	if err != nil {
+		return err
+	}
+	wart.CopyLayerPointers(ls, ret)
+	return nil
+}
+
+// RealizeDescriptions returns [claircore.Layer] structs populated according to
+// the passed slice of [claircore.LayerDescription].
+func (p *registryProxy) RealizeDescriptions(ctx context.Context, descs []claircore.LayerDescription) ([]claircore.Layer, error) {
+	ds := make([]claircore.LayerDescription, len(descs))
+	copy(ds, descs)
+	for i := range ds {
+		if err := resolveReference(&ds[i]); err != nil {
+			return nil, err
+		}
+	}
+	return p.FetchProxy.RealizeDescriptions(ctx, ds)
+}
+
+// FetchBlob implements [indexer.BlobFetcher].
+func (p *registryProxy) FetchBlob(ctx context.Context, desc *claircore.LayerDescription) (io.ReadCloser, error) {
+	d := *desc
+	if err := resolveReference(&d); err != nil {
+		return nil, err
+	}
+	return p.FetchProxy.FetchBlob(ctx, &d)
+}
+
+// ResolveReference rewrites a "docker://" URI into the blob URL for the
+// described layer or blob.
+func resolveReference(d *claircore.LayerDescription) error {
+	u, err := url.Parse(d.URI)
+	if err != nil || u.Scheme != "docker" {
+		// Let the RemoteFetchArena report any errors.
+		return nil
+	}
+	name := strings.Trim(u.Path, "/")
+	if u.Host == "" || name == "" {
+		return fmt.Errorf("libindex: bad registry reference %q", d.URI)
+	}
+	host := u.Host
+	if registryKey(host) == dockerHub {
+		host = "registry-1.docker.io"
+		if !strings.Contains(name, "/") {
+			name = "library/" + name
+		}
+	}
+	d.URI = (&url.URL{
+		Scheme: "https",
+		Host:   host,
+		Path:   path.Join("/v2", name, "blobs", d.Digest),
+	}).String()
+	return nil
+}
+
+// BlobRepository reports the repository name in the path of a registry blob
+// request, "/v2/<name>/blobs/<digest>".
+func blobRepository(p string) (string, bool) {
+	rest, ok := strings.CutPrefix(p, "/v2/")
+	if !ok {
+		return "", false
+	}
+	i := strings.LastIndex(rest, "/blobs/")
+	if i <= 0 || strings.Contains(rest[i+len("/blobs/"):], "/") {
+		return "", false
+	}
+	return rest[:i], true
+}
+
+// RegistryTransport is an http.RoundTripper that handles mirrors and
+// authentication for registry blob requests.
+type registryTransport struct {
+	base    http.RoundTripper
+	kc      Keychain
+	mirrors map[string][]*url.URL
+
+	mu sync.Mutex
+	// Tokens holds Authorization header values, keyed by host and scope.
+	tokens map[string]string
+}
+
+// RoundTrip implements [http.RoundTripper].
+func (t *registryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	name, ok := blobRepository(req.URL.Path)
+	if !ok || req.Method != http.MethodGet {
+		return t.base.RoundTrip(req)
+	}
+	ctx := req.Context()
+	for _, m := range t.mirrors[registryKey(req.URL.Host)] {
+		r := req.Clone(ctx)
+		u := *m
+		u.Path = path.Join("/", m.Path, req.URL.Path)
+		u.RawPath = ""
+		u.RawQuery = req.URL.RawQuery
+		r.URL = &u
+		r.Host = ""
+		res, err := t.fetch(r, name)
+		switch {
+		case err != nil:
+			zlog.Debug(ctx).
+				Err(err).
+				Stringer("mirror", m).
+				Msg("mirror request failed")
+			continue
+		case res.StatusCode >= http.StatusBadRequest:
+			res.Body.Close()
+			zlog.Debug(ctx).
+				Stringer("mirror", m).
+				Str("status", res.Status).
+				Msg("mirror request failed")
+			continue
+		}
+		return res, nil
+	}
+	return t.fetch(req, name)
+}
+
+// Fetch makes the request "req" for a blob in the repository "name",
+// authenticating if the registry asks for it.
+func (t *registryTransport) fetch(req *http.Request, name string) (*http.Response, error) {
+	if req.Header.Get("Authorization") != "" {
+		// Caller-supplied credentials take precedence.
+		return t.base.RoundTrip(req)
+	}
+	ctx := req.Context()
+	scope := "repository:" + name + ":pull"
+	host := req.URL.Host
+	do := func(auth string) (*http.Response, error) {
+		r := req.Clone(ctx)
+		if r.Header == nil {
+			r.Header = make(http.Header)
+		}
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		return t.base.RoundTrip(r)
+	}
+	cached := t.token(host, scope)
+	res, err := do(cached)
+	if err != nil || res.StatusCode != http.StatusUnauthorized {
+		return res, err
+	}
+	auth, err := t.authorize(ctx, host, res.Header.Get("Www-Authenticate"), scope)
+	if err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+	if auth == "" || auth == cached {
+		// Nothing else to try; hand back the original response.
+		return res, nil
+	}
+	res.Body.Close()
+	res, err = do(auth)
+	if err == nil && res.StatusCode != http.StatusUnauthorized {
+		t.setToken(host, scope, auth)
+	}
+	return res, err
+}
+
+func (t *registryTransport) token(host, scope string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.tokens[host+" "+scope]
+}
+
+func (t *registryTransport) setToken(host, scope, auth string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tokens[host+" "+scope] = auth
+}
+
+// Authorize returns an Authorization header value answering the challenge
+// "challenge" from the registry at "host".
+//
+// An empty string is returned if the challenge can't be answered.
+func (t *registryTransport) authorize(ctx context.Context, host, challenge, scope string) (string, error) {
+	scheme, params := parseChallenge(challenge)
+	var cred *Credential
+	if t.kc != nil {
+		var err error
+		cred, err = t.kc.Resolve(ctx, host)
+		if err != nil {
+			return "", fmt.Errorf("libindex: unable to resolve credentials for %q: %w", host, err)
+		}
+	}
+	if cred == nil {
+		cred = &Credential{}
+	}
+	switch scheme {
+	case "basic":
+		if cred.Username == "" && cred.Password == "" {
+			return "", nil
+		}
+		r := http.Request{Header: make(http.Header)}
+		r.SetBasicAuth(cred.Username, cred.Password)
+		return r.Header.Get("Authorization"), nil
+	case "bearer":
+	default:
+		return "", nil
+	}
+	if cred.RegistryToken != "" {
+		return "Bearer " + cred.RegistryToken, nil
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", fmt.Errorf("libindex: bad realm in challenge from %q: %q", host, challenge)
+	}
+	if s, ok := params["scope"]; ok {
+		scope = s
+	}
+	tok, err := t.requestToken(ctx, realm, params["service"], scope, cred)
+	if err != nil {
+		return "", fmt.Errorf("libindex: unable to get token for %q: %w", host, err)
+	}
+	return "Bearer " + tok, nil
+}
+
+// RequestToken asks the token service at "realm" for a token.
+//
+// See https://distribution.github.io/distribution/spec/auth/token/ and
+// https://distribution.github.io/distribution/spec/auth/oauth/.
+func (t *registryTransport) requestToken(ctx context.Context, realm *url.URL, service, scope string, cred *Credential) (string, error) {
+	var req *http.Request
+	var err error
+	if cred.IdentityToken != "" {
+		v := url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {cred.IdentityToken},
+			"service":       {service},
+			"scope":         {scope},
+			"client_id":     {"claircore"},
+		}
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, realm.String(), strings.NewReader(v.Encode()))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		u := *realm
+		v := u.Query()
+		if service != "" {
+			v.Set("service", service)
+		}
+		v.Set("scope", scope)
+		u.RawQuery = v.Encode()
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return "", err
+		}
+		if cred.Username != "" || cred.Password != "" {
+			req.SetBasicAuth(cred.Username, cred.Password)
+		}
+	}
+	c := http.Client{Transport: t.base}
+	res, err := c.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s %v: unexpected status: %s", req.Method, realm, res.Status)
+	}
+	var tok struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&tok); err != nil {
+		return "", fmt.Errorf("unable to decode token response: %w", err)
+	}
+	switch {
+	case tok.Token != "":
+		return tok.Token, nil
+	case tok.AccessToken != "":
+		return tok.AccessToken, nil
+	}
+	return "", errors.New("empty token response")
+}
+
+// ParseChallenge parses a WWW-Authenticate header value into a lower-cased
+// scheme and its parameters.
+//
+// Only the first challenge in the header is considered.
+func parseChallenge(h string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(h), " ")
+	params := make(map[string]string)
+	for {
+		rest = strings.TrimLeft(rest, " ,")
+		k, v, ok := strings.Cut(rest, "=")
+		if !ok {
+			break
+		}
+		k = strings.ToLower(strings.TrimSpace(k))
+		if strings.HasPrefix(v, `"`) {
+			// Quoted-string, possibly containing commas and escapes.
+			var b strings.Builder
+			i := 1
+			for ; i < len(v) && v[i] != '"'; i++ {
+				if v[i] == '\\' && i+1 < len(v) {
+					i++
+				}
+				b.WriteByte(v[i])
+			}
+			params[k] = b.String()
+			if i < len(v) {
+				i++
+			}
+			rest = v[i:]
+		} else {
+			v, rest, _ = strings.Cut(v, ",")
+			params[k] = strings.TrimSpace(v)
+		}
+	}
+	return strings.ToLower(scheme), params
+}
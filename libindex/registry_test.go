@@ -0,0 +1,247 @@
+package libindex
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/indexer"
+)
+
+// TestRegistry is a minimal registry serving blobs from memory, requiring a
+// bearer token from its token endpoint.
+type testRegistry struct {
+	*httptest.Server
+	blobs map[string][]byte
+	// Tokens counts requests to the token endpoint.
+	tokens atomic.Int64
+	// Anonymous controls whether blobs can be fetched without a token.
+	anonymous bool
+}
+
+func newTestRegistry(t *testing.T, n int) (*testRegistry, []claircore.LayerDescription) {
+	t.Helper()
+	r := &testRegistry{blobs: make(map[string][]byte)}
+	descs := make([]claircore.LayerDescription, n)
+	for i := range descs {
+		var buf bytes.Buffer
+		w := tar.NewWriter(&buf)
+		body := fmt.Sprintf("%032d\n", i)
+		if err := w.WriteHeader(&tar.Header{Name: "file", Size: int64(len(body))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		d := fmt.Sprintf("sha256:%x", sha256.Sum256(buf.Bytes()))
+		r.blobs[d] = buf.Bytes()
+		descs[i] = claircore.LayerDescription{
+			Digest:    d,
+			MediaType: `application/vnd.oci.image.layer.v1.tar`,
+		}
+	}
+	r.Server = httptest.NewTLSServer(r)
+	t.Cleanup(r.Close)
+	return r, descs
+}
+
+func (r *testRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch {
+	case req.URL.Path == "/token":
+		r.tokens.Add(1)
+		if u, p, ok := req.BasicAuth(); !ok || u != "user" || p != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if got, want := req.URL.Query().Get("scope"), "repository:test/repo:pull"; got != want {
+			http.Error(w, "bad scope: "+got, http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"token": "tok"})
+	case strings.HasPrefix(req.URL.Path, "/v2/test/repo/blobs/"):
+		if !r.anonymous && req.Header.Get("Authorization") != "Bearer tok" {
+			w.Header().Set("Www-Authenticate",
+				fmt.Sprintf(`Bearer realm="%s/token",service="test",scope="repository:test/repo:pull"`, r.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		b, ok := r.blobs[strings.TrimPrefix(req.URL.Path, "/v2/test/repo/blobs/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(b)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestRegistryFetch(t *testing.T) {
+	ctx := zlog.Test(context.Background(), t)
+	reg, descs := newTestRegistry(t, 3)
+	host := strings.TrimPrefix(reg.URL, "https://")
+	for i := range descs {
+		descs[i].URI = "docker://" + host + "/test/repo"
+	}
+	// Use a blob URL for one of the layers.
+	descs[2].URI = reg.URL + "/v2/test/repo/blobs/" + descs[2].Digest
+
+	a, err := NewRegistryFetchArena(reg.Client(), t.TempDir(), &RegistryOptions{
+		Keychain: StaticKeychain{host: {Username: "user", Password: "pass"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close(ctx)
+	// Layers are fetched concurrently, so fetch one first to have a token
+	// cached for the rest.
+	var n int
+	for _, ds := range [][]claircore.LayerDescription{descs[:1], descs[1:]} {
+		r := a.Realizer(ctx).(*registryProxy)
+		ls, err := r.RealizeDescriptions(ctx, ds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		n += len(ls)
+	}
+	if got, want := n, len(descs); got != want {
+		t.Errorf("got %d layers, want %d", got, want)
+	}
+	// The token should be reused after the first request.
+	if got := reg.tokens.Load(); got != 1 {
+		t.Errorf("got %d token requests, want 1", got)
+	}
+	if got := descs[0].URI; !strings.HasPrefix(got, "docker://") {
+		t.Errorf("passed descriptions modified: %q", got)
+	}
+
+	// Other blobs can be fetched through the same transport.
+	rc, err := a.Realizer(ctx).(indexer.BlobFetcher).FetchBlob(ctx, &descs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, reg.blobs[descs[0].Digest]) {
+		t.Error("blob contents differ")
+	}
+}
+
+func TestRegistryMirror(t *testing.T) {
+	ctx := zlog.Test(context.Background(), t)
+	reg, descs := newTestRegistry(t, 2)
+	mirror := &testRegistry{
+		blobs:     map[string][]byte{descs[0].Digest: reg.blobs[descs[0].Digest]},
+		anonymous: true,
+	}
+	mirror.Server = httptest.NewTLSServer(mirror)
+	defer mirror.Close()
+	reg.anonymous = true
+	var origin atomic.Int64
+	inner := reg.Config.Handler
+	reg.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin.Add(1)
+		inner.ServeHTTP(w, r)
+	})
+	host := strings.TrimPrefix(reg.URL, "https://")
+	for i := range descs {
+		descs[i].URI = "docker://" + host + "/test/repo"
+	}
+
+	a, err := NewRegistryFetchArena(reg.Client(), t.TempDir(), &RegistryOptions{
+		Mirrors: map[string][]string{host: {mirror.URL}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close(ctx)
+	r := a.Realizer(ctx).(*registryProxy)
+	if _, err := r.RealizeDescriptions(ctx, descs); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	// Only the blob missing from the mirror should be fetched from the
+	// registry.
+	if got := origin.Load(); got != 1 {
+		t.Errorf("got %d requests to the registry, want 1", got)
+	}
+}
+
+func TestDockerKeychain(t *testing.T) {
+	ctx := context.Background()
+	p := filepath.Join(t.TempDir(), "config.json")
+	const cfg = `{"auths":{
+	"https://index.docker.io/v1/": {"auth": "aHViOnNlY3JldA=="},
+	"quay.io": {"username": "robot", "password": "pw"},
+	"registry.example.com": {"identitytoken": "refresh"}
+}}`
+	if err := os.WriteFile(p, []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	k, err := NewDockerKeychain(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tt := []struct {
+		Host string
+		Want *Credential
+	}{
+		{"registry-1.docker.io", &Credential{Username: "hub", Password: "secret"}},
+		{"quay.io", &Credential{Username: "robot", Password: "pw"}},
+		{"registry.example.com", &Credential{IdentityToken: "refresh"}},
+		{"ghcr.io", nil},
+	}
+	for _, tc := range tt {
+		got, err := k.Resolve(ctx, tc.Host)
+		if err != nil {
+			t.Errorf("%s: %v", tc.Host, err)
+			continue
+		}
+		if !cmp.Equal(got, tc.Want) {
+			t.Errorf("%s: %v", tc.Host, cmp.Diff(got, tc.Want))
+		}
+	}
+
+	// A missing file is not an error.
+	if _, err := NewDockerKeychain(filepath.Join(t.TempDir(), "config.json")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:a/b:pull,push"`)
+	if got, want := scheme, "bearer"; got != want {
+		t.Errorf("got scheme %q, want %q", got, want)
+	}
+	want := map[string]string{
+		"realm":   "https://auth.example.com/token",
+		"service": "registry.example.com",
+		"scope":   "repository:a/b:pull,push",
+	}
+	if !cmp.Equal(params, want) {
+		t.Error(cmp.Diff(params, want))
+	}
+}
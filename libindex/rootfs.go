@@ -0,0 +1,320 @@
+package libindex
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/indexer"
+	"github.com/quay/claircore/internal/wart"
+)
+
+var (
+	_ indexer.FetchArena          = (*rootFS)(nil)
+	_ indexer.Realizer            = (*rootFSRealizer)(nil)
+	_ indexer.DescriptionRealizer = (*rootFSRealizer)(nil)
+)
+
+// IndexRootFS indexes a flattened root filesystem, such as a mounted VM
+// image, a host filesystem, or the output of "docker export".
+//
+// The "path" argument names either a directory or a tar file, which may be
+// gzip or zstd compressed. The filesystem is indexed as an image with a
+// single layer. The layer's digest is the digest of the tar file, or of a tar
+// file written from the directory, and the manifest's digest is derived from
+// the layer's digest.
+//
+// When indexing a directory, it's copied into a temporary file, and only
+// directories, regular files, and symlinks are included. The contents of the
+// top-level "proc", "sys", and "dev" directories are skipped, as are files
+// that can't be read.
+//
+// The size limits in [Options.Limits] apply to the synthetic layer.
+func (l *Libindex) IndexRootFS(ctx context.Context, path string) (*claircore.IndexReport, error) {
+	ctx = zlog.ContextWithValues(ctx,
+		"component", "libindex/Libindex.IndexRootFS",
+		"path", path)
+	r, err := openRootFS(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.f.Close()
+	r.limits = l.Limits.layerLimits()
+	m := r.Manifest()
+	ctx = zlog.ContextWithValues(ctx, "manifest", m.Hash.String())
+	zlog.Info(ctx).
+		Stringer("layer", r.digest).
+		Msg("index request start")
+	defer zlog.Info(ctx).Msg("index request done")
+
+	lc, done := l.locker.Lock(ctx, m.Hash.String())
+	defer done()
+	if err := lc.Err(); !errors.Is(err, nil) {
+		return nil, err
+	}
+	opts := *l.indexerOptions
+	opts.FetchArena = r
+	c := l.ControllerFactory(&opts)
+	return c.Index(lc, m)
+}
+
+// RootFS is a flattened root filesystem prepared for indexing.
+//
+// It's the FetchArena used for the synthetic layer.
+type rootFS struct {
+	// F is the tar file.
+	f      *os.File
+	digest claircore.Digest
+	uri    string
+	limits claircore.LayerLimits
+}
+
+// OpenRootFS prepares the directory or tar file at "path" for indexing.
+func openRootFS(ctx context.Context, path string) (*rootFS, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("libindex: bad rootfs path: %w", err)
+	}
+	fi, err := os.Stat(abs)
+	if err != nil {
+		return nil, fmt.Errorf("libindex: unable to open rootfs: %w", err)
+	}
+	r := rootFS{uri: "file://" + filepath.ToSlash(abs)}
+	h := sha256.New()
+	switch {
+	case fi.IsDir():
+		f, err := os.CreateTemp("", "rootfs.*.tar")
+		if err != nil {
+			return nil, fmt.Errorf("libindex: unable to create rootfs tar: %w", err)
+		}
+		if err := os.Remove(f.Name()); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("libindex: unable to unlink rootfs tar: %w", err)
+		}
+		r.f = f
+		if err := writeRootFS(ctx, io.MultiWriter(f, h), abs); err != nil {
+			f.Close()
+			return nil, err
+		}
+	case fi.Mode().IsRegular():
+		f, err := os.Open(abs)
+		if err != nil {
+			return nil, fmt.Errorf("libindex: unable to open rootfs: %w", err)
+		}
+		r.f = f
+		if _, err := io.Copy(h, f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("libindex: unable to read rootfs: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("libindex: rootfs %q is not a directory or regular file", path)
+	}
+	r.digest, err = claircore.NewDigest(claircore.SHA256, h.Sum(nil))
+	if err != nil {
+		r.f.Close()
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Manifest returns a synthetic manifest for the root filesystem.
+func (r *rootFS) Manifest() *claircore.Manifest {
+	sum := sha256.Sum256([]byte("rootfs\n" + r.digest.String()))
+	// NewDigest can't fail given a correctly sized checksum.
+	d, _ := claircore.NewDigest(claircore.SHA256, sum[:])
+	return &claircore.Manifest{
+		Hash: d,
+		Layers: []*claircore.Layer{
+			{Hash: r.digest, URI: r.uri},
+		},
+	}
+}
+
+// Realizer implements [indexer.FetchArena].
+func (r *rootFS) Realizer(_ context.Context) indexer.Realizer {
+	return &rootFSRealizer{r: r}
+}
+
+// Close implements [indexer.FetchArena].
+//
+// The tar file is closed by IndexRootFS.
+func (r *rootFS) Close(_ context.Context) error {
+	return nil
+}
+
+// RootFSRealizer serves the synthetic layer out of a rootFS.
+type rootFSRealizer struct {
+	r      *rootFS
+	layers []claircore.Layer
+}
+
+// Realize implements [indexer.Realizer].
+func (r *rootFSRealizer) Realize(ctx context.Context, ls []*claircore.Layer) error {
+	ds := wart.LayersToDescriptions(ls)
+	ret, err := r.RealizeDescriptions(ctx, ds)
+	if err != nil {
+		return err
+	}
+	wart.CopyLayerPointers(ls, ret)
+	return nil
+}
+
+// RealizeDescriptions implements [indexer.DescriptionRealizer].
+func (r *rootFSRealizer) RealizeDescriptions(ctx context.Context, descs []claircore.LayerDescription) ([]claircore.Layer, error) {
+	out := make([]claircore.Layer, len(descs))
+	for i := range descs {
+		d := descs[i]
+		if d.Digest != r.r.digest.String() {
+			return nil, fmt.Errorf("libindex: unknown layer %q", d.Digest)
+		}
+		// The tar file may be compressed; an empty media type has the Layer
+		// check.
+		d.MediaType = ""
+		if err := out[i].InitWithLimits(ctx, &d, r.r.f, r.r.limits); err != nil {
+			for j := 0; j < i; j++ {
+				out[j].Close()
+			}
+			return nil, err
+		}
+	}
+	r.layers = append(r.layers, out...)
+	return out, nil
+}
+
+// Close implements [indexer.Realizer] and [indexer.DescriptionRealizer].
+func (r *rootFSRealizer) Close() error {
+	errs := make([]error, len(r.layers))
+	for i := range r.layers {
+		errs[i] = r.layers[i].Close()
+	}
+	return errors.Join(errs...)
+}
+
+// WriteRootFS writes the directory tree at "root" to "w" as a tar file.
+func writeRootFS(ctx context.Context, w io.Writer, root string) error {
+	buf := bufio.NewWriter(w)
+	tw := tar.NewWriter(buf)
+	var skipped int
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, relErr := filepath.Rel(root, p)
+		if relErr != nil {
+			return relErr
+		}
+		rel = filepath.ToSlash(rel)
+		if err != nil {
+			if p == root {
+				return err
+			}
+			// Unreadable directories are skipped.
+			zlog.Debug(ctx).Err(err).Str("path", rel).Msg("skipping")
+			skipped++
+			return nil
+		}
+		if rel == "." {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			skipped++
+			return nil
+		}
+		var link string
+		switch {
+		case fi.IsDir():
+		case fi.Mode().IsRegular():
+		case fi.Mode()&fs.ModeSymlink != 0:
+			link, err = os.Readlink(p)
+			if err != nil {
+				skipped++
+				return nil
+			}
+		default:
+			// Devices, sockets, and the like aren't interesting.
+			return nil
+		}
+		h, err := tar.FileInfoHeader(fi, link)
+		if err != nil {
+			return fmt.Errorf("libindex: %q: %w", rel, err)
+		}
+		h.Name = rel
+		if fi.IsDir() {
+			h.Name += "/"
+		}
+
+		if fi.Mode().IsRegular() {
+			// Open the file before writing the header, so unreadable files
+			// can be skipped.
+			f, err := os.Open(p)
+			if err != nil {
+				zlog.Debug(ctx).Err(err).Str("path", rel).Msg("skipping")
+				skipped++
+				return nil
+			}
+			defer f.Close()
+			if err := tw.WriteHeader(h); err != nil {
+				return fmt.Errorf("libindex: %q: %w", rel, err)
+			}
+			// The file may change size while being read. The tar writer
+			// rejects extra bytes, and missing bytes are padded out.
+			n, err := io.Copy(tw, io.LimitReader(f, h.Size))
+			if err != nil {
+				return fmt.Errorf("libindex: %q: %w", rel, err)
+			}
+			if n < h.Size {
+				if _, err := io.CopyN(tw, zeroReader{}, h.Size-n); err != nil {
+					return fmt.Errorf("libindex: %q: %w", rel, err)
+				}
+			}
+			return nil
+		}
+		if err := tw.WriteHeader(h); err != nil {
+			return fmt.Errorf("libindex: %q: %w", rel, err)
+		}
+		if fi.IsDir() {
+			switch rel {
+			case "proc", "sys", "dev":
+				// Pseudo-filesystems on a live system.
+				return fs.SkipDir
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("libindex: unable to write rootfs tar: %w", err)
+	}
+	if skipped != 0 {
+		zlog.Info(ctx).
+			Int("count", skipped).
+			Msg("skipped unreadable files")
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("libindex: unable to write rootfs tar: %w", err)
+	}
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("libindex: unable to write rootfs tar: %w", err)
+	}
+	return nil
+}
+
+// ZeroReader is an infinite source of zero bytes.
+type zeroReader struct{}
+
+func (zeroReader) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = 0
+	}
+	return len(b), nil
+}
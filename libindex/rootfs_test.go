@@ -0,0 +1,160 @@
+package libindex
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+)
+
+func TestOpenRootFS(t *testing.T) {
+	ctx := zlog.Test(context.Background(), t)
+	const osRelease = "ID=test\nVERSION_ID=1\n"
+
+	check := func(t *testing.T, r *rootFS) {
+		t.Helper()
+		m := r.Manifest()
+		if len(m.Layers) != 1 || m.Layers[0].Hash.String() != r.digest.String() {
+			t.Fatalf("bad manifest: %+v", m)
+		}
+		if m.Hash.String() == r.digest.String() {
+			t.Error("manifest digest same as layer digest")
+		}
+		rz := r.Realizer(ctx).(*rootFSRealizer)
+		defer rz.Close()
+		ls, err := rz.RealizeDescriptions(ctx, []claircore.LayerDescription{{
+			Digest:    r.digest.String(),
+			URI:       r.uri,
+			MediaType: `application/vnd.oci.image.layer.v1.tar`,
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sys, err := ls[0].FS()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := fs.ReadFile(sys, "etc/os-release")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(b), osRelease; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+
+	t.Run("Dir", func(t *testing.T) {
+		root := t.TempDir()
+		for _, d := range []string{"usr/lib", "etc", "proc/1"} {
+			if err := os.MkdirAll(filepath.Join(root, d), 0o755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.WriteFile(filepath.Join(root, "usr/lib/os-release"), []byte(osRelease), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, "proc/1/status"), []byte("State: R\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink("../usr/lib/os-release", filepath.Join(root, "etc/os-release")); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := openRootFS(ctx, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.f.Close()
+		check(t, r)
+
+		sys, err := tarFS(t, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fs.Stat(sys, "proc"); err != nil {
+			t.Errorf("missing proc: %v", err)
+		}
+		if _, err := fs.Stat(sys, "proc/1/status"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("proc contents included: %v", err)
+		}
+
+		// The digest should be stable.
+		again, err := openRootFS(ctx, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer again.f.Close()
+		if got, want := again.digest.String(), r.digest.String(); got != want {
+			t.Errorf("got digest %s, want %s", got, want)
+		}
+	})
+
+	t.Run("Tarball", func(t *testing.T) {
+		name := filepath.Join(t.TempDir(), "rootfs.tar.gz")
+		f, err := os.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zw := gzip.NewWriter(f)
+		tw := tar.NewWriter(zw)
+		for _, h := range []*tar.Header{
+			{Name: "etc/", Typeflag: tar.TypeDir, Mode: 0o755},
+			{Name: "etc/os-release", Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(osRelease))},
+		} {
+			if err := tw.WriteHeader(h); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := tw.Write([]byte(osRelease)); err != nil {
+			t.Fatal(err)
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := openRootFS(ctx, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.f.Close()
+		b, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := r.digest.String(), fmt.Sprintf("sha256:%x", sha256.Sum256(b)); got != want {
+			t.Errorf("got digest %s, want %s", got, want)
+		}
+		check(t, r)
+	})
+}
+
+// TarFS realizes the rootFS's layer and returns its filesystem.
+func tarFS(t *testing.T, r *rootFS) (fs.FS, error) {
+	ctx := zlog.Test(context.Background(), t)
+	rz := r.Realizer(ctx).(*rootFSRealizer)
+	t.Cleanup(func() { rz.Close() })
+	ls, err := rz.RealizeDescriptions(ctx, []claircore.LayerDescription{{
+		Digest: r.digest.String(),
+		URI:    r.uri,
+	}})
+	if err != nil {
+		return nil, err
+	}
+	return ls[0].FS()
+}
@@ -0,0 +1,299 @@
+package libvuln
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/datastore"
+	"github.com/quay/claircore/libvuln/bundle"
+	"github.com/quay/claircore/libvuln/driver"
+)
+
+// RepositoryMappingFile is the name of the bundle file holding the RHEL
+// repository-to-CPE mapping. See WithRepositoryMapping.
+const RepositoryMappingFile = `rhel/repository-to-cpe.json`
+
+// ExportOption configures a call to Export.
+type ExportOption func(*exportConfig)
+
+type exportConfig struct {
+	mapping io.Reader
+}
+
+// WithRepositoryMapping includes the RHEL repository-to-CPE mapping read from
+// "r" in the bundle, as RepositoryMappingFile.
+//
+// The mapping is used by indexers rather than the matcher, so it's not in the
+// store: it can be written by
+// [github.com/quay/claircore/rhel.RepositoryScanner.WriteMapping] on a
+// connected indexer.
+func WithRepositoryMapping(r io.Reader) ExportOption {
+	return func(c *exportConfig) {
+		c.mapping = r
+	}
+}
+
+// ImportOption configures a call to Import.
+type ImportOption func(*importConfig)
+
+type importConfig struct {
+	mappingPath string
+}
+
+// WithRepositoryMappingFile writes the bundle's RHEL repository-to-CPE
+// mapping, if it has one, to the file "p". The file is suitable as the
+// Repo2CPEMappingFile of an offline
+// [github.com/quay/claircore/rhel.RepositoryScanner].
+func WithRepositoryMappingFile(p string) ImportOption {
+	return func(c *importConfig) {
+		c.mappingPath = p
+	}
+}
+
+// Export writes the latest vulnerability and enrichment update operations of
+// every updater in the store to "w", in the format described in the [bundle]
+// package.
+//
+// Enrichments are only exported if the store implements
+// [datastore.EnrichmentExporter].
+func (l *Libvuln) Export(ctx context.Context, w io.Writer, opts ...ExportOption) error {
+	ctx = zlog.ContextWithValues(ctx, "component", "libvuln/Libvuln.Export")
+	var cfg exportConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+	bw := bundle.NewWriter(w)
+	if err := l.export(ctx, bw, &cfg); err != nil {
+		bw.Close()
+		return err
+	}
+	return bw.Close()
+}
+
+// Export adds everything to be exported to "bw".
+func (l *Libvuln) export(ctx context.Context, bw *bundle.Writer, cfg *exportConfig) error {
+	ops, err := l.latestOps(ctx, driver.VulnerabilityKind)
+	if err != nil {
+		return err
+	}
+	for i := range ops {
+		op := &ops[i]
+		// Diffing against nothing reports every vulnerability in the
+		// operation as added.
+		diff, err := l.store.GetUpdateDiff(ctx, uuid.Nil, op.Ref)
+		if err != nil {
+			return fmt.Errorf("libvuln: unable to read update %v: %w", op.Ref, err)
+		}
+		vs := make([]*claircore.Vulnerability, len(diff.Added))
+		for i := range diff.Added {
+			vs[i] = &diff.Added[i]
+		}
+		if err := bw.Add(op, vs); err != nil {
+			return err
+		}
+		zlog.Debug(ctx).
+			Str("updater", op.Updater).
+			Int("count", len(vs)).
+			Msg("exported update")
+	}
+
+	if ee, ok := l.store.(datastore.EnrichmentExporter); ok {
+		ops, err := l.latestOps(ctx, driver.EnrichmentKind)
+		if err != nil {
+			return err
+		}
+		for i := range ops {
+			op := &ops[i]
+			recs, err := ee.GetEnrichmentRecords(ctx, op.Ref)
+			if err != nil {
+				return fmt.Errorf("libvuln: unable to read update %v: %w", op.Ref, err)
+			}
+			if err := bw.AddEnrichments(op, recs); err != nil {
+				return err
+			}
+			zlog.Debug(ctx).
+				Str("updater", op.Updater).
+				Int("count", len(recs)).
+				Msg("exported enrichments")
+		}
+	} else {
+		zlog.Warn(ctx).Msg("store unable to export enrichments, skipping")
+	}
+
+	if cfg.mapping != nil {
+		if err := bw.AddFile(RepositoryMappingFile, cfg.mapping); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LatestOps returns the latest update operation of the kind "k" for every
+// updater, sorted by updater name.
+func (l *Libvuln) latestOps(ctx context.Context, k driver.UpdateKind) ([]driver.UpdateOperation, error) {
+	latest, err := l.store.GetLatestUpdateRefs(ctx, k)
+	if err != nil {
+		return nil, fmt.Errorf("libvuln: unable to get update operations: %w", err)
+	}
+	ops := make([]driver.UpdateOperation, 0, len(latest))
+	for _, us := range latest {
+		if len(us) != 0 {
+			ops = append(ops, us[0])
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i].Updater < ops[j].Updater })
+	return ops, nil
+}
+
+// Import reads a bundle written by Export from "r" and imports its update
+// operations into the store.
+//
+// The whole bundle is verified before anything is imported. Update
+// operations with the same fingerprint as the updater's latest operation of
+// the same kind in the store are skipped.
+//
+// Import is all or nothing: if any update operation can't be imported, the
+// ones already imported are deleted, making the previous operations the
+// latest again. Matching done while Import runs may see some updaters'
+// imported operations and not others.
+func (l *Libvuln) Import(ctx context.Context, r io.Reader, opts ...ImportOption) (err error) {
+	ctx = zlog.ContextWithValues(ctx, "component", "libvuln/Libvuln.Import")
+	var cfg importConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+	b, err := bundle.Open(ctx, r)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	// The mapping file is staged before the store is changed and moved into
+	// place last, so failing to write it leaves everything as it was.
+	var staged string
+	if cfg.mappingPath != "" {
+		staged, err = stageFile(b, RepositoryMappingFile, cfg.mappingPath)
+		switch {
+		case errors.Is(err, bundle.ErrNoFile):
+			zlog.Info(ctx).Msg("bundle has no repository mapping")
+		case err != nil:
+			return err
+		default:
+			defer os.Remove(staged)
+		}
+	}
+
+	latest := make(map[driver.UpdateKind]map[string][]driver.UpdateOperation)
+	for _, k := range []driver.UpdateKind{driver.VulnerabilityKind, driver.EnrichmentKind} {
+		latest[k], err = l.store.GetLatestUpdateRefs(ctx, k)
+		if err != nil {
+			return fmt.Errorf("libvuln: unable to get update operations: %w", err)
+		}
+	}
+	var created []uuid.UUID
+	defer func() {
+		if err == nil || len(created) == 0 {
+			return
+		}
+		// Use a fresh Context, so that a cancelled import is still rolled
+		// back.
+		dctx, done := context.WithTimeout(context.Background(), time.Minute)
+		defer done()
+		if _, derr := l.store.DeleteUpdateOperations(dctx, created...); derr != nil {
+			err = errors.Join(err, fmt.Errorf("libvuln: unable to roll back import: %w", derr))
+			return
+		}
+		zlog.Info(ctx).
+			Int("count", len(created)).
+			Msg("rolled back imported updates")
+	}()
+	for i := range b.Manifest.Entries {
+		e := &b.Manifest.Entries[i]
+		if cur := latest[e.Kind][e.Updater]; len(cur) != 0 && cur[0].Fingerprint == e.Fingerprint {
+			zlog.Info(ctx).
+				Str("updater", e.Updater).
+				Str("kind", string(e.Kind)).
+				Msg("fingerprint match, skipping")
+			continue
+		}
+		ref, err := l.importEntry(ctx, b, e)
+		if err != nil {
+			return err
+		}
+		created = append(created, ref)
+		zlog.Info(ctx).
+			Str("updater", e.Updater).
+			Str("kind", string(e.Kind)).
+			Str("ref", ref.String()).
+			Int("count", e.Count).
+			Msg("update imported")
+	}
+	if staged != "" {
+		if err := os.Rename(staged, cfg.mappingPath); err != nil {
+			return fmt.Errorf("libvuln: unable to write repository mapping: %w", err)
+		}
+	}
+	return nil
+}
+
+// ImportEntry adds the update operation for "e" to the store.
+func (l *Libvuln) importEntry(ctx context.Context, b *bundle.Bundle, e *bundle.Entry) (uuid.UUID, error) {
+	var ref uuid.UUID
+	switch e.Kind {
+	case driver.VulnerabilityKind:
+		vs, err := b.Vulnerabilities(e)
+		if err != nil {
+			return uuid.Nil, err
+		}
+		ref, err = l.store.UpdateVulnerabilities(ctx, e.Updater, e.Fingerprint, vs)
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("libvuln: unable to import update for %q: %w", e.Updater, err)
+		}
+	case driver.EnrichmentKind:
+		recs, err := b.Enrichments(e)
+		if err != nil {
+			return uuid.Nil, err
+		}
+		ref, err = l.store.UpdateEnrichments(ctx, e.Updater, e.Fingerprint, recs)
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("libvuln: unable to import enrichments for %q: %w", e.Updater, err)
+		}
+	default:
+		// Checked by bundle.Open.
+		panic(fmt.Sprintf("programmer error: unknown kind %q", e.Kind))
+	}
+	return ref, nil
+}
+
+// StageFile copies the bundle file "name" to a temporary file next to "dst",
+// returning its path.
+func stageFile(b *bundle.Bundle, name, dst string) (string, error) {
+	r, err := b.File(name)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".*")
+	if err != nil {
+		return "", fmt.Errorf("libvuln: unable to stage %q: %w", name, err)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", fmt.Errorf("libvuln: unable to stage %q: %w", name, err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("libvuln: unable to stage %q: %w", name, err)
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,512 @@
+// Package bundle implements a versioned, verifiable format for moving
+// vulnerability updates to systems without network access.
+//
+// A bundle is a zstd-compressed tar archive. The first member is
+// "manifest.json", a [Manifest] recording the schema version and, for each
+// update operation, the kind, updater, fingerprint, number of records, and
+// SHA-256 checksum of the member holding them. The members for update
+// operations hold the vulnerabilities or enrichment records as a stream of
+// JSON objects.
+//
+// A bundle may also carry other files needed offline, like the RHEL
+// repository-to-CPE mapping. These are recorded in the manifest by name and
+// checksum.
+//
+// Bundles are written with a [Writer] and read with [Open], which verifies
+// the whole bundle before returning, so a truncated or corrupted bundle is
+// rejected before anything is imported.
+package bundle
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/libvuln/driver"
+	"github.com/quay/claircore/pkg/tmp"
+)
+
+// SchemaVersion is the version of the bundle format this package writes.
+const SchemaVersion = 1
+
+// ManifestName is the name of the manifest member.
+const manifestName = `manifest.json`
+
+// Manifest describes the contents of a bundle.
+type Manifest struct {
+	// SchemaVersion is the version of the bundle format.
+	SchemaVersion int `json:"schema_version"`
+	// Created is when the bundle was written.
+	Created time.Time `json:"created"`
+	// Entries are the update operations in the bundle, in the order they
+	// were added.
+	Entries []Entry `json:"entries"`
+	// Files are the other files in the bundle, in the order they were
+	// added.
+	Files []File `json:"files,omitempty"`
+}
+
+// Entry describes one update operation in a bundle.
+type Entry struct {
+	// Kind is the kind of the update operation, which determines the type of
+	// its records.
+	Kind driver.UpdateKind `json:"kind"`
+	// Updater is the name of the updater.
+	Updater string `json:"updater"`
+	// Fingerprint is the updater's fingerprint for the update.
+	Fingerprint driver.Fingerprint `json:"fingerprint"`
+	// Date is when the update operation happened.
+	Date time.Time `json:"date"`
+	// Path is the name of the member holding the records.
+	Path string `json:"path"`
+	// Count is the number of records.
+	Count int `json:"count"`
+	// SHA256 is the hex-encoded SHA-256 checksum of the member.
+	SHA256 string `json:"sha256"`
+}
+
+// File describes a file in a bundle.
+type File struct {
+	// Name is the name the file was added with.
+	Name string `json:"name"`
+	// Path is the name of the member holding the file.
+	Path string `json:"path"`
+	// SHA256 is the hex-encoded SHA-256 checksum of the member.
+	SHA256 string `json:"sha256"`
+}
+
+// Writer writes a bundle.
+//
+// Records and files are buffered in temporary files until Close is called.
+type Writer struct {
+	w       io.Writer
+	m       Manifest
+	members []member
+}
+
+// Member is a buffered bundle member.
+type member struct {
+	path string
+	f    *tmp.File
+}
+
+// NewWriter returns a Writer writing a bundle to "w".
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		w: w,
+		m: Manifest{
+			SchemaVersion: SchemaVersion,
+			Created:       time.Now().UTC(),
+		},
+	}
+}
+
+// Add adds the vulnerabilities from an update operation to the bundle.
+func (w *Writer) Add(op *driver.UpdateOperation, vulns []*claircore.Vulnerability) error {
+	return w.addEntry(driver.VulnerabilityKind, op, len(vulns), func(enc *json.Encoder) error {
+		for _, v := range vulns {
+			if err := enc.Encode(v); err != nil {
+				return fmt.Errorf("bundle: unable to encode vulnerability: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// AddEnrichments adds the records from an enrichment update operation to the
+// bundle.
+func (w *Writer) AddEnrichments(op *driver.UpdateOperation, recs []driver.EnrichmentRecord) error {
+	return w.addEntry(driver.EnrichmentKind, op, len(recs), func(enc *json.Encoder) error {
+		for i := range recs {
+			if err := enc.Encode(&recs[i]); err != nil {
+				return fmt.Errorf("bundle: unable to encode enrichment: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// AddEntry buffers a member written by "f" and records it in the manifest.
+func (w *Writer) addEntry(k driver.UpdateKind, op *driver.UpdateOperation, ct int, f func(*json.Encoder) error) error {
+	p := fmt.Sprintf("updates/%04d.json", len(w.m.Entries))
+	sum, err := w.buffer(p, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		return f(enc)
+	})
+	if err != nil {
+		return err
+	}
+	w.m.Entries = append(w.m.Entries, Entry{
+		Kind:        k,
+		Updater:     op.Updater,
+		Fingerprint: op.Fingerprint,
+		Date:        op.Date,
+		Path:        p,
+		Count:       ct,
+		SHA256:      sum,
+	})
+	return nil
+}
+
+// AddFile adds the contents of "r" to the bundle as the file "name", which
+// can be retrieved with [Bundle.File]. Names are slash-separated, like
+// "rhel/repository-to-cpe.json".
+func (w *Writer) AddFile(name string, r io.Reader) error {
+	if !validName(name) {
+		return fmt.Errorf("bundle: bad file name %q", name)
+	}
+	for _, f := range w.m.Files {
+		if f.Name == name {
+			return fmt.Errorf("bundle: duplicate file %q", name)
+		}
+	}
+	p := "files/" + name
+	sum, err := w.buffer(p, func(w io.Writer) error {
+		if _, err := io.Copy(w, r); err != nil {
+			return fmt.Errorf("bundle: unable to read %q: %w", name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	w.m.Files = append(w.m.Files, File{Name: name, Path: p, SHA256: sum})
+	return nil
+}
+
+// ValidName reports whether "n" is usable as a file name.
+func validName(n string) bool {
+	return n != "" && fs.ValidPath(n) && n != "."
+}
+
+// Buffer writes a member to a temporary file with "f", returning its
+// checksum.
+func (w *Writer) buffer(p string, f func(io.Writer) error) (string, error) {
+	tf, err := tmp.NewFile("", "bundle.*")
+	if err != nil {
+		return "", fmt.Errorf("bundle: unable to create buffer: %w", err)
+	}
+	w.members = append(w.members, member{path: p, f: tf})
+	h := sha256.New()
+	buf := bufio.NewWriter(io.MultiWriter(tf, h))
+	if err := f(buf); err != nil {
+		return "", err
+	}
+	if err := buf.Flush(); err != nil {
+		return "", fmt.Errorf("bundle: unable to write buffer: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Close writes the bundle and removes the temporary files. It does not close
+// the underlying io.Writer.
+func (w *Writer) Close() (err error) {
+	defer func() {
+		for _, m := range w.members {
+			if cerr := m.f.Close(); err == nil && cerr != nil {
+				err = fmt.Errorf("bundle: unable to remove buffer: %w", cerr)
+			}
+		}
+		w.members = nil
+	}()
+	z, err := zstd.NewWriter(w.w)
+	if err != nil {
+		return fmt.Errorf("bundle: unable to create zstd writer: %w", err)
+	}
+	tw := tar.NewWriter(z)
+	m, err := json.Marshal(&w.m)
+	if err != nil {
+		return fmt.Errorf("bundle: unable to encode manifest: %w", err)
+	}
+	hdr := tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     manifestName,
+		Size:     int64(len(m)),
+		Mode:     0o644,
+		ModTime:  w.m.Created,
+	}
+	if err := tw.WriteHeader(&hdr); err != nil {
+		return fmt.Errorf("bundle: unable to write manifest: %w", err)
+	}
+	if _, err := tw.Write(m); err != nil {
+		return fmt.Errorf("bundle: unable to write manifest: %w", err)
+	}
+	for _, m := range w.members {
+		f := m.f
+		fi, err := f.Stat()
+		if err != nil {
+			return fmt.Errorf("bundle: unable to stat buffer: %w", err)
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("bundle: unable to seek buffer: %w", err)
+		}
+		hdr := tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     m.path,
+			Size:     fi.Size(),
+			Mode:     0o644,
+			ModTime:  w.m.Created,
+		}
+		if err := tw.WriteHeader(&hdr); err != nil {
+			return fmt.Errorf("bundle: unable to write %q: %w", m.path, err)
+		}
+		if _, err := io.Copy(tw, f); err != nil {
+			return fmt.Errorf("bundle: unable to write %q: %w", m.path, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("bundle: unable to finish archive: %w", err)
+	}
+	if err := z.Close(); err != nil {
+		return fmt.Errorf("bundle: unable to finish compression: %w", err)
+	}
+	return nil
+}
+
+// ErrInvalid is returned (wrapped) by Open for bundles that are malformed or
+// don't match their manifest.
+var ErrInvalid = errors.New("bundle: invalid bundle")
+
+// Bundle is a verified bundle, read by Open.
+type Bundle struct {
+	// Manifest is the bundle's manifest.
+	Manifest Manifest
+	files    map[string]*tmp.File
+}
+
+// Open reads and verifies the bundle in "r".
+//
+// Every member's checksum, and every update operation's record count, is
+// checked against the manifest before Open returns. The contents are
+// buffered in temporary files, which are removed by Close.
+func Open(ctx context.Context, r io.Reader) (_ *Bundle, err error) {
+	ctx = zlog.ContextWithValues(ctx, "component", "libvuln/bundle/Open")
+	z, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("bundle: unable to create zstd reader: %w", err)
+	}
+	defer z.Close()
+	tr := tar.NewReader(z)
+
+	hdr, err := tr.Next()
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("%w: unable to read manifest: %v", ErrInvalid, err)
+	case hdr.Name != manifestName:
+		return nil, fmt.Errorf("%w: unexpected first member %q", ErrInvalid, hdr.Name)
+	}
+	b := Bundle{
+		files: make(map[string]*tmp.File),
+	}
+	defer func() {
+		if err != nil {
+			b.Close()
+		}
+	}()
+	if err := json.NewDecoder(tr).Decode(&b.Manifest); err != nil {
+		return nil, fmt.Errorf("%w: unable to decode manifest: %v", ErrInvalid, err)
+	}
+	if v := b.Manifest.SchemaVersion; v != SchemaVersion {
+		return nil, fmt.Errorf("bundle: unsupported schema version %d", v)
+	}
+	// Sums is the expected checksum of every member, and counts is the
+	// expected number of records in members holding update operations.
+	sums := make(map[string]string, len(b.Manifest.Entries)+len(b.Manifest.Files))
+	counts := make(map[string]int, len(b.Manifest.Entries))
+	for i := range b.Manifest.Entries {
+		e := &b.Manifest.Entries[i]
+		switch e.Kind {
+		case driver.VulnerabilityKind, driver.EnrichmentKind:
+		default:
+			return nil, fmt.Errorf("%w: unknown kind %q", ErrInvalid, e.Kind)
+		}
+		if _, ok := sums[e.Path]; ok {
+			return nil, fmt.Errorf("%w: duplicate path %q", ErrInvalid, e.Path)
+		}
+		sums[e.Path] = e.SHA256
+		counts[e.Path] = e.Count
+	}
+	names := make(map[string]struct{}, len(b.Manifest.Files))
+	for _, f := range b.Manifest.Files {
+		if _, ok := names[f.Name]; ok || !validName(f.Name) {
+			return nil, fmt.Errorf("%w: bad file name %q", ErrInvalid, f.Name)
+		}
+		names[f.Name] = struct{}{}
+		if _, ok := sums[f.Path]; ok {
+			return nil, fmt.Errorf("%w: duplicate path %q", ErrInvalid, f.Path)
+		}
+		sums[f.Path] = f.SHA256
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to read archive: %v", ErrInvalid, err)
+		}
+		want, ok := sums[hdr.Name]
+		if !ok {
+			zlog.Debug(ctx).Str("name", hdr.Name).Msg("skipping unknown member")
+			continue
+		}
+		if _, ok := b.files[hdr.Name]; ok {
+			return nil, fmt.Errorf("%w: duplicate member %q", ErrInvalid, hdr.Name)
+		}
+		f, err := tmp.NewFile("", "bundle.*")
+		if err != nil {
+			return nil, fmt.Errorf("bundle: unable to create buffer: %w", err)
+		}
+		b.files[hdr.Name] = f
+		h := sha256.New()
+		if _, err := io.Copy(io.MultiWriter(f, h), tr); err != nil {
+			return nil, fmt.Errorf("%w: unable to read %q: %v", ErrInvalid, hdr.Name, err)
+		}
+		if got := hex.EncodeToString(h.Sum(nil)); got != want {
+			return nil, fmt.Errorf("%w: checksum mismatch for %q: got %s, want %s", ErrInvalid, hdr.Name, got, want)
+		}
+		ct, ok := counts[hdr.Name]
+		if !ok {
+			continue
+		}
+		n, err := count(f)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unable to decode %q: %v", ErrInvalid, hdr.Name, err)
+		}
+		if n != ct {
+			return nil, fmt.Errorf("%w: %q has %d records, want %d", ErrInvalid, hdr.Name, n, ct)
+		}
+	}
+	for p := range sums {
+		if _, ok := b.files[p]; !ok {
+			return nil, fmt.Errorf("%w: missing member %q", ErrInvalid, p)
+		}
+	}
+	return &b, nil
+}
+
+// Count reports the number of JSON objects in the buffer "f".
+func count(f *tmp.File) (int, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	dec := json.NewDecoder(bufio.NewReader(f))
+	n := 0
+	for {
+		var v json.RawMessage
+		err := dec.Decode(&v)
+		switch {
+		case errors.Is(err, io.EOF):
+			return n, nil
+		case err != nil:
+			return n, err
+		}
+		n++
+	}
+}
+
+// Member returns the buffer for the entry "e", rewound to the start.
+func (b *Bundle) member(e *Entry, k driver.UpdateKind) (*tmp.File, error) {
+	if e.Kind != k {
+		return nil, fmt.Errorf("bundle: entry %q is not of kind %q", e.Path, k)
+	}
+	f, ok := b.files[e.Path]
+	if !ok {
+		return nil, fmt.Errorf("bundle: unknown entry %q", e.Path)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("bundle: unable to seek buffer: %w", err)
+	}
+	return f, nil
+}
+
+// Vulnerabilities returns the vulnerabilities for the entry "e", which must
+// be a vulnerability entry from the bundle's Manifest.
+func (b *Bundle) Vulnerabilities(e *Entry) ([]*claircore.Vulnerability, error) {
+	f, err := b.member(e, driver.VulnerabilityKind)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bufio.NewReader(f))
+	vs := make([]*claircore.Vulnerability, 0, e.Count)
+	for {
+		var v claircore.Vulnerability
+		err := dec.Decode(&v)
+		switch {
+		case errors.Is(err, io.EOF):
+			return vs, nil
+		case err != nil:
+			return nil, fmt.Errorf("bundle: unable to decode vulnerability: %w", err)
+		}
+		vs = append(vs, &v)
+	}
+}
+
+// Enrichments returns the records for the entry "e", which must be an
+// enrichment entry from the bundle's Manifest.
+func (b *Bundle) Enrichments(e *Entry) ([]driver.EnrichmentRecord, error) {
+	f, err := b.member(e, driver.EnrichmentKind)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bufio.NewReader(f))
+	rs := make([]driver.EnrichmentRecord, 0, e.Count)
+	for {
+		var r driver.EnrichmentRecord
+		err := dec.Decode(&r)
+		switch {
+		case errors.Is(err, io.EOF):
+			return rs, nil
+		case err != nil:
+			return nil, fmt.Errorf("bundle: unable to decode enrichment: %w", err)
+		}
+		rs = append(rs, r)
+	}
+}
+
+// ErrNoFile is returned (wrapped) by File when the bundle doesn't have the
+// named file.
+var ErrNoFile = errors.New("bundle: no such file")
+
+// File returns the contents of the file "name", as added with
+// [Writer.AddFile]. The returned Reader is only valid until the next call to
+// File with the same name, or Close.
+func (b *Bundle) File(name string) (io.Reader, error) {
+	for _, f := range b.Manifest.Files {
+		if f.Name != name {
+			continue
+		}
+		tf := b.files[f.Path]
+		if _, err := tf.Seek(0, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("bundle: unable to seek buffer: %w", err)
+		}
+		return bufio.NewReader(tf), nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrNoFile, name)
+}
+
+// Close removes the temporary files backing the Bundle.
+func (b *Bundle) Close() error {
+	var errs []error
+	for p, f := range b.files {
+		if err := f.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		delete(b.files, p)
+	}
+	return errors.Join(errs...)
+}
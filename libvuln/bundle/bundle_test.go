@@ -0,0 +1,256 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/libvuln/driver"
+)
+
+func testVulns() map[string][]*claircore.Vulnerability {
+	return map[string][]*claircore.Vulnerability{
+		"alpine": {
+			{Name: "CVE-2023-0001", Updater: "alpine", FixedInVersion: "1.2.3-r1"},
+			{Name: "CVE-2023-0002", Updater: "alpine", Links: "https://example.com/?a=1&b=2"},
+		},
+		"debian": {},
+	}
+}
+
+func testEnrichments() []driver.EnrichmentRecord {
+	return []driver.EnrichmentRecord{
+		{Tags: []string{"CVE-2023-0001"}, Enrichment: []byte(`{"score":0.5}`)},
+	}
+}
+
+const testMapping = `{"data":{"rhel-8-for-x86_64-baseos-rpms":{"cpes":["cpe:/o:redhat:enterprise_linux:8::baseos"]}}}`
+
+func writeBundle(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, name := range []string{"alpine", "debian"} {
+		op := driver.UpdateOperation{
+			Updater:     name,
+			Fingerprint: driver.Fingerprint(name + "-fp"),
+			Date:        time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		}
+		if err := w.Add(&op, testVulns()[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	op := driver.UpdateOperation{
+		Updater:     "clair.epss",
+		Fingerprint: driver.Fingerprint("clair.epss-fp"),
+		Date:        time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if err := w.AddEnrichments(&op, testEnrichments()); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddFile("rhel/repository-to-cpe.json", strings.NewReader(testMapping)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddFile("rhel/repository-to-cpe.json", strings.NewReader(testMapping)); err == nil {
+		t.Error("expected error for duplicate file")
+	}
+	if err := w.AddFile("../escape", strings.NewReader(testMapping)); err == nil {
+		t.Error("expected error for bad file name")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRoundtrip(t *testing.T) {
+	ctx := context.Background()
+	b, err := Open(ctx, bytes.NewReader(writeBundle(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if got, want := b.Manifest.SchemaVersion, SchemaVersion; got != want {
+		t.Errorf("got schema version %d, want %d", got, want)
+	}
+	want := testVulns()
+	if got := len(b.Manifest.Entries); got != len(want)+1 {
+		t.Fatalf("got %d entries, want %d", got, len(want)+1)
+	}
+	for i := range b.Manifest.Entries {
+		e := &b.Manifest.Entries[i]
+		if got, want := string(e.Fingerprint), e.Updater+"-fp"; got != want {
+			t.Errorf("got fingerprint %q, want %q", got, want)
+		}
+		if e.Kind == driver.EnrichmentKind {
+			got, err := b.Enrichments(e)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := testEnrichments(); !cmp.Equal(got, want) {
+				t.Error(cmp.Diff(got, want))
+			}
+			if _, err := b.Vulnerabilities(e); err == nil {
+				t.Error("expected error for wrong kind")
+			}
+			continue
+		}
+		got, err := b.Vulnerabilities(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cmp.Equal(got, want[e.Updater]) {
+			t.Error(cmp.Diff(got, want[e.Updater]))
+		}
+	}
+
+	r, err := b.File("rhel/repository-to-cpe.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(m), testMapping; got != want {
+		t.Errorf("got file %q, want %q", got, want)
+	}
+	if _, err := b.File("nope"); !errors.Is(err, ErrNoFile) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// Rewrite decompresses the bundle "in", passes each member to "f", and
+// recompresses the result. Members "f" returns nil for are dropped.
+func rewrite(t *testing.T, in []byte, f func(*tar.Header, []byte) []byte) []byte {
+	t.Helper()
+	z, err := zstd.NewReader(bytes.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer z.Close()
+	var out bytes.Buffer
+	zw, err := zstd.NewWriter(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, tw := tar.NewReader(z), tar.NewWriter(zw)
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b = f(h, b)
+		if b == nil {
+			continue
+		}
+		h.Size = int64(len(b))
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func TestInvalid(t *testing.T) {
+	ctx := context.Background()
+	good := writeBundle(t)
+	tt := []struct {
+		Name    string
+		In      []byte
+		Invalid bool
+	}{
+		{
+			Name:    "Truncated",
+			In:      good[:len(good)/2],
+			Invalid: true,
+		},
+		{
+			Name: "Checksum",
+			In: rewrite(t, good, func(h *tar.Header, b []byte) []byte {
+				if h.Name == manifestName {
+					return b
+				}
+				return bytes.ReplaceAll(b, []byte("CVE-2023-0001"), []byte("CVE-2023-9999"))
+			}),
+			Invalid: true,
+		},
+		{
+			Name: "Missing",
+			In: rewrite(t, good, func(h *tar.Header, b []byte) []byte {
+				if h.Name == "updates/0001.json" {
+					return nil
+				}
+				return b
+			}),
+			Invalid: true,
+		},
+		{
+			Name: "FileChecksum",
+			In: rewrite(t, good, func(h *tar.Header, b []byte) []byte {
+				if h.Name == "files/rhel/repository-to-cpe.json" {
+					return bytes.ReplaceAll(b, []byte("baseos"), []byte("BaseOS"))
+				}
+				return b
+			}),
+			Invalid: true,
+		},
+		{
+			Name: "Kind",
+			In: rewrite(t, good, func(h *tar.Header, b []byte) []byte {
+				if h.Name == manifestName {
+					return bytes.Replace(b, []byte(`"kind":"enrichment"`), []byte(`"kind":"other"`), 1)
+				}
+				return b
+			}),
+			Invalid: true,
+		},
+		{
+			Name: "Version",
+			In: rewrite(t, good, func(h *tar.Header, b []byte) []byte {
+				if h.Name == manifestName {
+					return bytes.Replace(b, []byte(`"schema_version":1`), []byte(`"schema_version":2`), 1)
+				}
+				return b
+			}),
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			b, err := Open(ctx, bytes.NewReader(tc.In))
+			if err == nil {
+				b.Close()
+				t.Fatal("expected error")
+			}
+			t.Log(err)
+			if got := errors.Is(err, ErrInvalid); got != tc.Invalid {
+				t.Errorf("got errors.Is(err, ErrInvalid) = %v, want %v", got, tc.Invalid)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package libvuln
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/uuid"
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/datastore"
+	"github.com/quay/claircore/libvuln/driver"
+)
+
+// BundleStore keeps update operations in memory. Updates for the updater
+// named by "fail" return an error.
+type bundleStore struct {
+	datastore.MatcherStore
+	ops         map[driver.UpdateKind]map[string][]driver.UpdateOperation
+	vulns       map[uuid.UUID][]*claircore.Vulnerability
+	enrichments map[uuid.UUID][]driver.EnrichmentRecord
+	fail        string
+}
+
+func newBundleStore() *bundleStore {
+	return &bundleStore{
+		ops: map[driver.UpdateKind]map[string][]driver.UpdateOperation{
+			driver.VulnerabilityKind: {},
+			driver.EnrichmentKind:    {},
+		},
+		vulns:       make(map[uuid.UUID][]*claircore.Vulnerability),
+		enrichments: make(map[uuid.UUID][]driver.EnrichmentRecord),
+	}
+}
+
+func (s *bundleStore) add(k driver.UpdateKind, name string, fp driver.Fingerprint) (uuid.UUID, error) {
+	if name == s.fail {
+		return uuid.Nil, errors.New("update failed")
+	}
+	op := driver.UpdateOperation{Ref: uuid.New(), Updater: name, Fingerprint: fp, Kind: k}
+	s.ops[k][name] = append([]driver.UpdateOperation{op}, s.ops[k][name]...)
+	return op.Ref, nil
+}
+
+func (s *bundleStore) UpdateVulnerabilities(_ context.Context, name string, fp driver.Fingerprint, vs []*claircore.Vulnerability) (uuid.UUID, error) {
+	ref, err := s.add(driver.VulnerabilityKind, name, fp)
+	if err == nil {
+		s.vulns[ref] = vs
+	}
+	return ref, err
+}
+
+func (s *bundleStore) UpdateEnrichments(_ context.Context, name string, fp driver.Fingerprint, rs []driver.EnrichmentRecord) (uuid.UUID, error) {
+	ref, err := s.add(driver.EnrichmentKind, name, fp)
+	if err == nil {
+		s.enrichments[ref] = rs
+	}
+	return ref, err
+}
+
+func (s *bundleStore) GetLatestUpdateRefs(_ context.Context, k driver.UpdateKind) (map[string][]driver.UpdateOperation, error) {
+	ret := make(map[string][]driver.UpdateOperation)
+	for n, ops := range s.ops[k] {
+		if len(ops) != 0 {
+			ret[n] = ops[:1]
+		}
+	}
+	return ret, nil
+}
+
+func (s *bundleStore) GetUpdateDiff(_ context.Context, _, cur uuid.UUID) (*driver.UpdateDiff, error) {
+	var d driver.UpdateDiff
+	for _, v := range s.vulns[cur] {
+		d.Added = append(d.Added, *v)
+	}
+	return &d, nil
+}
+
+func (s *bundleStore) GetEnrichmentRecords(_ context.Context, ref uuid.UUID) ([]driver.EnrichmentRecord, error) {
+	return s.enrichments[ref], nil
+}
+
+func (s *bundleStore) DeleteUpdateOperations(_ context.Context, refs ...uuid.UUID) (int64, error) {
+	var n int64
+	for _, ref := range refs {
+		for _, byName := range s.ops {
+			for name, ops := range byName {
+				for i := range ops {
+					if ops[i].Ref == ref {
+						byName[name] = append(ops[:i:i], ops[i+1:]...)
+						n++
+						break
+					}
+				}
+			}
+		}
+		delete(s.vulns, ref)
+		delete(s.enrichments, ref)
+	}
+	return n, nil
+}
+
+// Count reports the number of update operations in the store.
+func (s *bundleStore) count() (n int) {
+	for _, byName := range s.ops {
+		for _, ops := range byName {
+			n += len(ops)
+		}
+	}
+	return n
+}
+
+func TestBundle(t *testing.T) {
+	ctx := zlog.Test(context.Background(), t)
+	const mapping = `{"data":{}}`
+	vulns := []*claircore.Vulnerability{
+		{Name: "CVE-2023-0001", Updater: "rhel", Package: &claircore.Package{Name: "openssl"}},
+	}
+	recs := []driver.EnrichmentRecord{
+		{Tags: []string{"CVE-2023-0001"}, Enrichment: []byte(`{"score":0.5}`)},
+	}
+	src := newBundleStore()
+	if _, err := src.UpdateVulnerabilities(ctx, "rhel", "rhel-fp", vulns); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.UpdateEnrichments(ctx, "clair.epss", "epss-fp", recs); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := (&Libvuln{store: src}).Export(ctx, &buf, WithRepositoryMapping(strings.NewReader(mapping))); err != nil {
+		t.Fatal(err)
+	}
+	bundle := buf.Bytes()
+
+	t.Run("Import", func(t *testing.T) {
+		ctx := zlog.Test(ctx, t)
+		dst := newBundleStore()
+		out := filepath.Join(t.TempDir(), "repository-to-cpe.json")
+		l := &Libvuln{store: dst}
+		if err := l.Import(ctx, bytes.NewReader(bundle), WithRepositoryMappingFile(out)); err != nil {
+			t.Fatal(err)
+		}
+		op := dst.ops[driver.VulnerabilityKind]["rhel"][0]
+		if got, want := op.Fingerprint, driver.Fingerprint("rhel-fp"); got != want {
+			t.Errorf("got fingerprint %q, want %q", got, want)
+		}
+		if got, want := dst.vulns[op.Ref], vulns; !cmp.Equal(got, want) {
+			t.Error(cmp.Diff(got, want))
+		}
+		op = dst.ops[driver.EnrichmentKind]["clair.epss"][0]
+		if got, want := dst.enrichments[op.Ref], recs; !cmp.Equal(got, want) {
+			t.Error(cmp.Diff(got, want))
+		}
+		b, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(b), mapping; got != want {
+			t.Errorf("got mapping %q, want %q", got, want)
+		}
+
+		// Importing again skips everything.
+		if err := l.Import(ctx, bytes.NewReader(bundle)); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := dst.count(), 2; got != want {
+			t.Errorf("got %d update operations, want %d", got, want)
+		}
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		ctx := zlog.Test(ctx, t)
+		dst := newBundleStore()
+		dst.fail = "clair.epss"
+		out := filepath.Join(t.TempDir(), "repository-to-cpe.json")
+		err := (&Libvuln{store: dst}).Import(ctx, bytes.NewReader(bundle), WithRepositoryMappingFile(out))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		t.Log(err)
+		if got, want := dst.count(), 0; got != want {
+			t.Errorf("got %d update operations, want %d", got, want)
+		}
+		if _, err := os.Stat(out); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		ents, err := os.ReadDir(filepath.Dir(out))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ents) != 0 {
+			t.Errorf("staged files left behind: %v", ents)
+		}
+	})
+}
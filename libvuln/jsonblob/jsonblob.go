@@ -5,6 +5,8 @@ package jsonblob
 import (
 	"bufio"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -45,8 +47,12 @@ type Store struct {
 }
 
 // Load reads in all the records serialized in the provided [io.Reader].
+//
+// Both the current format and the older format, which repeated the entry's
+// metadata on every vulnerability, are accepted.
 func Load(ctx context.Context, r io.Reader) (*Loader, error) {
 	l := Loader{
+		ctx: ctx,
 		dec: json.NewDecoder(r),
 		cur: uuid.Nil,
 	}
@@ -56,15 +62,40 @@ func Load(ctx context.Context, r io.Reader) (*Loader, error) {
 // Loader is an iterator that returns a series of [Entry].
 //
 // Users should call [*Loader.Next] until it reports false, then check for
-// errors via [*Loader.Err].
+// errors via [*Loader.Err]. Users that stop calling [*Loader.Next] before
+// then must call [*Loader.Close] to release the Loader's temporary file.
 type Loader struct {
+	ctx context.Context
 	err error
 	e   *Entry
 
 	dec  *json.Decoder
 	next *Entry
-	de   diskEntry
 	cur  uuid.UUID
+	kind driver.UpdateKind
+	// Want is the number of records the header for "next" announced, or -1
+	// if there wasn't a header.
+	want int
+	got  int
+
+	// Bodies holds every record body seen with a hash, so that later records
+	// referring to the same hash can be decoded without keeping the bodies
+	// in memory.
+	bodies *os.File
+	spans  map[bodyKey]span
+	off    int64
+}
+
+// BodyKey identifies a record body by kind and content hash.
+type bodyKey struct {
+	kind driver.UpdateKind
+	hash string
+}
+
+// Span is the location of a record body in the Loader's bodies file.
+type span struct {
+	off int64
+	len int
 }
 
 // Next reports whether there's an [Entry] to be processed.
@@ -72,41 +103,168 @@ func (l *Loader) Next() bool {
 	if l.err != nil {
 		return false
 	}
-
-	var vs []claircore.Vulnerability
-	for l.err = l.dec.Decode(&l.de); l.err == nil; l.err = l.dec.Decode(&l.de) {
-		id := l.de.Ref
-		// If we just hit a new Entry, promote the current one.
-		if id != l.cur {
-			l.e = l.next
-			l.next = &Entry{}
-			l.next.Updater = l.de.Updater
-			l.next.Fingerprint = l.de.Fingerprint
-			l.next.Date = l.de.Date
-		}
-		i := len(vs)
-		vs = append(vs, claircore.Vulnerability{})
-		if err := json.Unmarshal(l.de.Vuln.buf, &vs[i]); err != nil {
+	fail := func(err error) bool {
+		l.err = err
+		l.closeBodies()
+		return false
+	}
+	for {
+		var de diskEntry
+		if err := l.dec.Decode(&de); err != nil {
 			l.err = err
-			return false
+			l.closeBodies()
+			if !errors.Is(err, io.EOF) || l.next == nil {
+				return false
+			}
+			if err := l.finish(); err != nil {
+				l.err = err
+				return false
+			}
+			l.e, l.next = l.next, nil
+			return true
+		}
+		var done *Entry
+		if de.Ref != l.cur {
+			if l.next != nil {
+				if err := l.finish(); err != nil {
+					return fail(err)
+				}
+			}
+			done = l.next
+			l.next = &Entry{CommonEntry: de.CommonEntry}
+			l.cur = de.Ref
+			// The kind is per-entry, and lines that don't name one hold
+			// vulnerabilities.
+			l.kind = driver.VulnerabilityKind
+			l.want, l.got = -1, 0
+		}
+		if de.Kind != "" {
+			l.kind = de.Kind
 		}
-		l.next.Vuln = append(l.next.Vuln, &vs[i])
+		if de.Count != nil {
+			l.want = *de.Count
+		}
+		if err := l.add(&de); err != nil {
+			return fail(err)
+		}
+		if done != nil {
+			l.e = done
+			return true
+		}
+	}
+}
 
-		// BUG(hank) The [Loader] type does not handle Enrichments.
+// Finish checks that the pending Entry has as many records as its header
+// announced.
+func (l *Loader) finish() error {
+	if l.want >= 0 && l.got != l.want {
+		return fmt.Errorf("jsonblob: entry %v: got %d records, want %d", l.cur, l.got, l.want)
+	}
+	return nil
+}
 
-		// If this was an initial diskEntry, promote the ref.
-		if id != l.cur {
-			l.cur = id
-			// If we have an Entry ready, report that.
-			if l.e != nil {
-				return true
-			}
+// Add decodes the record in "de", if any, into the pending Entry.
+func (l *Loader) add(de *diskEntry) error {
+	body := de.Vuln
+	if l.kind == driver.EnrichmentKind {
+		body = de.Enrichment
+	}
+	switch {
+	case len(body) != 0 && de.Hash != "":
+		if err := l.keep(bodyKey{l.kind, de.Hash}, body); err != nil {
+			return err
+		}
+	case len(body) != 0:
+	case de.Hash != "":
+		var err error
+		body, err = l.lookup(bodyKey{l.kind, de.Hash})
+		if err != nil {
+			return err
+		}
+	default:
+		// Header line.
+		return nil
+	}
+	l.got++
+	switch l.kind {
+	case driver.EnrichmentKind:
+		var r driver.EnrichmentRecord
+		if err := json.Unmarshal(body, &r); err != nil {
+			return err
+		}
+		l.next.Enrichment = append(l.next.Enrichment, r)
+	default:
+		var v claircore.Vulnerability
+		if err := json.Unmarshal(body, &v); err != nil {
+			return err
+		}
+		l.next.Vuln = append(l.next.Vuln, &v)
+	}
+	return nil
+}
+
+// Keep records "body" under "k" for later lookups.
+func (l *Loader) keep(k bodyKey, body []byte) error {
+	if l.bodies == nil {
+		f, err := diskBuf(l.ctx)
+		if err != nil {
+			return err
 		}
+		l.bodies = f
+		l.spans = make(map[bodyKey]span)
 	}
-	l.e = l.next
-	return true
+	if _, ok := l.spans[k]; ok {
+		return nil
+	}
+	if _, err := l.bodies.WriteAt(body, l.off); err != nil {
+		return err
+	}
+	l.spans[k] = span{off: l.off, len: len(body)}
+	l.off += int64(len(body))
+	return nil
 }
 
+// Lookup returns the body recorded under "k".
+func (l *Loader) lookup(k bodyKey) ([]byte, error) {
+	s, ok := l.spans[k]
+	if !ok {
+		return nil, fmt.Errorf("jsonblob: reference to unknown record %q", k.hash)
+	}
+	b := make([]byte, s.len)
+	if _, err := l.bodies.ReadAt(b, s.off); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// CloseBodies releases the bodies file, which is never linked into the
+// filesystem: closing it removes it.
+func (l *Loader) closeBodies() error {
+	var err error
+	if l.bodies != nil {
+		err = l.bodies.Close()
+		l.bodies = nil
+		l.spans = nil
+	}
+	return err
+}
+
+// Close releases the Loader's resources. After Close, [*Loader.Next] reports
+// false.
+//
+// Close is only needed if [*Loader.Next] is not called until it reports
+// false, but is safe to call in any case.
+func (l *Loader) Close() error {
+	if l.err == nil {
+		l.err = errClosed
+	}
+	return l.closeBodies()
+}
+
+// ErrClosed is reported by [*Loader.Err] if the Loader was closed before
+// reaching the end of its input.
+var errClosed = errors.New("jsonblob: Loader closed")
+
 // Entry returns the latest loaded [Entry].
 func (l *Loader) Entry() *Entry {
 	return l.e
@@ -124,6 +282,13 @@ func (l *Loader) Err() error {
 // Store writes out the contents of the receiver to the provided [io.Writer].
 // It's the inverse of [Load].
 //
+// Each entry is written as a header, recording the entry's metadata and
+// number of records, followed by one line per record. Records are streamed
+// from the temporary files written by [Store.UpdateVulnerabilities] and
+// [Store.UpdateEnrichments], and are identified by the SHA-256 hash of their
+// contents: records with the same contents as one already written only
+// carry the hash.
+//
 // Store may only be called once for a series of [Store.UpdateVulnerabilities] and
 // [Store.UpdateEnrichments] calls, as it deallocates resources as it writes them.
 //
@@ -134,33 +299,50 @@ func (s *Store) Store(w io.Writer) error {
 	defer s.RUnlock()
 	enc := json.NewEncoder(w)
 	enc.SetEscapeHTML(false)
+	seen := make(map[bodyKey]struct{})
+	buf := getBuf()
+	defer putBuf(buf)
 	write := func(id uuid.UUID, e CommonEntry) func(driver.UpdateKind, *os.File, int) error {
 		return func(k driver.UpdateKind, f *os.File, ct int) error {
 			if f == nil {
 				return nil
 			}
 			defer f.Close()
-			shim := newBufShim(f)
-			defer shim.Close()
-			for i := 0; i < ct; i++ {
-				dent := diskEntry{
-					CommonEntry: e,
-					Ref:         id,
-					Kind:        k,
+			hdr := diskHeader{
+				CommonEntry: e,
+				Ref:         id,
+				Kind:        k,
+				Count:       ct,
+			}
+			if err := enc.Encode(&hdr); err != nil {
+				return err
+			}
+			sc := bufio.NewScanner(f)
+			sc.Buffer(buf, len(buf))
+			for sc.Scan() {
+				b := sc.Bytes()
+				sum := sha256.Sum256(b)
+				rec := diskRecord{
+					Ref:  id,
+					Hash: hex.EncodeToString(sum[:]),
 				}
-				switch k {
-				case driver.EnrichmentKind:
-					dent.Enrichment = shim
-				case driver.VulnerabilityKind:
-					dent.Vuln = shim
-				default:
-					panic(fmt.Sprintf("programmer error: unknown kind: %v", k))
+				key := bodyKey{k, rec.Hash}
+				if _, ok := seen[key]; !ok {
+					seen[key] = struct{}{}
+					switch k {
+					case driver.EnrichmentKind:
+						rec.Enrichment = b
+					case driver.VulnerabilityKind:
+						rec.Vuln = b
+					default:
+						panic(fmt.Sprintf("programmer error: unknown kind: %v", k))
+					}
 				}
-				if err := enc.Encode(&dent); err != nil {
+				if err := enc.Encode(&rec); err != nil {
 					return err
 				}
 			}
-			return nil
+			return sc.Err()
 		}
 	}
 
@@ -176,42 +358,6 @@ func (s *Store) Store(w io.Writer) error {
 	return nil
 }
 
-// BufShim treats every call to [bufShim.MarshalJSON] as a [bufio.Scanner.Scan]
-// call.
-//
-// Note this type is very weird, in that it can only be used for _either_ an
-// Unmarshal or a Marshal, not both. Doing both on the same structure will
-// silently do the wrong thing.
-type bufShim struct {
-	s   *bufio.Scanner
-	buf []byte
-}
-
-func newBufShim(r io.Reader) *bufShim {
-	s := new(bufShim)
-	s.s = bufio.NewScanner(r)
-	s.buf = getBuf()
-	s.s.Buffer(s.buf, len(s.buf))
-	return s
-}
-
-func (s *bufShim) MarshalJSON() ([]byte, error) {
-	if !s.s.Scan() {
-		return nil, s.s.Err()
-	}
-	return s.s.Bytes(), nil
-}
-
-func (s *bufShim) UnmarshalJSON(b []byte) error {
-	s.buf = append(s.buf[0:0], b...)
-	return nil
-}
-
-func (s *bufShim) Close() error {
-	putBuf(s.buf)
-	return nil
-}
-
 // Entry is a record of all information needed to record a vulnerability at a
 // later date.
 type Entry struct {
@@ -234,17 +380,35 @@ type CommonEntry struct {
 	Date        time.Time
 }
 
-// DiskEntry is a single vulnerability. It's made from unpacking an Entry's
-// slice and adding a uuid for grouping back into an Entry upon read.
-//
-// "Vuln" and "Enrichment" are populated from the backing disk immediately
-// before being serialized.
+// DiskHeader starts an entry in the output of [Store.Store].
+type diskHeader struct {
+	CommonEntry
+	Ref   uuid.UUID
+	Kind  driver.UpdateKind
+	Count int
+}
+
+// DiskRecord is a single vulnerability or enrichment record in the output of
+// [Store.Store]. The body is omitted if a record with the same hash has
+// already been written.
+type diskRecord struct {
+	Ref        uuid.UUID
+	Hash       string
+	Vuln       json.RawMessage `json:",omitempty"`
+	Enrichment json.RawMessage `json:",omitempty"`
+}
+
+// DiskEntry is any line of the output of [Store.Store], in the current or
+// older format. The older format wrote the CommonEntry and Kind with every
+// record and had no headers or hashes.
 type diskEntry struct {
 	CommonEntry
 	Ref        uuid.UUID
-	Vuln       *bufShim `json:",omitempty"`
-	Enrichment *bufShim `json:",omitempty"`
 	Kind       driver.UpdateKind
+	Count      *int
+	Hash       string
+	Vuln       json.RawMessage
+	Enrichment json.RawMessage
 }
 
 // Entries returns a map containing all the Entries stored by calls to
@@ -3,13 +3,16 @@ package jsonblob
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"io"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/quay/claircore"
+	"github.com/quay/claircore/libvuln/driver"
 	"github.com/quay/claircore/test"
 )
 
@@ -77,3 +80,173 @@ func TestRoundtrip(t *testing.T) {
 		t.Error(cmp.Diff(got, vs))
 	}
 }
+
+func TestDedup(t *testing.T) {
+	ctx := context.Background()
+	s, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs := test.GenUniqueVulnerabilities(10, "test")
+	// The second updater reports the same vulnerabilities, and one of them
+	// twice.
+	dup := append(append([]*claircore.Vulnerability(nil), vs...), vs[0])
+	if _, err := s.UpdateVulnerabilities(ctx, "a", "", vs); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.UpdateVulnerabilities(ctx, "b", "", dup); err != nil {
+		t.Fatal(err)
+	}
+	es := []driver.EnrichmentRecord{
+		{Tags: []string{"a"}, Enrichment: json.RawMessage(`{"a":1}`)},
+		{Tags: []string{"a"}, Enrichment: json.RawMessage(`{"a":1}`)},
+	}
+	if _, err := s.UpdateEnrichments(ctx, "e", "", es); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := s.Store(&buf); err != nil {
+		t.Fatal(err)
+	}
+	// Every vulnerability name should only be written out once.
+	for _, v := range vs {
+		if n := strings.Count(buf.String(), `"name":"`+v.Name+`"`); n != 1 {
+			t.Errorf("%s: written %d times", v.Name, n)
+		}
+	}
+
+	l, err := Load(ctx, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]*Entry)
+	for l.Next() {
+		e := l.Entry()
+		got[e.Updater] = e
+	}
+	if err := l.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(got["a"].Vuln, vs) {
+		t.Error(cmp.Diff(got["a"].Vuln, vs))
+	}
+	if !cmp.Equal(got["b"].Vuln, dup) {
+		t.Error(cmp.Diff(got["b"].Vuln, dup))
+	}
+	if !cmp.Equal(got["e"].Enrichment, es) {
+		t.Error(cmp.Diff(got["e"].Enrichment, es))
+	}
+}
+
+func TestLoadLegacy(t *testing.T) {
+	// This is the format written before entries had headers and records had
+	// hashes.
+	const in = `{"Updater":"test","Fingerprint":"","Date":"2023-01-01T00:00:00Z","Ref":"9f2f1b1e-2d6e-4c79-9a1c-3b6f4f2f2a11","Vuln":{"name":"CVE-2023-0001"},"Kind":"vulnerability"}
+{"Updater":"test","Fingerprint":"","Date":"2023-01-01T00:00:00Z","Ref":"9f2f1b1e-2d6e-4c79-9a1c-3b6f4f2f2a11","Vuln":{"name":"CVE-2023-0002"},"Kind":"vulnerability"}
+{"Updater":"other","Fingerprint":"fp","Date":"2023-01-01T00:00:00Z","Ref":"0b1c8e8e-5b0a-4e0e-8f57-0f0d8f3c6d22","Vuln":{"name":"CVE-2023-0003"},"Kind":"vulnerability"}
+`
+	l, err := Load(context.Background(), strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string][]string)
+	for l.Next() {
+		e := l.Entry()
+		for _, v := range e.Vuln {
+			got[e.Updater] = append(got[e.Updater], v.Name)
+		}
+	}
+	if err := l.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"test":  {"CVE-2023-0001", "CVE-2023-0002"},
+		"other": {"CVE-2023-0003"},
+	}
+	if !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(got, want))
+	}
+}
+
+func TestLoadEmpty(t *testing.T) {
+	l, err := Load(context.Background(), strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Next() {
+		t.Errorf("unexpected entry: %+v", l.Entry())
+	}
+	if err := l.Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLoadKind(t *testing.T) {
+	// An enrichment entry followed by an entry whose lines don't name a kind,
+	// which must be read as vulnerabilities.
+	const in = `{"Updater":"e","Fingerprint":"","Date":"2023-01-01T00:00:00Z","Ref":"9f2f1b1e-2d6e-4c79-9a1c-3b6f4f2f2a11","Kind":"enrichment","Count":1}
+{"Ref":"9f2f1b1e-2d6e-4c79-9a1c-3b6f4f2f2a11","Enrichment":{"Tags":["a"],"Enrichment":{"a":1}}}
+{"Updater":"test","Fingerprint":"","Date":"2023-01-01T00:00:00Z","Ref":"0b1c8e8e-5b0a-4e0e-8f57-0f0d8f3c6d22","Vuln":{"name":"CVE-2023-0001"}}
+`
+	l, err := Load(context.Background(), strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	got := make(map[string]*Entry)
+	for l.Next() {
+		e := l.Entry()
+		got[e.Updater] = e
+	}
+	if err := l.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(got["e"].Enrichment), 1; got != want {
+		t.Errorf("got %d enrichments, want %d", got, want)
+	}
+	if e := got["test"]; len(e.Vuln) != 1 || len(e.Enrichment) != 0 {
+		t.Errorf("got %d vulnerabilities and %d enrichments, want 1 and 0", len(e.Vuln), len(e.Enrichment))
+	}
+}
+
+func TestLoadClose(t *testing.T) {
+	ctx := context.Background()
+	s, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs := test.GenUniqueVulnerabilities(10, "test")
+	for _, u := range []string{"a", "b"} {
+		if _, err := s.UpdateVulnerabilities(ctx, u, "", vs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := s.Store(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := Load(ctx, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !l.Next() {
+		t.Fatalf("no entry: %v", l.Err())
+	}
+	if l.bodies == nil {
+		t.Fatal("expected record bodies to be kept")
+	}
+	if err := l.Close(); err != nil {
+		t.Error(err)
+	}
+	if l.bodies != nil {
+		t.Error("record bodies not released")
+	}
+	if l.Next() {
+		t.Error("unexpected entry after Close")
+	}
+	if err := l.Close(); err != nil {
+		t.Error(err)
+	}
+}
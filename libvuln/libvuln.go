@@ -109,6 +109,7 @@ func New(ctx context.Context, opts *Options) (*Libvuln, error) {
 		updates.WithConfigs(opts.UpdaterConfigs),
 		updates.WithOutOfTree(opts.Updaters),
 		updates.WithGC(opts.UpdateRetention),
+		updates.WithUpdateHook(l.notifier(opts)),
 	)
 	if err != nil {
 		return nil, err
@@ -134,11 +135,63 @@ func (l *Libvuln) FetchUpdates(ctx context.Context) error {
 }
 
 // Scan creates a VulnerabilityReport given a manifest's IndexReport.
-func (l *Libvuln) Scan(ctx context.Context, ir *claircore.IndexReport) (*claircore.VulnerabilityReport, error) {
+//
+// By default, all the matchers Libvuln was constructed with are used. The
+// ScanOptions can narrow this down for a single call.
+func (l *Libvuln) Scan(ctx context.Context, ir *claircore.IndexReport, opts ...ScanOption) (*claircore.VulnerabilityReport, error) {
+	ms, err := l.scanMatchers(opts)
+	if err != nil {
+		return nil, err
+	}
 	if s, ok := l.store.(matcher.Store); ok {
-		return matcher.EnrichedMatch(ctx, ir, l.matchers, l.enrichers, s)
+		return matcher.EnrichedMatch(ctx, ir, ms, l.enrichers, s)
+	}
+	return matcher.Match(ctx, ir, ms, l.store)
+}
+
+// ScanMatchers returns the matchers to use for a call to Scan with the
+// options "opts".
+func (l *Libvuln) scanMatchers(opts []ScanOption) ([]driver.Matcher, error) {
+	if len(opts) == 0 {
+		return l.matchers, nil
+	}
+	var cfg scanConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+	known := make(map[string]bool, len(l.matchers))
+	for _, m := range l.matchers {
+		known[m.Name()] = true
+	}
+	for _, set := range []map[string]bool{cfg.enabled, cfg.disabled} {
+		for n := range set {
+			if !known[n] {
+				return nil, fmt.Errorf("libvuln: unknown matcher %q", n)
+			}
+		}
+	}
+	ms := make([]driver.Matcher, 0, len(l.matchers))
+	for _, m := range l.matchers {
+		n := m.Name()
+		if cfg.enabled != nil && !cfg.enabled[n] {
+			continue
+		}
+		if cfg.disabled[n] {
+			continue
+		}
+		ms = append(ms, m)
+	}
+	return ms, nil
+}
+
+// MatcherNames reports the names of the matchers Libvuln was constructed
+// with, for use with WithMatchers and WithoutMatchers.
+func (l *Libvuln) MatcherNames() []string {
+	ns := make([]string, len(l.matchers))
+	for i, m := range l.matchers {
+		ns[i] = m.Name()
 	}
-	return matcher.Match(ctx, ir, l.matchers, l.store)
+	return ns
 }
 
 // UpdateOperations returns UpdateOperations in date descending order keyed by the
@@ -5,6 +5,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/rs/zerolog"
 
 	"github.com/quay/claircore"
@@ -44,3 +45,75 @@ func (*TestMatcher) Query() []driver.MatchConstraint {
 func (*TestMatcher) Vulnerable(context.Context, *claircore.IndexRecord, *claircore.Vulnerability) (bool, error) {
 	return false, nil
 }
+
+func TestScanMatchers(t *testing.T) {
+	l := &Libvuln{
+		matchers: []driver.Matcher{
+			&namedMatcher{name: "rhel"},
+			&namedMatcher{name: "debian"},
+			&namedMatcher{name: "python"},
+			&namedMatcher{name: "gobin"},
+		},
+	}
+	tt := []struct {
+		Name string
+		Opts []ScanOption
+		Want []string
+		Err  bool
+	}{
+		{
+			Name: "Default",
+			Want: []string{"rhel", "debian", "python", "gobin"},
+		},
+		{
+			Name: "Only",
+			Opts: []ScanOption{WithMatchers("rhel")},
+			Want: []string{"rhel"},
+		},
+		{
+			Name: "Without",
+			Opts: []ScanOption{WithoutMatchers("python", "gobin")},
+			Want: []string{"rhel", "debian"},
+		},
+		{
+			Name: "Both",
+			Opts: []ScanOption{WithMatchers("rhel", "python"), WithMatchers("gobin"), WithoutMatchers("python")},
+			Want: []string{"rhel", "gobin"},
+		},
+		{
+			Name: "None",
+			Opts: []ScanOption{WithMatchers()},
+			Want: []string{},
+		},
+		{
+			Name: "Unknown",
+			Opts: []ScanOption{WithoutMatchers("ruby")},
+			Err:  true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			ms, err := l.scanMatchers(tc.Opts)
+			if (err != nil) != tc.Err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.Err {
+				return
+			}
+			got := make([]string, len(ms))
+			for i, m := range ms {
+				got[i] = m.Name()
+			}
+			if !cmp.Equal(got, tc.Want) {
+				t.Error(cmp.Diff(got, tc.Want))
+			}
+		})
+	}
+}
+
+type namedMatcher struct {
+	TestMatcher
+	name string
+}
+
+func (m *namedMatcher) Name() string { return m.name }
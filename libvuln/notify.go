@@ -0,0 +1,87 @@
+package libvuln
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/datastore"
+	"github.com/quay/claircore/libvuln/driver"
+	"github.com/quay/claircore/libvuln/updates"
+)
+
+// UpdateNotification describes the changes made by an updater run.
+type UpdateNotification struct {
+	// Operations are the update operations created by the run, sorted by
+	// updater name.
+	Operations []driver.UpdateOperation `json:"operations"`
+	// Diffs are the changes made by the vulnerability update operations,
+	// each compared to the updater's previous operation. Enrichment update
+	// operations don't have diffs.
+	Diffs []*driver.UpdateDiff `json:"diffs"`
+	// Affected are the manifests affected by the vulnerabilities added in
+	// the run. It's only set if Options.AffectedManifests is.
+	Affected *claircore.AffectedManifests `json:"affected,omitempty"`
+}
+
+// Notifier returns the hook for the update Manager that calls the
+// UpdateNotifier in "opts", or nil if there isn't one.
+func (l *Libvuln) notifier(opts *Options) updates.UpdateHook {
+	if opts.UpdateNotifier == nil {
+		return nil
+	}
+	notify, affected := opts.UpdateNotifier, opts.AffectedManifests
+	return func(ctx context.Context, ops []driver.UpdateOperation) {
+		ctx = zlog.ContextWithValues(ctx, "component", "libvuln/Libvuln.notifier")
+		n, err := newNotification(ctx, l.store, ops, affected)
+		if err != nil {
+			zlog.Error(ctx).Err(err).Msg("unable to construct update notification")
+			return
+		}
+		notify(ctx, n)
+	}
+}
+
+// NewNotification constructs the UpdateNotification for the update
+// operations "ops", using "affected" to find the affected manifests if it's
+// not nil.
+func newNotification(ctx context.Context, store datastore.Updater, ops []driver.UpdateOperation, affected func(context.Context, []claircore.Vulnerability) (*claircore.AffectedManifests, error)) (*UpdateNotification, error) {
+	n := UpdateNotification{
+		Operations: ops,
+	}
+	var added []claircore.Vulnerability
+	for _, op := range ops {
+		if op.Kind != driver.VulnerabilityKind {
+			continue
+		}
+		hist, err := store.GetUpdateOperations(ctx, op.Kind, op.Updater)
+		if err != nil {
+			return nil, err
+		}
+		// Operations are returned newest first, so the previous one is
+		// whichever follows the new one.
+		prev := uuid.Nil
+		for i, h := range hist[op.Updater] {
+			if h.Ref == op.Ref && i+1 < len(hist[op.Updater]) {
+				prev = hist[op.Updater][i+1].Ref
+				break
+			}
+		}
+		diff, err := store.GetUpdateDiff(ctx, prev, op.Ref)
+		if err != nil {
+			return nil, err
+		}
+		n.Diffs = append(n.Diffs, diff)
+		added = append(added, diff.Added...)
+	}
+	if affected != nil && len(added) != 0 {
+		a, err := affected(ctx, added)
+		if err != nil {
+			return nil, err
+		}
+		n.Affected = a
+	}
+	return &n, nil
+}
@@ -0,0 +1,89 @@
+package libvuln
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/datastore"
+	"github.com/quay/claircore/libvuln/driver"
+)
+
+// NotifyStore serves update operations and diffs from memory.
+type notifyStore struct {
+	datastore.Updater
+	ops   map[string][]driver.UpdateOperation
+	diffs map[[2]uuid.UUID]*driver.UpdateDiff
+}
+
+func (s *notifyStore) GetUpdateOperations(_ context.Context, _ driver.UpdateKind, names ...string) (map[string][]driver.UpdateOperation, error) {
+	ret := make(map[string][]driver.UpdateOperation)
+	for _, n := range names {
+		ret[n] = s.ops[n]
+	}
+	return ret, nil
+}
+
+func (s *notifyStore) GetUpdateDiff(_ context.Context, prev, cur uuid.UUID) (*driver.UpdateDiff, error) {
+	return s.diffs[[2]uuid.UUID{prev, cur}], nil
+}
+
+func TestNewNotification(t *testing.T) {
+	ctx := context.Background()
+	old, first, cur, enrich := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	vuln := claircore.Vulnerability{ID: "1", Name: "CVE-2023-0001"}
+	store := &notifyStore{
+		ops: map[string][]driver.UpdateOperation{
+			"rhel":   {{Ref: cur, Updater: "rhel"}, {Ref: old, Updater: "rhel"}},
+			"debian": {{Ref: first, Updater: "debian"}},
+		},
+		diffs: map[[2]uuid.UUID]*driver.UpdateDiff{
+			{old, cur}:        {Added: []claircore.Vulnerability{vuln}},
+			{uuid.Nil, first}: {},
+		},
+	}
+	ops := []driver.UpdateOperation{
+		{Ref: first, Updater: "debian", Kind: driver.VulnerabilityKind},
+		{Ref: enrich, Updater: "epss", Kind: driver.EnrichmentKind},
+		{Ref: cur, Updater: "rhel", Kind: driver.VulnerabilityKind},
+	}
+	var asked []claircore.Vulnerability
+	affected := func(_ context.Context, vs []claircore.Vulnerability) (*claircore.AffectedManifests, error) {
+		asked = vs
+		a := claircore.NewAffectedManifests()
+		a.Add(&vs[0], claircore.MustParseDigest(`sha256:0000000000000000000000000000000000000000000000000000000000000000`))
+		return &a, nil
+	}
+
+	n, err := newNotification(ctx, store, ops, affected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(n.Operations), 3; got != want {
+		t.Errorf("got %d operations, want %d", got, want)
+	}
+	if got, want := len(n.Diffs), 2; got != want {
+		t.Fatalf("got %d diffs, want %d", got, want)
+	}
+	if got, want := len(n.Diffs[1].Added), 1; got != want {
+		t.Errorf("got %d added vulnerabilities, want %d", got, want)
+	}
+	if len(asked) != 1 || asked[0].Name != vuln.Name {
+		t.Errorf("unexpected vulnerabilities passed to AffectedManifests: %v", asked)
+	}
+	if n.Affected == nil || len(n.Affected.VulnerableManifests) != 1 {
+		t.Errorf("unexpected affected manifests: %+v", n.Affected)
+	}
+
+	// Without any added vulnerabilities, AffectedManifests isn't called.
+	asked = nil
+	n, err = newNotification(ctx, store, ops[:1], affected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asked != nil || n.Affected != nil {
+		t.Error("AffectedManifests called without added vulnerabilities")
+	}
+}
@@ -1,9 +1,11 @@
 package libvuln
 
 import (
+	"context"
 	"net/http"
 	"time"
 
+	"github.com/quay/claircore"
 	"github.com/quay/claircore/datastore"
 	"github.com/quay/claircore/libvuln/driver"
 )
@@ -103,4 +105,59 @@ type Options struct {
 	//
 	// Must be set.
 	Client *http.Client
+
+	// UpdateNotifier, if set, is called after each updater run that created
+	// update operations, whether from the background updates or
+	// FetchUpdates.
+	UpdateNotifier func(context.Context, *UpdateNotification)
+
+	// AffectedManifests, if set, is used to find the manifests affected by
+	// the vulnerabilities added in an updater run, for the UpdateNotifier.
+	// The AffectedManifests method of a Libindex can be used.
+	AffectedManifests func(context.Context, []claircore.Vulnerability) (*claircore.AffectedManifests, error)
+}
+
+// ScanOption configures a single call to Scan.
+type ScanOption func(*scanConfig)
+
+type scanConfig struct {
+	// Enabled is the set of matchers to use, or nil for all of them.
+	enabled map[string]bool
+	// Disabled is the set of matchers to skip.
+	disabled map[string]bool
+}
+
+// WithMatchers restricts a Scan to the named matchers, such as only "rhel"
+// for a report about a RHEL image. Using this option more than once uses the
+// matchers named in any of them.
+//
+// Scan returns an error if a name isn't one of the matchers Libvuln was
+// constructed with.
+func WithMatchers(names ...string) ScanOption {
+	return func(c *scanConfig) {
+		if c.enabled == nil {
+			c.enabled = make(map[string]bool, len(names))
+		}
+		for _, n := range names {
+			c.enabled[n] = true
+		}
+	}
+}
+
+// WithoutMatchers excludes the named matchers from a Scan, such as the
+// language matchers ("gobin", "java-maven", "python") for a scan
+// only concerned with distribution packages. It takes precedence over
+// WithMatchers.
+//
+// Scan returns an error if a name isn't one of the matchers Libvuln was
+// constructed with.
+func WithoutMatchers(names ...string) ScanOption {
+	return func(c *scanConfig) {
+		if c.disabled == nil {
+			c.disabled = make(map[string]bool, len(names))
+		}
+		for _, n := range names {
+			c.disabled[n] = true
+		}
+	}
 }
@@ -0,0 +1,185 @@
+package libvuln
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/quay/claircore"
+)
+
+// ReportDiff describes the changes in findings between two
+// VulnerabilityReports, per package.
+type ReportDiff struct {
+	Prev     claircore.Digest `json:"prev"`
+	Cur      claircore.Digest `json:"cur"`
+	Packages []PackageDiff    `json:"packages"`
+}
+
+// PackageDiff describes the changes in findings for one package.
+type PackageDiff struct {
+	// Package is the package from the current report, or from the previous
+	// report if it's no longer present.
+	Package *claircore.Package `json:"package"`
+	// Added are the vulnerabilities only found in the current report.
+	Added []*claircore.Vulnerability `json:"added,omitempty"`
+	// Removed are the vulnerabilities only found in the previous report.
+	Removed []*claircore.Vulnerability `json:"removed,omitempty"`
+	// SeverityChanged are the vulnerabilities found in both reports with a
+	// different severity.
+	SeverityChanged []SeverityChange `json:"severity_changed,omitempty"`
+}
+
+// SeverityChange is a vulnerability whose severity differs between reports.
+type SeverityChange struct {
+	Prev *claircore.Vulnerability `json:"prev"`
+	Cur  *claircore.Vulnerability `json:"cur"`
+}
+
+// DiffReports compares the findings in the VulnerabilityReports "prev" and
+// "cur", such as a stored report and a fresh scan of the same manifest. A nil
+// report is treated as having no findings.
+//
+// Database IDs change as vulnerability data is updated and differ between
+// manifests, so they aren't used to line up the reports. Packages are matched
+// by name, version, kind, architecture, and module, and vulnerabilities by
+// updater and name. If a package has several vulnerabilities with the same
+// updater and name, the most severe is used. A vulnerability's severity
+// changed if either its normalized severity or its severity string differs.
+//
+// Only packages with changes are reported, sorted by name and version, with
+// the vulnerabilities for each sorted by name.
+func DiffReports(prev, cur *claircore.VulnerabilityReport) *ReportDiff {
+	var d ReportDiff
+	if prev != nil {
+		d.Prev = prev.Hash
+	}
+	if cur != nil {
+		d.Cur = cur.Hash
+	}
+	ps, cs := reportFindings(prev), reportFindings(cur)
+	keys := make(map[string]struct{}, len(ps)+len(cs))
+	for k := range ps {
+		keys[k] = struct{}{}
+	}
+	for k := range cs {
+		keys[k] = struct{}{}
+	}
+	for k := range keys {
+		p, c := ps[k], cs[k]
+		var pd PackageDiff
+		switch {
+		case c != nil:
+			pd.Package = c.pkg
+		default:
+			pd.Package = p.pkg
+		}
+		var pv, cv map[string]*claircore.Vulnerability
+		if p != nil {
+			pv = p.vulns
+		}
+		if c != nil {
+			cv = c.vulns
+		}
+		for vk, v := range cv {
+			old, ok := pv[vk]
+			switch {
+			case !ok:
+				pd.Added = append(pd.Added, v)
+			case old.NormalizedSeverity != v.NormalizedSeverity || old.Severity != v.Severity:
+				pd.SeverityChanged = append(pd.SeverityChanged, SeverityChange{Prev: old, Cur: v})
+			}
+		}
+		for vk, v := range pv {
+			if _, ok := cv[vk]; !ok {
+				pd.Removed = append(pd.Removed, v)
+			}
+		}
+		if len(pd.Added) == 0 && len(pd.Removed) == 0 && len(pd.SeverityChanged) == 0 {
+			continue
+		}
+		sortVulns(pd.Added)
+		sortVulns(pd.Removed)
+		sort.Slice(pd.SeverityChanged, func(i, j int) bool {
+			return vulnLess(pd.SeverityChanged[i].Cur, pd.SeverityChanged[j].Cur)
+		})
+		d.Packages = append(d.Packages, pd)
+	}
+	sort.Slice(d.Packages, func(i, j int) bool {
+		a, b := d.Packages[i].Package, d.Packages[j].Package
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Version != b.Version {
+			return a.Version < b.Version
+		}
+		return packageKey(a) < packageKey(b)
+	})
+	return &d
+}
+
+// Findings are the vulnerabilities reported for a package, keyed by
+// vulnerabilityKey.
+type findings struct {
+	pkg   *claircore.Package
+	vulns map[string]*claircore.Vulnerability
+}
+
+// ReportFindings collects the findings in "r", keyed by packageKey.
+func reportFindings(r *claircore.VulnerabilityReport) map[string]*findings {
+	if r == nil {
+		return nil
+	}
+	ret := make(map[string]*findings, len(r.PackageVulnerabilities))
+	for pkgID, vulnIDs := range r.PackageVulnerabilities {
+		p, ok := r.Packages[pkgID]
+		if !ok {
+			continue
+		}
+		k := packageKey(p)
+		f, ok := ret[k]
+		if !ok {
+			f = &findings{
+				pkg:   p,
+				vulns: make(map[string]*claircore.Vulnerability, len(vulnIDs)),
+			}
+			ret[k] = f
+		}
+		for _, id := range vulnIDs {
+			v, ok := r.Vulnerabilities[id]
+			if !ok {
+				continue
+			}
+			vk := v.Updater + "\x00" + v.Name
+			if old, ok := f.vulns[vk]; ok && !moreSevere(v, old) {
+				continue
+			}
+			f.vulns[vk] = v
+		}
+	}
+	return ret
+}
+
+// PackageKey identifies a package independently of its database ID.
+func packageKey(p *claircore.Package) string {
+	return strings.Join([]string{p.Name, p.Version, p.Kind, p.Arch, p.Module}, "\x00")
+}
+
+// MoreSevere reports whether "a" should be used over "b" when they have the
+// same updater and name. Ties go to the lower ID, so the choice is stable.
+func moreSevere(a, b *claircore.Vulnerability) bool {
+	if a.NormalizedSeverity != b.NormalizedSeverity {
+		return a.NormalizedSeverity > b.NormalizedSeverity
+	}
+	return a.ID < b.ID
+}
+
+func sortVulns(vs []*claircore.Vulnerability) {
+	sort.Slice(vs, func(i, j int) bool { return vulnLess(vs[i], vs[j]) })
+}
+
+func vulnLess(a, b *claircore.Vulnerability) bool {
+	if a.Name != b.Name {
+		return a.Name < b.Name
+	}
+	return a.Updater < b.Updater
+}
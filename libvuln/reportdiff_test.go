@@ -0,0 +1,83 @@
+package libvuln
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/quay/claircore"
+)
+
+func TestDiffReports(t *testing.T) {
+	prev := &claircore.VulnerabilityReport{
+		Packages: map[string]*claircore.Package{
+			"1": {ID: "1", Name: "openssl", Version: "3.0.7-1", Kind: claircore.BINARY},
+			"2": {ID: "2", Name: "bash", Version: "5.1-2", Kind: claircore.BINARY},
+			"3": {ID: "3", Name: "zlib", Version: "1.2.11", Kind: claircore.BINARY},
+		},
+		Vulnerabilities: map[string]*claircore.Vulnerability{
+			"10": {ID: "10", Updater: "rhel", Name: "CVE-2023-0001", NormalizedSeverity: claircore.Medium},
+			"11": {ID: "11", Updater: "rhel", Name: "CVE-2023-0002", NormalizedSeverity: claircore.Low},
+			"12": {ID: "12", Updater: "rhel", Name: "CVE-2023-0003", NormalizedSeverity: claircore.High},
+			"13": {ID: "13", Updater: "rhel", Name: "CVE-2023-0004", NormalizedSeverity: claircore.Low},
+		},
+		PackageVulnerabilities: map[string][]string{
+			"1": {"10", "11"},
+			"2": {"12"},
+			"3": {"13"},
+		},
+	}
+	// The IDs are all different, as they would be after an update.
+	cur := &claircore.VulnerabilityReport{
+		Packages: map[string]*claircore.Package{
+			"4": {ID: "4", Name: "openssl", Version: "3.0.7-1", Kind: claircore.BINARY},
+			"5": {ID: "5", Name: "bash", Version: "5.1-2", Kind: claircore.BINARY},
+			"6": {ID: "6", Name: "zlib", Version: "1.2.11", Kind: claircore.BINARY},
+		},
+		Vulnerabilities: map[string]*claircore.Vulnerability{
+			"20": {ID: "20", Updater: "rhel", Name: "CVE-2023-0001", NormalizedSeverity: claircore.Critical},
+			"21": {ID: "21", Updater: "rhel", Name: "CVE-2023-0005", NormalizedSeverity: claircore.Low},
+			"22": {ID: "22", Updater: "rhel", Name: "CVE-2023-0003", NormalizedSeverity: claircore.High},
+			"23": {ID: "23", Updater: "rhel", Name: "CVE-2023-0004", NormalizedSeverity: claircore.Low},
+			"24": {ID: "24", Updater: "rhel", Name: "CVE-2023-0004", NormalizedSeverity: claircore.Unknown},
+		},
+		PackageVulnerabilities: map[string][]string{
+			"4": {"20", "21"},
+			"5": {"22"},
+			"6": {"24", "23"},
+		},
+	}
+
+	got := DiffReports(prev, cur)
+	want := &ReportDiff{
+		Packages: []PackageDiff{
+			{
+				Package: cur.Packages["4"],
+				Added:   []*claircore.Vulnerability{cur.Vulnerabilities["21"]},
+				Removed: []*claircore.Vulnerability{prev.Vulnerabilities["11"]},
+				SeverityChanged: []SeverityChange{
+					{Prev: prev.Vulnerabilities["10"], Cur: cur.Vulnerabilities["20"]},
+				},
+			},
+		},
+	}
+	opt := cmp.AllowUnexported(claircore.Digest{})
+	if !cmp.Equal(got, want, opt) {
+		t.Error(cmp.Diff(got, want, opt))
+	}
+
+	t.Run("Nil", func(t *testing.T) {
+		got := DiffReports(nil, prev)
+		if len(got.Packages) != 3 {
+			t.Fatalf("got %d packages, want 3", len(got.Packages))
+		}
+		for _, pd := range got.Packages {
+			if len(pd.Removed) != 0 || len(pd.SeverityChanged) != 0 {
+				t.Errorf("%s: unexpected changes: %+v", pd.Package.Name, pd)
+			}
+		}
+		if got := DiffReports(cur, cur); len(got.Packages) != 0 {
+			t.Errorf("expected no changes, got: %+v", got.Packages)
+		}
+	})
+}
@@ -28,6 +28,7 @@ func OfflineImport(ctx context.Context, pool *pgxpool.Pool, in io.Reader) error
 	if err != nil {
 		return err
 	}
+	defer l.Close()
 
 	ops, err := s.GetUpdateOperations(ctx, driver.VulnerabilityKind)
 	if err != nil {
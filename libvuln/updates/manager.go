@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"runtime"
+	"sort"
 	"strings"
 	"time"
 
@@ -54,6 +55,12 @@ type Manager struct {
 	// instructs manager to run gc and provides the number of
 	// update operations to keep.
 	updateRetention int
+	// if non-zero, vulnerabilities issued longer ago than this are
+	// discarded. Entries in maxAges override it per updater.
+	maxAge  time.Duration
+	maxAges map[string]time.Duration
+	// called with the update operations created by each run, if set.
+	hook UpdateHook
 
 	locks  LockSource
 	client *http.Client
@@ -195,6 +202,7 @@ func (m *Manager) Run(ctx context.Context) error {
 
 	sem := semaphore.NewWeighted(int64(m.batchSize))
 	errChan := make(chan error, len(toRun)+1) // +1 for a potential ctx error
+	opChan := make(chan driver.UpdateOperation, len(toRun))
 	for i := range toRun {
 		err := sem.Acquire(ctx, 1)
 		if err != nil {
@@ -217,10 +225,13 @@ func (m *Manager) Run(ctx context.Context) error {
 				return
 			}
 
-			err = m.driveUpdater(ctx, u)
+			op, err := m.driveUpdater(ctx, u)
 			if err != nil {
 				errChan <- fmt.Errorf("%v: %w", u.Name(), err)
 			}
+			if op != nil {
+				opChan <- *op
+			}
 		}(toRun[i])
 	}
 
@@ -250,6 +261,16 @@ func (m *Manager) Run(ctx context.Context) error {
 		done()
 	}
 
+	close(opChan)
+	if m.hook != nil && len(opChan) != 0 {
+		ops := make([]driver.UpdateOperation, 0, len(opChan))
+		for op := range opChan {
+			ops = append(ops, op)
+		}
+		sort.Slice(ops, func(i, j int) bool { return ops[i].Updater < ops[j].Updater })
+		m.hook(ctx, ops)
+	}
+
 	close(errChan)
 	if len(errChan) != 0 {
 		var b strings.Builder
@@ -283,7 +304,9 @@ func getFactoryNameFromStubUpdater(set driver.UpdaterSet) (string, error) {
 
 // DriveUpdater performs the business logic of fetching, parsing, and loading
 // vulnerabilities discovered by an updater into the database.
-func (m *Manager) driveUpdater(ctx context.Context, u driver.Updater) (err error) {
+//
+// The update operation is returned if one was created.
+func (m *Manager) driveUpdater(ctx context.Context, u driver.Updater) (op *driver.UpdateOperation, err error) {
 	var newFP driver.Fingerprint
 	updateTime := time.Now()
 	defer func() {
@@ -360,6 +383,14 @@ func (m *Manager) driveUpdater(ctx context.Context, u driver.Updater) (err error
 			err = fmt.Errorf("vulnerability database parse failed: %v", err)
 			return
 		}
+		if age := m.maxAgeFor(name); age > 0 {
+			n := len(vulns)
+			vulns = filterAge(vulns, time.Now().Add(-age))
+			zlog.Debug(ctx).
+				Int("discarded", n-len(vulns)).
+				Stringer("max_age", age).
+				Msg("discarded old vulnerabilities")
+		}
 
 		ref, err = m.store.UpdateVulnerabilities(ctx, name, newFP, vulns)
 	}
@@ -370,7 +401,35 @@ func (m *Manager) driveUpdater(ctx context.Context, u driver.Updater) (err error
 	zlog.Info(ctx).
 		Str("ref", ref.String()).
 		Msg("successful update")
-	return nil
+	return &driver.UpdateOperation{
+		Ref:         ref,
+		Updater:     name,
+		Fingerprint: newFP,
+		Date:        updateTime,
+		Kind:        uoKind,
+	}, nil
+}
+
+// MaxAgeFor returns the age limit for the updater "name", or 0 if there isn't
+// one.
+func (m *Manager) maxAgeFor(name string) time.Duration {
+	if d, ok := m.maxAges[name]; ok {
+		return d
+	}
+	return m.maxAge
+}
+
+// FilterAge removes the vulnerabilities issued before "cutoff" from "vs",
+// reusing its backing array. Vulnerabilities with an unknown issued date are
+// kept.
+func filterAge(vs []*claircore.Vulnerability, cutoff time.Time) []*claircore.Vulnerability {
+	out := vs[:0]
+	for _, v := range vs {
+		if v.Issued.IsZero() || !v.Issued.Before(cutoff) {
+			out = append(out, v)
+		}
+	}
+	return out
 }
 
 // NoopConfig is used when an explicit config is not provided.
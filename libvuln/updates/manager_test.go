@@ -0,0 +1,52 @@
+package updates
+
+import (
+	"testing"
+	"time"
+
+	"github.com/quay/claircore"
+)
+
+func TestFilterAge(t *testing.T) {
+	const d = 90 * 24 * time.Hour
+	now := time.Now()
+	cutoff := now.Add(-d)
+	vs := []*claircore.Vulnerability{
+		{Name: "unknown"},
+		{Name: "new", Issued: now.Add(-time.Hour)},
+		{Name: "boundary", Issued: cutoff},
+		{Name: "just-old", Issued: cutoff.Add(-time.Nanosecond)},
+		{Name: "old", Issued: now.Add(-2 * d)},
+	}
+	got := filterAge(vs, cutoff)
+	want := []string{"unknown", "new", "boundary"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d vulnerabilities, want %d", len(got), len(want))
+	}
+	for i, v := range got {
+		if v.Name != want[i] {
+			t.Errorf("%d: got %q, want %q", i, v.Name, want[i])
+		}
+	}
+}
+
+func TestMaxAgeFor(t *testing.T) {
+	const d = 90 * 24 * time.Hour
+	var m Manager
+	for _, o := range []ManagerOption{
+		WithMaxAge(d),
+		WithUpdaterMaxAge("rhel-8", 7*24*time.Hour),
+		WithUpdaterMaxAge("osv", 0),
+	} {
+		o(&m)
+	}
+	for name, want := range map[string]time.Duration{
+		"rhel-8": 7 * 24 * time.Hour,
+		"osv":    0,
+		"alpine": d,
+	} {
+		if got := m.maxAgeFor(name); got != want {
+			t.Errorf("%s: got %v, want %v", name, got, want)
+		}
+	}
+}
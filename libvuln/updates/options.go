@@ -1,6 +1,7 @@
 package updates
 
 import (
+	"context"
 	"time"
 
 	"github.com/quay/claircore/libvuln/driver"
@@ -99,3 +100,48 @@ func WithFactories(f map[string]driver.UpdaterSetFactory) ManagerOption {
 		m.factories = f
 	}
 }
+
+// WithMaxAge configures the Manager to discard vulnerabilities issued more
+// than "d" before an update runs, for policies that accept old findings as
+// known risks.
+//
+// The age is taken from [claircore.Vulnerability.Issued], which updaters
+// populate from the advisory's issued or published date; for OVAL databases,
+// that's the "<advisory><issued>" element. Vulnerabilities without an issued
+// date are always kept.
+//
+// This applies to the vulnerabilities from every updater not configured with
+// WithUpdaterMaxAge, but not to enrichments.
+func WithMaxAge(d time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.maxAge = d
+	}
+}
+
+// WithUpdaterMaxAge configures the age limit for the updater named "name",
+// overriding WithMaxAge. A zero duration disables the filter for that
+// updater.
+func WithUpdaterMaxAge(name string, d time.Duration) ManagerOption {
+	return func(m *Manager) {
+		if m.maxAges == nil {
+			m.maxAges = make(map[string]time.Duration)
+		}
+		m.maxAges[name] = d
+	}
+}
+
+// UpdateHook is called with the update operations created by a run of the
+// Manager, sorted by updater name. It's not called for runs that didn't
+// create any, such as when every updater reported its data was unchanged.
+type UpdateHook func(context.Context, []driver.UpdateOperation)
+
+// WithUpdateHook configures the Manager to call "f" at the end of each run,
+// after garbage collection.
+//
+// The hook is called synchronously, so a hook that does slow work should
+// hand it off to another goroutine.
+func WithUpdateHook(f UpdateHook) ManagerOption {
+	return func(m *Manager) {
+		m.hook = f
+	}
+}
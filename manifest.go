@@ -8,4 +8,8 @@ type Manifest struct {
 	Hash Digest `json:"hash"`
 	// an array of filesystem layers indexed in the same order as the cooresponding image
 	Layers []*Layer `json:"layers"`
+	// Config optionally describes the image's configuration blob. If
+	// present, the configuration is fetched and parsed into the IndexReport.
+	// The MediaType field is unused.
+	Config *LayerDescription `json:"config,omitempty"`
 }
@@ -31,6 +31,13 @@ type Package struct {
 	Arch string `json:"arch,omitempty"`
 	// CPE name for package
 	CPE cpe.WFN `json:"cpe,omitempty"`
+	// the paths of the files installed by this package, if the scanner could find them
+	// and file attribution is enabled. these are reported in the IndexReport's
+	// PackageFiles map instead of inline.
+	Files []string `json:"-"`
+	// details about the ELF binary this package describes, for packages reported by
+	// scanners that inventory executables and shared objects.
+	ELF *ELFInfo `json:"elf,omitempty"`
 }
 
 const (
@@ -0,0 +1,161 @@
+// Package cvss implements parsing, scoring, and comparison of CVSS vectors.
+//
+// Versions 2.0, 3.0, 3.1, and 4.0 of the Common Vulnerability Scoring System
+// are supported. Base scores are computed for every version, and CVSS v3
+// vectors additionally compute temporal and environmental scores. Otherwise,
+// temporal, threat, environmental, and supplemental metrics are validated and
+// preserved, but do not affect the score.
+package cvss
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// ErrMalformed is returned by the Parse functions when a vector is not
+// well-formed for its version.
+var ErrMalformed = errors.New("cvss: malformed vector")
+
+// Vector is a parsed CVSS vector of any version.
+type Vector interface {
+	// Version reports the CVSS version of the vector, like "3.1".
+	Version() string
+	// BaseScore computes the base score of the vector, in the range [0, 10].
+	BaseScore() float64
+	// Severity reports the qualitative severity of the base score, using the
+	// labels the NVD uses for the vector's version.
+	Severity() string
+	// String returns the vector string, with metrics in the order the
+	// specification lists them.
+	String() string
+}
+
+// Parse parses a CVSS vector, detecting the version from its prefix.
+//
+// A vector starting with "CVSS:3.0/" or "CVSS:3.1/" is parsed as a CVSS v3
+// vector, one starting with "CVSS:4.0/" as a CVSS v4 vector, and one starting
+// with "AV:" (optionally enclosed in parentheses) as a CVSS v2 vector.
+func Parse(s string) (Vector, error) {
+	switch {
+	case strings.HasPrefix(s, "CVSS:3."):
+		return ParseV3(s)
+	case strings.HasPrefix(s, "CVSS:4."):
+		return ParseV4(s)
+	case strings.HasPrefix(s, "AV:"), strings.HasPrefix(s, "(AV:"):
+		return ParseV2(s)
+	}
+	return nil, fmt.Errorf("%w: %q: unknown version", ErrMalformed, s)
+}
+
+// Compare orders two vectors by base score, returning -1 if "a" is less
+// severe than "b", 1 if it is more severe, and 0 if the scores are equal.
+//
+// Vectors of different versions are compared by score alone.
+func Compare(a, b Vector) int {
+	as, bs := a.BaseScore(), b.BaseScore()
+	switch {
+	case as < bs:
+		return -1
+	case as > bs:
+		return 1
+	}
+	return 0
+}
+
+// Severity labels, as used by the NVD.
+const (
+	SeverityNone     = "None"
+	SeverityLow      = "Low"
+	SeverityMedium   = "Medium"
+	SeverityHigh     = "High"
+	SeverityCritical = "Critical"
+)
+
+// Severity returns the qualitative severity rating for a CVSS v3 or v4 score.
+func severity(score float64) string {
+	switch {
+	case score == 0:
+		return SeverityNone
+	case score < 4:
+		return SeverityLow
+	case score < 7:
+		return SeverityMedium
+	case score < 9:
+		return SeverityHigh
+	}
+	return SeverityCritical
+}
+
+// Metric describes one metric of a vector.
+type metric struct {
+	Name   string
+	Values []string
+	// Required is set for base metrics, which must be present.
+	Required bool
+}
+
+// Metrics is a list of metrics, in specification order.
+type metrics []metric
+
+// Parse parses the "/"-separated metrics in "s" and returns their values,
+// indexed in the same order as the receiver. Absent metrics are reported as
+// the empty string.
+//
+// The "vec" argument is the complete vector, used for error messages.
+func (ms metrics) parse(vec, s string) ([]string, error) {
+	vals := make([]string, len(ms))
+	if s == "" {
+		return nil, fmt.Errorf("%w: %q: no metrics", ErrMalformed, vec)
+	}
+Metric:
+	for _, m := range strings.Split(s, "/") {
+		n, v, ok := strings.Cut(m, ":")
+		if !ok {
+			return nil, fmt.Errorf("%w: %q: bad metric %q", ErrMalformed, vec, m)
+		}
+		for i := range ms {
+			if ms[i].Name != n {
+				continue
+			}
+			if vals[i] != "" {
+				return nil, fmt.Errorf("%w: %q: duplicate metric %q", ErrMalformed, vec, n)
+			}
+			for _, ok := range ms[i].Values {
+				if v == ok {
+					vals[i] = v
+					continue Metric
+				}
+			}
+			return nil, fmt.Errorf("%w: %q: bad value for metric %q: %q", ErrMalformed, vec, n, v)
+		}
+		return nil, fmt.Errorf("%w: %q: unknown metric %q", ErrMalformed, vec, n)
+	}
+	for i := range ms {
+		if ms[i].Required && vals[i] == "" {
+			return nil, fmt.Errorf("%w: %q: missing metric %q", ErrMalformed, vec, ms[i].Name)
+		}
+	}
+	return vals, nil
+}
+
+// Format writes the present metrics to "b", in specification order.
+func (ms metrics) format(b *strings.Builder, vals []string) {
+	for i, v := range vals {
+		if v == "" {
+			continue
+		}
+		if b.Len() != 0 {
+			b.WriteByte('/')
+		}
+		b.WriteString(ms[i].Name)
+		b.WriteByte(':')
+		b.WriteString(v)
+	}
+}
+
+// RoundTenth rounds "f" to one decimal place.
+func roundTenth(f float64) float64 {
+	return math.Round(f*10) / 10
+}
@@ -0,0 +1,357 @@
+package cvss
+
+import (
+	"errors"
+	"testing"
+)
+
+type scoreTestcase struct {
+	Vector   string
+	Score    float64
+	Severity string
+}
+
+func (tc scoreTestcase) Run(t *testing.T) {
+	t.Run(tc.Vector, func(t *testing.T) {
+		v, err := Parse(tc.Vector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := v.BaseScore(), tc.Score; got != want {
+			t.Errorf("score: got: %v, want: %v", got, want)
+		}
+		if got, want := v.Severity(), tc.Severity; got != want {
+			t.Errorf("severity: got: %q, want: %q", got, want)
+		}
+		if got, want := v.String(), tc.Vector; got != want {
+			t.Errorf("string: got: %q, want: %q", got, want)
+		}
+	})
+}
+
+func TestV2(t *testing.T) {
+	t.Parallel()
+	// Examples from section 3.3 of the CVSS v2 specification.
+	tcs := []scoreTestcase{
+		{"AV:N/AC:L/Au:N/C:N/I:N/A:C", 7.8, SeverityHigh},   // CVE-2002-0392
+		{"AV:N/AC:L/Au:N/C:C/I:C/A:C", 10.0, SeverityHigh},  // CVE-2003-0818
+		{"AV:L/AC:H/Au:N/C:C/I:C/A:C", 6.2, SeverityMedium}, // CVE-2003-0062
+		{"AV:N/AC:L/Au:N/C:P/I:N/A:N", 5.0, SeverityMedium},
+		{"AV:N/AC:M/Au:N/C:N/I:N/A:P", 4.3, SeverityMedium},
+		{"AV:L/AC:L/Au:N/C:P/I:N/A:N", 2.1, SeverityLow},
+		{"AV:N/AC:L/Au:N/C:N/I:N/A:N", 0, SeverityLow},
+		{"AV:N/AC:L/Au:N/C:N/I:N/A:C/E:F/RL:OF/RC:C", 7.8, SeverityHigh},
+	}
+	for _, tc := range tcs {
+		tc.Run(t)
+	}
+	v, err := Parse("(AV:N/AC:L/Au:N/C:P/I:N/A:N)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := v.String(), "AV:N/AC:L/Au:N/C:P/I:N/A:N"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+}
+
+func TestV3(t *testing.T) {
+	t.Parallel()
+	// Examples from the "CVSS v3.1 Examples" document.
+	tcs := []scoreTestcase{
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N", 6.1, SeverityMedium},   // CVE-2013-1937
+		{"CVSS:3.1/AV:N/AC:L/PR:L/UI:N/S:C/C:L/I:L/A:N", 6.4, SeverityMedium},   // CVE-2013-0375
+		{"CVSS:3.1/AV:N/AC:H/PR:N/UI:R/S:U/C:L/I:N/A:N", 3.1, SeverityLow},      // CVE-2014-3566
+		{"CVSS:3.1/AV:N/AC:L/PR:L/UI:N/S:C/C:H/I:H/A:H", 9.9, SeverityCritical}, // CVE-2012-1516
+		{"CVSS:3.1/AV:L/AC:L/PR:H/UI:N/S:U/C:L/I:L/A:L", 4.2, SeverityMedium},   // CVE-2009-0783
+		{"CVSS:3.1/AV:N/AC:L/PR:L/UI:N/S:U/C:H/I:H/A:H", 8.8, SeverityHigh},     // CVE-2012-0384
+		{"CVSS:3.1/AV:L/AC:L/PR:N/UI:R/S:U/C:H/I:H/A:H", 7.8, SeverityHigh},     // CVE-2015-1098
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N", 7.5, SeverityHigh},     // CVE-2014-0160
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", 9.8, SeverityCritical}, // CVE-2014-6271
+		{"CVSS:3.1/AV:N/AC:H/PR:N/UI:N/S:C/C:N/I:H/A:N", 6.8, SeverityMedium},   // CVE-2008-1447
+		{"CVSS:3.1/AV:P/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", 6.8, SeverityMedium},   // CVE-2014-2005
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:C/C:L/I:N/A:N", 5.8, SeverityMedium},   // CVE-2010-0467
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:C/C:N/I:L/A:N", 5.8, SeverityMedium},   // CVE-2012-1342
+		{"CVSS:3.1/AV:N/AC:L/PR:L/UI:R/S:C/C:L/I:L/A:N", 5.4, SeverityMedium},   // CVE-2014-9253
+		{"CVSS:3.1/AV:A/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", 8.8, SeverityHigh},     // CVE-2011-1265
+		{"CVSS:3.1/AV:P/AC:L/PR:N/UI:N/S:U/C:N/I:H/A:N", 4.6, SeverityMedium},   // CVE-2014-2019
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:U/C:H/I:H/A:H", 8.8, SeverityHigh},     // CVE-2015-0970
+		{"CVSS:3.1/AV:N/AC:H/PR:N/UI:N/S:U/C:H/I:H/A:N", 7.4, SeverityHigh},     // CVE-2014-0224
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:H/I:H/A:H", 9.6, SeverityCritical}, // CVE-2012-5376
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:U/C:H/I:H/A:H", 8.8, SeverityHigh},     // CVE-2016-1645
+		{"CVSS:3.1/AV:N/AC:H/PR:N/UI:R/S:U/C:H/I:H/A:N", 6.8, SeverityMedium},   // CVE-2016-0128
+		{"CVSS:3.1/AV:N/AC:H/PR:N/UI:R/S:U/C:H/I:H/A:H", 7.5, SeverityHigh},     // CVE-2016-2118
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:N/I:N/A:N", 0, SeverityNone},
+		{"CVSS:3.0/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N", 6.1, SeverityMedium},
+		{"CVSS:3.0/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", 9.8, SeverityCritical},
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N/E:U/RL:O/RC:C", 7.5, SeverityHigh},
+	}
+	for _, tc := range tcs {
+		tc.Run(t)
+	}
+}
+
+func TestV3Temporal(t *testing.T) {
+	t.Parallel()
+	// Base vectors from the "CVSS v3.1 Examples" document. The examples only
+	// include base metrics, so the expected scores are computed per section 7
+	// of the v3.1 specification.
+	tcs := []struct {
+		Base  string
+		TM    TemporalMetrics
+		Want  string
+		Score float64
+	}{
+		{
+			Base:  "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N", // CVE-2014-0160
+			TM:    TemporalMetrics{ExploitCodeMaturity: ExploitHigh, RemediationLevel: RemediationOfficialFix, ReportConfidence: ConfidenceConfirmed},
+			Want:  "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N/E:H/RL:O/RC:C",
+			Score: 7.2,
+		},
+		{
+			Base:  "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N", // CVE-2014-0160
+			TM:    TemporalMetrics{ExploitCodeMaturity: ExploitUnproven, RemediationLevel: RemediationOfficialFix},
+			Want:  "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N/E:U/RL:O",
+			Score: 6.5,
+		},
+		{
+			Base:  "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", // CVE-2014-6271
+			TM:    TemporalMetrics{ExploitCodeMaturity: ExploitFunctional, RemediationLevel: RemediationOfficialFix},
+			Want:  "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H/E:F/RL:O",
+			Score: 9.1,
+		},
+		{
+			Base:  "CVSS:3.1/AV:N/AC:L/PR:L/UI:N/S:C/C:H/I:H/A:H", // CVE-2012-1516
+			TM:    TemporalMetrics{},
+			Want:  "CVSS:3.1/AV:N/AC:L/PR:L/UI:N/S:C/C:H/I:H/A:H",
+			Score: 9.9,
+		},
+		{
+			Base:  "CVSS:3.1/AV:N/AC:L/PR:L/UI:N/S:C/C:H/I:H/A:H", // CVE-2012-1516
+			TM:    TemporalMetrics{ExploitCodeMaturity: ExploitCodeMaturity("Z")},
+			Want:  "CVSS:3.1/AV:N/AC:L/PR:L/UI:N/S:C/C:H/I:H/A:H",
+			Score: 9.9,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.Want, func(t *testing.T) {
+			v, err := ParseV3(tc.Base)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tv := v.WithTemporalMetrics(tc.TM)
+			if got, want := tv.String(), tc.Want; got != want {
+				t.Errorf("string: got: %q, want: %q", got, want)
+			}
+			if got, want := tv.TemporalScore(), tc.Score; got != want {
+				t.Errorf("score: got: %v, want: %v", got, want)
+			}
+			if got, want := v.String(), tc.Base; got != want {
+				t.Errorf("original modified: got: %q, want: %q", got, want)
+			}
+			pv, err := ParseV3(tc.Want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := pv.TemporalScore(), tc.Score; got != want {
+				t.Errorf("parsed score: got: %v, want: %v", got, want)
+			}
+		})
+	}
+}
+
+func TestV3Environmental(t *testing.T) {
+	t.Parallel()
+	// Base vectors from the "CVSS v3.1 Examples" document, with expected
+	// scores computed per section 7 of the v3.1 specification.
+	tcs := []struct {
+		Base  string
+		TM    TemporalMetrics
+		EM    EnvironmentalMetrics
+		Want  string
+		Score float64
+	}{
+		{
+			Base: "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N", // CVE-2014-0160
+			TM:   TemporalMetrics{ExploitCodeMaturity: ExploitHigh, RemediationLevel: RemediationOfficialFix, ReportConfidence: ConfidenceConfirmed},
+			EM: EnvironmentalMetrics{
+				ConfidentialityRequirement: RequirementHigh,
+				IntegrityRequirement:       RequirementLow,
+				AvailabilityRequirement:    RequirementLow,
+			},
+			Want:  "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N/E:H/RL:O/RC:C/CR:H/IR:L/AR:L",
+			Score: 8.9,
+		},
+		{
+			Base: "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", // CVE-2014-6271
+			EM: EnvironmentalMetrics{
+				ModifiedAttackVector:       AttackVectorAdjacent,
+				ModifiedPrivilegesRequired: PrivilegesRequiredLow,
+			},
+			Want:  "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H/MAV:A/MPR:L",
+			Score: 8.0,
+		},
+		{
+			Base: "CVSS:3.1/AV:N/AC:L/PR:L/UI:N/S:C/C:H/I:H/A:H", // CVE-2012-1516
+			TM:   TemporalMetrics{ExploitCodeMaturity: ExploitProofOfConcept, RemediationLevel: RemediationTemporaryFix, ReportConfidence: ConfidenceReasonable},
+			EM: EnvironmentalMetrics{
+				ConfidentialityRequirement: RequirementLow,
+				IntegrityRequirement:       RequirementLow,
+				AvailabilityRequirement:    RequirementLow,
+			},
+			Want:  "CVSS:3.1/AV:N/AC:L/PR:L/UI:N/S:C/C:H/I:H/A:H/E:P/RL:T/RC:R/CR:L/IR:L/AR:L",
+			Score: 7.2,
+		},
+		{
+			Base:  "CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N", // CVE-2013-1937
+			EM:    EnvironmentalMetrics{ModifiedScope: ScopeUnchanged},
+			Want:  "CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N/MS:U",
+			Score: 5.4,
+		},
+		{
+			Base: "CVSS:3.1/AV:L/AC:L/PR:N/UI:R/S:U/C:H/I:H/A:H", // CVE-2015-1098
+			EM: EnvironmentalMetrics{
+				ModifiedConfidentiality: ImpactNone,
+				ModifiedIntegrity:       ImpactNone,
+				ModifiedAvailability:    ImpactNone,
+			},
+			Want:  "CVSS:3.1/AV:L/AC:L/PR:N/UI:R/S:U/C:H/I:H/A:H/MC:N/MI:N/MA:N",
+			Score: 0,
+		},
+		{
+			Base: "CVSS:3.1/AV:N/AC:H/PR:N/UI:N/S:C/C:N/I:H/A:N", // CVE-2008-1447
+			TM:   TemporalMetrics{ExploitCodeMaturity: ExploitUnproven, RemediationLevel: RemediationWorkaround, ReportConfidence: ConfidenceUnknown},
+			EM: EnvironmentalMetrics{
+				IntegrityRequirement:     RequirementHigh,
+				ModifiedAttackComplexity: AttackComplexityLow,
+			},
+			Want:  "CVSS:3.1/AV:N/AC:H/PR:N/UI:N/S:C/C:N/I:H/A:N/E:U/RL:W/RC:U/IR:H/MAC:L",
+			Score: 8.2,
+		},
+		{
+			Base:  "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", // CVE-2014-6271
+			EM:    EnvironmentalMetrics{ModifiedAttackVector: AttackVectorNotDefined},
+			Want:  "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H/MAV:X",
+			Score: 9.8,
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.Want, func(t *testing.T) {
+			v, err := ParseV3(tc.Base)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ev := v.WithTemporalMetrics(tc.TM).WithEnvironmentalMetrics(tc.EM)
+			if got, want := ev.String(), tc.Want; got != want {
+				t.Errorf("string: got: %q, want: %q", got, want)
+			}
+			if got, want := ev.EnvironmentalScore(), tc.Score; got != want {
+				t.Errorf("score: got: %v, want: %v", got, want)
+			}
+			pv, err := ParseV3(tc.Want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := pv.EnvironmentalScore(), tc.Score; got != want {
+				t.Errorf("parsed score: got: %v, want: %v", got, want)
+			}
+		})
+	}
+}
+
+func TestV4(t *testing.T) {
+	t.Parallel()
+	tcs := []scoreTestcase{
+		{"CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:H/VI:H/VA:H/SC:H/SI:H/SA:H", 10.0, SeverityCritical},
+		{"CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:H/VI:H/VA:H/SC:N/SI:N/SA:N", 9.3, SeverityCritical},
+		{"CVSS:4.0/AV:N/AC:L/AT:N/PR:L/UI:N/VC:H/VI:H/VA:H/SC:N/SI:N/SA:N", 8.7, SeverityHigh},
+		{"CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:H/VI:N/VA:N/SC:N/SI:N/SA:N", 8.7, SeverityHigh},
+		{"CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:N/VI:N/VA:H/SC:N/SI:N/SA:N", 8.7, SeverityHigh},
+		{"CVSS:4.0/AV:L/AC:L/AT:N/PR:L/UI:N/VC:H/VI:H/VA:H/SC:N/SI:N/SA:N", 8.5, SeverityHigh},
+		{"CVSS:4.0/AV:L/AC:L/AT:N/PR:N/UI:P/VC:H/VI:H/VA:H/SC:N/SI:N/SA:N", 8.5, SeverityHigh},
+		{"CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:N/VI:N/VA:N/SC:N/SI:N/SA:N", 0, SeverityNone},
+		{"CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:H/VI:H/VA:H/SC:N/SI:N/SA:N/E:U/CR:L", 9.3, SeverityCritical},
+	}
+	for _, tc := range tcs {
+		tc.Run(t)
+	}
+}
+
+// TestV4Lookup checks the shape of the macro vector table.
+func TestV4Lookup(t *testing.T) {
+	t.Parallel()
+	var n int
+	for eq1 := 0; eq1 < 3; eq1++ {
+		for eq2 := 0; eq2 < 2; eq2++ {
+			for eq3 := 0; eq3 < 3; eq3++ {
+				for eq4 := 0; eq4 < 3; eq4++ {
+					for eq5 := 0; eq5 < 3; eq5++ {
+						for eq6 := 0; eq6 < 2; eq6++ {
+							if eq3 == 2 && eq6 == 0 {
+								continue
+							}
+							n++
+							k := v4MacroKey([6]int{eq1, eq2, eq3, eq4, eq5, eq6})
+							if _, ok := v4Lookup[k]; !ok {
+								t.Errorf("missing macro vector %q", k)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	if got, want := len(v4Lookup), n; got != want {
+		t.Errorf("got: %d entries, want: %d", got, want)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	t.Parallel()
+	for _, in := range []string{
+		"",
+		"CVSS:2.0/AV:N",
+		"CVSS:3.2/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N",
+		"CVSS:3.1/",
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N",
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N/A:N",
+		"CVSS:3.1/AV:X/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N",
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N/Q:X",
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A",
+		"CVSS:4.0/AV:N/AC:L/PR:N/UI:N/VC:H/VI:H/VA:H/SC:N/SI:N/SA:N",
+		"CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:H/VI:H/VA:H/SC:N/SI:S/SA:N",
+		"AV:N/AC:L/Au:N/C:P/I:N",
+		"AV:N/AC:L/Au:N/C:P/I:N/A:N/",
+	} {
+		_, err := Parse(in)
+		t.Logf("%q: %v", in, err)
+		if !errors.Is(err, ErrMalformed) {
+			t.Errorf("%q: unexpected error: %v", in, err)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	t.Parallel()
+	parse := func(s string) Vector {
+		v, err := Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tcs := []struct {
+		A, B string
+		Want int
+	}{
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N", "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", -1},
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N", 1},
+		{"CVSS:3.1/AV:N/AC:L/PR:L/UI:N/S:U/C:H/I:H/A:H", "CVSS:3.1/AV:A/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", 0},
+		{"AV:N/AC:L/Au:N/C:C/I:C/A:C", "CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:H/VI:H/VA:H/SC:N/SI:N/SA:N", 1},
+		{"CVSS:4.0/AV:N/AC:L/AT:N/PR:L/UI:N/VC:H/VI:H/VA:H/SC:N/SI:N/SA:N", "CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:U/C:H/I:H/A:H", -1},
+	}
+	for _, tc := range tcs {
+		if got, want := Compare(parse(tc.A), parse(tc.B)), tc.Want; got != want {
+			t.Errorf("Compare(%q, %q): got: %d, want: %d", tc.A, tc.B, got, want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package cvss
+
+import (
+	"strings"
+)
+
+// V2 is a CVSS v2.0 vector.
+type V2 struct {
+	vals []string
+}
+
+var _ Vector = (*V2)(nil)
+
+// Indexes into v2Metrics.
+const (
+	v2AV = iota
+	v2AC
+	v2Au
+	v2C
+	v2I
+	v2A
+)
+
+var v2Metrics = metrics{
+	{Name: "AV", Values: []string{"L", "A", "N"}, Required: true},
+	{Name: "AC", Values: []string{"H", "M", "L"}, Required: true},
+	{Name: "Au", Values: []string{"M", "S", "N"}, Required: true},
+	{Name: "C", Values: []string{"N", "P", "C"}, Required: true},
+	{Name: "I", Values: []string{"N", "P", "C"}, Required: true},
+	{Name: "A", Values: []string{"N", "P", "C"}, Required: true},
+	// Temporal
+	{Name: "E", Values: []string{"U", "POC", "F", "H", "ND"}},
+	{Name: "RL", Values: []string{"OF", "TF", "W", "U", "ND"}},
+	{Name: "RC", Values: []string{"UC", "UR", "C", "ND"}},
+	// Environmental
+	{Name: "CDP", Values: []string{"N", "L", "LM", "MH", "H", "ND"}},
+	{Name: "TD", Values: []string{"N", "L", "M", "H", "ND"}},
+	{Name: "CR", Values: []string{"L", "M", "H", "ND"}},
+	{Name: "IR", Values: []string{"L", "M", "H", "ND"}},
+	{Name: "AR", Values: []string{"L", "M", "H", "ND"}},
+}
+
+// ParseV2 parses a CVSS v2.0 vector, like "AV:N/AC:L/Au:N/C:P/I:N/A:N".
+//
+// The vector may be enclosed in parentheses, as the NVD used to.
+func ParseV2(s string) (*V2, error) {
+	in := s
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		in = s[1 : len(s)-1]
+	}
+	vals, err := v2Metrics.parse(s, in)
+	if err != nil {
+		return nil, err
+	}
+	return &V2{vals: vals}, nil
+}
+
+// Version implements Vector.
+func (*V2) Version() string { return "2.0" }
+
+// String implements Vector.
+func (v *V2) String() string {
+	var b strings.Builder
+	v2Metrics.format(&b, v.vals)
+	return b.String()
+}
+
+// Weights for the CVSS v2 base metrics, from section 3.2.1 of the
+// specification.
+var (
+	v2WeightAV  = map[string]float64{"L": 0.395, "A": 0.646, "N": 1.0}
+	v2WeightAC  = map[string]float64{"H": 0.35, "M": 0.61, "L": 0.71}
+	v2WeightAu  = map[string]float64{"M": 0.45, "S": 0.56, "N": 0.704}
+	v2WeightCIA = map[string]float64{"N": 0, "P": 0.275, "C": 0.660}
+)
+
+// BaseScore implements Vector.
+func (v *V2) BaseScore() float64 {
+	impact := 10.41 * (1 -
+		(1-v2WeightCIA[v.vals[v2C]])*
+			(1-v2WeightCIA[v.vals[v2I]])*
+			(1-v2WeightCIA[v.vals[v2A]]))
+	exploitability := 20 *
+		v2WeightAV[v.vals[v2AV]] *
+		v2WeightAC[v.vals[v2AC]] *
+		v2WeightAu[v.vals[v2Au]]
+	if impact == 0 {
+		return 0
+	}
+	return roundTenth(((0.6 * impact) + (0.4 * exploitability) - 1.5) * 1.176)
+}
+
+// Severity implements Vector.
+//
+// The NVD does not use the "None" or "Critical" labels for CVSS v2 scores.
+func (v *V2) Severity() string {
+	switch s := v.BaseScore(); {
+	case s < 4:
+		return SeverityLow
+	case s < 7:
+		return SeverityMedium
+	}
+	return SeverityHigh
+}
@@ -0,0 +1,168 @@
+package cvss
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// V3 is a CVSS v3.0 or v3.1 vector.
+type V3 struct {
+	minor int
+	vals  []string
+}
+
+var _ Vector = (*V3)(nil)
+
+// Indexes into v3Metrics.
+const (
+	v3AV = iota
+	v3AC
+	v3PR
+	v3UI
+	v3S
+	v3C
+	v3I
+	v3A
+	v3E
+	v3RL
+	v3RC
+	v3CR
+	v3IR
+	v3AR
+	v3MAV
+	v3MAC
+	v3MPR
+	v3MUI
+	v3MS
+	v3MC
+	v3MI
+	v3MA
+)
+
+var v3Metrics = metrics{
+	{Name: "AV", Values: []string{"N", "A", "L", "P"}, Required: true},
+	{Name: "AC", Values: []string{"L", "H"}, Required: true},
+	{Name: "PR", Values: []string{"N", "L", "H"}, Required: true},
+	{Name: "UI", Values: []string{"N", "R"}, Required: true},
+	{Name: "S", Values: []string{"U", "C"}, Required: true},
+	{Name: "C", Values: []string{"H", "L", "N"}, Required: true},
+	{Name: "I", Values: []string{"H", "L", "N"}, Required: true},
+	{Name: "A", Values: []string{"H", "L", "N"}, Required: true},
+	// Temporal
+	{Name: "E", Values: []string{"X", "H", "F", "P", "U"}},
+	{Name: "RL", Values: []string{"X", "U", "W", "T", "O"}},
+	{Name: "RC", Values: []string{"X", "C", "R", "U"}},
+	// Environmental
+	{Name: "CR", Values: []string{"X", "H", "M", "L"}},
+	{Name: "IR", Values: []string{"X", "H", "M", "L"}},
+	{Name: "AR", Values: []string{"X", "H", "M", "L"}},
+	{Name: "MAV", Values: []string{"X", "N", "A", "L", "P"}},
+	{Name: "MAC", Values: []string{"X", "L", "H"}},
+	{Name: "MPR", Values: []string{"X", "N", "L", "H"}},
+	{Name: "MUI", Values: []string{"X", "N", "R"}},
+	{Name: "MS", Values: []string{"X", "U", "C"}},
+	{Name: "MC", Values: []string{"X", "H", "L", "N"}},
+	{Name: "MI", Values: []string{"X", "H", "L", "N"}},
+	{Name: "MA", Values: []string{"X", "H", "L", "N"}},
+}
+
+// ParseV3 parses a CVSS v3.0 or v3.1 vector, like
+// "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N".
+func ParseV3(s string) (*V3, error) {
+	var v V3
+	var rest string
+	switch {
+	case strings.HasPrefix(s, "CVSS:3.0/"):
+		v.minor = 0
+		rest = s[len("CVSS:3.0/"):]
+	case strings.HasPrefix(s, "CVSS:3.1/"):
+		v.minor = 1
+		rest = s[len("CVSS:3.1/"):]
+	default:
+		return nil, fmt.Errorf("%w: %q: not a CVSS v3 vector", ErrMalformed, s)
+	}
+	var err error
+	v.vals, err = v3Metrics.parse(s, rest)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// Version implements Vector.
+func (v *V3) Version() string { return fmt.Sprintf("3.%d", v.minor) }
+
+// String implements Vector.
+func (v *V3) String() string {
+	var b strings.Builder
+	b.WriteString("CVSS:")
+	b.WriteString(v.Version())
+	v3Metrics.format(&b, v.vals)
+	return b.String()
+}
+
+// Weights for the CVSS v3 base metrics, from section 7.4 of the
+// specification.
+var (
+	v3WeightAV  = map[string]float64{"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2}
+	v3WeightAC  = map[string]float64{"L": 0.77, "H": 0.44}
+	v3WeightUI  = map[string]float64{"N": 0.85, "R": 0.62}
+	v3WeightCIA = map[string]float64{"H": 0.56, "L": 0.22, "N": 0}
+	// The weight of the "Privileges Required" metric depends on "Scope".
+	v3WeightPR        = map[string]float64{"N": 0.85, "L": 0.62, "H": 0.27}
+	v3WeightPRChanged = map[string]float64{"N": 0.85, "L": 0.68, "H": 0.5}
+)
+
+// BaseScore implements Vector.
+func (v *V3) BaseScore() float64 {
+	changed := v.vals[v3S] == "C"
+	iss := 1 -
+		(1-v3WeightCIA[v.vals[v3C]])*
+			(1-v3WeightCIA[v.vals[v3I]])*
+			(1-v3WeightCIA[v.vals[v3A]])
+	var impact float64
+	if changed {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	} else {
+		impact = 6.42 * iss
+	}
+	pr := v3WeightPR
+	if changed {
+		pr = v3WeightPRChanged
+	}
+	exploitability := 8.22 *
+		v3WeightAV[v.vals[v3AV]] *
+		v3WeightAC[v.vals[v3AC]] *
+		pr[v.vals[v3PR]] *
+		v3WeightUI[v.vals[v3UI]]
+	if impact <= 0 {
+		return 0
+	}
+	s := impact + exploitability
+	if changed {
+		s *= 1.08
+	}
+	return v.roundup(math.Min(s, 10))
+}
+
+// Roundup returns the smallest number, to one decimal place, that is equal to
+// or higher than its input.
+//
+// CVSS v3.1 redefined the function to avoid floating point errors, see
+// Appendix A of the v3.1 specification.
+func (v *V3) roundup(f float64) float64 {
+	if v.minor == 0 {
+		return math.Ceil(f*10) / 10
+	}
+	i := int64(math.Round(f * 100000))
+	if i%10000 == 0 {
+		return float64(i) / 100000
+	}
+	return float64(i/10000+1) / 10
+}
+
+// Severity implements Vector.
+func (v *V3) Severity() string {
+	return severity(v.BaseScore())
+}
@@ -0,0 +1,286 @@
+package cvss
+
+import "math"
+
+// These are the values of the CVSS v3 Exploit Code Maturity (E) metric.
+const (
+	ExploitNotDefined     ExploitCodeMaturity = "X"
+	ExploitHigh           ExploitCodeMaturity = "H"
+	ExploitFunctional     ExploitCodeMaturity = "F"
+	ExploitProofOfConcept ExploitCodeMaturity = "P"
+	ExploitUnproven       ExploitCodeMaturity = "U"
+)
+
+// ExploitCodeMaturity is a value of the CVSS v3 Exploit Code Maturity (E)
+// metric.
+type ExploitCodeMaturity string
+
+// These are the values of the CVSS v3 Remediation Level (RL) metric.
+const (
+	RemediationNotDefined   RemediationLevel = "X"
+	RemediationUnavailable  RemediationLevel = "U"
+	RemediationWorkaround   RemediationLevel = "W"
+	RemediationTemporaryFix RemediationLevel = "T"
+	RemediationOfficialFix  RemediationLevel = "O"
+)
+
+// RemediationLevel is a value of the CVSS v3 Remediation Level (RL) metric.
+type RemediationLevel string
+
+// These are the values of the CVSS v3 Report Confidence (RC) metric.
+const (
+	ConfidenceNotDefined ReportConfidence = "X"
+	ConfidenceConfirmed  ReportConfidence = "C"
+	ConfidenceReasonable ReportConfidence = "R"
+	ConfidenceUnknown    ReportConfidence = "U"
+)
+
+// ReportConfidence is a value of the CVSS v3 Report Confidence (RC) metric.
+type ReportConfidence string
+
+// These are the values of the CVSS v3 Confidentiality, Integrity, and
+// Availability Requirement (CR, IR, AR) metrics.
+const (
+	RequirementNotDefined SecurityRequirement = "X"
+	RequirementHigh       SecurityRequirement = "H"
+	RequirementMedium     SecurityRequirement = "M"
+	RequirementLow        SecurityRequirement = "L"
+)
+
+// SecurityRequirement is a value of the CVSS v3 Confidentiality, Integrity,
+// and Availability Requirement (CR, IR, AR) metrics.
+type SecurityRequirement string
+
+// These are the values of the CVSS v3 Attack Vector (AV) and Modified Attack
+// Vector (MAV) metrics. AttackVectorNotDefined is only valid for MAV.
+const (
+	AttackVectorNotDefined AttackVector = "X"
+	AttackVectorNetwork    AttackVector = "N"
+	AttackVectorAdjacent   AttackVector = "A"
+	AttackVectorLocal      AttackVector = "L"
+	AttackVectorPhysical   AttackVector = "P"
+)
+
+// AttackVector is a value of the CVSS v3 Attack Vector (AV) and Modified
+// Attack Vector (MAV) metrics.
+type AttackVector string
+
+// These are the values of the CVSS v3 Attack Complexity (AC) and Modified
+// Attack Complexity (MAC) metrics. AttackComplexityNotDefined is only valid for
+// MAC.
+const (
+	AttackComplexityNotDefined AttackComplexity = "X"
+	AttackComplexityLow        AttackComplexity = "L"
+	AttackComplexityHigh       AttackComplexity = "H"
+)
+
+// AttackComplexity is a value of the CVSS v3 Attack Complexity (AC) and
+// Modified Attack Complexity (MAC) metrics.
+type AttackComplexity string
+
+// These are the values of the CVSS v3 Privileges Required (PR) and Modified
+// Privileges Required (MPR) metrics. PrivilegesRequiredNotDefined is only
+// valid for MPR.
+const (
+	PrivilegesRequiredNotDefined PrivilegesRequired = "X"
+	PrivilegesRequiredNone       PrivilegesRequired = "N"
+	PrivilegesRequiredLow        PrivilegesRequired = "L"
+	PrivilegesRequiredHigh       PrivilegesRequired = "H"
+)
+
+// PrivilegesRequired is a value of the CVSS v3 Privileges Required (PR) and
+// Modified Privileges Required (MPR) metrics.
+type PrivilegesRequired string
+
+// These are the values of the CVSS v3 User Interaction (UI) and Modified User
+// Interaction (MUI) metrics. UserInteractionNotDefined is only valid for MUI.
+const (
+	UserInteractionNotDefined UserInteraction = "X"
+	UserInteractionNone       UserInteraction = "N"
+	UserInteractionRequired   UserInteraction = "R"
+)
+
+// UserInteraction is a value of the CVSS v3 User Interaction (UI) and
+// Modified User Interaction (MUI) metrics.
+type UserInteraction string
+
+// These are the values of the CVSS v3 Scope (S) and Modified Scope (MS)
+// metrics. ScopeNotDefined is only valid for MS.
+const (
+	ScopeNotDefined Scope = "X"
+	ScopeUnchanged  Scope = "U"
+	ScopeChanged    Scope = "C"
+)
+
+// Scope is a value of the CVSS v3 Scope (S) and Modified Scope (MS) metrics.
+type Scope string
+
+// These are the values of the CVSS v3 impact metrics (C, I, A) and modified
+// impact metrics (MC, MI, MA). ImpactNotDefined is only valid for the
+// modified metrics.
+const (
+	ImpactNotDefined Impact = "X"
+	ImpactHigh       Impact = "H"
+	ImpactLow        Impact = "L"
+	ImpactNone       Impact = "N"
+)
+
+// Impact is a value of the CVSS v3 Confidentiality, Integrity, and
+// Availability impact metrics (C, I, A) and their modified versions (MC, MI,
+// MA).
+type Impact string
+
+// TemporalMetrics are the CVSS v3 temporal metrics.
+//
+// Fields left empty are not changed by [V3.WithTemporalMetrics].
+type TemporalMetrics struct {
+	ExploitCodeMaturity ExploitCodeMaturity
+	RemediationLevel    RemediationLevel
+	ReportConfidence    ReportConfidence
+}
+
+// EnvironmentalMetrics are the CVSS v3 environmental metrics.
+//
+// Fields left empty are not changed by [V3.WithEnvironmentalMetrics].
+type EnvironmentalMetrics struct {
+	ConfidentialityRequirement SecurityRequirement
+	IntegrityRequirement       SecurityRequirement
+	AvailabilityRequirement    SecurityRequirement
+
+	ModifiedAttackVector       AttackVector
+	ModifiedAttackComplexity   AttackComplexity
+	ModifiedPrivilegesRequired PrivilegesRequired
+	ModifiedUserInteraction    UserInteraction
+	ModifiedScope              Scope
+	ModifiedConfidentiality    Impact
+	ModifiedIntegrity          Impact
+	ModifiedAvailability       Impact
+}
+
+// WithTemporalMetrics returns a copy of the vector with the provided temporal
+// metrics set. Values not defined by the specification are ignored.
+func (v *V3) WithTemporalMetrics(tm TemporalMetrics) *V3 {
+	r := v.clone()
+	r.set(v3E, string(tm.ExploitCodeMaturity))
+	r.set(v3RL, string(tm.RemediationLevel))
+	r.set(v3RC, string(tm.ReportConfidence))
+	return r
+}
+
+// WithEnvironmentalMetrics returns a copy of the vector with the provided
+// environmental metrics set. Values not defined by the specification are
+// ignored.
+func (v *V3) WithEnvironmentalMetrics(em EnvironmentalMetrics) *V3 {
+	r := v.clone()
+	r.set(v3CR, string(em.ConfidentialityRequirement))
+	r.set(v3IR, string(em.IntegrityRequirement))
+	r.set(v3AR, string(em.AvailabilityRequirement))
+	r.set(v3MAV, string(em.ModifiedAttackVector))
+	r.set(v3MAC, string(em.ModifiedAttackComplexity))
+	r.set(v3MPR, string(em.ModifiedPrivilegesRequired))
+	r.set(v3MUI, string(em.ModifiedUserInteraction))
+	r.set(v3MS, string(em.ModifiedScope))
+	r.set(v3MC, string(em.ModifiedConfidentiality))
+	r.set(v3MI, string(em.ModifiedIntegrity))
+	r.set(v3MA, string(em.ModifiedAvailability))
+	return r
+}
+
+// Clone returns a deep copy of the vector.
+func (v *V3) clone() *V3 {
+	return &V3{
+		minor: v.minor,
+		vals:  append([]string(nil), v.vals...),
+	}
+}
+
+// Set sets the metric at index "i" to "val", if it's a valid value.
+func (v *V3) set(i int, val string) {
+	for _, ok := range v3Metrics[i].Values {
+		if val == ok {
+			v.vals[i] = val
+			return
+		}
+	}
+}
+
+// Weights for the CVSS v3 temporal and environmental metrics, from section
+// 7.4 of the specification. "Not Defined" values have a weight of 1.
+var (
+	v3WeightE  = map[string]float64{"H": 1, "F": 0.97, "P": 0.94, "U": 0.91}
+	v3WeightRL = map[string]float64{"U": 1, "W": 0.97, "T": 0.96, "O": 0.95}
+	v3WeightRC = map[string]float64{"C": 1, "R": 0.96, "U": 0.92}
+	v3WeightR  = map[string]float64{"H": 1.5, "M": 1, "L": 0.5}
+)
+
+// Weight returns the weight for "val" from "m", or 1 if "val" is absent or
+// "Not Defined".
+func weight(m map[string]float64, val string) float64 {
+	if w, ok := m[val]; ok {
+		return w
+	}
+	return 1
+}
+
+// TemporalScore computes the temporal score of the vector, in the range
+// [0, 10]. If no temporal metrics are present, this is the same as the base
+// score.
+func (v *V3) TemporalScore() float64 {
+	return v.roundup(v.BaseScore() * v.temporalFactor())
+}
+
+// TemporalFactor is the product of the temporal metric weights.
+func (v *V3) temporalFactor() float64 {
+	return weight(v3WeightE, v.vals[v3E]) *
+		weight(v3WeightRL, v.vals[v3RL]) *
+		weight(v3WeightRC, v.vals[v3RC])
+}
+
+// Modified returns the value of the modified metric at index "i", falling back
+// to the corresponding base metric at index "base" if it's absent or "Not
+// Defined".
+func (v *V3) modified(i, base int) string {
+	if m := v.vals[i]; m != "" && m != "X" {
+		return m
+	}
+	return v.vals[base]
+}
+
+// EnvironmentalScore computes the environmental score of the vector, in the
+// range [0, 10]. The temporal metrics are taken into account.
+func (v *V3) EnvironmentalScore() float64 {
+	changed := v.modified(v3MS, v3S) == "C"
+	miss := math.Min(1-
+		(1-weight(v3WeightR, v.vals[v3CR])*v3WeightCIA[v.modified(v3MC, v3C)])*
+			(1-weight(v3WeightR, v.vals[v3IR])*v3WeightCIA[v.modified(v3MI, v3I)])*
+			(1-weight(v3WeightR, v.vals[v3AR])*v3WeightCIA[v.modified(v3MA, v3A)]),
+		0.915)
+	var impact float64
+	switch {
+	case !changed:
+		impact = 6.42 * miss
+	case v.minor == 0:
+		impact = 7.52*(miss-0.029) - 3.25*math.Pow(miss-0.02, 15)
+	default:
+		// CVSS v3.1 changed this formula; see section 7.3 of the v3.1
+		// specification.
+		impact = 7.52*(miss-0.029) - 3.25*math.Pow(miss*0.9731-0.02, 13)
+	}
+	pr := v3WeightPR
+	if changed {
+		pr = v3WeightPRChanged
+	}
+	exploitability := 8.22 *
+		v3WeightAV[v.modified(v3MAV, v3AV)] *
+		v3WeightAC[v.modified(v3MAC, v3AC)] *
+		pr[v.modified(v3MPR, v3PR)] *
+		v3WeightUI[v.modified(v3MUI, v3UI)]
+	if impact <= 0 {
+		return 0
+	}
+	s := impact + exploitability
+	if changed {
+		s *= 1.08
+	}
+	return v.roundup(v.roundup(math.Min(s, 10)) * v.temporalFactor())
+}
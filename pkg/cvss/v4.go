@@ -0,0 +1,349 @@
+package cvss
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// V4 is a CVSS v4.0 vector.
+type V4 struct {
+	vals []string
+}
+
+var _ Vector = (*V4)(nil)
+
+// Indexes into v4Metrics.
+const (
+	v4AV = iota
+	v4AC
+	v4AT
+	v4PR
+	v4UI
+	v4VC
+	v4VI
+	v4VA
+	v4SC
+	v4SI
+	v4SA
+)
+
+var v4Metrics = metrics{
+	{Name: "AV", Values: []string{"N", "A", "L", "P"}, Required: true},
+	{Name: "AC", Values: []string{"L", "H"}, Required: true},
+	{Name: "AT", Values: []string{"N", "P"}, Required: true},
+	{Name: "PR", Values: []string{"N", "L", "H"}, Required: true},
+	{Name: "UI", Values: []string{"N", "P", "A"}, Required: true},
+	{Name: "VC", Values: []string{"H", "L", "N"}, Required: true},
+	{Name: "VI", Values: []string{"H", "L", "N"}, Required: true},
+	{Name: "VA", Values: []string{"H", "L", "N"}, Required: true},
+	{Name: "SC", Values: []string{"H", "L", "N"}, Required: true},
+	{Name: "SI", Values: []string{"H", "L", "N"}, Required: true},
+	{Name: "SA", Values: []string{"H", "L", "N"}, Required: true},
+	// Threat
+	{Name: "E", Values: []string{"X", "A", "P", "U"}},
+	// Environmental
+	{Name: "CR", Values: []string{"X", "H", "M", "L"}},
+	{Name: "IR", Values: []string{"X", "H", "M", "L"}},
+	{Name: "AR", Values: []string{"X", "H", "M", "L"}},
+	{Name: "MAV", Values: []string{"X", "N", "A", "L", "P"}},
+	{Name: "MAC", Values: []string{"X", "L", "H"}},
+	{Name: "MAT", Values: []string{"X", "N", "P"}},
+	{Name: "MPR", Values: []string{"X", "N", "L", "H"}},
+	{Name: "MUI", Values: []string{"X", "N", "P", "A"}},
+	{Name: "MVC", Values: []string{"X", "H", "L", "N"}},
+	{Name: "MVI", Values: []string{"X", "H", "L", "N"}},
+	{Name: "MVA", Values: []string{"X", "H", "L", "N"}},
+	{Name: "MSC", Values: []string{"X", "H", "L", "N"}},
+	{Name: "MSI", Values: []string{"X", "S", "H", "L", "N"}},
+	{Name: "MSA", Values: []string{"X", "S", "H", "L", "N"}},
+	// Supplemental
+	{Name: "S", Values: []string{"X", "N", "P"}},
+	{Name: "AU", Values: []string{"X", "N", "Y"}},
+	{Name: "R", Values: []string{"X", "A", "U", "I"}},
+	{Name: "V", Values: []string{"X", "D", "C"}},
+	{Name: "RE", Values: []string{"X", "L", "M", "H"}},
+	{Name: "U", Values: []string{"X", "Clear", "Green", "Amber", "Red"}},
+}
+
+// ParseV4 parses a CVSS v4.0 vector, like
+// "CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:H/VI:N/VA:N/SC:N/SI:N/SA:N".
+func ParseV4(s string) (*V4, error) {
+	const prefix = "CVSS:4.0/"
+	if !strings.HasPrefix(s, prefix) {
+		return nil, fmt.Errorf("%w: %q: not a CVSS v4 vector", ErrMalformed, s)
+	}
+	vals, err := v4Metrics.parse(s, s[len(prefix):])
+	if err != nil {
+		return nil, err
+	}
+	return &V4{vals: vals}, nil
+}
+
+// Version implements Vector.
+func (*V4) Version() string { return "4.0" }
+
+// String implements Vector.
+func (v *V4) String() string {
+	var b strings.Builder
+	b.WriteString("CVSS:4.0")
+	v4Metrics.format(&b, v.vals)
+	return b.String()
+}
+
+// Severity implements Vector.
+func (v *V4) Severity() string {
+	return severity(v.BaseScore())
+}
+
+// Metric returns the value of the named metric as used for the base score.
+//
+// The threat and environmental metrics used to pick a macro vector take their
+// "Not Defined" values, which are the most severe.
+func (v *V4) metric(n string) string {
+	switch n {
+	case "E":
+		return "A"
+	case "CR", "IR", "AR":
+		return "H"
+	}
+	for i := range v4Metrics[:v4SA+1] {
+		if v4Metrics[i].Name == n {
+			return v.vals[i]
+		}
+	}
+	panic("programmer error: unknown metric: " + n)
+}
+
+// Macro returns the vector's levels for the six equivalence sets (EQ1–EQ6)
+// described in section 8.2 of the specification.
+func (v *V4) macro() [6]int {
+	var eq [6]int
+	av, pr, ui := v.vals[v4AV], v.vals[v4PR], v.vals[v4UI]
+	switch {
+	case av == "N" && pr == "N" && ui == "N":
+		eq[0] = 0
+	case (av == "N" || pr == "N" || ui == "N") && av != "P":
+		eq[0] = 1
+	default:
+		eq[0] = 2
+	}
+	if v.vals[v4AC] == "L" && v.vals[v4AT] == "N" {
+		eq[1] = 0
+	} else {
+		eq[1] = 1
+	}
+	vc, vi, va := v.vals[v4VC], v.vals[v4VI], v.vals[v4VA]
+	switch {
+	case vc == "H" && vi == "H":
+		eq[2] = 0
+	case vc == "H" || vi == "H" || va == "H":
+		eq[2] = 1
+	default:
+		eq[2] = 2
+	}
+	// Level 0 of EQ4 requires the "Safety" value, which only the modified
+	// metrics can take.
+	if v.vals[v4SC] == "H" || v.vals[v4SI] == "H" || v.vals[v4SA] == "H" {
+		eq[3] = 1
+	} else {
+		eq[3] = 2
+	}
+	// EQ5 is level 0 for the "Attacked" exploit maturity used for base scores.
+	eq[4] = 0
+	// With the requirements all "High", EQ6 is level 0 if any of the
+	// vulnerable system impacts are "High".
+	if vc == "H" || vi == "H" || va == "H" {
+		eq[5] = 0
+	} else {
+		eq[5] = 1
+	}
+	return eq
+}
+
+// V4MacroKey returns the lookup key for the macro vector "eq".
+func v4MacroKey(eq [6]int) string {
+	var b [6]byte
+	for i, l := range eq {
+		b[i] = byte('0' + l)
+	}
+	return string(b[:])
+}
+
+// V4Score returns the score for the macro vector "eq", or NaN if there is no
+// such macro vector.
+func v4Score(eq [6]int) float64 {
+	for _, l := range eq {
+		if l < 0 || l > 2 {
+			return math.NaN()
+		}
+	}
+	s, ok := v4Lookup[v4MacroKey(eq)]
+	if !ok {
+		return math.NaN()
+	}
+	return s
+}
+
+// V4Levels are the severity levels of the metric values, used to measure the
+// distance between a vector and the highest-severity vector of its macro
+// vector.
+var v4Levels = map[string]map[string]float64{
+	"AV": {"N": 0.0, "A": 0.1, "L": 0.2, "P": 0.3},
+	"PR": {"N": 0.0, "L": 0.1, "H": 0.2},
+	"UI": {"N": 0.0, "P": 0.1, "A": 0.2},
+	"AC": {"L": 0.0, "H": 0.1},
+	"AT": {"N": 0.0, "P": 0.1},
+	"VC": {"H": 0.0, "L": 0.1, "N": 0.2},
+	"VI": {"H": 0.0, "L": 0.1, "N": 0.2},
+	"VA": {"H": 0.0, "L": 0.1, "N": 0.2},
+	"SC": {"H": 0.1, "L": 0.2, "N": 0.3},
+	"SI": {"S": 0.0, "H": 0.1, "L": 0.2, "N": 0.3},
+	"SA": {"S": 0.0, "H": 0.1, "L": 0.2, "N": 0.3},
+	"CR": {"H": 0.0, "M": 0.1, "L": 0.2},
+	"IR": {"H": 0.0, "M": 0.1, "L": 0.2},
+	"AR": {"H": 0.0, "M": 0.1, "L": 0.2},
+	"E":  {"U": 0.2, "P": 0.1, "A": 0.0},
+}
+
+// The highest-severity vectors for each level of each equivalence set. EQ3 and
+// EQ6 are considered jointly and indexed by "EQ3*2+EQ6".
+var (
+	v4MaxEQ1 = [][]string{
+		{"AV:N/PR:N/UI:N"},
+		{"AV:A/PR:N/UI:N", "AV:N/PR:L/UI:N", "AV:N/PR:N/UI:P"},
+		{"AV:P/PR:N/UI:N", "AV:A/PR:L/UI:P"},
+	}
+	v4MaxEQ2 = [][]string{
+		{"AC:L/AT:N"},
+		{"AC:H/AT:N", "AC:L/AT:P"},
+	}
+	v4MaxEQ3EQ6 = [][]string{
+		0*2 + 0: {"VC:H/VI:H/VA:H/CR:H/IR:H/AR:H"},
+		0*2 + 1: {"VC:H/VI:H/VA:L/CR:M/IR:M/AR:H", "VC:H/VI:H/VA:H/CR:M/IR:M/AR:M"},
+		1*2 + 0: {"VC:L/VI:H/VA:H/CR:H/IR:H/AR:H", "VC:H/VI:L/VA:H/CR:H/IR:H/AR:H"},
+		1*2 + 1: {"VC:L/VI:H/VA:L/CR:H/IR:M/AR:H", "VC:L/VI:H/VA:H/CR:H/IR:M/AR:M", "VC:H/VI:L/VA:H/CR:M/IR:H/AR:M", "VC:H/VI:L/VA:L/CR:M/IR:H/AR:H", "VC:L/VI:L/VA:H/CR:H/IR:H/AR:M"},
+		2*2 + 1: {"VC:L/VI:L/VA:L/CR:H/IR:H/AR:H"},
+	}
+	v4MaxEQ4 = [][]string{
+		{"SC:H/SI:S/SA:S"},
+		{"SC:H/SI:H/SA:H"},
+		{"SC:L/SI:L/SA:L"},
+	}
+	v4MaxEQ5 = [][]string{
+		{"E:A"},
+		{"E:P"},
+		{"E:U"},
+	}
+)
+
+// The depth of each level of each equivalence set, in steps of 0.1. EQ3 and
+// EQ6 are indexed as in v4MaxEQ3EQ6.
+var (
+	v4DepthEQ1    = []float64{1, 4, 5}
+	v4DepthEQ2    = []float64{1, 2}
+	v4DepthEQ3EQ6 = []float64{0*2 + 0: 7, 0*2 + 1: 6, 1*2 + 0: 8, 1*2 + 1: 8, 2*2 + 1: 10}
+	v4DepthEQ4    = []float64{6, 5, 4}
+	v4DepthEQ5    = []float64{1, 1, 1}
+)
+
+// BaseScore implements Vector.
+//
+// This is the "CVSS-B" score: threat and environmental metrics are ignored.
+// The score is computed by interpolating within the vector's macro vector, as
+// described in section 8.2 of the specification.
+func (v *V4) BaseScore() float64 {
+	none := true
+	for _, i := range []int{v4VC, v4VI, v4VA, v4SC, v4SI, v4SA} {
+		if v.vals[i] != "N" {
+			none = false
+			break
+		}
+	}
+	if none {
+		return 0
+	}
+
+	eq := v.macro()
+	value := v4Score(eq)
+
+	// Find the score of the next lower macro vector for each equivalence set.
+	lower := func(i int) float64 {
+		n := eq
+		n[i]++
+		return v4Score(n)
+	}
+	nextEQ1, nextEQ2, nextEQ4, nextEQ5 := lower(0), lower(1), lower(3), lower(4)
+	var nextEQ3EQ6 float64
+	switch eq3, eq6 := eq[2], eq[5]; {
+	case eq3 == 0 && eq6 == 0:
+		// Both 01 and 10 are lower; use the higher of the two.
+		nextEQ3EQ6 = math.Max(lower(5), lower(2))
+	case eq3 == 1 && eq6 == 0:
+		nextEQ3EQ6 = lower(5)
+	case eq3 == 2 && eq6 == 1:
+		nextEQ3EQ6 = math.NaN()
+	default: // 01 and 11
+		nextEQ3EQ6 = lower(2)
+	}
+
+	// Find the first highest-severity vector that this vector doesn't exceed
+	// in any metric, and the distance to it.
+	var dist map[string]float64
+Search:
+	for _, m1 := range v4MaxEQ1[eq[0]] {
+		for _, m2 := range v4MaxEQ2[eq[1]] {
+			for _, m36 := range v4MaxEQ3EQ6[eq[2]*2+eq[5]] {
+				for _, m4 := range v4MaxEQ4[eq[3]] {
+					for _, m5 := range v4MaxEQ5[eq[4]] {
+						ms := make(map[string]float64)
+						ok := true
+						for _, m := range strings.Split(strings.Join([]string{m1, m2, m36, m4, m5}, "/"), "/") {
+							n, hi, _ := strings.Cut(m, ":")
+							d := v4Levels[n][v.metric(n)] - v4Levels[n][hi]
+							if d < 0 {
+								ok = false
+								break
+							}
+							ms[n] = d
+						}
+						if ok {
+							dist = ms
+							break Search
+						}
+					}
+				}
+			}
+		}
+	}
+	sum := func(ns ...string) (s float64) {
+		for _, n := range ns {
+			s += dist[n]
+		}
+		return s
+	}
+	const step = 0.1
+	sets := []struct {
+		next, dist, depth float64
+	}{
+		{nextEQ1, sum("AV", "PR", "UI"), v4DepthEQ1[eq[0]]},
+		{nextEQ2, sum("AC", "AT"), v4DepthEQ2[eq[1]]},
+		{nextEQ3EQ6, sum("VC", "VI", "VA", "CR", "IR", "AR"), v4DepthEQ3EQ6[eq[2]*2+eq[5]]},
+		{nextEQ4, sum("SC", "SI", "SA"), v4DepthEQ4[eq[3]]},
+		{nextEQ5, 0, v4DepthEQ5[eq[4]]},
+	}
+	var n int
+	var total float64
+	for _, s := range sets {
+		if math.IsNaN(s.next) {
+			continue
+		}
+		n++
+		available := value - s.next
+		total += available * (s.dist / (s.depth * step))
+	}
+	if n != 0 {
+		value -= total / float64(n)
+	}
+	return roundTenth(math.Min(math.Max(value, 0), 10))
+}
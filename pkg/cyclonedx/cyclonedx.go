@@ -0,0 +1,131 @@
+// Package cyclonedx matches the components of a CycloneDX SBOM against a
+// vulnerability database.
+//
+// Versions 1.4 and 1.5 of the CycloneDX JSON format are supported. See
+// https://cyclonedx.org/specification/overview/ for the specification.
+//
+// The BOM is decoded into an IndexReport with
+// [github.com/quay/claircore/sbom/cyclonedx.DecodeComponents], so components
+// are identified by their Package URLs and matched by the same matchers as
+// indexed packages. Components without a Package URL are skipped.
+package cyclonedx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	cdx "github.com/quay/claircore/sbom/cyclonedx"
+)
+
+// ErrUnsupported is returned by MatchBOM when the document is not a supported
+// CycloneDX BOM.
+var ErrUnsupported = errors.New("cyclonedx: unsupported document")
+
+// VulnDB matches the packages in an IndexReport against known
+// vulnerabilities.
+//
+// A [github.com/quay/claircore/libvuln.Libvuln] can be used via ScanFunc:
+//
+//	db := cyclonedx.ScanFunc(func(ctx context.Context, ir *claircore.IndexReport) (*claircore.VulnerabilityReport, error) {
+//		return lv.Scan(ctx, ir)
+//	})
+type VulnDB interface {
+	Scan(ctx context.Context, ir *claircore.IndexReport) (*claircore.VulnerabilityReport, error)
+}
+
+// ScanFunc adapts a function to a VulnDB.
+type ScanFunc func(ctx context.Context, ir *claircore.IndexReport) (*claircore.VulnerabilityReport, error)
+
+// Scan implements VulnDB.
+func (f ScanFunc) Scan(ctx context.Context, ir *claircore.IndexReport) (*claircore.VulnerabilityReport, error) {
+	return f(ctx, ir)
+}
+
+// Component is a component from a BOM.
+type Component = cdx.Component
+
+// Match is a vulnerability affecting a BOM component.
+type Match struct {
+	// Component is the component as it appears in the BOM. Its BOMRef
+	// identifies it within the BOM, but may be empty.
+	Component     *Component
+	Vulnerability *claircore.Vulnerability
+}
+
+// MatchBOM reads a CycloneDX JSON BOM from "r" and uses "db" to find the
+// vulnerabilities affecting each of its components, including nested ones.
+//
+// The returned matches are in BOM order, and then sorted by vulnerability
+// name.
+func MatchBOM(ctx context.Context, r io.Reader, db VulnDB) ([]Match, error) {
+	ctx = zlog.ContextWithValues(ctx, "component", "pkg/cyclonedx/MatchBOM")
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("cyclonedx: unable to read BOM: %w", err)
+	}
+	var hdr struct {
+		BOMFormat   string `json:"bomFormat"`
+		SpecVersion string `json:"specVersion"`
+	}
+	if err := json.Unmarshal(b, &hdr); err != nil {
+		return nil, fmt.Errorf("cyclonedx: unable to decode BOM: %w", err)
+	}
+	if hdr.BOMFormat != "CycloneDX" {
+		return nil, fmt.Errorf("%w: unknown format %q", ErrUnsupported, hdr.BOMFormat)
+	}
+	switch hdr.SpecVersion {
+	case "1.4", "1.5":
+	default:
+		return nil, fmt.Errorf("%w: unknown spec version %q", ErrUnsupported, hdr.SpecVersion)
+	}
+	ir, refs, err := cdx.DecodeComponents(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	vr, err := db.Scan(ctx, ir)
+	if err != nil {
+		return nil, fmt.Errorf("cyclonedx: unable to match BOM: %w", err)
+	}
+
+	// The Builder assigns package IDs in BOM order, so sorting them
+	// numerically recovers it.
+	ids := make([]string, 0, len(vr.PackageVulnerabilities))
+	for id := range vr.PackageVulnerabilities {
+		if _, ok := refs[id]; ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, _ := strconv.Atoi(ids[i])
+		b, _ := strconv.Atoi(ids[j])
+		return a < b
+	})
+	var out []Match
+	for _, id := range ids {
+		var vs []*claircore.Vulnerability
+		for _, vid := range vr.PackageVulnerabilities[id] {
+			if v, ok := vr.Vulnerabilities[vid]; ok {
+				vs = append(vs, v)
+			}
+		}
+		sort.Slice(vs, func(i, j int) bool { return vs[i].Name < vs[j].Name })
+		for _, v := range vs {
+			out = append(out, Match{Component: refs[id], Vulnerability: v})
+		}
+	}
+	zlog.Debug(ctx).
+		Str("spec_version", hdr.SpecVersion).
+		Int("packages", len(refs)).
+		Int("matches", len(out)).
+		Msg("matched BOM")
+	return out, nil
+}
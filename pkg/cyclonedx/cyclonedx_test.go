@@ -0,0 +1,110 @@
+package cyclonedx
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+)
+
+// MapDB is a VulnDB that reports the vulnerabilities in a map, keyed by
+// package name and version.
+type mapDB struct {
+	vulns map[string][]*claircore.Vulnerability
+	err   error
+}
+
+func (m *mapDB) Scan(_ context.Context, ir *claircore.IndexReport) (*claircore.VulnerabilityReport, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	vr := &claircore.VulnerabilityReport{
+		Hash:                   ir.Hash,
+		Packages:               ir.Packages,
+		Vulnerabilities:        make(map[string]*claircore.Vulnerability),
+		PackageVulnerabilities: make(map[string][]string),
+	}
+	for id, p := range ir.Packages {
+		for _, v := range m.vulns[p.Name+"@"+p.Version] {
+			vr.Vulnerabilities[v.ID] = v
+			vr.PackageVulnerabilities[id] = append(vr.PackageVulnerabilities[id], v.ID)
+		}
+	}
+	return vr, nil
+}
+
+func TestMatchBOM(t *testing.T) {
+	ctx := zlog.Test(context.Background(), t)
+	curl := &claircore.Vulnerability{ID: "1", Name: "CVE-2020-8177"}
+	log4j := &claircore.Vulnerability{ID: "2", Name: "CVE-2021-44228"}
+	log4jDoS := &claircore.Vulnerability{ID: "3", Name: "CVE-2021-45105"}
+	jackson := &claircore.Vulnerability{ID: "4", Name: "CVE-2020-36518"}
+	db := &mapDB{
+		vulns: map[string][]*claircore.Vulnerability{
+			"curl@7.61.1-12.el8":                         {curl},
+			"org.apache.logging.log4j:log4j-core@2.14.1": {log4jDoS, log4j},
+			"jackson-databind@2.9.10":                    {jackson},
+		},
+	}
+	type row struct{ Ref, Vuln string }
+	// The jackson component only has a CPE, so it isn't matched.
+	want := []row{
+		{"pkg:rpm/redhat/curl@7.61.1-12.el8?arch=x86_64&distro=rhel-8.2", "CVE-2020-8177"},
+		{"pkg:maven/org.apache.logging.log4j/log4j-core@2.14.1", "CVE-2021-44228"},
+		{"pkg:maven/org.apache.logging.log4j/log4j-core@2.14.1", "CVE-2021-45105"},
+	}
+	for _, n := range []string{"syft-1.4.json", "syft-1.5.json"} {
+		t.Run(n, func(t *testing.T) {
+			ctx := zlog.Test(ctx, t)
+			f, err := os.Open(filepath.Join("testdata", n))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			ms, err := MatchBOM(ctx, f, db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []row
+			for _, m := range ms {
+				got = append(got, row{m.Component.BOMRef, m.Vulnerability.Name})
+			}
+			if !cmp.Equal(got, want) {
+				t.Error(cmp.Diff(got, want))
+			}
+			if got, want := ms[1].Component.Group, "org.apache.logging.log4j"; got != want {
+				t.Errorf("got group %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestMatchBOMError(t *testing.T) {
+	ctx := zlog.Test(context.Background(), t)
+	for _, doc := range []string{
+		`{"bomFormat": "SPDX", "specVersion": "1.4"}`,
+		`{"bomFormat": "CycloneDX", "specVersion": "1.3"}`,
+		`{"bomFormat": "CycloneDX"`,
+	} {
+		if _, err := MatchBOM(ctx, strings.NewReader(doc), &mapDB{}); err == nil {
+			t.Errorf("%s: expected error", doc)
+		}
+	}
+
+	dbErr := errors.New("db down")
+	f, err := os.Open("testdata/syft-1.5.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := MatchBOM(ctx, f, &mapDB{err: dbErr}); !errors.Is(err, dbErr) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+// Package dedupe collapses vulnerabilities reported for the same CVE by
+// multiple sources.
+package dedupe
+
+import (
+	"regexp"
+	"sort"
+
+	"github.com/quay/claircore"
+)
+
+// Strategy decides what to report for a set of vulnerabilities with the same
+// ID.
+type Strategy interface {
+	// Merge is passed two or more vulnerabilities with the same ID, sorted by
+	// source, and returns the one vulnerability to report.
+	//
+	// Implementations must not modify the passed vulnerabilities.
+	Merge(dups []*claircore.Vulnerability) *claircore.Vulnerability
+}
+
+// Deduplicator removes duplicate vulnerabilities according to its Strategy.
+//
+// Vulnerabilities are considered duplicates if they have the same ID, which
+// is the first CVE identifier in the Name, or the whole Name if it contains
+// none. The source of a vulnerability is its Updater. Deduplicating only
+// makes sense for vulnerabilities affecting the same package, such as the
+// vulnerabilities reported for one package in a [claircore.VulnerabilityReport].
+type Deduplicator struct {
+	// Strategy is used to merge duplicates. If nil, a zero PreferSource is
+	// used.
+	Strategy Strategy
+}
+
+// DeduplicateByID deduplicates "vs" using the default Strategy.
+func DeduplicateByID(vs []*claircore.Vulnerability) []*claircore.Vulnerability {
+	var d Deduplicator
+	return d.Deduplicate(vs)
+}
+
+// Deduplicate returns the vulnerabilities in "vs", with duplicates merged.
+//
+// The returned vulnerabilities are sorted by ID. The result doesn't depend on
+// the order of "vs".
+func (d *Deduplicator) Deduplicate(vs []*claircore.Vulnerability) []*claircore.Vulnerability {
+	s := d.Strategy
+	if s == nil {
+		s = PreferSource{}
+	}
+	type keyed struct {
+		ID string
+		V  *claircore.Vulnerability
+	}
+	ks := make([]keyed, 0, len(vs))
+	for _, v := range vs {
+		if v == nil {
+			continue
+		}
+		ks = append(ks, keyed{ID: ID(v), V: v})
+	}
+	sort.SliceStable(ks, func(i, j int) bool {
+		a, b := ks[i], ks[j]
+		switch {
+		case a.ID != b.ID:
+			return a.ID < b.ID
+		case a.V.Updater != b.V.Updater:
+			return a.V.Updater < b.V.Updater
+		}
+		return a.V.ID < b.V.ID
+	})
+
+	out := make([]*claircore.Vulnerability, 0, len(ks))
+	dups := make([]*claircore.Vulnerability, 0, 4)
+	for i := 0; i < len(ks); {
+		j := i + 1
+		for j < len(ks) && ks[j].ID == ks[i].ID {
+			j++
+		}
+		if j-i == 1 {
+			out = append(out, ks[i].V)
+			i = j
+			continue
+		}
+		dups = dups[:0]
+		for _, k := range ks[i:j] {
+			dups = append(dups, k.V)
+		}
+		out = append(out, s.Merge(dups))
+		i = j
+	}
+	return out
+}
+
+var cvePattern = regexp.MustCompile(`CVE-\d{4}-\d{4,}`)
+
+// ID returns the identifier used to find duplicates of "v".
+func ID(v *claircore.Vulnerability) string {
+	if id := cvePattern.FindString(v.Name); id != "" {
+		return id
+	}
+	return v.Name
+}
+
+// PreferSource is a Strategy that reports the vulnerability from the
+// highest-priority source.
+type PreferSource struct {
+	// Order lists sources (Updater names) from highest priority to lowest.
+	// Sources not in the list have lower priority than any listed source,
+	// and are ordered by name.
+	Order []string
+}
+
+// Merge implements Strategy.
+func (p PreferSource) Merge(dups []*claircore.Vulnerability) *claircore.Vulnerability {
+	best, bestRank := dups[0], p.rank(dups[0].Updater)
+	for _, v := range dups[1:] {
+		// Dups are sorted by source, so ties keep the first one.
+		if r := p.rank(v.Updater); r < bestRank {
+			best, bestRank = v, r
+		}
+	}
+	return best
+}
+
+// Rank returns the position of "src" in the Order, or the length of the
+// Order if it's not present.
+func (p PreferSource) rank(src string) int {
+	for i, o := range p.Order {
+		if o == src {
+			return i
+		}
+	}
+	return len(p.Order)
+}
+
+// MergeScores is a Strategy that reports a single vulnerability with the
+// highest scores from all the duplicates.
+//
+// The returned vulnerability is a shallow copy of the one chosen by Prefer,
+// with the CVSS scores and vectors for each CVSS version replaced by the
+// highest scoring one from any duplicate, and the NormalizedSeverity replaced
+// by the most severe.
+type MergeScores struct {
+	// Prefer chooses the vulnerability the merged record is based on.
+	Prefer PreferSource
+}
+
+// Merge implements Strategy.
+func (m MergeScores) Merge(dups []*claircore.Vulnerability) *claircore.Vulnerability {
+	v := *m.Prefer.Merge(dups)
+	for _, d := range dups {
+		if d.CVSSv2Score > v.CVSSv2Score {
+			v.CVSSv2Score, v.CVSSv2Vector = d.CVSSv2Score, d.CVSSv2Vector
+		}
+		if d.CVSSv3Score > v.CVSSv3Score {
+			v.CVSSv3Score, v.CVSSv3Vector = d.CVSSv3Score, d.CVSSv3Vector
+		}
+		if d.CVSSv4Score > v.CVSSv4Score {
+			v.CVSSv4Score, v.CVSSv4Vector = d.CVSSv4Score, d.CVSSv4Vector
+		}
+		if d.NormalizedSeverity > v.NormalizedSeverity {
+			v.NormalizedSeverity = d.NormalizedSeverity
+		}
+	}
+	return &v
+}
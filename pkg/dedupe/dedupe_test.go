@@ -0,0 +1,142 @@
+package dedupe
+
+import (
+	"encoding/json"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/quay/claircore"
+)
+
+// LoadFixture loads the vulnerabilities in "testdata/vulns.json": two sets of
+// duplicates from different sources and one vulnerability with no CVE.
+func loadFixture(t *testing.T) []*claircore.Vulnerability {
+	t.Helper()
+	b, err := os.ReadFile("testdata/vulns.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vs []*claircore.Vulnerability
+	if err := json.Unmarshal(b, &vs); err != nil {
+		t.Fatal(err)
+	}
+	return vs
+}
+
+// Summary is the subset of a vulnerability compared in tests.
+type summary struct {
+	ID, Updater string
+	Severity    claircore.Severity
+	V2, V3, V4  float64
+}
+
+func summarize(vs []*claircore.Vulnerability) []summary {
+	out := make([]summary, len(vs))
+	for i, v := range vs {
+		out[i] = summary{
+			ID:       v.ID,
+			Updater:  v.Updater,
+			Severity: v.NormalizedSeverity,
+			V2:       v.CVSSv2Score,
+			V3:       v.CVSSv3Score,
+			V4:       v.CVSSv4Score,
+		}
+	}
+	return out
+}
+
+func TestDeduplicate(t *testing.T) {
+	tcs := []struct {
+		Name     string
+		Strategy Strategy
+		Want     []summary
+	}{
+		{
+			Name: "Default",
+			Want: []summary{
+				{ID: "6", Updater: "nvd", Severity: claircore.High, V3: 8.1},
+				{ID: "2", Updater: "nvd", Severity: claircore.Critical, V2: 10, V3: 9.8},
+				{ID: "3", Updater: "osv/go", Severity: claircore.Low},
+			},
+		},
+		{
+			Name:     "PreferSource",
+			Strategy: PreferSource{Order: []string{"rhel-vex", "osv/go"}},
+			Want: []summary{
+				{ID: "4", Updater: "rhel-vex", Severity: claircore.High, V3: 8.1},
+				{ID: "1", Updater: "rhel-vex", Severity: claircore.Medium, V3: 7.8},
+				{ID: "3", Updater: "osv/go", Severity: claircore.Low},
+			},
+		},
+		{
+			Name:     "PreferUnlisted",
+			Strategy: PreferSource{Order: []string{"osv/go"}},
+			Want: []summary{
+				{ID: "5", Updater: "osv/go", Severity: claircore.High, V4: 9.3},
+				{ID: "2", Updater: "nvd", Severity: claircore.Critical, V2: 10, V3: 9.8},
+				{ID: "3", Updater: "osv/go", Severity: claircore.Low},
+			},
+		},
+		{
+			Name:     "MergeScores",
+			Strategy: MergeScores{Prefer: PreferSource{Order: []string{"rhel-vex"}}},
+			Want: []summary{
+				{ID: "4", Updater: "rhel-vex", Severity: claircore.High, V3: 8.1, V4: 9.3},
+				{ID: "1", Updater: "rhel-vex", Severity: claircore.Critical, V2: 10, V3: 9.8},
+				{ID: "3", Updater: "osv/go", Severity: claircore.Low},
+			},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			vs := loadFixture(t)
+			orig := summarize(vs)
+			d := Deduplicator{Strategy: tc.Strategy}
+			got := summarize(d.Deduplicate(vs))
+			if !cmp.Equal(got, tc.Want) {
+				t.Error(cmp.Diff(got, tc.Want))
+			}
+			if !cmp.Equal(summarize(vs), orig) {
+				t.Error("input modified")
+			}
+
+			// The order of the input must not matter.
+			r := rand.New(rand.NewSource(1))
+			for i := 0; i < 10; i++ {
+				r.Shuffle(len(vs), func(i, j int) { vs[i], vs[j] = vs[j], vs[i] })
+				if got := summarize(d.Deduplicate(vs)); !cmp.Equal(got, tc.Want) {
+					t.Errorf("order %v: %s", vs, cmp.Diff(got, tc.Want))
+				}
+			}
+		})
+	}
+}
+
+func TestDeduplicateByID(t *testing.T) {
+	vs := loadFixture(t)
+	got := DeduplicateByID(vs)
+	if got, want := len(got), 3; got != want {
+		t.Errorf("got %d vulnerabilities, want %d", got, want)
+	}
+	if got := DeduplicateByID(nil); len(got) != 0 {
+		t.Errorf("got %d vulnerabilities, want 0", len(got))
+	}
+}
+
+func TestID(t *testing.T) {
+	tcs := []struct {
+		Name, Want string
+	}{
+		{Name: "CVE-2023-12345", Want: "CVE-2023-12345"},
+		{Name: "RHSA-2023:0002: libbar security update (Important) CVE-2023-0002", Want: "CVE-2023-0002"},
+		{Name: "GO-2023-0001", Want: "GO-2023-0001"},
+	}
+	for _, tc := range tcs {
+		if got := ID(&claircore.Vulnerability{Name: tc.Name}); got != tc.Want {
+			t.Errorf("%q: got %q, want %q", tc.Name, got, tc.Want)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+// Package epss reads Exploit Prediction Scoring System (EPSS) scores, either
+// on demand from the FIRST API with an [Enricher], or in bulk from the daily
+// score files with a [Feed].
+//
+// EPSS scores estimate the probability that a CVE will be exploited in the
+// next 30 days, and are updated daily. See https://www.first.org/epss/ for
+// details.
+//
+// The [github.com/quay/claircore/enricher/epss] package uses a Feed to store
+// the scores as enrichments.
+package epss
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quay/zlog"
+)
+
+// DefaultURL is the FIRST EPSS API endpoint.
+//
+//doc:url updater
+const DefaultURL = `https://api.first.org/data/1.0/epss`
+
+// DefaultBatchSize is the default number of CVEs requested at once.
+//
+// The API returns at most 100 results per page by default, and the CVEs are
+// sent in the query string, so batches should not be much larger.
+const DefaultBatchSize = 100
+
+// EPSSScore is the EPSS data for a single CVE.
+type EPSSScore struct {
+	// Probability is the probability of exploitation in the next 30 days,
+	// in the range [0, 1].
+	Probability float64
+	// Percentile is the proportion of all scored CVEs with the same or a lower
+	// probability, in the range [0, 1].
+	Percentile float64
+	// Date is the day the score was computed.
+	Date time.Time
+}
+
+// Enricher fetches EPSS scores for CVEs.
+//
+// An Enricher is safe for concurrent use.
+type Enricher struct {
+	c     *http.Client
+	url   *url.URL
+	batch int
+
+	// If ttl is non-zero, results are cached in "cache" for that long.
+	ttl   time.Duration
+	cache sync.Map // map[string]cacheEntry
+	now   func() time.Time
+}
+
+// CacheEntry is a cached lookup result. CVEs without a score are cached too,
+// so that they're not requested over and over.
+type cacheEntry struct {
+	Score   EPSSScore
+	Found   bool
+	Expires time.Time
+}
+
+// Option configures an Enricher.
+type Option func(*Enricher) error
+
+// WithCache configures the Enricher to remember results for "ttl".
+//
+// As scores are recomputed daily, there's little point in a ttl longer than
+// a day.
+func WithCache(ttl time.Duration) Option {
+	return func(e *Enricher) error {
+		if ttl <= 0 {
+			return fmt.Errorf("epss: invalid cache ttl: %v", ttl)
+		}
+		e.ttl = ttl
+		return nil
+	}
+}
+
+// WithURL configures the Enricher to use the API at "u" instead of
+// DefaultURL.
+func WithURL(u string) Option {
+	return func(e *Enricher) error {
+		var err error
+		e.url, err = url.Parse(u)
+		if err != nil {
+			return fmt.Errorf("epss: invalid url: %w", err)
+		}
+		return nil
+	}
+}
+
+// WithBatchSize configures the number of CVEs requested at once. The default
+// is DefaultBatchSize.
+func WithBatchSize(n int) Option {
+	return func(e *Enricher) error {
+		if n < 1 {
+			return fmt.Errorf("epss: invalid batch size: %d", n)
+		}
+		e.batch = n
+		return nil
+	}
+}
+
+// NewEnricher returns an Enricher using the provided client.
+func NewEnricher(c *http.Client, opts ...Option) (*Enricher, error) {
+	if c == nil {
+		return nil, fmt.Errorf("epss: nil http.Client")
+	}
+	e := &Enricher{
+		c:     c,
+		batch: DefaultBatchSize,
+		now:   time.Now,
+	}
+	for _, o := range opts {
+		if err := o(e); err != nil {
+			return nil, err
+		}
+	}
+	if e.url == nil {
+		var err error
+		e.url, err = url.Parse(DefaultURL)
+		if err != nil {
+			panic("programmer error: " + err.Error())
+		}
+	}
+	return e, nil
+}
+
+// CVEPattern matches CVE IDs.
+var cvePattern = regexp.MustCompile(`^CVE-\d{4}-\d{4,}$`)
+
+// Scores returns the EPSS scores for the CVE IDs in "cves", keyed by CVE ID.
+//
+// Strings that are not CVE IDs are ignored, as are CVEs the API has no score
+// for. An error is returned if any request fails; the returned map then holds
+// whatever scores were retrieved before the failure.
+func (e *Enricher) Scores(ctx context.Context, cves []string) (map[string]EPSSScore, error) {
+	ctx = zlog.ContextWithValues(ctx, "component", "pkg/epss/Enricher/Scores")
+	out := make(map[string]EPSSScore, len(cves))
+	now := e.now()
+	seen := make(map[string]struct{}, len(cves))
+	todo := make([]string, 0, len(cves))
+	for _, id := range cves {
+		id = strings.ToUpper(strings.TrimSpace(id))
+		if !cvePattern.MatchString(id) {
+			continue
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		if e.ttl != 0 {
+			if v, ok := e.cache.Load(id); ok {
+				ent := v.(cacheEntry)
+				if now.Before(ent.Expires) {
+					if ent.Found {
+						out[id] = ent.Score
+					}
+					continue
+				}
+				e.cache.Delete(id)
+			}
+		}
+		todo = append(todo, id)
+	}
+	zlog.Debug(ctx).
+		Int("requested", len(seen)).
+		Int("cached", len(seen)-len(todo)).
+		Msg("fetching scores")
+
+	for len(todo) > 0 {
+		n := e.batch
+		if n > len(todo) {
+			n = len(todo)
+		}
+		batch := todo[:n]
+		todo = todo[n:]
+		got, err := e.fetch(ctx, batch)
+		if err != nil {
+			return out, err
+		}
+		for id, s := range got {
+			out[id] = s
+		}
+		if e.ttl != 0 {
+			exp := now.Add(e.ttl)
+			for _, id := range batch {
+				s, ok := got[id]
+				e.cache.Store(id, cacheEntry{Score: s, Found: ok, Expires: exp})
+			}
+		}
+	}
+	return out, nil
+}
+
+// Response is the API response.
+type response struct {
+	Status     string `json:"status"`
+	StatusCode int    `json:"status-code"`
+	Total      int    `json:"total"`
+	Data       []struct {
+		CVE        string `json:"cve"`
+		EPSS       string `json:"epss"`
+		Percentile string `json:"percentile"`
+		Date       string `json:"date"`
+	} `json:"data"`
+}
+
+// Fetch requests the scores for a single batch of CVEs.
+func (e *Enricher) fetch(ctx context.Context, cves []string) (map[string]EPSSScore, error) {
+	u := *e.url
+	v := u.Query()
+	v.Set("cve", strings.Join(cves, ","))
+	v.Set("limit", strconv.Itoa(len(cves)))
+	u.RawQuery = v.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("epss: unable to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	res, err := e.c.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("epss: unable to fetch scores: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("epss: unexpected response: %s", res.Status)
+	}
+	var r response
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("epss: unable to decode response: %w", err)
+	}
+	if r.StatusCode != 0 && r.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("epss: unexpected API status: %d (%s)", r.StatusCode, r.Status)
+	}
+	out := make(map[string]EPSSScore, len(r.Data))
+	for _, d := range r.Data {
+		var s EPSSScore
+		if s.Probability, err = strconv.ParseFloat(d.EPSS, 64); err != nil {
+			return nil, fmt.Errorf("epss: bad score for %q: %w", d.CVE, err)
+		}
+		if s.Percentile, err = strconv.ParseFloat(d.Percentile, 64); err != nil {
+			return nil, fmt.Errorf("epss: bad percentile for %q: %w", d.CVE, err)
+		}
+		if d.Date != "" {
+			if s.Date, err = time.Parse("2006-01-02", d.Date); err != nil {
+				return nil, fmt.Errorf("epss: bad date for %q: %w", d.CVE, err)
+			}
+		}
+		out[d.CVE] = s
+	}
+	return out, nil
+}
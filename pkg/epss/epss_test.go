@@ -0,0 +1,156 @@
+package epss
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/quay/zlog"
+)
+
+// NewServer returns a server answering requests from the canned response in
+// testdata, and a counter of requests made.
+func newServer(t *testing.T) (*httptest.Server, *int64) {
+	t.Helper()
+	f, err := os.Open("testdata/scores.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var canned response
+	if err := json.NewDecoder(f).Decode(&canned); err != nil {
+		t.Fatal(err)
+	}
+	var ct int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&ct, 1)
+		if r.URL.Path != "/data/1.0/epss" {
+			http.NotFound(w, r)
+			return
+		}
+		want := make(map[string]bool)
+		for _, id := range strings.Split(r.URL.Query().Get("cve"), ",") {
+			want[id] = true
+		}
+		res := canned
+		res.Data = res.Data[:0:0]
+		for _, d := range canned.Data {
+			if want[d.CVE] {
+				res.Data = append(res.Data, d)
+			}
+		}
+		res.Total = len(res.Data)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(&res); err != nil {
+			t.Error(err)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &ct
+}
+
+func TestScores(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	srv, ct := newServer(t)
+	e, err := NewEnricher(srv.Client(),
+		WithURL(srv.URL+"/data/1.0/epss"),
+		WithBatchSize(2),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := e.Scores(ctx, []string{
+		"CVE-2021-44228",
+		"cve-2014-0160",
+		"CVE-2021-44228",
+		"CVE-2099-0001",
+		"RHSA-2020:1980",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := time.Date(2023, 11, 1, 0, 0, 0, 0, time.UTC)
+	want := map[string]EPSSScore{
+		"CVE-2021-44228": {Probability: 0.97566, Percentile: 0.99999, Date: date},
+		"CVE-2014-0160":  {Probability: 0.97351, Percentile: 0.999, Date: date},
+	}
+	if !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(got, want))
+	}
+	// Three distinct CVEs in batches of two.
+	if got, want := atomic.LoadInt64(ct), int64(2); got != want {
+		t.Errorf("got %d requests, want %d", got, want)
+	}
+}
+
+func TestCache(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	srv, ct := newServer(t)
+	e, err := NewEnricher(srv.Client(),
+		WithURL(srv.URL+"/data/1.0/epss"),
+		WithCache(time.Hour),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2023, 11, 1, 12, 0, 0, 0, time.UTC)
+	e.now = func() time.Time { return now }
+	cves := []string{"CVE-2021-44228", "CVE-2022-27225", "CVE-2099-0001"}
+
+	first, err := e.Scores(ctx, cves)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(first), 2; got != want {
+		t.Errorf("got %d scores, want %d", got, want)
+	}
+	second, err := e.Scores(ctx, cves)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(first, second) {
+		t.Error(cmp.Diff(first, second))
+	}
+	if got, want := atomic.LoadInt64(ct), int64(1); got != want {
+		t.Errorf("got %d requests, want %d", got, want)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if _, err := e.Scores(ctx, cves[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := atomic.LoadInt64(ct), int64(2); got != want {
+		t.Errorf("got %d requests after expiry, want %d", got, want)
+	}
+}
+
+func TestError(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	srv, _ := newServer(t)
+	e, err := NewEnricher(srv.Client(), WithURL(srv.URL+"/nope"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Scores(ctx, []string{"CVE-2021-44228"}); err == nil {
+		t.Error("expected error for missing endpoint")
+	}
+
+	for _, o := range []Option{WithCache(0), WithBatchSize(0), WithURL(":")} {
+		if _, err := NewEnricher(srv.Client(), o); err == nil {
+			t.Error("expected error for bad option")
+		}
+	}
+	if _, err := NewEnricher(nil); err == nil {
+		t.Error("expected error for nil client")
+	}
+}
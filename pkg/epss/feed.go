@@ -0,0 +1,109 @@
+package epss
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Feed reads the daily CSV files of every score published by FIRST, like
+// https://epss.cyentia.com/epss_scores-current.csv.gz.
+//
+// A file has "cve", "epss", and "percentile" columns, optionally preceded by a
+// comment line with the model version and score date.
+type Feed struct {
+	rd             *csv.Reader
+	cve, epss, pct int
+
+	// Comment is the file's comment line, without the leading "#", or an
+	// empty string if it has none.
+	Comment string
+	// ModelVersion is the version of the EPSS model, if provided.
+	ModelVersion string
+	// Date is the day the scores were computed, if provided.
+	Date time.Time
+}
+
+// NewFeed returns a Feed reading the decompressed CSV file in "r".
+//
+// The comment line and header are read before NewFeed returns.
+func NewFeed(r io.Reader) (*Feed, error) {
+	f := Feed{cve: -1, epss: -1, pct: -1}
+	br := bufio.NewReader(r)
+	if b, err := br.Peek(1); err == nil && b[0] == '#' {
+		l, err := br.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("epss: unable to read comment: %w", err)
+		}
+		f.Comment = strings.TrimSpace(strings.TrimPrefix(l, "#"))
+		if err := f.parseComment(); err != nil {
+			return nil, err
+		}
+	}
+	f.rd = csv.NewReader(br)
+	f.rd.ReuseRecord = true
+	hdr, err := f.rd.Read()
+	if err != nil {
+		return nil, fmt.Errorf("epss: unable to read header: %w", err)
+	}
+	for i, h := range hdr {
+		switch strings.TrimSpace(h) {
+		case "cve":
+			f.cve = i
+		case "epss":
+			f.epss = i
+		case "percentile":
+			f.pct = i
+		}
+	}
+	if f.cve == -1 || f.epss == -1 || f.pct == -1 {
+		return nil, fmt.Errorf("epss: unexpected header: %q", hdr)
+	}
+	// Every row must have as many fields as the header.
+	f.rd.FieldsPerRecord = len(hdr)
+	return &f, nil
+}
+
+// ParseComment parses a comment line like
+// "model_version:v2023.03.01,score_date:2024-01-01T00:00:00+0000".
+func (f *Feed) parseComment() error {
+	for _, kv := range strings.Split(f.Comment, ",") {
+		k, v, _ := strings.Cut(kv, ":")
+		switch strings.TrimSpace(k) {
+		case "model_version":
+			f.ModelVersion = strings.TrimSpace(v)
+		case "score_date":
+			d, _, _ := strings.Cut(strings.TrimSpace(v), "T")
+			var err error
+			if f.Date, err = time.Parse("2006-01-02", d); err != nil {
+				return fmt.Errorf("epss: bad score date: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// Next returns the next CVE ID in the file and its score. It returns [io.EOF]
+// at the end of the file.
+func (f *Feed) Next() (string, EPSSScore, error) {
+	row, err := f.rd.Read()
+	if err != nil {
+		if err == io.EOF {
+			return "", EPSSScore{}, err
+		}
+		return "", EPSSScore{}, fmt.Errorf("epss: unable to read row: %w", err)
+	}
+	id := strings.ToUpper(strings.TrimSpace(row[f.cve]))
+	s := EPSSScore{Date: f.Date}
+	if s.Probability, err = strconv.ParseFloat(strings.TrimSpace(row[f.epss]), 64); err != nil {
+		return "", EPSSScore{}, fmt.Errorf("epss: bad score for %q: %w", id, err)
+	}
+	if s.Percentile, err = strconv.ParseFloat(strings.TrimSpace(row[f.pct]), 64); err != nil {
+		return "", EPSSScore{}, fmt.Errorf("epss: bad percentile for %q: %w", id, err)
+	}
+	return id, s, nil
+}
@@ -0,0 +1,71 @@
+package epss
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFeed(t *testing.T) {
+	t.Run("Comment", func(t *testing.T) {
+		const in = `#model_version:v2023.03.01,score_date:2024-01-01T00:00:00+0000
+cve,epss,percentile
+CVE-2023-0001,0.00043,0.0768
+cve-2023-0002,0.97565,0.99985
+`
+		f, err := NewFeed(strings.NewReader(in))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := f.ModelVersion, "v2023.03.01"; got != want {
+			t.Errorf("got model version %q, want %q", got, want)
+		}
+		date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		got := make(map[string]EPSSScore)
+		for {
+			id, s, err := f.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got[id] = s
+		}
+		want := map[string]EPSSScore{
+			"CVE-2023-0001": {Probability: 0.00043, Percentile: 0.0768, Date: date},
+			"CVE-2023-0002": {Probability: 0.97565, Percentile: 0.99985, Date: date},
+		}
+		if !cmp.Equal(got, want) {
+			t.Error(cmp.Diff(got, want))
+		}
+	})
+	t.Run("NoComment", func(t *testing.T) {
+		f, err := NewFeed(strings.NewReader("percentile,cve,epss\n0.5,CVE-2023-0001,0.1\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Comment != "" {
+			t.Errorf("unexpected comment: %q", f.Comment)
+		}
+		id, s, err := f.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := id, "CVE-2023-0001"; got != want {
+			t.Errorf("got CVE %q, want %q", got, want)
+		}
+		if got, want := s, (EPSSScore{Probability: 0.1, Percentile: 0.5}); !cmp.Equal(got, want) {
+			t.Error(cmp.Diff(got, want))
+		}
+	})
+	t.Run("BadHeader", func(t *testing.T) {
+		if _, err := NewFeed(strings.NewReader("cve,score\n")); err == nil {
+			t.Error("expected error")
+		}
+	})
+}
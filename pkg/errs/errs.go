@@ -0,0 +1,115 @@
+// Package errs provides error types for failures when fetching and parsing
+// vulnerability feeds.
+//
+// Callers can use [errors.As] to find out what kind of failure occurred, and
+// [Transient] to decide whether retrying might help.
+package errs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// FetchError is a failure to retrieve a resource.
+type FetchError struct {
+	// URL is the resource being fetched.
+	URL string
+	// StatusCode is the HTTP status code of the response, or zero if no
+	// response was received.
+	StatusCode int
+	// Err is the underlying error, if any.
+	Err error
+}
+
+// Error implements error.
+func (e *FetchError) Error() string {
+	switch {
+	case e.StatusCode != 0 && e.Err != nil:
+		return fmt.Sprintf("fetch %q: unexpected response: %d (%s): %v", e.URL, e.StatusCode, http.StatusText(e.StatusCode), e.Err)
+	case e.StatusCode != 0:
+		return fmt.Sprintf("fetch %q: unexpected response: %d (%s)", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("fetch %q: %v", e.URL, e.Err)
+}
+
+// Unwrap is for use with [errors.Unwrap].
+func (e *FetchError) Unwrap() error { return e.Err }
+
+// Temporary reports whether the failure is likely to be resolved by retrying
+// the request later: a "request timeout", "too many requests", or server
+// error response, or a network timeout.
+func (e *FetchError) Temporary() bool {
+	switch e.StatusCode {
+	case 0:
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	default:
+		return e.StatusCode >= 500
+	}
+	if errors.Is(e.Err, context.DeadlineExceeded) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(e.Err, &ne) && ne.Timeout()
+}
+
+// ParseError is a failure to parse a feed.
+type ParseError struct {
+	// ID is the identifier of the record (such as an OVAL definition) being
+	// parsed, if known.
+	ID string
+	// Offset is the byte offset in the input where the error was detected,
+	// or -1 if not known.
+	Offset int64
+	// Err is the underlying error.
+	Err error
+}
+
+// Error implements error.
+func (e *ParseError) Error() string {
+	switch {
+	case e.ID != "" && e.Offset >= 0:
+		return fmt.Sprintf("parse %q (offset %d): %v", e.ID, e.Offset, e.Err)
+	case e.ID != "":
+		return fmt.Sprintf("parse %q: %v", e.ID, e.Err)
+	case e.Offset >= 0:
+		return fmt.Sprintf("parse (offset %d): %v", e.Offset, e.Err)
+	}
+	return fmt.Sprintf("parse: %v", e.Err)
+}
+
+// Unwrap is for use with [errors.Unwrap].
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// ValidationError is a feed that was retrieved and decoded, but failed a
+// check, such as a schema or signature verification.
+type ValidationError struct {
+	// Check names the failed check, such as "signature".
+	Check string
+	// Err is the underlying error.
+	Err error
+}
+
+// Error implements error.
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s validation failed: %v", e.Check, e.Err)
+}
+
+// Unwrap is for use with [errors.Unwrap].
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+// Transient reports whether "err" is a failure that may be resolved by
+// retrying: a [FetchError] that reports itself as temporary. Parse and
+// validation failures are permanent for a given input.
+func Transient(err error) bool {
+	var pe *ParseError
+	var ve *ValidationError
+	if errors.As(err, &pe) || errors.As(err, &ve) {
+		return false
+	}
+	var fe *FetchError
+	return errors.As(err, &fe) && fe.Temporary()
+}
@@ -0,0 +1,77 @@
+package errs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+)
+
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string   { return "i/o timeout" }
+func (timeoutErr) Timeout() bool   { return true }
+func (timeoutErr) Temporary() bool { return true }
+
+func TestTransient(t *testing.T) {
+	tcs := []struct {
+		Name string
+		Err  error
+		Want bool
+	}{
+		{Name: "Nil", Err: nil, Want: false},
+		{Name: "Plain", Err: io.EOF, Want: false},
+		{Name: "NotFound", Err: &FetchError{URL: "http://x", StatusCode: http.StatusNotFound}, Want: false},
+		{Name: "TooMany", Err: &FetchError{URL: "http://x", StatusCode: http.StatusTooManyRequests}, Want: true},
+		{Name: "Unavailable", Err: &FetchError{URL: "http://x", StatusCode: http.StatusServiceUnavailable}, Want: true},
+		{Name: "Timeout", Err: &FetchError{URL: "http://x", Err: timeoutErr{}}, Want: true},
+		{Name: "Deadline", Err: &FetchError{URL: "http://x", Err: context.DeadlineExceeded}, Want: true},
+		{Name: "Refused", Err: &FetchError{URL: "http://x", Err: errors.New("connection refused")}, Want: false},
+		{Name: "Wrapped", Err: fmt.Errorf("rhel: %w", &FetchError{URL: "http://x", StatusCode: 502}), Want: true},
+		{Name: "Parse", Err: &ParseError{ID: "oval:1", Offset: 10, Err: io.ErrUnexpectedEOF}, Want: false},
+		{Name: "Validation", Err: &ValidationError{Check: "signature", Err: io.EOF}, Want: false},
+	}
+	for _, tc := range tcs {
+		if got := Transient(tc.Err); got != tc.Want {
+			t.Errorf("%s: got %v, want %v", tc.Name, got, tc.Want)
+		}
+	}
+}
+
+func TestError(t *testing.T) {
+	tcs := []struct {
+		Err  error
+		Want string
+	}{
+		{
+			Err:  &FetchError{URL: "http://x", StatusCode: 404},
+			Want: `fetch "http://x": unexpected response: 404 (Not Found)`,
+		},
+		{
+			Err:  &FetchError{URL: "http://x", Err: io.EOF},
+			Want: `fetch "http://x": EOF`,
+		},
+		{
+			Err:  &ParseError{ID: "oval:1", Offset: 10, Err: io.EOF},
+			Want: `parse "oval:1" (offset 10): EOF`,
+		},
+		{
+			Err:  &ParseError{Offset: -1, Err: io.EOF},
+			Want: `parse: EOF`,
+		},
+		{
+			Err:  &ValidationError{Check: "signature", Err: io.EOF},
+			Want: `signature validation failed: EOF`,
+		},
+	}
+	for _, tc := range tcs {
+		if got := tc.Err.Error(); got != tc.Want {
+			t.Errorf("got %q, want %q", got, tc.Want)
+		}
+		if !errors.Is(tc.Err, io.EOF) && errors.Unwrap(tc.Err) != nil {
+			t.Errorf("%q: Unwrap: got %v", tc.Err, errors.Unwrap(tc.Err))
+		}
+	}
+}
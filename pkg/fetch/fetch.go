@@ -0,0 +1,284 @@
+// Package fetch provides an HTTP client wrapper for downloading vulnerability
+// feeds, with retries and per-host rate limiting.
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/quay/zlog"
+	"golang.org/x/time/rate"
+)
+
+// These are the defaults used if not configured otherwise.
+const (
+	DefaultRetries    = 3
+	DefaultBackoff    = time.Second
+	DefaultMaxBackoff = 30 * time.Second
+)
+
+// Fetcher makes GET requests, retrying transient failures.
+//
+// Network errors and responses with the status codes 429, 500, 502, 503, and
+// 504 are considered transient. Retries are delayed with exponential backoff
+// and jitter, or by the amount of time requested by a "Retry-After" header.
+//
+// A Fetcher is safe for concurrent use.
+type Fetcher struct {
+	c          *http.Client
+	retries    int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	// Extra holds status codes to retry in addition to the usual ones.
+	extra map[int]bool
+
+	limit rate.Limit
+	burst int
+	mu    sync.Mutex
+	hosts map[string]*rate.Limiter
+
+	// Sleep and jitter are swapped out in tests.
+	sleep  func(context.Context, time.Duration) error
+	jitter func(time.Duration) time.Duration
+}
+
+// Option configures a Fetcher.
+type Option func(*Fetcher) error
+
+// WithRetries configures the number of times a request is retried after the
+// first attempt. The default is DefaultRetries.
+func WithRetries(n int) Option {
+	return func(f *Fetcher) error {
+		if n < 0 {
+			return fmt.Errorf("fetch: invalid number of retries: %d", n)
+		}
+		f.retries = n
+		return nil
+	}
+}
+
+// WithBackoff configures the delay before the first retry, which is doubled
+// for every subsequent retry up to "max". The defaults are DefaultBackoff and
+// DefaultMaxBackoff.
+func WithBackoff(base, max time.Duration) Option {
+	return func(f *Fetcher) error {
+		if base <= 0 || max < base {
+			return fmt.Errorf("fetch: invalid backoff: %v, %v", base, max)
+		}
+		f.backoff, f.maxBackoff = base, max
+		return nil
+	}
+}
+
+// WithRetryStatus configures the Fetcher to also consider responses with the
+// status codes in "codes" transient, for servers that signal throttling
+// unusually.
+func WithRetryStatus(codes ...int) Option {
+	return func(f *Fetcher) error {
+		for _, c := range codes {
+			if c < 100 || c > 599 {
+				return fmt.Errorf("fetch: invalid status code: %d", c)
+			}
+			f.extra[c] = true
+		}
+		return nil
+	}
+}
+
+// WithRateLimit limits requests to any single host to "r" per second, with
+// bursts of up to "burst" requests. Retries count against the limit. By
+// default, requests are not limited.
+func WithRateLimit(r rate.Limit, burst int) Option {
+	return func(f *Fetcher) error {
+		if r <= 0 || burst < 1 {
+			return fmt.Errorf("fetch: invalid rate limit: %v, %d", r, burst)
+		}
+		f.limit, f.burst = r, burst
+		return nil
+	}
+}
+
+// NewFetcher returns a Fetcher using the provided client.
+func NewFetcher(c *http.Client, opts ...Option) (*Fetcher, error) {
+	if c == nil {
+		return nil, errors.New("fetch: nil http.Client")
+	}
+	f := &Fetcher{
+		c:          c,
+		retries:    DefaultRetries,
+		backoff:    DefaultBackoff,
+		maxBackoff: DefaultMaxBackoff,
+		extra:      make(map[int]bool),
+		limit:      rate.Inf,
+		hosts:      make(map[string]*rate.Limiter),
+		sleep:      sleep,
+		jitter:     jitter,
+	}
+	for _, o := range opts {
+		if err := o(f); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// Get issues a GET request for "u", retrying transient failures.
+//
+// As with [http.Client.Do], a non-2xx response is not an error: if the
+// retries are exhausted on a transient status code, the last response is
+// returned. The caller must close the returned response's Body.
+func (f *Fetcher) Get(ctx context.Context, u string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetch: %w", err)
+	}
+	return f.Do(req)
+}
+
+// Do issues the request "req", retrying transient failures, for callers that
+// need to set headers. The request's Context is used for waiting on the rate
+// limit and between retries.
+//
+// Only requests without a body can be retried; a request with a body is
+// rejected.
+func (f *Fetcher) Do(req *http.Request) (*http.Response, error) {
+	if req.Body != nil && req.Body != http.NoBody {
+		return nil, errors.New("fetch: request with a body")
+	}
+	ctx := req.Context()
+	lim := f.limiter(req.URL)
+	ctx = zlog.ContextWithValues(ctx,
+		"component", "pkg/fetch/Fetcher.Get",
+		"url", req.URL.Redacted())
+
+	for attempt := 0; ; attempt++ {
+		if err := lim.Wait(ctx); err != nil {
+			return nil, fmt.Errorf("fetch: %w", err)
+		}
+		res, err := f.c.Do(req)
+		var wait time.Duration
+		switch {
+		case err != nil:
+			if ctx.Err() != nil || attempt == f.retries {
+				return nil, fmt.Errorf("fetch: %w", err)
+			}
+			wait = f.delay(attempt)
+			zlog.Debug(ctx).
+				Err(err).
+				Int("attempt", attempt+1).
+				Dur("wait", wait).
+				Msg("request failed, retrying")
+		case !(retryable(res.StatusCode) || f.extra[res.StatusCode]) || attempt == f.retries:
+			return res, nil
+		default:
+			var ok bool
+			wait, ok = retryAfter(res.Header.Get("Retry-After"), time.Now())
+			if !ok {
+				wait = f.delay(attempt)
+			}
+			// Drain the body so the connection can be reused.
+			io.Copy(io.Discard, io.LimitReader(res.Body, 4096))
+			res.Body.Close()
+			zlog.Debug(ctx).
+				Int("status", res.StatusCode).
+				Int("attempt", attempt+1).
+				Dur("wait", wait).
+				Msg("transient failure, retrying")
+		}
+		if err := f.sleep(ctx, wait); err != nil {
+			return nil, fmt.Errorf("fetch: %w", err)
+		}
+	}
+}
+
+// Limiter returns the rate limiter for the host in "u".
+func (f *Fetcher) limiter(u *url.URL) *rate.Limiter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	l, ok := f.hosts[u.Host]
+	if !ok {
+		l = rate.NewLimiter(f.limit, f.burst)
+		f.hosts[u.Host] = l
+	}
+	return l
+}
+
+// Delay returns the backoff before retrying after "attempt" (counting from
+// zero) failed.
+func (f *Fetcher) delay(attempt int) time.Duration {
+	d := f.backoff
+	for i := 0; i < attempt && d < f.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > f.maxBackoff {
+		d = f.maxBackoff
+	}
+	return f.jitter(d)
+}
+
+// Retryable reports whether a response with the status code "c" should be
+// retried.
+func retryable(c int) bool {
+	switch c {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// RetryAfter parses the value of a "Retry-After" header, which is either a
+// number of seconds or an HTTP date. The returned bool reports false if the
+// value is missing or invalid.
+func retryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(v); err == nil {
+		if s < 0 {
+			return 0, false
+		}
+		return time.Duration(s) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	d := t.Sub(now)
+	if d < 0 {
+		d = 0
+	}
+	return d, true
+}
+
+// Jitter returns a random duration in the range [d/2, d).
+func jitter(d time.Duration) time.Duration {
+	h := d / 2
+	if h <= 0 {
+		return d
+	}
+	return h + time.Duration(rand.Int63n(int64(h)))
+}
+
+// Sleep waits for "d" or until the Context is canceled.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
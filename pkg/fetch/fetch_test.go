@@ -0,0 +1,340 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/quay/zlog"
+	"golang.org/x/time/rate"
+)
+
+// Flaky returns a handler that responds with the status codes in "codes" in
+// order, then with 200 and the body "ok". The Retry-After header is set to
+// "after" on 429 responses, if provided.
+func flaky(t *testing.T, after string, codes ...int) (http.Handler, *int32) {
+	var n int32
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(atomic.AddInt32(&n, 1)) - 1
+		if i < len(codes) {
+			if codes[i] == http.StatusTooManyRequests && after != "" {
+				w.Header().Set("Retry-After", after)
+			}
+			w.WriteHeader(codes[i])
+			return
+		}
+		io.WriteString(w, "ok")
+	}), &n
+}
+
+// MkFetcher returns a Fetcher that records the delays it would sleep for
+// instead of sleeping.
+func mkFetcher(t *testing.T, c *http.Client, opts ...Option) (*Fetcher, *[]time.Duration) {
+	t.Helper()
+	f, err := NewFetcher(c, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var waits []time.Duration
+	f.sleep = func(ctx context.Context, d time.Duration) error {
+		mu.Lock()
+		defer mu.Unlock()
+		waits = append(waits, d)
+		return ctx.Err()
+	}
+	f.jitter = func(d time.Duration) time.Duration { return d }
+	return f, &waits
+}
+
+func TestRetry(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	tcs := []struct {
+		Name   string
+		Codes  []int
+		After  string
+		Status int
+		Reqs   int32
+		Waits  []time.Duration
+		Opts   []Option
+	}{
+		{
+			Name:   "Success",
+			Status: http.StatusOK,
+			Reqs:   1,
+		},
+		{
+			Name:   "Transient",
+			Codes:  []int{http.StatusServiceUnavailable, http.StatusBadGateway},
+			Status: http.StatusOK,
+			Reqs:   3,
+			Waits:  []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			Name:   "RetryAfter",
+			Codes:  []int{http.StatusTooManyRequests},
+			After:  "7",
+			Status: http.StatusOK,
+			Reqs:   2,
+			Waits:  []time.Duration{7 * time.Second},
+		},
+		{
+			Name:   "BadRetryAfter",
+			Codes:  []int{http.StatusTooManyRequests},
+			After:  "soon",
+			Status: http.StatusOK,
+			Reqs:   2,
+			Waits:  []time.Duration{time.Second},
+		},
+		{
+			Name:   "Exhausted",
+			Codes:  []int{500, 500, 500, 500, 500},
+			Status: http.StatusInternalServerError,
+			Reqs:   4,
+			Waits:  []time.Duration{time.Second, 2 * time.Second, 3 * time.Second},
+		},
+		{
+			Name:   "NotRetryable",
+			Codes:  []int{http.StatusNotFound},
+			Status: http.StatusNotFound,
+			Reqs:   1,
+		},
+		{
+			Name:   "RetryStatus",
+			Codes:  []int{http.StatusForbidden},
+			Status: http.StatusOK,
+			Reqs:   2,
+			Waits:  []time.Duration{time.Second},
+			Opts:   []Option{WithRetryStatus(http.StatusForbidden)},
+		},
+	}
+	for _, tc := range tcs {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			h, n := flaky(t, tc.After, tc.Codes...)
+			srv := httptest.NewServer(h)
+			defer srv.Close()
+			opts := append([]Option{WithBackoff(time.Second, 3*time.Second)}, tc.Opts...)
+			f, waits := mkFetcher(t, srv.Client(), opts...)
+			res, err := f.Get(ctx, srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if got, want := res.StatusCode, tc.Status; got != want {
+				t.Errorf("got status %d, want %d", got, want)
+			}
+			if got, want := atomic.LoadInt32(n), tc.Reqs; got != want {
+				t.Errorf("got %d requests, want %d", got, want)
+			}
+			if got, want := *waits, tc.Waits; !cmp.Equal(got, want) {
+				t.Error(cmp.Diff(got, want))
+			}
+			if res.StatusCode == http.StatusOK {
+				b, err := io.ReadAll(res.Body)
+				if err != nil {
+					t.Error(err)
+				}
+				if got, want := string(b), "ok"; got != want {
+					t.Errorf("got body %q, want %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNetworkError(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	// Hijack and close the connection on the first request.
+	var n int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&n, 1) == 1 {
+			c, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			c.Close()
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+	f, waits := mkFetcher(t, srv.Client())
+	res, err := f.Get(ctx, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if got, want := atomic.LoadInt32(&n), int32(2); got != want {
+		t.Errorf("got %d requests, want %d", got, want)
+	}
+	if got, want := len(*waits), 1; got != want {
+		t.Errorf("got %d waits, want %d", got, want)
+	}
+
+	// Once the retries are exhausted, the error is reported.
+	dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&n, 1)
+		c, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		c.Close()
+	}))
+	defer dead.Close()
+	atomic.StoreInt32(&n, 0)
+	f, _ = mkFetcher(t, dead.Client(), WithRetries(1))
+	if _, err := f.Get(ctx, dead.URL); err == nil {
+		t.Error("expected error")
+	}
+	if got, want := atomic.LoadInt32(&n), int32(2); got != want {
+		t.Errorf("got %d requests, want %d", got, want)
+	}
+}
+
+func TestDo(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	var seen int32
+	h, n := flaky(t, "", http.StatusServiceUnavailable)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == "tag" {
+			atomic.AddInt32(&seen, 1)
+		}
+		h.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	f, _ := mkFetcher(t, srv.Client())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("If-None-Match", "tag")
+	res, err := f.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if got, want := atomic.LoadInt32(n), int32(2); got != want {
+		t.Errorf("got %d requests, want %d", got, want)
+	}
+	if got, want := atomic.LoadInt32(&seen), int32(2); got != want {
+		t.Errorf("headers sent on %d requests, want %d", got, want)
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodPost, srv.URL, strings.NewReader("body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Do(req); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestCancel(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	h, n := flaky(t, "3600", http.StatusTooManyRequests)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	f, err := NewFetcher(srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = f.Get(ctx, srv.URL)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if got, want := atomic.LoadInt32(n), int32(1); got != want {
+		t.Errorf("got %d requests, want %d", got, want)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	h, _ := flaky(t, "")
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	const every = 20 * time.Millisecond
+	f, _ := mkFetcher(t, srv.Client(), WithRateLimit(rate.Every(every), 1))
+	const reqs = 5
+	start := time.Now()
+	for i := 0; i < reqs; i++ {
+		res, err := f.Get(ctx, srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+	if got, want := time.Since(start), (reqs-1)*every; got < want {
+		t.Errorf("requests not limited: took %v, want at least %v", got, want)
+	}
+
+	// Another host has its own limit.
+	other := httptest.NewServer(h)
+	defer other.Close()
+	u, err := url.Parse(other.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lim := f.limiter(u); !lim.Allow() {
+		t.Error("limit unexpectedly shared between hosts")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	tcs := []struct {
+		In   string
+		Want time.Duration
+		OK   bool
+	}{
+		{In: "", OK: false},
+		{In: "120", Want: 2 * time.Minute, OK: true},
+		{In: "-1", OK: false},
+		{In: "Sun, 01 Jan 2023 00:00:30 GMT", Want: 30 * time.Second, OK: true},
+		{In: "Sat, 31 Dec 2022 00:00:00 GMT", Want: 0, OK: true},
+		{In: "tomorrow", OK: false},
+	}
+	for _, tc := range tcs {
+		got, ok := retryAfter(tc.In, now)
+		if got != tc.Want || ok != tc.OK {
+			t.Errorf("%q: got (%v, %v), want (%v, %v)", tc.In, got, ok, tc.Want, tc.OK)
+		}
+	}
+}
+
+func TestOptions(t *testing.T) {
+	for _, o := range []Option{
+		WithRetries(-1),
+		WithBackoff(0, time.Second),
+		WithBackoff(time.Second, time.Millisecond),
+		WithRateLimit(0, 1),
+		WithRateLimit(1, 0),
+		WithRetryStatus(1000),
+	} {
+		if _, err := NewFetcher(http.DefaultClient, o); err == nil {
+			t.Error("expected error")
+		}
+	}
+	if _, err := NewFetcher(nil); err == nil {
+		t.Error("expected error for nil client")
+	}
+}
@@ -0,0 +1,361 @@
+// Package ghsa is a client for GitHub Security Advisories, using the GitHub
+// GraphQL API.
+//
+// See https://docs.github.com/en/graphql/reference/objects#securityadvisory
+// for the upstream documentation of the fields used here.
+package ghsa
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/quay/zlog"
+)
+
+// DefaultURL is the GitHub GraphQL endpoint.
+//
+//doc:url updater
+const DefaultURL = `https://api.github.com/graphql`
+
+// ErrNotFound is returned by FetchAdvisory when there's no advisory with the
+// requested ID.
+var ErrNotFound = errors.New("ghsa: advisory not found")
+
+// Advisory is a GitHub Security Advisory.
+type Advisory struct {
+	// ID is the GHSA ID, like "GHSA-jfh8-c2jp-5v3q".
+	ID string
+	// Aliases are the other identifiers of the advisory, like CVE IDs.
+	Aliases     []string
+	Summary     string
+	Description string
+	// Severity is one of "LOW", "MODERATE", "HIGH", or "CRITICAL".
+	Severity  string
+	Permalink string
+	Published time.Time
+	Updated   time.Time
+	// Withdrawn is the zero Time if the advisory has not been withdrawn.
+	Withdrawn time.Time
+	// CVSS holds the advisory's CVSS vectors, if any. The v3 vector is first.
+	CVSS []CVSS
+	// CWEs holds CWE IDs, like "CWE-502".
+	CWEs       []string
+	References []string
+	Affected   []Affected
+}
+
+// CVSS is a CVSS vector and its base score.
+type CVSS struct {
+	Vector string
+	Score  float64
+}
+
+// Affected is a package range affected by an advisory.
+type Affected struct {
+	// Ecosystem is the GitHub name of the package ecosystem, like "MAVEN" or
+	// "PIP".
+	Ecosystem string
+	Package   string
+	// VulnerableRange is the range of affected versions, like ">= 2.0.0, <
+	// 2.15.0".
+	VulnerableRange string
+	// FirstPatched is the first fixed version, if there is one.
+	FirstPatched string
+}
+
+// Client queries the GitHub GraphQL API for advisories.
+type Client struct {
+	c     *http.Client
+	url   string
+	token string
+}
+
+// Option configures a Client.
+type Option func(*Client) error
+
+// WithURL configures the Client to use the GraphQL endpoint at "u" instead
+// of DefaultURL.
+func WithURL(u string) Option {
+	return func(c *Client) error {
+		if u == "" {
+			return errors.New("ghsa: empty url")
+		}
+		c.url = u
+		return nil
+	}
+}
+
+// NewClient returns a Client that authenticates with the bearer token "token".
+func NewClient(c *http.Client, token string, opts ...Option) (*Client, error) {
+	if c == nil {
+		return nil, errors.New("ghsa: nil http.Client")
+	}
+	if token == "" {
+		return nil, errors.New("ghsa: empty token")
+	}
+	cl := &Client{
+		c:     c,
+		url:   DefaultURL,
+		token: token,
+	}
+	for _, o := range opts {
+		if err := o(cl); err != nil {
+			return nil, err
+		}
+	}
+	return cl, nil
+}
+
+// AdvisoryFields is the selection of SecurityAdvisory fields used by all
+// queries.
+const advisoryFields = `
+ghsaId
+summary
+description
+severity
+permalink
+publishedAt
+updatedAt
+withdrawnAt
+identifiers { type value }
+cvssSeverities {
+  cvssV3 { score vectorString }
+  cvssV4 { score vectorString }
+}
+cwes(first: 25) { nodes { cweId } }
+references { url }
+vulnerabilities(first: 100) {
+  nodes {
+    package { ecosystem name }
+    vulnerableVersionRange
+    firstPatchedVersion { identifier }
+  }
+}`
+
+const (
+	fetchQuery = `query($id: String!) {
+securityAdvisory(ghsaId: $id) {` + advisoryFields + `
+}
+}`
+	listQuery = `query($ecosystem: SecurityAdvisoryEcosystem, $since: DateTime, $cursor: String) {
+securityAdvisories(first: 100, after: $cursor, updatedSince: $since, ecosystem: $ecosystem, orderBy: {field: UPDATED_AT, direction: ASC}) {
+  pageInfo { hasNextPage endCursor }
+  nodes {` + advisoryFields + `
+  }
+}
+}`
+)
+
+// FetchAdvisory returns the advisory with the GHSA ID "id".
+//
+// ErrNotFound is returned if there is no such advisory.
+func (c *Client) FetchAdvisory(ctx context.Context, id string) (*Advisory, error) {
+	ctx = zlog.ContextWithValues(ctx, "component", "pkg/ghsa/Client.FetchAdvisory")
+	var res struct {
+		SecurityAdvisory *advisory `json:"securityAdvisory"`
+	}
+	if err := c.query(ctx, fetchQuery, map[string]any{"id": id}, &res); err != nil {
+		return nil, err
+	}
+	if res.SecurityAdvisory == nil {
+		return nil, fmt.Errorf("%w: %q", ErrNotFound, id)
+	}
+	return res.SecurityAdvisory.Advisory(), nil
+}
+
+// ListAdvisories returns all the advisories for "ecosystem" updated since
+// "since", in update order.
+//
+// The ecosystem is a GitHub ecosystem name, like "MAVEN" or "PIP", and is
+// case-insensitive. If the ecosystem is empty, advisories for all ecosystems
+// are returned. If "since" is the zero Time, all advisories are returned.
+func (c *Client) ListAdvisories(ctx context.Context, ecosystem string, since time.Time) ([]*Advisory, error) {
+	ctx = zlog.ContextWithValues(ctx, "component", "pkg/ghsa/Client.ListAdvisories")
+	vars := map[string]any{}
+	if ecosystem != "" {
+		vars["ecosystem"] = strings.ToUpper(ecosystem)
+	}
+	if !since.IsZero() {
+		vars["since"] = since.UTC().Format(time.RFC3339)
+	}
+	var out []*Advisory
+	for page := 1; ; page++ {
+		var res struct {
+			SecurityAdvisories struct {
+				PageInfo struct {
+					HasNextPage bool   `json:"hasNextPage"`
+					EndCursor   string `json:"endCursor"`
+				} `json:"pageInfo"`
+				Nodes []advisory `json:"nodes"`
+			} `json:"securityAdvisories"`
+		}
+		if err := c.query(ctx, listQuery, vars, &res); err != nil {
+			return out, err
+		}
+		for i := range res.SecurityAdvisories.Nodes {
+			out = append(out, res.SecurityAdvisories.Nodes[i].Advisory())
+		}
+		pi := res.SecurityAdvisories.PageInfo
+		zlog.Debug(ctx).
+			Int("page", page).
+			Int("count", len(res.SecurityAdvisories.Nodes)).
+			Bool("more", pi.HasNextPage).
+			Msg("fetched page")
+		if !pi.HasNextPage {
+			break
+		}
+		if pi.EndCursor == "" {
+			return out, errors.New("ghsa: missing cursor for next page")
+		}
+		vars["cursor"] = pi.EndCursor
+	}
+	return out, nil
+}
+
+// Query sends a GraphQL request and decodes the "data" member of the
+// response into "data".
+func (c *Client) query(ctx context.Context, q string, vars map[string]any, data any) error {
+	body, err := json.Marshal(struct {
+		Query     string         `json:"query"`
+		Variables map[string]any `json:"variables,omitempty"`
+	}{q, vars})
+	if err != nil {
+		return fmt.Errorf("ghsa: unable to encode request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("ghsa: unable to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	res, err := c.c.Do(req)
+	if err != nil {
+		return fmt.Errorf("ghsa: request failed: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 256))
+		return fmt.Errorf("ghsa: unexpected response: %s (body: %q)", res.Status, msg)
+	}
+	var r struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return fmt.Errorf("ghsa: unable to decode response: %w", err)
+	}
+	if len(r.Errors) != 0 {
+		msgs := make([]string, len(r.Errors))
+		for i, e := range r.Errors {
+			msgs[i] = e.Message
+		}
+		return fmt.Errorf("ghsa: query error: %s", strings.Join(msgs, "; "))
+	}
+	if err := json.Unmarshal(r.Data, data); err != nil {
+		return fmt.Errorf("ghsa: unable to decode response data: %w", err)
+	}
+	return nil
+}
+
+// Advisory is the GraphQL SecurityAdvisory object.
+type advisory struct {
+	GHSAID      string     `json:"ghsaId"`
+	Summary     string     `json:"summary"`
+	Description string     `json:"description"`
+	Severity    string     `json:"severity"`
+	Permalink   string     `json:"permalink"`
+	PublishedAt time.Time  `json:"publishedAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	WithdrawnAt *time.Time `json:"withdrawnAt"`
+	Identifiers []struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	} `json:"identifiers"`
+	CVSSSeverities struct {
+		CVSSV3 *cvss `json:"cvssV3"`
+		CVSSV4 *cvss `json:"cvssV4"`
+	} `json:"cvssSeverities"`
+	CWEs struct {
+		Nodes []struct {
+			CWEID string `json:"cweId"`
+		} `json:"nodes"`
+	} `json:"cwes"`
+	References []struct {
+		URL string `json:"url"`
+	} `json:"references"`
+	Vulnerabilities struct {
+		Nodes []struct {
+			Package struct {
+				Ecosystem string `json:"ecosystem"`
+				Name      string `json:"name"`
+			} `json:"package"`
+			VulnerableVersionRange string `json:"vulnerableVersionRange"`
+			FirstPatchedVersion    *struct {
+				Identifier string `json:"identifier"`
+			} `json:"firstPatchedVersion"`
+		} `json:"nodes"`
+	} `json:"vulnerabilities"`
+}
+
+// Cvss is the GraphQL CVSS object. Advisories without a vector report an
+// empty vector string.
+type cvss struct {
+	Score        float64 `json:"score"`
+	VectorString string  `json:"vectorString"`
+}
+
+// Advisory converts the GraphQL object into an Advisory.
+func (a *advisory) Advisory() *Advisory {
+	out := Advisory{
+		ID:          a.GHSAID,
+		Summary:     a.Summary,
+		Description: a.Description,
+		Severity:    a.Severity,
+		Permalink:   a.Permalink,
+		Published:   a.PublishedAt,
+		Updated:     a.UpdatedAt,
+	}
+	if a.WithdrawnAt != nil {
+		out.Withdrawn = *a.WithdrawnAt
+	}
+	for _, id := range a.Identifiers {
+		if id.Type == "GHSA" {
+			continue
+		}
+		out.Aliases = append(out.Aliases, id.Value)
+	}
+	for _, c := range []*cvss{a.CVSSSeverities.CVSSV3, a.CVSSSeverities.CVSSV4} {
+		if c == nil || c.VectorString == "" {
+			continue
+		}
+		out.CVSS = append(out.CVSS, CVSS{Vector: c.VectorString, Score: c.Score})
+	}
+	for _, n := range a.CWEs.Nodes {
+		out.CWEs = append(out.CWEs, n.CWEID)
+	}
+	for _, r := range a.References {
+		out.References = append(out.References, r.URL)
+	}
+	for _, n := range a.Vulnerabilities.Nodes {
+		af := Affected{
+			Ecosystem:       n.Package.Ecosystem,
+			Package:         n.Package.Name,
+			VulnerableRange: n.VulnerableVersionRange,
+		}
+		if n.FirstPatchedVersion != nil {
+			af.FirstPatched = n.FirstPatchedVersion.Identifier
+		}
+		out.Affected = append(out.Affected, af)
+	}
+	return &out
+}
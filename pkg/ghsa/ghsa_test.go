@@ -0,0 +1,176 @@
+package ghsa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/quay/zlog"
+)
+
+const testToken = `test-token`
+
+// Replay is a GraphQL server that answers with the recorded responses in
+// testdata.
+//
+// Fetch queries are answered with "testdata/<id>.json", if it exists, and
+// list queries with "testdata/list-<page>.json".
+type replay struct {
+	t *testing.T
+	// Cursors maps a pagination cursor to the page it starts.
+	cursors map[string]int
+	// Vars records the variables of every request.
+	vars []map[string]any
+}
+
+func (r *replay) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if got, want := req.Header.Get("Authorization"), "Bearer "+testToken; got != want {
+		http.Error(w, `{"message":"Bad credentials"}`, http.StatusUnauthorized)
+		return
+	}
+	var q struct {
+		Query     string         `json:"query"`
+		Variables map[string]any `json:"variables"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&q); err != nil {
+		r.t.Error(err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.vars = append(r.vars, q.Variables)
+	var name string
+	switch {
+	case strings.Contains(q.Query, "securityAdvisory("):
+		id, _ := q.Variables["id"].(string)
+		name = id + ".json"
+		if m, _ := filepath.Glob(filepath.Join("testdata", name)); len(m) == 0 {
+			name = "notfound.json"
+		}
+	case strings.Contains(q.Query, "securityAdvisories("):
+		page := 1
+		if c, ok := q.Variables["cursor"].(string); ok {
+			page = r.cursors[c]
+		}
+		name = "list-" + strconv.Itoa(page) + ".json"
+	default:
+		http.Error(w, "unknown query", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	http.ServeFile(w, req, filepath.Join("testdata", name))
+}
+
+func newTestClient(t *testing.T, token string) (*Client, *replay) {
+	t.Helper()
+	r := &replay{
+		t: t,
+		cursors: map[string]int{
+			"Y3Vyc29yOnYyOpK5MjAyNC0wMS0xMFQxMjowMDowMFo=": 2,
+		},
+	}
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	c, err := NewClient(srv.Client(), token, WithURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, r
+}
+
+func TestFetchAdvisory(t *testing.T) {
+	ctx := zlog.Test(context.Background(), t)
+	c, _ := newTestClient(t, testToken)
+
+	got, err := c.FetchAdvisory(ctx, "GHSA-jfh8-c2jp-5v3q")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Advisory{
+		ID:          "GHSA-jfh8-c2jp-5v3q",
+		Aliases:     []string{"CVE-2021-44228"},
+		Summary:     "Remote code injection in Log4j",
+		Description: "Apache Log4j2 JNDI features used in configuration, log messages, and parameters do not protect against attacker controlled LDAP and other JNDI related endpoints.",
+		Severity:    "CRITICAL",
+		Permalink:   "https://github.com/advisories/GHSA-jfh8-c2jp-5v3q",
+		Published:   time.Date(2021, 12, 10, 0, 40, 56, 0, time.UTC),
+		Updated:     time.Date(2024, 2, 22, 21, 53, 29, 0, time.UTC),
+		CVSS: []CVSS{
+			{Vector: "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:C/C:H/I:H/A:H", Score: 10},
+		},
+		CWEs: []string{"CWE-20", "CWE-502", "CWE-917"},
+		References: []string{
+			"https://nvd.nist.gov/vuln/detail/CVE-2021-44228",
+			"https://logging.apache.org/log4j/2.x/security.html",
+		},
+		Affected: []Affected{
+			{Ecosystem: "MAVEN", Package: "org.apache.logging.log4j:log4j-core", VulnerableRange: ">= 2.13.0, < 2.15.0", FirstPatched: "2.15.0"},
+			{Ecosystem: "MAVEN", Package: "org.apache.logging.log4j:log4j-core", VulnerableRange: "< 2.3.1", FirstPatched: "2.3.1"},
+		},
+	}
+	if !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(got, want))
+	}
+
+	_, err = c.FetchAdvisory(ctx, "GHSA-0000-0000-0000")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestListAdvisories(t *testing.T) {
+	ctx := zlog.Test(context.Background(), t)
+	c, r := newTestClient(t, testToken)
+
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	as, err := c.ListAdvisories(ctx, "pip", since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, a := range as {
+		ids = append(ids, a.ID)
+	}
+	wantIDs := []string{"GHSA-xxxx-0001-0001", "GHSA-xxxx-0002-0002", "GHSA-xxxx-0003-0003"}
+	if !cmp.Equal(ids, wantIDs) {
+		t.Error(cmp.Diff(ids, wantIDs))
+	}
+	if got, want := len(as[0].CVSS), 2; got != want {
+		t.Errorf("got %d CVSS vectors, want %d", got, want)
+	}
+	if got := as[1].CVSS; len(got) != 0 {
+		t.Errorf("unexpected CVSS vectors: %v", got)
+	}
+	if got, want := as[1].Affected[0].FirstPatched, ""; got != want {
+		t.Errorf("got first patched %q, want %q", got, want)
+	}
+	if got, want := as[2].Withdrawn, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("got withdrawn %v, want %v", got, want)
+	}
+
+	wantVars := []map[string]any{
+		{"ecosystem": "PIP", "since": "2024-01-01T00:00:00Z"},
+		{"ecosystem": "PIP", "since": "2024-01-01T00:00:00Z", "cursor": "Y3Vyc29yOnYyOpK5MjAyNC0wMS0xMFQxMjowMDowMFo="},
+	}
+	if !cmp.Equal(r.vars, wantVars) {
+		t.Error(cmp.Diff(r.vars, wantVars))
+	}
+}
+
+func TestBadToken(t *testing.T) {
+	ctx := zlog.Test(context.Background(), t)
+	c, _ := newTestClient(t, "wrong-token")
+	if _, err := c.FetchAdvisory(ctx, "GHSA-jfh8-c2jp-5v3q"); err == nil {
+		t.Error("expected error for bad token")
+	}
+	if _, err := NewClient(http.DefaultClient, ""); err == nil {
+		t.Error("expected error for empty token")
+	}
+}
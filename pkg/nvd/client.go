@@ -0,0 +1,107 @@
+package nvd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/quay/claircore/pkg/errs"
+	"github.com/quay/claircore/pkg/fetch"
+)
+
+// Client makes requests to the NVD CVE API, waiting between requests to stay
+// within the API's rate limits and retrying throttled requests.
+//
+// A Client is safe for concurrent use. Users of the API in the same process
+// should share a Client, so that their requests are limited together.
+type Client struct {
+	url *url.URL
+	f   *fetch.Fetcher
+}
+
+// NewClient returns a Client for the API at "u", or DefaultURL if "u" is
+// empty. If "key" is not empty, it's sent as the API key with every request,
+// which allows requests to be made every APIKeyDelay instead of every
+// DefaultDelay.
+//
+// The options in "opts" are passed to [fetch.NewFetcher] after the Client's
+// own, so they may be used to override them.
+func NewClient(c *http.Client, u, key string, opts ...fetch.Option) (*Client, error) {
+	if c == nil {
+		return nil, fmt.Errorf("nvd: nil http.Client")
+	}
+	if u == "" {
+		u = DefaultURL
+	}
+	pu, err := url.Parse(u)
+	if err != nil {
+		return nil, fmt.Errorf("nvd: invalid url: %w", err)
+	}
+	delay := DefaultDelay
+	if key != "" {
+		delay = APIKeyDelay
+		kc := *c
+		kc.Transport = &keyTransport{key: key, next: kc.Transport}
+		c = &kc
+	}
+	opts = append([]fetch.Option{
+		fetch.WithRateLimit(rate.Every(delay), 1),
+		// The API responds with a 403 when throttling requests.
+		fetch.WithRetryStatus(http.StatusForbidden),
+		fetch.WithRetries(5),
+		fetch.WithBackoff(2*time.Second, fetch.DefaultMaxBackoff),
+	}, opts...)
+	f, err := fetch.NewFetcher(c, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("nvd: %w", err)
+	}
+	return &Client{url: pu, f: f}, nil
+}
+
+// KeyTransport adds the NVD API key header to requests.
+type keyTransport struct {
+	key  string
+	next http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *keyTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	r = r.Clone(r.Context())
+	r.Header.Set("apiKey", t.key)
+	return next.RoundTrip(r)
+}
+
+// Get requests the API with the query parameters "q" and decodes the JSON
+// response into "v".
+//
+// Failed requests are reported as an [errs.FetchError] and undecodable
+// responses as an [errs.ParseError].
+func (c *Client) Get(ctx context.Context, q url.Values, v interface{}) error {
+	u := *c.url
+	uq := u.Query()
+	for k, vs := range q {
+		uq[k] = vs
+	}
+	u.RawQuery = uq.Encode()
+	res, err := c.f.Get(ctx, u.String())
+	if err != nil {
+		return fmt.Errorf("nvd: %w", &errs.FetchError{URL: u.String(), Err: err})
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("nvd: %w", &errs.FetchError{URL: u.String(), StatusCode: res.StatusCode})
+	}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return fmt.Errorf("nvd: %w", &errs.ParseError{Offset: -1, Err: err})
+	}
+	return nil
+}
@@ -0,0 +1,468 @@
+// Package nvd fills in missing CVSS data for vulnerabilities from the National
+// Vulnerability Database's CVE API.
+//
+// Some databases omit CVSS scores for older advisories. The NVD has scores for
+// almost every CVE, so it makes a useful fallback. The API is rate limited;
+// see https://nvd.nist.gov/developers/start-here for details and for how to
+// request an API key.
+package nvd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/pkg/cvss"
+)
+
+// DefaultURL is the NVD CVE API 2.0 endpoint.
+//
+//doc:url updater
+const DefaultURL = `https://services.nvd.nist.gov/rest/json/cves/2.0`
+
+// These are the delays between requests.
+//
+// The NVD allows 5 requests in a rolling 30 second window without an API key
+// and recommends waiting 6 seconds between requests; with a key, 50 requests
+// are allowed in the same window.
+const (
+	DefaultDelay = 6 * time.Second
+	APIKeyDelay  = 600 * time.Millisecond
+)
+
+// Enricher fetches CVSS data from the NVD for vulnerabilities lacking it.
+//
+// An Enricher is safe for concurrent use, but requests are serialized to stay
+// within the NVD's rate limits.
+type Enricher struct {
+	api *Client
+	url *url.URL
+	key string
+
+	// If cachePath is set, records are remembered in the file there.
+	cachePath string
+	mu        sync.Mutex
+	cache     map[string]record // nil until loaded
+	dirty     bool
+}
+
+// Option configures an Enricher.
+type Option func(*Enricher) error
+
+// WithURL configures the Enricher to use the API at "u" instead of
+// DefaultURL.
+func WithURL(u string) Option {
+	return func(e *Enricher) error {
+		var err error
+		e.url, err = url.Parse(u)
+		if err != nil {
+			return fmt.Errorf("nvd: invalid url: %w", err)
+		}
+		return nil
+	}
+}
+
+// WithAPIKey configures the Enricher to send "key" with every request, which
+// allows requests to be made every APIKeyDelay instead of every DefaultDelay.
+func WithAPIKey(key string) Option {
+	return func(e *Enricher) error {
+		if key == "" {
+			return errors.New("nvd: empty api key")
+		}
+		e.key = key
+		return nil
+	}
+}
+
+// WithClient configures the Enricher to make requests with "c", so that its
+// requests are rate limited together with those of other users of "c". It
+// can't be used with WithURL or WithAPIKey.
+func WithClient(c *Client) Option {
+	return func(e *Enricher) error {
+		if c == nil {
+			return errors.New("nvd: nil Client")
+		}
+		e.api = c
+		return nil
+	}
+}
+
+// WithCache configures the Enricher to remember the NVD's data for each CVE
+// in the JSON file at "path", so that repeated runs don't request the same CVE
+// again. The file and its directory are created if needed.
+//
+// CVEs the NVD has no CVSS data for are not remembered, as they may be scored
+// later.
+func WithCache(path string) Option {
+	return func(e *Enricher) error {
+		if path == "" {
+			return errors.New("nvd: empty cache path")
+		}
+		e.cachePath = path
+		return nil
+	}
+}
+
+// NewEnricher returns an Enricher using the provided client.
+func NewEnricher(c *http.Client, opts ...Option) (*Enricher, error) {
+	if c == nil {
+		return nil, fmt.Errorf("nvd: nil http.Client")
+	}
+	e := &Enricher{}
+	for _, o := range opts {
+		if err := o(e); err != nil {
+			return nil, err
+		}
+	}
+	if e.api != nil {
+		if e.url != nil || e.key != "" {
+			return nil, errors.New("nvd: WithClient used with WithURL or WithAPIKey")
+		}
+		return e, nil
+	}
+	var u string
+	if e.url != nil {
+		u = e.url.String()
+	}
+	var err error
+	e.api, err = NewClient(c, u, e.key)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// CVEPattern matches CVE IDs.
+var cvePattern = regexp.MustCompile(`(?i)CVE-\d{4}-\d{4,}`)
+
+// CveIDs returns the CVE IDs mentioned in the vulnerability's Name and Links,
+// in that order.
+func cveIDs(v *claircore.Vulnerability) []string {
+	seen := make(map[string]struct{})
+	var ret []string
+	for _, s := range []string{v.Name, v.Links} {
+		for _, m := range cvePattern.FindAllString(s, -1) {
+			id := strings.ToUpper(m)
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			seen[id] = struct{}{}
+			ret = append(ret, id)
+		}
+	}
+	return ret
+}
+
+// EmptyTTL is how long a record without any CVSS data is cached, as the NVD
+// usually scores new CVEs within days of their publication.
+const emptyTTL = 24 * time.Hour
+
+// Enrich fills in the CVSS fields of the vulnerabilities in "vs" that have no
+// CVSS vectors at all, using the NVD's data for the CVEs in the
+// vulnerability's Name and Links. If there are several CVEs with data, as for
+// an advisory fixing several flaws, the one with the highest base score is
+// used. Vulnerabilities with any CVSS vector or without a CVE are left alone.
+// If a filled-in vulnerability has no Severity, it's set to the qualitative
+// rating of the most recent CVSS version, as is an Unknown
+// NormalizedSeverity.
+//
+// CVEs the NVD has no CVSS data for are also cached, but only for a day.
+//
+// An error is returned if any request fails; vulnerabilities for CVEs fetched
+// before the failure are still updated and cached.
+func (e *Enricher) Enrich(ctx context.Context, vs []*claircore.Vulnerability) error {
+	ctx = zlog.ContextWithValues(ctx, "component", "pkg/nvd/Enricher.Enrich")
+	byVuln := make(map[*claircore.Vulnerability][]string)
+	seen := make(map[string]struct{})
+	var ids []string
+	for _, v := range vs {
+		if v.CVSSv2Vector != "" || v.CVSSv3Vector != "" || v.CVSSv4Vector != "" {
+			continue
+		}
+		vids := cveIDs(v)
+		if len(vids) == 0 {
+			continue
+		}
+		byVuln[v] = vids
+		for _, id := range vids {
+			if _, ok := seen[id]; !ok {
+				seen[id] = struct{}{}
+				ids = append(ids, id)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.loadCache(); err != nil {
+		return err
+	}
+	defer func() {
+		if err := e.saveCache(); err != nil {
+			zlog.Warn(ctx).Err(err).Msg("unable to write cache")
+		}
+	}()
+	apply := func() {
+		for _, v := range vs {
+			vids, ok := byVuln[v]
+			if !ok {
+				continue
+			}
+			var best record
+			bestScore := -1.0
+			for _, id := range vids {
+				rec, ok := e.cache[id]
+				if !ok || rec.empty() {
+					continue
+				}
+				if s := rec.score(); s > bestScore {
+					best, bestScore = rec, s
+				}
+			}
+			best.apply(v)
+		}
+	}
+	// Apply whatever was fetched, even on error.
+	defer apply()
+	now := time.Now()
+	var fetched int
+	for _, id := range ids {
+		rec, ok := e.cache[id]
+		if ok && (!rec.empty() || now.Sub(rec.Checked) < emptyTTL) {
+			continue
+		}
+		rec, err := e.fetch(ctx, id)
+		if err != nil {
+			return err
+		}
+		fetched++
+		if rec.empty() {
+			rec.Checked = now
+		}
+		e.cache[id] = rec
+		e.dirty = true
+	}
+	zlog.Debug(ctx).
+		Int("cves", len(ids)).
+		Int("fetched", fetched).
+		Msg("enriched vulnerabilities")
+	return nil
+}
+
+// Record is the CVSS data the NVD has for a CVE. It's also the format of the
+// cache file's values.
+type record struct {
+	V2 string `json:"v2,omitempty"`
+	V3 string `json:"v3,omitempty"`
+	V4 string `json:"v4,omitempty"`
+	// Checked is when an empty record was fetched.
+	Checked time.Time `json:"checked,omitempty"`
+}
+
+// Empty reports whether the record has no vectors.
+func (r record) empty() bool { return r.V2 == "" && r.V3 == "" && r.V4 == "" }
+
+// Score returns the highest base score of the record's vectors.
+func (r record) score() float64 {
+	var v claircore.Vulnerability
+	r.apply(&v)
+	s := v.CVSSv2Score
+	for _, f := range []float64{v.CVSSv3Score, v.CVSSv4Score} {
+		if f > s {
+			s = f
+		}
+	}
+	return s
+}
+
+// Apply sets the CVSS fields of "v" from the record. Vectors that don't parse
+// are skipped.
+func (r record) apply(v *claircore.Vulnerability) {
+	var label string
+	if r.V2 != "" {
+		if vec, err := cvss.ParseV2(r.V2); err == nil {
+			v.CVSSv2Vector, v.CVSSv2Score = r.V2, vec.BaseScore()
+			label = vec.Severity()
+		}
+	}
+	if r.V3 != "" {
+		if vec, err := cvss.ParseV3(r.V3); err == nil {
+			v.CVSSv3Vector, v.CVSSv3Score = r.V3, vec.BaseScore()
+			label = vec.Severity()
+		}
+	}
+	if r.V4 != "" {
+		if vec, err := cvss.ParseV4(r.V4); err == nil {
+			v.CVSSv4Vector, v.CVSSv4Score = r.V4, vec.BaseScore()
+			label = vec.Severity()
+		}
+	}
+	if label == "" {
+		return
+	}
+	if v.Severity == "" {
+		v.Severity = label
+	}
+	if v.NormalizedSeverity == claircore.Unknown {
+		v.NormalizedSeverity = fromLabel(label)
+	}
+}
+
+// FromLabel maps a qualitative severity rating to a claircore.Severity.
+func fromLabel(l string) claircore.Severity {
+	switch l {
+	case cvss.SeverityNone:
+		return claircore.Negligible
+	case cvss.SeverityLow:
+		return claircore.Low
+	case cvss.SeverityMedium:
+		return claircore.Medium
+	case cvss.SeverityHigh:
+		return claircore.High
+	case cvss.SeverityCritical:
+		return claircore.Critical
+	}
+	return claircore.Unknown
+}
+
+// Response is the subset of the API response used here.
+type response struct {
+	TotalResults    int `json:"totalResults"`
+	Vulnerabilities []struct {
+		CVE struct {
+			ID      string `json:"id"`
+			Metrics struct {
+				V40 []metric `json:"cvssMetricV40"`
+				V31 []metric `json:"cvssMetricV31"`
+				V30 []metric `json:"cvssMetricV30"`
+				V2  []metric `json:"cvssMetricV2"`
+			} `json:"metrics"`
+		} `json:"cve"`
+	} `json:"vulnerabilities"`
+}
+
+// Metric is a CVSS metric block. A CVE may have metrics from several sources.
+type metric struct {
+	Source string `json:"source"`
+	// Type is "Primary" for the NVD's own analysis and "Secondary" for
+	// others, like the CNA's.
+	Type string `json:"type"`
+	Data struct {
+		Version string `json:"version"`
+		Vector  string `json:"vectorString"`
+	} `json:"cvssData"`
+}
+
+// Pick returns the vector from the primary metric in "ms", or from the first
+// one if there's no primary.
+func pick(ms []metric) string {
+	for _, m := range ms {
+		if m.Type == "Primary" {
+			return m.Data.Vector
+		}
+	}
+	if len(ms) != 0 {
+		return ms[0].Data.Vector
+	}
+	return ""
+}
+
+// Fetch requests the NVD's data for the CVE "id".
+func (e *Enricher) fetch(ctx context.Context, id string) (record, error) {
+	var r response
+	if err := e.api.Get(ctx, url.Values{"cveId": {id}}, &r); err != nil {
+		return record{}, err
+	}
+	var rec record
+	for _, v := range r.Vulnerabilities {
+		if v.CVE.ID != id {
+			continue
+		}
+		m := &v.CVE.Metrics
+		rec.V2 = pick(m.V2)
+		rec.V3 = pick(m.V31)
+		if rec.V3 == "" {
+			rec.V3 = pick(m.V30)
+		}
+		rec.V4 = pick(m.V40)
+	}
+	return rec, nil
+}
+
+// LoadCache reads the cache file, if configured and not already loaded. A
+// missing file is not an error.
+//
+// The caller must hold e.mu.
+func (e *Enricher) loadCache() error {
+	if e.cache != nil {
+		return nil
+	}
+	e.cache = make(map[string]record)
+	if e.cachePath == "" {
+		return nil
+	}
+	b, err := os.ReadFile(e.cachePath)
+	switch {
+	case errors.Is(err, nil):
+	case errors.Is(err, fs.ErrNotExist):
+		return nil
+	default:
+		return fmt.Errorf("nvd: unable to read cache: %w", err)
+	}
+	if err := json.Unmarshal(b, &e.cache); err != nil {
+		return fmt.Errorf("nvd: unable to read cache: %w", err)
+	}
+	return nil
+}
+
+// SaveCache writes the cache file, if configured and changed since it was
+// last written.
+//
+// The caller must hold e.mu.
+func (e *Enricher) saveCache() error {
+	if e.cachePath == "" || !e.dirty {
+		return nil
+	}
+	b, err := json.Marshal(e.cache)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(e.cachePath)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	// Write and rename, so that other processes never see a partial file.
+	f, err := os.CreateTemp(dir, filepath.Base(e.cachePath)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), e.cachePath); err != nil {
+		return err
+	}
+	e.dirty = false
+	return nil
+}
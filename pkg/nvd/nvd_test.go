@@ -0,0 +1,308 @@
+package nvd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/quay/zlog"
+	"golang.org/x/time/rate"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/pkg/errs"
+	"github.com/quay/claircore/pkg/fetch"
+)
+
+// NewServer returns a server answering requests from the canned response in
+// testdata, and a counter of requests made. Requests must carry the API key
+// "key", if it's not empty.
+func newServer(t *testing.T, key string) (*httptest.Server, *int64) {
+	t.Helper()
+	f, err := os.Open("testdata/cves.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var canned map[string]json.RawMessage
+	if err := json.NewDecoder(f).Decode(&canned); err != nil {
+		t.Fatal(err)
+	}
+	var vulns []struct {
+		CVE struct {
+			ID string `json:"id"`
+		} `json:"cve"`
+	}
+	if err := json.Unmarshal(canned["vulnerabilities"], &vulns); err != nil {
+		t.Fatal(err)
+	}
+	var all []json.RawMessage
+	if err := json.Unmarshal(canned["vulnerabilities"], &all); err != nil {
+		t.Fatal(err)
+	}
+	var ct int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&ct, 1)
+		if r.URL.Path != "/rest/json/cves/2.0" {
+			http.NotFound(w, r)
+			return
+		}
+		if got := r.Header.Get("apiKey"); got != key {
+			t.Errorf("apiKey: got %q, want %q", got, key)
+		}
+		id := r.URL.Query().Get("cveId")
+		res := make(map[string]interface{}, len(canned))
+		for k, v := range canned {
+			res[k] = v
+		}
+		found := []json.RawMessage{}
+		for i, v := range vulns {
+			if v.CVE.ID == id {
+				found = append(found, all[i])
+			}
+		}
+		res["vulnerabilities"] = found
+		res["totalResults"] = len(found)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			t.Error(err)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &ct
+}
+
+// MkEnricher returns an Enricher for the server "srv" that doesn't wait
+// between requests.
+func mkEnricher(t *testing.T, srv *httptest.Server, opts ...Option) *Enricher {
+	t.Helper()
+	opts = append([]Option{WithURL(srv.URL + "/rest/json/cves/2.0")}, opts...)
+	e, err := NewEnricher(srv.Client(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.api, err = NewClient(srv.Client(), e.url.String(), e.key, fetch.WithRateLimit(rate.Inf, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestEnrich(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	srv, ct := newServer(t, "")
+	e := mkEnricher(t, srv)
+
+	vs := []*claircore.Vulnerability{
+		{Name: "CVE-2014-0160"},
+		{Name: "RHSA-2014:0376: openssl security update (Important) CVE-2014-0160", Severity: "Important", NormalizedSeverity: claircore.High},
+		{Name: "RHSA-2021:5206: example security update", Links: "https://access.redhat.com/security/cve/CVE-2014-0160 https://access.redhat.com/security/cve/cve-2021-44228"},
+		{Name: "CVE-2021-44228"},
+		{Name: "CVE-2024-0001"},
+		{Name: "CVE-2099-99999"},
+		{Name: "GHSA-jfh8-c2jp-5v3q"},
+		{Name: "CVE-2021-44228", CVSSv3Vector: "CVSS:3.1/AV:N/AC:H/PR:N/UI:N/S:C/C:H/I:H/A:H", CVSSv3Score: 9.0},
+	}
+	if err := e.Enrich(ctx, vs); err != nil {
+		t.Fatal(err)
+	}
+	want := []*claircore.Vulnerability{
+		{
+			Name:               "CVE-2014-0160",
+			Severity:           "High",
+			NormalizedSeverity: claircore.High,
+			CVSSv2Vector:       "AV:N/AC:L/Au:N/C:P/I:N/A:N",
+			CVSSv2Score:        5.0,
+			CVSSv3Vector:       "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N",
+			CVSSv3Score:        7.5,
+		},
+		{
+			Name:               "RHSA-2014:0376: openssl security update (Important) CVE-2014-0160",
+			Severity:           "Important",
+			NormalizedSeverity: claircore.High,
+			CVSSv2Vector:       "AV:N/AC:L/Au:N/C:P/I:N/A:N",
+			CVSSv2Score:        5.0,
+			CVSSv3Vector:       "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N",
+			CVSSv3Score:        7.5,
+		},
+		{
+			// CVEs are also found in the links, and the highest scoring
+			// one is used.
+			Name:               "RHSA-2021:5206: example security update",
+			Links:              "https://access.redhat.com/security/cve/CVE-2014-0160 https://access.redhat.com/security/cve/cve-2021-44228",
+			Severity:           "Critical",
+			NormalizedSeverity: claircore.Critical,
+			CVSSv2Vector:       "AV:N/AC:M/Au:N/C:C/I:C/A:C",
+			CVSSv2Score:        9.3,
+			CVSSv3Vector:       "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:C/C:H/I:H/A:H",
+			CVSSv3Score:        10.0,
+		},
+		{
+			// The NVD's own ("Primary") score is preferred.
+			Name:               "CVE-2021-44228",
+			Severity:           "Critical",
+			NormalizedSeverity: claircore.Critical,
+			CVSSv2Vector:       "AV:N/AC:M/Au:N/C:C/I:C/A:C",
+			CVSSv2Score:        9.3,
+			CVSSv3Vector:       "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:C/C:H/I:H/A:H",
+			CVSSv3Score:        10.0,
+		},
+		{Name: "CVE-2024-0001"},
+		{Name: "CVE-2099-99999"},
+		{Name: "GHSA-jfh8-c2jp-5v3q"},
+		{Name: "CVE-2021-44228", CVSSv3Vector: "CVSS:3.1/AV:N/AC:H/PR:N/UI:N/S:C/C:H/I:H/A:H", CVSSv3Score: 9.0},
+	}
+	if !cmp.Equal(vs, want) {
+		t.Error(cmp.Diff(vs, want))
+	}
+	// One request per distinct CVE without CVSS data.
+	if got, want := atomic.LoadInt64(ct), int64(4); got != want {
+		t.Errorf("requests: got %d, want %d", got, want)
+	}
+}
+
+func TestCache(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	srv, ct := newServer(t, "")
+	path := filepath.Join(t.TempDir(), "nvd", "cache.json")
+	mk := func() []*claircore.Vulnerability {
+		return []*claircore.Vulnerability{
+			{Name: "CVE-2014-0160"},
+			{Name: "CVE-2021-44228"},
+			{Name: "CVE-2024-0001"},
+		}
+	}
+
+	first := mk()
+	if err := mkEnricher(t, srv, WithCache(path)).Enrich(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := atomic.LoadInt64(ct), int64(3); got != want {
+		t.Errorf("requests: got %d, want %d", got, want)
+	}
+
+	// A new Enricher with the same cache doesn't ask about any of them,
+	// including the CVE the NVD had nothing for.
+	second := mk()
+	if err := mkEnricher(t, srv, WithCache(path)).Enrich(ctx, second); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := atomic.LoadInt64(ct), int64(3); got != want {
+		t.Errorf("requests: got %d, want %d", got, want)
+	}
+	if !cmp.Equal(first, second) {
+		t.Error(cmp.Diff(first, second))
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c map[string]record
+	if err := json.Unmarshal(b, &c); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(c), 3; got != want {
+		t.Errorf("cached records: got %d, want %d", got, want)
+	}
+
+	// Once the empty record expires, it's asked about again.
+	rec := c["CVE-2024-0001"]
+	if !rec.empty() || rec.Checked.IsZero() {
+		t.Fatalf("unexpected record: %+v", rec)
+	}
+	rec.Checked = rec.Checked.Add(-emptyTTL)
+	c["CVE-2024-0001"] = rec
+	b, err = json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := mkEnricher(t, srv, WithCache(path)).Enrich(ctx, mk()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := atomic.LoadInt64(ct), int64(4); got != want {
+		t.Errorf("requests: got %d, want %d", got, want)
+	}
+
+	if _, err := NewEnricher(srv.Client(), WithCache("")); err == nil {
+		t.Error("expected error for empty cache path")
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	const key = "00000000-0000-0000-0000-000000000000"
+	srv, ct := newServer(t, key)
+	e := mkEnricher(t, srv, WithAPIKey(key))
+	vs := []*claircore.Vulnerability{{Name: "CVE-2014-0160"}}
+	if err := e.Enrich(ctx, vs); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := atomic.LoadInt64(ct), int64(1); got != want {
+		t.Errorf("requests: got %d, want %d", got, want)
+	}
+	if vs[0].CVSSv3Score == 0 {
+		t.Error("vulnerability not enriched")
+	}
+
+	if _, err := NewEnricher(srv.Client(), WithAPIKey("")); err == nil {
+		t.Error("expected error for empty api key")
+	}
+}
+
+func TestClient(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	srv, ct := newServer(t, "")
+	c, err := NewClient(srv.Client(), srv.URL+"/rest/json/cves/2.0", "", fetch.WithRateLimit(rate.Inf, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"CVE-2014-0160", "CVE-2021-44228"} {
+		e, err := NewEnricher(srv.Client(), WithClient(c))
+		if err != nil {
+			t.Fatal(err)
+		}
+		vs := []*claircore.Vulnerability{{Name: name}}
+		if err := e.Enrich(ctx, vs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := atomic.LoadInt64(ct), int64(2); got != want {
+		t.Errorf("requests: got %d, want %d", got, want)
+	}
+
+	if _, err := NewEnricher(srv.Client(), WithClient(c), WithURL(srv.URL)); err == nil {
+		t.Error("expected error for WithClient and WithURL")
+	}
+}
+
+func TestFetchError(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	srv, _ := newServer(t, "")
+	e, err := NewEnricher(srv.Client(), WithURL(srv.URL+"/missing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = e.Enrich(ctx, []*claircore.Vulnerability{{Name: "CVE-2014-0160"}})
+	var fe *errs.FetchError
+	if !errors.As(err, &fe) {
+		t.Fatalf("got error %#v, want %T", err, fe)
+	}
+	if got, want := fe.StatusCode, http.StatusNotFound; got != want {
+		t.Errorf("got status %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,387 @@
+// Package ovaldb builds portable SQLite snapshots of vulnerability databases.
+//
+// A snapshot is built once with BuildDB, which runs the configured updaters,
+// and can then be copied into environments without access to the upstream
+// feeds and queried with QueryDB.
+package ovaldb
+
+import (
+	"context"
+	"database/sql"
+	_ "embed" // embed sql statements
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/quay/zlog"
+	_ "modernc.org/sqlite" // register the sqlite driver
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/libvuln/driver"
+	"github.com/quay/claircore/pkg/cpe"
+)
+
+// SchemaVersion is the version of the database schema written by BuildDB. It's
+// recorded in the "meta" table under the "schema_version" key.
+const SchemaVersion = 2
+
+var (
+	//go:embed sql/schema.sql
+	schema string
+	//go:embed sql/insert.sql
+	insertVuln string
+	//go:embed sql/query.sql
+	queryVulns string
+)
+
+// BuildDB runs every Updater in "us" and writes the resulting vulnerabilities
+// into a new SQLite database at "path".
+//
+// The database is written to a temporary file next to "path" and renamed into
+// place once complete, so an existing file at "path" is only replaced by a
+// complete database.
+func BuildDB(ctx context.Context, path string, us []driver.Updater) (err error) {
+	ctx = zlog.ContextWithValues(ctx, "component", "pkg/ovaldb/BuildDB")
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("ovaldb: unable to create database: %w", err)
+	}
+	tmp := f.Name()
+	f.Close()
+	defer func() {
+		if err != nil {
+			os.Remove(tmp)
+		}
+	}()
+
+	db, err := open(tmp, false)
+	if err != nil {
+		return err
+	}
+	err = build(ctx, db, us)
+	// The database needs to be closed before it's renamed into place.
+	if cerr := db.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("ovaldb: unable to close database: %w", cerr)
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("ovaldb: unable to rename database: %w", err)
+	}
+	return nil
+}
+
+// Build creates the schema in "db" and writes the results of every Updater
+// in "us".
+func build(ctx context.Context, db *sql.DB, us []driver.Updater) error {
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		return fmt.Errorf("ovaldb: unable to create schema: %w", err)
+	}
+	if _, err := db.ExecContext(ctx,
+		`INSERT INTO meta (key, value) VALUES ('schema_version', ?), ('created', ?);`,
+		strconv.Itoa(SchemaVersion), time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("ovaldb: unable to write metadata: %w", err)
+	}
+	for _, u := range us {
+		name := u.Name()
+		ctx := zlog.ContextWithValues(ctx, "updater", name)
+		rc, fp, err := u.Fetch(ctx, "")
+		if err != nil {
+			return fmt.Errorf("ovaldb: updater %q: fetch error: %w", name, err)
+		}
+		vs, err := u.Parse(ctx, rc)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("ovaldb: updater %q: parse error: %w", name, err)
+		}
+		if err := writeVulns(ctx, db, name, fp, vs); err != nil {
+			return fmt.Errorf("ovaldb: updater %q: %w", name, err)
+		}
+		zlog.Info(ctx).
+			Int("count", len(vs)).
+			Msg("wrote vulnerabilities")
+	}
+	return nil
+}
+
+// OpenDB opens the database at "path", created by BuildDB, for querying.
+//
+// An error is reported if the database was written with a different schema
+// version.
+func OpenDB(ctx context.Context, path string) (*sql.DB, error) {
+	db, err := open(path, true)
+	if err != nil {
+		return nil, err
+	}
+	var v string
+	err = db.QueryRowContext(ctx, `SELECT value FROM meta WHERE key = 'schema_version';`).Scan(&v)
+	switch {
+	case err != nil:
+		err = fmt.Errorf("ovaldb: unable to read schema version: %w", err)
+	case v != strconv.Itoa(SchemaVersion):
+		err = fmt.Errorf("ovaldb: unsupported schema version %q (want %d)", v, SchemaVersion)
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Open opens the named SQLite database. Like rpm/sqlite, this must be a file
+// on-disk.
+func open(path string, ro bool) (*sql.DB, error) {
+	p := []string{"foreign_keys(1)"}
+	if ro {
+		p = append(p, "query_only(1)")
+	}
+	u := url.URL{
+		Scheme:   `file`,
+		Opaque:   path,
+		RawQuery: url.Values{"_pragma": p}.Encode(),
+	}
+	db, err := sql.Open(`sqlite`, u.String())
+	if err != nil {
+		return nil, fmt.Errorf("ovaldb: unable to open database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ovaldb: unable to open database: %w", err)
+	}
+	return db, nil
+}
+
+// CvePattern is used to find the CVE IDs a vulnerability refers to.
+var cvePattern = regexp.MustCompile(`CVE-\d{4}-\d{4,}`)
+
+// WriteVulns records the results of the named updater in a single
+// transaction.
+func writeVulns(ctx context.Context, db *sql.DB, name string, fp driver.Fingerprint, vs []*claircore.Vulnerability) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("unable to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO updater (name, fingerprint, fetched) VALUES (?, ?, ?);`,
+		name, string(fp), time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("unable to record updater: %w", err)
+	}
+	insert, err := tx.PrepareContext(ctx, insertVuln)
+	if err != nil {
+		return fmt.Errorf("unable to prepare statement: %w", err)
+	}
+	defer insert.Close()
+	insertCPE, err := tx.PrepareContext(ctx, `INSERT INTO affected_cpe (vulnerability, idx, cpe) VALUES (?, ?, ?);`)
+	if err != nil {
+		return fmt.Errorf("unable to prepare statement: %w", err)
+	}
+	defer insertCPE.Close()
+	insertCVE, err := tx.PrepareContext(ctx, `INSERT OR IGNORE INTO cve (vulnerability, id) VALUES (?, ?);`)
+	if err != nil {
+		return fmt.Errorf("unable to prepare statement: %w", err)
+	}
+	defer insertCVE.Close()
+
+	for _, v := range vs {
+		res, err := insert.ExecContext(ctx, vulnArgs(v)...)
+		if err != nil {
+			return fmt.Errorf("unable to insert %q: %w", v.Name, err)
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("unable to insert %q: %w", v.Name, err)
+		}
+		for i, c := range v.AffectedCPEs {
+			if _, err := insertCPE.ExecContext(ctx, id, i, c); err != nil {
+				return fmt.Errorf("unable to insert %q: %w", v.Name, err)
+			}
+		}
+		for _, c := range cvePattern.FindAllString(v.Name+" "+v.Links, -1) {
+			if _, err := insertCVE.ExecContext(ctx, id, c); err != nil {
+				return fmt.Errorf("unable to insert %q: %w", v.Name, err)
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("unable to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// VulnArgs returns the arguments for the insert statement. Absent nested
+// structs are recorded as NULLs.
+func vulnArgs(v *claircore.Vulnerability) []any {
+	args := []any{
+		v.Updater, v.Name, v.Description, v.Issued.Format(time.RFC3339Nano), v.Links, v.Severity, v.NormalizedSeverity,
+		v.CVSSv2Vector, v.CVSSv2Score, v.CVSSv3Vector, v.CVSSv3Score, v.CVSSv4Vector, v.CVSSv4Score,
+		string(v.FixState), v.FixedInVersion, v.ArchOperation,
+	}
+	if p := v.Package; p != nil {
+		args = append(args, p.Name, p.Version, p.Kind, p.Module, p.Arch, cpeText(p.CPE))
+	} else {
+		args = append(args, nil, nil, nil, nil, nil, nil)
+	}
+	if d := v.Dist; d != nil {
+		args = append(args, d.ID, d.DID, d.Name, d.Version, d.VersionCodeName, d.VersionID, d.Arch, cpeText(d.CPE), d.PrettyName)
+	} else {
+		args = append(args, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	}
+	if r := v.Repo; r != nil {
+		args = append(args, r.Name, r.Key, r.URI, cpeText(r.CPE))
+	} else {
+		args = append(args, nil, nil, nil, nil)
+	}
+	if r := v.Range; r != nil {
+		lo, _ := r.Lower.MarshalText()
+		hi, _ := r.Upper.MarshalText()
+		args = append(args, string(lo), string(hi))
+	} else {
+		args = append(args, nil, nil)
+	}
+	cvss := []byte(`[]`)
+	if len(v.CVSS) != 0 {
+		// Marshaling a slice of plain structs can't fail.
+		cvss, _ = json.Marshal(v.CVSS)
+	}
+	args = append(args, string(cvss))
+	return args
+}
+
+// CpeText returns the formatted string binding of the WFN, or an empty string
+// if it's unset.
+func cpeText(w cpe.WFN) string {
+	b, err := w.MarshalText()
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// QueryDB returns the vulnerabilities in the database for packages with the
+// same name and module as "pkg".
+//
+// Versions are not compared, so the results are candidates to be checked by
+// the appropriate matcher. The ID of each returned vulnerability is unset, as
+// row numbers aren't stable between snapshots. Empty AffectedCPEs are
+// returned as nil.
+//
+// CPEs are stored in the formatted string binding, so unset attributes are
+// returned as ANY. These are equivalent when matching.
+func QueryDB(ctx context.Context, db *sql.DB, pkg *claircore.Package) ([]*claircore.Vulnerability, error) {
+	rows, err := db.QueryContext(ctx, queryVulns, pkg.Name, pkg.Module)
+	if err != nil {
+		return nil, fmt.Errorf("ovaldb: query error: %w", err)
+	}
+	defer rows.Close()
+	var out []*claircore.Vulnerability
+	for rows.Next() {
+		v, err := scanVuln(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ovaldb: scan error: %w", err)
+		}
+		out = append(out, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ovaldb: query error: %w", err)
+	}
+	return out, nil
+}
+
+// ScanVuln reads a row returned by the query statement.
+func scanVuln(rows *sql.Rows) (*claircore.Vulnerability, error) {
+	var (
+		v      claircore.Vulnerability
+		id     int64
+		issued string
+		fix    string
+		pkg    [6]sql.NullString
+		dist   [9]sql.NullString
+		repo   [4]sql.NullString
+		rng    [2]sql.NullString
+		cvss   string
+		cpes   string
+	)
+	dest := []any{
+		&id, &v.Updater, &v.Name, &v.Description, &issued, &v.Links, &v.Severity, &v.NormalizedSeverity,
+		&v.CVSSv2Vector, &v.CVSSv2Score, &v.CVSSv3Vector, &v.CVSSv3Score, &v.CVSSv4Vector, &v.CVSSv4Score,
+		&fix, &v.FixedInVersion, &v.ArchOperation,
+	}
+	for _, s := range [][]sql.NullString{pkg[:], dist[:], repo[:], rng[:]} {
+		for i := range s {
+			dest = append(dest, &s[i])
+		}
+	}
+	dest = append(dest, &cvss, &cpes)
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	var err error
+	v.Issued, err = time.Parse(time.RFC3339Nano, issued)
+	if err != nil {
+		return nil, fmt.Errorf("vulnerability %d: %w", id, err)
+	}
+	v.FixState = claircore.FixState(fix)
+	if err := json.Unmarshal([]byte(cpes), &v.AffectedCPEs); err != nil {
+		return nil, fmt.Errorf("vulnerability %d: %w", id, err)
+	}
+	if len(v.AffectedCPEs) == 0 {
+		v.AffectedCPEs = nil
+	}
+	if err := json.Unmarshal([]byte(cvss), &v.CVSS); err != nil {
+		return nil, fmt.Errorf("vulnerability %d: %w", id, err)
+	}
+	if len(v.CVSS) == 0 {
+		v.CVSS = nil
+	}
+	var errs []error
+	if pkg[0].Valid {
+		v.Package = &claircore.Package{
+			Name:    pkg[0].String,
+			Version: pkg[1].String,
+			Kind:    pkg[2].String,
+			Module:  pkg[3].String,
+			Arch:    pkg[4].String,
+		}
+		errs = append(errs, v.Package.CPE.UnmarshalText([]byte(pkg[5].String)))
+	}
+	if dist[0].Valid {
+		v.Dist = &claircore.Distribution{
+			ID:              dist[0].String,
+			DID:             dist[1].String,
+			Name:            dist[2].String,
+			Version:         dist[3].String,
+			VersionCodeName: dist[4].String,
+			VersionID:       dist[5].String,
+			Arch:            dist[6].String,
+			PrettyName:      dist[8].String,
+		}
+		errs = append(errs, v.Dist.CPE.UnmarshalText([]byte(dist[7].String)))
+	}
+	if repo[0].Valid {
+		v.Repo = &claircore.Repository{
+			Name: repo[0].String,
+			Key:  repo[1].String,
+			URI:  repo[2].String,
+		}
+		errs = append(errs, v.Repo.CPE.UnmarshalText([]byte(repo[3].String)))
+	}
+	if rng[0].Valid {
+		v.Range = new(claircore.Range)
+		errs = append(errs,
+			v.Range.Lower.UnmarshalText([]byte(rng[0].String)),
+			v.Range.Upper.UnmarshalText([]byte(rng[1].String)))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("vulnerability %d: %w", id, err)
+	}
+	return &v, nil
+}
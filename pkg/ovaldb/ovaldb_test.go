@@ -0,0 +1,234 @@
+package ovaldb
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/libvuln/driver"
+	"github.com/quay/claircore/pkg/cpe"
+	"github.com/quay/claircore/rhel"
+)
+
+const fixture = "testdata/com.redhat.rhsa-20201980.xml"
+
+// CmpWFN compares WFNs by their formatted string bindings, as unset
+// attributes are read back from the database as ANY.
+var cmpWFN = cmp.Comparer(func(a, b cpe.WFN) bool {
+	return a.String() == b.String()
+})
+
+// NewUpdater returns a RHEL updater serving the fixture from a test server.
+func newUpdater(t *testing.T, ctx context.Context) *rhel.Updater {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, fixture)
+	}))
+	t.Cleanup(srv.Close)
+	u, err := rhel.NewUpdater(`rhel-8-updater`, 8, srv.URL, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Configure(ctx, func(_ interface{}) error { return nil }, srv.Client()); err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	u := newUpdater(t, ctx)
+
+	f, err := os.Open(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := u.Parse(ctx, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(want) == 0 {
+		t.Fatal("no vulnerabilities parsed")
+	}
+
+	path := filepath.Join(t.TempDir(), "oval.db")
+	if err := BuildDB(ctx, path, []driver.Updater{u}); err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenDB(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	type key struct{ name, module string }
+	byPkg := make(map[key][]*claircore.Vulnerability)
+	for _, v := range want {
+		k := key{name: v.Package.Name, module: v.Package.Module}
+		byPkg[k] = append(byPkg[k], v)
+	}
+	var n int
+	for k, want := range byPkg {
+		got, err := QueryDB(ctx, db, &claircore.Package{Name: k.name, Module: k.module})
+		if err != nil {
+			t.Fatal(err)
+		}
+		n += len(got)
+		if !cmp.Equal(got, want, cmpWFN) {
+			t.Errorf("%s: %s", k.name, cmp.Diff(got, want, cmpWFN))
+		}
+	}
+	if got, want := n, len(want); got != want {
+		t.Errorf("got %d vulnerabilities, want %d", got, want)
+	}
+
+	got, err := QueryDB(ctx, db, &claircore.Package{Name: "not-a-package"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("unexpected vulnerabilities: %v", got)
+	}
+
+	var cves []string
+	rows, err := db.QueryContext(ctx, `SELECT DISTINCT id FROM cve;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c string
+		if err := rows.Scan(&c); err != nil {
+			t.Fatal(err)
+		}
+		cves = append(cves, c)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(cves)
+	if want := []string{"CVE-2020-11008"}; !cmp.Equal(cves, want) {
+		t.Error(cmp.Diff(cves, want))
+	}
+}
+
+func TestRoundTripFields(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	path := filepath.Join(t.TempDir(), "oval.db")
+	db, err := open(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every field that's stored is populated.
+	want := []*claircore.Vulnerability{
+		{
+			Updater:            "test",
+			Name:               "CVE-2024-0001",
+			Description:        "A vulnerability.",
+			Links:              "https://example.com/CVE-2024-0001",
+			Severity:           "Important",
+			NormalizedSeverity: claircore.High,
+			CVSSv2Vector:       "AV:N/AC:L/Au:N/C:P/I:N/A:N",
+			CVSSv2Score:        5,
+			CVSSv3Vector:       "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N",
+			CVSSv3Score:        7.5,
+			CVSSv4Vector:       "CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:H/VI:N/VA:N/SC:N/SI:N/SA:N",
+			CVSSv4Score:        8.7,
+			AffectedCPEs:       []string{"cpe:/o:redhat:enterprise_linux:9::baseos", "cpe:/o:redhat:enterprise_linux:9"},
+			FixState:           claircore.FixStateFixed,
+			FixedInVersion:     "0:1.2.3-1.el9",
+			ArchOperation:      claircore.OpPatternMatch,
+			Package: &claircore.Package{
+				Name:    "pkg",
+				Version: "1.2.3",
+				Kind:    claircore.BINARY,
+				Module:  "mod:1",
+				Arch:    "x86_64|aarch64",
+				CPE:     cpe.MustUnbind("cpe:2.3:a:example:pkg:1.2.3:*:*:*:*:*:*:*"),
+			},
+			Dist: &claircore.Distribution{
+				ID:              "1",
+				DID:             "rhel",
+				Name:            "Red Hat Enterprise Linux",
+				Version:         "9",
+				VersionCodeName: "Plow",
+				VersionID:       "9.4",
+				Arch:            "x86_64",
+				CPE:             cpe.MustUnbind("cpe:/o:redhat:enterprise_linux:9"),
+				PrettyName:      "Red Hat Enterprise Linux 9",
+			},
+			Repo: &claircore.Repository{
+				Name: "cpe:/o:redhat:enterprise_linux:9::baseos",
+				Key:  "rhel-cpe-repository",
+				URI:  "https://example.com/repo",
+				CPE:  cpe.MustUnbind("cpe:/o:redhat:enterprise_linux:9::baseos"),
+			},
+			Range: &claircore.Range{
+				Lower: claircore.Version{Kind: "test", V: [10]int32{0, 1}},
+				Upper: claircore.Version{Kind: "test", V: [10]int32{0, 2, 3}},
+			},
+		},
+		{
+			Updater: "test",
+			Name:    "CVE-2024-0002",
+			Package: &claircore.Package{Name: "pkg", Module: "mod:1"},
+		},
+	}
+	want[0].Issued = want[0].Issued.AddDate(2024, 0, 0)
+	if err := writeVulns(ctx, db, "test", driver.Fingerprint("fp"), want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := QueryDB(ctx, db, &claircore.Package{Name: "pkg", Module: "mod:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(got, want, cmpWFN) {
+		t.Error(cmp.Diff(got, want, cmpWFN))
+	}
+}
+
+func TestOpenDB(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	path := filepath.Join(t.TempDir(), "oval.db")
+	if err := BuildDB(ctx, path, nil); err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenDB(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, `DELETE FROM meta;`); err == nil {
+		t.Error("expected error writing to read-only database")
+	}
+	db.Close()
+
+	// A database with another schema version is rejected.
+	rw, err := sql.Open(`sqlite`, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rw.ExecContext(ctx, `UPDATE meta SET value = '0' WHERE key = 'schema_version';`); err != nil {
+		t.Fatal(err)
+	}
+	rw.Close()
+	if _, err := OpenDB(ctx, path); err == nil {
+		t.Error("expected error for old schema version")
+	}
+}
@@ -0,0 +1,19 @@
+INSERT INTO vulnerability (
+	updater, name, description, issued, links, severity, normalized_severity,
+	cvss_v2_vector, cvss_v2_score, cvss_v3_vector, cvss_v3_score, cvss_v4_vector, cvss_v4_score,
+	fix_state, fixed_in_version, arch_operation,
+	package_name, package_version, package_kind, package_module, package_arch, package_cpe,
+	dist_id, dist_did, dist_name, dist_version, dist_version_code_name, dist_version_id, dist_arch, dist_cpe, dist_pretty_name,
+	repo_name, repo_key, repo_uri, repo_cpe,
+	range_lower, range_upper,
+	cvss
+) VALUES (
+	?, ?, ?, ?, ?, ?, ?,
+	?, ?, ?, ?, ?, ?,
+	?, ?, ?,
+	?, ?, ?, ?, ?, ?,
+	?, ?, ?, ?, ?, ?, ?, ?, ?,
+	?, ?, ?, ?,
+	?, ?,
+	?
+);
@@ -0,0 +1,63 @@
+CREATE TABLE meta (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+CREATE TABLE updater (
+	name        TEXT PRIMARY KEY,
+	fingerprint TEXT NOT NULL,
+	fetched     TEXT NOT NULL
+);
+CREATE TABLE vulnerability (
+	id                     INTEGER PRIMARY KEY,
+	updater                TEXT NOT NULL,
+	name                   TEXT NOT NULL,
+	description            TEXT NOT NULL,
+	issued                 TEXT NOT NULL,
+	links                  TEXT NOT NULL,
+	severity               TEXT NOT NULL,
+	normalized_severity    TEXT NOT NULL,
+	cvss_v2_vector         TEXT NOT NULL,
+	cvss_v2_score          REAL NOT NULL,
+	cvss_v3_vector         TEXT NOT NULL,
+	cvss_v3_score          REAL NOT NULL,
+	cvss_v4_vector         TEXT NOT NULL,
+	cvss_v4_score          REAL NOT NULL,
+	fix_state              TEXT NOT NULL,
+	fixed_in_version       TEXT NOT NULL,
+	arch_operation         TEXT NOT NULL,
+	package_name           TEXT,
+	package_version        TEXT,
+	package_kind           TEXT,
+	package_module         TEXT,
+	package_arch           TEXT,
+	package_cpe            TEXT,
+	dist_id                TEXT,
+	dist_did               TEXT,
+	dist_name              TEXT,
+	dist_version           TEXT,
+	dist_version_code_name TEXT,
+	dist_version_id        TEXT,
+	dist_arch              TEXT,
+	dist_cpe               TEXT,
+	dist_pretty_name       TEXT,
+	repo_name              TEXT,
+	repo_key               TEXT,
+	repo_uri               TEXT,
+	repo_cpe               TEXT,
+	range_lower            TEXT,
+	range_upper            TEXT,
+	cvss                   TEXT NOT NULL -- JSON array of claircore.CVSS
+);
+CREATE INDEX vulnerability_package ON vulnerability (package_name, package_module);
+CREATE TABLE affected_cpe (
+	vulnerability INTEGER NOT NULL REFERENCES vulnerability (id) ON DELETE CASCADE,
+	idx           INTEGER NOT NULL,
+	cpe           TEXT NOT NULL,
+	PRIMARY KEY (vulnerability, idx)
+);
+CREATE TABLE cve (
+	vulnerability INTEGER NOT NULL REFERENCES vulnerability (id) ON DELETE CASCADE,
+	id            TEXT NOT NULL,
+	PRIMARY KEY (vulnerability, id)
+);
+CREATE INDEX cve_id ON cve (id);
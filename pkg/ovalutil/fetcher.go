@@ -4,16 +4,21 @@ import (
 	"compress/bzip2"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime"
 	"net/http"
 	"net/url"
 	"path"
+	"strings"
 
 	"github.com/quay/zlog"
+	"golang.org/x/crypto/openpgp"
 
 	"github.com/quay/claircore/libvuln/driver"
+	"github.com/quay/claircore/pkg/errs"
+	"github.com/quay/claircore/pkg/fetch"
 	"github.com/quay/claircore/pkg/tmp"
 )
 
@@ -59,6 +64,34 @@ type Fetcher struct {
 	URL         *url.URL
 	Client      *http.Client
 	Compression Compressor
+	// Keyring is optional. If populated, the database's detached, armored
+	// signature is fetched from URL with ".asc" appended and checked against
+	// the downloaded bytes before they're returned. Any key in the Keyring is
+	// accepted, to allow for key rotation.
+	Keyring openpgp.EntityList
+	// Stream, if set, makes Fetch return a reader that decompresses the
+	// response body as it's read, instead of buffering the database in a
+	// temporary file. The returned reader must be consumed and closed before
+	// the Context passed to Fetch is canceled. Stream has no effect if a
+	// Keyring is configured, so that the database is always verified before
+	// it's returned.
+	Stream bool
+	// CompressionFromExtension, if set, makes a compression extension on the
+	// URL path (".bz2", ".gz", or ".zst") take precedence over a missing or
+	// generic ("application/octet-stream") Content-Type when Compression is
+	// CompressionAuto.
+	CompressionFromExtension bool
+	// Retry is optional. If populated, requests are made with it instead of
+	// directly with Client, so that transient failures are retried.
+	Retry *fetch.Fetcher
+}
+
+// Do issues the request with Retry, if populated, or Client.
+func (f *Fetcher) do(req *http.Request) (*http.Response, error) {
+	if f.Retry != nil {
+		return f.Retry.Do(req)
+	}
+	return f.Client.Do(req)
 }
 
 // Configure implements driver.Configurable.
@@ -110,7 +143,8 @@ type FetcherConfig struct {
 // Fetch makes GET requests, and will make conditional requests using the
 // passed-in hint.
 //
-// Tmp.File is used to return a ReadCloser that outlives the passed-in context.
+// Tmp.File is used to return a ReadCloser that outlives the passed-in context,
+// unless Fetcher.Stream is set.
 func (f *Fetcher) Fetch(ctx context.Context, hint driver.Fingerprint) (io.ReadCloser, driver.Fingerprint, error) {
 	ctx = zlog.ContextWithValues(ctx, "component", "pkg/ovalutil/Fetcher.Fetch")
 	zlog.Info(ctx).Str("database", f.URL.String()).Msg("starting fetch")
@@ -132,12 +166,20 @@ func (f *Fetcher) Fetch(ctx context.Context, hint driver.Fingerprint) (io.ReadCl
 		}
 	}
 
-	res, err := f.Client.Do(req.WithContext(ctx))
+	// Release holds cleanups for the response, run in reverse order on
+	// return unless they've been handed off to a streamReader.
+	var release []func()
+	defer func() {
+		for i := len(release) - 1; i >= 0; i-- {
+			release[i]()
+		}
+	}()
+	res, err := f.do(req.WithContext(ctx))
 	if res != nil {
-		defer res.Body.Close()
+		release = append(release, func() { res.Body.Close() })
 	}
 	if err != nil {
-		return nil, hint, err
+		return nil, hint, fmt.Errorf("ovalutil: %w", &errs.FetchError{URL: f.URL.String(), Err: err})
 	}
 	switch res.StatusCode {
 	case http.StatusOK:
@@ -148,10 +190,23 @@ func (f *Fetcher) Fetch(ctx context.Context, hint driver.Fingerprint) (io.ReadCl
 	case http.StatusNotModified:
 		return nil, hint, driver.Unchanged
 	default:
-		return nil, hint, fmt.Errorf("ovalutil: fetcher got unexpected HTTP response: %d (%s)", res.StatusCode, res.Status)
+		return nil, hint, fmt.Errorf("ovalutil: %w", &errs.FetchError{URL: f.URL.String(), StatusCode: res.StatusCode})
 	}
 	zlog.Debug(ctx).Msg("request ok")
 
+	var body io.Reader = res.Body
+	var v *verifier
+	if len(f.Keyring) != 0 {
+		sig, err := f.fetchSignature(ctx)
+		if err != nil {
+			return nil, hint, err
+		}
+		v = newVerifier(ctx, f.Keyring, sig)
+		// Stops the verifier if returning before the body is consumed.
+		release = append(release, func() { v.pw.CloseWithError(io.ErrUnexpectedEOF) })
+		body = io.TeeReader(res.Body, v)
+	}
+
 	var r io.Reader
 	cmp := f.Compression
 Compression:
@@ -194,6 +249,18 @@ Compression:
 		}
 		kind = mime.TypeByExtension(path.Ext(res.Request.URL.Path))
 	Found:
+		if f.CompressionFromExtension {
+			switch ext := path.Ext(res.Request.URL.Path); {
+			case kind != `` && kind != `application/octet-stream`:
+				// Trust a specific type from the server.
+			case ext == `.bz2`:
+				kind = `application/x-bzip2`
+			case ext == `.gz`:
+				kind = `application/gzip`
+			case ext == `.zst`:
+				kind = `application/zstd`
+			}
+		}
 		switch kind {
 		case `application/x-bzip2`:
 			cmp = CompressionBzip2
@@ -207,22 +274,22 @@ Compression:
 		}
 		goto Compression
 	case CompressionNone:
-		r = res.Body
+		r = body
 	case CompressionGzip:
-		gz, err := getGzip(res.Body)
+		gz, err := getGzip(body)
 		if err != nil {
 			return nil, hint, err
 		}
-		defer putGzip(gz)
+		release = append(release, func() { putGzip(gz) })
 		r = gz
 	case CompressionBzip2:
-		r = bzip2.NewReader(res.Body)
+		r = &bzip2Reader{r: bzip2.NewReader(body)}
 	case CompressionZstd:
-		zz, err := getZstd(res.Body)
+		zz, err := getZstd(body)
 		if err != nil {
 			return nil, hint, err
 		}
-		defer putZstd(zz)
+		release = append(release, func() { putZstd(zz) })
 		r = zz
 	default:
 		panic(fmt.Sprintf("ovalutil: programmer error: unknown compression scheme: %v", f.Compression))
@@ -231,6 +298,15 @@ Compression:
 		Stringer("compression", cmp).
 		Msg("found compression scheme")
 
+	if f.Stream && v == nil {
+		fp.From(res.Header)
+		hint = fp.Fingerprint()
+		sr := &streamReader{r: r, release: release}
+		release = nil
+		zlog.Debug(ctx).Msg("streaming database")
+		return sr, hint, nil
+	}
+
 	tf, err := tmp.NewFile("", "fetcher.")
 	if err != nil {
 		return nil, hint, err
@@ -249,8 +325,27 @@ Compression:
 	}()
 
 	if _, err := io.Copy(tf, r); err != nil {
+		if v != nil {
+			// Prefer reporting a bad signature over the resulting I/O error.
+			if verr := v.Wait(); verr != nil {
+				return nil, hint, verr
+			}
+		}
 		return nil, hint, err
 	}
+	if v != nil {
+		// Make sure the verifier sees any trailing bytes the decompressor
+		// didn't consume.
+		if _, err := io.Copy(io.Discard, body); err != nil {
+			if verr := v.Wait(); verr != nil {
+				return nil, hint, verr
+			}
+			return nil, hint, err
+		}
+		if err := v.Wait(); err != nil {
+			return nil, hint, err
+		}
+	}
 	if o, err := tf.Seek(0, io.SeekStart); err != nil || o != 0 {
 		return nil, hint, err
 	}
@@ -287,3 +382,40 @@ func (f fingerprint) Fingerprint() driver.Fingerprint {
 	b, _ := json.Marshal(f)
 	return driver.Fingerprint(string(b))
 }
+
+// StreamReader is the ReadCloser returned by Fetcher.Fetch when
+// Fetcher.Stream is set.
+type streamReader struct {
+	r       io.Reader
+	release []func()
+}
+
+// Read implements io.Reader.
+func (s *streamReader) Read(b []byte) (int, error) {
+	return s.r.Read(b)
+}
+
+// Close implements io.Closer.
+func (s *streamReader) Close() error {
+	for i := len(s.release) - 1; i >= 0; i-- {
+		s.release[i]()
+	}
+	s.release = nil
+	return nil
+}
+
+// Bzip2Reader wraps a bzip2 decompressor, reporting checksum failures as
+// [errs.ValidationError].
+type bzip2Reader struct {
+	r io.Reader
+}
+
+// Read implements io.Reader.
+func (z *bzip2Reader) Read(b []byte) (int, error) {
+	n, err := z.r.Read(b)
+	var se bzip2.StructuralError
+	if errors.As(err, &se) && strings.Contains(string(se), "checksum") {
+		err = &errs.ValidationError{Check: "bzip2 checksum", Err: err}
+	}
+	return n, err
+}
@@ -54,100 +54,125 @@ func RPMDefsToVulns(ctx context.Context, root *oval.Root, protoVulns ProtoVulnsF
 				Msg("could not create prototype vulnerabilities")
 			continue
 		}
-		// recursively collect criterions for this definition
-		cris := cris[:0]
-		walkCriterion(ctx, &def.Criteria, &cris)
-		enabledModules := getEnabledModules(cris)
-		if len(enabledModules) == 0 {
-			// add default empty module
-			enabledModules = append(enabledModules, "")
-		}
-		// unpack criterions into vulnerabilities
-		for _, criterion := range cris {
-			// if test object is not rmpinfo_test the provided test is not
-			// associated with a package. this criterion will be skipped.
-			test, err := TestLookup(root, criterion.TestRef, func(kind string) bool {
-				if kind != "rpminfo_test" {
-					return false
-				}
-				return true
-			})
-			switch {
-			case errors.Is(err, nil):
-			case errors.Is(err, errTestSkip):
-				continue
-			default:
-				zlog.Debug(ctx).Str("test_ref", criterion.TestRef).Msg("test ref lookup failure. moving to next criterion")
-				continue
+		vulns = appendRPMVulns(ctx, vulns, root, &def, protoVulns, &cris)
+	}
+
+	return vulns, nil
+}
+
+// RPMDefToVulns is like RPMDefsToVulns, but only for the single definition
+// "def", which need not be in "root".
+//
+// The root is only read, so multiple goroutines may call this with the same
+// root. Errors from protoVulns are returned.
+func RPMDefToVulns(ctx context.Context, root *oval.Root, def *oval.Definition, protoVulns ProtoVulnsFunc) ([]*claircore.Vulnerability, error) {
+	ctx = zlog.ContextWithValues(ctx, "component", "ovalutil/RPMDefToVulns")
+	pvs, err := protoVulns(*def)
+	if err != nil {
+		return nil, err
+	}
+	var cris []*oval.Criterion
+	return appendRPMVulns(ctx, nil, root, def, pvs, &cris), nil
+}
+
+// AppendRPMVulns appends the vulnerabilities described by the definition to
+// "vulns", copying each of the prototype vulnerabilities per package.
+//
+// The slice pointed to by "scratch" is reused between calls.
+func appendRPMVulns(ctx context.Context, vulns []*claircore.Vulnerability, root *oval.Root, def *oval.Definition, protoVulns []*claircore.Vulnerability, scratch *[]*oval.Criterion) []*claircore.Vulnerability {
+	// recursively collect criterions for this definition
+	cris := (*scratch)[:0]
+	walkCriterion(ctx, &def.Criteria, &cris)
+	*scratch = cris
+	enabledModules := getEnabledModules(cris)
+	if len(enabledModules) == 0 {
+		// add default empty module
+		enabledModules = append(enabledModules, "")
+	}
+	// unpack criterions into vulnerabilities
+	for _, criterion := range cris {
+		// if test object is not rmpinfo_test the provided test is not
+		// associated with a package. this criterion will be skipped.
+		test, err := TestLookup(root, criterion.TestRef, func(kind string) bool {
+			if kind != "rpminfo_test" {
+				return false
 			}
+			return true
+		})
+		switch {
+		case errors.Is(err, nil):
+		case errors.Is(err, errTestSkip):
+			continue
+		default:
+			zlog.Debug(ctx).Str("test_ref", criterion.TestRef).Msg("test ref lookup failure. moving to next criterion")
+			continue
+		}
 
-			objRefs := test.ObjectRef()
-			stateRefs := test.StateRef()
-
-			// from the rpminfo_test specification found here: https://oval.mitre.org/language/version5.7/ovaldefinition/documentation/linux-definitions-schema.html
-			// "The required object element references a rpminfo_object and the optional state element specifies the data to check.
-			//  The evaluation of the test is guided by the check attribute that is inherited from the TestType."
-			//
-			// thus we *should* only need to care about a single rpminfo_object and optionally a state object providing the package's fixed-in version.
-
-			objRef := objRefs[0].ObjectRef
-			object, err := rpmObjectLookup(root, objRef)
-			switch {
-			case errors.Is(err, nil):
-			case errors.Is(err, errObjectSkip):
-				// We only handle rpminfo_objects.
-				continue
-			default:
+		objRefs := test.ObjectRef()
+		stateRefs := test.StateRef()
+
+		// from the rpminfo_test specification found here: https://oval.mitre.org/language/version5.7/ovaldefinition/documentation/linux-definitions-schema.html
+		// "The required object element references a rpminfo_object and the optional state element specifies the data to check.
+		//  The evaluation of the test is guided by the check attribute that is inherited from the TestType."
+		//
+		// thus we *should* only need to care about a single rpminfo_object and optionally a state object providing the package's fixed-in version.
+
+		objRef := objRefs[0].ObjectRef
+		object, err := rpmObjectLookup(root, objRef)
+		switch {
+		case errors.Is(err, nil):
+		case errors.Is(err, errObjectSkip):
+			// We only handle rpminfo_objects.
+			continue
+		default:
+			zlog.Debug(ctx).
+				Err(err).
+				Str("object_ref", objRef).
+				Msg("failed object lookup. moving to next criterion")
+			continue
+		}
+
+		// state refs are optional, so this is not a requirement.
+		// if a state object is discovered, we can use it to find
+		// the "fixed-in-version"
+		var state *oval.RPMInfoState
+		if len(stateRefs) > 0 {
+			stateRef := stateRefs[0].StateRef
+			state, err = rpmStateLookup(root, stateRef)
+			if err != nil {
 				zlog.Debug(ctx).
 					Err(err).
-					Str("object_ref", objRef).
-					Msg("failed object lookup. moving to next criterion")
+					Str("state_ref", stateRef).
+					Msg("failed state lookup. moving to next criterion")
 				continue
 			}
-
-			// state refs are optional, so this is not a requirement.
-			// if a state object is discovered, we can use it to find
-			// the "fixed-in-version"
-			var state *oval.RPMInfoState
-			if len(stateRefs) > 0 {
-				stateRef := stateRefs[0].StateRef
-				state, err = rpmStateLookup(root, stateRef)
-				if err != nil {
-					zlog.Debug(ctx).
-						Err(err).
-						Str("state_ref", stateRef).
-						Msg("failed state lookup. moving to next criterion")
-					continue
-				}
-				// if we find a state, but this state does not contain an EVR,
-				// we are not looking at a linux package.
-				if state.EVR == nil {
-					continue
-				}
+			// if we find a state, but this state does not contain an EVR,
+			// we are not looking at a linux package.
+			if state.EVR == nil {
+				continue
 			}
+		}
 
-			for _, module := range enabledModules {
-				for _, protoVuln := range protoVulns {
-					vuln := *protoVuln
-					vuln.Package = &claircore.Package{
-						Name:   object.Name,
-						Module: module,
-						Kind:   claircore.BINARY,
-					}
-					if state != nil {
-						vuln.FixedInVersion = state.EVR.Body
-						if state.Arch != nil {
-							vuln.ArchOperation = mapArchOp(state.Arch.Operation)
-							vuln.Package.Arch = state.Arch.Body
-						}
+		for _, module := range enabledModules {
+			for _, protoVuln := range protoVulns {
+				vuln := *protoVuln
+				vuln.Package = &claircore.Package{
+					Name:   object.Name,
+					Module: module,
+					Kind:   claircore.BINARY,
+				}
+				if state != nil {
+					vuln.FixedInVersion = state.EVR.Body
+					if state.Arch != nil {
+						vuln.ArchOperation = mapArchOp(state.Arch.Operation)
+						vuln.Package.Arch = state.Arch.Body
 					}
-					vulns = append(vulns, &vuln)
 				}
+				vulns = append(vulns, &vuln)
 			}
 		}
 	}
-
-	return vulns, nil
+	return vulns
 }
 
 func mapArchOp(op oval.Operation) claircore.ArchOp {
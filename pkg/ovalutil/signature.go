@@ -0,0 +1,98 @@
+package ovalutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/quay/zlog"
+	"golang.org/x/crypto/openpgp"
+
+	"github.com/quay/claircore/pkg/errs"
+)
+
+// ErrSignatureInvalid is returned by Fetcher.Fetch when a Keyring is
+// configured and the fetched database's detached signature cannot be verified
+// by any key in it.
+var ErrSignatureInvalid = errors.New("ovalutil: signature invalid")
+
+// SignatureSuffix is appended to the database URL to find its detached,
+// armored OpenPGP signature.
+const signatureSuffix = `.asc`
+
+// FetchSignature retrieves the detached signature for the Fetcher's URL.
+func (f *Fetcher) fetchSignature(ctx context.Context) ([]byte, error) {
+	u := *f.URL
+	u.Path += signatureSuffix
+	if u.RawPath != "" {
+		u.RawPath += signatureSuffix
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "claircore/pkg/ovalutil.Fetcher")
+	res, err := f.do(req)
+	if res != nil {
+		defer res.Body.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ovalutil: %w", &errs.FetchError{URL: u.String(), Err: err})
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ovalutil: %w", &errs.FetchError{URL: u.String(), StatusCode: res.StatusCode, Err: ErrSignatureInvalid})
+	}
+	// Armored signatures are well under a kilobyte; anything this large is
+	// not one.
+	return io.ReadAll(io.LimitReader(res.Body, 1024*1024))
+}
+
+// Verifier checks a detached signature against data written to it.
+//
+// The data is checked as it's written, so the database doesn't need to be
+// buffered twice.
+type verifier struct {
+	pw   *io.PipeWriter
+	done chan error
+}
+
+// NewVerifier starts checking "sig" against data written to the returned
+// verifier.
+func newVerifier(ctx context.Context, keyring openpgp.EntityList, sig []byte) *verifier {
+	pr, pw := io.Pipe()
+	v := &verifier{
+		pw:   pw,
+		done: make(chan error, 1),
+	}
+	go func() {
+		signer, err := openpgp.CheckArmoredDetachedSignature(keyring, pr, bytes.NewReader(sig))
+		if err != nil {
+			err = &errs.ValidationError{
+				Check: "signature",
+				Err:   fmt.Errorf("%w: %v", ErrSignatureInvalid, err),
+			}
+		} else {
+			zlog.Debug(ctx).
+				Uint64("key_id", signer.PrimaryKey.KeyId).
+				Msg("signature verified")
+		}
+		// Unblock any pending writes if verification stopped early.
+		pr.CloseWithError(err)
+		v.done <- err
+	}()
+	return v
+}
+
+// Write implements io.Writer.
+func (v *verifier) Write(b []byte) (int, error) {
+	return v.pw.Write(b)
+}
+
+// Wait signals the end of the data and reports the verification result.
+func (v *verifier) Wait() error {
+	v.pw.Close()
+	return <-v.done
+}
@@ -0,0 +1,377 @@
+// Package purl implements parsing and formatting of Package URLs, as defined
+// by the specification at https://github.com/package-url/purl-spec.
+package purl
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// These are the package types with builder functions in this package.
+const (
+	TypeRPM    = "rpm"
+	TypeDebian = "deb"
+	TypePyPI   = "pypi"
+	TypeMaven  = "maven"
+	TypeGo     = "golang"
+)
+
+// PURL is a Package URL.
+//
+// The fields hold decoded values. Values returned by Parse and the builder
+// functions have had the type-specific normalizations of the specification
+// applied.
+type PURL struct {
+	// Type is the package type, such as "rpm" or "maven". It's always
+	// lowercase.
+	Type string
+	// Namespace is the optional name prefix, such as a Maven group ID or an
+	// RPM vendor. It may contain "/" to separate segments.
+	Namespace string
+	// Name is the name of the package.
+	Name string
+	// Version is the optional version of the package.
+	Version string
+	// Qualifiers are the optional extra data for the package, such as the
+	// architecture. Keys are lowercase, and values are never empty.
+	Qualifiers map[string]string
+	// Subpath is the optional path inside the package. It may contain "/" to
+	// separate segments.
+	Subpath string
+}
+
+// ErrInvalid is returned (wrapped) by Parse for strings that aren't valid
+// Package URLs.
+var ErrInvalid = errors.New("purl: invalid package url")
+
+// Parse parses the Package URL "s", following the algorithm in the
+// specification.
+//
+// Leading slashes after the scheme are tolerated, and the scheme and type are
+// case-insensitive. Empty and "." or ".." segments in the subpath are
+// discarded, as are qualifiers with empty values.
+func Parse(s string) (*PURL, error) {
+	var p PURL
+	rest, sub, ok := cutLast(s, "#")
+	if ok {
+		var err error
+		p.Subpath, err = decodeSegments(sub, true)
+		if err != nil {
+			return nil, fmt.Errorf("%w: subpath: %v", ErrInvalid, err)
+		}
+	}
+	rest, qs, ok := cutLast(rest, "?")
+	if ok {
+		var err error
+		p.Qualifiers, err = parseQualifiers(qs)
+		if err != nil {
+			return nil, fmt.Errorf("%w: qualifiers: %v", ErrInvalid, err)
+		}
+	}
+	scheme, rest, ok := strings.Cut(rest, ":")
+	if !ok || !strings.EqualFold(scheme, "pkg") {
+		return nil, fmt.Errorf("%w: missing \"pkg\" scheme: %q", ErrInvalid, s)
+	}
+	rest = strings.TrimLeft(rest, "/")
+	typ, rest, ok := strings.Cut(rest, "/")
+	if !ok {
+		return nil, fmt.Errorf("%w: missing name: %q", ErrInvalid, s)
+	}
+	p.Type = strings.ToLower(typ)
+	if err := checkType(p.Type); err != nil {
+		return nil, err
+	}
+	rest, ver, ok := cutLast(rest, "@")
+	if ok {
+		var err error
+		if p.Version, err = url.PathUnescape(ver); err != nil {
+			return nil, fmt.Errorf("%w: version: %v", ErrInvalid, err)
+		}
+	}
+	rest = strings.TrimRight(rest, "/")
+	ns, name, ok := cutLast(rest, "/")
+	if !ok {
+		ns, name = "", rest
+	}
+	var err error
+	if p.Name, err = url.PathUnescape(name); err != nil {
+		return nil, fmt.Errorf("%w: name: %v", ErrInvalid, err)
+	}
+	if p.Name == "" {
+		return nil, fmt.Errorf("%w: missing name: %q", ErrInvalid, s)
+	}
+	if p.Namespace, err = decodeSegments(ns, false); err != nil {
+		return nil, fmt.Errorf("%w: namespace: %v", ErrInvalid, err)
+	}
+	p.normalize()
+	return &p, nil
+}
+
+// String returns the canonical form of the Package URL.
+//
+// Qualifiers are sorted by key, and qualifiers with empty values are omitted.
+func (p *PURL) String() string {
+	var b strings.Builder
+	b.WriteString("pkg:")
+	b.WriteString(strings.ToLower(p.Type))
+	b.WriteByte('/')
+	if ns := encodeSegments(p.Namespace, false); ns != "" {
+		b.WriteString(ns)
+		b.WriteByte('/')
+	}
+	b.WriteString(escape(p.Name, false))
+	if p.Version != "" {
+		b.WriteByte('@')
+		b.WriteString(escape(p.Version, false))
+	}
+	keys := make([]string, 0, len(p.Qualifiers))
+	for k, v := range p.Qualifiers {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		if i == 0 {
+			b.WriteByte('?')
+		} else {
+			b.WriteByte('&')
+		}
+		b.WriteString(strings.ToLower(k))
+		b.WriteByte('=')
+		b.WriteString(escape(p.Qualifiers[k], true))
+	}
+	if sub := encodeSegments(p.Subpath, true); sub != "" {
+		b.WriteByte('#')
+		b.WriteString(sub)
+	}
+	return b.String()
+}
+
+// NewRPM returns a Package URL for an RPM package. The "namespace" is the
+// vendor, such as "redhat" or "fedora". The "arch" qualifier is only set if
+// "arch" is not empty.
+func NewRPM(namespace, name, version, arch string) *PURL {
+	p := PURL{
+		Type:      TypeRPM,
+		Namespace: namespace,
+		Name:      name,
+		Version:   version,
+	}
+	if arch != "" {
+		p.Qualifiers = map[string]string{"arch": arch}
+	}
+	p.normalize()
+	return &p
+}
+
+// NewDebian returns a Package URL for a Debian package. The namespace is
+// "debian".
+func NewDebian(name, version string) *PURL {
+	p := PURL{
+		Type:      TypeDebian,
+		Namespace: "debian",
+		Name:      name,
+		Version:   version,
+	}
+	p.normalize()
+	return &p
+}
+
+// NewPyPI returns a Package URL for a Python package. The name is normalized
+// as required by the specification.
+func NewPyPI(name, version string) *PURL {
+	p := PURL{
+		Type:    TypePyPI,
+		Name:    name,
+		Version: version,
+	}
+	p.normalize()
+	return &p
+}
+
+// NewMaven returns a Package URL for a Maven artifact.
+func NewMaven(group, artifact, version string) *PURL {
+	p := PURL{
+		Type:      TypeMaven,
+		Namespace: group,
+		Name:      artifact,
+		Version:   version,
+	}
+	p.normalize()
+	return &p
+}
+
+// NewGo returns a Package URL for a Go module. The last element of the module
+// path is the name, and the rest is the namespace.
+func NewGo(module, version string) *PURL {
+	p := PURL{
+		Type:    TypeGo,
+		Version: version,
+	}
+	module = strings.Trim(module, "/")
+	if i := strings.LastIndexByte(module, '/'); i != -1 {
+		p.Namespace, p.Name = module[:i], module[i+1:]
+	} else {
+		p.Name = module
+	}
+	p.normalize()
+	return &p
+}
+
+// Normalize applies the type-specific normalizations from the specification.
+//
+// Go module paths are case-sensitive, so "golang" names are not lowercased.
+func (p *PURL) normalize() {
+	p.Type = strings.ToLower(p.Type)
+	switch p.Type {
+	case TypeDebian, "bitbucket", "github":
+		p.Namespace = strings.ToLower(p.Namespace)
+		p.Name = strings.ToLower(p.Name)
+	case TypePyPI:
+		p.Name = strings.ReplaceAll(strings.ToLower(p.Name), "_", "-")
+	}
+	for k, v := range p.Qualifiers {
+		if lk := strings.ToLower(k); lk != k || v == "" {
+			delete(p.Qualifiers, k)
+			if v != "" {
+				p.Qualifiers[lk] = v
+			}
+		}
+	}
+	if len(p.Qualifiers) == 0 {
+		p.Qualifiers = nil
+	}
+}
+
+// CheckType reports an error if "t" isn't a valid package type.
+func checkType(t string) error {
+	if t == "" {
+		return fmt.Errorf("%w: empty type", ErrInvalid)
+	}
+	for i, c := range t {
+		switch {
+		case c >= 'a' && c <= 'z', c == '.', c == '+', c == '-':
+		case c >= '0' && c <= '9' && i != 0:
+		default:
+			return fmt.Errorf("%w: invalid type %q", ErrInvalid, t)
+		}
+	}
+	return nil
+}
+
+// ParseQualifiers parses the qualifiers component, without the leading "?".
+func parseQualifiers(s string) (map[string]string, error) {
+	var m map[string]string
+	for _, kv := range strings.Split(s, "&") {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			if kv == "" {
+				continue
+			}
+			return nil, fmt.Errorf("missing \"=\" in %q", kv)
+		}
+		k = strings.ToLower(k)
+		if err := checkKey(k); err != nil {
+			return nil, err
+		}
+		v, err := url.PathUnescape(v)
+		if err != nil {
+			return nil, err
+		}
+		if v == "" {
+			continue
+		}
+		if _, ok := m[k]; ok {
+			return nil, fmt.Errorf("duplicate key %q", k)
+		}
+		if m == nil {
+			m = make(map[string]string)
+		}
+		m[k] = v
+	}
+	return m, nil
+}
+
+// CheckKey reports an error if "k" isn't a valid (lowercased) qualifier key.
+func checkKey(k string) error {
+	if k == "" {
+		return errors.New("empty key")
+	}
+	for i, c := range k {
+		switch {
+		case c >= 'a' && c <= 'z', c == '.', c == '-', c == '_':
+		case c >= '0' && c <= '9' && i != 0:
+		default:
+			return fmt.Errorf("invalid key %q", k)
+		}
+	}
+	return nil
+}
+
+// DecodeSegments splits "s" on "/", percent-decodes every segment, and joins
+// them back together. Empty segments are discarded, as are "." and ".." if
+// "subpath" is set.
+func decodeSegments(s string, subpath bool) (string, error) {
+	var segs []string
+	for _, seg := range strings.Split(s, "/") {
+		if seg == "" {
+			continue
+		}
+		seg, err := url.PathUnescape(seg)
+		if err != nil {
+			return "", err
+		}
+		if subpath && (seg == "." || seg == "..") {
+			continue
+		}
+		segs = append(segs, seg)
+	}
+	return strings.Join(segs, "/"), nil
+}
+
+// EncodeSegments is the inverse of decodeSegments.
+func encodeSegments(s string, subpath bool) string {
+	var segs []string
+	for _, seg := range strings.Split(s, "/") {
+		if seg == "" || (subpath && (seg == "." || seg == "..")) {
+			continue
+		}
+		segs = append(segs, escape(seg, false))
+	}
+	return strings.Join(segs, "/")
+}
+
+// Escape percent-encodes "s". Unreserved characters and ":" are left as-is, as
+// is "/" if "slash" is set.
+func escape(s string, slash bool) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9',
+			c == '-', c == '.', c == '_', c == '~', c == ':',
+			c == '/' && slash:
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&0xf])
+		}
+	}
+	return b.String()
+}
+
+// CutLast is like [strings.Cut], but slices around the last instance of
+// "sep".
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
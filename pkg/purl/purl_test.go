@@ -0,0 +1,276 @@
+package purl
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+	// Most of these are from the specification's test suite.
+	tt := []struct {
+		In        string
+		Want      PURL
+		Canonical string
+	}{
+		{
+			In: "pkg:maven/org.apache.commons/io",
+			Want: PURL{
+				Type: "maven", Namespace: "org.apache.commons", Name: "io",
+			},
+		},
+		{
+			In: "pkg:maven/org.apache.xmlgraphics/batik-anim@1.9.1?repository_url=repo.spring.io/release&classifier=sources",
+			Want: PURL{
+				Type: "maven", Namespace: "org.apache.xmlgraphics", Name: "batik-anim", Version: "1.9.1",
+				Qualifiers: map[string]string{"classifier": "sources", "repository_url": "repo.spring.io/release"},
+			},
+			Canonical: "pkg:maven/org.apache.xmlgraphics/batik-anim@1.9.1?classifier=sources&repository_url=repo.spring.io/release",
+		},
+		{
+			In: "pkg:rpm/fedora/curl@7.50.3-1.fc25?arch=i386&distro=fedora-25",
+			Want: PURL{
+				Type: "rpm", Namespace: "fedora", Name: "curl", Version: "7.50.3-1.fc25",
+				Qualifiers: map[string]string{"arch": "i386", "distro": "fedora-25"},
+			},
+		},
+		{
+			In: "pkg:rpm/redhat/openssl@1.1.1k-7.el8_6?arch=x86_64&epoch=1",
+			Want: PURL{
+				Type: "rpm", Namespace: "redhat", Name: "openssl", Version: "1.1.1k-7.el8_6",
+				Qualifiers: map[string]string{"arch": "x86_64", "epoch": "1"},
+			},
+		},
+		{
+			In: "pkg:deb/debian/Curl@7.50.3-1?arch=i386&distro=jessie",
+			Want: PURL{
+				Type: "deb", Namespace: "debian", Name: "curl", Version: "7.50.3-1",
+				Qualifiers: map[string]string{"arch": "i386", "distro": "jessie"},
+			},
+			Canonical: "pkg:deb/debian/curl@7.50.3-1?arch=i386&distro=jessie",
+		},
+		{
+			In: "pkg:PYPI/Django_package@1.11.1.dev1",
+			Want: PURL{
+				Type: "pypi", Name: "django-package", Version: "1.11.1.dev1",
+			},
+			Canonical: "pkg:pypi/django-package@1.11.1.dev1",
+		},
+		{
+			In: "pkg:golang/github.com/gorilla/context@234fd47e07d1004f0aed9c#api",
+			Want: PURL{
+				Type: "golang", Namespace: "github.com/gorilla", Name: "context", Version: "234fd47e07d1004f0aed9c",
+				Subpath: "api",
+			},
+		},
+		{
+			In: "pkg:npm/%40angular/animation@12.3.1",
+			Want: PURL{
+				Type: "npm", Namespace: "@angular", Name: "animation", Version: "12.3.1",
+			},
+		},
+		{
+			In: "pkg:GitHub/Package-URL/purl-spec@244fd47e07d1004#everybody/loves/dogs",
+			Want: PURL{
+				Type: "github", Namespace: "package-url", Name: "purl-spec", Version: "244fd47e07d1004",
+				Subpath: "everybody/loves/dogs",
+			},
+			Canonical: "pkg:github/package-url/purl-spec@244fd47e07d1004#everybody/loves/dogs",
+		},
+		{
+			In: "pkg:docker/customer/dockerimage@sha256%3A244fd47e07d10?repository_url=gcr.io",
+			Want: PURL{
+				Type: "docker", Namespace: "customer", Name: "dockerimage", Version: "sha256:244fd47e07d10",
+				Qualifiers: map[string]string{"repository_url": "gcr.io"},
+			},
+			Canonical: "pkg:docker/customer/dockerimage@sha256:244fd47e07d10?repository_url=gcr.io",
+		},
+		{
+			// Leading slashes, empty qualifiers, and odd subpath segments.
+			In: "pkg://generic//name@1.0?empty=&Key=value#/./a/../b/",
+			Want: PURL{
+				Type: "generic", Name: "name", Version: "1.0",
+				Qualifiers: map[string]string{"key": "value"},
+				Subpath:    "a/b",
+			},
+			Canonical: "pkg:generic/name@1.0?key=value#a/b",
+		},
+		{
+			In: "pkg:generic/a%2Fb@1%2B2%20%3F",
+			Want: PURL{
+				Type: "generic", Name: "a/b", Version: "1+2 ?",
+			},
+		},
+	}
+	for _, tc := range tt {
+		got, err := Parse(tc.In)
+		if err != nil {
+			t.Errorf("%s: %v", tc.In, err)
+			continue
+		}
+		if !cmp.Equal(*got, tc.Want) {
+			t.Errorf("%s: %s", tc.In, cmp.Diff(*got, tc.Want))
+		}
+		want := tc.Canonical
+		if want == "" {
+			want = tc.In
+		}
+		if got := got.String(); got != want {
+			t.Errorf("%s: got: %q, want: %q", tc.In, got, want)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	t.Parallel()
+	for _, in := range []string{
+		"",
+		"maven/org.apache.commons/io",
+		"http://example.com/io",
+		"pkg:maven",
+		"pkg:maven/",
+		"pkg:/name",
+		"pkg:9type/name",
+		"pkg:ty_pe/name",
+		"pkg:generic/name?a=1&a=2",
+		"pkg:generic/name?key",
+		"pkg:generic/name?k%20y=1",
+		"pkg:generic/name@%zz",
+	} {
+		if p, err := Parse(in); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%q: got: %v, %v; want: ErrInvalid", in, p, err)
+		}
+	}
+}
+
+func TestBuilders(t *testing.T) {
+	t.Parallel()
+	tt := []struct {
+		Got  *PURL
+		Want string
+	}{
+		{NewRPM("redhat", "openssl-libs", "1.1.1k-7.el8_6", "x86_64"), "pkg:rpm/redhat/openssl-libs@1.1.1k-7.el8_6?arch=x86_64"},
+		{NewRPM("fedora", "curl", "", ""), "pkg:rpm/fedora/curl"},
+		{NewDebian("LibSSL3", "3.0.11-1~deb12u2"), "pkg:deb/debian/libssl3@3.0.11-1~deb12u2"},
+		{NewPyPI("Typing_Extensions", "4.8.0"), "pkg:pypi/typing-extensions@4.8.0"},
+		{NewMaven("org.apache.logging.log4j", "log4j-core", "2.14.1"), "pkg:maven/org.apache.logging.log4j/log4j-core@2.14.1"},
+		{NewGo("github.com/quay/claircore", "v1.5.0"), "pkg:golang/github.com/quay/claircore@v1.5.0"},
+		{NewGo("github.com/Azure/go-autorest/autorest", "v0.11.29+incompatible"), "pkg:golang/github.com/Azure/go-autorest/autorest@v0.11.29%2Bincompatible"},
+		{NewGo("stdlib", "1.21.1"), "pkg:golang/stdlib@1.21.1"},
+	}
+	for _, tc := range tt {
+		if got := tc.Got.String(); got != tc.Want {
+			t.Errorf("got: %q, want: %q", got, tc.Want)
+		}
+		p, err := Parse(tc.Want)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if !cmp.Equal(p, tc.Got) {
+			t.Error(cmp.Diff(p, tc.Got))
+		}
+	}
+}
+
+// TestRoundTrip checks that parsing the String of a PURL returns the same
+// PURL.
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+	cfg := quick.Config{
+		MaxCount: 5000,
+		Values: func(vs []reflect.Value, r *rand.Rand) {
+			vs[0] = reflect.ValueOf(randPURL(r))
+		},
+	}
+	f := func(p *PURL) bool {
+		got, err := Parse(p.String())
+		if err != nil {
+			t.Logf("%q: %v", p.String(), err)
+			return false
+		}
+		if !cmp.Equal(got, p) {
+			t.Logf("%q: %s", p.String(), cmp.Diff(got, p))
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(f, &cfg); err != nil {
+		t.Error(err)
+	}
+}
+
+// RandPURL returns a random normalized PURL, made with one of the builders or
+// from random components.
+func randPURL(r *rand.Rand) *PURL {
+	// Characters that need escaping are overrepresented.
+	const chars = "abcXYZ019-._~:/@?#&=%+ !\"'()*,;[]\\`{}|<>^\t\n\x00\x7fé漢"
+	str := func(min int) string {
+		n := min + r.Intn(8)
+		rs := []rune(chars)
+		var b strings.Builder
+		for i := 0; i < n; i++ {
+			b.WriteRune(rs[r.Intn(len(rs))])
+		}
+		return b.String()
+	}
+	// Segment returns a string suitable for a segment of a namespace or
+	// subpath.
+	segment := func() string {
+		for {
+			s := strings.ReplaceAll(str(1), "/", "")
+			if s != "" && s != "." && s != ".." {
+				return s
+			}
+		}
+	}
+	segments := func() string {
+		segs := make([]string, r.Intn(4))
+		for i := range segs {
+			segs[i] = segment()
+		}
+		return strings.Join(segs, "/")
+	}
+	opt := func() string {
+		if r.Intn(3) == 0 {
+			return ""
+		}
+		return str(1)
+	}
+
+	switch r.Intn(7) {
+	case 0:
+		return NewRPM(segment(), str(1), opt(), opt())
+	case 1:
+		return NewDebian(str(1), opt())
+	case 2:
+		return NewPyPI(str(1), opt())
+	case 3:
+		return NewMaven(segment(), str(1), opt())
+	case 4:
+		return NewGo(segments()+"/"+segment(), opt())
+	}
+	types := []string{"generic", "npm", "oci", "c++", "a.b-c", TypeDebian, TypePyPI, "github"}
+	p := PURL{
+		Type:      types[r.Intn(len(types))],
+		Namespace: segments(),
+		Name:      str(1),
+		Version:   opt(),
+		Subpath:   segments(),
+	}
+	keys := []string{"arch", "distro", "repository_url", "a.b-c_d", "k9"}
+	for _, k := range keys[:r.Intn(len(keys))] {
+		if p.Qualifiers == nil {
+			p.Qualifiers = make(map[string]string)
+		}
+		p.Qualifiers[k] = str(1)
+	}
+	p.normalize()
+	return &p
+}
@@ -0,0 +1,258 @@
+// Package risk aggregates the CVSS scores of the vulnerabilities found in an
+// image into a single risk score.
+package risk
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/pkg/cvss"
+	"github.com/quay/claircore/pkg/dedupe"
+)
+
+// Aggregator computes a risk score from a set of vulnerabilities.
+//
+// Aggregators remember the vulnerabilities that contributed to the last
+// computed score, so they're not safe for concurrent use.
+type Aggregator interface {
+	// Score returns the risk score of "vs", in the range [0, 10].
+	//
+	// Vulnerabilities without a CVSS score, as reported by BaseScore, don't
+	// contribute to the score.
+	Score(vs []*claircore.Vulnerability) float64
+	// Explain describes the vulnerabilities that contributed to the last
+	// score returned by Score, most significant first. Each line starts with
+	// the vulnerability's ID (see [dedupe.ID]).
+	Explain() []string
+}
+
+// BaseScore returns the CVSS base score of "v", preferring the newest version
+// of CVSS with a score. If there's a vector but no score for a version, the
+// score is computed from the vector. Zero is returned if there's no usable
+// score.
+func BaseScore(v *claircore.Vulnerability) float64 {
+	for _, s := range []struct {
+		Score  float64
+		Vector string
+	}{
+		{v.CVSSv4Score, v.CVSSv4Vector},
+		{v.CVSSv3Score, v.CVSSv3Vector},
+		{v.CVSSv2Score, v.CVSSv2Vector},
+	} {
+		if s.Score > 0 {
+			return clamp(s.Score)
+		}
+		if s.Vector == "" {
+			continue
+		}
+		if vec, err := cvss.Parse(s.Vector); err == nil && vec.BaseScore() > 0 {
+			return vec.BaseScore()
+		}
+	}
+	return 0
+}
+
+// MaxScore returns an Aggregator that reports the highest base score.
+//
+// Explain reports every vulnerability with the highest score.
+func MaxScore() Aggregator {
+	return &maxScore{}
+}
+
+type maxScore struct {
+	explain []string
+}
+
+// Score implements Aggregator.
+func (a *maxScore) Score(vs []*claircore.Vulnerability) float64 {
+	fs := findings(vs)
+	a.explain = nil
+	if len(fs) == 0 {
+		return 0
+	}
+	max := fs[0].Score
+	for _, f := range fs {
+		if f.Score < max {
+			break
+		}
+		a.explain = append(a.explain, f.String())
+	}
+	return max
+}
+
+// Explain implements Aggregator.
+func (a *maxScore) Explain() []string { return a.explain }
+
+// TopNMean returns an Aggregator that reports the mean of the "n" highest base
+// scores. If there are fewer than "n" scored vulnerabilities, the mean of all
+// of them is reported. An "n" less than 1 is treated as 1.
+//
+// Explain reports the vulnerabilities that were averaged.
+func TopNMean(n int) Aggregator {
+	if n < 1 {
+		n = 1
+	}
+	return &topNMean{n: n}
+}
+
+type topNMean struct {
+	n       int
+	explain []string
+}
+
+// Score implements Aggregator.
+func (a *topNMean) Score(vs []*claircore.Vulnerability) float64 {
+	fs := findings(vs)
+	if len(fs) > a.n {
+		fs = fs[:a.n]
+	}
+	a.explain = nil
+	if len(fs) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, f := range fs {
+		sum += f.Score
+		a.explain = append(a.explain, f.String())
+	}
+	return clamp(sum / float64(len(fs)))
+}
+
+// Explain implements Aggregator.
+func (a *topNMean) Explain() []string { return a.explain }
+
+// ScoreWeights are the multipliers used by the WeightedSum Aggregator.
+//
+// For all the maps, a missing or non-positive weight is treated as 1.
+type ScoreWeights struct {
+	// FixState weights vulnerabilities by their FixState. Vulnerabilities
+	// without a FixState are treated as [claircore.FixStateFixed] if they
+	// have a FixedInVersion, and [claircore.FixStateAffected] otherwise.
+	//
+	// Weighting unfixed vulnerabilities more than fixed ones reflects that
+	// they can't be remediated by updating.
+	FixState map[claircore.FixState]float64
+	// Package weights vulnerabilities by the name of the affected package,
+	// to amplify vulnerabilities in critical packages.
+	Package map[string]float64
+	// Scale multiplies the sum of the weighted scores before it's capped at
+	// 10. If zero, 1 is used.
+	Scale float64
+}
+
+// WeightedSum returns an Aggregator that reports the sum of the base scores,
+// each multiplied by its weights from "w", capped at 10.
+//
+// Explain reports every scored vulnerability and its weighted score, in order
+// of weighted score.
+func WeightedSum(w ScoreWeights) Aggregator {
+	return &weightedSum{w: w}
+}
+
+type weightedSum struct {
+	w       ScoreWeights
+	explain []string
+}
+
+// Score implements Aggregator.
+func (a *weightedSum) Score(vs []*claircore.Vulnerability) float64 {
+	fs := findings(vs)
+	for i := range fs {
+		fs[i].Score *= a.weight(fs[i].V)
+	}
+	sortFindings(fs)
+	a.explain = nil
+	var sum float64
+	for _, f := range fs {
+		sum += f.Score
+		a.explain = append(a.explain, f.String())
+	}
+	if a.w.Scale != 0 {
+		sum *= a.w.Scale
+	}
+	return clamp(sum)
+}
+
+// Explain implements Aggregator.
+func (a *weightedSum) Explain() []string { return a.explain }
+
+// Weight returns the product of the weights that apply to "v".
+func (a *weightedSum) weight(v *claircore.Vulnerability) float64 {
+	st := v.FixState
+	if st == "" {
+		st = claircore.FixStateAffected
+		if v.FixedInVersion != "" {
+			st = claircore.FixStateFixed
+		}
+	}
+	w := 1.0
+	if f := a.w.FixState[st]; f > 0 {
+		w *= f
+	}
+	if v.Package != nil {
+		if f := a.w.Package[v.Package.Name]; f > 0 {
+			w *= f
+		}
+	}
+	return w
+}
+
+// Finding is a vulnerability and the score it contributes.
+type finding struct {
+	V     *claircore.Vulnerability
+	ID    string
+	Score float64
+}
+
+// String formats the finding for an explanation.
+func (f *finding) String() string {
+	if p := f.V.Package; p != nil && p.Name != "" {
+		return fmt.Sprintf("%s (%s %s): %.1f", f.ID, p.Name, p.Version, f.Score)
+	}
+	return fmt.Sprintf("%s: %.1f", f.ID, f.Score)
+}
+
+// Findings returns the scored vulnerabilities in "vs", sorted by descending
+// score.
+func findings(vs []*claircore.Vulnerability) []finding {
+	fs := make([]finding, 0, len(vs))
+	for _, v := range vs {
+		if v == nil {
+			continue
+		}
+		s := BaseScore(v)
+		if s == 0 {
+			continue
+		}
+		fs = append(fs, finding{V: v, ID: dedupe.ID(v), Score: s})
+	}
+	sortFindings(fs)
+	return fs
+}
+
+// SortFindings sorts "fs" by descending score, then by ID, so that the
+// results don't depend on the order of the input.
+func sortFindings(fs []finding) {
+	sort.SliceStable(fs, func(i, j int) bool {
+		a, b := &fs[i], &fs[j]
+		switch {
+		case a.Score != b.Score:
+			return a.Score > b.Score
+		case a.ID != b.ID:
+			return a.ID < b.ID
+		}
+		return a.V.ID < b.V.ID
+	})
+}
+
+// Clamp limits "f" to the range [0, 10].
+func clamp(f float64) float64 {
+	switch {
+	case f < 0:
+		return 0
+	case f > 10:
+		return 10
+	}
+	return f
+}
@@ -0,0 +1,152 @@
+package risk
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/quay/claircore"
+)
+
+var (
+	openssl = &claircore.Package{Name: "openssl", Version: "1.1.1k"}
+	zlib    = &claircore.Package{Name: "zlib", Version: "1.2.11"}
+)
+
+// Vulns is the fixture shared by the tests.
+var vulns = []*claircore.Vulnerability{
+	{ID: "1", Name: "CVE-2023-0001", Package: openssl, CVSSv3Score: 9.8, FixedInVersion: "1.1.1l"},
+	{ID: "2", Name: "RHSA-2023:0002: zlib security update CVE-2023-0002", Package: zlib, CVSSv3Score: 7.5},
+	{ID: "3", Name: "CVE-2023-0003", Package: zlib, CVSSv2Score: 5.0, FixState: claircore.FixStateWillNotFix},
+	// Only a vector: 8.1.
+	{ID: "4", Name: "CVE-2023-0004", CVSSv3Vector: "CVSS:3.1/AV:N/AC:H/PR:N/UI:N/S:U/C:H/I:H/A:H"},
+	// No score at all.
+	{ID: "5", Name: "GO-2023-0005", Package: zlib},
+	nil,
+}
+
+func TestBaseScore(t *testing.T) {
+	t.Parallel()
+	tcs := []struct {
+		V    claircore.Vulnerability
+		Want float64
+	}{
+		{claircore.Vulnerability{}, 0},
+		{claircore.Vulnerability{CVSSv2Score: 5, CVSSv3Score: 7.5}, 7.5},
+		{claircore.Vulnerability{CVSSv2Score: 5, CVSSv3Score: 7.5, CVSSv4Score: 9.3}, 9.3},
+		{claircore.Vulnerability{CVSSv2Score: 5}, 5},
+		{claircore.Vulnerability{CVSSv2Vector: "AV:N/AC:L/Au:N/C:C/I:C/A:C"}, 10},
+		{claircore.Vulnerability{CVSSv3Vector: "bogus", CVSSv2Score: 4.3}, 4.3},
+		{claircore.Vulnerability{CVSSv3Score: 12}, 10},
+	}
+	for _, tc := range tcs {
+		if got := BaseScore(&tc.V); got != tc.Want {
+			t.Errorf("%+v: got: %v, want: %v", tc.V, got, tc.Want)
+		}
+	}
+}
+
+func TestAggregator(t *testing.T) {
+	t.Parallel()
+	tcs := []struct {
+		Name    string
+		Agg     Aggregator
+		Want    float64
+		Explain []string
+	}{
+		{
+			Name:    "MaxScore",
+			Agg:     MaxScore(),
+			Want:    9.8,
+			Explain: []string{"CVE-2023-0001 (openssl 1.1.1k): 9.8"},
+		},
+		{
+			Name: "TopNMean",
+			Agg:  TopNMean(2),
+			Want: (9.8 + 8.1) / 2,
+			Explain: []string{
+				"CVE-2023-0001 (openssl 1.1.1k): 9.8",
+				"CVE-2023-0004: 8.1",
+			},
+		},
+		{
+			Name: "TopNMeanShort",
+			Agg:  TopNMean(10),
+			Want: (9.8 + 8.1 + 7.5 + 5.0) / 4,
+			Explain: []string{
+				"CVE-2023-0001 (openssl 1.1.1k): 9.8",
+				"CVE-2023-0004: 8.1",
+				"CVE-2023-0002 (zlib 1.2.11): 7.5",
+				"CVE-2023-0003 (zlib 1.2.11): 5.0",
+			},
+		},
+		{
+			Name:    "TopNMeanZero",
+			Agg:     TopNMean(0),
+			Want:    9.8,
+			Explain: []string{"CVE-2023-0001 (openssl 1.1.1k): 9.8"},
+		},
+		{
+			Name: "WeightedSum",
+			Agg: WeightedSum(ScoreWeights{
+				FixState: map[claircore.FixState]float64{
+					claircore.FixStateFixed:      0.5,
+					claircore.FixStateWillNotFix: 2,
+				},
+				Package: map[string]float64{"zlib": 0.5},
+				Scale:   0.25,
+			}),
+			// 0.25 × (9.8×0.5 + 8.1 + 7.5×0.5 + 5.0×2×0.5)
+			Want: 0.25 * (4.9 + 8.1 + 3.75 + 5),
+			Explain: []string{
+				"CVE-2023-0004: 8.1",
+				"CVE-2023-0003 (zlib 1.2.11): 5.0",
+				"CVE-2023-0001 (openssl 1.1.1k): 4.9",
+				"CVE-2023-0002 (zlib 1.2.11): 3.8",
+			},
+		},
+		{
+			Name: "WeightedSumCapped",
+			Agg:  WeightedSum(ScoreWeights{}),
+			Want: 10,
+			Explain: []string{
+				"CVE-2023-0001 (openssl 1.1.1k): 9.8",
+				"CVE-2023-0004: 8.1",
+				"CVE-2023-0002 (zlib 1.2.11): 7.5",
+				"CVE-2023-0003 (zlib 1.2.11): 5.0",
+			},
+		},
+	}
+	for _, tc := range tcs {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			if got := tc.Agg.Score(vulns); math.Abs(got-tc.Want) > 1e-9 {
+				t.Errorf("got: %v, want: %v", got, tc.Want)
+			}
+			if got, want := tc.Agg.Explain(), tc.Explain; !cmp.Equal(got, want) {
+				t.Error(cmp.Diff(got, want))
+			}
+
+			// The result shouldn't depend on the order of the input.
+			vs := append([]*claircore.Vulnerability(nil), vulns...)
+			rand.Shuffle(len(vs), func(i, j int) { vs[i], vs[j] = vs[j], vs[i] })
+			if got := tc.Agg.Score(vs); math.Abs(got-tc.Want) > 1e-9 {
+				t.Errorf("shuffled: got: %v, want: %v", got, tc.Want)
+			}
+			if got, want := tc.Agg.Explain(), tc.Explain; !cmp.Equal(got, want) {
+				t.Error(cmp.Diff(got, want))
+			}
+
+			// Nothing scored should reset the explanation.
+			if got := tc.Agg.Score(vulns[4:]); got != 0 {
+				t.Errorf("unscored: got: %v, want: 0", got)
+			}
+			if got := tc.Agg.Explain(); len(got) != 0 {
+				t.Errorf("unscored: got: %q, want: none", got)
+			}
+		})
+	}
+}
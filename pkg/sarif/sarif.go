@@ -0,0 +1,311 @@
+// Package sarif formats vulnerability scan results as SARIF 2.1.0 logs.
+//
+// SARIF (Static Analysis Results Interchange Format) is an OASIS standard
+// consumed by many CI systems' code scanning features. The specification is
+// at https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html.
+package sarif
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/quay/claircore"
+)
+
+// Version is the SARIF version produced.
+const Version = `2.1.0`
+
+// SchemaURI is the location of the SARIF 2.1.0 JSON schema.
+const SchemaURI = `https://docs.oasis-open.org/sarif/sarif/v2.1.0/errata01/os/schemas/sarif-schema-2.1.0.json`
+
+// ScanResult is a vulnerability found in a package.
+type ScanResult struct {
+	// Artifact identifies what was scanned, like a container image reference.
+	// It's optional, and recorded as the location's "uriBaseId" if provided.
+	Artifact      string
+	Package       *claircore.Package
+	Vulnerability *claircore.Vulnerability
+}
+
+// ResultsFromReport returns a ScanResult for every package and vulnerability
+// pair in "r".
+//
+// Packages without a Filepath use the package database they were found in as
+// their location.
+func ResultsFromReport(artifact string, r *claircore.VulnerabilityReport) []ScanResult {
+	var out []ScanResult
+	for pkgID, vulnIDs := range r.PackageVulnerabilities {
+		pkg, ok := r.Packages[pkgID]
+		if !ok {
+			continue
+		}
+		if pkg.Filepath == "" {
+			for _, env := range r.Environments[pkgID] {
+				if env.PackageDB != "" {
+					p := *pkg
+					p.Filepath = env.PackageDB
+					pkg = &p
+					break
+				}
+			}
+		}
+		for _, id := range vulnIDs {
+			v, ok := r.Vulnerabilities[id]
+			if !ok {
+				continue
+			}
+			out = append(out, ScanResult{
+				Artifact:      artifact,
+				Package:       pkg,
+				Vulnerability: v,
+			})
+		}
+	}
+	return out
+}
+
+// Formatter writes SARIF logs.
+//
+// The zero value is ready to use, and names the tool "claircore".
+type Formatter struct {
+	// ToolName is reported as the name of the analysis tool.
+	ToolName string
+	// ToolVersion is reported as the version of the analysis tool, if set.
+	ToolVersion string
+	// InformationURI is reported as the tool's documentation, if set.
+	InformationURI string
+}
+
+// Format writes a SARIF log with a single run containing "results" to "w".
+//
+// Each distinct vulnerability becomes a rule, identified by its CVE ID if it
+// has one, and each result is reported at the path of the affected package.
+// Results and rules are sorted, so the output is stable.
+func (f *Formatter) Format(results []ScanResult, w io.Writer) error {
+	name := f.ToolName
+	if name == "" {
+		name = "claircore"
+	}
+	l := log{
+		Schema:  SchemaURI,
+		Version: Version,
+		Runs: []run{{
+			Tool: tool{Driver: driver{
+				Name:           name,
+				Version:        f.ToolVersion,
+				InformationURI: f.InformationURI,
+				Rules:          []rule{},
+			}},
+			Results: []result{},
+		}},
+	}
+	r := &l.Runs[0]
+
+	type entry struct {
+		ruleID string
+		res    *ScanResult
+	}
+	es := make([]entry, 0, len(results))
+	rules := make(map[string]*claircore.Vulnerability)
+	for i := range results {
+		res := &results[i]
+		if res.Vulnerability == nil || res.Package == nil {
+			return fmt.Errorf("sarif: result %d: missing vulnerability or package", i)
+		}
+		id := ruleID(res.Vulnerability)
+		es = append(es, entry{ruleID: id, res: res})
+		if _, ok := rules[id]; !ok {
+			rules[id] = res.Vulnerability
+		}
+	}
+	ids := make([]string, 0, len(rules))
+	for id := range rules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	index := make(map[string]int, len(ids))
+	for i, id := range ids {
+		index[id] = i
+		r.Tool.Driver.Rules = append(r.Tool.Driver.Rules, newRule(id, rules[id]))
+	}
+	sort.SliceStable(es, func(i, j int) bool {
+		a, b := es[i], es[j]
+		switch {
+		case a.ruleID != b.ruleID:
+			return a.ruleID < b.ruleID
+		case a.res.Package.Name != b.res.Package.Name:
+			return a.res.Package.Name < b.res.Package.Name
+		default:
+			return a.res.Package.Version < b.res.Package.Version
+		}
+	})
+	for _, e := range es {
+		r.Results = append(r.Results, newResult(e.ruleID, index[e.ruleID], e.res))
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(&l); err != nil {
+		return fmt.Errorf("sarif: unable to write log: %w", err)
+	}
+	return nil
+}
+
+// CVEPattern matches CVE IDs.
+var cvePattern = regexp.MustCompile(`CVE-\d{4}-\d{4,}`)
+
+// RuleID returns the rule ID for a vulnerability: the first CVE ID in its
+// name, or failing that, the name itself.
+func ruleID(v *claircore.Vulnerability) string {
+	if id := cvePattern.FindString(v.Name); id != "" {
+		return id
+	}
+	return v.Name
+}
+
+// Level maps a severity to a SARIF result level.
+func level(s claircore.Severity) string {
+	switch s {
+	case claircore.Critical:
+		return "error"
+	case claircore.High:
+		return "warning"
+	case claircore.Medium:
+		return "note"
+	}
+	return "none"
+}
+
+// SecuritySeverity returns the highest CVSS base score of the vulnerability,
+// as a string, for the "security-severity" property GitHub code scanning
+// uses to rank results. An empty string is returned if there's no score.
+func securitySeverity(v *claircore.Vulnerability) string {
+	var s float64
+	for _, f := range []float64{v.CVSSv2Score, v.CVSSv3Score, v.CVSSv4Score} {
+		if f > s {
+			s = f
+		}
+	}
+	if s == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(s, 'f', 1, 64)
+}
+
+func newRule(id string, v *claircore.Vulnerability) rule {
+	r := rule{
+		ID:               id,
+		ShortDescription: &message{Text: v.Name},
+		Properties: &properties{
+			Tags:             []string{"security", "vulnerability"},
+			SecuritySeverity: securitySeverity(v),
+		},
+	}
+	if v.Description != "" {
+		r.FullDescription = &message{Text: v.Description}
+	}
+	if links := strings.Fields(v.Links); len(links) != 0 {
+		if u, err := url.Parse(links[0]); err == nil && u.IsAbs() {
+			r.HelpURI = links[0]
+		}
+	}
+	return r
+}
+
+func newResult(id string, idx int, res *ScanResult) result {
+	v, p := res.Vulnerability, res.Package
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "%s %s is affected by %s", p.Name, p.Version, v.Name)
+	if v.FixedInVersion != "" {
+		fmt.Fprintf(&msg, "; fixed in %s", v.FixedInVersion)
+	}
+	msg.WriteByte('.')
+	loc := location{
+		LogicalLocations: []logicalLocation{{
+			Name:               p.Name,
+			FullyQualifiedName: p.Name + "@" + p.Version,
+			Kind:               "package",
+		}},
+	}
+	if p.Filepath != "" {
+		loc.PhysicalLocation = &physicalLocation{
+			ArtifactLocation: artifactLocation{
+				URI:       (&url.URL{Path: strings.TrimPrefix(p.Filepath, "/")}).String(),
+				URIBaseID: res.Artifact,
+			},
+		}
+	}
+	return result{
+		RuleID:    id,
+		RuleIndex: idx,
+		Level:     level(v.NormalizedSeverity),
+		Message:   message{Text: msg.String()},
+		Locations: []location{loc},
+	}
+}
+
+// These types are the subset of the SARIF object model used by the
+// Formatter. Field names follow the specification.
+type (
+	log struct {
+		Schema  string `json:"$schema"`
+		Version string `json:"version"`
+		Runs    []run  `json:"runs"`
+	}
+	run struct {
+		Tool    tool     `json:"tool"`
+		Results []result `json:"results"`
+	}
+	tool struct {
+		Driver driver `json:"driver"`
+	}
+	driver struct {
+		Name           string `json:"name"`
+		Version        string `json:"version,omitempty"`
+		InformationURI string `json:"informationUri,omitempty"`
+		Rules          []rule `json:"rules"`
+	}
+	rule struct {
+		ID               string      `json:"id"`
+		ShortDescription *message    `json:"shortDescription,omitempty"`
+		FullDescription  *message    `json:"fullDescription,omitempty"`
+		HelpURI          string      `json:"helpUri,omitempty"`
+		Properties       *properties `json:"properties,omitempty"`
+	}
+	properties struct {
+		Tags             []string `json:"tags,omitempty"`
+		SecuritySeverity string   `json:"security-severity,omitempty"`
+	}
+	result struct {
+		RuleID    string     `json:"ruleId"`
+		RuleIndex int        `json:"ruleIndex"`
+		Level     string     `json:"level"`
+		Message   message    `json:"message"`
+		Locations []location `json:"locations"`
+	}
+	message struct {
+		Text string `json:"text"`
+	}
+	location struct {
+		PhysicalLocation *physicalLocation `json:"physicalLocation,omitempty"`
+		LogicalLocations []logicalLocation `json:"logicalLocations,omitempty"`
+	}
+	physicalLocation struct {
+		ArtifactLocation artifactLocation `json:"artifactLocation"`
+	}
+	artifactLocation struct {
+		URI       string `json:"uri"`
+		URIBaseID string `json:"uriBaseId,omitempty"`
+	}
+	logicalLocation struct {
+		Name               string `json:"name"`
+		FullyQualifiedName string `json:"fullyQualifiedName,omitempty"`
+		Kind               string `json:"kind,omitempty"`
+	}
+)
@@ -0,0 +1,339 @@
+package sarif
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/quay/claircore"
+)
+
+func testReport() *claircore.VulnerabilityReport {
+	return &claircore.VulnerabilityReport{
+		Packages: map[string]*claircore.Package{
+			"1": {ID: "1", Name: "curl", Version: "7.61.1-12.el8"},
+			"2": {ID: "2", Name: "log4j-core", Version: "2.14.1", Filepath: "/opt/app/lib/log4j-core-2.14.1.jar"},
+			"3": {ID: "3", Name: "libfoo", Version: "1.0-1.el8"},
+		},
+		Environments: map[string][]*claircore.Environment{
+			"1": {{PackageDB: "var/lib/rpm"}},
+			"3": {{PackageDB: "var/lib/rpm"}},
+		},
+		Vulnerabilities: map[string]*claircore.Vulnerability{
+			"a": {
+				ID:                 "a",
+				Name:               "RHSA-2020:3102: curl security update (Moderate)",
+				Description:        "curl: incorrect argument check can allow remote servers to overwrite local files",
+				Links:              "https://access.redhat.com/errata/RHSA-2020:3102 https://access.redhat.com/security/cve/CVE-2020-8177",
+				NormalizedSeverity: claircore.Medium,
+				FixedInVersion:     "0:7.61.1-12.el8_2.1",
+				CVSSv3Score:        5.3,
+			},
+			"b": {
+				ID:                 "b",
+				Name:               "CVE-2021-44228",
+				Description:        "Remote code injection in Log4j",
+				Links:              "https://nvd.nist.gov/vuln/detail/CVE-2021-44228",
+				NormalizedSeverity: claircore.Critical,
+				FixedInVersion:     "2.15.0",
+				CVSSv3Score:        10,
+			},
+			"c": {
+				ID:                 "c",
+				Name:               "libfoo: example vulnerability",
+				NormalizedSeverity: claircore.Low,
+			},
+			"d": {
+				ID:                 "d",
+				Name:               "CVE-2023-0001",
+				NormalizedSeverity: claircore.High,
+				CVSSv2Score:        7.1,
+				CVSSv4Score:        8.7,
+			},
+		},
+		PackageVulnerabilities: map[string][]string{
+			"1": {"a", "d"},
+			"2": {"b"},
+			"3": {"c", "d"},
+		},
+	}
+}
+
+func TestFormat(t *testing.T) {
+	res := ResultsFromReport("quay.io/example/app:latest", testReport())
+	f := Formatter{ToolName: "clair", ToolVersion: "4.7.0"}
+	var buf bytes.Buffer
+	if err := f.Format(res, &buf); err != nil {
+		t.Fatal(err)
+	}
+	t.Log(buf.String())
+	var doc map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	checkLog(t, doc)
+
+	run := doc["runs"].([]any)[0].(map[string]any)
+	type row struct{ Rule, Level, URI string }
+	var got []row
+	for _, r := range run["results"].([]any) {
+		r := r.(map[string]any)
+		var uri string
+		if pl, ok := r["locations"].([]any)[0].(map[string]any)["physicalLocation"].(map[string]any); ok {
+			uri = pl["artifactLocation"].(map[string]any)["uri"].(string)
+		}
+		got = append(got, row{r["ruleId"].(string), r["level"].(string), uri})
+	}
+	want := []row{
+		{"CVE-2021-44228", "error", "opt/app/lib/log4j-core-2.14.1.jar"},
+		{"CVE-2023-0001", "warning", "var/lib/rpm"},
+		{"CVE-2023-0001", "warning", "var/lib/rpm"},
+		{"RHSA-2020:3102: curl security update (Moderate)", "note", "var/lib/rpm"},
+		{"libfoo: example vulnerability", "none", "var/lib/rpm"},
+	}
+	if !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(got, want))
+	}
+	rules := run["tool"].(map[string]any)["driver"].(map[string]any)["rules"].([]any)
+	if got, want := len(rules), 4; got != want {
+		t.Errorf("got %d rules, want %d", got, want)
+	}
+	sev := rules[1].(map[string]any)["properties"].(map[string]any)["security-severity"]
+	if got, want := sev, "8.7"; got != want {
+		t.Errorf("got security-severity %v, want %v", got, want)
+	}
+
+	// Output should be stable.
+	var again bytes.Buffer
+	if err := f.Format(ResultsFromReport("quay.io/example/app:latest", testReport()), &again); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), again.Bytes()) {
+		t.Error("output differs between runs")
+	}
+}
+
+func TestFormatEmpty(t *testing.T) {
+	var f Formatter
+	var buf bytes.Buffer
+	if err := f.Format(nil, &buf); err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	checkLog(t, doc)
+
+	if err := f.Format([]ScanResult{{}}, &buf); err == nil {
+		t.Error("expected error for incomplete result")
+	}
+}
+
+// Schema is a JSON Schema document, with only the keywords used by the SARIF
+// 2.1.0 schema.
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 schemaType         `json:"type"`
+	Enum                 []any              `json:"enum"`
+	Format               string             `json:"format"`
+	Pattern              string             `json:"pattern"`
+	Minimum              *float64           `json:"minimum"`
+	MinItems             int                `json:"minItems"`
+	UniqueItems          bool               `json:"uniqueItems"`
+	Items                *schema            `json:"items"`
+	Properties           map[string]*schema `json:"properties"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Required             []string           `json:"required"`
+	AnyOf                []*schema          `json:"anyOf"`
+	Definitions          map[string]*schema `json:"definitions"`
+}
+
+// SchemaType is the "type" keyword, which may be a string or an array of
+// strings.
+type schemaType []string
+
+func (t *schemaType) UnmarshalJSON(b []byte) error {
+	if len(b) != 0 && b[0] == '[' {
+		return json.Unmarshal(b, (*[]string)(t))
+	}
+	*t = make(schemaType, 1)
+	return json.Unmarshal(b, &(*t)[0])
+}
+
+func loadSchema(t *testing.T) *schema {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", "sarif-schema-2.1.0.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s schema
+	if err := json.Unmarshal(b, &s); err != nil {
+		t.Fatal(err)
+	}
+	return &s
+}
+
+// CheckLog reports every way "doc" fails to validate against the SARIF
+// schema.
+func checkLog(t *testing.T, doc map[string]any) {
+	t.Helper()
+	root := loadSchema(t)
+	for _, err := range validate(root, root, "$", doc) {
+		t.Error(err)
+	}
+}
+
+func validate(root, s *schema, path string, v any) (errs []error) {
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/definitions/")
+		d, ok := root.Definitions[name]
+		if !ok {
+			return []error{fmt.Errorf("%s: unknown reference %q", path, s.Ref)}
+		}
+		return validate(root, d, path, v)
+	}
+	fail := func(f string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: "+f, append([]any{path}, args...)...))
+	}
+	if len(s.Type) != 0 {
+		ok := false
+		for _, t := range s.Type {
+			ok = ok || isType(t, v)
+		}
+		if !ok {
+			fail("want %v, got %T", []string(s.Type), v)
+			return errs
+		}
+	}
+	if s.Enum != nil {
+		ok := false
+		for _, e := range s.Enum {
+			ok = ok || e == v
+		}
+		if !ok {
+			fail("%v not in %v", v, s.Enum)
+		}
+	}
+	switch v := v.(type) {
+	case string:
+		switch s.Format {
+		case "uri":
+			if u, err := url.Parse(v); err != nil || !u.IsAbs() {
+				fail("bad uri %q", v)
+			}
+		case "uri-reference":
+			if _, err := url.Parse(v); err != nil {
+				fail("bad uri-reference %q", v)
+			}
+		}
+		if s.Pattern != "" && !regexp.MustCompile(s.Pattern).MatchString(v) {
+			fail("%q doesn't match %q", v, s.Pattern)
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			fail("%v less than %v", v, *s.Minimum)
+		}
+	case []any:
+		if len(v) < s.MinItems {
+			fail("want at least %d items", s.MinItems)
+		}
+		if s.UniqueItems {
+			seen := make(map[string]struct{}, len(v))
+			for _, e := range v {
+				b, _ := json.Marshal(e)
+				if _, ok := seen[string(b)]; ok {
+					fail("duplicate item %s", b)
+				}
+				seen[string(b)] = struct{}{}
+			}
+		}
+		if s.Items != nil {
+			for i, e := range v {
+				errs = append(errs, validate(root, s.Items, fmt.Sprintf("%s[%d]", path, i), e)...)
+			}
+		}
+	case map[string]any:
+		for _, r := range s.Required {
+			if _, ok := v[r]; !ok {
+				fail("missing required property %q", r)
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			ps, ok := s.Properties[k]
+			switch {
+			case ok:
+				errs = append(errs, validate(root, ps, path+"."+k, v[k])...)
+			case s.AdditionalProperties != nil && !*s.AdditionalProperties:
+				fail("unexpected property %q", k)
+			}
+		}
+	}
+	if len(s.AnyOf) != 0 {
+		ok := false
+		for _, a := range s.AnyOf {
+			ok = ok || len(validate(root, a, path, v)) == 0
+		}
+		if !ok {
+			fail("matches none of anyOf")
+		}
+	}
+	return errs
+}
+
+func isType(t string, v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return t == "null"
+	case bool:
+		return t == "boolean"
+	case string:
+		return t == "string"
+	case float64:
+		return t == "number" || (t == "integer" && v == float64(int64(v)))
+	case []any:
+		return t == "array"
+	case map[string]any:
+		return t == "object"
+	}
+	return false
+}
+
+func TestSchema(t *testing.T) {
+	// Make sure the validator rejects things, so the other tests mean
+	// something.
+	root := loadSchema(t)
+	bad := []string{
+		`{"runs":[]}`,
+		`{"version":"2.0.0","runs":[]}`,
+		`{"version":"2.1.0","runs":[{}]}`,
+		`{"version":"2.1.0","runs":[{"tool":{"driver":{"name":"x","bogus":1}}}]}`,
+		`{"version":"2.1.0","runs":[{"tool":{"driver":{"name":"x","rules":[{"id":"a"},{"id":"a"}]}}}]}`,
+		`{"version":"2.1.0","runs":[{"tool":{"driver":{"name":"x"}},"results":[{"message":{},"ruleIndex":-2}]}]}`,
+		`{"version":"2.1.0","runs":[{"tool":{"driver":{"name":"x"}},"results":[{"message":{"text":"m"},"level":"fatal"}]}]}`,
+		`{"version":"2.1.0","runs":[{"tool":{"driver":{"name":"x","informationUri":"relative/path"}}}]}`,
+	}
+	for _, in := range bad {
+		var doc any
+		if err := json.Unmarshal([]byte(in), &doc); err != nil {
+			t.Fatal(err)
+		}
+		if errs := validate(root, root, "$", doc); len(errs) == 0 {
+			t.Errorf("%s: unexpectedly valid", in)
+		}
+	}
+}
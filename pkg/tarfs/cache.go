@@ -0,0 +1,155 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"container/list"
+	"io"
+	"sync"
+)
+
+// ContentCacheMaxEntry is the size of the largest file that will be stored by
+// the cache configured with [WithContentCache].
+const ContentCacheMaxEntry = 1 << 20
+
+// WithContentCache configures an LRU cache of file contents, holding at most
+// "maxBytes" of data. Regular files no larger than [ContentCacheMaxEntry] are
+// stored in the cache when read, so that repeated reads of the same file are
+// served from memory instead of the underlying [io.ReaderAt]. Values less than
+// 1 mean no cache, which is the default.
+//
+// The cache is shared with any FSes returned by Sub. The [FS.CacheStats]
+// method reports how effective the cache is.
+func WithContentCache(maxBytes int64) Option {
+	return func(c *config) { c.contentCache = maxBytes }
+}
+
+// CacheStats reports the number of reads of regular files served from the
+// content cache and the number that had to use the underlying reader. Both
+// are zero if the FS was not created with the [WithContentCache] Option.
+func (f *FS) CacheStats() (hits, misses int64) {
+	if f.cache == nil {
+		return 0, 0
+	}
+	f.cache.mu.Lock()
+	defer f.cache.mu.Unlock()
+	return f.cache.hits, f.cache.misses
+}
+
+// ContentCache is an LRU cache of file contents, keyed by the location of the
+// member in the archive.
+//
+// Hardlinks share the location of their target, so they share cache entries
+// as well.
+type contentCache struct {
+	mu     sync.Mutex
+	max    int64
+	size   int64
+	ll     *list.List // of *cacheEntry, most recently used first
+	m      map[cacheKey]*list.Element
+	hits   int64
+	misses int64
+}
+
+// CacheKey is the offset and size of a member in the archive.
+type cacheKey struct {
+	off, sz int64
+}
+
+// CacheEntry is an element of the contentCache's list.
+type cacheEntry struct {
+	key cacheKey
+	b   []byte
+}
+
+// NewContentCache returns a contentCache holding at most "max" bytes.
+func newContentCache(max int64) *contentCache {
+	return &contentCache{
+		max: max,
+		ll:  list.New(),
+		m:   make(map[cacheKey]*list.Element),
+	}
+}
+
+// Get returns the cached contents for "k", if present. The returned slice
+// must not be modified.
+func (c *contentCache) get(k cacheKey) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.m[k]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.ll.MoveToFront(e)
+	return e.Value.(*cacheEntry).b, true
+}
+
+// Miss records a read that could not use the cache.
+func (c *contentCache) miss() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.misses++
+}
+
+// Put stores "b" as the contents for "k", evicting the least recently used
+// entries as needed.
+func (c *contentCache) put(k cacheKey, b []byte) {
+	sz := int64(len(b))
+	if sz > c.max {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.m[k]; ok {
+		// Another reader got here first.
+		return
+	}
+	for c.size+sz > c.max {
+		e := c.ll.Back()
+		ent := e.Value.(*cacheEntry)
+		c.ll.Remove(e)
+		delete(c.m, ent.key)
+		c.size -= int64(len(ent.b))
+	}
+	c.m[k] = c.ll.PushFront(&cacheEntry{key: k, b: b})
+	c.size += sz
+}
+
+// Contents returns a reader for the contents of the regular file "i", using
+// the content cache if configured.
+func (f *FS) contents(i *inode) (io.ReadSeeker, error) {
+	c := f.cache
+	cacheable := c != nil && i.h.Size <= ContentCacheMaxEntry && i.h.Size <= c.max
+	k := cacheKey{off: i.off, sz: i.sz}
+	if cacheable {
+		if b, ok := c.get(k); ok {
+			return bytes.NewReader(b), nil
+		}
+	} else if c != nil {
+		c.miss()
+	}
+	var r io.ReadSeeker
+	if i.sparse != nil {
+		r = &sparseReader{r: f.r, ext: i.sparse, size: i.h.Size}
+	} else {
+		// Read the headers to find where the contents start, then read the
+		// contents directly so that they can be seeked.
+		sr := io.NewSectionReader(f.r, i.off, i.sz)
+		if _, err := tar.NewReader(sr).Next(); err != nil {
+			return nil, err
+		}
+		start, _ := sr.Seek(0, io.SeekCurrent)
+		r = io.NewSectionReader(f.r, i.off+start, i.h.Size)
+	}
+	if !cacheable {
+		return r, nil
+	}
+	b := make([]byte, i.h.Size)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	c.put(k, b)
+	return bytes.NewReader(b), nil
+}
@@ -0,0 +1,127 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"io/fs"
+	"sort"
+)
+
+// ErrNoDigest is returned by [FS.FileDigest] when digests were not computed
+// for the FS.
+var ErrNoDigest = errors.New("tarfs: no digest computed")
+
+// WithDigests causes [New] to compute the SHA-256 digest of every regular file
+// while indexing the archive. The digests are available via [FS.FileDigest].
+//
+// This requires reading the contents of every file in the archive, in
+// addition to the headers, so it's not the default.
+func WithDigests() Option {
+	return func(c *config) { c.digests = true }
+}
+
+// IsRegular reports if the header describes a regular file.
+func isRegular(h *tar.Header) bool {
+	return h.FileInfo().Mode().IsRegular()
+}
+
+// FileDigest returns the SHA-256 digest of the contents of the named regular
+// file. Symlinks are followed.
+//
+// An error wrapping [ErrNoDigest] is returned if the FS was not created with
+// the [WithDigests] Option. Hardlinks report the digest of their targets.
+func (f *FS) FileDigest(name string) ([]byte, error) {
+	return f.fileDigest(name, 0)
+}
+
+// FileDigest is the implementation of FileDigest, tracking the number of
+// symlinks followed.
+func (f *FS) fileDigest(name string, depth int) ([]byte, error) {
+	const op = `digest`
+	if err := f.checkDepth(op, name, depth); err != nil {
+		return nil, err
+	}
+	i, err := f.getInode(op, name)
+	if err != nil {
+		return nil, err
+	}
+	typ := i.h.FileInfo().Mode().Type()
+	switch {
+	case typ.IsRegular():
+	case typ&fs.ModeSymlink != 0:
+		n, ok := f.rel(i.h.Linkname)
+		if !ok {
+			return nil, &fs.PathError{
+				Op:   op,
+				Path: name,
+				Err:  fs.ErrNotExist,
+			}
+		}
+		return f.fileDigest(n, depth+1)
+	default:
+		return nil, &fs.PathError{
+			Op:   op,
+			Path: name,
+			Err:  fs.ErrInvalid,
+		}
+	}
+	if i.digest == nil {
+		return nil, &fs.PathError{
+			Op:   op,
+			Path: name,
+			Err:  ErrNoDigest,
+		}
+	}
+	return append([]byte(nil), i.digest...), nil
+}
+
+// ContentDigest returns a SHA-256 digest of the names and contents of every
+// regular file in the FS. FSes with the same ContentDigest have the same
+// content, regardless of the order of members in the archive, timestamps, or
+// other metadata.
+//
+// The digest is computed over the sorted names of the regular files, each
+// followed by a NUL byte and the SHA-256 digest of the file's contents.
+// Directories, symlinks, and other kinds of files don't contribute, nor do
+// file modes or ownership. Hardlinks contribute under every name. Digests
+// computed because of [WithDigests] are used if present; otherwise, the
+// contents of every file are read.
+func (f *FS) ContentDigest() ([]byte, error) {
+	const op = `digest`
+	names := make([]string, 0, len(f.lookup))
+	for n, idx := range f.lookup {
+		if isRegular(f.inode[idx].h) {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+	// Hardlinks share contents, so only hash them once.
+	seen := make(map[cacheKey][]byte)
+	root, h := sha256.New(), sha256.New()
+	for _, n := range names {
+		i := &f.inode[f.lookup[n]]
+		d := i.digest
+		if d == nil {
+			k := cacheKey{off: i.off, sz: i.sz}
+			d = seen[k]
+			if d == nil {
+				r, err := f.contents(i)
+				if err != nil {
+					return nil, &fs.PathError{Op: op, Path: n, Err: err}
+				}
+				h.Reset()
+				if _, err := io.Copy(h, r); err != nil {
+					return nil, &fs.PathError{Op: op, Path: n, Err: err}
+				}
+				d = h.Sum(nil)
+				seen[k] = d
+			}
+		}
+		io.WriteString(root, n)
+		root.Write([]byte{0})
+		root.Write(d)
+	}
+	return root.Sum(nil), nil
+}
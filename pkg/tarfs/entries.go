@@ -0,0 +1,58 @@
+package tarfs
+
+import (
+	"io/fs"
+	"sort"
+	"sync"
+	"time"
+)
+
+// EntryInfo describes a single entry in the FS's index.
+type EntryInfo struct {
+	// Name is the full path of the entry in the FS.
+	Name string
+	// Size is the length of the contents, for regular files.
+	Size int64
+	// Mode is the entry's file mode bits.
+	Mode fs.FileMode
+	// ModTime is the modification time recorded in the archive.
+	ModTime time.Time
+	// Type is the entry's type bits, equivalent to Mode.Type().
+	Type fs.FileMode
+}
+
+// Entries returns a description of every entry in the FS, except for the
+// root directory, sorted by Name.
+//
+// This is built from the index constructed by New, so no file contents are
+// read and no directories need to be listed. Directories implied by member
+// names and whiteouts are included; hardlinks report the size of their
+// targets. The returned slice is owned by the caller.
+func (f *FS) Entries() []EntryInfo {
+	f.entries.once.Do(func() {
+		es := make([]EntryInfo, 0, len(f.lookup))
+		for n, i := range f.lookup {
+			if n == "." {
+				continue
+			}
+			h := f.inode[i].h
+			m := h.FileInfo().Mode()
+			es = append(es, EntryInfo{
+				Name:    n,
+				Size:    h.Size,
+				Mode:    m,
+				ModTime: h.ModTime,
+				Type:    m.Type(),
+			})
+		}
+		sort.Slice(es, func(i, j int) bool { return es[i].Name < es[j].Name })
+		f.entries.es = es
+	})
+	return append([]EntryInfo(nil), f.entries.es...)
+}
+
+// EntryCache holds the result of Entries, which is computed once.
+type entryCache struct {
+	once sync.Once
+	es   []EntryInfo
+}
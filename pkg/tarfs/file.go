@@ -8,12 +8,17 @@ import (
 	"strings"
 )
 
-var _ fs.File = (*file)(nil)
+var (
+	_ fs.File   = (*file)(nil)
+	_ io.Seeker = (*file)(nil)
+)
 
 // File implements fs.File.
 type file struct {
-	h *tar.Header
-	r *tar.Reader
+	h    *tar.Header
+	wh   *Whiteout
+	stat StatInfo
+	r    io.ReadSeeker
 }
 
 func (f *file) Close() error {
@@ -24,22 +29,28 @@ func (f *file) Read(b []byte) (int, error) {
 	return f.r.Read(b)
 }
 
+// Seek implements [io.Seeker].
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	return f.r.Seek(offset, whence)
+}
+
 func (f *file) Stat() (fs.FileInfo, error) {
-	return f.h.FileInfo(), nil
+	return fileInfo(f.h, f.wh, f.stat), nil
 }
 
 var _ fs.ReadDirFile = (*dir)(nil)
 
 // Dir implements fs.ReadDirFile.
 type dir struct {
-	h   *tar.Header
-	es  []fs.DirEntry
-	pos int
+	h    *tar.Header
+	stat StatInfo
+	es   []fs.DirEntry
+	pos  int
 }
 
 func (*dir) Close() error                 { return nil }
 func (*dir) Read(_ []byte) (int, error)   { return 0, io.EOF }
-func (d *dir) Stat() (fs.FileInfo, error) { return d.h.FileInfo(), nil }
+func (d *dir) Stat() (fs.FileInfo, error) { return fileInfo(d.h, nil, d.stat), nil }
 func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
 	es := d.es[d.pos:]
 	if len(es) == 0 {
@@ -63,14 +74,51 @@ func min(a, b int) int {
 	return b
 }
 
-type dirent struct{ *tar.Header }
+// TarDirEntry is an [fs.DirEntry] that also provides the tar header it was
+// created from.
+//
+// All [fs.DirEntry] values returned by this package implement TarDirEntry.
+type TarDirEntry interface {
+	fs.DirEntry
+	// TarHeader returns a copy of the header describing the entry.
+	//
+	// The header has had its Name, and Linkname if applicable, normalized to
+	// be relative to the root of the archive.
+	TarHeader() *tar.Header
+}
+
+type dirent struct {
+	*tar.Header
+	wh   *Whiteout
+	stat StatInfo
+}
 
-var _ fs.DirEntry = dirent{}
+var _ TarDirEntry = dirent{}
 
 func (d dirent) Name() string               { return filepath.Base(d.Header.Name) }
 func (d dirent) IsDir() bool                { return d.Header.FileInfo().IsDir() }
 func (d dirent) Type() fs.FileMode          { return d.Header.FileInfo().Mode() & fs.ModeType }
-func (d dirent) Info() (fs.FileInfo, error) { return d.FileInfo(), nil }
+func (d dirent) Info() (fs.FileInfo, error) { return fileInfo(d.Header, d.wh, d.stat), nil }
+func (d dirent) TarHeader() *tar.Header     { return copyHeader(d.Header) }
+
+// CopyHeader returns a copy of "h" that doesn't share its maps, so callers
+// can't modify the headers in an FS's index.
+func copyHeader(h *tar.Header) *tar.Header {
+	c := *h
+	if h.PAXRecords != nil {
+		c.PAXRecords = make(map[string]string, len(h.PAXRecords))
+		for k, v := range h.PAXRecords {
+			c.PAXRecords[k] = v
+		}
+	}
+	if h.Xattrs != nil {
+		c.Xattrs = make(map[string]string, len(h.Xattrs))
+		for k, v := range h.Xattrs {
+			c.Xattrs[k] = v
+		}
+	}
+	return &c
+}
 
 // SortDirent returns a function suitable to pass to sort.Slice as a "cmp"
 // function.
@@ -82,3 +130,58 @@ func sortDirent(s []fs.DirEntry) func(i, j int) bool {
 		return strings.Compare(s[i].Name(), s[j].Name()) == -1
 	}
 }
+
+// FileInfo is the [fs.FileInfo] for members of an FS, returned by the Stat
+// methods and the Info method of [fs.DirEntry] values.
+//
+// The Sys method reports a *StatInfo, except for whiteouts, where it reports
+// a *Whiteout.
+type FileInfo struct {
+	fs.FileInfo
+	h    *tar.Header
+	wh   *Whiteout
+	stat StatInfo
+}
+
+// StatInfo is the inode information for a member of an FS.
+//
+// These are computed when the FS is created, rather than taken from the
+// archive, as the archive's values are frequently unset.
+type StatInfo struct {
+	// Nlink is the number of links to the file. For regular files, this is
+	// one plus the number of hardlinks to the file. For directories, this is
+	// two plus the number of subdirectories. Everything else has one link.
+	Nlink uint64
+	// Ino is the inode number, derived from the member's position in the FS's
+	// index. Hardlinks report the number of their targets. It's never zero.
+	Ino uint64
+}
+
+// Sys implements [fs.FileInfo].
+func (fi *FileInfo) Sys() any {
+	if fi.wh != nil {
+		return fi.wh
+	}
+	st := fi.stat
+	return &st
+}
+
+// TarHeader returns a copy of the header describing the file.
+//
+// The header has had its Name, and Linkname if applicable, normalized to be
+// relative to the root of the archive.
+func (fi *FileInfo) TarHeader() *tar.Header {
+	return copyHeader(fi.h)
+}
+
+// FileInfo returns the FileInfo for the header, taking whiteouts into account.
+//
+// If "wh" is nil and "st" is the zero value, as is the case for files that
+// aren't in an FS's index, the header's FileInfo is returned as-is.
+func fileInfo(h *tar.Header, wh *Whiteout, st StatInfo) fs.FileInfo {
+	fi := h.FileInfo()
+	if wh == nil && st.Ino == 0 {
+		return fi
+	}
+	return &FileInfo{FileInfo: fi, h: h, wh: wh, stat: st}
+}
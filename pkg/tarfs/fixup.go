@@ -0,0 +1,91 @@
+package tarfs
+
+import (
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+// DuplicatePolicy controls how New treats archive members with the same name
+// as an earlier member.
+type DuplicatePolicy uint
+
+// These are the duplicate handling policies.
+const (
+	// DuplicateLastWins replaces earlier regular files with later members of
+	// the same name, as extracting the archive would. Duplicate directories
+	// keep the first member's metadata. This is the default.
+	DuplicateLastWins DuplicatePolicy = iota
+	// DuplicateFirstWins ignores members with the same name as an earlier
+	// member.
+	DuplicateFirstWins
+	// DuplicateError causes New to fail with an error wrapping [fs.ErrExist]
+	// if any members have the same name.
+	DuplicateError
+)
+
+// WithDuplicatePolicy configures how members with the same name as an earlier
+// member are treated. Every duplicate that's skipped or replaces an earlier
+// member is recorded in [FS.Fixups].
+//
+// Names are compared after normalization, so "a/b", "./a/b", and "/a/b" are
+// duplicates.
+func WithDuplicatePolicy(p DuplicatePolicy) Option {
+	return func(c *config) { c.duplicates = p }
+}
+
+// FixupKind is the kind of change recorded in a [Fixup].
+type FixupKind uint
+
+// These are the kinds of Fixups.
+const (
+	// FixupRenamed means the member's name was sanitized: it was absolute,
+	// contained ".." elements, or was not valid UTF-8.
+	FixupRenamed FixupKind = iota
+	// FixupReplaced means the member replaced an earlier member with the
+	// same name.
+	FixupReplaced
+	// FixupSkipped means the member was ignored because an earlier member
+	// had the same name.
+	FixupSkipped
+	// FixupTooLarge means the member was ignored because it was larger than
+	// the size configured by [WithMaxFileSize].
+	FixupTooLarge
+)
+
+// Fixup records a member of the archive that New didn't add to the FS exactly
+// as it appears in the archive.
+type Fixup struct {
+	// Name is the member's name as it appears in the archive.
+	Name string
+	// Path is the member's normalized name, relative to the archive root.
+	Path string
+	// Offset is the offset of the member in the archive.
+	Offset int64
+	// Kind is the change made.
+	Kind FixupKind
+}
+
+// Fixups returns the changes New made to members of the archive, in archive
+// order. A member may have more than one Fixup.
+//
+// Cosmetic differences, like a leading "./" or a trailing slash, aren't
+// recorded. In an FS returned by Sub, the Fixups for the whole archive are
+// returned.
+func (f *FS) Fixups() []Fixup {
+	return append([]Fixup(nil), f.fixups...)
+}
+
+// Sanitized reports whether normalizing the member name "n" changes its
+// meaning, rather than just its spelling.
+func sanitized(n string) bool {
+	if path.IsAbs(n) || !utf8.ValidString(n) {
+		return true
+	}
+	for _, e := range strings.Split(n, "/") {
+		if e == ".." {
+			return true
+		}
+	}
+	return false
+}
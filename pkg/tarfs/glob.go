@@ -0,0 +1,107 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// GlobCache is a cache of Glob results, keyed by pattern.
+//
+// Entries are tagged with the generation they were computed in. An FS is
+// immutable once constructed, so the generation never changes today, but any
+// future mutation must call invalidate so stale results aren't returned.
+type globCache struct {
+	gen atomic.Uint64
+	m   sync.Map // map[string]globEntry
+}
+
+// GlobEntry is a cached Glob result.
+type globEntry struct {
+	gen   uint64
+	names []string
+}
+
+// Invalidate drops all cached results.
+func (c *globCache) invalidate() {
+	c.gen.Add(1)
+}
+
+// Glob implements fs.GlobFS.
+//
+// See path.Match for the patten syntax. Results are cached per pattern, so
+// repeated calls with the same pattern don't walk the index again. The
+// returned slice is owned by the caller.
+func (f *FS) Glob(pat string) ([]string, error) {
+	// GlobFS is implemented because it can walk the index directly instead of
+	// doing a ReadDir for every directory in every call.
+	//
+	// Path.Match is documented as only returning an error when the pattern is
+	// invalid, so check it here and we can avoid the check in the loop.
+	if _, err := path.Match(pat, ""); err != nil {
+		return nil, err
+	}
+	gen := f.glob.gen.Load()
+	if v, ok := f.glob.m.Load(pat); ok {
+		if e := v.(globEntry); e.gen == gen {
+			return clone(e.names), nil
+		}
+	}
+	ret := f.globWalk(pat)
+	f.glob.m.Store(pat, globEntry{gen: gen, names: ret})
+	return clone(ret), nil
+}
+
+// GlobWalk matches the pattern against the index, one path element at a
+// time. Only directories with a matching prefix are descended into.
+func (f *FS) globWalk(pat string) []string {
+	var ret []string
+	// The root is matched against the whole pattern, as it's the only name
+	// that's not reachable as a child of some directory.
+	if ok, _ := path.Match(pat, "."); ok {
+		ret = append(ret, ".")
+	}
+	type cand struct {
+		name string
+		idx  int
+	}
+	cur := []cand{{name: ".", idx: f.lookup["."]}}
+	segs := strings.Split(pat, "/")
+	for n, seg := range segs {
+		last := n == len(segs)-1
+		var next []cand
+		for _, c := range cur {
+			for ci := range f.inode[c.idx].children {
+				t := &f.inode[ci]
+				if !last && t.h.Typeflag != tar.TypeDir {
+					continue
+				}
+				b := path.Base(t.h.Name)
+				if ok, _ := path.Match(seg, b); !ok {
+					continue
+				}
+				next = append(next, cand{name: path.Join(c.name, b), idx: ci})
+			}
+		}
+		cur = next
+		if len(cur) == 0 {
+			break
+		}
+	}
+	for _, c := range cur {
+		ret = append(ret, c.name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Clone returns a copy of the slice, preserving nil.
+func clone(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append(make([]string, 0, len(s)), s...)
+}
@@ -0,0 +1,229 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ErrIndex can be compared via [errors.Is] against errors reported by
+// [NewFromIndex] to determine if the index could not be used, because it's
+// corrupt, from an incompatible version of this package, or doesn't describe
+// the passed archive. Callers should fall back to [New] in that case.
+var ErrIndex = errors.New("tarfs: unusable index")
+
+// IndexVersion is the version of the serialized index format, and must be
+// changed whenever the format or the meaning of any stored field changes.
+const indexVersion = 1
+
+// IndexFile is the serialized form of an FS.
+type indexFile struct {
+	Version     int
+	ArchiveSize int64
+	// Check is a digest of the start and end of the archive, to catch an
+	// index being used with the wrong archive.
+	Check  []byte
+	Lookup map[string]int
+	Inodes []indexInode
+	Fixups []Fixup
+}
+
+// IndexInode is the serialized form of an inode.
+type indexInode struct {
+	Header *tar.Header
+	// Dir is set if the inode has a set of children, even if it's empty.
+	Dir      bool
+	Children []int
+	Off, Sz  int64
+	Whiteout *indexWhiteout
+	Digest   []byte
+	Stat     StatInfo
+	Sparse   []indexExtent
+}
+
+// IndexWhiteout is the serialized form of a Whiteout. The header is always
+// the inode's header.
+type indexWhiteout struct {
+	Target string
+	Opaque bool
+}
+
+// IndexExtent is the serialized form of an extent.
+type indexExtent struct {
+	Off, Sz, Phys int64
+}
+
+// WriteIndex writes a serialized form of the FS's index to "w", which can be
+// used with [NewFromIndex] to recreate the FS without reading the archive's
+// headers again.
+//
+// The index includes the results of Options that affect how the archive is
+// read, like [WithWhiteoutHandling] and [WithDigests], but not the contents
+// of the archive. WriteIndex can't be used with an FS returned by Sub.
+func (f *FS) WriteIndex(w io.Writer) error {
+	if f.root != "" {
+		return errors.New("tarfs: cannot write index of a sub FS")
+	}
+	check, err := indexCheck(f.r, f.archiveSize)
+	if err != nil {
+		return fmt.Errorf("tarfs: unable to write index: %w", err)
+	}
+	idx := indexFile{
+		Version:     indexVersion,
+		ArchiveSize: f.archiveSize,
+		Check:       check,
+		Lookup:      f.lookup,
+		Inodes:      make([]indexInode, len(f.inode)),
+		Fixups:      f.fixups,
+	}
+	for n := range f.inode {
+		i := &f.inode[n]
+		ii := &idx.Inodes[n]
+		ii.Header = i.h
+		ii.Dir = i.children != nil
+		ii.Off, ii.Sz = i.off, i.sz
+		ii.Digest = i.digest
+		ii.Stat = i.stat
+		if i.children != nil {
+			ii.Children = make([]int, 0, len(i.children))
+			for c := range i.children {
+				ii.Children = append(ii.Children, c)
+			}
+			sort.Ints(ii.Children)
+		}
+		if i.wh != nil {
+			ii.Whiteout = &indexWhiteout{Target: i.wh.Target, Opaque: i.wh.Opaque}
+		}
+		if i.sparse != nil {
+			ii.Sparse = make([]indexExtent, len(i.sparse))
+			for j, e := range i.sparse {
+				ii.Sparse[j] = indexExtent{Off: e.off, Sz: e.sz, Phys: e.phys}
+			}
+		}
+	}
+	if err := gob.NewEncoder(w).Encode(&idx); err != nil {
+		return fmt.Errorf("tarfs: unable to write index: %w", err)
+	}
+	return nil
+}
+
+// NewFromIndex creates an FS from the tar contained in the ReaderAt, using an
+// index previously written by [FS.WriteIndex] instead of reading the archive's
+// headers.
+//
+// Only Options that affect how the FS is used, like [WithMaxSymlinkDepth] and
+// [WithContentCache], have any effect; the others were applied when the index
+// was written. An error wrapping [ErrIndex] is returned if the index can't be
+// used. The archive is only spot-checked against the index, so callers should
+// store indexes keyed by something that identifies the archive, like a layer
+// digest.
+//
+// The ReaderAt must remain valid for the entire life of the returned FS and any
+// FSes returned by Sub.
+func NewFromIndex(r io.ReaderAt, idx io.Reader, opts ...Option) (*FS, error) {
+	cfg := config{
+		maxSymlinkDepth: DefaultMaxSymlinkDepth,
+	}
+	for _, o := range opts {
+		o(&cfg)
+	}
+	var in indexFile
+	if err := gob.NewDecoder(idx).Decode(&in); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrIndex, err)
+	}
+	if in.Version != indexVersion {
+		return nil, fmt.Errorf("%w: version %d (want %d)", ErrIndex, in.Version, indexVersion)
+	}
+	check, err := indexCheck(r, in.ArchiveSize)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrIndex, err)
+	}
+	if string(check) != string(in.Check) {
+		return nil, fmt.Errorf("%w: archive does not match index", ErrIndex)
+	}
+	if _, ok := in.Lookup["."]; !ok {
+		return nil, fmt.Errorf("%w: missing root", ErrIndex)
+	}
+
+	s := FS{
+		r:           r,
+		lookup:      in.Lookup,
+		inode:       make([]inode, len(in.Inodes)),
+		maxLinks:    cfg.maxSymlinkDepth,
+		archiveSize: in.ArchiveSize,
+		glob:        new(globCache),
+		entries:     new(entryCache),
+		fixups:      in.Fixups,
+	}
+	if cfg.contentCache > 0 {
+		s.cache = newContentCache(cfg.contentCache)
+	}
+	for _, i := range s.lookup {
+		if i < 0 || i >= len(s.inode) {
+			return nil, fmt.Errorf("%w: inode %d out of range", ErrIndex, i)
+		}
+	}
+	for n := range in.Inodes {
+		ii := &in.Inodes[n]
+		if ii.Header == nil {
+			return nil, fmt.Errorf("%w: inode %d: missing header", ErrIndex, n)
+		}
+		if ii.Off < 0 || ii.Sz < 0 || ii.Off+ii.Sz > in.ArchiveSize {
+			return nil, fmt.Errorf("%w: inode %d: outside of archive", ErrIndex, n)
+		}
+		i := &s.inode[n]
+		i.h = ii.Header
+		i.off, i.sz = ii.Off, ii.Sz
+		i.digest = ii.Digest
+		i.stat = ii.Stat
+		if ii.Dir {
+			i.children = make(map[int]struct{}, len(ii.Children))
+			for _, c := range ii.Children {
+				if c < 0 || c >= len(s.inode) {
+					return nil, fmt.Errorf("%w: inode %d: child %d out of range", ErrIndex, n, c)
+				}
+				i.children[c] = struct{}{}
+			}
+		}
+		if w := ii.Whiteout; w != nil {
+			i.wh = &Whiteout{Header: i.h, Target: w.Target, Opaque: w.Opaque}
+		}
+		if ii.Sparse != nil {
+			i.sparse = make([]extent, len(ii.Sparse))
+			for j, e := range ii.Sparse {
+				i.sparse[j] = extent{off: e.Off, sz: e.Sz, phys: e.Phys}
+			}
+		}
+	}
+	return &s, nil
+}
+
+// IndexCheck returns a digest of the first and last blocks of the archive,
+// which is "size" bytes long.
+func indexCheck(r io.ReaderAt, size int64) ([]byte, error) {
+	const blockSz = 512
+	h := sha256.New()
+	if size == 0 {
+		return h.Sum(nil), nil
+	}
+	b := make([]byte, blockSz)
+	for _, off := range []int64{0, size - blockSz} {
+		if off < 0 {
+			off = 0
+		}
+		n, err := r.ReadAt(b, off)
+		switch {
+		case n == blockSz:
+		case err == nil, errors.Is(err, io.EOF):
+			return nil, fmt.Errorf("short read at %d: archive is smaller than %d bytes", off, size)
+		default:
+			return nil, err
+		}
+		h.Write(b)
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,109 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+)
+
+// ReadLink returns the destination of the named symbolic link, without
+// following it.
+//
+// The destination is relative to the directory containing the link. Absolute
+// link targets in the archive are relative to the archive root, and so are
+// reported relative to the link's directory like any other target. For an FS
+// returned by Sub, the destination may point outside of the FS.
+//
+// If the named file is not a symbolic link, the returned error wraps
+// [fs.ErrInvalid]. With Go 1.25 or later, ReadLink and Lstat implement
+// fs.ReadLinkFS.
+func (f *FS) ReadLink(name string) (string, error) {
+	const op = `readlink`
+	i, err := f.lstatInode(op, name)
+	if err != nil {
+		return "", err
+	}
+	if i.h.Typeflag != tar.TypeSymlink {
+		return "", &fs.PathError{
+			Op:   op,
+			Path: name,
+			Err:  fmt.Errorf("not a symlink: %w", fs.ErrInvalid),
+		}
+	}
+	dir := path.Dir(i.h.Name)
+	tgt, err := filepath.Rel(dir, i.h.Linkname)
+	if err != nil {
+		// Should be impossible, as both are relative to the archive root.
+		return "", &fs.PathError{
+			Op:   op,
+			Path: name,
+			Err:  err,
+		}
+	}
+	return filepath.ToSlash(tgt), nil
+}
+
+// Lstat returns an [fs.FileInfo] describing the named file, without following
+// the final element if it's a symbolic link.
+func (f *FS) Lstat(name string) (fs.FileInfo, error) {
+	const op = `lstat`
+	i, err := f.lstatInode(op, name)
+	if err != nil {
+		return nil, err
+	}
+	return fileInfo(i.h, i.wh, i.stat), nil
+}
+
+// LstatInode returns the inode for "name", following symlinks in every
+// element except the final one.
+func (f *FS) lstatInode(op, name string) (*inode, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{
+			Op:   op,
+			Path: name,
+			Err:  fs.ErrInvalid,
+		}
+	}
+	if i, ok := f.lookup[name]; ok || name == "." {
+		if !ok {
+			return f.getInode(op, name)
+		}
+		return &f.inode[i], nil
+	}
+	dir, base := path.Split(name)
+	dir = path.Clean(dir)
+	p, err := f.getInode(op, dir)
+	if err != nil {
+		return nil, err
+	}
+	for depth := 0; p.h.Typeflag == tar.TypeSymlink; depth++ {
+		if err := f.checkDepth(op, name, depth); err != nil {
+			return nil, err
+		}
+		tgt, ok := f.rel(p.h.Linkname)
+		if !ok {
+			return nil, &fs.PathError{
+				Op:   op,
+				Path: name,
+				Err:  fs.ErrNotExist,
+			}
+		}
+		p, err = f.getInode(op, tgt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for ci := range p.children {
+		c := &f.inode[ci]
+		if path.Base(c.h.Name) == base {
+			return c, nil
+		}
+	}
+	return nil, &fs.PathError{
+		Op:   op,
+		Path: name,
+		Err:  fs.ErrNotExist,
+	}
+}
@@ -0,0 +1,487 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// NewMerged returns an FS presenting the union of the provided layers, as
+// specified by the OCI image layer specification. Layers are ordered from
+// oldest to newest, as in an image manifest.
+//
+// Whiteout entries remove the named path from lower layers and opaque
+// whiteouts remove everything in lower layers under their directory. The
+// whiteout entries themselves are not present in the returned FS. Whiteouts
+// are recognized by name, so the layers do not need to be created with
+// [WithWhiteoutHandling]. Hardlinks that refer to a file in a lower layer are
+// resolved against the merged contents as of their layer.
+//
+// Looking up a name returns the entry from the newest layer containing a
+// non-whiteout entry for it. The merged tree is computed once, so lookups
+// don't need to consult every layer.
+//
+// The returned FS implements [fs.StatFS], [fs.ReadDirFS], and
+// [fs.ReadFileFS]. It also has ReadLink and Lstat methods like [FS], which
+// implement fs.ReadLinkFS with Go 1.25 or later. The layers must remain valid
+// for the entire life of the returned FS.
+func NewMerged(layers []*FS) (fs.FS, error) {
+	m := merged{
+		ents:     make(map[string]*mergedEntry),
+		maxLinks: DefaultMaxSymlinkDepth,
+	}
+	root := newDir(".")
+	m.ents["."] = &mergedEntry{h: root.h, children: make(map[string]struct{})}
+	for n, l := range layers {
+		if l == nil {
+			return nil, fmt.Errorf("tarfs: merge: layer %d: nil FS", n)
+		}
+		if l.maxLinks > 0 && l.maxLinks < m.maxLinks {
+			m.maxLinks = l.maxLinks
+		}
+		if err := m.apply(l); err != nil {
+			return nil, fmt.Errorf("tarfs: merge: layer %d: %w", n, err)
+		}
+	}
+	return &m, nil
+}
+
+// Merged is the FS returned by NewMerged.
+type merged struct {
+	ents     map[string]*mergedEntry
+	maxLinks int
+}
+
+// MergedEntry is a member of the merged tree.
+type mergedEntry struct {
+	// H is the header reported for the entry.
+	h *tar.Header
+	// Layer and ino are the source of the contents for regular files. Both
+	// are nil for implicitly created directories.
+	layer *FS
+	ino   *inode
+	// Children is the set of child names for directories.
+	children map[string]struct{}
+}
+
+// IsDir reports whether the entry is a directory.
+func (e *mergedEntry) isDir() bool {
+	return e.children != nil
+}
+
+// Apply adds the contents of the layer "l" on top of the current tree.
+func (m *merged) apply(l *FS) error {
+	var names []string
+	var whs []Whiteout
+	for n, i := range l.lookup {
+		if n == "." {
+			continue
+		}
+		ino := &l.inode[i]
+		wh := ino.wh
+		if wh == nil {
+			wh, _ = parseWhiteout(WhiteoutModeOCI, ino.h)
+		}
+		if wh != nil {
+			// Use the name from this FS, in case it's a Sub.
+			if wh.Opaque {
+				whs = append(whs, Whiteout{Header: ino.h, Target: path.Dir(n), Opaque: true})
+			} else {
+				whs = append(whs, Whiteout{Header: ino.h, Target: path.Join(path.Dir(n), strings.TrimPrefix(path.Base(n), whiteoutPrefix))})
+			}
+			continue
+		}
+		names = append(names, n)
+	}
+	// Dangling hardlinks were removed from the layer's tree, but may refer to
+	// a file in a lower layer.
+	var links []string
+	linkIno := make(map[string]*inode)
+	for i := range l.inode {
+		ino := &l.inode[i]
+		if ino.h.Typeflag != tar.TypeLink {
+			continue
+		}
+		n, ok := l.rel(ino.h.Name)
+		if !ok {
+			continue
+		}
+		if _, ok := l.lookup[n]; ok {
+			continue
+		}
+		links = append(links, n)
+		linkIno[n] = ino
+	}
+
+	// Whiteouts only apply to lower layers, so handle them first.
+	sort.Slice(whs, func(i, j int) bool { return whs[i].Target < whs[j].Target })
+	for _, wh := range whs {
+		if wh.Opaque {
+			if e, ok := m.ents[wh.Target]; ok && e.isDir() {
+				for c := range e.children {
+					m.remove(path.Join(wh.Target, c))
+				}
+			}
+			continue
+		}
+		m.remove(wh.Target)
+	}
+
+	// Sorting means parents are added before their children.
+	sort.Strings(names)
+	for _, n := range names {
+		ino := &l.inode[l.lookup[n]]
+		e := mergedEntry{h: ino.h, layer: l, ino: ino}
+		if ino.h.Typeflag == tar.TypeDir {
+			e.children = make(map[string]struct{})
+		}
+		if err := m.put(n, &e); err != nil {
+			return err
+		}
+	}
+	sort.Strings(links)
+	for _, n := range links {
+		ino := linkIno[n]
+		tn, ok := l.rel(ino.h.Linkname)
+		if !ok {
+			continue
+		}
+		tgt, ok := m.ents[tn]
+		if !ok || tgt.ino == nil || !tgt.h.FileInfo().Mode().IsRegular() {
+			// Dangling in the merged tree as well.
+			continue
+		}
+		h := *ino.h
+		h.Size = tgt.h.Size
+		if err := m.put(n, &mergedEntry{h: &h, layer: tgt.layer, ino: tgt.ino}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Put adds the entry "e" at "name", creating any needed parent directories
+// and replacing any existing entry.
+//
+// Existing directories keep their children when replaced by a directory.
+// Symlinks in leading elements are followed.
+func (m *merged) put(name string, e *mergedEntry) error {
+	const op = `create`
+	depth := 0
+Again:
+	parent := m.ents["."]
+	els := strings.Split(name, "/")
+	for i, el := range els[:len(els)-1] {
+		p := strings.Join(els[:i+1], "/")
+		cur, ok := m.ents[p]
+		switch {
+		case !ok:
+		case cur.isDir():
+			parent = cur
+			continue
+		case cur.h.Typeflag == tar.TypeSymlink:
+			depth++
+			if depth > m.maxLinks {
+				return &fs.PathError{
+					Op:   op,
+					Path: name,
+					Err:  fmt.Errorf("too many levels of symbolic links: %w", ErrLimit),
+				}
+			}
+			tgt, ok := cur.layer.rel(cur.h.Linkname)
+			if !ok {
+				return &fs.PathError{
+					Op:   op,
+					Path: name,
+					Err:  fmt.Errorf("symlink %q points outside of the FS: %w", p, fs.ErrInvalid),
+				}
+			}
+			name = path.Join(append([]string{tgt}, els[i+1:]...)...)
+			goto Again
+		default:
+			// A directory replaces a non-directory in a lower layer.
+			m.remove(p)
+		}
+		d := newDir(p)
+		cur = &mergedEntry{h: d.h, children: make(map[string]struct{})}
+		m.ents[p] = cur
+		parent.children[el] = struct{}{}
+		parent = cur
+	}
+	if cur, ok := m.ents[name]; ok {
+		switch {
+		case e.isDir() && e.ino != nil && e.ino.sz == 0 &&
+			(cur.isDir() || cur.h.Typeflag == tar.TypeSymlink):
+			// Implied by the layer rather than present in it, so keep the
+			// existing entry. Children of a symlink are added via its
+			// target.
+			return nil
+		case cur.isDir() && e.isDir():
+			e.children = cur.children
+		default:
+			m.remove(name)
+		}
+	}
+	m.ents[name] = e
+	parent.children[path.Base(name)] = struct{}{}
+	return nil
+}
+
+// Remove removes "name" and, if it's a directory, everything under it.
+func (m *merged) remove(name string) {
+	e, ok := m.ents[name]
+	if !ok || name == "." {
+		return
+	}
+	for c := range e.children {
+		m.remove(path.Join(name, c))
+	}
+	delete(m.ents, name)
+	if p, ok := m.ents[path.Dir(name)]; ok {
+		delete(p.children, path.Base(name))
+	}
+}
+
+// Resolve returns the canonical name and entry for "name", following symlinks
+// in every element.
+func (m *merged) resolve(op, name string) (string, *mergedEntry, error) {
+	if !fs.ValidPath(name) {
+		return "", nil, &fs.PathError{
+			Op:   op,
+			Path: name,
+			Err:  fs.ErrInvalid,
+		}
+	}
+	orig := name
+	depth := 0
+Again:
+	cur := "."
+	e := m.ents["."]
+	if name == "." {
+		return cur, e, nil
+	}
+	els := strings.Split(name, "/")
+	for i, el := range els {
+		p := path.Join(cur, el)
+		next, ok := m.ents[p]
+		if !ok || !e.isDir() {
+			return "", nil, &fs.PathError{
+				Op:   op,
+				Path: orig,
+				Err:  fs.ErrNotExist,
+			}
+		}
+		if next.h.Typeflag == tar.TypeSymlink {
+			depth++
+			if depth > m.maxLinks {
+				return "", nil, &fs.PathError{
+					Op:   op,
+					Path: orig,
+					Err:  fmt.Errorf("too many levels of symbolic links: %w", ErrLimit),
+				}
+			}
+			tgt, ok := next.layer.rel(next.h.Linkname)
+			if !ok {
+				return "", nil, &fs.PathError{
+					Op:   op,
+					Path: orig,
+					Err:  fs.ErrNotExist,
+				}
+			}
+			name = path.Join(append([]string{tgt}, els[i+1:]...)...)
+			goto Again
+		}
+		cur, e = p, next
+	}
+	return cur, e, nil
+}
+
+// Open implements fs.FS.
+func (m *merged) Open(name string) (fs.File, error) {
+	const op = `open`
+	n, e, err := m.resolve(op, name)
+	if err != nil {
+		return nil, err
+	}
+	typ := e.h.FileInfo().Mode().Type()
+	switch {
+	case typ.IsDir():
+		return &dir{h: e.h, es: m.dirents(n, e)}, nil
+	case typ.IsRegular():
+	default:
+		// Pretend all other kinds of files don't exist.
+		return nil, &fs.PathError{
+			Op:   op,
+			Path: name,
+			Err:  fs.ErrExist,
+		}
+	}
+	r, err := e.reader()
+	if err != nil {
+		return nil, &fs.PathError{
+			Op:   op,
+			Path: name,
+			Err:  err,
+		}
+	}
+	return &file{h: e.h, r: r}, nil
+}
+
+// Reader returns a reader for the entry's contents.
+func (e *mergedEntry) reader() (io.ReadSeeker, error) {
+	if e.ino == nil {
+		return nil, errors.New("no contents")
+	}
+	return e.layer.contents(e.ino)
+}
+
+// Dirents returns the sorted entries of the directory "e" at "name".
+func (m *merged) dirents(name string, e *mergedEntry) []fs.DirEntry {
+	ret := make([]fs.DirEntry, 0, len(e.children))
+	for c := range e.children {
+		ret = append(ret, dirent{Header: m.ents[path.Join(name, c)].h})
+	}
+	sort.Slice(ret, sortDirent(ret))
+	return ret
+}
+
+// Stat implements fs.StatFS.
+func (m *merged) Stat(name string) (fs.FileInfo, error) {
+	_, e, err := m.resolve(`stat`, name)
+	if err != nil {
+		return nil, err
+	}
+	return e.h.FileInfo(), nil
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (m *merged) ReadDir(name string) ([]fs.DirEntry, error) {
+	const op = `readdir`
+	n, e, err := m.resolve(op, name)
+	if err != nil {
+		return nil, err
+	}
+	if !e.isDir() {
+		return nil, &fs.PathError{
+			Op:   op,
+			Path: name,
+			Err:  fmt.Errorf("not a directory: %w", fs.ErrInvalid),
+		}
+	}
+	return m.dirents(n, e), nil
+}
+
+// ReadFile implements fs.ReadFileFS.
+func (m *merged) ReadFile(name string) ([]byte, error) {
+	const op = `readfile`
+	_, e, err := m.resolve(op, name)
+	if err != nil {
+		return nil, err
+	}
+	if !e.h.FileInfo().Mode().IsRegular() {
+		return nil, &fs.PathError{
+			Op:   op,
+			Path: name,
+			Err:  fmt.Errorf("not a regular file: %w", fs.ErrInvalid),
+		}
+	}
+	r, err := e.reader()
+	if err != nil {
+		return nil, &fs.PathError{
+			Op:   op,
+			Path: name,
+			Err:  err,
+		}
+	}
+	b := make([]byte, e.h.Size)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, &fs.PathError{
+			Op:   op,
+			Path: name,
+			Err:  err,
+		}
+	}
+	return b, nil
+}
+
+// Lstat returns an [fs.FileInfo] describing the named file, without following
+// the final element if it's a symbolic link.
+func (m *merged) Lstat(name string) (fs.FileInfo, error) {
+	_, e, err := m.lresolve(`lstat`, name)
+	if err != nil {
+		return nil, err
+	}
+	return e.h.FileInfo(), nil
+}
+
+// ReadLink returns the destination of the named symbolic link, without
+// following it. The destination is relative to the directory containing the
+// link, as with [FS.ReadLink].
+func (m *merged) ReadLink(name string) (string, error) {
+	const op = `readlink`
+	n, e, err := m.lresolve(op, name)
+	if err != nil {
+		return "", err
+	}
+	if e.h.Typeflag != tar.TypeSymlink {
+		return "", &fs.PathError{
+			Op:   op,
+			Path: name,
+			Err:  fmt.Errorf("not a symlink: %w", fs.ErrInvalid),
+		}
+	}
+	// Prefer the target as seen from the link's place in the merged tree. A
+	// target outside of the layer's root is reported as the layer would.
+	dir, tgt := path.Dir(n), ""
+	if t, ok := e.layer.rel(e.h.Linkname); ok {
+		tgt = t
+	} else {
+		dir, tgt = path.Dir(e.h.Name), e.h.Linkname
+	}
+	rel, err := filepath.Rel(dir, tgt)
+	if err != nil {
+		// Should be impossible, as both are relative to the same root.
+		return "", &fs.PathError{
+			Op:   op,
+			Path: name,
+			Err:  err,
+		}
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// Lresolve is like resolve, but doesn't follow the final element if it's a
+// symlink.
+func (m *merged) lresolve(op, name string) (string, *mergedEntry, error) {
+	if !fs.ValidPath(name) {
+		return "", nil, &fs.PathError{
+			Op:   op,
+			Path: name,
+			Err:  fs.ErrInvalid,
+		}
+	}
+	if name == "." {
+		return name, m.ents["."], nil
+	}
+	dir, base := path.Split(name)
+	dn, de, err := m.resolve(op, path.Clean(dir))
+	if err != nil {
+		return "", nil, err
+	}
+	n := path.Join(dn, base)
+	e, ok := m.ents[n]
+	if !ok || !de.isDir() {
+		return "", nil, &fs.PathError{
+			Op:   op,
+			Path: name,
+			Err:  fs.ErrNotExist,
+		}
+	}
+	return n, e, nil
+}
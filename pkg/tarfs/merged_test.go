@@ -0,0 +1,290 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMerged(t *testing.T) {
+	layers := []*FS{
+		mkFS(t, []tar.Header{
+			{Name: `etc/`, Typeflag: tar.TypeDir, Mode: 0o755},
+			{Name: `etc/passwd`},
+			{Name: `etc/group`},
+			{Name: `var/`, Typeflag: tar.TypeDir},
+			{Name: `var/lib/`, Typeflag: tar.TypeDir},
+			{Name: `var/lib/old`},
+			{Name: `var/cache`},
+			{Name: `opt/a/b`},
+			{Name: `usr/lib/libc.so`},
+			{Name: `lib`, Typeflag: tar.TypeSymlink, Linkname: `usr/lib`},
+		}),
+		mkFS(t, []tar.Header{
+			{Name: `etc/.wh.group`},
+			{Name: `var/lib/.wh..wh..opq`},
+			{Name: `var/lib/new`},
+			{Name: `lib/libm.so`},
+			{Name: `hard`, Typeflag: tar.TypeLink, Linkname: `etc/passwd`},
+		}),
+		mkFS(t, []tar.Header{
+			{Name: `etc/passwd`},
+			{Name: `var/.wh.cache`},
+			{Name: `opt/a`},
+			{Name: `var/cache/`, Typeflag: tar.TypeDir},
+			{Name: `var/cache/file`},
+		}),
+	}
+	sys, err := NewMerged(layers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		Name string
+		Want string
+	}{
+		{Name: "etc/passwd", Want: "etc/passwd"},
+		{Name: "var/lib/new", Want: "var/lib/new"},
+		{Name: "var/cache/file", Want: "var/cache/file"},
+		{Name: "opt/a", Want: "opt/a"},
+		{Name: "lib/libc.so", Want: "usr/lib/libc.so"},
+		{Name: "usr/lib/libm.so", Want: "lib/libm.so"},
+		{Name: "hard", Want: "etc/passwd"},
+	} {
+		b, err := fs.ReadFile(sys, tc.Name)
+		if err != nil {
+			t.Errorf("%s: %v", tc.Name, err)
+			continue
+		}
+		if got, want := string(b), tc.Want; got != want {
+			t.Errorf("%s: got: %q, want: %q", tc.Name, got, want)
+		}
+	}
+	for _, n := range []string{
+		"etc/group",
+		"etc/.wh.group",
+		"var/lib/old",
+		"var/lib/.wh..wh..opq",
+		"var/.wh.cache",
+		"opt/a/b",
+	} {
+		if _, err := fs.Stat(sys, n); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%s: unexpected error: %v", n, err)
+		}
+	}
+	fi, err := fs.Stat(sys, "etc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fi.Mode().Perm(), fs.FileMode(0o755); got != want {
+		t.Errorf("etc: got mode %v, want %v", got, want)
+	}
+
+	if err := fstest.TestFS(sys,
+		"etc/passwd",
+		"var/lib/new",
+		"var/cache/file",
+		"opt/a",
+		"usr/lib/libc.so",
+		"usr/lib/libm.so",
+		"hard",
+	); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMergedReadLink(t *testing.T) {
+	layers := []*FS{
+		mkFS(t, []tar.Header{
+			{Name: `usr/bin/python3.11`},
+			{Name: `usr/bin/python3`, Typeflag: tar.TypeSymlink, Linkname: `python3.11`},
+			{Name: `bin`, Typeflag: tar.TypeSymlink, Linkname: `usr/bin`},
+			{Name: `old`, Typeflag: tar.TypeSymlink, Linkname: `usr/bin/python3.11`},
+		}),
+		mkFS(t, []tar.Header{
+			{Name: `usr/bin/python3.12`},
+			{Name: `usr/bin/python3`, Typeflag: tar.TypeSymlink, Linkname: `/usr/bin/python3.12`},
+			{Name: `old`},
+		}),
+	}
+	sys, err := NewMerged(layers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl, ok := sys.(interface {
+		ReadLink(string) (string, error)
+		Lstat(string) (fs.FileInfo, error)
+	})
+	if !ok {
+		t.Fatalf("%T does not implement ReadLink and Lstat", sys)
+	}
+	for n, want := range map[string]string{
+		"usr/bin/python3": "python3.12",
+		"bin/python3":     "python3.12",
+		"bin":             "usr/bin",
+	} {
+		got, err := rl.ReadLink(n)
+		if err != nil {
+			t.Errorf("%q: %v", n, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("%q: got: %q, want: %q", n, got, want)
+		}
+	}
+	for _, n := range []string{"usr/bin/python3.11", "old", "usr", "."} {
+		if _, err := rl.ReadLink(n); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("%q: unexpected error: %v", n, err)
+		}
+	}
+	for _, n := range []string{"nope", "old/nope"} {
+		if _, err := rl.ReadLink(n); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%q: unexpected error: %v", n, err)
+		}
+	}
+
+	fi, err := rl.Lstat("bin/python3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fi.Mode().Type(), fs.ModeSymlink; got != want {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+	fi, err = fs.Stat(sys, "bin/python3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.Mode().IsRegular() {
+		t.Errorf("unexpected mode: %v", fi.Mode())
+	}
+}
+
+func TestMergedEmpty(t *testing.T) {
+	sys, err := NewMerged(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(sys); err != nil {
+		t.Error(err)
+	}
+	if _, err := NewMerged([]*FS{nil}); err == nil {
+		t.Error("expected error for nil layer")
+	}
+}
+
+func BenchmarkMerged(b *testing.B) {
+	const ct = 10
+	layers := make([]*FS, ct)
+	for i := range layers {
+		sys, err := New(bytes.NewReader(mkMergeBenchTar(b, i)))
+		if err != nil {
+			b.Fatal(err)
+		}
+		layers[i] = sys
+	}
+
+	b.Run("Merged", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			sys, err := NewMerged(layers)
+			if err != nil {
+				b.Fatal(err)
+			}
+			n := 0
+			err = fs.WalkDir(sys, ".", func(_ string, d fs.DirEntry, err error) error {
+				if err == nil && d.Type().IsRegular() {
+					n++
+				}
+				return err
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+			if n == 0 {
+				b.Fatal("no files")
+			}
+		}
+	})
+	// Sequential does the equivalent work by walking every layer and keeping a
+	// map of the visible files.
+	b.Run("Sequential", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			seen := make(map[string]struct{})
+			for _, l := range layers {
+				err := fs.WalkDir(l, ".", func(p string, d fs.DirEntry, err error) error {
+					switch {
+					case err != nil:
+						return err
+					case !d.Type().IsRegular():
+					case strings.HasPrefix(d.Name(), whiteoutPrefix):
+						tgt := path.Join(path.Dir(p), strings.TrimPrefix(d.Name(), whiteoutPrefix))
+						for k := range seen {
+							if k == tgt || strings.HasPrefix(k, tgt+"/") {
+								delete(seen, k)
+							}
+						}
+					default:
+						seen[p] = struct{}{}
+					}
+					return nil
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			if len(seen) == 0 {
+				b.Fatal("no files")
+			}
+		}
+	})
+}
+
+// MkMergeBenchTar creates the layer "n" of a synthetic image: each layer
+// replaces some files from the layer below, removes a directory, and adds a
+// new directory of files.
+func mkMergeBenchTar(b *testing.B, n int) []byte {
+	b.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	contents := []byte("contents")
+	add := func(name string) {
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Size:     int64(len(contents)),
+			Mode:     0o644,
+		}); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := tw.Write(contents); err != nil {
+			b.Fatal(err)
+		}
+	}
+	const dirs, perDir = 20, 50
+	for d := 0; d < dirs; d++ {
+		for f := 0; f < perDir; f++ {
+			if n != 0 && f%10 != n {
+				continue
+			}
+			add(fmt.Sprintf("usr/share/dir%02d/file%02d", d, f))
+		}
+	}
+	if n != 0 {
+		add(fmt.Sprintf("usr/share/.wh.dir%02d", n))
+	}
+	for f := 0; f < perDir; f++ {
+		add(fmt.Sprintf("opt/layer%02d/file%02d", n, f))
+	}
+	if err := tw.Close(); err != nil {
+		b.Fatal(err)
+	}
+	return buf.Bytes()
+}
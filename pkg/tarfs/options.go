@@ -0,0 +1,214 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"path"
+	"strings"
+
+	"github.com/quay/claircore/internal/zreader"
+)
+
+// ErrLimit can be compared via [errors.Is] against errors reported by [New]
+// and the methods of [FS] to determine if a configured limit was exceeded.
+var ErrLimit = errors.New("tarfs: limit exceeded")
+
+// ErrMaliciousPath can be compared via [errors.Is] against errors reported by
+// [New] to determine if the archive was rejected because of a member name that
+// attempts to traverse outside of the archive root. Errors that match
+// ErrMaliciousPath also match [fs.ErrInvalid].
+var ErrMaliciousPath = fmt.Errorf("tarfs: malicious path: %w", fs.ErrInvalid)
+
+// DefaultMaxSymlinkDepth is the number of symlinks that will be followed when
+// resolving a name if not configured otherwise. This is the same as the Linux
+// kernel's limit.
+const DefaultMaxSymlinkDepth = 40
+
+// These are the limits used by NewLenient.
+const (
+	LenientMaxEntries      = 1 << 20
+	LenientMaxSymlinkDepth = DefaultMaxSymlinkDepth
+	LenientMaxTotalSize    = 10 << 30
+)
+
+// Option configures the FS returned by New.
+type Option func(*config)
+
+// Config is the configuration built by Options.
+type config struct {
+	maxEntries      int
+	maxSymlinkDepth int
+	maxTotalSize    int64
+	maxFileSize     int64
+	strictPaths     bool
+	whiteout        WhiteoutMode
+	memLimit        int64
+	memLimitSet     bool
+	digests         bool
+	contentCache    int64
+	duplicates      DuplicatePolicy
+	ignoreZeros     bool
+}
+
+// WithMaxEntries limits the number of entries in the archive. Values less than
+// 1 mean no limit.
+func WithMaxEntries(n int) Option {
+	return func(c *config) { c.maxEntries = n }
+}
+
+// WithMaxSymlinkDepth limits the number of symlinks followed when resolving a
+// single name. Values less than 1 mean no limit, which is only advisable for
+// trusted archives.
+func WithMaxSymlinkDepth(n int) Option {
+	return func(c *config) { c.maxSymlinkDepth = n }
+}
+
+// WithMaxTotalSize limits the sum of the sizes of all entries in the archive.
+// Values less than 1 mean no limit.
+func WithMaxTotalSize(n int64) Option {
+	return func(c *config) { c.maxTotalSize = n }
+}
+
+// WithMaxFileSize leaves regular files larger than "n" bytes out of the FS,
+// as if they weren't in the archive. Every such member is recorded in
+// [FS.Fixups]. Hardlinks to a skipped file are dropped as well. Values less
+// than 1 mean no limit.
+//
+// This is useful for bounding the work done by callers that read whole files
+// into memory.
+func WithMaxFileSize(n int64) Option {
+	return func(c *config) { c.maxFileSize = n }
+}
+
+// WithStrictPaths rejects archives with members that could be interpreted as
+// traversing outside of the archive root: absolute names, names with ".."
+// elements, and link targets that resolve outside of the root.
+//
+// By default, only names that still escape the root after cleaning are
+// rejected; absolute names are interpreted relative to the archive root, and
+// link targets are clamped to the archive root.
+func WithStrictPaths() Option {
+	return func(c *config) { c.strictPaths = true }
+}
+
+// WithIgnoreZeros causes New to skip blocks of zeroes in the archive instead
+// of treating them as the end of the archive, like the "--ignore-zeros" flag
+// to GNU tar. This allows reading archives made by concatenating tar files,
+// which some build tools produce: without it, only the members of the first
+// archive are present.
+//
+// The whole of the ReaderAt is scanned for headers, so it must not have any
+// trailing data that isn't part of an archive.
+func WithIgnoreZeros() Option {
+	return func(c *config) { c.ignoreZeros = true }
+}
+
+// NewLenient creates an FS from the tar read from "r", using defensive limits
+// appropriate for processing untrusted archives, such as container image
+// layers from public registries.
+//
+// Despite the name, this is the recommended constructor for untrusted input:
+// it is lenient in what archives it accepts, but not in how many resources it
+// will spend on them. The limits used are [LenientMaxEntries],
+// [LenientMaxSymlinkDepth], and [LenientMaxTotalSize], and path traversal
+// attempts are rejected as with [WithStrictPaths]. Additional Options are
+// applied after these defaults.
+//
+// Compressed archives are decompressed transparently, as with [NewCompressed].
+// If "r" is an [io.ReaderAt] containing an uncompressed tar it's used
+// directly. Otherwise, it's read as if by [NewFromStream].
+func NewLenient(r io.Reader, opts ...Option) (*FS, error) {
+	opts = append([]Option{
+		WithMaxEntries(LenientMaxEntries),
+		WithMaxSymlinkDepth(LenientMaxSymlinkDepth),
+		WithMaxTotalSize(LenientMaxTotalSize),
+		WithStrictPaths(),
+	}, opts...)
+	ra, ok := r.(io.ReaderAt)
+	if !ok {
+		return NewCompressed(r, opts...)
+	}
+	// Peek at the start of the archive without disturbing "ra", so that an
+	// uncompressed archive can be indexed in place.
+	zr, kind, err := zreader.Detect(io.NewSectionReader(ra, 0, math.MaxInt64))
+	switch {
+	case err == nil:
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+	default:
+		return nil, fmt.Errorf("tarfs: unable to detect compression: %w", err)
+	}
+	defer zr.Close()
+	if kind == zreader.KindNone {
+		return New(ra, opts...)
+	}
+	return NewFromStream(zr, opts...)
+}
+
+// CheckName reports an error if the header's name escapes the archive root
+// after cleaning.
+//
+// Absolute names are allowed, because they're interpreted as relative to the
+// archive root; some images in the wild have such names.
+func checkName(h *tar.Header) error {
+	c := path.Clean(h.Name)
+	if c == ".." || strings.HasPrefix(c, "../") {
+		return &fs.PathError{
+			Op:   `create`,
+			Path: h.Name,
+			Err:  fmt.Errorf("member name escapes archive root: %w", ErrMaliciousPath),
+		}
+	}
+	return nil
+}
+
+// CheckTraversal reports an error if the header's name or link target attempt
+// to reach outside of the archive root.
+func checkTraversal(h *tar.Header) error {
+	const op = `create`
+	bad := func(p string) bool {
+		if path.IsAbs(p) {
+			return true
+		}
+		for _, e := range strings.Split(p, "/") {
+			if e == ".." {
+				return true
+			}
+		}
+		return false
+	}
+	if bad(h.Name) {
+		return &fs.PathError{
+			Op:   op,
+			Path: h.Name,
+			Err:  fmt.Errorf("path traversal in member name: %w", ErrMaliciousPath),
+		}
+	}
+	switch h.Typeflag {
+	case tar.TypeSymlink:
+		if path.IsAbs(h.Linkname) {
+			// Absolute symlinks are resolved against the archive root.
+			break
+		}
+		tgt := path.Join(path.Dir(h.Name), h.Linkname)
+		if tgt == ".." || strings.HasPrefix(tgt, "../") {
+			return &fs.PathError{
+				Op:   op,
+				Path: h.Name,
+				Err:  fmt.Errorf("symlink target %q escapes root: %w", h.Linkname, ErrMaliciousPath),
+			}
+		}
+	case tar.TypeLink:
+		if bad(h.Linkname) {
+			return &fs.PathError{
+				Op:   op,
+				Path: h.Name,
+				Err:  fmt.Errorf("path traversal in hardlink target %q: %w", h.Linkname, ErrMaliciousPath),
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,381 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	_ fs.StatFS     = (*Overlay)(nil)
+	_ fs.ReadDirFS  = (*Overlay)(nil)
+	_ fs.ReadFileFS = (*Overlay)(nil)
+)
+
+// Overlay is an in-memory writable layer over an FS, allowing files to be
+// added, replaced, or removed without rebuilding the archive. It's intended
+// for tests that need to modify a real layer, such as to inject a package
+// database.
+//
+// Reads consult the overlay first and then fall through to the base FS, which
+// is never modified. Symlinks in the base FS are followed when resolving names,
+// so files written beneath a symlinked directory are visible through the
+// link.
+//
+// An Overlay is safe for concurrent use.
+type Overlay struct {
+	base *FS
+
+	mu   sync.RWMutex
+	ents map[string]*overlayEntry
+}
+
+// OverlayEntry is a change recorded by an Overlay.
+type overlayEntry struct {
+	kind overlayKind
+	h    *tar.Header
+	b    []byte
+}
+
+// OverlayKind is the kind of an overlayEntry.
+type overlayKind uint8
+
+const (
+	// OverlayFile is a regular file, with contents.
+	overlayFile overlayKind = iota
+	// OverlayDir is a directory created to hold an overlaid file. It hides
+	// any contents in the base FS.
+	overlayDir
+	// OverlayRemoved hides the name in the base FS.
+	overlayRemoved
+)
+
+// NewOverlay returns an Overlay on top of "base". The base FS must remain
+// valid for the entire life of the returned Overlay.
+func NewOverlay(base *FS) *Overlay {
+	return &Overlay{
+		base: base,
+		ents: make(map[string]*overlayEntry),
+	}
+}
+
+// WriteFile creates or replaces the named regular file with "content" and the
+// permission bits of "mode". Any missing parent directories are created.
+//
+// It's an error to replace a directory, or to create a file beneath a
+// non-directory. If the name refers to a symlink, the link's target is
+// written.
+func (o *Overlay) WriteFile(name string, content []byte, mode fs.FileMode) error {
+	const op = `write`
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if !mode.IsRegular() {
+		return &fs.PathError{
+			Op:   op,
+			Path: name,
+			Err:  fmt.Errorf("mode %v is not a regular file: %w", mode, fs.ErrInvalid),
+		}
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	p, err := o.resolve(op, name, true)
+	if err != nil {
+		return err
+	}
+	if fi, err := o.lstat(p); err == nil && fi.IsDir() {
+		return &fs.PathError{
+			Op:   op,
+			Path: name,
+			Err:  fmt.Errorf("new file cannot replace directory: %w", fs.ErrExist),
+		}
+	}
+	// Check the parents before modifying anything, so a failed write has no
+	// effect.
+	var mkdir []string
+	for d := path.Dir(p); d != "."; d = path.Dir(d) {
+		fi, err := o.lstat(d)
+		switch {
+		case err != nil:
+			mkdir = append(mkdir, d)
+		case !fi.IsDir():
+			return &fs.PathError{
+				Op:   op,
+				Path: name,
+				Err:  fmt.Errorf("parent %q is not a directory: %w", d, fs.ErrExist),
+			}
+		}
+	}
+	now := time.Now()
+	for _, d := range mkdir {
+		o.ents[d] = &overlayEntry{
+			kind: overlayDir,
+			h: &tar.Header{
+				Typeflag: tar.TypeDir,
+				Name:     d,
+				Mode:     0o755,
+				ModTime:  now,
+			},
+		}
+	}
+	o.ents[p] = &overlayEntry{
+		kind: overlayFile,
+		h: &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     p,
+			Size:     int64(len(content)),
+			Mode:     int64(mode.Perm()),
+			ModTime:  now,
+		},
+		b: append([]byte(nil), content...),
+	}
+	return nil
+}
+
+// Remove removes the named file or directory, including the contents of a
+// directory. A symlink is removed, not its target.
+func (o *Overlay) Remove(name string) error {
+	const op = `remove`
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	p, err := o.resolve(op, name, false)
+	if err != nil {
+		return err
+	}
+	if _, err := o.lstat(p); err != nil {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	pfx := p + "/"
+	for n := range o.ents {
+		if n == p || strings.HasPrefix(n, pfx) {
+			delete(o.ents, n)
+		}
+	}
+	// If that uncovered an entry in the base FS, hide it.
+	if _, err := o.lstat(p); err == nil {
+		o.ents[p] = &overlayEntry{kind: overlayRemoved}
+	}
+	return nil
+}
+
+// Open implements fs.FS.
+func (o *Overlay) Open(name string) (fs.File, error) {
+	const op = `open`
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	p, err := o.resolvePath(op, name)
+	if err != nil {
+		return nil, err
+	}
+	e, h, err := o.lookup(op, name, p)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case e != nil && e.kind == overlayFile:
+		return &file{h: e.h, r: bytes.NewReader(e.b)}, nil
+	case h.Typeflag == tar.TypeDir:
+		es, err := o.readDir(op, name, p)
+		if err != nil {
+			return nil, err
+		}
+		return &dir{h: h, es: es}, nil
+	}
+	return o.base.Open(p)
+}
+
+// Stat implements fs.StatFS.
+func (o *Overlay) Stat(name string) (fs.FileInfo, error) {
+	const op = `stat`
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	p, err := o.resolvePath(op, name)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := o.lookup(op, name, p); err != nil {
+		return nil, err
+	}
+	return o.lstat(p)
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (o *Overlay) ReadDir(name string) ([]fs.DirEntry, error) {
+	const op = `readdir`
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	p, err := o.resolvePath(op, name)
+	if err != nil {
+		return nil, err
+	}
+	_, h, err := o.lookup(op, name, p)
+	if err != nil {
+		return nil, err
+	}
+	if h.Typeflag != tar.TypeDir {
+		return nil, &fs.PathError{
+			Op:   op,
+			Path: name,
+			Err:  fmt.Errorf("not a directory: %w", fs.ErrInvalid),
+		}
+	}
+	return o.readDir(op, name, p)
+}
+
+// ReadFile implements fs.ReadFileFS.
+func (o *Overlay) ReadFile(name string) ([]byte, error) {
+	const op = `readfile`
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	p, err := o.resolvePath(op, name)
+	if err != nil {
+		return nil, err
+	}
+	e, h, err := o.lookup(op, name, p)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case e != nil && e.kind == overlayFile:
+		return append([]byte(nil), e.b...), nil
+	case h.Typeflag == tar.TypeDir:
+		return nil, &fs.PathError{
+			Op:   op,
+			Path: name,
+			Err:  fmt.Errorf("is a directory: %w", fs.ErrInvalid),
+		}
+	}
+	return o.base.ReadFile(p)
+}
+
+// ResolvePath validates "name" and resolves it, following every symlink.
+func (o *Overlay) resolvePath(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return o.resolve(op, name, true)
+}
+
+// Resolve returns the name that "name" refers to in the combined view,
+// following symlinks in every element except, if "last" is unset, the final
+// one. The returned name has no symlinks in its leading elements.
+//
+// The caller must hold o.mu.
+func (o *Overlay) resolve(op, name string, last bool) (string, error) {
+	todo := strings.Split(name, "/")
+	if name == "." {
+		todo = todo[:0]
+	}
+	cur := "."
+	for links := 0; len(todo) != 0; {
+		next := path.Join(cur, todo[0])
+		todo = todo[1:]
+		if len(todo) == 0 && !last {
+			return next, nil
+		}
+		fi, err := o.lstat(next)
+		if err != nil || fi.Mode()&fs.ModeSymlink == 0 {
+			cur = next
+			continue
+		}
+		links++
+		if err := o.base.checkDepth(op, name, links); err != nil {
+			return "", err
+		}
+		tgt, err := o.base.ReadLink(next)
+		if err != nil {
+			return "", err
+		}
+		// Restart from the root with the link's target in place of the link.
+		tgt = normPath(path.Join(path.Dir(next), tgt))
+		cur = "."
+		if tgt != "." {
+			todo = append(strings.Split(tgt, "/"), todo...)
+		}
+	}
+	return cur, nil
+}
+
+// Lookup reports what the resolved name "p" refers to: the overlay entry, if
+// any, and the header describing it. The original "name" is used in errors.
+//
+// The caller must hold o.mu.
+func (o *Overlay) lookup(op, name, p string) (*overlayEntry, *tar.Header, error) {
+	if e, ok := o.ents[p]; ok && e.kind != overlayRemoved {
+		return e, e.h, nil
+	}
+	if _, err := o.lstat(p); err != nil {
+		return nil, nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	i, err := o.base.lstatInode(op, p)
+	if err != nil {
+		return nil, nil, err
+	}
+	return nil, i.h, nil
+}
+
+// Lstat returns the FileInfo for the name "p" in the combined view, without
+// following symlinks in any element. Names hidden by the overlay report
+// [fs.ErrNotExist].
+//
+// The caller must hold o.mu.
+func (o *Overlay) lstat(p string) (fs.FileInfo, error) {
+	if e, ok := o.ents[p]; ok {
+		if e.kind == overlayRemoved {
+			return nil, fs.ErrNotExist
+		}
+		return e.h.FileInfo(), nil
+	}
+	// If an ancestor is in the overlay, the base FS is hidden.
+	for d := p; d != "."; {
+		d = path.Dir(d)
+		if _, ok := o.ents[d]; ok {
+			return nil, fs.ErrNotExist
+		}
+	}
+	return o.base.Lstat(p)
+}
+
+// ReadDir returns the sorted entries of the directory at the resolved name
+// "p".
+//
+// The caller must hold o.mu.
+func (o *Overlay) readDir(op, name, p string) ([]fs.DirEntry, error) {
+	byName := make(map[string]fs.DirEntry)
+	if e, ok := o.ents[p]; !ok || e.kind != overlayDir {
+		// Only directories from the base FS have base contents.
+		es, err := o.base.ReadDir(p)
+		if err != nil {
+			return nil, &fs.PathError{Op: op, Path: name, Err: err}
+		}
+		for _, e := range es {
+			byName[e.Name()] = e
+		}
+	}
+	for n, e := range o.ents {
+		if path.Dir(n) != p || n == "." {
+			continue
+		}
+		b := path.Base(n)
+		if e.kind == overlayRemoved {
+			delete(byName, b)
+			continue
+		}
+		byName[b] = dirent{Header: e.h}
+	}
+	es := make([]fs.DirEntry, 0, len(byName))
+	for _, e := range byName {
+		es = append(es, e)
+	}
+	sort.Slice(es, sortDirent(es))
+	return es, nil
+}
@@ -0,0 +1,149 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestOverlay(t *testing.T) {
+	base := mkFS(t, []tar.Header{
+		{Name: `etc/`, Typeflag: tar.TypeDir, Mode: 0o755},
+		{Name: `etc/passwd`},
+		{Name: `etc/group`},
+		{Name: `var/lib/rpm/Packages`},
+		{Name: `var/lib/dpkg/status`},
+		{Name: `usr/lib/libc.so`},
+		{Name: `lib`, Typeflag: tar.TypeSymlink, Linkname: `usr/lib`},
+	})
+	sys := NewOverlay(base)
+
+	for _, tc := range []struct {
+		Name    string
+		Content string
+	}{
+		{Name: "etc/passwd", Content: "root:x:0:0::/root:/bin/sh\n"},
+		{Name: "etc/os-release", Content: "ID=test\n"},
+		{Name: "opt/new/file", Content: "new"},
+		{Name: "lib/libm.so", Content: "libm"},
+	} {
+		if err := sys.WriteFile(tc.Name, []byte(tc.Content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sys.Remove("etc/group"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sys.Remove("var/lib/rpm"); err != nil {
+		t.Fatal(err)
+	}
+	// Writing beneath a removed directory re-creates it, without the old
+	// contents.
+	if err := sys.WriteFile("var/lib/rpm/rpmdb.sqlite", []byte("sqlite"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		Name string
+		Want string
+	}{
+		{Name: "etc/passwd", Want: "root:x:0:0::/root:/bin/sh\n"},
+		{Name: "etc/os-release", Want: "ID=test\n"},
+		{Name: "opt/new/file", Want: "new"},
+		{Name: "var/lib/dpkg/status", Want: "var/lib/dpkg/status"},
+		{Name: "var/lib/rpm/rpmdb.sqlite", Want: "sqlite"},
+		{Name: "lib/libc.so", Want: "usr/lib/libc.so"},
+		{Name: "lib/libm.so", Want: "libm"},
+		{Name: "usr/lib/libm.so", Want: "libm"},
+	} {
+		b, err := fs.ReadFile(sys, tc.Name)
+		if err != nil {
+			t.Errorf("%s: %v", tc.Name, err)
+			continue
+		}
+		if got, want := string(b), tc.Want; got != want {
+			t.Errorf("%s: got: %q, want: %q", tc.Name, got, want)
+		}
+	}
+	for _, n := range []string{
+		"etc/group",
+		"var/lib/rpm/Packages",
+	} {
+		if _, err := fs.Stat(sys, n); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%s: unexpected error: %v", n, err)
+		}
+	}
+
+	// The base FS is unchanged.
+	b, err := fs.ReadFile(base, "etc/passwd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "etc/passwd"; got != want {
+		t.Errorf("base etc/passwd: got: %q, want: %q", got, want)
+	}
+	for _, n := range []string{"etc/group", "var/lib/rpm/Packages"} {
+		if _, err := fs.Stat(base, n); err != nil {
+			t.Errorf("base %s: %v", n, err)
+		}
+	}
+	if _, err := fs.Stat(base, "etc/os-release"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("base etc/os-release: unexpected error: %v", err)
+	}
+
+	if err := fstest.TestFS(sys,
+		"etc/passwd",
+		"etc/os-release",
+		"opt/new/file",
+		"var/lib/dpkg/status",
+		"var/lib/rpm/rpmdb.sqlite",
+		"usr/lib/libc.so",
+		"usr/lib/libm.so",
+	); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOverlayErrors(t *testing.T) {
+	sys := NewOverlay(mkFS(t, []tar.Header{
+		{Name: `etc/`, Typeflag: tar.TypeDir, Mode: 0o755},
+		{Name: `etc/passwd`},
+	}))
+	for _, tc := range []struct {
+		Name string
+		Mode fs.FileMode
+		Err  error
+	}{
+		{Name: "etc", Mode: 0o644, Err: fs.ErrExist},
+		{Name: "etc/passwd/file", Mode: 0o644, Err: fs.ErrExist},
+		{Name: "/etc/shadow", Mode: 0o644, Err: fs.ErrInvalid},
+		{Name: ".", Mode: 0o644, Err: fs.ErrInvalid},
+		{Name: "etc/link", Mode: fs.ModeSymlink | 0o777, Err: fs.ErrInvalid},
+	} {
+		if err := sys.WriteFile(tc.Name, nil, tc.Mode); !errors.Is(err, tc.Err) {
+			t.Errorf("write %s: got: %v, want: %v", tc.Name, err, tc.Err)
+		}
+	}
+	if err := sys.Remove("etc/shadow"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("remove etc/shadow: unexpected error: %v", err)
+	}
+	// Removing a file only present in the overlay leaves nothing behind.
+	if err := sys.WriteFile("etc/shadow", nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := sys.Remove("etc/shadow"); err != nil {
+		t.Fatal(err)
+	}
+	es, err := fs.ReadDir(sys, "etc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(es), 1; got != want {
+		t.Errorf("etc: got %d entries, want %d", got, want)
+	}
+	if err := fstest.TestFS(sys, "etc/passwd"); err != nil {
+		t.Error(err)
+	}
+}
@@ -3,10 +3,11 @@ package tarfs
 import (
 	"archive/tar"
 	"bytes"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"io"
-	"strconv"
+	"math"
 )
 
 // The value we should find in the "magic" position of the tar header.
@@ -16,6 +17,12 @@ var (
 	magicOldGNU = []byte("ustar  \x00")
 )
 
+// GNU type flags not defined in [archive/tar].
+const (
+	typeGNUDumpDir = 'D'
+	typeGNUVolHdr  = 'V'
+)
+
 // ErrFormat can be compared via [errors.Is] against errors reported by [New]
 // to determine if the tar fail is considered well-formed.
 var ErrFormat = errors.New("tarfs: format error reading file")
@@ -35,7 +42,10 @@ func (e parseError) Error() string     { return string(e) }
 // FindSegments looks at a tar blockwise to establish where individual files and
 // their headers are stored. Each returned segment describes a region that is
 // not a complete tar file, but can have exactly one file read from it.
-func findSegments(r io.ReaderAt) ([]segment, error) {
+//
+// If "ignoreZeros" is set, blocks of zeroes are skipped instead of ending the
+// archive, so that concatenated archives are read in full.
+func findSegments(r io.ReaderAt, ignoreZeros bool) ([]segment, error) {
 	// Constants and offsets from POSIX.
 	const (
 		blockSz    = 512
@@ -43,6 +53,7 @@ func findSegments(r io.ReaderAt) ([]segment, error) {
 		versionOff = 263
 		typeflag   = 156
 		sizeOff    = 124
+		chksumOff  = 148
 	)
 	b := make([]byte, blockSz)
 	var ret []segment
@@ -89,6 +100,12 @@ Scan:
 			}
 		}
 		switch {
+		case zeroBlock && ignoreZeros:
+			// The trailer of one archive, or padding. Keep looking for
+			// headers until the end of the file.
+			blk++
+			cur = blk
+			continue
 		// Tar files end with two blocks of zeroes. These two arms track that.
 		case !zeroes && zeroBlock:
 			zeroes = true
@@ -99,6 +116,10 @@ Scan:
 		case zeroes && !zeroBlock:
 			// Found the first trailer block, but not the second.
 			return nil, parseErr("bad block at %d: expected second trailer block", off)
+		case b[typeflag] == typeGNUVolHdr && validChecksum(b, chksumOff):
+			// GNU tar writes volume headers without any magic, so the best that
+			// can be done is checking the header checksum. These are skipped
+			// below.
 		// These arms are belt-and-suspenders to make sure we're reading a
 		// header block and not a contents block, somehow.
 		case bytes.Equal(b[magicOff:][:8], magicOldGNU):
@@ -121,14 +142,34 @@ Scan:
 		if sz%blockSz != 0 {
 			nBlk++
 		}
-		blk++       // Current header block
+		typ := b[typeflag]
+		blk++ // Current header block
+		if typ == tar.TypeGNUSparse && b[gnuExtendedOff] != 0 {
+			// Old GNU sparse headers may be followed by extension blocks
+			// holding the rest of the sparse map. These aren't counted in
+			// the size.
+			for {
+				off := blk * blockSz
+				if n, err := r.ReadAt(b, off); n != blockSz {
+					return nil, parseErr("short read of sparse header at %d: %v", off, err)
+				}
+				blk++
+				if b[sparseExtendedOff] == 0 {
+					break
+				}
+			}
+		}
 		blk += nBlk // File contents
-		switch b[typeflag] {
-		case tar.TypeXHeader, tar.TypeGNULongLink, tar.TypeGNULongName, tar.TypeGNUSparse:
+		switch typ {
+		case tar.TypeXHeader, tar.TypeGNULongLink, tar.TypeGNULongName:
 			// All these are prepended to a "real" entry.
-		case tar.TypeBlock, tar.TypeChar, tar.TypeCont, tar.TypeDir, tar.TypeFifo, tar.TypeLink, tar.TypeReg, tar.TypeRegA, tar.TypeSymlink:
+		case typeGNUVolHdr:
+			// Volume headers in GNU multi-volume and incremental archives
+			// describe the archive, not a member. Skip them.
+			cur = blk
+		case tar.TypeBlock, tar.TypeChar, tar.TypeCont, tar.TypeDir, tar.TypeFifo, tar.TypeLink, tar.TypeReg, tar.TypeRegA, tar.TypeSymlink, typeGNUDumpDir, tar.TypeGNUSparse:
 			// Found a data block, emit it:
-			ret = append(ret, segment{start: cur * blockSz, size: (blk - cur) * blockSz})
+			ret = append(ret, segment{start: cur * blockSz, size: (blk - cur) * blockSz, hdr: off})
 			fallthrough
 		default:
 			// any blocks not enumerated are not handled.
@@ -138,10 +179,36 @@ Scan:
 	return ret, nil
 }
 
+// ValidChecksum reports whether the header block "b" has a correct checksum,
+// where "off" is the offset of the checksum field.
+//
+// See also: src/archive/tar/format.go
+func validChecksum(b []byte, off int) bool {
+	const chksumSz = 8
+	want, err := parseNumber(b[off:][:chksumSz])
+	if err != nil {
+		return false
+	}
+	// The checksum is calculated with the checksum field itself treated as
+	// spaces. Some old implementations used signed bytes, so accept either.
+	var unsigned, signed int64
+	for i, c := range b {
+		if i >= off && i < off+chksumSz {
+			c = ' '
+		}
+		unsigned += int64(c)
+		signed += int64(int8(c))
+	}
+	return want == unsigned || want == signed
+}
+
 // Segment describes one file in a tar, including relevant headers.
 type segment struct {
 	start int64
 	size  int64
+	// Hdr is the offset of the member's own header block, after any
+	// prepended headers.
+	hdr int64
 }
 
 // ParseNumber extracts a number from the encoded form in the tar header.
@@ -185,21 +252,104 @@ func parseNumber(b []byte) (int64, error) {
 	if len(b) == 0 {
 		return 0, nil
 	}
-	n, err := strconv.ParseUint(cstring(b), 8, 63) // Only positive int64 values allowed.
-	if err != nil {
-		return 0, err
+	// This is strconv.ParseUint(cstring(b), 8, 63), but without converting to
+	// a string. Only positive int64 values are allowed.
+	b = cstring(b)
+	var n uint64
+	for _, c := range b {
+		if c < '0' || c > '7' {
+			return 0, fmt.Errorf("invalid octal number %q", b)
+		}
+		if n>>60 != 0 {
+			return 0, errors.New("integer overflow")
+		}
+		n = n<<3 | uint64(c-'0')
+	}
+	if n>>63 != 0 {
+		return 0, errors.New("integer overflow")
 	}
 	return int64(n), nil
 }
 
 // Cstring interprets the byte slice as a C string. If there is no NULL, it
-// returns the entire slice as a string.
+// returns the entire slice.
 //
 // The entire-slice behavior handles the case where a fixed size header field is
 // fully populated.
-func cstring(b []byte) string {
+func cstring(b []byte) []byte {
 	if i := bytes.IndexByte(b, 0); i >= 0 {
-		return string(b[:i])
+		return b[:i]
 	}
-	return string(b)
+	return b
+}
+
+// HeaderReader reads the headers for segments returned by findSegments.
+//
+// Creating a [tar.Reader] for every segment is expensive, so a single
+// tar.Reader is reused across contiguous segments. The segment a header belongs
+// to is determined by examining the reader's offset after each header is read.
+type headerReader struct {
+	r io.ReaderAt
+	// These are the current readers. The tar.Reader is nil if a new pair
+	// needs to be constructed.
+	sr *io.SectionReader
+	tr *tar.Reader
+	// Base is the offset of "sr" in "r".
+	base int64
+	// End is the end offset of the previously read segment.
+	end int64
+}
+
+// Next returns the header for the segment. Segments must be passed in order.
+func (h *headerReader) Next(seg segment) (*tar.Header, error) {
+	fresh := h.tr == nil || seg.start != h.end
+	if fresh {
+		h.reset(seg.start)
+	}
+	h.end = seg.start + seg.size
+	for {
+		hdr, err := h.tr.Next()
+		if err == nil {
+			cur, _ := h.sr.Seek(0, io.SeekCurrent)
+			pos := h.base + cur
+			switch {
+			case pos <= seg.start:
+				// Belongs to the previous segment. This happens with some
+				// types findSegments treats as prefixes but archive/tar treats
+				// as entries.
+				continue
+			case pos <= h.end:
+				return hdr, nil
+			default:
+				err = parseErr("header @%d not in segment [%d, %d)", pos, seg.start, h.end)
+			}
+		}
+		if fresh {
+			h.tr = nil
+			return nil, err
+		}
+		// Retry with a reader positioned at this segment before reporting
+		// an error.
+		h.reset(seg.start)
+		fresh = true
+	}
+}
+
+// Digest returns the SHA-256 of the contents of the entry most recently
+// returned by Next.
+func (h *headerReader) Digest() ([]byte, error) {
+	d := sha256.New()
+	if _, err := io.Copy(d, h.tr); err != nil {
+		// Make sure the next call doesn't pick up a reader in a strange state.
+		h.tr = nil
+		return nil, err
+	}
+	return d.Sum(nil), nil
+}
+
+// Reset positions the readers at "off".
+func (h *headerReader) reset(off int64) {
+	h.base = off
+	h.sr = io.NewSectionReader(h.r, off, math.MaxInt64-off)
+	h.tr = tar.NewReader(h.sr)
 }
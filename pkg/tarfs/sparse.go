@@ -0,0 +1,263 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"bufio"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Offsets into old GNU sparse headers and their extension blocks.
+//
+// See also: src/archive/tar/format.go
+const (
+	gnuSparseOff      = 386
+	gnuExtendedOff    = 482
+	gnuSparseEntries  = 4
+	sparseExtendedOff = 504
+	sparseEntries     = 21
+)
+
+// PAX records used by the GNU sparse formats.
+const (
+	paxGNUSparseMajor = "GNU.sparse.major"
+	paxGNUSparseMinor = "GNU.sparse.minor"
+	paxGNUSparseMap   = "GNU.sparse.map"
+)
+
+// MaxSparseMap is the largest sparse map that will be read, in bytes. This is
+// the same as archive/tar's limit on special files.
+const maxSparseMap = 1 << 20
+
+// Extent is a region of a sparse file that's stored in the archive. Everything
+// outside the extents of a sparse file is a hole, and reads as zeroes.
+type extent struct {
+	// Off and Sz are the logical offset and size of the region in the file.
+	off, sz int64
+	// Phys is the offset of the region's data in the archive.
+	phys int64
+}
+
+// ReadSparse returns the extents of the member described by "h" and "seg", or
+// nil if it's not a sparse file. The returned slice is non-nil for sparse files
+// that are entirely holes.
+//
+// The GNU sparse formats are understood: old GNU "S" entries and the 0.0, 0.1,
+// and 1.0 PAX formats. Entries are expected to already be validated by
+// archive/tar, but the extents are checked to be within the segment.
+func readSparse(r io.ReaderAt, seg segment, h *tar.Header) ([]extent, error) {
+	const blockSz = 512
+	var (
+		spans []int64 // Pairs of offset, size.
+		data  int64   // Offset of the first extent's data.
+		err   error
+	)
+	switch {
+	case h.Typeflag == tar.TypeGNUSparse:
+		spans, data, err = readOldGNUSparse(r, seg.hdr)
+	case h.PAXRecords[paxGNUSparseMajor] == "1" && h.PAXRecords[paxGNUSparseMinor] == "0":
+		spans, data, err = readSparseMap1x0(r, seg.hdr+blockSz)
+	case h.PAXRecords[paxGNUSparseMap] != "":
+		// Archive/tar transforms the 0.0 format into the 0.1 format.
+		spans, err = readSparseMap0x1(h.PAXRecords[paxGNUSparseMap])
+		data = seg.hdr + blockSz
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	end := seg.start + seg.size
+	ext := make([]extent, 0, len(spans)/2)
+	var last int64
+	for i := 0; i < len(spans); i += 2 {
+		off, sz := spans[i], spans[i+1]
+		switch {
+		case off < last || sz < 0 || off > h.Size-sz:
+			return nil, parseErr("bad sparse extent for %q: [%d, %d+%d)", h.Name, off, off, sz)
+		case data > end-sz:
+			return nil, parseErr("sparse extent for %q past end of segment: %d+%d > %d", h.Name, data, sz, end)
+		case sz == 0:
+			// GNU tar writes an empty extent at the end of the file.
+			continue
+		}
+		ext = append(ext, extent{off: off, sz: sz, phys: data})
+		data += sz
+		last = off + sz
+	}
+	return ext, nil
+}
+
+// ReadOldGNUSparse reads the sparse map from the old GNU header at "off" and
+// any extension blocks following it. The returned offset is that of the data
+// following the header.
+func readOldGNUSparse(r io.ReaderAt, off int64) ([]int64, int64, error) {
+	const (
+		blockSz = 512
+		entSz   = 24
+	)
+	var spans []int64
+	b := make([]byte, blockSz)
+	if n, err := r.ReadAt(b, off); n != blockSz {
+		return nil, 0, parseErr("short read of sparse header at %d: %v", off, err)
+	}
+	s, n, ext := b[gnuSparseOff:], gnuSparseEntries, gnuExtendedOff
+	for {
+		for i := 0; i < n; i++ {
+			e := s[i*entSz:][:entSz]
+			if e[0] == 0x00 {
+				break
+			}
+			o, err := parseNumber(e[:12])
+			if err != nil {
+				return nil, 0, parseErr("invalid sparse offset at %d: %v", off, err)
+			}
+			l, err := parseNumber(e[12:])
+			if err != nil {
+				return nil, 0, parseErr("invalid sparse length at %d: %v", off, err)
+			}
+			spans = append(spans, o, l)
+		}
+		more := b[ext] != 0
+		off += blockSz
+		if !more {
+			break
+		}
+		if n, err := r.ReadAt(b, off); n != blockSz {
+			return nil, 0, parseErr("short read of sparse header at %d: %v", off, err)
+		}
+		s, n, ext = b, sparseEntries, sparseExtendedOff
+	}
+	return spans, off, nil
+}
+
+// ReadSparseMap1x0 reads the sparse map stored at the start of the data of a
+// GNU PAX 1.0 sparse file, at "off". The map is padded to a block boundary; the
+// returned offset is that of the data following it.
+func readSparseMap1x0(r io.ReaderAt, off int64) ([]int64, int64, error) {
+	const blockSz = 512
+	br := bufio.NewReader(io.NewSectionReader(r, off, maxSparseMap))
+	var read int64
+	next := func() (int64, error) {
+		l, err := br.ReadString('\n')
+		read += int64(len(l))
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		return strconv.ParseInt(l[:len(l)-1], 10, 64)
+	}
+	ct, err := next()
+	if err != nil || ct < 0 || ct > maxSparseMap/4 {
+		return nil, 0, parseErr("invalid sparse map at %d: %v", off, err)
+	}
+	spans := make([]int64, 2*ct)
+	for i := range spans {
+		if spans[i], err = next(); err != nil {
+			return nil, 0, parseErr("invalid sparse map at %d: %v", off, err)
+		}
+	}
+	if read%blockSz != 0 {
+		read += blockSz - read%blockSz
+	}
+	return spans, off + read, nil
+}
+
+// ReadSparseMap0x1 parses the sparse map stored in the "GNU.sparse.map" PAX
+// record.
+func readSparseMap0x1(m string) ([]int64, error) {
+	fields := strings.Split(m, ",")
+	if len(fields)%2 != 0 {
+		return nil, parseErr("invalid sparse map %q", m)
+	}
+	spans := make([]int64, len(fields))
+	for i, f := range fields {
+		n, err := strconv.ParseInt(f, 10, 64)
+		if err != nil {
+			return nil, parseErr("invalid sparse map %q: %v", m, err)
+		}
+		spans[i] = n
+	}
+	return spans, nil
+}
+
+// SparseReader reads the contents of a sparse file. Holes are served as zeroes
+// without reading from the archive.
+type sparseReader struct {
+	r   io.ReaderAt
+	ext []extent
+	// Cur is the index of the first extent not entirely before "pos".
+	cur  int
+	pos  int64
+	size int64
+}
+
+// Read implements [io.Reader].
+func (s *sparseReader) Read(b []byte) (int, error) {
+	if s.pos >= s.size {
+		return 0, io.EOF
+	}
+	if rem := s.size - s.pos; int64(len(b)) > rem {
+		b = b[:rem]
+	}
+	for s.cur < len(s.ext) && s.ext[s.cur].off+s.ext[s.cur].sz <= s.pos {
+		s.cur++
+	}
+	var n int
+	var err error
+	if s.cur == len(s.ext) || s.pos < s.ext[s.cur].off {
+		// In a hole.
+		end := s.size
+		if s.cur != len(s.ext) {
+			end = s.ext[s.cur].off
+		}
+		if l := end - s.pos; int64(len(b)) > l {
+			b = b[:l]
+		}
+		for i := range b {
+			b[i] = 0
+		}
+		n = len(b)
+	} else {
+		e := &s.ext[s.cur]
+		in := s.pos - e.off
+		if l := e.sz - in; int64(len(b)) > l {
+			b = b[:l]
+		}
+		n, err = s.r.ReadAt(b, e.phys+in)
+		switch {
+		case errors.Is(err, io.EOF) && n == len(b):
+			err = nil
+		case errors.Is(err, io.EOF):
+			err = io.ErrUnexpectedEOF
+		}
+	}
+	s.pos += int64(n)
+	return n, err
+}
+
+// Seek implements [io.Seeker].
+func (s *sparseReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.pos
+	case io.SeekEnd:
+		offset += s.size
+	default:
+		return 0, errors.New("tarfs: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("tarfs: negative position")
+	}
+	s.pos = offset
+	s.cur = sort.Search(len(s.ext), func(i int) bool {
+		return s.ext[i].off+s.ext[i].sz > offset
+	})
+	return offset, nil
+}
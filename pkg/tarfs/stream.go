@@ -0,0 +1,138 @@
+package tarfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/quay/claircore/internal/zreader"
+)
+
+// DefaultMemoryLimit is the number of bytes NewFromStream will buffer in
+// memory before spilling to a temporary file, if not configured otherwise.
+const DefaultMemoryLimit = 64 << 20
+
+// WithMemoryLimit sets the number of bytes NewFromStream will buffer in memory
+// before spilling the archive to a temporary file. Values less than 1 mean the
+// archive is always spilled.
+//
+// This has no effect on New.
+func WithMemoryLimit(n int64) Option {
+	return func(c *config) {
+		c.memLimit = n
+		c.memLimitSet = true
+	}
+}
+
+// NewFromStream creates an FS from the tar read from "r", configured according
+// to the provided Options.
+//
+// The entire stream is consumed before NewFromStream returns, so the returned
+// FS does not reference "r". Archives no larger than the memory limit (see
+// [WithMemoryLimit] and [DefaultMemoryLimit]) are held in memory; larger
+// archives are copied into an unlinked temporary file that's released when
+// the returned FS is garbage collected.
+//
+// If the [WithMaxTotalSize] Option is used, reading is stopped with an error
+// wrapping [ErrLimit] once the stream is twice that size.
+func NewFromStream(r io.Reader, opts ...Option) (*FS, error) {
+	var cfg config
+	for _, o := range opts {
+		o(&cfg)
+	}
+	ra, err := spool(r, &cfg)
+	if err != nil {
+		return nil, err
+	}
+	sys, err := New(ra, opts...)
+	if err != nil {
+		if c, ok := ra.(io.Closer); ok {
+			c.Close()
+		}
+		return nil, err
+	}
+	return sys, nil
+}
+
+// NewCompressed creates an FS from the possibly-compressed tar read from "r",
+// configured according to the provided Options.
+//
+// The compression scheme is detected from the first bytes of the stream; gzip
+// and zstd are the common cases for layers, but anything supported by the
+// zreader package is accepted. Streams with no recognized header are treated
+// as uncompressed tars. The decompressed archive is buffered the same way as
+// in [NewFromStream], and is subject to the same checks as [New].
+func NewCompressed(r io.Reader, opts ...Option) (*FS, error) {
+	zr, _, err := zreader.Detect(r)
+	switch {
+	case err == nil:
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		// Too short to have a compression header. Let the tar reading report
+		// any problem.
+	default:
+		return nil, fmt.Errorf("tarfs: unable to detect compression: %w", err)
+	}
+	defer zr.Close()
+	return NewFromStream(zr, opts...)
+}
+
+// Spool reads all of "r" into memory or a temporary file, according to the
+// limits in "cfg".
+func spool(r io.Reader, cfg *config) (io.ReaderAt, error) {
+	memLimit := int64(DefaultMemoryLimit)
+	if cfg.memLimitSet {
+		memLimit = cfg.memLimit
+	}
+	// The archive is bigger than the contents, but twice the contents is
+	// enough to bound the resources used.
+	var lim int64 = -1
+	if cfg.maxTotalSize > 0 {
+		lim = cfg.maxTotalSize * 2
+	}
+	if lim >= 0 {
+		r = io.LimitReader(r, lim+1)
+	}
+	checkLim := func(n int64) error {
+		if lim >= 0 && n > lim {
+			return fmt.Errorf("tarfs: archive exceeds %d bytes: %w", lim, ErrLimit)
+		}
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if memLimit > 0 {
+		n, err := io.Copy(&buf, io.LimitReader(r, memLimit+1))
+		if err != nil {
+			return nil, err
+		}
+		if n <= memLimit {
+			// Fits in memory.
+			if err := checkLim(n); err != nil {
+				return nil, err
+			}
+			return bytes.NewReader(buf.Bytes()), nil
+		}
+	}
+
+	f, err := os.CreateTemp("", "tarfs.")
+	if err != nil {
+		return nil, err
+	}
+	// Unlink the file immediately; the open descriptor keeps the contents
+	// around as long as the FS does.
+	if err := os.Remove(f.Name()); err != nil {
+		f.Close()
+		return nil, err
+	}
+	n, err := io.Copy(f, io.MultiReader(&buf, r))
+	if err == nil {
+		err = checkLim(n)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
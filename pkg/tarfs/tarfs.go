@@ -19,6 +19,23 @@ type FS struct {
 	r      io.ReaderAt
 	lookup map[string]int
 	inode  []inode
+	// Root is the path of this FS's root, relative to the root of the
+	// archive. It's only populated for FSes returned by Sub.
+	root string
+	// MaxLinks is the number of symlinks that will be followed while
+	// resolving a single name.
+	maxLinks int
+	// ArchiveSize is the size of the archive, up to the end of the last
+	// member.
+	archiveSize int64
+	// Glob caches the results of calls to Glob.
+	glob *globCache
+	// Cache is the content cache, if configured with WithContentCache.
+	cache *contentCache
+	// Entries caches the result of Entries.
+	entries *entryCache
+	// Fixups records the changes made to members while indexing.
+	fixups []Fixup
 }
 
 // Inode is a fake inode(7)-like structure for keeping track of filesystem
@@ -27,6 +44,16 @@ type inode struct {
 	h        *tar.Header
 	children map[int]struct{}
 	off, sz  int64
+	// Wh is populated if this entry is a whiteout.
+	wh *Whiteout
+	// Digest is the SHA-256 of the contents of a regular file, if requested
+	// with WithDigests.
+	digest []byte
+	// Stat is populated by New, once every entry has been seen.
+	stat StatInfo
+	// Sparse is non-nil if this entry is a sparse file, and holds the
+	// regions of the contents actually stored in the archive.
+	sparse []extent
 }
 
 // NormPath removes relative elements and enforces that the resulting string is
@@ -34,7 +61,23 @@ type inode struct {
 //
 // This is needed any time a name is pulled from the archive.
 func normPath(p string) string {
-	s, _ := filepath.Rel("/", filepath.Join("/", p))
+	// This is equivalent to:
+	//
+	//	filepath.Rel("/", filepath.Join("/", p))
+	//
+	// but doesn't allocate for names that are already clean, which is the
+	// common case.
+	s := path.Clean(p)
+	if s == ".." || strings.HasPrefix(s, "../") {
+		// Clamp to the root.
+		s = path.Clean("/" + s)
+	}
+	if strings.HasPrefix(s, "/") {
+		s = s[1:]
+		if s == "" {
+			s = "."
+		}
+	}
 	if utf8.ValidString(s) {
 		return s
 	}
@@ -80,42 +123,133 @@ func newDir(n string) inode {
 	}
 }
 
-// New creates an FS from the tar contained in the ReaderAt.
+// New creates an FS from the tar contained in the ReaderAt, configured
+// according to the provided Options.
 //
 // The ReaderAt must remain valid for the entire life of the returned FS and any
 // FSes returned by Sub.
-func New(r io.ReaderAt) (*FS, error) {
+func New(r io.ReaderAt, opts ...Option) (*FS, error) {
 	var err error
+	cfg := config{
+		maxSymlinkDepth: DefaultMaxSymlinkDepth,
+	}
+	for _, o := range opts {
+		o(&cfg)
+	}
 	s := FS{
-		r:      r,
-		lookup: make(map[string]int),
+		r:        r,
+		lookup:   make(map[string]int),
+		maxLinks: cfg.maxSymlinkDepth,
+		glob:     new(globCache),
+		entries:  new(entryCache),
+	}
+	if cfg.contentCache > 0 {
+		s.cache = newContentCache(cfg.contentCache)
 	}
 	hardlink := make(map[string][]string)
+	// Members is the set of names seen as members, as opposed to directories
+	// created implicitly, for detecting duplicates.
+	members := make(map[string]struct{})
+	fixup := func(i *inode, orig string, k FixupKind) {
+		s.fixups = append(s.fixups, Fixup{Name: orig, Path: i.h.Name, Offset: i.off, Kind: k})
+	}
 	if err := s.add(".", newDir("."), hardlink); err != nil {
 		return nil, err
 	}
 
-	segs, err := findSegments(r)
+	segs, err := findSegments(r, cfg.ignoreZeros)
 	if err != nil {
 		return nil, fmt.Errorf("tarfs: error finding segments: %w", err)
 	}
+	if cfg.maxEntries > 0 && len(segs) > cfg.maxEntries {
+		return nil, fmt.Errorf("tarfs: archive has %d entries (max %d): %w", len(segs), cfg.maxEntries, ErrLimit)
+	}
+	var total int64
+	if len(segs) != 0 {
+		last := segs[len(segs)-1]
+		s.archiveSize = last.start + last.size
+	}
+	hr := headerReader{r: r}
 	for _, seg := range segs {
-		r := io.NewSectionReader(r, seg.start, seg.size)
-		rd := tar.NewReader(r)
 		i := inode{
 			off: seg.start,
 			sz:  seg.size,
 		}
-		i.h, err = rd.Next()
+		i.h, err = hr.Next(seg)
 		if err != nil {
 			return nil, fmt.Errorf("tarfs: error reading header @%d(%d): %w", seg.start, seg.size, err)
 		}
+		i.sparse, err = readSparse(r, seg, i.h)
+		if err != nil {
+			return nil, fmt.Errorf("tarfs: error reading sparse map @%d(%d): %w", seg.start, seg.size, err)
+		}
+		if i.h.Typeflag == tar.TypeGNUSparse {
+			// The sparse map has been read, so this is a regular file as far
+			// as everything else is concerned.
+			i.h.Typeflag = tar.TypeReg
+		}
+		total += i.h.Size
+		if cfg.maxTotalSize > 0 && total > cfg.maxTotalSize {
+			return nil, fmt.Errorf("tarfs: archive contents exceed %d bytes: %w", cfg.maxTotalSize, ErrLimit)
+		}
+		if err := checkName(i.h); err != nil {
+			return nil, err
+		}
+		if cfg.strictPaths {
+			if err := checkTraversal(i.h); err != nil {
+				return nil, err
+			}
+		}
+		if cfg.digests && isRegular(i.h) {
+			i.digest, err = hr.Digest()
+			if err != nil {
+				return nil, fmt.Errorf("tarfs: error hashing %q: %w", i.h.Name, err)
+			}
+		}
+		orig := i.h.Name
 		i.h.Name = normPath(i.h.Name)
 		n := i.h.Name
+		if sanitized(orig) {
+			fixup(&i, orig, FixupRenamed)
+		}
+		var skip bool
+		i.wh, skip = parseWhiteout(cfg.whiteout, i.h)
+		if skip {
+			continue
+		}
+		if cfg.maxFileSize > 0 && isRegular(i.h) && i.h.Size > cfg.maxFileSize {
+			fixup(&i, orig, FixupTooLarge)
+			continue
+		}
+		_, dup := members[n]
+		members[n] = struct{}{}
+		if dup {
+			switch cfg.duplicates {
+			case DuplicateError:
+				return nil, &fs.PathError{
+					Op:   `create`,
+					Path: orig,
+					Err:  fmt.Errorf("duplicate member: %w", fs.ErrExist),
+				}
+			case DuplicateFirstWins:
+				fixup(&i, orig, FixupSkipped)
+				continue
+			}
+		}
 		switch i.h.Typeflag {
+		case typeGNUDumpDir:
+			// GNU incremental archives describe directories with these
+			// entries. The contents are a listing of the directory at archive
+			// time, which isn't needed, so treat these as normal directories.
+			i.h.Typeflag = tar.TypeDir
+			i.h.Size = 0
+			fallthrough
 		case tar.TypeDir:
 			// Has this been created this already?
 			if _, ok := s.lookup[n]; ok {
+				if dup {
+					fixup(&i, orig, FixupSkipped)
+				}
 				continue
 			}
 			i.children = make(map[int]struct{})
@@ -138,6 +272,9 @@ func New(r io.ReaderAt) (*FS, error) {
 		if err := s.add(n, i, hardlink); err != nil {
 			return nil, err
 		}
+		if dup {
+			fixup(&i, orig, FixupReplaced)
+		}
 	}
 	// Cleanup any dangling hardlinks.
 	// This leaves them in the inode slice, but removes them from the observable
@@ -150,9 +287,90 @@ func New(r io.ReaderAt) (*FS, error) {
 			delete(p.children, idx)
 		}
 	}
+	// Now that every entry has been seen, point hardlinks at their targets'
+	// contents. Doing this after the fact handles hardlinks that appear
+	// before their targets.
+	for idx := range s.inode {
+		if err := s.resolveHardlink(idx); err != nil {
+			return nil, err
+		}
+	}
+	s.countLinks()
 	return &s, nil
 }
 
+// ResolveHardlink makes the inode at "idx", if it's a hardlink, refer to the
+// same contents as the (regular file) target. Hardlinks to hardlinks are
+// followed.
+func (f *FS) resolveHardlink(idx int) error {
+	i := &f.inode[idx]
+	if i.h.Typeflag != tar.TypeLink {
+		return nil
+	}
+	seen := map[int]struct{}{idx: {}}
+	tgt, tgtIdx := i, idx
+	for tgt.h.Typeflag == tar.TypeLink {
+		ti, ok := f.lookup[tgt.h.Linkname]
+		if !ok {
+			// Dangling; removed from the tree already.
+			return nil
+		}
+		if _, ok := seen[ti]; ok {
+			return &fs.PathError{
+				Op:   `create`,
+				Path: i.h.Name,
+				Err:  fmt.Errorf("found cycle when resolving hardlink: %w", fs.ErrInvalid),
+			}
+		}
+		seen[ti] = struct{}{}
+		tgt, tgtIdx = &f.inode[ti], ti
+	}
+	if !tgt.h.FileInfo().Mode().IsRegular() {
+		// Hardlinks to anything else are passed through as-is.
+		return nil
+	}
+	i.off, i.sz = tgt.off, tgt.sz
+	i.digest = tgt.digest
+	i.sparse = tgt.sparse
+	i.h.Size = tgt.h.Size
+	i.stat.Ino = uint64(tgtIdx) + 1
+	return nil
+}
+
+// CountLinks populates the StatInfo of every inode. It must be called after
+// hardlinks are resolved, so that they share their targets' inode numbers.
+func (f *FS) countLinks() {
+	for idx := range f.inode {
+		i := &f.inode[idx]
+		if i.stat.Ino == 0 {
+			i.stat.Ino = uint64(idx) + 1
+		}
+		i.stat.Nlink = 1
+	}
+	// Only count names that are in the tree, as dangling hardlinks are left
+	// in the inode slice.
+	files := make(map[uint64]uint64)
+	for _, idx := range f.lookup {
+		if i := &f.inode[idx]; isRegular(i.h) {
+			files[i.stat.Ino]++
+		}
+	}
+	for _, idx := range f.lookup {
+		i := &f.inode[idx]
+		switch {
+		case isRegular(i.h):
+			i.stat.Nlink = files[i.stat.Ino]
+		case i.h.Typeflag == tar.TypeDir:
+			i.stat.Nlink = 2
+			for ci := range i.children {
+				if f.inode[ci].h.Typeflag == tar.TypeDir {
+					i.stat.Nlink++
+				}
+			}
+		}
+	}
+}
+
 // Add does what it says on the tin.
 //
 // In addition, it creates any needed leading directory elements. The caller
@@ -164,6 +382,7 @@ func New(r io.ReaderAt) (*FS, error) {
 // The "hardlink" map is used for deferring hardlink creation.
 func (f *FS) add(name string, ino inode, hardlink map[string][]string) error {
 	const op = `create`
+	var followed map[string]struct{}
 Again:
 	if i, ok := f.lookup[name]; ok {
 		n := &f.inode[i]
@@ -185,6 +404,17 @@ Again:
 			}
 		case et&fs.ModeSymlink != 0:
 			// Follow the link target.
+			if followed == nil {
+				followed = make(map[string]struct{})
+			}
+			if _, ok := followed[name]; ok {
+				return &fs.PathError{
+					Op:   op,
+					Path: name,
+					Err:  fmt.Errorf("found cycle when resolving member: %w", fs.ErrInvalid),
+				}
+			}
+			followed[name] = struct{}{}
 			name = n.h.Linkname
 			goto Again
 		}
@@ -307,6 +537,13 @@ func (f *FS) walkTo(p string, create bool) (*inode, error) {
 			cycle := make(map[int]struct{})
 		Resolve:
 			for {
+				if f.maxLinks > 0 && len(cycle) > f.maxLinks {
+					return nil, &fs.PathError{
+						Op:   `walk`,
+						Path: b.String(),
+						Err:  fmt.Errorf("too many levels of symbolic links: %w", ErrLimit),
+					}
+				}
 				if _, ok := cycle[ci]; ok {
 					return nil, &fs.PathError{
 						Op:   `walk`,
@@ -319,9 +556,10 @@ func (f *FS) walkTo(p string, create bool) (*inode, error) {
 				case tar.TypeDir:
 					break Resolve
 				case tar.TypeSymlink:
-					tgt := child.h.Linkname
-					var ok bool
-					ci, ok = f.lookup[tgt]
+					tgt, ok := f.rel(child.h.Linkname)
+					if ok {
+						ci, ok = f.lookup[tgt]
+					}
 					switch {
 					case ok && create, ok && !create:
 						child = &f.inode[ci]
@@ -363,39 +601,80 @@ func (f *FS) walkTo(p string, create bool) (*inode, error) {
 	return cur, nil
 }
 
+// CheckDepth reports an error if "depth" symlinks is too many to follow.
+func (f *FS) checkDepth(op, name string, depth int) error {
+	if f.maxLinks > 0 && depth > f.maxLinks {
+		return &fs.PathError{
+			Op:   op,
+			Path: name,
+			Err:  fmt.Errorf("too many levels of symbolic links: %w", ErrLimit),
+		}
+	}
+	return nil
+}
+
+// Rel translates a name relative to the root of the archive, like the
+// Linkname member of a header, to a name relative to the root of this FS.
+//
+// The returned bool reports false if the name is outside of this FS, which is
+// only possible for FSes returned by Sub.
+func (f *FS) rel(name string) (string, bool) {
+	switch {
+	case f.root == "" || f.root == ".":
+		return name, true
+	case name == f.root:
+		return ".", true
+	case strings.HasPrefix(name, f.root+"/"):
+		return name[len(f.root)+1:], true
+	}
+	return "", false
+}
+
 // Open implements fs.FS.
+//
+// Regular files returned by Open implement [io.Seeker].
 func (f *FS) Open(name string) (fs.File, error) {
+	return f.open(name, 0)
+}
+
+// Open is the implementation of Open, tracking the number of symlinks
+// followed.
+func (f *FS) open(name string, depth int) (fs.File, error) {
 	const op = `open`
+	if err := f.checkDepth(op, name, depth); err != nil {
+		return nil, err
+	}
 	i, err := f.getInode(op, name)
 	if err != nil {
 		return nil, err
 	}
 	typ := i.h.FileInfo().Mode().Type()
-	var r *tar.Reader
 	switch {
-	case typ.IsRegular() && i.h.Typeflag != tar.TypeLink:
-		r = tar.NewReader(io.NewSectionReader(f.r, i.off, i.sz))
-	case typ.IsRegular() && i.h.Typeflag == tar.TypeLink:
-		tgt, err := f.getInode(op, i.h.Linkname)
-		if err != nil {
-			return nil, err
-		}
-		r = tar.NewReader(io.NewSectionReader(f.r, tgt.off, tgt.sz))
+	case typ.IsRegular():
 	case typ.IsDir():
 		d := dir{
-			h:  i.h,
-			es: make([]fs.DirEntry, len(i.children)),
+			h:    i.h,
+			stat: i.stat,
+			es:   make([]fs.DirEntry, len(i.children)),
 		}
 		n := 0
 		for i := range i.children {
 			ct := &f.inode[i]
-			d.es[n] = dirent{ct.h}
+			d.es[n] = dirent{ct.h, ct.wh, ct.stat}
 			n++
 		}
 		sort.Slice(d.es, sortDirent(d.es))
 		return &d, nil
 	case typ&fs.ModeSymlink != 0: // typ.IsSymlink()
-		return f.Open(i.h.Linkname)
+		n, ok := f.rel(i.h.Linkname)
+		if !ok {
+			return nil, &fs.PathError{
+				Op:   op,
+				Path: name,
+				Err:  fs.ErrNotExist,
+			}
+		}
+		return f.open(n, depth+1)
 	default:
 		// Pretend all other kinds of files don't exist.
 		return nil, &fs.PathError{
@@ -404,7 +683,9 @@ func (f *FS) Open(name string) (fs.File, error) {
 			Err:  fs.ErrExist,
 		}
 	}
-	if _, err := r.Next(); err != nil {
+	// Hardlinks have had their offsets resolved by New.
+	r, err := f.contents(i)
+	if err != nil {
 		return nil, &fs.PathError{
 			Op:   op,
 			Path: name,
@@ -412,8 +693,10 @@ func (f *FS) Open(name string) (fs.File, error) {
 		}
 	}
 	return &file{
-		h: i.h,
-		r: r,
+		h:    i.h,
+		wh:   i.wh,
+		stat: i.stat,
+		r:    r,
 	}, nil
 }
 
@@ -426,7 +709,7 @@ func (f *FS) Stat(name string) (fs.FileInfo, error) {
 	if err != nil {
 		return nil, err
 	}
-	return i.h.FileInfo(), nil
+	return fileInfo(i.h, i.wh, i.stat), nil
 }
 
 // ReadDir implements fs.ReadDirFS.
@@ -441,7 +724,7 @@ func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
 	ret := make([]fs.DirEntry, 0, len(i.children))
 	for ti := range i.children {
 		t := &f.inode[ti]
-		ret = append(ret, dirent{t.h})
+		ret = append(ret, dirent{t.h, t.wh, t.stat})
 	}
 	sort.Slice(ret, sortDirent(ret))
 	return ret, nil
@@ -452,16 +735,33 @@ func (f *FS) ReadFile(name string) ([]byte, error) {
 	// ReadFileFS is implemented because it can avoid allocating an intermediate
 	// "file" struct and can immediately allocate a byte slice of the correct
 	// size.
+	return f.readFile(name, 0)
+}
+
+// ReadFile is the implementation of ReadFile, tracking the number of symlinks
+// followed.
+func (f *FS) readFile(name string, depth int) ([]byte, error) {
 	const op = `readfile`
+	if err := f.checkDepth(op, name, depth); err != nil {
+		return nil, err
+	}
 	i, err := f.getInode(op, name)
 	if err != nil {
 		return nil, err
 	}
 	if i.h.FileInfo().Mode().Type()&fs.ModeSymlink != 0 {
-		return f.ReadFile(i.h.Linkname)
+		n, ok := f.rel(i.h.Linkname)
+		if !ok {
+			return nil, &fs.PathError{
+				Op:   op,
+				Path: name,
+				Err:  fs.ErrNotExist,
+			}
+		}
+		return f.readFile(n, depth+1)
 	}
-	r := tar.NewReader(io.NewSectionReader(f.r, i.off, i.sz))
-	if _, err := r.Next(); err != nil {
+	r, err := f.contents(i)
+	if err != nil {
 		return nil, &fs.PathError{
 			Op:   op,
 			Path: name,
@@ -479,25 +779,46 @@ func (f *FS) ReadFile(name string) ([]byte, error) {
 	return ret, nil
 }
 
-// Glob implements fs.GlobFS.
+// FSStat describes an FS as a whole, in the spirit of statfs(2).
+type FSStat struct {
+	// Blocks is the number of entries in the FS, not counting the root
+	// directory. This includes directories implied by member names.
+	Blocks int64
+	// Bsize is the block size of the archive, which is always 512.
+	Bsize int64
+	// Size is the total size of the contents of all regular files in the FS.
+	// Contents shared by hardlinks are only counted once.
+	Size int64
+	// ArchiveSize is the size of the backing archive up to the end of the
+	// last member. For an FS returned by Sub, this is the size of the
+	// containing archive.
+	ArchiveSize int64
+}
+
+// StatFS reports information about the FS as a whole.
 //
-// See path.Match for the patten syntax.
-func (f *FS) Glob(pat string) ([]string, error) {
-	// GlobFS is implemented because it can avoid allocating for the walk.
-	//
-	// Path.Match is documented as only returning an error when the pattern is
-	// invalid, so check it here and we can avoid the check in the loop.
-	if _, err := path.Match(pat, ""); err != nil {
-		return nil, err
+// This is not [fs.StatFS], which FS also implements via the Stat method.
+func (f *FS) StatFS() FSStat {
+	ret := FSStat{
+		Bsize:       512,
+		ArchiveSize: f.archiveSize,
 	}
-	var ret []string
-	for n := range f.lookup {
-		if ok, _ := path.Match(pat, n); ok {
-			ret = append(ret, n)
+	seen := make(map[int64]struct{})
+	for n, i := range f.lookup {
+		if n != "." {
+			ret.Blocks++
+		}
+		ino := &f.inode[i]
+		if !ino.h.FileInfo().Mode().IsRegular() {
+			continue
 		}
+		if _, ok := seen[ino.off]; ok {
+			continue
+		}
+		seen[ino.off] = struct{}{}
+		ret.Size += ino.h.Size
 	}
-	sort.Strings(ret)
-	return ret, nil
+	return ret
 }
 
 // Sub implements fs.SubFS.
@@ -510,11 +831,28 @@ func (f *FS) Sub(dir string) (fs.FS, error) {
 	if err != nil {
 		return nil, err
 	}
-	bp := n.h.Name
+	// Find the name of the inode in this FS's lookup table. Using the name
+	// in the header would be incorrect if this FS is itself the result of a
+	// call to Sub.
+	bp := path.Clean(dir)
+	for name, i := range f.lookup {
+		if &f.inode[i] == n {
+			bp = name
+			break
+		}
+	}
 	ret := FS{
-		r:      f.r,
-		inode:  f.inode,
-		lookup: make(map[string]int),
+		r:        f.r,
+		inode:    f.inode,
+		lookup:   make(map[string]int),
+		root:     path.Join(f.root, bp),
+		maxLinks: f.maxLinks,
+		glob:     new(globCache),
+		cache:    f.cache,
+		entries:  new(entryCache),
+		fixups:   f.fixups,
+
+		archiveSize: f.archiveSize,
 	}
 	for n, i := range f.lookup {
 		rel, err := filepath.Rel(bp, n)
@@ -522,8 +860,9 @@ func (f *FS) Sub(dir string) (fs.FS, error) {
 			// Can't be made relative.
 			continue
 		}
-		if strings.HasPrefix(rel, "..") {
-			// Not in this subtree.
+		if rel == ".." || strings.HasPrefix(rel, "../") {
+			// Not in this subtree. Note that a name like "..a" is a valid
+			// member of the subtree.
 			continue
 		}
 		ret.lookup[rel] = i
@@ -5,15 +5,24 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"errors"
+	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
 	"testing/fstest"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
 
 	"github.com/quay/claircore/test/integration"
 )
@@ -320,6 +329,11 @@ func TestSymlinks(t *testing.T) {
 		},
 		{Name: `b/c`},
 	}, nil))
+	t.Run("ReplaceCycle", run(true, []tar.Header{
+		{Typeflag: tar.TypeSymlink, Name: `b`, Linkname: `a`},
+		{Typeflag: tar.TypeSymlink, Name: `a`, Linkname: `b`},
+		{Name: `b`},
+	}, nil))
 	t.Run("ReplaceSymlink", run(false, []tar.Header{
 		{Name: `a`},
 		{
@@ -618,3 +632,2058 @@ func TestInvalidName(t *testing.T) {
 		}
 	}
 }
+
+// MkFS builds a tar with the provided headers in memory and returns an FS
+// over it. Any regular file entries are given their Name as contents.
+func mkFS(t *testing.T, hs []tar.Header) *FS {
+	t.Helper()
+	sys, err := New(bytes.NewReader(mkTar(t, hs)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sys
+}
+
+// MkTar builds a tar with the provided headers in memory. Any regular file
+// entries are given their Name as contents.
+func mkTar(t testing.TB, hs []tar.Header) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for i := range hs {
+		h := hs[i]
+		var b []byte
+		if h.Typeflag == tar.TypeReg || (h.Typeflag == 0 && !strings.HasSuffix(h.Name, "/")) {
+			b = []byte(h.Name)
+			h.Size = int64(len(b))
+		}
+		if err := tw.WriteHeader(&h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// TestSub checks the behavior of an FS returned by [fs.Sub] beyond what
+// [fstest.TestFS] exercises.
+func TestSub(t *testing.T) {
+	sys := mkFS(t, []tar.Header{
+		{Name: `a/`, Typeflag: tar.TypeDir},
+		{Name: `a/b/`, Typeflag: tar.TypeDir},
+		{Name: `a/b/c/`, Typeflag: tar.TypeDir},
+		{Name: `a/b/c/file`},
+		{Name: `a/sibling`},
+		{Name: `outside`},
+	})
+	sub, err := fs.Sub(sys, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(sub, "b/c/file", "sibling"); err != nil {
+		t.Error(err)
+	}
+
+	t.Run("StatRoot", func(t *testing.T) {
+		fi, err := fs.Stat(sub, ".")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !fi.IsDir() {
+			t.Errorf("sub-root is not a directory: %v", fi.Mode())
+		}
+	})
+	t.Run("ReadDirRoot", func(t *testing.T) {
+		ents, err := fs.ReadDir(sub, ".")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, e := range ents {
+			got = append(got, e.Name())
+		}
+		if got, want := strings.Join(got, ","), "b,sibling"; got != want {
+			t.Errorf("got: %q, want: %q", got, want)
+		}
+	})
+	t.Run("OpenDeep", func(t *testing.T) {
+		b, err := fs.ReadFile(sub, "b/c/file")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(b), "a/b/c/file"; got != want {
+			t.Errorf("got: %q, want: %q", got, want)
+		}
+	})
+	t.Run("OpenDir", func(t *testing.T) {
+		f, err := sub.Open("b")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		d, ok := f.(fs.ReadDirFile)
+		if !ok {
+			t.Fatalf("directory handle %T is not an fs.ReadDirFile", f)
+		}
+		ents, err := d.ReadDir(-1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ents) != 1 || ents[0].Name() != "c" {
+			t.Errorf("unexpected entries: %v", ents)
+		}
+	})
+	t.Run("OpenOutside", func(t *testing.T) {
+		for _, n := range []string{"../outside", "..", "/outside", "b/../../outside"} {
+			_, err := sub.Open(n)
+			if !errors.Is(err, fs.ErrInvalid) {
+				t.Errorf("%q: unexpected err return: %v", n, err)
+			}
+		}
+		_, err := sub.Open("outside")
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%q: unexpected err return: %v", "outside", err)
+		}
+	})
+}
+
+func TestDirEntryHeader(t *testing.T) {
+	sys := mkFS(t, []tar.Header{
+		{Name: `a/`, Typeflag: tar.TypeDir, Uid: 1000},
+		{Name: `a/file`, Uid: 1001, Xattrs: map[string]string{"user.test": "ok"}},
+	})
+	ents, err := fs.ReadDir(sys, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ents) != 1 {
+		t.Fatalf("unexpected entries: %v", ents)
+	}
+	te, ok := ents[0].(TarDirEntry)
+	if !ok {
+		t.Fatalf("%T is not a TarDirEntry", ents[0])
+	}
+	h := te.TarHeader()
+	if got, want := h.Name, "a/file"; got != want {
+		t.Errorf("name: got: %q, want: %q", got, want)
+	}
+	if got, want := h.Uid, 1001; got != want {
+		t.Errorf("uid: got: %d, want: %d", got, want)
+	}
+	if got, want := h.PAXRecords["SCHILY.xattr.user.test"], "ok"; got != want {
+		t.Errorf("xattr: got: %q, want: %q", got, want)
+	}
+	// Modifying the returned header should not modify the FS.
+	h.Uid = 0
+	h.PAXRecords["SCHILY.xattr.user.test"] = "modified"
+	h.Xattrs["user.test"] = "modified"
+	h = te.TarHeader()
+	if got, want := h.Uid, 1001; got != want {
+		t.Errorf("uid: got: %d, want: %d", got, want)
+	}
+	if got, want := h.PAXRecords["SCHILY.xattr.user.test"], "ok"; got != want {
+		t.Errorf("xattr: got: %q, want: %q", got, want)
+	}
+	if got, want := h.Xattrs["user.test"], "ok"; got != want {
+		t.Errorf("xattr: got: %q, want: %q", got, want)
+	}
+	fi, err := fs.Stat(sys, "a/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fh := fi.(*FileInfo).TarHeader()
+	fh.PAXRecords["SCHILY.xattr.user.test"] = "modified"
+	if got, want := fi.(*FileInfo).TarHeader().PAXRecords["SCHILY.xattr.user.test"], "ok"; got != want {
+		t.Errorf("xattr: got: %q, want: %q", got, want)
+	}
+}
+
+func TestXattrs(t *testing.T) {
+	caps := "\x01\x00\x00\x02\x00\x04\x00\x00"
+	sys := mkFS(t, []tar.Header{
+		{Name: `plain`},
+		{Name: `schily`, PAXRecords: map[string]string{
+			"SCHILY.xattr.security.selinux":    "system_u:object_r:bin_t:s0",
+			"SCHILY.xattr.security.capability": caps,
+			"comment":                          "not an xattr",
+		}},
+		{Name: `bsd`, PAXRecords: map[string]string{
+			// Base64, without padding.
+			"LIBARCHIVE.xattr.user.a%3Db": "dmFsdWU",
+			"LIBARCHIVE.xattr.user.bad":   "!!!",
+			"LIBARCHIVE.xattr.user.both":  "bGliYXJjaGl2ZQ",
+			"SCHILY.xattr.user.both":      "schily",
+		}},
+		{Name: `link`, Typeflag: tar.TypeSymlink, Linkname: `schily`},
+	})
+	tcs := []struct {
+		Name string
+		Want map[string]string
+	}{
+		{Name: `plain`},
+		{Name: `schily`, Want: map[string]string{
+			"security.selinux":    "system_u:object_r:bin_t:s0",
+			"security.capability": caps,
+		}},
+		{Name: `bsd`, Want: map[string]string{
+			"user.a=b":  "value",
+			"user.both": "schily",
+		}},
+		{Name: `link`, Want: map[string]string{
+			"security.selinux":    "system_u:object_r:bin_t:s0",
+			"security.capability": caps,
+		}},
+	}
+	for _, tc := range tcs {
+		got, err := sys.Xattrs(tc.Name)
+		if err != nil {
+			t.Errorf("%s: %v", tc.Name, err)
+			continue
+		}
+		if !cmp.Equal(got, tc.Want) {
+			t.Errorf("%s: %s", tc.Name, cmp.Diff(got, tc.Want))
+		}
+	}
+	if _, err := sys.Xattrs(`nope`); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("unexpected error for missing file: %v", err)
+	}
+}
+
+func TestDuplicatePolicy(t *testing.T) {
+	b := mkTar(t, []tar.Header{
+		{Name: `d/`, Typeflag: tar.TypeDir, Uid: 1},
+		{Name: `d/a`},
+		{Name: `./d/a`},
+		{Name: `/d/a`},
+		{Name: `d/`, Typeflag: tar.TypeDir, Uid: 2},
+		{Name: `x/../b`},
+	})
+	type fixup struct {
+		Name string
+		Kind FixupKind
+	}
+	summarize := func(fs []Fixup) []fixup {
+		var ret []fixup
+		for _, f := range fs {
+			ret = append(ret, fixup{f.Name, f.Kind})
+		}
+		return ret
+	}
+	tcs := []struct {
+		Name   string
+		Policy DuplicatePolicy
+		Want   string
+		Fixups []fixup
+	}{
+		{
+			Name:   "LastWins",
+			Policy: DuplicateLastWins,
+			Want:   `/d/a`,
+			Fixups: []fixup{
+				{`./d/a`, FixupReplaced},
+				{`/d/a`, FixupRenamed},
+				{`/d/a`, FixupReplaced},
+				{`d/`, FixupSkipped},
+				{`x/../b`, FixupRenamed},
+			},
+		},
+		{
+			Name:   "FirstWins",
+			Policy: DuplicateFirstWins,
+			Want:   `d/a`,
+			Fixups: []fixup{
+				{`./d/a`, FixupSkipped},
+				{`/d/a`, FixupRenamed},
+				{`/d/a`, FixupSkipped},
+				{`d/`, FixupSkipped},
+				{`x/../b`, FixupRenamed},
+			},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			sys, err := New(bytes.NewReader(b), WithDuplicatePolicy(tc.Policy))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := fs.ReadFile(sys, `d/a`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := string(got), tc.Want; got != want {
+				t.Errorf("got: %q, want: %q", got, want)
+			}
+			fi, err := sys.Stat(`d`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := fi.(*FileInfo).TarHeader().Uid, 1; got != want {
+				t.Errorf("directory uid: got: %d, want: %d", got, want)
+			}
+			if got, want := summarize(sys.Fixups()), tc.Fixups; !cmp.Equal(got, want) {
+				t.Error(cmp.Diff(got, want))
+			}
+			if err := fstest.TestFS(sys, `d/a`, `b`); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	t.Run("Error", func(t *testing.T) {
+		_, err := New(bytes.NewReader(b), WithDuplicatePolicy(DuplicateError))
+		if !errors.Is(err, fs.ErrExist) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		b := mkTar(t, []tar.Header{{Name: `d/a`}, {Name: `d/b`}, {Name: `d/`, Typeflag: tar.TypeDir}})
+		sys, err := New(bytes.NewReader(b), WithDuplicatePolicy(DuplicateError))
+		if err != nil {
+			t.Fatalf("implicit directory reported as duplicate: %v", err)
+		}
+		if fs := sys.Fixups(); len(fs) != 0 {
+			t.Errorf("unexpected fixups: %v", fs)
+		}
+	})
+}
+
+// TestSubEscape checks that links in an FS returned by [fs.Sub] are resolved
+// relative to the archive and cannot be used to reach outside the sub-root.
+func TestSubEscape(t *testing.T) {
+	sys := mkFS(t, []tar.Header{
+		{Name: `etc/`, Typeflag: tar.TypeDir},
+		{Name: `etc/passwd`},
+		{Name: `a/b/etc/`, Typeflag: tar.TypeDir},
+		{Name: `a/b/etc/passwd`},
+		{Name: `a/b/..passwd`},
+		{
+			Typeflag: tar.TypeSymlink,
+			Name:     `a/b/escape`,
+			Linkname: `../../etc`,
+		},
+		{
+			Typeflag: tar.TypeSymlink,
+			Name:     `a/b/local`,
+			Linkname: `etc`,
+		},
+		{
+			Typeflag: tar.TypeSymlink,
+			Name:     `a/b/abs`,
+			Linkname: `/etc/passwd`,
+		},
+		{
+			Typeflag: tar.TypeSymlink,
+			Name:     `a/b/dots`,
+			Linkname: `../b/../../../../../etc/passwd`,
+		},
+	})
+	sub, err := fs.Sub(sys, "a/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ents, err := fs.ReadDir(sub, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range ents {
+		names = append(names, e.Name())
+	}
+	if got, want := strings.Join(names, ","), "..passwd,abs,dots,escape,etc,local"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+	for _, e := range ents {
+		p := path.Join(".", e.Name())
+		if !fs.ValidPath(p) {
+			t.Errorf("invalid path from ReadDir: %q", p)
+		}
+	}
+
+	for _, n := range []string{"escape/passwd", "abs", "dots"} {
+		_, err := sub.Open(n)
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%q: unexpected err return: %v", n, err)
+		}
+		_, err = fs.ReadFile(sub, n)
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%q: unexpected err return: %v", n, err)
+		}
+	}
+	for n, want := range map[string]string{
+		"local/passwd": "a/b/etc/passwd",
+		"..passwd":     "a/b/..passwd",
+	} {
+		b, err := fs.ReadFile(sub, n)
+		if err != nil {
+			t.Errorf("%q: %v", n, err)
+			continue
+		}
+		if got := string(b); got != want {
+			t.Errorf("%q: got: %q, want: %q", n, got, want)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	hs := []tar.Header{
+		{Name: `a/`, Typeflag: tar.TypeDir},
+		{Name: `a/file`},
+		{Name: `b`},
+		{Typeflag: tar.TypeSymlink, Name: `l1`, Linkname: `l2`},
+		{Typeflag: tar.TypeSymlink, Name: `l2`, Linkname: `l3`},
+		{Typeflag: tar.TypeSymlink, Name: `l3`, Linkname: `b`},
+		{Typeflag: tar.TypeSymlink, Name: `loop1`, Linkname: `loop2`},
+		{Typeflag: tar.TypeSymlink, Name: `loop2`, Linkname: `loop1`},
+	}
+	b := mkTar(t, hs)
+
+	t.Run("Entries", func(t *testing.T) {
+		if _, err := New(bytes.NewReader(b), WithMaxEntries(len(hs))); err != nil {
+			t.Error(err)
+		}
+		_, err := New(bytes.NewReader(b), WithMaxEntries(len(hs)-1))
+		if !errors.Is(err, ErrLimit) {
+			t.Errorf("unexpected err return: %v", err)
+		}
+	})
+	t.Run("TotalSize", func(t *testing.T) {
+		sz := int64(len("a/file") + len("b"))
+		if _, err := New(bytes.NewReader(b), WithMaxTotalSize(sz)); err != nil {
+			t.Error(err)
+		}
+		_, err := New(bytes.NewReader(b), WithMaxTotalSize(sz-1))
+		if !errors.Is(err, ErrLimit) {
+			t.Errorf("unexpected err return: %v", err)
+		}
+	})
+	t.Run("FileSize", func(t *testing.T) {
+		b := mkTar(t, []tar.Header{
+			{Name: `bigger`},
+			{Name: `small`},
+			{Typeflag: tar.TypeLink, Name: `hardlink`, Linkname: `bigger`},
+			{Typeflag: tar.TypeSymlink, Name: `symlink`, Linkname: `bigger`},
+		})
+		sys, err := New(bytes.NewReader(b), WithMaxFileSize(int64(len("small"))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fs.Stat(sys, "small"); err != nil {
+			t.Error(err)
+		}
+		for _, n := range []string{"bigger", "hardlink", "symlink"} {
+			if _, err := fs.ReadFile(sys, n); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("%s: unexpected err return: %v", n, err)
+			}
+		}
+		fx := sys.Fixups()
+		if len(fx) != 1 || fx[0].Path != "bigger" || fx[0].Kind != FixupTooLarge {
+			t.Errorf("unexpected fixups: %+v", fx)
+		}
+	})
+	t.Run("SymlinkDepth", func(t *testing.T) {
+		sys, err := New(bytes.NewReader(b), WithMaxSymlinkDepth(3))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fs.ReadFile(sys, "l1"); err != nil {
+			t.Error(err)
+		}
+		sys, err = New(bytes.NewReader(b), WithMaxSymlinkDepth(2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fs.ReadFile(sys, "l1"); !errors.Is(err, ErrLimit) {
+			t.Errorf("unexpected err return: %v", err)
+		}
+		if _, err := sys.Open("l1"); !errors.Is(err, ErrLimit) {
+			t.Errorf("unexpected err return: %v", err)
+		}
+	})
+	t.Run("Loop", func(t *testing.T) {
+		sys, err := New(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := sys.Open("loop1"); !errors.Is(err, ErrLimit) {
+			t.Errorf("unexpected err return: %v", err)
+		}
+	})
+}
+
+func TestStrictPaths(t *testing.T) {
+	tcs := []struct {
+		Name string
+		Bad  bool
+		tar.Header
+	}{
+		{Name: "OK", Header: tar.Header{Name: `a/b`}},
+		{Name: "Dotdot", Bad: true, Header: tar.Header{Name: `a/../b`}},
+		{Name: "Absolute", Bad: true, Header: tar.Header{Name: `/etc/passwd`}},
+		{Name: "AbsSymlink", Header: tar.Header{Typeflag: tar.TypeSymlink, Name: `a/l`, Linkname: `/etc/passwd`}},
+		{Name: "RelSymlink", Header: tar.Header{Typeflag: tar.TypeSymlink, Name: `a/l`, Linkname: `../etc/passwd`}},
+		{Name: "EscapingSymlink", Bad: true, Header: tar.Header{Typeflag: tar.TypeSymlink, Name: `a/l`, Linkname: `../../etc/passwd`}},
+		{Name: "EscapingHardlink", Bad: true, Header: tar.Header{Typeflag: tar.TypeLink, Name: `a/l`, Linkname: `../etc/passwd`}},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			b := mkTar(t, []tar.Header{tc.Header})
+			if _, err := New(bytes.NewReader(b)); err != nil {
+				t.Errorf("default: unexpected error: %v", err)
+			}
+			_, err := New(bytes.NewReader(b), WithStrictPaths())
+			t.Log(err)
+			if (err != nil) != tc.Bad {
+				t.Errorf("strict: unexpected err return: %v", err)
+			}
+			if tc.Bad && (!errors.Is(err, fs.ErrInvalid) || !errors.Is(err, ErrMaliciousPath)) {
+				t.Errorf("strict: unexpected err return: %v", err)
+			}
+		})
+	}
+}
+
+func TestIgnoreZeros(t *testing.T) {
+	first := mkTar(t, []tar.Header{
+		{Name: `a/`, Typeflag: tar.TypeDir},
+		{Name: `a/file`},
+	})
+	second := mkTar(t, []tar.Header{
+		{Name: `a/other`},
+		{Name: `b`},
+	})
+	// Some tools pad archives out to a multiple of 10KiB.
+	pad := make([]byte, 4*512)
+	b := bytes.Join([][]byte{first, pad, second, pad}, nil)
+
+	sys, err := New(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Stat(sys, `b`); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("second archive read without WithIgnoreZeros: %v", err)
+	}
+
+	sys, err = New(bytes.NewReader(b), WithIgnoreZeros())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(sys, `a/file`, `a/other`, `b`); err != nil {
+		t.Error(err)
+	}
+	got, err := fs.ReadFile(sys, `a/other`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `a/other`; string(got) != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	// Summarize returns a description of everything observable about an FS.
+	summarize := func(t *testing.T, sys *FS) []string {
+		t.Helper()
+		var ret []string
+		err := fs.WalkDir(sys, ".", func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			fi, err := sys.Lstat(p)
+			if err != nil {
+				return err
+			}
+			s := fmt.Sprintf("%s %v %d %+v", p, fi.Mode(), fi.Size(), *fi.(*FileInfo).TarHeader())
+			switch v := fi.Sys().(type) {
+			case *Whiteout:
+				s += fmt.Sprintf(" whiteout(%s, %v)", v.Target, v.Opaque)
+			case *StatInfo:
+				s += fmt.Sprintf(" %+v", *v)
+			}
+			switch {
+			case fi.Mode().IsRegular():
+				b, err := fs.ReadFile(sys, p)
+				if err != nil {
+					return err
+				}
+				d, _ := sys.FileDigest(p)
+				s += fmt.Sprintf(" %q %x", b, d)
+			case fi.Mode()&fs.ModeSymlink != 0:
+				tgt, err := sys.ReadLink(p)
+				if err != nil {
+					return err
+				}
+				s += " -> " + tgt
+			}
+			ret = append(ret, s)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, wh := range sys.Whiteouts() {
+			ret = append(ret, fmt.Sprintf("whiteout %s %v", wh.Target, wh.Opaque))
+		}
+		for _, f := range sys.Fixups() {
+			ret = append(ret, fmt.Sprintf("fixup %+v", f))
+		}
+		return ret
+	}
+	b := mkTar(t, []tar.Header{
+		{Name: `a/`, Typeflag: tar.TypeDir, Mode: 0o755},
+		{Name: `a/file`, Uid: 1000},
+		{Name: `/a/file`},
+		{Name: `a/.wh.gone`},
+		{Name: `empty/`, Typeflag: tar.TypeDir},
+		{Name: `hard`, Typeflag: tar.TypeLink, Linkname: `a/file`},
+		{Name: `link`, Typeflag: tar.TypeSymlink, Linkname: `a/file`},
+		{Name: `implied/dir/file`},
+	})
+	sys, err := New(bytes.NewReader(b), WithDigests(), WithWhiteoutHandling(WhiteoutModeOCI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var idx bytes.Buffer
+	if err := sys.WriteIndex(&idx); err != nil {
+		t.Fatal(err)
+	}
+	idxb := idx.Bytes()
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		got, err := NewFromIndex(bytes.NewReader(b), bytes.NewReader(idxb))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := summarize(t, sys); !cmp.Equal(summarize(t, got), want) {
+			t.Error(cmp.Diff(summarize(t, got), want))
+		}
+	})
+	t.Run("Sparse", func(t *testing.T) {
+		f, err := os.Open(filepath.Join("testdata", "sparse_pax10.tar"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		sys, err := New(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var idx bytes.Buffer
+		if err := sys.WriteIndex(&idx); err != nil {
+			t.Fatal(err)
+		}
+		got, err := NewFromIndex(f, &idx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := fs.ReadFile(sys, "frag")
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := fs.ReadFile(got, "frag")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, want) {
+			t.Error("sparse file contents differ")
+		}
+	})
+	t.Run("Mismatch", func(t *testing.T) {
+		other := mkTar(t, []tar.Header{{Name: `other`}})
+		for name, tc := range map[string]struct {
+			Archive, Index []byte
+		}{
+			"WrongArchive": {other, idxb},
+			"Truncated":    {b[:512], idxb},
+			"Corrupt":      {b, idxb[:len(idxb)/2]},
+			"Empty":        {b, nil},
+		} {
+			if _, err := NewFromIndex(bytes.NewReader(tc.Archive), bytes.NewReader(tc.Index)); !errors.Is(err, ErrIndex) {
+				t.Errorf("%s: unexpected error: %v", name, err)
+			}
+		}
+	})
+	t.Run("Sub", func(t *testing.T) {
+		sub, err := sys.Sub("a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sub.(*FS).WriteIndex(io.Discard); err == nil {
+			t.Error("expected error writing index of a sub FS")
+		}
+	})
+}
+
+func TestNewLenient(t *testing.T) {
+	b := mkTar(t, []tar.Header{
+		{Name: `a/`, Typeflag: tar.TypeDir},
+		{Name: `a/file`},
+	})
+	// Hide the ReaderAt implementation to force the spooling path.
+	r := struct{ io.Reader }{bytes.NewReader(b)}
+	sys, err := NewLenient(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(sys, "a/file"); err != nil {
+		t.Error(err)
+	}
+
+	// Compressed archives should be handled for both paths.
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	if _, err := gw.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []io.Reader{
+		bytes.NewReader(gz.Bytes()),
+		struct{ io.Reader }{bytes.NewReader(gz.Bytes())},
+	} {
+		sys, err := NewLenient(r)
+		if err != nil {
+			t.Fatalf("%T: %v", r, err)
+		}
+		if err := fstest.TestFS(sys, "a/file"); err != nil {
+			t.Errorf("%T: %v", r, err)
+		}
+	}
+
+	b = mkTar(t, []tar.Header{{Name: `../escape`}})
+	if _, err := NewLenient(bytes.NewReader(b)); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("unexpected err return: %v", err)
+	}
+}
+
+// TestGNUIncremental checks that a GNU incremental archive, as created by
+//
+//	tar -c --format=gnu -V "Backup vol" --listed-incremental=snap -f gnu_incremental.tar -C src .
+//
+// can be read. Such archives have a volume header and describe directories
+// with "dumpdir" entries.
+func TestGNUIncremental(t *testing.T) {
+	f, err := os.Open(`testdata/gnu_incremental.tar`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	sys, err := New(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(sys, "d/f"); err != nil {
+		t.Error(err)
+	}
+	fi, err := fs.Stat(sys, "d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.IsDir() {
+		t.Errorf("unexpected mode: %v", fi.Mode())
+	}
+	b, err := fs.ReadFile(sys, "d/f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "hi\n"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+	// The volume header shouldn't show up anywhere.
+	ents, err := fs.ReadDir(sys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ents) != 1 || ents[0].Name() != "d" {
+		t.Errorf("unexpected entries: %v", ents)
+	}
+}
+
+// TestSparse checks that sparse files can be read without allocating or
+// reading their holes. The archives are created from the same directory by
+//
+//	tar -c -b 1 --format=gnu -S -f sparse_gnu.tar -C src big frag
+//	tar -c -b 1 --format=posix --sparse-version=$v -S -f sparse_pax${v/./}.tar -C src big frag
+//
+// where "big" is a 1 GiB file with one 4 KiB extent at 512 MiB, and "frag" is
+// a 1 MiB file with eight 4 KiB extents every 128 KiB, each filled with a
+// different letter. The old GNU format needs an extension block for "frag".
+func TestSparse(t *testing.T) {
+	const (
+		bigSize  = 1 << 30
+		bigOff   = 1 << 29
+		fragSize = 1 << 20
+	)
+	bigData := bytes.Repeat([]byte("0123456789abcdef"), 256)
+	fragWant := make([]byte, fragSize)
+	for i := 0; i < 8; i++ {
+		copy(fragWant[i*(128<<10):], bytes.Repeat([]byte{'a' + byte(i)}, 4096))
+	}
+
+	for _, name := range []string{"gnu", "pax00", "pax01", "pax10"} {
+		t.Run(name, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", "sparse_"+name+".tar"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			r := &countingReaderAt{r: f}
+
+			var before, after runtime.MemStats
+			runtime.ReadMemStats(&before)
+			sys, err := New(r)
+			runtime.ReadMemStats(&after)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := after.TotalAlloc - before.TotalAlloc; got > 10<<20 {
+				t.Errorf("allocated %d bytes creating FS", got)
+			}
+
+			fi, err := fs.Stat(sys, "big")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !fi.Mode().IsRegular() || fi.Size() != bigSize {
+				t.Errorf("unexpected stat: %v %d", fi.Mode(), fi.Size())
+			}
+			b, err := fs.ReadFile(sys, "frag")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, fragWant) {
+				t.Error("unexpected contents of frag")
+			}
+
+			// Read all of "big", checking that only the extent has data and
+			// that the holes didn't touch the archive.
+			bf, err := sys.Open("big")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer bf.Close()
+			r.n = 0
+			buf := make([]byte, 64<<10)
+			zero := make([]byte, len(buf))
+			var pos int64
+			for {
+				n, err := bf.Read(buf)
+				switch {
+				case pos <= bigOff && pos+int64(n) > bigOff:
+					if !bytes.HasPrefix(buf[bigOff-pos:n], bigData) {
+						t.Errorf("unexpected data at %d", bigOff)
+					}
+					if !bytes.Equal(buf[:bigOff-pos], zero[:bigOff-pos]) ||
+						!bytes.Equal(buf[bigOff-pos+4096:n], zero[:n-int(bigOff-pos+4096)]) {
+						t.Errorf("unexpected data around %d", bigOff)
+					}
+				case !bytes.Equal(buf[:n], zero[:n]):
+					t.Errorf("unexpected data at %d", pos)
+				}
+				pos += int64(n)
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if pos != bigSize {
+				t.Errorf("read %d bytes, want %d", pos, bigSize)
+			}
+			if r.n > 4096 {
+				t.Errorf("read %d bytes from the archive", r.n)
+			}
+
+			// Seeking into the middle of the extent.
+			s := bf.(io.ReadSeeker)
+			if _, err := s.Seek(bigOff+100, io.SeekStart); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := io.ReadFull(s, buf[:4096]); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf[:3996], bigData[100:]) || !bytes.Equal(buf[3996:4096], zero[:100]) {
+				t.Error("unexpected data after seek")
+			}
+		})
+	}
+}
+
+// CountingReaderAt counts the bytes read from the wrapped ReaderAt.
+type countingReaderAt struct {
+	r io.ReaderAt
+	n int64
+}
+
+func (c *countingReaderAt) ReadAt(b []byte, off int64) (int, error) {
+	n, err := c.r.ReadAt(b, off)
+	c.n += int64(n)
+	return n, err
+}
+
+func TestWhiteout(t *testing.T) {
+	b := mkTar(t, []tar.Header{
+		{Name: `etc/`, Typeflag: tar.TypeDir},
+		{Name: `etc/.wh.passwd`},
+		{Name: `etc/group`},
+		{Name: `var/`, Typeflag: tar.TypeDir},
+		{Name: `var/.wh..wh..opq`},
+		{Name: `var/.wh..wh.aufs`},
+		{Name: `.wh..wh.plnk/`, Typeflag: tar.TypeDir},
+		{Name: `.wh..wh.plnk/123.456`},
+	})
+
+	t.Run("None", func(t *testing.T) {
+		sys, err := New(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fi, err := fs.Stat(sys, "etc/.wh.passwd")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := fi.Sys().(*Whiteout); ok {
+			t.Error("whiteout unexpectedly recognized")
+		}
+		if ws := sys.Whiteouts(); len(ws) != 0 {
+			t.Errorf("unexpected whiteouts: %v", ws)
+		}
+	})
+
+	chk := func(t *testing.T, sys *FS, want []string) {
+		t.Helper()
+		var got []string
+		for _, w := range sys.Whiteouts() {
+			got = append(got, w.Header.Name+"="+w.Target)
+		}
+		if got, want := strings.Join(got, ","), strings.Join(want, ","); got != want {
+			t.Errorf("got: %q, want: %q", got, want)
+		}
+		fi, err := fs.Stat(sys, "etc/.wh.passwd")
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, ok := fi.Sys().(*Whiteout)
+		if !ok || w.Target != "etc/passwd" || w.Opaque {
+			t.Errorf("unexpected Sys: %#v", fi.Sys())
+		}
+		ents, err := fs.ReadDir(sys, "var")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range ents {
+			fi, err := e.Info()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if e.Name() != ".wh..wh..opq" {
+				continue
+			}
+			w, ok := fi.Sys().(*Whiteout)
+			if !ok || w.Target != "var" || !w.Opaque {
+				t.Errorf("unexpected Sys: %#v", fi.Sys())
+			}
+		}
+		if _, err := fs.Stat(sys, "etc/group"); err != nil {
+			t.Error(err)
+		}
+	}
+	t.Run("OCI", func(t *testing.T) {
+		sys, err := New(bytes.NewReader(b), WithWhiteoutHandling(WhiteoutModeOCI))
+		if err != nil {
+			t.Fatal(err)
+		}
+		chk(t, sys, []string{
+			"etc/.wh.passwd=etc/passwd",
+			"var/.wh..wh..opq=var",
+			"var/.wh..wh.aufs=var/.wh.aufs",
+		})
+	})
+	t.Run("AUFS", func(t *testing.T) {
+		sys, err := New(bytes.NewReader(b), WithWhiteoutHandling(WhiteoutModeAUFS))
+		if err != nil {
+			t.Fatal(err)
+		}
+		chk(t, sys, []string{
+			"etc/.wh.passwd=etc/passwd",
+			"var/.wh..wh..opq=var",
+		})
+		for _, n := range []string{".wh..wh.plnk", ".wh..wh.plnk/123.456", "var/.wh..wh.aufs"} {
+			if _, err := fs.Stat(sys, n); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("%q: unexpected err return: %v", n, err)
+			}
+		}
+	})
+	t.Run("Sub", func(t *testing.T) {
+		sys, err := New(bytes.NewReader(b), WithWhiteoutHandling(WhiteoutModeAUFS))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sub, err := sys.Sub("etc")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ws := sub.(*FS).Whiteouts()
+		if len(ws) != 1 || ws[0].Target != "passwd" {
+			t.Errorf("unexpected whiteouts: %v", ws)
+		}
+	})
+}
+
+func TestNewFromStream(t *testing.T) {
+	b := mkTar(t, []tar.Header{
+		{Name: `a/`, Typeflag: tar.TypeDir},
+		{Name: `a/file`},
+		{Name: `b`},
+	})
+	tcs := []struct {
+		Name string
+		Opts []Option
+		Err  error
+	}{
+		{Name: "Default"},
+		{Name: "Spill", Opts: []Option{WithMemoryLimit(512)}},
+		{Name: "AlwaysSpill", Opts: []Option{WithMemoryLimit(0)}},
+		{Name: "TooBig", Opts: []Option{WithMaxTotalSize(int64(len(b)/2 - 1))}, Err: ErrLimit},
+		{Name: "TooBigSpill", Opts: []Option{WithMemoryLimit(512), WithMaxTotalSize(int64(len(b)/2 - 1))}, Err: ErrLimit},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			// Hide any other interfaces.
+			r := struct{ io.Reader }{bytes.NewReader(b)}
+			sys, err := NewFromStream(r, tc.Opts...)
+			if tc.Err != nil {
+				if !errors.Is(err, tc.Err) {
+					t.Errorf("unexpected err return: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := fstest.TestFS(sys, "a/file", "b"); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestNewCompressed(t *testing.T) {
+	b := mkTar(t, []tar.Header{
+		{Name: `a/`, Typeflag: tar.TypeDir},
+		{Name: `a/file`},
+		{Name: `b`},
+	})
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	if _, err := gw.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zw, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zst := zw.EncodeAll(b, nil)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	evil := mkTar(t, []tar.Header{{Name: `../evil`}})
+	var evilgz bytes.Buffer
+	gw = gzip.NewWriter(&evilgz)
+	if _, err := gw.Write(evil); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tcs := []struct {
+		Name string
+		In   []byte
+		Opts []Option
+		Err  error
+	}{
+		{Name: "None", In: b},
+		{Name: "Gzip", In: gz.Bytes()},
+		{Name: "Zstd", In: zst},
+		{Name: "Spill", In: zst, Opts: []Option{WithMemoryLimit(0)}},
+		{Name: "TooBig", In: gz.Bytes(), Opts: []Option{WithMaxTotalSize(int64(len(b)/2 - 1))}, Err: ErrLimit},
+		{Name: "Malicious", In: evilgz.Bytes(), Err: ErrMaliciousPath},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			sys, err := NewCompressed(bytes.NewReader(tc.In), tc.Opts...)
+			if tc.Err != nil {
+				if !errors.Is(err, tc.Err) {
+					t.Errorf("unexpected err return: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := fstest.TestFS(sys, "a/file", "b"); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestHardlink(t *testing.T) {
+	sys := mkFS(t, []tar.Header{
+		// Out of order: link before target.
+		{Typeflag: tar.TypeLink, Name: `early`, Linkname: `a/target`},
+		{Name: `a/`, Typeflag: tar.TypeDir},
+		{Name: `a/target`},
+		{Typeflag: tar.TypeLink, Name: `late`, Linkname: `a/target`},
+		{Typeflag: tar.TypeLink, Name: `chain`, Linkname: `late`},
+		{Typeflag: tar.TypeLink, Name: `dangling`, Linkname: `nope`},
+	})
+	want, err := fs.ReadFile(sys, "a/target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(want) == 0 {
+		t.Fatal("empty target")
+	}
+	for _, n := range []string{"early", "late", "chain"} {
+		got, err := fs.ReadFile(sys, n)
+		if err != nil {
+			t.Errorf("%s: %v", n, err)
+			continue
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: got: %q, want: %q", n, got, want)
+		}
+		f, err := sys.Open(n)
+		if err != nil {
+			t.Errorf("%s: %v", n, err)
+			continue
+		}
+		got, err = io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			t.Errorf("%s: %v", n, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: got: %q, want: %q", n, got, want)
+		}
+		fi, err := fs.Stat(sys, n)
+		if err != nil {
+			t.Errorf("%s: %v", n, err)
+			continue
+		}
+		if got, want := fi.Size(), int64(len(want)); got != want {
+			t.Errorf("%s: size: got: %d, want: %d", n, got, want)
+		}
+	}
+	if _, err := sys.Open("dangling"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("unexpected err return: %v", err)
+	}
+	if err := fstest.TestFS(sys, "early", "late", "chain", "a/target"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLinkCount(t *testing.T) {
+	sys := mkFS(t, []tar.Header{
+		{Typeflag: tar.TypeLink, Name: `early`, Linkname: `a/target`},
+		{Name: `a/`, Typeflag: tar.TypeDir},
+		{Name: `a/target`},
+		{Typeflag: tar.TypeLink, Name: `late`, Linkname: `a/target`},
+		{Typeflag: tar.TypeLink, Name: `chain`, Linkname: `late`},
+		{Typeflag: tar.TypeLink, Name: `dangling`, Linkname: `nope`},
+		{Name: `a/b/c/`, Typeflag: tar.TypeDir},
+		{Name: `a/d/`, Typeflag: tar.TypeDir},
+		{Name: `a/file`},
+		{Name: `link`, Typeflag: tar.TypeSymlink, Linkname: `a/file`},
+	})
+	stat := func(t *testing.T, fi fs.FileInfo) *StatInfo {
+		t.Helper()
+		if _, ok := fi.(*FileInfo); !ok {
+			t.Fatalf("%s: unexpected FileInfo type: %T", fi.Name(), fi)
+		}
+		st, ok := fi.Sys().(*StatInfo)
+		if !ok {
+			t.Fatalf("%s: unexpected Sys: %#v", fi.Name(), fi.Sys())
+		}
+		return st
+	}
+
+	tgt, err := sys.Stat("a/target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ino := stat(t, tgt).Ino
+	inos := make(map[uint64]string)
+	for _, tc := range []struct {
+		Name  string
+		Nlink uint64
+	}{
+		{Name: ".", Nlink: 3},
+		{Name: "a", Nlink: 4},
+		{Name: "a/b", Nlink: 3},
+		{Name: "a/b/c", Nlink: 2},
+		{Name: "a/target", Nlink: 4},
+		{Name: "early", Nlink: 4},
+		{Name: "late", Nlink: 4},
+		{Name: "chain", Nlink: 4},
+		{Name: "a/file", Nlink: 1},
+		{Name: "link", Nlink: 1},
+	} {
+		fi, err := sys.Lstat(tc.Name)
+		if err != nil {
+			t.Errorf("%s: %v", tc.Name, err)
+			continue
+		}
+		st := stat(t, fi)
+		if got, want := st.Nlink, tc.Nlink; got != want {
+			t.Errorf("%s: nlink: got: %d, want: %d", tc.Name, got, want)
+		}
+		if st.Ino == 0 {
+			t.Errorf("%s: zero inode number", tc.Name)
+		}
+		switch tc.Name {
+		case "a/target", "early", "late", "chain":
+			if got, want := st.Ino, ino; got != want {
+				t.Errorf("%s: ino: got: %d, want: %d", tc.Name, got, want)
+			}
+		default:
+			if prev, ok := inos[st.Ino]; ok {
+				t.Errorf("%s: ino %d shared with %s", tc.Name, st.Ino, prev)
+			}
+			inos[st.Ino] = tc.Name
+		}
+	}
+
+	// The other ways to get a FileInfo agree.
+	es, err := sys.ReadDir("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range es {
+		fi, err := e.Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := sys.Stat(path.Join("a", e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := stat(t, fi), stat(t, want); !cmp.Equal(got, want) {
+			t.Errorf("%s: %s", e.Name(), cmp.Diff(got, want))
+		}
+	}
+	f, err := sys.Open("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stat(t, fi).Nlink, uint64(4); got != want {
+		t.Errorf("open a: nlink: got: %d, want: %d", got, want)
+	}
+	if got, want := fi.(*FileInfo).TarHeader().Name, "a"; got != want {
+		t.Errorf("open a: header name: got: %q, want: %q", got, want)
+	}
+}
+
+func TestStatFS(t *testing.T) {
+	hs := []tar.Header{
+		{Name: `a/`, Typeflag: tar.TypeDir},
+		{Name: `a/file`},
+		{Name: `b/c`},
+		{Typeflag: tar.TypeLink, Name: `link`, Linkname: `a/file`},
+		{Typeflag: tar.TypeSymlink, Name: `sym`, Linkname: `b/c`},
+	}
+	b := mkTar(t, hs)
+	sys, err := New(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := sys.StatFS()
+	want := FSStat{
+		// Every header plus the implied "b" directory.
+		Blocks: int64(len(hs) + 1),
+		Bsize:  512,
+		Size:   int64(len("a/file") + len("b/c")),
+		// Everything but the two trailer blocks.
+		ArchiveSize: int64(len(b) - 1024),
+	}
+	if got != want {
+		t.Errorf("got: %+v, want: %+v", got, want)
+	}
+
+	sub, err := sys.Sub("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = sub.(*FS).StatFS()
+	want.Blocks = 1
+	want.Size = int64(len("a/file"))
+	if got != want {
+		t.Errorf("got: %+v, want: %+v", got, want)
+	}
+}
+
+func TestReadLink(t *testing.T) {
+	sys := mkFS(t, []tar.Header{
+		{Name: `a/b/`, Typeflag: tar.TypeDir},
+		{Name: `a/b/file`},
+		{Typeflag: tar.TypeSymlink, Name: `a/rel`, Linkname: `b/file`},
+		{Typeflag: tar.TypeSymlink, Name: `a/abs`, Linkname: `/a/b/file`},
+		{Typeflag: tar.TypeSymlink, Name: `a/up`, Linkname: `../top`},
+		{Typeflag: tar.TypeSymlink, Name: `dir`, Linkname: `a`},
+		{Name: `top`},
+	})
+	for n, want := range map[string]string{
+		"a/rel":     "b/file",
+		"a/abs":     "b/file",
+		"a/up":      "../top",
+		"dir":       "a",
+		"dir/rel":   "b/file",
+		"dir/../up": "",
+	} {
+		got, err := sys.ReadLink(n)
+		if want == "" {
+			if !errors.Is(err, fs.ErrInvalid) {
+				t.Errorf("%q: unexpected err return: %v", n, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", n, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("%q: got: %q, want: %q", n, got, want)
+		}
+	}
+	for _, n := range []string{"a/b/file", "a/b", "top"} {
+		if _, err := sys.ReadLink(n); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("%q: unexpected err return: %v", n, err)
+		}
+	}
+	if _, err := sys.ReadLink("nope"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("unexpected err return: %v", err)
+	}
+
+	fi, err := sys.Lstat("dir/rel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Type() != fs.ModeSymlink {
+		t.Errorf("unexpected mode: %v", fi.Mode())
+	}
+	f, err := sys.Open("dir/rel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err = f.Stat()
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.Mode().IsRegular() {
+		t.Errorf("unexpected mode: %v", fi.Mode())
+	}
+}
+
+// BenchmarkNew measures index construction for synthetic archives of varying
+// sizes. Run with "-benchmem" to see allocations.
+func BenchmarkNew(b *testing.B) {
+	for _, n := range []int{1000, 10000, 100000} {
+		buf := mkBenchTar(b, n)
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(buf)))
+			rd := bytes.NewReader(buf)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := New(rd); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// MkBenchTar creates a tar with "n" entries, laid out like a typical layer:
+// a number of directories, each containing a number of small files.
+func mkBenchTar(b *testing.B, n int) []byte {
+	b.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	const perDir = 50
+	contents := []byte("contents")
+	for i := 0; i < n; i++ {
+		d := fmt.Sprintf("usr/share/dir%04d/", i/perDir)
+		if i%perDir == 0 {
+			if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: d, Mode: 0o755}); err != nil {
+				b.Fatal(err)
+			}
+			continue
+		}
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     fmt.Sprintf("%sfile%04d", d, i%perDir),
+			Size:     int64(len(contents)),
+			Mode:     0o644,
+		}); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := tw.Write(contents); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		b.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// TestNormPath checks that the allocation-avoiding normPath matches the
+// original implementation.
+func TestNormPath(t *testing.T) {
+	for _, p := range []string{
+		"", ".", "..", "/", "//", "a", "a/", "/a", "./a", "a/./b", "a//b",
+		"a/../b", "a/../../b", "../a", "../../a/b/", "/../a", "a/b/..",
+		"a/b/../..", "a/b/../../..", ".a", "..a", "a/..b", "a/b.",
+	} {
+		want, _ := filepath.Rel("/", filepath.Join("/", p))
+		if got := normPath(p); got != want {
+			t.Errorf("%q: got: %q, want: %q", p, got, want)
+		}
+	}
+}
+
+func TestMaliciousPath(t *testing.T) {
+	tcs := []struct {
+		Name string
+		Bad  bool
+	}{
+		{Name: `..`, Bad: true},
+		{Name: `../`, Bad: true},
+		{Name: `../etc/passwd`, Bad: true},
+		{Name: `../../etc/passwd`, Bad: true},
+		{Name: `./../etc/passwd`, Bad: true},
+		{Name: `..//etc/passwd`, Bad: true},
+		{Name: `a/../../etc/passwd`, Bad: true},
+		{Name: `a/b/../../../etc/passwd`, Bad: true},
+		{Name: `./a/./../../etc/passwd`, Bad: true},
+		{Name: `a/../..`, Bad: true},
+		{Name: `../a/../../b`, Bad: true},
+		// These are fine.
+		{Name: `/etc/shadow`},
+		{Name: `a/../b`},
+		{Name: `..a`},
+		{Name: `a/..b`},
+		{Name: `./a`},
+		{Name: `a/b/../c`},
+	}
+	for _, tc := range tcs {
+		t.Run(strings.ReplaceAll(tc.Name, "/", "_"), func(t *testing.T) {
+			b := mkTar(t, []tar.Header{{Name: tc.Name}})
+			_, err := New(bytes.NewReader(b))
+			if !tc.Bad {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			t.Log(err)
+			if !errors.Is(err, ErrMaliciousPath) {
+				t.Fatalf("unexpected err return: %v", err)
+			}
+			if !strings.Contains(err.Error(), tc.Name) {
+				t.Errorf("error %q doesn't mention raw name %q", err, tc.Name)
+			}
+		})
+	}
+}
+
+func TestGlob(t *testing.T) {
+	sys := mkFS(t, []tar.Header{
+		{Name: `etc/`, Typeflag: tar.TypeDir},
+		{Name: `etc/os-release`},
+		{Name: `etc/passwd`},
+		{Name: `usr/lib/os-release`},
+		{Name: `usr/lib/rpm/`, Typeflag: tar.TypeDir},
+		{Name: `usr/lib/rpm/rpmdb.sqlite`},
+		{Name: `usr/share/os-release`},
+		{Name: `lib`, Typeflag: tar.TypeSymlink, Linkname: `usr/lib`},
+	})
+	tcs := []struct {
+		Pattern string
+		Want    []string
+	}{
+		{Pattern: `.`, Want: []string{`.`}},
+		{Pattern: `*`, Want: []string{`.`, `etc`, `lib`, `usr`}},
+		{Pattern: `etc/*`, Want: []string{`etc/os-release`, `etc/passwd`}},
+		{Pattern: `*/*/os-release`, Want: []string{`usr/lib/os-release`, `usr/share/os-release`}},
+		{Pattern: `usr/lib/rpm/rpmdb.*`, Want: []string{`usr/lib/rpm/rpmdb.sqlite`}},
+		{Pattern: `etc/passwd/*`},
+		{Pattern: `nope/*`},
+		{Pattern: `etc//passwd`},
+	}
+	for _, tc := range tcs {
+		// Do every lookup twice to exercise the cache.
+		for n := 0; n < 2; n++ {
+			got, err := sys.Glob(tc.Pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(got, tc.Want) {
+				t.Errorf("%q: %v", tc.Pattern, cmp.Diff(got, tc.Want))
+			}
+			// Results should agree with the generic implementation, except
+			// that the root is reported when matched.
+			ref, err := fs.Glob(readDirOnly{sys}, tc.Pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.Pattern == `*` {
+				ref = append([]string{`.`}, ref...)
+			}
+			if !cmp.Equal(got, ref) {
+				t.Errorf("%q: %v", tc.Pattern, cmp.Diff(got, ref))
+			}
+			if got != nil {
+				// Mutating the result must not affect the cache.
+				got[0] = "x"
+			}
+		}
+	}
+	if _, err := sys.Glob(`[`); !errors.Is(err, path.ErrBadPattern) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	t.Run("Invalidate", func(t *testing.T) {
+		if _, err := sys.Glob(`etc/*`); err != nil {
+			t.Fatal(err)
+		}
+		// Poison the cache entry, then check that invalidating ignores it.
+		sys.glob.m.Store(`etc/*`, globEntry{gen: sys.glob.gen.Load(), names: []string{"bogus"}})
+		got, _ := sys.Glob(`etc/*`)
+		if want := []string{"bogus"}; !cmp.Equal(got, want) {
+			t.Errorf("cache not used: %v", cmp.Diff(got, want))
+		}
+		sys.glob.invalidate()
+		got, _ = sys.Glob(`etc/*`)
+		if want := []string{`etc/os-release`, `etc/passwd`}; !cmp.Equal(got, want) {
+			t.Error(cmp.Diff(got, want))
+		}
+	})
+	t.Run("Sub", func(t *testing.T) {
+		sub, err := fs.Sub(sys, "usr")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := fs.Glob(sub, `*/os-release`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{`lib/os-release`, `share/os-release`}; !cmp.Equal(got, want) {
+			t.Error(cmp.Diff(got, want))
+		}
+	})
+}
+
+// ReadDirOnly hides every method but Open and ReadDir, forcing the generic
+// fs.Glob implementation.
+type readDirOnly struct{ f *FS }
+
+func (r readDirOnly) Open(name string) (fs.File, error)          { return r.f.Open(name) }
+func (r readDirOnly) ReadDir(name string) ([]fs.DirEntry, error) { return r.f.ReadDir(name) }
+
+func TestFileDigest(t *testing.T) {
+	b := mkTar(t, []tar.Header{
+		{Name: `a/`, Typeflag: tar.TypeDir},
+		{Name: `a/file`},
+		{Name: `b`},
+		{Name: `link`, Typeflag: tar.TypeSymlink, Linkname: `a/file`},
+		{Name: `hard`, Typeflag: tar.TypeLink, Linkname: `b`},
+	})
+	sys, err := New(bytes.NewReader(b), WithDigests())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		`a/file`: `a/file`,
+		`b`:      `b`,
+		`link`:   `a/file`,
+		`hard`:   `b`,
+	} {
+		got, err := sys.FileDigest(name)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if want := sha256.Sum256([]byte(want)); !bytes.Equal(got, want[:]) {
+			t.Errorf("%s: got: %x, want: %x", name, got, want)
+		}
+	}
+	if _, err := sys.FileDigest(`a`); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("unexpected error for directory: %v", err)
+	}
+	if _, err := sys.FileDigest(`nope`); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("unexpected error for missing file: %v", err)
+	}
+	// The contents should still be readable after hashing.
+	for _, name := range []string{`a/file`, `b`, `link`, `hard`} {
+		if _, err := fs.ReadFile(sys, name); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	sys, err = New(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sys.FileDigest(`b`); !errors.Is(err, ErrNoDigest) {
+		t.Errorf("unexpected err return: %v", err)
+	}
+}
+
+func TestContentDigest(t *testing.T) {
+	// MkArchive returns an archive containing "files", in order, with every
+	// member having the modification time "mod".
+	type file struct{ Name, Content string }
+	mkArchive := func(t *testing.T, mod time.Time, files ...file) []byte {
+		t.Helper()
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for _, f := range files {
+			h := tar.Header{
+				Name:     f.Name,
+				Typeflag: tar.TypeReg,
+				Size:     int64(len(f.Content)),
+				Mode:     0o644,
+				ModTime:  mod,
+			}
+			if strings.HasSuffix(f.Name, "/") {
+				h.Typeflag = tar.TypeDir
+				h.Mode = 0o755
+			}
+			if err := tw.WriteHeader(&h); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := io.WriteString(tw, f.Content); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	digest := func(t *testing.T, b []byte, opts ...Option) []byte {
+		t.Helper()
+		sys, err := New(bytes.NewReader(b), opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := sys.ContentDigest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	t1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(24 * time.Hour)
+	files := []file{
+		{`a/`, ``},
+		{`a/file`, `hello`},
+		{`b`, `world`},
+		{`c`, ``},
+	}
+	want := digest(t, mkArchive(t, t1, files...))
+
+	t.Run("Timestamps", func(t *testing.T) {
+		got := digest(t, mkArchive(t, t2, files...))
+		if !bytes.Equal(got, want) {
+			t.Errorf("got: %x, want: %x", got, want)
+		}
+	})
+	t.Run("Order", func(t *testing.T) {
+		got := digest(t, mkArchive(t, t1, files[2], files[0], files[3], files[1]))
+		if !bytes.Equal(got, want) {
+			t.Errorf("got: %x, want: %x", got, want)
+		}
+	})
+	t.Run("WithDigests", func(t *testing.T) {
+		got := digest(t, mkArchive(t, t1, files...), WithDigests())
+		if !bytes.Equal(got, want) {
+			t.Errorf("got: %x, want: %x", got, want)
+		}
+	})
+	t.Run("Content", func(t *testing.T) {
+		got := digest(t, mkArchive(t, t1, files[0], file{`a/file`, `hellO`}, files[2], files[3]))
+		if bytes.Equal(got, want) {
+			t.Errorf("got: %x, want anything else", got)
+		}
+	})
+	t.Run("Rename", func(t *testing.T) {
+		got := digest(t, mkArchive(t, t1, files[0], file{`a/elif`, `hello`}, files[2], files[3]))
+		if bytes.Equal(got, want) {
+			t.Errorf("got: %x, want anything else", got)
+		}
+	})
+	t.Run("Boundary", func(t *testing.T) {
+		// Moving bytes between a name and the previous file's contents
+		// shouldn't produce the same digest.
+		a := digest(t, mkArchive(t, t1, file{`x`, `ab`}, file{`y`, `c`}))
+		b := digest(t, mkArchive(t, t1, file{`x`, `a`}, file{`y`, `bc`}))
+		if bytes.Equal(a, b) {
+			t.Errorf("got: %x, want anything else", a)
+		}
+	})
+	t.Run("Hardlink", func(t *testing.T) {
+		b := mkTar(t, []tar.Header{
+			{Name: `a`},
+			{Name: `hard`, Typeflag: tar.TypeLink, Linkname: `a`},
+			{Name: `link`, Typeflag: tar.TypeSymlink, Linkname: `a`},
+		})
+		got := digest(t, b)
+		h := sha256.New()
+		for _, n := range []string{`a`, `hard`} {
+			d := sha256.Sum256([]byte(`a`))
+			io.WriteString(h, n)
+			h.Write([]byte{0})
+			h.Write(d[:])
+		}
+		if want := h.Sum(nil); !bytes.Equal(got, want) {
+			t.Errorf("got: %x, want: %x", got, want)
+		}
+	})
+}
+
+func TestContentCache(t *testing.T) {
+	b := mkTar(t, []tar.Header{
+		{Name: `a`},
+		{Name: `b`},
+		{Name: `c`},
+		{Name: `d/`, Typeflag: tar.TypeDir},
+		{Name: `d/link`, Typeflag: tar.TypeLink, Linkname: `a`},
+	})
+	t.Run("Disabled", func(t *testing.T) {
+		sys, err := New(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fs.ReadFile(sys, "a"); err != nil {
+			t.Fatal(err)
+		}
+		if h, m := sys.CacheStats(); h != 0 || m != 0 {
+			t.Errorf("got: %d hits, %d misses", h, m)
+		}
+	})
+	t.Run("Hits", func(t *testing.T) {
+		sys, err := New(bytes.NewReader(b), WithContentCache(1024))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, n := range []string{"a", "a", "b", "d/link", "a"} {
+			got, err := fs.ReadFile(sys, n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := string(got), "a"; n != "b" && got != want {
+				t.Errorf("%s: got: %q, want: %q", n, got, want)
+			}
+			// Make sure callers can't modify the cached contents.
+			got[0] = 'x'
+		}
+		f, err := sys.Open("a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(got), "a"; got != want {
+			t.Errorf("got: %q, want: %q", got, want)
+		}
+		// Misses: "a", "b". Everything else, including the hardlink, hits.
+		if h, m := sys.CacheStats(); h != 4 || m != 2 {
+			t.Errorf("got: %d hits, %d misses", h, m)
+		}
+		sub, err := fs.Sub(sys, "d")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fs.ReadFile(sub, "link"); err != nil {
+			t.Fatal(err)
+		}
+		if h, _ := sys.CacheStats(); h != 5 {
+			t.Errorf("Sub did not share cache: got: %d hits", h)
+		}
+	})
+	t.Run("Evict", func(t *testing.T) {
+		// Room for exactly one of the 1-byte files.
+		sys, err := New(bytes.NewReader(b), WithContentCache(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, n := range []string{"a", "b", "a", "c", "c"} {
+			if _, err := fs.ReadFile(sys, n); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if h, m := sys.CacheStats(); h != 1 || m != 4 {
+			t.Errorf("got: %d hits, %d misses", h, m)
+		}
+	})
+	t.Run("Concurrent", func(t *testing.T) {
+		sys, err := New(bytes.NewReader(b), WithContentCache(2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					n := string(rune('a' + (i+j)%3))
+					got, err := fs.ReadFile(sys, n)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if string(got) != n {
+						t.Errorf("got: %q, want: %q", got, n)
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+		if h, m := sys.CacheStats(); h+m != 800 {
+			t.Errorf("got: %d hits, %d misses", h, m)
+		}
+	})
+}
+
+func BenchmarkContentCache(b *testing.B) {
+	const files = 20
+	contents := bytes.Repeat([]byte("contents"), 4096)
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for i := 0; i < files; i++ {
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     fmt.Sprintf("usr/lib/lib%02d.so", i),
+			Size:     int64(len(contents)),
+			Mode:     0o644,
+		}); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := tw.Write(contents); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		b.Fatal(err)
+	}
+	for _, tc := range []struct {
+		Name string
+		Opts []Option
+	}{
+		{Name: "NoCache"},
+		{Name: "Cache", Opts: []Option{WithContentCache(files * int64(len(contents)))}},
+	} {
+		b.Run(tc.Name, func(b *testing.B) {
+			sys, err := New(bytes.NewReader(buf.Bytes()), tc.Opts...)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.SetBytes(files * int64(len(contents)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for j := 0; j < files; j++ {
+					if _, err := sys.ReadFile(fmt.Sprintf("usr/lib/lib%02d.so", j)); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEntries(t *testing.T) {
+	sys := mkFS(t, []tar.Header{
+		{Name: `b/`, Typeflag: tar.TypeDir, Mode: 0o755},
+		{Name: `b/file`, Mode: 0o644},
+		{Name: `a/implied/file`, Mode: 0o600},
+		{Name: `link`, Typeflag: tar.TypeSymlink, Linkname: `b/file`},
+		{Name: `hard`, Typeflag: tar.TypeLink, Linkname: `b/file`},
+	})
+	// Build the expected entries with WalkDir.
+	var want []EntryInfo
+	err := fs.WalkDir(sys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == "." {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		want = append(want, EntryInfo{
+			Name:    p,
+			Size:    fi.Size(),
+			Mode:    fi.Mode(),
+			ModTime: fi.ModTime(),
+			Type:    d.Type(),
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(want, func(i, j int) bool { return want[i].Name < want[j].Name })
+	got := sys.Entries()
+	if !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(got, want))
+	}
+	if got, want := len(got), 7; got != want {
+		t.Errorf("got %d entries, want %d", got, want)
+	}
+	// The returned slice is owned by the caller.
+	got[0].Name = "mutated"
+	if sys.Entries()[0].Name == "mutated" {
+		t.Error("internal slice returned")
+	}
+
+	sub, err := fs.Sub(sys, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ents := sub.(*FS).Entries()
+	if len(ents) != 1 || ents[0].Name != "file" {
+		t.Errorf("unexpected Sub entries: %+v", ents)
+	}
+}
+
+// BenchmarkEntries compares Entries to an equivalent fs.WalkDir.
+func BenchmarkEntries(b *testing.B) {
+	sys, err := New(bytes.NewReader(mkBenchTar(b, 10000)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("Entries", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if len(sys.Entries()) == 0 {
+				b.Fatal("no entries")
+			}
+		}
+	})
+	b.Run("WalkDir", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var es []EntryInfo
+			err := fs.WalkDir(sys, ".", func(p string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				fi, err := d.Info()
+				if err != nil {
+					return err
+				}
+				es = append(es, EntryInfo{
+					Name:    p,
+					Size:    fi.Size(),
+					Mode:    fi.Mode(),
+					ModTime: fi.ModTime(),
+					Type:    d.Type(),
+				})
+				return nil
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// FuzzNew checks that New and the methods on the returned FS don't panic on
+// arbitrary input. The seed corpus runs as part of the normal tests; use
+// "go test -fuzz FuzzNew" to explore further. Some seeds are large, so
+// passing a short "-fuzzminimizetime" keeps the fuzzer from stalling while it
+// minimizes new inputs.
+func FuzzNew(f *testing.F) {
+	// Empty archive.
+	f.Add(make([]byte, 2*512))
+	// Symlink cycle.
+	f.Add(mkTar(f, []tar.Header{
+		{Typeflag: tar.TypeSymlink, Name: `b`, Linkname: `a`},
+		{Typeflag: tar.TypeSymlink, Name: `a`, Linkname: `b`},
+		{Name: `b/c`},
+	}))
+	// A little of everything, like the fstest tar.
+	f.Add(mkTar(f, []tar.Header{
+		{Typeflag: tar.TypeDir, Name: `etc/`, Mode: 0o755},
+		{Typeflag: tar.TypeReg, Name: `etc/os-release`, Mode: 0o644},
+		{Typeflag: tar.TypeSymlink, Name: `usr/lib/os-release`, Linkname: `../../etc/os-release`},
+		{Typeflag: tar.TypeLink, Name: `etc/hard`, Linkname: `etc/os-release`},
+		{Typeflag: tar.TypeReg, Name: `etc/.wh.gone`},
+		{Typeflag: tar.TypeReg, Name: `./../escape`},
+	}))
+	for _, name := range []string{
+		`testdata/bad_name.tar`,
+		`testdata/concat.tar`,
+		`testdata/gnu_incremental.tar`,
+		`testdata/known/oddlayer.tar`,
+	} {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(b)
+	}
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		for _, mk := range []func(io.ReaderAt) (*FS, error){
+			func(r io.ReaderAt) (*FS, error) { return New(r) },
+			func(r io.ReaderAt) (*FS, error) {
+				return NewLenient(io.NewSectionReader(r, 0, int64(len(b))))
+			},
+		} {
+			sys, err := mk(bytes.NewReader(b))
+			if err != nil {
+				continue
+			}
+			fs.WalkDir(sys, ".", func(p string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return nil
+				}
+				switch {
+				case d.Type()&fs.ModeSymlink != 0:
+					sys.ReadLink(p)
+					sys.Stat(p)
+				case d.Type().IsRegular():
+					sys.ReadFile(p)
+					sys.FileDigest(p)
+				}
+				return nil
+			})
+			sys.Entries()
+			sys.Whiteouts()
+			sys.StatFS()
+			sys.Glob("*/*")
+		}
+	})
+}
@@ -0,0 +1,201 @@
+//go:build tarfshttpd
+
+// Package tarfshttpd serves the contents of a [tarfs.FS] over HTTP, for
+// inspecting the filesystem of a layer while debugging.
+//
+// This is a debugging tool, not a production server, so it's only built with
+// the "tarfshttpd" build tag.
+package tarfshttpd
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/quay/claircore/pkg/tarfs"
+)
+
+// ServeFS returns a handler that serves the contents of "fsys" read-only, in
+// the manner of [http.FileServer].
+//
+// Directories are served as HTML listings, and regular files are served with a
+// Content-Type based on their extension. Range requests are served by seeking
+// to the requested offset in the archive. If "fsys" was created with
+// [tarfs.WithDigests], files are served with an ETag of their SHA-256 digest.
+//
+// Symlinks are followed, but requests that would follow a symlink out of the
+// root of "fsys", which is possible for FSes returned by [tarfs.FS.Sub], are
+// refused with "403 Forbidden". Other kinds of files are reported as not
+// existing.
+func ServeFS(fsys *tarfs.FS) http.Handler {
+	return &handler{fsys: fsys}
+}
+
+// Handler is the handler returned by ServeFS.
+type handler struct {
+	fsys *tarfs.FS
+}
+
+// ServeHTTP implements [http.Handler].
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	default:
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	p := r.URL.Path
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	name := path.Clean(p)[1:]
+	if name == "" {
+		name = "."
+	}
+	name, err := h.resolve(name)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	fi, err := h.fsys.Stat(name)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	switch {
+	case fi.IsDir():
+		if !strings.HasSuffix(p, "/") {
+			redirect(w, r, path.Base(p)+"/")
+			return
+		}
+		h.serveDir(w, r, name)
+	case fi.Mode().IsRegular():
+		h.serveFile(w, r, name, fi)
+	default:
+		httpError(w, fs.ErrNotExist)
+	}
+}
+
+// Resolve follows the symlinks in every element of "name", returning the
+// resulting name.
+//
+// An error wrapping [fs.ErrPermission] is returned if a symlink points outside
+// of the FS.
+func (h *handler) resolve(name string) (string, error) {
+	elems := strings.Split(name, "/")
+	cur := "."
+	var links int
+	for i := 0; i < len(elems); i++ {
+		next := path.Join(cur, elems[i])
+		fi, err := h.fsys.Lstat(next)
+		if err != nil {
+			return "", err
+		}
+		if fi.Mode()&fs.ModeSymlink == 0 {
+			cur = next
+			continue
+		}
+		links++
+		if links > tarfs.DefaultMaxSymlinkDepth {
+			return "", &fs.PathError{
+				Op:   `open`,
+				Path: name,
+				Err:  fmt.Errorf("too many levels of symbolic links: %w", tarfs.ErrLimit),
+			}
+		}
+		tgt, err := h.fsys.ReadLink(next)
+		if err != nil {
+			return "", err
+		}
+		// The target is relative to the directory containing the link.
+		tgt = path.Join(cur, tgt)
+		if tgt == ".." || strings.HasPrefix(tgt, "../") {
+			return "", &fs.PathError{
+				Op:   `open`,
+				Path: next,
+				Err:  fmt.Errorf("symlink escapes root: %w", fs.ErrPermission),
+			}
+		}
+		// Start over with the target in place of the link.
+		elems = append(strings.Split(tgt, "/"), elems[i+1:]...)
+		cur = "."
+		i = -1
+	}
+	return cur, nil
+}
+
+// ServeDir writes a listing of the directory "name".
+func (h *handler) serveDir(w http.ResponseWriter, r *http.Request, name string) {
+	ents, err := h.fsys.ReadDir(name)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if r.Method == http.MethodHead {
+		return
+	}
+	fmt.Fprintln(w, "<!doctype html>")
+	fmt.Fprintf(w, "<title>%s</title>\n", html.EscapeString(name))
+	fmt.Fprintln(w, "<pre>")
+	for _, e := range ents {
+		n := e.Name()
+		if e.IsDir() {
+			n += "/"
+		}
+		// Escape the name as a relative reference, so that a name containing a
+		// colon isn't interpreted as a scheme.
+		u := url.URL{Path: n}
+		fmt.Fprintf(w, "<a href=\"%s\">%s</a>\n", html.EscapeString(u.String()), html.EscapeString(n))
+	}
+	fmt.Fprintln(w, "</pre>")
+}
+
+// ServeFile writes the contents of the regular file "name".
+func (h *handler) serveFile(w http.ResponseWriter, r *http.Request, name string, fi fs.FileInfo) {
+	f, err := h.fsys.Open(name)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	defer f.Close()
+	rs, ok := f.(io.ReadSeeker)
+	if !ok {
+		httpError(w, errors.New("file is not seekable"))
+		return
+	}
+	if d, err := h.fsys.FileDigest(name); err == nil {
+		w.Header().Set("ETag", `"sha256:`+hex.EncodeToString(d)+`"`)
+	}
+	http.ServeContent(w, r, fi.Name(), fi.ModTime(), rs)
+}
+
+// Redirect sends a redirect to the relative URL "to", keeping any query.
+func redirect(w http.ResponseWriter, r *http.Request, to string) {
+	if q := r.URL.RawQuery; q != "" {
+		to += "?" + q
+	}
+	w.Header().Set("Location", to)
+	w.WriteHeader(http.StatusMovedPermanently)
+}
+
+// HttpError writes an error response appropriate for "err". Like
+// [http.FileServer], the error itself isn't reported.
+func httpError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		http.Error(w, "404 page not found", http.StatusNotFound)
+	case errors.Is(err, fs.ErrPermission):
+		http.Error(w, "403 Forbidden", http.StatusForbidden)
+	default:
+		http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+	}
+}
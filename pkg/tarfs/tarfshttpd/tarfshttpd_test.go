@@ -0,0 +1,220 @@
+//go:build tarfshttpd
+
+package tarfshttpd
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/quay/claircore/pkg/tarfs"
+)
+
+// NewFS returns a tarfs.FS created from an archive with the members described
+// by "hs". Regular files have their names as contents.
+func newFS(t *testing.T, hs []tar.Header) *tarfs.FS {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for i := range hs {
+		h := &hs[i]
+		var b []byte
+		if h.Typeflag == tar.TypeReg {
+			b = []byte(h.Name)
+			h.Size = int64(len(b))
+		}
+		if h.Mode == 0 {
+			h.Mode = 0o644
+		}
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	sys, err := tarfs.New(bytes.NewReader(buf.Bytes()), tarfs.WithDigests())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sys
+}
+
+func TestServeFS(t *testing.T) {
+	t.Parallel()
+	root := newFS(t, []tar.Header{
+		{Name: "secret", Typeflag: tar.TypeReg},
+		{Name: "root/", Typeflag: tar.TypeDir, Mode: 0o755},
+		{Name: "root/file.txt", Typeflag: tar.TypeReg},
+		{Name: "root/data.json", Typeflag: tar.TypeReg},
+		{Name: "root/dir/", Typeflag: tar.TypeDir, Mode: 0o755},
+		{Name: "root/dir/nested", Typeflag: tar.TypeReg},
+		{Name: "root/link", Typeflag: tar.TypeSymlink, Linkname: "dir/nested"},
+		{Name: "root/dirlink", Typeflag: tar.TypeSymlink, Linkname: "dir"},
+		{Name: "root/escape", Typeflag: tar.TypeSymlink, Linkname: "../secret"},
+		{Name: "root/dir/up", Typeflag: tar.TypeSymlink, Linkname: "../../secret"},
+		{Name: "root/fifo", Typeflag: tar.TypeFifo},
+	})
+	sub, err := root.Sub("root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := ServeFS(sub.(*tarfs.FS))
+	sum := sha256.Sum256([]byte("root/file.txt"))
+	etag := `"sha256:` + hex.EncodeToString(sum[:]) + `"`
+
+	tt := []struct {
+		Name    string
+		Method  string
+		Path    string
+		Header  http.Header
+		Code    int
+		Body    string
+		Want    http.Header
+		Contain []string
+	}{
+		{
+			Name: "File",
+			Path: "/file.txt",
+			Code: http.StatusOK,
+			Body: "root/file.txt",
+			Want: http.Header{
+				"Content-Type": {"text/plain; charset=utf-8"},
+				"Etag":         {etag},
+			},
+		},
+		{
+			Name: "ContentType",
+			Path: "/data.json",
+			Code: http.StatusOK,
+			Want: http.Header{"Content-Type": {"application/json"}},
+		},
+		{
+			Name:   "Range",
+			Path:   "/file.txt",
+			Header: http.Header{"Range": {"bytes=5-8"}},
+			Code:   http.StatusPartialContent,
+			Body:   "file",
+			Want:   http.Header{"Content-Range": {"bytes 5-8/13"}},
+		},
+		{
+			Name:   "NotModified",
+			Path:   "/file.txt",
+			Header: http.Header{"If-None-Match": {etag}},
+			Code:   http.StatusNotModified,
+		},
+		{
+			Name:   "Head",
+			Method: http.MethodHead,
+			Path:   "/file.txt",
+			Code:   http.StatusOK,
+			Want:   http.Header{"Content-Length": {"13"}},
+		},
+		{
+			Name:    "Listing",
+			Path:    "/",
+			Code:    http.StatusOK,
+			Want:    http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+			Contain: []string{`<a href="file.txt">file.txt</a>`, `<a href="dir/">dir/</a>`},
+		},
+		{
+			Name: "Redirect",
+			Path: "/dir",
+			Code: http.StatusMovedPermanently,
+			Want: http.Header{"Location": {"dir/"}},
+		},
+		{
+			Name: "Symlink",
+			Path: "/link",
+			Code: http.StatusOK,
+			Body: "root/dir/nested",
+		},
+		{
+			Name: "SymlinkDir",
+			Path: "/dirlink/nested",
+			Code: http.StatusOK,
+			Body: "root/dir/nested",
+		},
+		{
+			Name: "Escape",
+			Path: "/escape",
+			Code: http.StatusForbidden,
+		},
+		{
+			Name: "EscapeNested",
+			Path: "/dirlink/up",
+			Code: http.StatusForbidden,
+		},
+		{
+			Name: "Traversal",
+			Path: "/../secret",
+			Code: http.StatusNotFound,
+		},
+		{
+			Name: "Missing",
+			Path: "/missing",
+			Code: http.StatusNotFound,
+		},
+		{
+			Name: "Fifo",
+			Path: "/fifo",
+			Code: http.StatusNotFound,
+		},
+		{
+			Name:   "Post",
+			Method: http.MethodPost,
+			Path:   "/file.txt",
+			Code:   http.StatusMethodNotAllowed,
+			Want:   http.Header{"Allow": {"GET, HEAD"}},
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			m := tc.Method
+			if m == "" {
+				m = http.MethodGet
+			}
+			req := httptest.NewRequest(m, "http://example.com"+tc.Path, nil)
+			for k, v := range tc.Header {
+				req.Header[k] = v
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			res := rec.Result()
+			defer res.Body.Close()
+			b, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := res.StatusCode, tc.Code; got != want {
+				t.Errorf("got status %d, want %d: %q", got, want, b)
+			}
+			if tc.Body != "" {
+				if got, want := string(b), tc.Body; got != want {
+					t.Errorf("got body %q, want %q", got, want)
+				}
+			}
+			for k := range tc.Want {
+				if got, want := res.Header.Get(k), tc.Want.Get(k); got != want {
+					t.Errorf("%s: got %q, want %q", k, got, want)
+				}
+			}
+			for _, s := range tc.Contain {
+				if !strings.Contains(string(b), s) {
+					t.Errorf("body missing %q:\n%s", s, b)
+				}
+			}
+		})
+	}
+}
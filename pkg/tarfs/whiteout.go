@@ -0,0 +1,116 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"path"
+	"sort"
+	"strings"
+)
+
+// WhiteoutMode controls how New treats "whiteout" entries, which are used in
+// container image layers to indicate that a path in a lower layer was
+// removed.
+type WhiteoutMode uint
+
+// These are the whiteout handling modes.
+const (
+	// WhiteoutModeNone treats whiteouts as ordinary files. This is the
+	// default.
+	WhiteoutModeNone WhiteoutMode = iota
+	// WhiteoutModeOCI recognizes whiteouts as described in the OCI image
+	// specification: files named ".wh.<name>" remove "<name>", and a file
+	// named ".wh..wh..opq" makes its directory opaque.
+	WhiteoutModeOCI
+	// WhiteoutModeAUFS recognizes whiteouts as WhiteoutModeOCI does, and
+	// additionally omits any AUFS metadata entries (those named with a
+	// ".wh..wh." prefix, like ".wh..wh.plnk") that aren't opaque markers.
+	WhiteoutModeAUFS
+)
+
+// These are the special names used by whiteouts.
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutMeta   = whiteoutPrefix + whiteoutPrefix
+	whiteoutOpaque = whiteoutMeta + ".opq"
+)
+
+// WithWhiteoutHandling configures how whiteout entries are treated.
+//
+// In modes other than WhiteoutModeNone, whiteout entries are still present in
+// the FS under their own names, but the [fs.FileInfo] values reported for them
+// return a *[Whiteout] from their Sys method. The [FS.Whiteouts] method can
+// be used to list all of them.
+func WithWhiteoutHandling(mode WhiteoutMode) Option {
+	return func(c *config) { c.whiteout = mode }
+}
+
+// Whiteout describes a whiteout entry.
+type Whiteout struct {
+	// Header is the header of the whiteout entry itself.
+	Header *tar.Header
+	// Target is the path removed by this whiteout. For opaque whiteouts, this
+	// is the directory made opaque.
+	Target string
+	// Opaque reports whether this is an opaque whiteout, meaning all entries
+	// in lower layers under Target are removed.
+	Opaque bool
+}
+
+// ParseWhiteout examines the (normalized) name and reports what should be
+// done with the entry: "skip" reports the entry should be omitted, and a
+// non-nil Whiteout is returned if the entry is a whiteout.
+func parseWhiteout(mode WhiteoutMode, h *tar.Header) (wh *Whiteout, skip bool) {
+	if mode == WhiteoutModeNone {
+		return nil, false
+	}
+	if mode == WhiteoutModeAUFS {
+		// AUFS metadata may be directories, so check every element.
+		for _, e := range strings.Split(path.Dir(h.Name), "/") {
+			if strings.HasPrefix(e, whiteoutMeta) {
+				return nil, true
+			}
+		}
+	}
+	dir, base := path.Split(h.Name)
+	dir = path.Clean(dir)
+	isReg := h.Typeflag == tar.TypeReg || h.Typeflag == tar.TypeRegA
+	switch {
+	case mode == WhiteoutModeAUFS && strings.HasPrefix(base, whiteoutMeta) && base != whiteoutOpaque:
+		return nil, true
+	case !isReg || !strings.HasPrefix(base, whiteoutPrefix):
+		// Whiteouts are only ever regular files.
+		return nil, false
+	case base == whiteoutOpaque:
+		return &Whiteout{Header: h, Target: dir, Opaque: true}, false
+	}
+	return &Whiteout{Header: h, Target: path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix))}, false
+}
+
+// Whiteouts returns all the whiteouts in the FS, sorted by Target.
+//
+// Whiteouts are only recognized if New was passed the [WithWhiteoutHandling]
+// Option. In an FS returned by Sub, Targets are relative to the root of that
+// FS and whiteouts with Targets outside of it are omitted.
+func (f *FS) Whiteouts() []Whiteout {
+	var ret []Whiteout
+	for _, i := range f.lookup {
+		wh := f.inode[i].wh
+		if wh == nil {
+			continue
+		}
+		tgt, ok := f.rel(wh.Target)
+		if !ok {
+			continue
+		}
+		w := *wh
+		w.Target = tgt
+		ret = append(ret, w)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Target == ret[j].Target {
+			return ret[i].Opaque && !ret[j].Opaque
+		}
+		return ret[i].Target < ret[j].Target
+	})
+	return ret
+}
@@ -0,0 +1,92 @@
+package tarfs
+
+import (
+	"archive/tar"
+	"encoding/base64"
+	"io/fs"
+	"net/url"
+	"strings"
+)
+
+// These are the PAX record prefixes for extended attributes.
+const (
+	// PaxSchilyXattr is used by GNU tar, star, and Go's archive/tar. The
+	// attribute value is stored as-is.
+	paxSchilyXattr = "SCHILY.xattr."
+	// PaxLibarchiveXattr is used by bsdtar. The attribute name is
+	// percent-encoded and the value is base64-encoded.
+	paxLibarchiveXattr = "LIBARCHIVE.xattr."
+)
+
+// Xattrs returns the extended attributes recorded in the archive for the named
+// file, such as "security.selinux" or "security.capability", following
+// symlinks. Values are returned as stored, and so may be binary.
+//
+// Attributes are read from both the "SCHILY.xattr." PAX records written by GNU
+// tar and Go's archive/tar, and the "LIBARCHIVE.xattr." records written by
+// bsdtar; if both name the same attribute, the SCHILY record wins. Records that
+// can't be decoded are skipped. A nil map is returned if the file has no
+// extended attributes. Other PAX records are available from the header
+// returned by [FileInfo.TarHeader].
+func (f *FS) Xattrs(name string) (map[string]string, error) {
+	return f.xattrs(name, 0)
+}
+
+// Xattrs is the implementation of Xattrs, tracking the number of symlinks
+// followed.
+func (f *FS) xattrs(name string, depth int) (map[string]string, error) {
+	const op = `xattrs`
+	if err := f.checkDepth(op, name, depth); err != nil {
+		return nil, err
+	}
+	i, err := f.getInode(op, name)
+	if err != nil {
+		return nil, err
+	}
+	if i.h.Typeflag == tar.TypeSymlink {
+		n, ok := f.rel(i.h.Linkname)
+		if !ok {
+			return nil, &fs.PathError{
+				Op:   op,
+				Path: name,
+				Err:  fs.ErrNotExist,
+			}
+		}
+		return f.xattrs(n, depth+1)
+	}
+	return parseXattrs(i.h.PAXRecords), nil
+}
+
+// ParseXattrs extracts the extended attributes from a header's PAX records.
+func parseXattrs(recs map[string]string) map[string]string {
+	var m map[string]string
+	set := func(k, v string) {
+		if m == nil {
+			m = make(map[string]string)
+		}
+		m[k] = v
+	}
+	for k, v := range recs {
+		n, ok := strings.CutPrefix(k, paxLibarchiveXattr)
+		if !ok {
+			continue
+		}
+		n, err := url.PathUnescape(n)
+		if err != nil || n == "" {
+			continue
+		}
+		// Libarchive doesn't write padding, but be tolerant of it.
+		b, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(v, "="))
+		if err != nil {
+			continue
+		}
+		set(n, string(b))
+	}
+	// Done second, so that SCHILY records take precedence.
+	for k, v := range recs {
+		if n, ok := strings.CutPrefix(k, paxSchilyXattr); ok && n != "" {
+			set(n, v)
+		}
+	}
+	return m
+}
@@ -0,0 +1,113 @@
+package zipfs
+
+import (
+	"io"
+	"io/fs"
+	"path"
+	"time"
+)
+
+var _ fs.File = (*file)(nil)
+
+// File implements fs.File.
+type file struct {
+	e  *entry
+	rc io.ReadCloser
+}
+
+func (f *file) Close() error               { return f.rc.Close() }
+func (f *file) Read(b []byte) (int, error) { return f.rc.Read(b) }
+func (f *file) Stat() (fs.FileInfo, error) { return fileInfo{f.e}, nil }
+
+var _ fs.ReadDirFile = (*dir)(nil)
+
+// Dir implements fs.ReadDirFile.
+type dir struct {
+	e   *entry
+	es  []fs.DirEntry
+	pos int
+}
+
+func (*dir) Close() error                 { return nil }
+func (d *dir) Stat() (fs.FileInfo, error) { return fileInfo{d.e}, nil }
+func (d *dir) Read(_ []byte) (int, error) {
+	return 0, &fs.PathError{
+		Op:   `read`,
+		Path: d.e.name,
+		Err:  fs.ErrInvalid,
+	}
+}
+
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if d.es == nil {
+		d.es = d.e.dirents()
+	}
+	es := d.es[d.pos:]
+	if len(es) == 0 {
+		if n <= 0 {
+			return nil, nil
+		}
+		return nil, io.EOF
+	}
+	end := len(es)
+	if n > 0 && n < end {
+		end = n
+	}
+	d.pos += end
+	return es[:end], nil
+}
+
+// Dirents returns the, already sorted, children of the entry.
+func (e *entry) dirents() []fs.DirEntry {
+	ret := make([]fs.DirEntry, len(e.children))
+	for i, c := range e.children {
+		ret[i] = fs.FileInfoToDirEntry(fileInfo{c})
+	}
+	return ret
+}
+
+var _ fs.FileInfo = fileInfo{}
+
+// FileInfo is the [fs.FileInfo] for members of an FS.
+//
+// The Sys method reports the member's *zip.FileHeader, or nil for
+// synthesized directories.
+type fileInfo struct {
+	e *entry
+}
+
+func (fi fileInfo) Name() string { return path.Base(fi.e.name) }
+func (fi fileInfo) IsDir() bool  { return fi.e.dir }
+func (fi fileInfo) ModTime() time.Time {
+	if fi.e.f == nil {
+		return time.Time{}
+	}
+	return fi.e.f.Modified
+}
+
+func (fi fileInfo) Size() int64 {
+	if fi.e.f == nil || fi.e.dir {
+		return 0
+	}
+	return int64(fi.e.f.UncompressedSize64)
+}
+
+// Mode reports the permission bits recorded in the member, and only the
+// directory type bit; see [FS].
+func (fi fileInfo) Mode() fs.FileMode {
+	if fi.e.f == nil {
+		return fs.ModeDir | 0o755
+	}
+	m := fi.e.f.Mode().Perm()
+	if fi.e.dir {
+		m |= fs.ModeDir
+	}
+	return m
+}
+
+func (fi fileInfo) Sys() any {
+	if fi.e.f == nil {
+		return nil
+	}
+	return &fi.e.f.FileHeader
+}
@@ -0,0 +1,277 @@
+// Package zipfs implements the fs.FS interface over a zip archive.
+//
+// This is a companion to [tarfs], so that the contents of zip-based archives
+// (such as Java archives or some OCI artifacts) can be handled the same way as
+// the contents of container image layers.
+package zipfs
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/quay/claircore/pkg/tarfs"
+)
+
+// ErrMaliciousPath can be compared via [errors.Is] against errors reported by
+// [New] to determine if the archive was rejected because of a member name that
+// attempts to traverse outside of the archive root ("zip slip").
+//
+// This is the same value as [tarfs.ErrMaliciousPath], so errors from either
+// package can be checked against either, and errors that match
+// ErrMaliciousPath also match [fs.ErrInvalid].
+var ErrMaliciousPath = tarfs.ErrMaliciousPath
+
+// ErrNoSymlink is returned (wrapped) by [FS.ReadLink]. Errors that match
+// ErrNoSymlink also match [fs.ErrInvalid], like the error [tarfs.FS.ReadLink]
+// returns for files that aren't symlinks.
+var ErrNoSymlink = fmt.Errorf("zipfs: not a symlink: %w", fs.ErrInvalid)
+
+// FS implements a filesystem abstraction over an io.ReaderAt containing a zip.
+//
+// Zip archives have no portable notion of symlinks, so every member is
+// presented as either a regular file or a directory. Members recorded with a
+// symlink mode by Unix tools are presented as regular files containing the
+// link target. Directories that are implied by member names but have no
+// member of their own are synthesized.
+type FS struct {
+	lookup map[string]*entry
+}
+
+// Entry is a member of the archive, or a synthesized directory.
+type entry struct {
+	// Name is the normalized name, relative to the archive root.
+	name string
+	// F is nil for synthesized directories.
+	f        *zip.File
+	dir      bool
+	children []*entry
+}
+
+// New creates an FS from the zip contained in the ReaderAt, which is "size"
+// bytes long.
+//
+// Member names are normalized: backslashes are treated as separators, as some
+// Windows tools write them, and absolute names are interpreted relative to the
+// archive root. Archives with names that still escape the root after cleaning
+// are rejected with an error matching [ErrMaliciousPath]. If the archive
+// contains multiple members with the same name, the last one is used.
+//
+// The ReaderAt must remain valid for the entire life of the returned FS.
+func New(r io.ReaderAt, size int64) (*FS, error) {
+	z, err := zip.NewReader(r, size)
+	// The zip package only reports ErrInsecurePath if asked to by the
+	// "zipinsecurepath" GODEBUG setting; the names are checked below either
+	// way.
+	if err != nil && !errors.Is(err, zip.ErrInsecurePath) {
+		return nil, fmt.Errorf("zipfs: unable to read archive: %w", err)
+	}
+	f := FS{
+		lookup: map[string]*entry{
+			".": {name: ".", dir: true},
+		},
+	}
+	for _, zf := range z.File {
+		name, err := normName(zf.Name)
+		if err != nil {
+			return nil, err
+		}
+		if name == "." {
+			continue
+		}
+		isDir := strings.HasSuffix(zf.Name, "/") || zf.Mode().IsDir()
+		if err := f.add(name, zf, isDir); err != nil {
+			return nil, err
+		}
+	}
+	for _, e := range f.lookup {
+		sort.Slice(e.children, func(i, j int) bool {
+			return e.children[i].name < e.children[j].name
+		})
+	}
+	return &f, nil
+}
+
+// NormName returns the normalized form of the member name "n", or an error if
+// it escapes the archive root.
+func normName(n string) (string, error) {
+	c := path.Clean(strings.ReplaceAll(n, `\`, "/"))
+	if c == ".." || strings.HasPrefix(c, "../") {
+		return "", &fs.PathError{
+			Op:   `create`,
+			Path: n,
+			Err:  fmt.Errorf("member name escapes archive root: %w", ErrMaliciousPath),
+		}
+	}
+	c = strings.TrimPrefix(c, "/")
+	if c == "" {
+		c = "."
+	}
+	return c, nil
+}
+
+// Add adds the member "zf" at "name", synthesizing any missing parent
+// directories.
+func (f *FS) add(name string, zf *zip.File, isDir bool) error {
+	if e, ok := f.lookup[name]; ok {
+		if e.dir != isDir {
+			return &fs.PathError{
+				Op:   `create`,
+				Path: zf.Name,
+				Err:  fmt.Errorf("conflicting file and directory members: %w", fs.ErrExist),
+			}
+		}
+		e.f = zf
+		return nil
+	}
+	e := &entry{name: name, f: zf, dir: isDir}
+	f.lookup[name] = e
+	for {
+		dir := path.Dir(e.name)
+		p, ok := f.lookup[dir]
+		switch {
+		case !ok:
+			p = &entry{name: dir, dir: true}
+			f.lookup[dir] = p
+		case !p.dir:
+			return &fs.PathError{
+				Op:   `create`,
+				Path: zf.Name,
+				Err:  fmt.Errorf("parent %q is not a directory: %w", dir, fs.ErrExist),
+			}
+		}
+		p.children = append(p.children, e)
+		if ok {
+			return nil
+		}
+		e = p
+	}
+}
+
+// GetEntry returns the entry for "name", or an error suitable for returning
+// from the fs interfaces.
+func (f *FS) getEntry(op, name string) (*entry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{
+			Op:   op,
+			Path: name,
+			Err:  fs.ErrInvalid,
+		}
+	}
+	e, ok := f.lookup[name]
+	if !ok {
+		return nil, &fs.PathError{
+			Op:   op,
+			Path: name,
+			Err:  fs.ErrNotExist,
+		}
+	}
+	return e, nil
+}
+
+// Open implements fs.FS.
+func (f *FS) Open(name string) (fs.File, error) {
+	const op = `open`
+	e, err := f.getEntry(op, name)
+	if err != nil {
+		return nil, err
+	}
+	if e.dir {
+		return &dir{e: e}, nil
+	}
+	rc, err := e.f.Open()
+	if err != nil {
+		return nil, &fs.PathError{
+			Op:   op,
+			Path: name,
+			Err:  err,
+		}
+	}
+	return &file{e: e, rc: rc}, nil
+}
+
+// Stat implements fs.StatFS.
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	const op = `stat`
+	e, err := f.getEntry(op, name)
+	if err != nil {
+		return nil, err
+	}
+	return fileInfo{e}, nil
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	const op = `readdir`
+	e, err := f.getEntry(op, name)
+	if err != nil {
+		return nil, err
+	}
+	if !e.dir {
+		return nil, &fs.PathError{
+			Op:   op,
+			Path: name,
+			Err:  fmt.Errorf("not a directory: %w", fs.ErrInvalid),
+		}
+	}
+	return e.dirents(), nil
+}
+
+// ReadFile implements fs.ReadFileFS.
+func (f *FS) ReadFile(name string) ([]byte, error) {
+	const op = `readfile`
+	e, err := f.getEntry(op, name)
+	if err != nil {
+		return nil, err
+	}
+	if e.dir {
+		return nil, &fs.PathError{
+			Op:   op,
+			Path: name,
+			Err:  fmt.Errorf("is a directory: %w", fs.ErrInvalid),
+		}
+	}
+	rc, err := e.f.Open()
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	defer rc.Close()
+	// The recorded size isn't trustworthy, so don't use it to preallocate.
+	b, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return b, nil
+}
+
+// ReadLink returns an error wrapping [ErrNoSymlink] for every file that
+// exists, as zip archives have no symlinks.
+//
+// With Go 1.25 or later, ReadLink and Lstat implement fs.ReadLinkFS.
+func (f *FS) ReadLink(name string) (string, error) {
+	const op = `readlink`
+	if _, err := f.getEntry(op, name); err != nil {
+		return "", err
+	}
+	return "", &fs.PathError{
+		Op:   op,
+		Path: name,
+		Err:  ErrNoSymlink,
+	}
+}
+
+// Lstat returns an [fs.FileInfo] describing the named file. As there are no
+// symlinks, this is the same as Stat.
+func (f *FS) Lstat(name string) (fs.FileInfo, error) {
+	const op = `lstat`
+	e, err := f.getEntry(op, name)
+	if err != nil {
+		return nil, err
+	}
+	return fileInfo{e}, nil
+}
@@ -0,0 +1,183 @@
+package zipfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/quay/claircore/pkg/tarfs"
+)
+
+// MkZip returns a zip archive containing the members described by "hs".
+// Regular files have their names as contents.
+func mkZip(t *testing.T, hs []zip.FileHeader) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for i := range hs {
+		h := &hs[i]
+		if h.Modified.IsZero() {
+			h.Modified = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		}
+		if h.Method == 0 && h.Name[len(h.Name)-1] != '/' {
+			h.Method = zip.Deflate
+		}
+		w, err := zw.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.Name[len(h.Name)-1] == '/' {
+			continue
+		}
+		if _, err := io.WriteString(w, h.Name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func mkFS(t *testing.T, hs []zip.FileHeader) *FS {
+	t.Helper()
+	b := mkZip(t, hs)
+	sys, err := New(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sys
+}
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+	sys := mkFS(t, []zip.FileHeader{
+		{Name: "META-INF/"},
+		{Name: "META-INF/MANIFEST.MF"},
+		{Name: "com/example/App.class"},
+		{Name: "stored.txt", Method: zip.Store},
+		{Name: "/abs/file"},
+		{Name: `win\style\path`},
+	})
+	if err := fstest.TestFS(sys,
+		"META-INF/MANIFEST.MF",
+		"com/example/App.class",
+		"stored.txt",
+		"abs/file",
+		"win/style/path",
+	); err != nil {
+		t.Error(err)
+	}
+	b, err := fs.ReadFile(sys, "stored.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "stored.txt"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+
+	fi, err := sys.Stat("com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.IsDir() || fi.Sys() != nil {
+		t.Errorf("synthesized directory: got: %v, %v", fi.Mode(), fi.Sys())
+	}
+	fi, err = sys.Stat("META-INF/MANIFEST.MF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fi.Sys().(*zip.FileHeader); !ok {
+		t.Errorf("got Sys: %T, want: *zip.FileHeader", fi.Sys())
+	}
+}
+
+func TestMaliciousPath(t *testing.T) {
+	t.Parallel()
+	for _, n := range []string{
+		"../evil",
+		"a/../../evil",
+		`..\evil`,
+	} {
+		b := mkZip(t, []zip.FileHeader{{Name: "ok"}, {Name: n}})
+		_, err := New(bytes.NewReader(b), int64(len(b)))
+		if !errors.Is(err, ErrMaliciousPath) || !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("%q: unexpected error: %v", n, err)
+		}
+		// Should be the same sentinel as tarfs.
+		if !errors.Is(err, tarfs.ErrMaliciousPath) {
+			t.Errorf("%q: error doesn't match tarfs.ErrMaliciousPath: %v", n, err)
+		}
+	}
+}
+
+func TestSymlink(t *testing.T) {
+	t.Parallel()
+	link := zip.FileHeader{Name: "link"}
+	link.SetMode(fs.ModeSymlink | 0o777)
+	sys := mkFS(t, []zip.FileHeader{
+		{Name: "dir/"},
+		{Name: "file"},
+		link,
+	})
+	for _, n := range []string{"dir", "file", "link", "."} {
+		_, err := sys.ReadLink(n)
+		if !errors.Is(err, ErrNoSymlink) || !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("%s: unexpected error: %v", n, err)
+		}
+	}
+	if _, err := sys.ReadLink("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	fi, err := sys.Lstat("link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fi.Mode(), fs.FileMode(0o777); got != want {
+		t.Errorf("got: %v, want: %v", got, want)
+	}
+	if err := fstest.TestFS(sys, "dir", "file", "link"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConflict(t *testing.T) {
+	t.Parallel()
+	for _, hs := range [][]zip.FileHeader{
+		{{Name: "a"}, {Name: "a/"}},
+		{{Name: "a/"}, {Name: "a"}},
+		{{Name: "a"}, {Name: "a/b"}},
+	} {
+		b := mkZip(t, hs)
+		if _, err := New(bytes.NewReader(b), int64(len(b))); !errors.Is(err, fs.ErrExist) {
+			t.Errorf("%s, %s: unexpected error: %v", hs[0].Name, hs[1].Name, err)
+		}
+	}
+
+	// Duplicate files use the last member.
+	b := mkZip(t, []zip.FileHeader{{Name: "a", Comment: "first"}, {Name: "a", Comment: "second"}})
+	sys, err := New(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := sys.Stat("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fi.Sys().(*zip.FileHeader).Comment, "second"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+}
+
+func TestNotZip(t *testing.T) {
+	t.Parallel()
+	b := []byte("not a zip file")
+	if _, err := New(bytes.NewReader(b), int64(len(b))); !errors.Is(err, zip.ErrFormat) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -6,7 +6,10 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/csv"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"net/textproto"
 	"path"
@@ -46,7 +49,7 @@ type Scanner struct{}
 func (*Scanner) Name() string { return "python" }
 
 // Version implements scanner.VersionedScanner.
-func (*Scanner) Version() string { return "3" }
+func (*Scanner) Version() string { return "4" }
 
 // Kind implements scanner.VersionedScanner.
 func (*Scanner) Kind() string { return "package" }
@@ -109,7 +112,12 @@ func (ps *Scanner) Scan(ctx context.Context, layer *claircore.Layer) ([]*clairco
 		if strings.HasSuffix(n, `.egg-info`) {
 			pkgDB = filepath.Join(n, "..")
 		}
+		var files []string
+		if strings.HasSuffix(n, `.dist-info/METADATA`) {
+			files = recordFiles(ctx, sys, path.Join(path.Dir(n), "RECORD"), pkgDB)
+		}
 		ret = append(ret, &claircore.Package{
+			Files:             files,
 			Name:              strings.ToLower(hdr.Get("Name")),
 			Version:           v.String(),
 			PackageDB:         "python:" + pkgDB,
@@ -124,6 +132,45 @@ func (ps *Scanner) Scan(ctx context.Context, layer *claircore.Layer) ([]*clairco
 	return ret, nil
 }
 
+// RecordFiles reads the paths from the wheel RECORD file "n", which are
+// relative to the directory "site".
+//
+// See https://packaging.python.org/en/latest/specifications/recording-installed-packages/#the-record-file
+func recordFiles(ctx context.Context, sys fs.FS, n, site string) []string {
+	f, err := sys.Open(n)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	rd := csv.NewReader(f)
+	rd.FieldsPerRecord = -1
+	rd.ReuseRecord = true
+	var out []string
+	for {
+		rec, err := rd.Read()
+		switch {
+		case errors.Is(err, nil):
+		case errors.Is(err, io.EOF):
+			return out
+		default:
+			zlog.Warn(ctx).
+				Err(err).
+				Str("path", n).
+				Msg("unable to read RECORD, skipping")
+			return nil
+		}
+		if len(rec) == 0 || rec[0] == "" {
+			continue
+		}
+		p := rec[0]
+		if !path.IsAbs(p) {
+			p = path.Join(site, p)
+		}
+		p = strings.TrimPrefix(path.Clean("/"+p), "/")
+		out = append(out, p)
+	}
+}
+
 // DefaultRepository implements [indexer.DefaultRepoScanner]
 func (Scanner) DefaultRepository(ctx context.Context) *claircore.Repository {
 	return &Repository
@@ -0,0 +1,112 @@
+package rhel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore/libvuln/driver"
+)
+
+// CacheFile is the name of the file in the directory passed to WithCache.
+const cacheFile = `rhel-oval-cache.json`
+
+// CacheMu serializes access to cache files, which are commonly shared by all
+// the Updaters in a process.
+var cacheMu sync.Mutex
+
+// WithCache configures the Updater to remember the "ETag" and "Last-Modified"
+// headers of OVAL database responses in a file in the directory "dir", which
+// is created if needed.
+//
+// The file is a JSON object keyed by database URL. When Fetch is called
+// without a hint, the remembered values are used to make a conditional
+// request, so an unchanged database results in a [driver.Unchanged] error
+// instead of being downloaded and parsed again. This is only useful when the
+// caller doesn't already keep track of the returned [driver.Fingerprint], as
+// the updater machinery in libvuln does.
+func WithCache(dir string) Option {
+	return func(u *Updater) error {
+		if dir == "" {
+			return errors.New("rhel: empty cache directory")
+		}
+		u.cacheDir = dir
+		return nil
+	}
+}
+
+// CachedHint returns the fingerprint remembered for the Updater's URL, if any.
+func (u *Updater) cachedHint(ctx context.Context) driver.Fingerprint {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	c, err := readCache(u.cacheDir)
+	if err != nil {
+		zlog.Warn(ctx).Err(err).Msg("unable to read cache")
+		return ""
+	}
+	return driver.Fingerprint(c[u.Fetcher.URL.String()])
+}
+
+// StoreHint remembers the fingerprint for the Updater's URL.
+func (u *Updater) storeHint(ctx context.Context, fp driver.Fingerprint) {
+	if !json.Valid([]byte(fp)) {
+		return
+	}
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	err := func() error {
+		c, err := readCache(u.cacheDir)
+		if err != nil {
+			return err
+		}
+		c[u.Fetcher.URL.String()] = json.RawMessage(fp)
+		b, err := json.Marshal(c)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(u.cacheDir, 0o755); err != nil {
+			return err
+		}
+		// Write and rename, so that other processes never see a partial
+		// file.
+		f, err := os.CreateTemp(u.cacheDir, cacheFile+".")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(f.Name())
+		if _, err := f.Write(b); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		return os.Rename(f.Name(), filepath.Join(u.cacheDir, cacheFile))
+	}()
+	if err != nil {
+		zlog.Warn(ctx).Err(err).Msg("unable to write cache")
+	}
+}
+
+// ReadCache reads the cache file in "dir". A missing file is not an error.
+func readCache(dir string) (map[string]json.RawMessage, error) {
+	c := make(map[string]json.RawMessage)
+	b, err := os.ReadFile(filepath.Join(dir, cacheFile))
+	switch {
+	case errors.Is(err, nil):
+	case errors.Is(err, fs.ErrNotExist):
+		return c, nil
+	default:
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,525 @@
+package rhel
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/quay/zlog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/pkg/errs"
+	"github.com/quay/claircore/pkg/tmp"
+	"github.com/quay/claircore/rhel/internal/common"
+	"github.com/quay/claircore/rhel/internal/csaf"
+	"github.com/quay/claircore/toolkit/types/cpe"
+)
+
+// DefaultCSAFProviderMetadata is the location of Red Hat's CSAF provider
+// metadata document.
+const DefaultCSAFProviderMetadata = `https://access.redhat.com/security/data/csaf/v2/provider-metadata.json`
+
+// ParseCSAF is like [Updater.Parse], but treats the data inside the provided
+// io.ReadCloser as a sequence of Red Hat CSAF or VEX JSON documents, as
+// returned by [FetchCSAF].
+//
+// The returned vulnerabilities have the same shape as the ones produced from
+// OVAL: one per advisory, package, and repository CPE. The Severity is the
+// document's aggregate severity, unless the Updater was configured with
+// WithCVSSSeverity. Every distinct vector in the document is reported in the
+// CVSS list, as for OVAL.
+//
+// Unpatched products are reported with the state from the document's
+// remediations: "no_fix_planned" is "will not fix", and "none_available" or no
+// remediation is "affected". Products under investigation are reported as
+// well. If the Updater was configured with WithUnpatchedStates, unpatched
+// products in other states are skipped.
+//
+// If the Updater was configured with WithCPEFilter, products on platforms
+// without a matching CPE are skipped.
+func (u *Updater) ParseCSAF(ctx context.Context, r io.ReadCloser) ([]*claircore.Vulnerability, error) {
+	ctx = zlog.ContextWithValues(ctx, "component", "rhel/Updater.ParseCSAF")
+	ctx, span := u.getTracer().Start(ctx, "rhel.updater.parse",
+		trace.WithAttributes(attribute.String("updater", u.name), attribute.String("format", "csaf")))
+	defer span.End()
+	zlog.Info(ctx).Msg("starting parse")
+	defer r.Close()
+
+	var vs []*claircore.Vulnerability
+	dec := json.NewDecoder(r)
+	docs := 0
+	for {
+		var doc csaf.Document
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "decode error")
+			return nil, fmt.Errorf("rhel: unable to decode CSAF document: %w", &errs.ParseError{Offset: dec.InputOffset(), Err: err})
+		}
+		docs++
+		dvs, err := u.csafVulns(&doc)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "conversion error")
+			return nil, fmt.Errorf("rhel: %s: %w", doc.Document.Tracking.ID, err)
+		}
+		vs = append(vs, dvs...)
+	}
+	span.SetAttributes(
+		attribute.Int("definitions", docs),
+		attribute.Int("vulnerabilities", len(vs)),
+	)
+	span.SetStatus(codes.Ok, "")
+	zlog.Debug(ctx).
+		Int("documents", docs).
+		Int("vulnerabilities", len(vs)).
+		Msg("parsed CSAF documents")
+	return vs, nil
+}
+
+// CsafVulns converts a single CSAF document.
+func (u *Updater) csafVulns(doc *csaf.Document) ([]*claircore.Vulnerability, error) {
+	products := doc.Products()
+	rels := make(map[string]*csaf.Relationship, len(doc.ProductTree.Relationships))
+	for i := range doc.ProductTree.Relationships {
+		r := &doc.ProductTree.Relationships[i]
+		rels[r.FullProductName.ProductID] = r
+	}
+
+	// Collapse per-arch products into one record, like the OVAL feeds.
+	type key struct {
+		Name, Module, Fixed, CPE string
+		State                    claircore.FixState
+	}
+	arches := make(map[key]map[string]struct{})
+	var repoErr error
+	add := func(id string, state claircore.FixState) {
+		rel, ok := rels[id]
+		if !ok {
+			return
+		}
+		comp, plat := products[rel.ProductReference], products[rel.RelatesTo]
+		if comp == nil || plat == nil || comp.Helper.PURL == "" || plat.Helper.CPE == "" {
+			return
+		}
+		p, err := parseRPMPURL(comp.Helper.PURL)
+		if err != nil || p.Arch == "src" {
+			return
+		}
+		if u.cpeFilter != nil && !u.cpeFilter(plat.Helper.CPE) {
+			return
+		}
+		if _, err := cpe.Unbind(plat.Helper.CPE); err != nil {
+			repoErr = errors.Join(repoErr, err)
+			return
+		}
+		k := key{Name: p.Name, Module: p.Module, CPE: plat.Helper.CPE, State: state}
+		if state == claircore.FixStateFixed {
+			k.Fixed = p.EVR()
+		}
+		as, ok := arches[k]
+		if !ok {
+			as = make(map[string]struct{})
+			arches[k] = as
+		}
+		if p.Arch != "" {
+			as[p.Arch] = struct{}{}
+		}
+	}
+	for i := range doc.Vulnerabilities {
+		v := &doc.Vulnerabilities[i]
+		for _, id := range v.ProductStatus.Fixed {
+			add(id, claircore.FixStateFixed)
+		}
+		states := remediationStates(v)
+		for _, id := range v.ProductStatus.KnownAffected {
+			s, ok := states[id]
+			if !ok {
+				s = claircore.FixStateAffected
+			}
+			if u.keepUnpatched(s) {
+				add(id, s)
+			}
+		}
+		if u.keepUnpatched(claircore.FixStateUnderInvestigation) {
+			for _, id := range v.ProductStatus.UnderInvestigation {
+				add(id, claircore.FixStateUnderInvestigation)
+			}
+		}
+	}
+	if repoErr != nil {
+		return nil, repoErr
+	}
+
+	proto := claircore.Vulnerability{
+		Updater:            u.Name(),
+		Name:               csafName(doc),
+		Description:        csafDescription(doc),
+		Issued:             doc.Document.Tracking.InitialReleaseDate,
+		Links:              csafLinks(doc),
+		Dist:               u.dist,
+		NormalizedSeverity: common.NormalizeSeverity(doc.Document.AggregateSeverity.Text),
+	}
+	proto.Severity = doc.Document.AggregateSeverity.Text
+	scores := csafScores(doc)
+	scores.Apply(&proto)
+	if s, ok := scores.Preferred(); ok {
+		if u.cvssSeverity {
+			proto.Severity = s.String()
+		}
+		if proto.NormalizedSeverity == claircore.Unknown {
+			proto.NormalizedSeverity = cvssSeverity(s.Severity)
+		}
+	}
+
+	ks := make([]key, 0, len(arches))
+	for k := range arches {
+		ks = append(ks, k)
+	}
+	sort.Slice(ks, func(i, j int) bool {
+		a, b := ks[i], ks[j]
+		switch {
+		case a.CPE != b.CPE:
+			return a.CPE < b.CPE
+		case a.Name != b.Name:
+			return a.Name < b.Name
+		case a.Module != b.Module:
+			return a.Module < b.Module
+		case a.Fixed != b.Fixed:
+			return a.Fixed < b.Fixed
+		}
+		return a.State < b.State
+	})
+	vs := make([]*claircore.Vulnerability, 0, len(ks))
+	for _, k := range ks {
+		v := proto
+		// Checked above.
+		wfn, _ := cpe.Unbind(k.CPE)
+		v.Repo = &claircore.Repository{
+			Name: k.CPE,
+			CPE:  wfn,
+			Key:  repositoryKey,
+		}
+		v.Package = &claircore.Package{
+			Name:   k.Name,
+			Module: k.Module,
+			Kind:   claircore.BINARY,
+		}
+		v.FixedInVersion = k.Fixed
+		v.FixState = k.State
+		if as := arches[k]; len(as) != 0 {
+			l := make([]string, 0, len(as))
+			for a := range as {
+				l = append(l, a)
+			}
+			sort.Strings(l)
+			v.Package.Arch = strings.Join(l, "|")
+			v.ArchOperation = claircore.OpPatternMatch
+		}
+		vs = append(vs, &v)
+	}
+	return vs, nil
+}
+
+// FetchCSAF fetches all the documents listed by the CSAF provider metadata at
+// "metadata" and returns them concatenated in a temporary file, suitable for
+// passing to [Updater.ParseCSAF].
+//
+// Only distributions using the "directory" scheme are supported: the
+// "index.txt" file in each directory is used to enumerate the documents.
+func FetchCSAF(ctx context.Context, c *http.Client, metadata string) (io.ReadCloser, error) {
+	ctx = zlog.ContextWithValues(ctx, "component", "rhel/FetchCSAF")
+	base, err := url.Parse(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("rhel: bad CSAF provider metadata URL: %w", err)
+	}
+	var md csafProviderMetadata
+	if err := csafGetJSON(ctx, c, base.String(), &md); err != nil {
+		return nil, err
+	}
+
+	tf, err := tmp.NewFile("", "csaf.")
+	if err != nil {
+		return nil, err
+	}
+	success := false
+	defer func() {
+		if !success {
+			if err := tf.Close(); err != nil {
+				zlog.Warn(ctx).Err(err).Msg("failed to close tempfile")
+			}
+		}
+	}()
+
+	ct := 0
+	for _, d := range md.Distributions {
+		if d.DirectoryURL == "" {
+			continue
+		}
+		dir, err := base.Parse(d.DirectoryURL)
+		if err != nil {
+			return nil, fmt.Errorf("rhel: bad CSAF directory URL: %w", err)
+		}
+		if !strings.HasSuffix(dir.Path, "/") {
+			dir.Path += "/"
+		}
+		idx, err := dir.Parse("index.txt")
+		if err != nil {
+			return nil, err
+		}
+		res, err := csafGet(ctx, c, idx.String())
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		s := bufio.NewScanner(res.Body)
+		for s.Scan() {
+			if l := strings.TrimSpace(s.Text()); l != "" {
+				names = append(names, l)
+			}
+		}
+		res.Body.Close()
+		if err := s.Err(); err != nil {
+			return nil, fmt.Errorf("rhel: error reading %q: %w", idx, err)
+		}
+		zlog.Debug(ctx).
+			Stringer("directory", dir).
+			Int("count", len(names)).
+			Msg("found CSAF documents")
+
+		for _, n := range names {
+			u, err := dir.Parse(n)
+			if err != nil {
+				return nil, fmt.Errorf("rhel: bad CSAF document name %q: %w", n, err)
+			}
+			res, err := csafGet(ctx, c, u.String())
+			if err != nil {
+				return nil, err
+			}
+			_, err = io.Copy(tf, res.Body)
+			res.Body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("rhel: error reading %q: %w", u, err)
+			}
+			// Make sure documents are separated.
+			if _, err := io.WriteString(tf, "\n"); err != nil {
+				return nil, err
+			}
+			ct++
+		}
+	}
+	if _, err := tf.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	zlog.Info(ctx).
+		Int("count", ct).
+		Msg("fetched CSAF documents")
+	success = true
+	return tf, nil
+}
+
+// CsafGet issues a GET request, returning an error for any non-200 response.
+func csafGet(ctx context.Context, c *http.Client, u string) (*http.Response, error) {
+	res, err := csaf.Get(ctx, c, u)
+	if err != nil {
+		return nil, fmt.Errorf("rhel: %w", err)
+	}
+	return res, nil
+}
+
+// CsafGetJSON GETs the URL and decodes the body into "v".
+func csafGetJSON(ctx context.Context, c *http.Client, u string, v interface{}) error {
+	res, err := csafGet(ctx, c, u)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return fmt.Errorf("rhel: unable to decode %q: %w", u, &errs.ParseError{Offset: -1, Err: err})
+	}
+	return nil
+}
+
+// CsafProviderMetadata is the subset of the CSAF provider metadata document
+// that's needed to enumerate documents.
+type csafProviderMetadata struct {
+	Distributions []struct {
+		DirectoryURL string `json:"directory_url"`
+	} `json:"distributions"`
+}
+
+// RemediationStates reports the FixState of the affected products named in
+// the vulnerability's remediations. Red Hat uses the "no_fix_planned" category
+// for "Will not fix" and "Out of support scope", and "none_available" for
+// "Affected" and "Fix deferred".
+func remediationStates(v *csaf.Vulnerability) map[string]claircore.FixState {
+	var m map[string]claircore.FixState
+	for _, r := range v.Remediations {
+		var s claircore.FixState
+		switch r.Category {
+		case "no_fix_planned":
+			s = claircore.FixStateWillNotFix
+		case "none_available":
+			s = claircore.FixStateAffected
+		default:
+			continue
+		}
+		if m == nil {
+			m = make(map[string]claircore.FixState)
+		}
+		for _, id := range r.ProductIDs {
+			m[id] = s
+		}
+	}
+	return m
+}
+
+// CsafName reports a name in the same style as the OVAL definition titles.
+func csafName(d *csaf.Document) string {
+	t := strings.TrimPrefix(d.Document.Title, "Red Hat Security Advisory: ")
+	n := d.Document.Tracking.ID + ": " + t
+	if s := d.Document.AggregateSeverity.Text; s != "" {
+		n += " (" + s + ")"
+	}
+	return n
+}
+
+// CsafDescription reports the "general" note, falling back to the "summary".
+func csafDescription(d *csaf.Document) string {
+	var sum string
+	for _, n := range d.Document.Notes {
+		switch n.Category {
+		case "general":
+			return n.Text
+		case "summary":
+			sum = n.Text
+		}
+	}
+	return sum
+}
+
+// CsafLinks reports the unique reference URLs in the document, space
+// separated.
+func csafLinks(d *csaf.Document) string {
+	seen := make(map[string]struct{})
+	var ls []string
+	add := func(rs []csaf.Reference) {
+		for _, r := range rs {
+			if _, ok := seen[r.URL]; ok || r.URL == "" {
+				continue
+			}
+			seen[r.URL] = struct{}{}
+			ls = append(ls, r.URL)
+		}
+	}
+	add(d.Document.References)
+	for _, v := range d.Vulnerabilities {
+		add(v.References)
+	}
+	return strings.Join(ls, " ")
+}
+
+// CsafScores reports every CVSS vector in the document.
+func csafScores(d *csaf.Document) (c cvssScores) {
+	for _, v := range d.Vulnerabilities {
+		for _, s := range v.Scores {
+			if s := s.CVSSv4; s != nil {
+				c.Add(4, csafScoredVector(s))
+			}
+			if s := s.CVSSv3; s != nil {
+				c.Add(3, csafScoredVector(s))
+			}
+			if s := s.CVSSv2; s != nil {
+				c.Add(2, csafScoredVector(s))
+			}
+		}
+	}
+	return c
+}
+
+func csafScoredVector(c *csaf.CVSS) scoredVector {
+	return scoredVector{Vector: c.VectorString, Score: c.BaseScore, Severity: c.BaseSeverity}
+}
+
+// CvssSeverity maps a CVSS v3 qualitative severity to a claircore.Severity.
+func cvssSeverity(s string) claircore.Severity {
+	switch strings.ToLower(s) {
+	case "low":
+		return claircore.Low
+	case "medium":
+		return claircore.Medium
+	case "high":
+		return claircore.High
+	case "critical":
+		return claircore.Critical
+	}
+	return claircore.Unknown
+}
+
+// RpmPURL is the information from an rpm package URL.
+type rpmPURL struct {
+	Name    string
+	Version string
+	Epoch   string
+	Arch    string
+	Module  string
+}
+
+// EVR reports the package's version in "epoch:version-release" form.
+func (p *rpmPURL) EVR() string {
+	if p.Version == "" {
+		return ""
+	}
+	e := p.Epoch
+	if e == "" {
+		e = "0"
+	}
+	return e + ":" + p.Version
+}
+
+// ParseRPMPURL parses a package URL of the form:
+//
+//	pkg:rpm/redhat/name@version-release?arch=x86_64&epoch=1&rpmmod=name:stream:version:context
+func parseRPMPURL(s string) (*rpmPURL, error) {
+	rest, ok := strings.CutPrefix(s, "pkg:rpm/")
+	if !ok {
+		return nil, fmt.Errorf("rhel: not an rpm purl: %q", s)
+	}
+	rest, qs, _ := strings.Cut(rest, "?")
+	rest, _, _ = strings.Cut(rest, "#")
+	q, err := url.ParseQuery(qs)
+	if err != nil {
+		return nil, fmt.Errorf("rhel: bad purl %q: %w", s, err)
+	}
+	rest, ver, _ := strings.Cut(rest, "@")
+	name := rest[strings.LastIndexByte(rest, '/')+1:]
+	if name, err = url.PathUnescape(name); err != nil {
+		return nil, fmt.Errorf("rhel: bad purl %q: %w", s, err)
+	}
+	if ver, err = url.PathUnescape(ver); err != nil {
+		return nil, fmt.Errorf("rhel: bad purl %q: %w", s, err)
+	}
+	p := rpmPURL{
+		Name:    name,
+		Version: ver,
+		Epoch:   q.Get("epoch"),
+		Arch:    q.Get("arch"),
+	}
+	if m := strings.SplitN(q.Get("rpmmod"), ":", 3); len(m) >= 2 {
+		p.Module = m[0] + ":" + m[1]
+	}
+	return &p, nil
+}
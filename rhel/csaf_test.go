@@ -0,0 +1,521 @@
+package rhel
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/klauspost/compress/zstd"
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/libvuln/driver"
+)
+
+// The CSAF fixture is a trimmed copy of the advisory for RHSA-2020:1980, which
+// is also present as OVAL in "testdata/com.redhat.rhsa-20201980.xml".
+const csafFixture = `testdata/csaf/rhsa-2020_1980.json`
+
+func TestParseCSAF(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	u, err := NewUpdater(`rhel-8-updater`, 8, "file:///dev/null", false, WithCVSSSeverity())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(csafFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs, err := u.ParseCSAF(ctx, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("found %d vulnerabilities", len(vs))
+	// 18 packages, 1 cpe; the source package is skipped.
+	if got, want := len(vs), 18; got != want {
+		t.Fatalf("got: %d vulnerabilities, want: %d vulnerabilities", got, want)
+	}
+	const appstream = "cpe:/a:redhat:enterprise_linux:8::appstream"
+	byName := make(map[string]*claircore.Vulnerability)
+	for _, v := range vs {
+		if got, want := v.Repo.Name, appstream; got != want {
+			t.Errorf("%s: got repo %q, want %q", v.Package.Name, got, want)
+		}
+		if got, want := v.Severity, "7.5/CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N"; got != want {
+			t.Errorf("%s: got severity %q, want %q", v.Package.Name, got, want)
+		}
+		if got, want := v.CVSSv3Score, 7.5; got != want {
+			t.Errorf("%s: got CVSSv3 score %v, want %v", v.Package.Name, got, want)
+		}
+		byName[v.Package.Name] = v
+	}
+	if got, want := byName["git"].Package.Arch, "aarch64|ppc64le|s390x|x86_64"; got != want {
+		t.Errorf("got arch %q, want %q", got, want)
+	}
+	if got, want := byName["gitk"].Package.Arch, "noarch"; got != want {
+		t.Errorf("got arch %q, want %q", got, want)
+	}
+
+	// Check against what the OVAL parser produces for the same advisory.
+	f, err = os.Open("testdata/com.redhat.rhsa-20201980.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ovs, err := u.Parse(ctx, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range ovs {
+		if o.Repo.Name != appstream {
+			continue
+		}
+		c, ok := byName[o.Package.Name]
+		if !ok {
+			t.Errorf("missing package %q", o.Package.Name)
+			continue
+		}
+		type cmpVuln struct {
+			Updater, Name, Package, Fixed, Repo string
+			Sev                                 claircore.Severity
+		}
+		mk := func(v *claircore.Vulnerability) cmpVuln {
+			return cmpVuln{
+				Updater: v.Updater,
+				Name:    v.Name,
+				Package: v.Package.Name,
+				Fixed:   v.FixedInVersion,
+				Repo:    v.Repo.Name,
+				Sev:     v.NormalizedSeverity,
+			}
+		}
+		if got, want := mk(c), mk(o); !cmp.Equal(got, want) {
+			t.Error(cmp.Diff(got, want))
+		}
+		if got, want := c.Issued.Format("2006-01-02"), o.Issued.Format("2006-01-02"); got != want {
+			t.Errorf("%s: issued: got %v, want %v", o.Package.Name, got, want)
+		}
+	}
+}
+
+func TestParseCSAFUnpatched(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	const doc = `{
+  "document": {
+    "aggregate_severity": {"text": "Moderate"},
+    "title": "libfoo: out-of-bounds read",
+    "tracking": {"id": "CVE-2023-0001", "initial_release_date": "2023-01-01T00:00:00+00:00"}
+  },
+  "product_tree": {
+    "branches": [{"branches": [
+      {"product": {"product_id": "red_hat_enterprise_linux_9", "product_identification_helper": {"cpe": "cpe:/o:redhat:enterprise_linux:9"}}},
+      {"product": {"product_id": "libfoo", "product_identification_helper": {"purl": "pkg:rpm/redhat/libfoo?arch=src"}}},
+      {"product": {"product_id": "libfoo-devel", "product_identification_helper": {"purl": "pkg:rpm/redhat/libfoo-devel"}}}
+    ]}],
+    "relationships": [
+      {"full_product_name": {"product_id": "red_hat_enterprise_linux_9:libfoo"}, "product_reference": "libfoo", "relates_to_product_reference": "red_hat_enterprise_linux_9"},
+      {"full_product_name": {"product_id": "red_hat_enterprise_linux_9:libfoo-devel"}, "product_reference": "libfoo-devel", "relates_to_product_reference": "red_hat_enterprise_linux_9"}
+    ]
+  },
+  "vulnerabilities": [{
+    "cve": "CVE-2023-0001",
+    "product_status": {"known_affected": ["red_hat_enterprise_linux_9:libfoo", "red_hat_enterprise_linux_9:libfoo-devel"]},
+    "scores": [{"cvss_v2": {"baseScore": 4.3, "vectorString": "AV:N/AC:M/Au:N/C:N/I:N/A:P"}}]
+  }]
+}`
+	for _, ignore := range []bool{false, true} {
+		u, err := NewUpdater(`rhel-9-updater`, 9, "file:///dev/null", ignore)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vs, err := u.ParseCSAF(ctx, io.NopCloser(strings.NewReader(doc)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ignore {
+			if len(vs) != 0 {
+				t.Errorf("expected no vulnerabilities, got %d", len(vs))
+			}
+			continue
+		}
+		if got, want := len(vs), 1; got != want {
+			t.Fatalf("got: %d vulnerabilities, want: %d vulnerabilities", got, want)
+		}
+		v := vs[0]
+		got := []string{v.Name, v.Package.Name, v.FixedInVersion, v.Severity, v.NormalizedSeverity.String(), v.CVSSv2Vector, v.CVSSv3Vector}
+		want := []string{
+			"CVE-2023-0001: libfoo: out-of-bounds read (Moderate)",
+			"libfoo-devel",
+			"",
+			"Moderate",
+			claircore.Medium.String(),
+			"AV:N/AC:M/Au:N/C:N/I:N/A:P",
+			"",
+		}
+		if !cmp.Equal(got, want) {
+			t.Error(cmp.Diff(got, want))
+		}
+	}
+}
+
+func TestParseCSAFStates(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	const doc = `{
+  "document": {
+    "aggregate_severity": {"text": "Low"},
+    "title": "libfoo: several issues",
+    "tracking": {"id": "CVE-2023-0002", "initial_release_date": "2023-01-01T00:00:00+00:00"}
+  },
+  "product_tree": {
+    "branches": [{"branches": [
+      {"product": {"product_id": "red_hat_enterprise_linux_9", "product_identification_helper": {"cpe": "cpe:/o:redhat:enterprise_linux:9"}}},
+      {"product": {"product_id": "libfoo", "product_identification_helper": {"purl": "pkg:rpm/redhat/libfoo"}}},
+      {"product": {"product_id": "libbar", "product_identification_helper": {"purl": "pkg:rpm/redhat/libbar"}}},
+      {"product": {"product_id": "libbaz", "product_identification_helper": {"purl": "pkg:rpm/redhat/libbaz"}}}
+    ]}],
+    "relationships": [
+      {"full_product_name": {"product_id": "red_hat_enterprise_linux_9:libfoo"}, "product_reference": "libfoo", "relates_to_product_reference": "red_hat_enterprise_linux_9"},
+      {"full_product_name": {"product_id": "red_hat_enterprise_linux_9:libbar"}, "product_reference": "libbar", "relates_to_product_reference": "red_hat_enterprise_linux_9"},
+      {"full_product_name": {"product_id": "red_hat_enterprise_linux_9:libbaz"}, "product_reference": "libbaz", "relates_to_product_reference": "red_hat_enterprise_linux_9"}
+    ]
+  },
+  "vulnerabilities": [{
+    "cve": "CVE-2023-0002",
+    "product_status": {
+      "known_affected": ["red_hat_enterprise_linux_9:libfoo", "red_hat_enterprise_linux_9:libbar"],
+      "under_investigation": ["red_hat_enterprise_linux_9:libbaz"]
+    },
+    "remediations": [
+      {"category": "none_available", "details": "Fix deferred", "product_ids": ["red_hat_enterprise_linux_9:libfoo"]},
+      {"category": "no_fix_planned", "details": "Will not fix", "product_ids": ["red_hat_enterprise_linux_9:libbar"]}
+    ]
+  }]
+}`
+	for _, tc := range []struct {
+		Name   string
+		Opts   []Option
+		Ignore bool
+		Want   map[string]claircore.FixState
+	}{
+		{
+			Name: "Default",
+			Want: map[string]claircore.FixState{
+				"libfoo": claircore.FixStateAffected,
+				"libbar": claircore.FixStateWillNotFix,
+				"libbaz": claircore.FixStateUnderInvestigation,
+			},
+		},
+		{
+			Name: "WillNotFix",
+			Opts: []Option{WithUnpatchedStates(claircore.FixStateWillNotFix)},
+			Want: map[string]claircore.FixState{
+				"libbar": claircore.FixStateWillNotFix,
+			},
+		},
+		{
+			Name:   "Ignore",
+			Opts:   []Option{WithUnpatchedStates(claircore.FixStateWillNotFix)},
+			Ignore: true,
+			Want:   map[string]claircore.FixState{},
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			u, err := NewUpdater(`rhel-9-updater`, 9, "file:///dev/null", tc.Ignore, tc.Opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			vs, err := u.ParseCSAF(ctx, io.NopCloser(strings.NewReader(doc)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]claircore.FixState)
+			for _, v := range vs {
+				if v.FixedInVersion != "" {
+					t.Errorf("%s: unexpected fixed-in version %q", v.Package.Name, v.FixedInVersion)
+				}
+				got[v.Package.Name] = v.FixState
+			}
+			if !cmp.Equal(got, tc.Want) {
+				t.Error(cmp.Diff(got, tc.Want))
+			}
+		})
+	}
+}
+
+func TestFetchCSAF(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/provider-metadata.json", func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, `{"distributions":[{"directory_url":"/advisories"}]}`)
+	})
+	mux.HandleFunc("/advisories/index.txt", func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, "2020/rhsa-2020_1980.json\n2020/rhsa-2020_1980.json\n")
+	})
+	mux.HandleFunc("/advisories/2020/rhsa-2020_1980.json", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, csafFixture)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	rc, err := FetchCSAF(ctx, srv.Client(), srv.URL+"/provider-metadata.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := NewUpdater(`rhel-8-updater`, 8, "file:///dev/null", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs, err := u.ParseCSAF(ctx, rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(vs), 36; got != want {
+		t.Errorf("got: %d vulnerabilities, want: %d vulnerabilities", got, want)
+	}
+
+	if _, err := FetchCSAF(ctx, srv.Client(), srv.URL+"/nope.json"); err == nil {
+		t.Error("expected error for missing metadata")
+	}
+}
+
+func TestCSAFUpdater(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	const archive = "csaf_advisories_2020-04-29.tar.zst"
+	doc, err := os.ReadFile(csafFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	zw, err := zstd.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := tar.NewWriter(zw)
+	for _, n := range []string{"2020/rhsa-2020_1980.json", "2020/rhsa-2020_1981.json"} {
+		if err := tw.WriteHeader(&tar.Header{Name: n, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(doc))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu      sync.Mutex
+		changes string
+		fetches = make(map[string]int)
+	)
+	setChanges := func(s string) {
+		mu.Lock()
+		defer mu.Unlock()
+		changes = s
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/advisories/changes.csv", func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		io.WriteString(w, changes)
+	})
+	mux.HandleFunc("/advisories/archive_latest.txt", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetches[r.URL.Path]++
+		mu.Unlock()
+		io.WriteString(w, archive+"\n")
+	})
+	mux.HandleFunc("/advisories/"+archive, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetches[r.URL.Path]++
+		mu.Unlock()
+		w.Write(buf.Bytes())
+	})
+	mux.HandleFunc("/advisories/", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetches[r.URL.Path]++
+		mu.Unlock()
+		http.ServeFile(w, r, csafFixture)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	u, err := NewCSAFUpdater(`rhel-csaf`, srv.URL+"/advisories", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Configure(ctx, func(_ interface{}) error { return nil }, srv.Client()); err != nil {
+		t.Fatal(err)
+	}
+	// Run does a Fetch and Parse, checking the number of vulnerabilities and
+	// documents fetched.
+	run := func(t *testing.T, u *CSAFUpdater, hint driver.Fingerprint, vulns int, fetched map[string]int) driver.Fingerprint {
+		t.Helper()
+		mu.Lock()
+		for k := range fetches {
+			delete(fetches, k)
+		}
+		mu.Unlock()
+		rc, fp, err := u.Fetch(ctx, hint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vs, err := u.Parse(ctx, rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(vs), vulns; got != want {
+			t.Errorf("got: %d vulnerabilities, want: %d vulnerabilities", got, want)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if !cmp.Equal(fetches, fetched) {
+			t.Error(cmp.Diff(fetches, fetched))
+		}
+		return fp
+	}
+
+	setChanges(`"2020/rhsa-2020_1980.json","2020-04-28T10:00:00+00:00"
+"2020/rhsa-2020_1981.json","2020-04-28T09:00:00+00:00"
+"2020/rhsa-2020_1980.json","2020-04-28T08:00:00+00:00"
+`)
+	fp := run(t, u, "", 36, map[string]int{
+		"/advisories/2020/rhsa-2020_1980.json": 1,
+		"/advisories/2020/rhsa-2020_1981.json": 1,
+	})
+	if got, want := fp, driver.Fingerprint("2020-04-28T10:00:00+00:00"); got != want {
+		t.Errorf("got fingerprint %q, want %q", got, want)
+	}
+	if _, _, err := u.Fetch(ctx, fp); !errors.Is(err, driver.Unchanged) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	// Without a hint, nothing needs to be fetched again.
+	run(t, u, "", 36, map[string]int{})
+
+	setChanges(`"2020/rhsa-2020_1981.json","2020-05-01T00:00:00+00:00"
+"2020/rhsa-2020_1980.json","2020-04-28T10:00:00+00:00"
+`)
+	fp = run(t, u, fp, 36, map[string]int{
+		"/advisories/2020/rhsa-2020_1981.json": 1,
+	})
+	if got, want := fp, driver.Fingerprint("2020-05-01T00:00:00+00:00"); got != want {
+		t.Errorf("got fingerprint %q, want %q", got, want)
+	}
+
+	// Documents no longer listed are dropped.
+	setChanges(`"2020/rhsa-2020_1980.json","2020-05-02T00:00:00+00:00"
+`)
+	run(t, u, fp, 18, map[string]int{
+		"/advisories/2020/rhsa-2020_1980.json": 1,
+	})
+
+	// A cold start reads the documents that predate the archive from it, and
+	// fetches the rest: RHSA-2020:1981 was changed after the archive was made,
+	// and RHSA-2020:1982 isn't in it.
+	cold, err := NewCSAFUpdater(`rhel-csaf`, srv.URL+"/advisories", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cold.Configure(ctx, func(_ interface{}) error { return nil }, srv.Client()); err != nil {
+		t.Fatal(err)
+	}
+	cold.archiveMin = 1
+	setChanges(`"2020/rhsa-2020_1981.json","2020-05-01T00:00:00+00:00"
+"2020/rhsa-2020_1980.json","2020-04-28T10:00:00+00:00"
+"2020/rhsa-2020_1982.json","2020-04-28T07:00:00+00:00"
+`)
+	run(t, cold, "", 54, map[string]int{
+		"/advisories/archive_latest.txt":       1,
+		"/advisories/" + archive:               1,
+		"/advisories/2020/rhsa-2020_1981.json": 1,
+		"/advisories/2020/rhsa-2020_1982.json": 1,
+	})
+	run(t, cold, "", 54, map[string]int{})
+
+	// A persistent store lets a restarted updater skip unchanged documents.
+	store := filepath.Join(t.TempDir(), "csaf.json")
+	for _, fetched := range []map[string]int{
+		{
+			"/advisories/2020/rhsa-2020_1980.json": 1,
+			"/advisories/2020/rhsa-2020_1981.json": 1,
+			"/advisories/2020/rhsa-2020_1982.json": 1,
+		},
+		{},
+	} {
+		st, err := OpenFileIncrementalStore(store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, err := NewCSAFUpdater(`rhel-csaf`, srv.URL+"/advisories", false, WithIncrementalStore(st))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := u.Configure(ctx, func(_ interface{}) error { return nil }, srv.Client()); err != nil {
+			t.Fatal(err)
+		}
+		run(t, u, "", 54, fetched)
+	}
+
+	setChanges(`"bad"` + "\n")
+	if _, _, err := u.Fetch(ctx, ""); err == nil {
+		t.Error("expected error for malformed changes.csv")
+	}
+}
+
+func TestParseRPMPURL(t *testing.T) {
+	t.Parallel()
+	tcs := []struct {
+		In   string
+		Want rpmPURL
+		EVR  string
+	}{
+		{
+			In:   "pkg:rpm/redhat/git@2.18.4-2.el8_2?arch=x86_64",
+			Want: rpmPURL{Name: "git", Version: "2.18.4-2.el8_2", Arch: "x86_64"},
+			EVR:  "0:2.18.4-2.el8_2",
+		},
+		{
+			In:   "pkg:rpm/redhat/nodejs@16.20.2-1.module%2Bel8.8.0%2B19690%2B6b913c7a?arch=x86_64&epoch=1&rpmmod=nodejs:16:8080020230725100446:63b34585",
+			Want: rpmPURL{Name: "nodejs", Version: "16.20.2-1.module+el8.8.0+19690+6b913c7a", Epoch: "1", Arch: "x86_64", Module: "nodejs:16"},
+			EVR:  "1:16.20.2-1.module+el8.8.0+19690+6b913c7a",
+		},
+		{
+			In:   "pkg:rpm/redhat/kernel",
+			Want: rpmPURL{Name: "kernel"},
+		},
+	}
+	for _, tc := range tcs {
+		got, err := parseRPMPURL(tc.In)
+		if err != nil {
+			t.Errorf("%s: %v", tc.In, err)
+			continue
+		}
+		if !cmp.Equal(*got, tc.Want) {
+			t.Errorf("%s: %v", tc.In, cmp.Diff(*got, tc.Want))
+		}
+		if got, want := got.EVR(), tc.EVR; got != want {
+			t.Errorf("%s: got EVR %q, want %q", tc.In, got, want)
+		}
+	}
+	if _, err := parseRPMPURL("pkg:deb/debian/curl@1.0"); err == nil {
+		t.Error("expected error for non-rpm purl")
+	}
+}
@@ -0,0 +1,485 @@
+package rhel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/quay/zlog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/libvuln/driver"
+	"github.com/quay/claircore/pkg/errs"
+	"github.com/quay/claircore/pkg/tmp"
+	"github.com/quay/claircore/rhel/internal/csaf"
+)
+
+// DefaultCSAFAdvisories is the location of Red Hat's CSAF advisory directory.
+//
+//doc:url updater
+const DefaultCSAFAdvisories = `https://access.redhat.com/security/data/csaf/v2/advisories/`
+
+var (
+	_ driver.Updater      = (*CSAFUpdater)(nil)
+	_ driver.Configurable = (*CSAFUpdater)(nil)
+)
+
+// CSAFUpdater fetches and parses Red Hat's CSAF advisories or VEX files,
+// producing the same vulnerabilities as the OVAL [Updater] does; see
+// [Updater.ParseCSAF]. Unlike the OVAL feeds, there's one set of documents for
+// every product, so one CSAFUpdater covers every release.
+//
+// Documents are synchronized incrementally using the "changes.csv" file in the
+// directory: only documents that are new or have changed since they were last
+// parsed are fetched. The vulnerabilities from the other documents are reused
+// from the IncrementalStore configured by [WithIncrementalStore], or from
+// memory if there isn't one. A store that persists, like a
+// [FileIncrementalStore], lets a restarted process pick up where it left off.
+//
+// When many documents need to be fetched, as on a cold start, the ones that
+// haven't changed since the directory's latest archive (named by its
+// "archive_latest.txt" file) are read from the archive, and only the documents
+// changed after it was made are fetched individually.
+type CSAFUpdater struct {
+	u      *Updater
+	dir    *url.URL
+	client *http.Client
+	// ArchiveMin is the number of documents that need to be read from the
+	// archive for it to be used.
+	archiveMin int
+}
+
+// CsafArchiveMin is the default for CSAFUpdater.archiveMin. The archive is
+// hundreds of megabytes, so it's only worth downloading in place of a large
+// number of individual documents.
+const csafArchiveMin = 100
+
+// CSAFUpdaterConfig is the configuration accepted by a CSAFUpdater.
+type CSAFUpdaterConfig struct {
+	// URL is the location of the CSAF directory, which must contain a
+	// "changes.csv" file.
+	URL string `json:"url" yaml:"url"`
+	// CVSSSeverity, if set, overrides whether the Updater was configured
+	// with WithCVSSSeverity.
+	CVSSSeverity *bool `json:"cvss_severity" yaml:"cvss_severity"`
+}
+
+// NewCSAFUpdater returns a CSAFUpdater for the CSAF directory at "uri". If
+// "uri" is empty, DefaultCSAFAdvisories is used.
+//
+// Of the Options, only WithTelemetry, WithCPEFilter, WithIncrementalStore,
+// WithCVSSSeverity, and WithUnpatchedStates have any effect.
+func NewCSAFUpdater(name, uri string, ignoreUnpatched bool, opts ...Option) (*CSAFUpdater, error) {
+	if uri == "" {
+		uri = DefaultCSAFAdvisories
+	}
+	dir, err := parseCSAFDir(uri)
+	if err != nil {
+		return nil, err
+	}
+	u := &Updater{
+		name:            name,
+		ignoreUnpatched: ignoreUnpatched,
+		tracer:          tracer,
+	}
+	for _, o := range opts {
+		if err := o(u); err != nil {
+			return nil, err
+		}
+	}
+	if u.incremental == nil {
+		u.incremental = &memChecksums{m: make(map[string]string)}
+		u.results = &memResults{m: make(map[string][]*claircore.Vulnerability)}
+	}
+	return &CSAFUpdater{u: u, dir: dir, archiveMin: csafArchiveMin}, nil
+}
+
+// ParseCSAFDir parses a directory URL, making sure it has a trailing slash so
+// that relative references resolve inside it.
+func parseCSAFDir(uri string) (*url.URL, error) {
+	dir, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("rhel: bad CSAF directory URL: %w", err)
+	}
+	if !strings.HasSuffix(dir.Path, "/") {
+		dir.Path += "/"
+	}
+	return dir, nil
+}
+
+// Name implements [driver.Updater].
+func (u *CSAFUpdater) Name() string { return u.u.name }
+
+// Configure implements [driver.Configurable].
+func (u *CSAFUpdater) Configure(ctx context.Context, cf driver.ConfigUnmarshaler, c *http.Client) error {
+	ctx = zlog.ContextWithValues(ctx, "component", "rhel/CSAFUpdater.Configure")
+	var cfg CSAFUpdaterConfig
+	if err := cf(&cfg); err != nil {
+		return err
+	}
+	if cfg.URL != "" {
+		dir, err := parseCSAFDir(cfg.URL)
+		if err != nil {
+			return err
+		}
+		zlog.Info(ctx).
+			Stringer("url", dir).
+			Msg("configured CSAF directory URL")
+		u.dir = dir
+	}
+	if cfg.CVSSSeverity != nil {
+		// The setting is part of the stored checksums, so changing it causes
+		// every document to be fetched again.
+		u.u.cvssSeverity = *cfg.CVSSSeverity
+	}
+	u.client = c
+	return nil
+}
+
+// CsafFetch is the io.ReadCloser returned by CSAFUpdater.Fetch. It's the
+// fetched documents, concatenated, along with the list of every document in
+// the directory.
+type csafFetch struct {
+	*tmp.File
+	// Changes is every document in the directory.
+	changes []csaf.Change
+	// Fetched is the indexes into "changes" of the documents in the file, in
+	// order.
+	fetched []int
+}
+
+// Fetch implements [driver.Updater].
+//
+// The returned fingerprint is the newest timestamp in "changes.csv". If it's
+// the same as "hint", [driver.Unchanged] is reported. Otherwise, the documents
+// that haven't been parsed at their current timestamp are fetched, from the
+// directory's archive where possible. The returned io.ReadCloser should be
+// passed to [CSAFUpdater.Parse].
+func (u *CSAFUpdater) Fetch(ctx context.Context, hint driver.Fingerprint) (io.ReadCloser, driver.Fingerprint, error) {
+	ctx = zlog.ContextWithValues(ctx, "component", "rhel/CSAFUpdater.Fetch")
+	ctx, span := u.u.getTracer().Start(ctx, "rhel.updater.fetch",
+		trace.WithAttributes(attribute.String("updater", u.u.name), attribute.String("format", "csaf")))
+	defer span.End()
+	if u.client == nil {
+		err := errors.New("rhel: CSAFUpdater not configured with an HTTP client")
+		span.SetStatus(codes.Error, "fetch error")
+		return nil, hint, err
+	}
+
+	changes, err := u.changes(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "fetch error")
+		return nil, hint, err
+	}
+	var fp driver.Fingerprint
+	for _, c := range changes {
+		// RFC 3339 timestamps in the same zone sort lexically.
+		if t := driver.Fingerprint(c.Timestamp); t > fp {
+			fp = t
+		}
+	}
+	if fp == hint && hint != "" {
+		span.SetAttributes(attribute.Bool("unchanged", true))
+		span.SetStatus(codes.Ok, "")
+		return nil, hint, driver.Unchanged
+	}
+
+	tf, err := tmp.NewFile("", "csaf.")
+	if err != nil {
+		return nil, hint, err
+	}
+	success := false
+	defer func() {
+		if !success {
+			if err := tf.Close(); err != nil {
+				zlog.Warn(ctx).Err(err).Msg("failed to close tempfile")
+			}
+		}
+	}()
+	var stale []int
+	for i, c := range changes {
+		if !u.current(c) {
+			stale = append(stale, i)
+		}
+	}
+	archived, rest, err := u.fetchArchive(ctx, tf, changes, stale)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "fetch error")
+		return nil, hint, err
+	}
+	out := csafFetch{File: tf, changes: changes, fetched: archived}
+	for _, i := range rest {
+		if err := u.fetchDoc(ctx, tf, changes[i].Path); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "fetch error")
+			return nil, hint, err
+		}
+		out.fetched = append(out.fetched, i)
+	}
+	if _, err := tf.Seek(0, io.SeekStart); err != nil {
+		return nil, hint, err
+	}
+	zlog.Info(ctx).
+		Int("documents", len(changes)).
+		Int("archived", len(archived)).
+		Int("fetched", len(out.fetched)).
+		Msg("fetched CSAF documents")
+	span.SetAttributes(
+		attribute.Int("documents", len(changes)),
+		attribute.Int("fetched", len(out.fetched)),
+	)
+	span.SetStatus(codes.Ok, "")
+	success = true
+	return &out, fp, nil
+}
+
+// Changes fetches and parses the directory's "changes.csv" file.
+func (u *CSAFUpdater) changes(ctx context.Context) ([]csaf.Change, error) {
+	ref, err := u.dir.Parse("changes.csv")
+	if err != nil {
+		return nil, err
+	}
+	res, err := csafGet(ctx, u.client, ref.String())
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	cs, err := csaf.ParseChanges(res.Body, "")
+	if err != nil {
+		return nil, fmt.Errorf("rhel: unable to read %q: %w", ref, err)
+	}
+	return cs, nil
+}
+
+// FetchArchive copies the documents indexed by "stale" that predate the
+// directory's latest archive from the archive to "w". It returns the indexes
+// of the documents written, in order, and of the documents that still need to
+// be fetched.
+//
+// The archive is skipped if there are fewer than u.archiveMin documents to
+// take from it, or if the directory doesn't have one.
+func (u *CSAFUpdater) fetchArchive(ctx context.Context, w io.Writer, changes []csaf.Change, stale []int) (fetched, rest []int, err error) {
+	if len(stale) < u.archiveMin {
+		return nil, stale, nil
+	}
+	ref, err := u.dir.Parse("archive_latest.txt")
+	if err != nil {
+		return nil, nil, err
+	}
+	res, err := csafGet(ctx, u.client, ref.String())
+	var fe *errs.FetchError
+	if errors.As(err, &fe) && fe.StatusCode == http.StatusNotFound {
+		zlog.Debug(ctx).
+			Stringer("url", ref).
+			Msg("no CSAF archive, fetching documents individually")
+		return nil, stale, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	name, err := csaf.ReadArchiveName(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, nil, fmt.Errorf("rhel: unable to read %q: %w", ref, err)
+	}
+	date := csaf.ArchiveDate(name)
+
+	// Documents changed on the day the archive was made may be newer than
+	// the archived version.
+	want := make(map[string]int)
+	for _, i := range stale {
+		if c := changes[i]; date != "" && c.Timestamp < date {
+			want[c.Path] = i
+		} else {
+			rest = append(rest, i)
+		}
+	}
+	if len(want) < u.archiveMin {
+		return nil, stale, nil
+	}
+	ref, err = u.dir.Parse(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("rhel: bad CSAF archive name %q: %w", name, err)
+	}
+	res, err = csafGet(ctx, u.client, ref.String())
+	if err != nil {
+		return nil, nil, err
+	}
+	err = csaf.WalkArchive(res.Body, func(p string, r io.Reader) error {
+		i, ok := want[p]
+		if !ok {
+			return nil
+		}
+		delete(want, p)
+		if _, err := io.Copy(w, r); err != nil {
+			return err
+		}
+		// Make sure documents are separated.
+		if _, err := io.WriteString(w, "\n"); err != nil {
+			return err
+		}
+		fetched = append(fetched, i)
+		return nil
+	})
+	res.Body.Close()
+	if err != nil {
+		return nil, nil, fmt.Errorf("rhel: %q: %w", ref, err)
+	}
+	// Anything missing from the archive is fetched individually.
+	for _, i := range want {
+		rest = append(rest, i)
+	}
+	sort.Ints(rest)
+	zlog.Debug(ctx).
+		Str("archive", name).
+		Int("count", len(fetched)).
+		Msg("read CSAF documents from archive")
+	return fetched, rest, nil
+}
+
+// FetchDoc copies the document at "p", relative to the directory, to "w".
+func (u *CSAFUpdater) fetchDoc(ctx context.Context, w io.Writer, p string) error {
+	ref, err := u.dir.Parse(p)
+	if err != nil {
+		return fmt.Errorf("rhel: bad CSAF document name %q: %w", p, err)
+	}
+	res, err := csafGet(ctx, u.client, ref.String())
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if _, err := io.Copy(w, res.Body); err != nil {
+		return fmt.Errorf("rhel: error reading %q: %w", ref, err)
+	}
+	// Make sure documents are separated.
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// Key returns the IncrementalStore key for the document at "p".
+func (u *CSAFUpdater) key(p string) string {
+	return u.u.name + "/" + p
+}
+
+// Checksum returns the value recorded in the IncrementalStore for a change.
+// The unpatched and CVSS severity settings are included, as they change the
+// results.
+func (u *CSAFUpdater) checksum(c csaf.Change) string {
+	sum := c.Timestamp + "/" + u.u.unpatchedConfig()
+	if u.u.cvssSeverity {
+		sum += "/cvss"
+	}
+	return sum
+}
+
+// Current reports whether the vulnerabilities for the change are already in
+// the IncrementalStore.
+func (u *CSAFUpdater) current(c csaf.Change) bool {
+	k := u.key(c.Path)
+	if sum, ok := u.u.incremental.GetChecksum(k); !ok || sum != u.checksum(c) {
+		return false
+	}
+	_, ok := u.u.results.GetVulnerabilities(k)
+	return ok
+}
+
+// Parse implements [driver.Updater].
+//
+// When passed the result of [CSAFUpdater.Fetch], the vulnerabilities for every
+// document in the directory are returned, using stored results for documents
+// that weren't fetched. Otherwise, this is the same as [Updater.ParseCSAF].
+func (u *CSAFUpdater) Parse(ctx context.Context, r io.ReadCloser) ([]*claircore.Vulnerability, error) {
+	f, ok := r.(*csafFetch)
+	if !ok {
+		return u.u.ParseCSAF(ctx, r)
+	}
+	ctx = zlog.ContextWithValues(ctx, "component", "rhel/CSAFUpdater.Parse")
+	ctx, span := u.u.getTracer().Start(ctx, "rhel.updater.parse",
+		trace.WithAttributes(attribute.String("updater", u.u.name), attribute.String("format", "csaf")))
+	defer span.End()
+	defer r.Close()
+
+	dec := json.NewDecoder(f)
+	for _, i := range f.fetched {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		c := f.changes[i]
+		var doc csaf.Document
+		if err := dec.Decode(&doc); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "decode error")
+			return nil, fmt.Errorf("rhel: unable to decode CSAF document %q: %w", c.Path, &errs.ParseError{Offset: dec.InputOffset(), Err: err})
+		}
+		vs, err := u.u.csafVulns(&doc)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "conversion error")
+			return nil, fmt.Errorf("rhel: %s: %w", c.Path, err)
+		}
+		k := u.key(c.Path)
+		u.u.results.SetVulnerabilities(k, vs)
+		u.u.incremental.SetChecksum(k, u.checksum(c))
+	}
+
+	var out []*claircore.Vulnerability
+	for _, c := range f.changes {
+		vs, ok := u.u.results.GetVulnerabilities(u.key(c.Path))
+		if !ok {
+			// Only possible if the store was changed out from under the
+			// Updater between Fetch and Parse.
+			err := fmt.Errorf("rhel: missing results for CSAF document %q", c.Path)
+			span.SetStatus(codes.Error, "missing results")
+			return nil, err
+		}
+		out = append(out, vs...)
+	}
+	if fl, ok := u.u.incremental.(interface{ Flush() error }); ok && len(f.fetched) != 0 {
+		if err := fl.Flush(); err != nil {
+			zlog.Warn(ctx).Err(err).Msg("unable to flush incremental store")
+		}
+	}
+	zlog.Debug(ctx).
+		Int("documents", len(f.changes)).
+		Int("parsed", len(f.fetched)).
+		Int("vulnerabilities", len(out)).
+		Msg("parsed CSAF documents")
+	span.SetAttributes(
+		attribute.Int("definitions", len(f.changes)),
+		attribute.Int("vulnerabilities", len(out)),
+	)
+	span.SetStatus(codes.Ok, "")
+	return out, nil
+}
+
+// MemChecksums is the IncrementalStore used by a CSAFUpdater that wasn't
+// configured with one.
+type memChecksums struct {
+	mu sync.Mutex
+	m  map[string]string
+}
+
+func (s *memChecksums) GetChecksum(id string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sum, ok := s.m[id]
+	return sum, ok
+}
+
+func (s *memChecksums) SetChecksum(id, sum string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m[id] = sum
+}
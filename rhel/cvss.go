@@ -0,0 +1,282 @@
+package rhel
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/quay/claircore"
+)
+
+// CVSSVector is a CVSS v3.x vector string, as found in the "cvss3" attribute
+// of Red Hat OVAL advisories.
+//
+// Red Hat prefixes vectors with the base score, like
+// "7.5/CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N". Both this form and the
+// plain vector form are accepted.
+type CVSSVector string
+
+// Cvss3Order is the canonical order of the CVSS v3 base metrics.
+var cvss3Order = []string{`AV`, `AC`, `PR`, `UI`, `S`, `C`, `I`, `A`}
+
+// Cvss3Phrases maps CVSS v3 base metrics and values to descriptive phrases.
+//
+// Impact metrics with a value of "None" have no entry, because nothing is
+// affected.
+var cvss3Phrases = map[string]map[string]string{
+	`AV`: {
+		`N`: "network-accessible",
+		`A`: "adjacent-network-accessible",
+		`L`: "local-access-required",
+		`P`: "physical-access-required",
+	},
+	`AC`: {
+		`L`: "low-attack-complexity",
+		`H`: "high-attack-complexity",
+	},
+	`PR`: {
+		`N`: "no-auth-required",
+		`L`: "low-privileges-required",
+		`H`: "high-privileges-required",
+	},
+	`UI`: {
+		`N`: "no-user-interaction",
+		`R`: "user-interaction-required",
+	},
+	`S`: {
+		`U`: "scope-unchanged",
+		`C`: "scope-changed",
+	},
+	`C`: {
+		`H`: "high-impact-confidentiality",
+		`L`: "low-impact-confidentiality",
+	},
+	`I`: {
+		`H`: "high-impact-integrity",
+		`L`: "low-impact-integrity",
+	},
+	`A`: {
+		`H`: "high-impact-availability",
+		`L`: "low-impact-availability",
+	},
+}
+
+// AffectedMetrics returns a list of descriptive phrases for the base metrics
+// in the vector, in the order the CVSS specification lists them.
+//
+// For example, "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N" is described
+// as:
+//
+//	["network-accessible", "low-attack-complexity", "no-auth-required",
+//	 "no-user-interaction", "scope-unchanged", "high-impact-confidentiality"]
+//
+// Unknown metrics and values are ignored. If the string is not a CVSS v3.x
+// vector, nil is returned.
+func (v CVSSVector) AffectedMetrics() []string {
+	s := string(v)
+	if i := strings.Index(s, "CVSS:3"); i > 0 {
+		// Trim a leading score.
+		s = s[i:]
+	}
+	ms := strings.Split(strings.TrimRight(s, "/"), "/")
+	if !strings.HasPrefix(ms[0], "CVSS:3.") {
+		return nil
+	}
+	vals := make(map[string]string, len(ms)-1)
+	for _, m := range ms[1:] {
+		n, val, ok := strings.Cut(m, ":")
+		if !ok {
+			continue
+		}
+		vals[n] = val
+	}
+	var ret []string
+	for _, n := range cvss3Order {
+		if p, ok := cvss3Phrases[n][vals[n]]; ok {
+			ret = append(ret, p)
+		}
+	}
+	return ret
+}
+
+// CvssScores tracks every distinct CVSS vector seen in an advisory.
+type cvssScores struct {
+	vs []versionedVector
+}
+
+// ScoredVector is a CVSS vector and its base score.
+type scoredVector struct {
+	Vector string
+	Score  float64
+	// Severity is the qualitative severity, if provided.
+	Severity string
+}
+
+// VersionedVector is a scoredVector with its exact CVSS version.
+type versionedVector struct {
+	scoredVector
+	Major   int
+	Version string
+}
+
+// Add records the vector, unless it's already been seen. Versions other than
+// 2, 3, and 4 are ignored.
+func (c *cvssScores) Add(version int, v scoredVector) {
+	if version < 2 || version > 4 || v.Vector == "" {
+		return
+	}
+	vv := versionedVector{scoredVector: v, Major: version, Version: vectorVersion(version, v.Vector)}
+	for _, o := range c.vs {
+		if o.Version == vv.Version && o.Vector == vv.Vector {
+			return
+		}
+	}
+	c.vs = append(c.vs, vv)
+}
+
+// VectorVersion reports the exact version of a vector of the given major
+// version. CVSS v3 and later vectors declare their minor version.
+func vectorVersion(major int, vec string) string {
+	if p, rest, ok := strings.Cut(vec, "/"); ok && rest != "" {
+		if n, ok := strings.CutPrefix(p, "CVSS:"); ok {
+			return n
+		}
+	}
+	return strconv.Itoa(major) + ".0"
+}
+
+// AddOVAL records the "cvss2" and "cvss3" attributes of an OVAL "cve" element,
+// which are in "score/vector" form. Malformed values are ignored.
+func (c *cvssScores) AddOVAL(cvss2, cvss3 string) {
+	if v, ok := parseScoredVector(cvss2); ok {
+		c.Add(2, v)
+	}
+	if v, ok := parseScoredVector(cvss3); ok {
+		c.Add(3, v)
+	}
+}
+
+// Best reports the vector of the newest CVSS version seen with the major
+// version "major", or of any version if "major" is 0. Of vectors with the
+// same version, the highest-scoring one is reported.
+func (c *cvssScores) best(major int) (versionedVector, bool) {
+	var ret versionedVector
+	ok := false
+	for _, v := range c.vs {
+		if major != 0 && v.Major != major {
+			continue
+		}
+		switch {
+		case !ok,
+			v.Major > ret.Major,
+			v.Major == ret.Major && v.Version > ret.Version,
+			v.Version == ret.Version && v.Score > ret.Score:
+			ret, ok = v, true
+		}
+	}
+	return ret, ok
+}
+
+// Preferred reports the authoritative vector, if any: the highest-scoring
+// vector of the most recent CVSS version seen.
+func (c *cvssScores) Preferred() (scoredVector, bool) {
+	v, ok := c.best(0)
+	return v.scoredVector, ok
+}
+
+// Apply populates the CVSS fields of "v". The fields for each major version
+// get the vector that [cvssScores.Preferred] would pick from that version.
+func (c *cvssScores) Apply(v *claircore.Vulnerability) {
+	for _, f := range []struct {
+		Major  int
+		Vector *string
+		Score  *float64
+	}{
+		{2, &v.CVSSv2Vector, &v.CVSSv2Score},
+		{3, &v.CVSSv3Vector, &v.CVSSv3Score},
+		{4, &v.CVSSv4Vector, &v.CVSSv4Score},
+	} {
+		b, _ := c.best(f.Major)
+		*f.Vector, *f.Score = b.Vector, b.Score
+	}
+	v.CVSS = nil
+	if len(c.vs) == 0 {
+		return
+	}
+	auth, _ := c.best(0)
+	v.CVSS = make([]claircore.CVSS, len(c.vs))
+	for i, o := range c.vs {
+		v.CVSS[i] = claircore.CVSS{
+			Version:       o.Version,
+			Vector:        o.Vector,
+			Score:         o.Score,
+			Authoritative: o.Version == auth.Version && o.Vector == auth.Vector,
+		}
+	}
+}
+
+// Merge adds all the vectors in "o".
+func (c *cvssScores) Merge(o *cvssScores) {
+	for _, v := range o.vs {
+		c.Add(v.Major, v.scoredVector)
+	}
+}
+
+// String returns the vector in "score/vector" form.
+func (v scoredVector) String() string {
+	return strconv.FormatFloat(v.Score, 'f', 1, 64) + "/" + v.Vector
+}
+
+// ParseScoredVector parses the "score/vector" form used in Red Hat OVAL.
+func parseScoredVector(s string) (scoredVector, bool) {
+	sc, vec, ok := strings.Cut(s, "/")
+	if !ok || vec == "" {
+		return scoredVector{}, false
+	}
+	f, err := strconv.ParseFloat(sc, 64)
+	if err != nil {
+		return scoredVector{}, false
+	}
+	return scoredVector{Vector: vec, Score: f}, true
+}
+
+// CVSSVectors returns every CVSS vector recorded in "v", with the
+// authoritative one first. The authoritative vector is the one used for the
+// Severity by [WithCVSSSeverity] and to compare against the minimum given to
+// [WithMinSeverity]: Red Hat treats the newest CVSS version it has published
+// for a CVE as authoritative, and of several vectors of that version, the
+// highest-scoring one is used.
+//
+// Vulnerabilities without a CVSS list, like ones stored by older versions,
+// only report the vectors in their per-version fields. A nil slice is returned
+// if there are no vectors.
+func CVSSVectors(v *claircore.Vulnerability) []claircore.CVSS {
+	var ret []claircore.CVSS
+	if len(v.CVSS) != 0 {
+		ret = append(ret, v.CVSS...)
+	} else {
+		for _, c := range []struct {
+			Major  int
+			Vector string
+			Score  float64
+		}{
+			{4, v.CVSSv4Vector, v.CVSSv4Score},
+			{3, v.CVSSv3Vector, v.CVSSv3Score},
+			{2, v.CVSSv2Vector, v.CVSSv2Score},
+		} {
+			if c.Vector == "" {
+				continue
+			}
+			ret = append(ret, claircore.CVSS{
+				Version:       vectorVersion(c.Major, c.Vector),
+				Vector:        c.Vector,
+				Score:         c.Score,
+				Authoritative: len(ret) == 0,
+			})
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Authoritative && !ret[j].Authoritative
+	})
+	return ret
+}
@@ -0,0 +1,154 @@
+package rhel
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/quay/claircore"
+)
+
+func TestAffectedMetrics(t *testing.T) {
+	t.Parallel()
+	tcs := []struct {
+		In   CVSSVector
+		Want []string
+	}{
+		{
+			In: "7.5/CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N",
+			Want: []string{
+				"network-accessible",
+				"low-attack-complexity",
+				"no-auth-required",
+				"no-user-interaction",
+				"scope-unchanged",
+				"high-impact-confidentiality",
+			},
+		},
+		{
+			In: "CVSS:3.0/AV:L/AC:H/PR:L/UI:R/S:C/C:L/I:L/A:H",
+			Want: []string{
+				"local-access-required",
+				"high-attack-complexity",
+				"low-privileges-required",
+				"user-interaction-required",
+				"scope-changed",
+				"low-impact-confidentiality",
+				"low-impact-integrity",
+				"high-impact-availability",
+			},
+		},
+		{
+			// Metrics out of order, with an unknown temporal metric.
+			In:   "CVSS:3.1/A:H/E:P/AV:P",
+			Want: []string{"physical-access-required", "high-impact-availability"},
+		},
+		{In: "6.8/AV:N/AC:M/Au:N/C:P/I:P/A:P"},
+		{In: ""},
+	}
+	for _, tc := range tcs {
+		got := tc.In.AffectedMetrics()
+		if !cmp.Equal(got, tc.Want) {
+			t.Errorf("%q: %s", tc.In, cmp.Diff(got, tc.Want))
+		}
+	}
+}
+
+func TestCVSSScores(t *testing.T) {
+	var s cvssScores
+	for _, c := range ovalDef.Advisory.Cves {
+		s.AddOVAL(c.Cvss2, c.Cvss3)
+	}
+	// Garbage should be ignored.
+	s.AddOVAL("AV:N/AC:L", "high")
+	var got claircore.Vulnerability
+	s.Apply(&got)
+	want := claircore.Vulnerability{
+		CVSSv2Vector: "AV:N/AC:M/Au:N/C:P/I:P/A:P",
+		CVSSv2Score:  6.8,
+		// Every distinct vector is kept.
+		CVSS: []claircore.CVSS{
+			{Version: "2.0", Vector: "AV:A/AC:L/Au:N/C:P/I:P/A:P", Score: 5.8},
+			{Version: "2.0", Vector: "AV:L/AC:L/Au:N/C:N/I:N/A:P", Score: 2.1},
+			{Version: "2.0", Vector: "AV:N/AC:M/Au:N/C:P/I:P/A:P", Score: 6.8, Authoritative: true},
+		},
+	}
+	if !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(got, want))
+	}
+	p, ok := s.Preferred()
+	if !ok {
+		t.Fatal("expected a preferred vector")
+	}
+	if got, want := p.String(), "6.8/AV:N/AC:M/Au:N/C:P/I:P/A:P"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+
+	s.AddOVAL("", "5.3/CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:L/I:N/A:N")
+	s.AddOVAL("", "4.3/CVSS:3.1/AV:N/AC:L/PR:L/UI:N/S:U/C:L/I:N/A:N")
+	p, _ = s.Preferred()
+	if got, want := p.String(), "5.3/CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:L/I:N/A:N"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+}
+
+func TestCVSSPreferV4(t *testing.T) {
+	var s cvssScores
+	s.AddOVAL("5.0/AV:N/AC:L/Au:N/C:P/I:N/A:N", "9.8/CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H")
+	s.Add(4, scoredVector{Vector: "CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:L/VI:N/VA:N/SC:N/SI:N/SA:N", Score: 6.9})
+	p, _ := s.Preferred()
+	if got, want := p.String(), "6.9/CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:L/VI:N/VA:N/SC:N/SI:N/SA:N"; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+}
+
+func TestCVSSVectors(t *testing.T) {
+	t.Parallel()
+	// A v3.0 vector with a higher score than the v3.1 one, and a duplicate.
+	var s cvssScores
+	s.AddOVAL("5.0/AV:N/AC:L/Au:N/C:P/I:N/A:N", "9.8/CVSS:3.0/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H")
+	s.AddOVAL("", "7.5/CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N")
+	s.AddOVAL("5.0/AV:N/AC:L/Au:N/C:P/I:N/A:N", "")
+	var v claircore.Vulnerability
+	s.Apply(&v)
+
+	got := CVSSVectors(&v)
+	want := []claircore.CVSS{
+		{Version: "3.1", Vector: "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N", Score: 7.5, Authoritative: true},
+		{Version: "2.0", Vector: "AV:N/AC:L/Au:N/C:P/I:N/A:N", Score: 5.0},
+		{Version: "3.0", Vector: "CVSS:3.0/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", Score: 9.8},
+	}
+	if !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(got, want))
+	}
+	// The per-version fields hold the newest vector of each major version.
+	if got, want := v.CVSSv3Vector, want[0].Vector; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+	// The authoritative vector is the one used for the Severity.
+	p, _ := s.Preferred()
+	if got, want := p.Vector, got[0].Vector; got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+
+	t.Run("Fields", func(t *testing.T) {
+		v := &claircore.Vulnerability{
+			CVSSv2Vector: "AV:N/AC:L/Au:N/C:P/I:N/A:N",
+			CVSSv2Score:  5.0,
+			CVSSv4Vector: "CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:L/VI:N/VA:N/SC:N/SI:N/SA:N",
+			CVSSv4Score:  6.9,
+		}
+		got := CVSSVectors(v)
+		want := []claircore.CVSS{
+			{Version: "4.0", Vector: v.CVSSv4Vector, Score: 6.9, Authoritative: true},
+			{Version: "2.0", Vector: v.CVSSv2Vector, Score: 5.0},
+		}
+		if !cmp.Equal(got, want) {
+			t.Error(cmp.Diff(got, want))
+		}
+	})
+
+	if got := CVSSVectors(&claircore.Vulnerability{}); got != nil {
+		t.Errorf("got: %v, want: nil", got)
+	}
+}
@@ -1,15 +1,24 @@
 package rhel
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/quay/zlog"
 
 	"github.com/quay/claircore/libvuln/driver"
+	"github.com/quay/claircore/pkg/errs"
+	"github.com/quay/claircore/pkg/tmp"
 )
 
 func TestFetch(t *testing.T) {
@@ -88,3 +97,297 @@ func TestFetch(t *testing.T) {
 		}
 	})
 }
+
+func TestFetchCache(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	const etag = `"abc123"`
+	var full, notModified atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("if-none-match") == etag {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full.Add(1)
+		w.Header().Set("etag", etag)
+		w.Header().Set("last-modified", "Wed, 21 Oct 2015 07:28:00 GMT")
+		w.Header().Set("content-type", "application/xml")
+		io.WriteString(w, "<oval_definitions/>")
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+
+	mk := func(t *testing.T, opts ...Option) *Updater {
+		u, err := NewUpdater(`rhel-8-updater`, 8, srv.URL, false, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := u.Configure(ctx, func(_ interface{}) error { return nil }, srv.Client()); err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+
+	// The first fetch populates the cache.
+	rc, _, err := mk(t, WithCache(dir)).Fetch(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc.Close()
+	b, err := os.ReadFile(filepath.Join(dir, cacheFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c map[string]struct {
+		Etag string
+		Date string
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c[srv.URL].Etag, etag; got != want {
+		t.Errorf("cached etag: got %q, want %q", got, want)
+	}
+
+	// A new Updater using the same cache makes a conditional request.
+	_, _, err = mk(t, WithCache(dir)).Fetch(ctx, "")
+	if !errors.Is(err, driver.Unchanged) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// Without the cache, the database is fetched again.
+	rc, _, err = mk(t).Fetch(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc.Close()
+
+	if got, want := full.Load(), int32(2); got != want {
+		t.Errorf("full responses: got %d, want %d", got, want)
+	}
+	if got, want := notModified.Load(), int32(1); got != want {
+		t.Errorf("not modified responses: got %d, want %d", got, want)
+	}
+
+	if _, err := NewUpdater(`rhel-8-updater`, 8, srv.URL, false, WithCache("")); err == nil {
+		t.Error("expected error for empty directory")
+	}
+}
+
+func TestFetchSignature(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	const db = "testdata/Red_Hat_Enterprise_Linux_3.xml"
+	// The keyring holds two keys; the signature is by the second, to mimic a
+	// key rotation.
+	tcs := []struct {
+		Name string
+		Sig  string
+		OK   bool
+		// Err is the expected type of error.
+		Err interface{}
+	}{
+		{Name: "Valid", Sig: "testdata/signature/Red_Hat_Enterprise_Linux_3.xml.asc", OK: true},
+		{Name: "Corrupted", Sig: "testdata/signature/corrupted.asc", Err: new(*errs.ValidationError)},
+		{Name: "Missing", Sig: "", Err: new(*errs.FetchError)},
+	}
+	for _, tc := range tcs {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			ctx := zlog.Test(ctx, t)
+			mux := http.NewServeMux()
+			mux.HandleFunc("/db.xml", func(w http.ResponseWriter, r *http.Request) {
+				http.ServeFile(w, r, db)
+			})
+			if tc.Sig != "" {
+				mux.HandleFunc("/db.xml.asc", func(w http.ResponseWriter, r *http.Request) {
+					http.ServeFile(w, r, tc.Sig)
+				})
+			}
+			srv := httptest.NewServer(mux)
+			defer srv.Close()
+
+			kr, err := os.Open("testdata/signature/keyring.asc")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer kr.Close()
+			u, err := NewUpdater(`rhel-3-updater`, 3, srv.URL+"/db.xml", false, WithSignatureVerification(kr))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := u.Configure(ctx, func(_ interface{}) error { return nil }, srv.Client()); err != nil {
+				t.Fatal(err)
+			}
+			rd, _, err := u.Fetch(ctx, driver.Fingerprint(""))
+			if !tc.OK {
+				if !errors.Is(err, ErrSignatureInvalid) {
+					t.Fatalf("got error %v, want %v", err, ErrSignatureInvalid)
+				}
+				if !errors.As(err, tc.Err) {
+					t.Errorf("got error %#v, want %T", err, tc.Err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rd.Close()
+			got, err := io.ReadAll(rd)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := os.ReadFile(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Error("fetched database differs from served database")
+			}
+		})
+	}
+
+	t.Run("EmptyKeyring", func(t *testing.T) {
+		_, err := NewUpdater(`rhel-3-updater`, 3, "file:///dev/null", false, WithSignatureVerification(strings.NewReader("")))
+		if err == nil {
+			t.Error("expected error for empty keyring")
+		}
+	})
+}
+
+func TestFetchError(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	for _, tc := range []struct {
+		Code      int
+		Transient bool
+	}{
+		{Code: http.StatusNotFound, Transient: false},
+		{Code: http.StatusServiceUnavailable, Transient: true},
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(tc.Code)
+		}))
+		u, err := NewUpdater(`rhel-8-updater`, 8, srv.URL, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := u.Configure(ctx, func(_ interface{}) error { return nil }, srv.Client()); err != nil {
+			t.Fatal(err)
+		}
+		_, _, err = u.Fetch(ctx, "")
+		srv.Close()
+		var fe *errs.FetchError
+		if !errors.As(err, &fe) {
+			t.Errorf("%d: got error %#v, want %T", tc.Code, err, fe)
+			continue
+		}
+		if got, want := fe.StatusCode, tc.Code; got != want {
+			t.Errorf("got status %d, want %d", got, want)
+		}
+		if got, want := errs.Transient(err), tc.Transient; got != want {
+			t.Errorf("%d: transient: got %v, want %v", tc.Code, got, want)
+		}
+	}
+}
+
+func TestFetchBzip2(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	// The compressed database is two concatenated bzip2 streams.
+	const db = "testdata/com.redhat.rhsa-20201980.xml"
+	want, err := os.ReadFile(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed, err := os.ReadFile(db + ".bz2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Flip a bit in the first block's checksum, which follows the 4-byte
+	// stream header and 6-byte block magic.
+	corrupt := append([]byte(nil), compressed...)
+	corrupt[10] ^= 0x01
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/typed.xml", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("content-type", "application/x-bzip2")
+		w.Write(compressed)
+	})
+	mux.HandleFunc("/db.xml.bz2", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("content-type", "application/octet-stream")
+		w.Write(compressed)
+	})
+	mux.HandleFunc("/corrupt.xml.bz2", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write(corrupt)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	fetch := func(ctx context.Context, t *testing.T, path string) io.ReadCloser {
+		u, err := NewUpdater(`rhel-8-updater`, 8, srv.URL+path, false, WithStreaming())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := u.Configure(ctx, func(_ interface{}) error { return nil }, srv.Client()); err != nil {
+			t.Fatal(err)
+		}
+		rc, _, err := u.Fetch(ctx, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := rc.(*os.File); ok {
+			t.Error("database unexpectedly buffered to disk")
+		}
+		return rc
+	}
+
+	for _, path := range []string{"/typed.xml", "/db.xml.bz2"} {
+		t.Run(path, func(t *testing.T) {
+			ctx := zlog.Test(ctx, t)
+			rc := fetch(ctx, t, path)
+			defer rc.Close()
+			got, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Error("fetched database differs from served database")
+			}
+		})
+	}
+	t.Run("Checksum", func(t *testing.T) {
+		ctx := zlog.Test(ctx, t)
+		rc := fetch(ctx, t, "/corrupt.xml.bz2")
+		u, err := NewUpdater(`rhel-8-updater`, 8, srv.URL, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = u.Parse(ctx, rc)
+		var ve *errs.ValidationError
+		if !errors.As(err, &ve) {
+			t.Fatalf("got error %#v, want %T", err, ve)
+		}
+		t.Log(err)
+	})
+	t.Run("Default", func(t *testing.T) {
+		ctx := zlog.Test(ctx, t)
+		u, err := NewUpdater(`rhel-8-updater`, 8, srv.URL+"/typed.xml", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := u.Configure(ctx, func(_ interface{}) error { return nil }, srv.Client()); err != nil {
+			t.Fatal(err)
+		}
+		rc, _, err := u.Fetch(ctx, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		if _, ok := rc.(*tmp.File); !ok {
+			t.Errorf("got %T, want database buffered to disk", rc)
+		}
+	})
+}
@@ -0,0 +1,395 @@
+package rhel
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/quay/zlog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/quay/claircore"
+)
+
+// IncrementalStore records a checksum for every OVAL definition an Updater
+// has processed. See WithIncrementalStore.
+//
+// Keys are the definition ID prefixed with the Updater's name, so one store
+// can be shared by multiple Updaters.
+type IncrementalStore interface {
+	GetChecksum(defID string) (string, bool)
+	SetChecksum(defID, checksum string)
+}
+
+// IncrementalResults is implemented by an IncrementalStore that can also keep
+// the vulnerabilities produced from each definition.
+//
+// If the store passed to WithIncrementalStore doesn't implement this, the
+// Updater keeps the vulnerabilities in memory, so definitions can only be
+// skipped from the second Parse call on.
+type IncrementalResults interface {
+	GetVulnerabilities(defID string) ([]*claircore.Vulnerability, bool)
+	SetVulnerabilities(defID string, vs []*claircore.Vulnerability)
+}
+
+// WithIncrementalStore configures the Updater to only convert OVAL
+// definitions that differ from the last run, as recorded in "s". The
+// vulnerabilities for unchanged definitions are reused.
+//
+// If every definition is unchanged, the document isn't decoded at all, which
+// is the bulk of the work done by Parse. Changed definitions are decoded and
+// converted by the workers configured by WithWorkers. Checksums cover the definition
+// element and the rest of the document besides the other definitions and the
+// "generator" element, because definitions refer to tests, objects, and
+// states by ID. This means a change to any of those causes every definition
+// to be converted again.
+//
+// If the store has a "Flush() error" method, it's called at the end of every
+// successful Parse.
+//
+// Of the Updater's configuration, only the setting for unpatched
+// vulnerabilities is part of the checksum, so a store should not be reused
+// after changing options like WithCPEFilter.
+func WithIncrementalStore(s IncrementalStore) Option {
+	return func(u *Updater) error {
+		if s == nil {
+			return errors.New("rhel: nil incremental store")
+		}
+		u.incremental = s
+		if rs, ok := s.(IncrementalResults); ok {
+			u.results = rs
+		} else {
+			u.results = &memResults{m: make(map[string][]*claircore.Vulnerability)}
+		}
+		return nil
+	}
+}
+
+// ParseIncremental is the Parse implementation when an IncrementalStore is
+// configured.
+func (u *Updater) parseIncremental(ctx context.Context, r io.Reader) ([]*claircore.Vulnerability, error) {
+	span := trace.SpanFromContext(ctx)
+	b, err := io.ReadAll(r)
+	if err != nil {
+		span.SetStatus(codes.Error, "read error")
+		return nil, fmt.Errorf("rhel: unable to read OVAL document: %w", err)
+	}
+	defs, shared := scanDefinitions(b)
+	span.SetAttributes(attribute.Int("definitions", len(defs)))
+	sums := make([]string, len(defs))
+	keys := make([]string, len(defs))
+	cached := make([][]*claircore.Vulnerability, len(defs))
+	changed := make(map[string]int)
+	conf := u.unpatchedConfig()
+	for i, d := range defs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		h := sha256.New()
+		h.Write(shared)
+		io.WriteString(h, conf)
+		h.Write(b[d.Start:d.End])
+		sums[i] = hex.EncodeToString(h.Sum(nil))
+		keys[i] = u.name + "/" + d.ID
+		if prev, ok := u.incremental.GetChecksum(keys[i]); ok && prev == sums[i] {
+			if vs, ok := u.results.GetVulnerabilities(keys[i]); ok {
+				cached[i] = vs
+				continue
+			}
+		}
+		changed[d.ID] = i
+	}
+	zlog.Debug(ctx).
+		Int("definitions", len(defs)).
+		Int("changed", len(changed)).
+		Msg("computed definition checksums")
+
+	switch {
+	case len(defs) == 0:
+		// Nothing recognized by the scanner, so process the whole document
+		// without remembering anything.
+		root, scores, err := u.decode(ctx, bytes.NewReader(b))
+		if err != nil {
+			span.SetStatus(codes.Error, "decode error")
+			return nil, err
+		}
+		vs, err := u.convert(ctx, root, scores)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "conversion error")
+			return nil, err
+		}
+		span.SetStatus(codes.Ok, "")
+		return vs, nil
+	case len(changed) != 0:
+		// Only convert the changed definitions.
+		todo := make([]defSpan, 0, len(changed))
+		pos := make([]int, 0, len(changed))
+		for i, d := range defs {
+			if j, ok := changed[d.ID]; ok && j == i {
+				todo = append(todo, d)
+				pos = append(pos, i)
+			}
+		}
+		res, err := u.parseSpans(ctx, b, defs, todo)
+		if err != nil {
+			span.SetStatus(codes.Error, "decode error")
+			return nil, err
+		}
+		for j, i := range pos {
+			cached[i] = res[j]
+			u.results.SetVulnerabilities(keys[i], res[j])
+			u.incremental.SetChecksum(keys[i], sums[i])
+		}
+	}
+
+	var n int
+	for _, vs := range cached {
+		n += len(vs)
+	}
+	out := make([]*claircore.Vulnerability, 0, n)
+	for _, vs := range cached {
+		out = append(out, vs...)
+	}
+	if f, ok := u.incremental.(interface{ Flush() error }); ok && len(changed) != 0 {
+		if err := f.Flush(); err != nil {
+			zlog.Warn(ctx).Err(err).Msg("unable to flush incremental store")
+		}
+	}
+	span.SetAttributes(attribute.Int("vulnerabilities", len(out)))
+	span.SetStatus(codes.Ok, "")
+	return out, nil
+}
+
+// DefSpan is the location of a "definition" element in a document.
+type defSpan struct {
+	ID         string
+	Start, End int
+}
+
+// ScanDefinitions finds the "definition" elements in "b" without decoding the
+// document. It also returns a digest of the document with all the definitions
+// and the "generator" element removed.
+//
+// If the document isn't laid out as expected or isn't UTF-8, no definitions
+// are returned.
+func scanDefinitions(b []byte) ([]defSpan, []byte) {
+	if !isUTF8Doc(b) {
+		return nil, nil
+	}
+	var (
+		startTag = []byte("<definition")
+		endTag   = []byte("</definition>")
+		idAttr   = []byte(` id="`)
+	)
+	h := sha256.New()
+	var defs []defSpan
+	rest := 0
+	if s, e := bytes.Index(b, []byte("<generator>")), bytes.Index(b, []byte("</generator>")); s != -1 && e > s {
+		h.Write(b[:s])
+		rest = e + len("</generator>")
+	}
+	for pos := rest; ; {
+		i := bytes.Index(b[pos:], startTag)
+		if i == -1 {
+			break
+		}
+		i += pos
+		// Skip "<definitions>".
+		next := i + len(startTag)
+		if next >= len(b) || !isSpace(b[next]) {
+			pos = next
+			continue
+		}
+		tagEnd := bytes.IndexByte(b[next:], '>')
+		if tagEnd == -1 {
+			return nil, nil
+		}
+		tag := b[next : next+tagEnd]
+		a := bytes.Index(tag, idAttr)
+		if a == -1 {
+			return nil, nil
+		}
+		id := tag[a+len(idAttr):]
+		q := bytes.IndexByte(id, '"')
+		if q == -1 {
+			return nil, nil
+		}
+		e := bytes.Index(b[next:], endTag)
+		if e == -1 {
+			return nil, nil
+		}
+		e += next + len(endTag)
+		h.Write(b[rest:i])
+		defs = append(defs, defSpan{ID: string(id[:q]), Start: i, End: e})
+		rest, pos = e, e
+	}
+	h.Write(b[rest:])
+	return defs, h.Sum(nil)
+}
+
+// IsUTF8Doc reports whether the XML declaration, if any, doesn't name an
+// encoding other than UTF-8. Definitions are decoded separately, without the
+// declaration, so other encodings can't be handled.
+func isUTF8Doc(b []byte) bool {
+	if !bytes.HasPrefix(b, []byte("<?xml")) {
+		return true
+	}
+	end := bytes.Index(b, []byte("?>"))
+	if end == -1 {
+		return false
+	}
+	decl := b[:end]
+	i := bytes.Index(decl, []byte("encoding="))
+	if i == -1 {
+		return true
+	}
+	v := decl[i+len("encoding="):]
+	if len(v) < 2 {
+		return false
+	}
+	q := v[0]
+	v = v[1:]
+	if j := bytes.IndexByte(v, q); j != -1 {
+		v = v[:j]
+	}
+	return bytes.EqualFold(v, []byte("utf-8")) || bytes.EqualFold(v, []byte("utf8"))
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// MemResults is the IncrementalResults used when the configured store
+// doesn't provide one.
+type memResults struct {
+	mu sync.Mutex
+	m  map[string][]*claircore.Vulnerability
+}
+
+func (r *memResults) GetVulnerabilities(id string) ([]*claircore.Vulnerability, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	vs, ok := r.m[id]
+	return vs, ok
+}
+
+func (r *memResults) SetVulnerabilities(id string, vs []*claircore.Vulnerability) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.m[id] = vs
+}
+
+// FileIncrementalStore is an IncrementalStore and IncrementalResults backed
+// by a JSON file.
+//
+// Changes are only written out by Flush.
+type FileIncrementalStore struct {
+	path string
+	mu   sync.Mutex
+	data incrementalFile
+}
+
+type incrementalFile struct {
+	Checksums       map[string]string                     `json:"checksums"`
+	Vulnerabilities map[string][]*claircore.Vulnerability `json:"vulnerabilities"`
+}
+
+var (
+	_ IncrementalStore   = (*FileIncrementalStore)(nil)
+	_ IncrementalResults = (*FileIncrementalStore)(nil)
+)
+
+// OpenFileIncrementalStore returns a FileIncrementalStore using the file at
+// "path". A missing file is treated as an empty store.
+func OpenFileIncrementalStore(path string) (*FileIncrementalStore, error) {
+	s := FileIncrementalStore{
+		path: path,
+		data: incrementalFile{
+			Checksums:       make(map[string]string),
+			Vulnerabilities: make(map[string][]*claircore.Vulnerability),
+		},
+	}
+	b, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, nil):
+	case errors.Is(err, fs.ErrNotExist):
+		return &s, nil
+	default:
+		return nil, fmt.Errorf("rhel: unable to open incremental store: %w", err)
+	}
+	if err := json.Unmarshal(b, &s.data); err != nil {
+		return nil, fmt.Errorf("rhel: unable to open incremental store: %w", err)
+	}
+	if s.data.Checksums == nil {
+		s.data.Checksums = make(map[string]string)
+	}
+	if s.data.Vulnerabilities == nil {
+		s.data.Vulnerabilities = make(map[string][]*claircore.Vulnerability)
+	}
+	return &s, nil
+}
+
+// GetChecksum implements IncrementalStore.
+func (s *FileIncrementalStore) GetChecksum(id string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sum, ok := s.data.Checksums[id]
+	return sum, ok
+}
+
+// SetChecksum implements IncrementalStore.
+func (s *FileIncrementalStore) SetChecksum(id, sum string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Checksums[id] = sum
+}
+
+// GetVulnerabilities implements IncrementalResults.
+func (s *FileIncrementalStore) GetVulnerabilities(id string) ([]*claircore.Vulnerability, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	vs, ok := s.data.Vulnerabilities[id]
+	return vs, ok
+}
+
+// SetVulnerabilities implements IncrementalResults.
+func (s *FileIncrementalStore) SetVulnerabilities(id string, vs []*claircore.Vulnerability) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Vulnerabilities[id] = vs
+}
+
+// Flush writes the store's contents to its file.
+func (s *FileIncrementalStore) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dir := filepath.Dir(s.path)
+	f, err := os.CreateTemp(dir, filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("rhel: unable to flush incremental store: %w", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if err := json.NewEncoder(f).Encode(&s.data); err != nil {
+		return fmt.Errorf("rhel: unable to flush incremental store: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("rhel: unable to flush incremental store: %w", err)
+	}
+	if err := os.Rename(f.Name(), s.path); err != nil {
+		return fmt.Errorf("rhel: unable to flush incremental store: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package rhel
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/toolkit/types/cpe"
+)
+
+// MapStore is an IncrementalStore that counts updates.
+type mapStore struct {
+	mu   sync.Mutex
+	m    map[string]string
+	sets int
+}
+
+func (s *mapStore) GetChecksum(id string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.m[id]
+	return v, ok
+}
+
+func (s *mapStore) SetChecksum(id, sum string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.m == nil {
+		s.m = make(map[string]string)
+	}
+	s.m[id] = sum
+	s.sets++
+}
+
+func TestIncremental(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	const file = "testdata/rhsa-module-synthetic.xml"
+	doc, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parse := func(t *testing.T, u *Updater, b []byte) []*claircore.Vulnerability {
+		t.Helper()
+		vs, err := u.Parse(ctx, io.NopCloser(bytes.NewReader(b)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return vs
+	}
+	u, err := NewUpdater(`rhel-8-updater`, 8, "file:///dev/null", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := parse(t, u, doc)
+	// The CPE binding used for JSON doesn't preserve the difference between
+	// unset and "ANY" attributes.
+	cpeCmp := cmp.Comparer(func(a, b cpe.WFN) bool { return a.BindFS() == b.BindFS() })
+
+	t.Run("Memory", func(t *testing.T) {
+		s := &mapStore{}
+		u, err := NewUpdater(`rhel-8-updater`, 8, "file:///dev/null", false, WithIncrementalStore(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := parse(t, u, doc); !cmp.Equal(got, want) {
+			t.Error(cmp.Diff(got, want))
+		}
+		n := s.sets
+		if n == 0 {
+			t.Fatal("no checksums recorded")
+		}
+		if got := parse(t, u, doc); !cmp.Equal(got, want) {
+			t.Error(cmp.Diff(got, want))
+		}
+		if got, want := s.sets, n; got != want {
+			t.Errorf("unchanged document: got %d updates, want %d", got-n, 0)
+		}
+
+		// Change one definition's title.
+		const from, to = "RHSA-2020:3102: curl security update (Moderate)", "RHSA-2020:3102: curl security update (Important)"
+		mod := bytes.Replace(doc, []byte(from), []byte(to), 1)
+		if bytes.Equal(mod, doc) {
+			t.Fatal("test document not modified")
+		}
+		got := parse(t, u, mod)
+		if got, want := s.sets-n, 1; got != want {
+			t.Errorf("modified document: got %d updates, want %d", got, want)
+		}
+		if got, want := len(got), len(want); got != want {
+			t.Fatalf("got %d vulnerabilities, want %d", got, want)
+		}
+		var seen bool
+		for _, v := range got {
+			seen = seen || v.Name == to
+		}
+		if !seen {
+			t.Error("modified definition not reprocessed")
+		}
+	})
+
+	t.Run("File", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "incremental.json")
+		s, err := OpenFileIncrementalStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, err := NewUpdater(`rhel-8-updater`, 8, "file:///dev/null", false, WithIncrementalStore(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := parse(t, u, doc); !cmp.Equal(got, want) {
+			t.Error(cmp.Diff(got, want))
+		}
+
+		// Simulate a restart.
+		s, err = OpenFileIncrementalStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, err = NewUpdater(`rhel-8-updater`, 8, "file:///dev/null", false, WithIncrementalStore(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := parse(t, u, doc); !cmp.Equal(got, want, cpeCmp) {
+			t.Error(cmp.Diff(got, want, cpeCmp))
+		}
+	})
+}
+
+// BenchmarkIncremental compares parsing a document from scratch to parsing it
+// again with an IncrementalStore.
+func BenchmarkIncremental(b *testing.B) {
+	ctx := zlog.Test(context.Background(), b)
+	doc, err := os.ReadFile("testdata/Red_Hat_Enterprise_Linux_3.xml")
+	if err != nil {
+		b.Fatal(err)
+	}
+	run := func(b *testing.B, u *Updater) {
+		b.Helper()
+		b.SetBytes(int64(len(doc)))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := u.Parse(ctx, io.NopCloser(bytes.NewReader(doc))); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	b.Run("Full", func(b *testing.B) {
+		u, err := NewUpdater(`rhel-3-updater`, 3, "file:///dev/null", false)
+		if err != nil {
+			b.Fatal(err)
+		}
+		run(b, u)
+	})
+	b.Run("Unchanged", func(b *testing.B) {
+		u, err := NewUpdater(`rhel-3-updater`, 3, "file:///dev/null", false, WithIncrementalStore(&mapStore{}))
+		if err != nil {
+			b.Fatal(err)
+		}
+		// Populate the store.
+		if _, err := u.Parse(ctx, io.NopCloser(bytes.NewReader(doc))); err != nil {
+			b.Fatal(err)
+		}
+		run(b, u)
+	})
+}
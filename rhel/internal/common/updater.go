@@ -64,6 +64,12 @@ func (u *Updater) Get(ctx context.Context, c *http.Client) (interface{}, error)
 	return u.value.Load(), err
 }
 
+// Value returns a pointer to the current copy of the value, without attempting
+// an update.
+func (u *Updater) Value() interface{} {
+	return u.value.Load()
+}
+
 // Fetch attempts to perform an atomic update of the mapping file.
 //
 // Fetch is safe to call concurrently.
@@ -0,0 +1,281 @@
+// Package csaf holds the subset of the CSAF 2.0 document model used by the
+// rhel packages, along with helpers for Red Hat's CSAF directories.
+//
+// A directory, like https://access.redhat.com/security/data/csaf/v2/advisories/,
+// has a "changes.csv" file listing every document by modification time, and an
+// "archive_latest.txt" file naming a compressed tar of every document as of
+// the date in its name.
+package csaf
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/quay/claircore/internal/zreader"
+	"github.com/quay/claircore/pkg/errs"
+)
+
+// Document is the subset of a CSAF 2.0 document that's used.
+//
+// Documents may be re-encoded from this type, so the fields should be kept a
+// superset of what's needed by any user.
+type Document struct {
+	Document struct {
+		AggregateSeverity struct {
+			Text string `json:"text"`
+		} `json:"aggregate_severity"`
+		Notes      []Note      `json:"notes,omitempty"`
+		References []Reference `json:"references,omitempty"`
+		Title      string      `json:"title,omitempty"`
+		Tracking   struct {
+			ID                 string    `json:"id"`
+			InitialReleaseDate time.Time `json:"initial_release_date"`
+		} `json:"tracking"`
+	} `json:"document"`
+	ProductTree struct {
+		Branches      []Branch       `json:"branches,omitempty"`
+		Relationships []Relationship `json:"relationships,omitempty"`
+	} `json:"product_tree"`
+	Vulnerabilities []Vulnerability `json:"vulnerabilities,omitempty"`
+}
+
+// Note is a document or vulnerability note.
+type Note struct {
+	Category string `json:"category"`
+	Text     string `json:"text"`
+}
+
+// Reference is a document or vulnerability reference.
+type Reference struct {
+	Category string `json:"category"`
+	URL      string `json:"url"`
+}
+
+// Branch is a node of the product tree.
+type Branch struct {
+	Branches []Branch `json:"branches,omitempty"`
+	Product  *Product `json:"product,omitempty"`
+}
+
+// Product is a product in the product tree.
+type Product struct {
+	ProductID string `json:"product_id"`
+	Helper    struct {
+		CPE  string `json:"cpe,omitempty"`
+		PURL string `json:"purl,omitempty"`
+	} `json:"product_identification_helper"`
+}
+
+// Relationship places a product on a platform, creating a new product.
+type Relationship struct {
+	FullProductName  Product `json:"full_product_name"`
+	ProductReference string  `json:"product_reference"`
+	RelatesTo        string  `json:"relates_to_product_reference,omitempty"`
+}
+
+// Vulnerability is a vulnerability addressed by a document.
+type Vulnerability struct {
+	CVE           string      `json:"cve"`
+	Notes         []Note      `json:"notes,omitempty"`
+	References    []Reference `json:"references,omitempty"`
+	ProductStatus struct {
+		Fixed              []string `json:"fixed,omitempty"`
+		KnownAffected      []string `json:"known_affected,omitempty"`
+		UnderInvestigation []string `json:"under_investigation,omitempty"`
+	} `json:"product_status"`
+	Remediations []Remediation `json:"remediations,omitempty"`
+	Scores       []Score       `json:"scores,omitempty"`
+}
+
+// Remediation describes the fix status of some products.
+type Remediation struct {
+	Category   string   `json:"category"`
+	Details    string   `json:"details"`
+	ProductIDs []string `json:"product_ids"`
+}
+
+// Score holds the CVSS vectors for some products.
+type Score struct {
+	CVSSv4 *CVSS `json:"cvss_v4,omitempty"`
+	CVSSv3 *CVSS `json:"cvss_v3,omitempty"`
+	CVSSv2 *CVSS `json:"cvss_v2,omitempty"`
+}
+
+// CVSS is a CVSS vector and its base score.
+type CVSS struct {
+	BaseScore    float64 `json:"baseScore"`
+	BaseSeverity string  `json:"baseSeverity"`
+	VectorString string  `json:"vectorString"`
+}
+
+// Products returns every product in the document's product tree, keyed by
+// product ID. Products created by relationships aren't included.
+func (d *Document) Products() map[string]*Product {
+	m := make(map[string]*Product)
+	var walk func([]Branch)
+	walk = func(bs []Branch) {
+		for i := range bs {
+			b := &bs[i]
+			if b.Product != nil {
+				m[b.Product.ProductID] = b.Product
+			}
+			walk(b.Branches)
+		}
+	}
+	walk(d.ProductTree.Branches)
+	return m
+}
+
+// SelfLink reports the document's "self" reference.
+func (d *Document) SelfLink() string {
+	for _, r := range d.Document.References {
+		if r.Category == "self" {
+			return r.URL
+		}
+	}
+	return ""
+}
+
+// Description reports the vulnerability's "description" note.
+func (v *Vulnerability) Description() string {
+	for _, n := range v.Notes {
+		if n.Category == "description" {
+			return strings.TrimSpace(n.Text)
+		}
+	}
+	return ""
+}
+
+// Change is a row of a "changes.csv" file.
+type Change struct {
+	// Path is the document's path, relative to the directory.
+	Path string
+	// Timestamp is the document's modification time, in RFC 3339 format.
+	Timestamp string
+}
+
+// ParseChanges reads a "changes.csv" file and returns the newest version of
+// every document modified on or after "since", newest first. The "since"
+// argument is a prefix of an RFC 3339 timestamp, like "2024-01-07"; if it's
+// empty, every document is returned.
+//
+// The file has rows of the form:
+//
+//	"2024/rhsa-2024_0001.json","2024-01-02T03:04:05+00:00"
+func ParseChanges(r io.Reader, since string) ([]Change, error) {
+	rd := csv.NewReader(r)
+	rd.FieldsPerRecord = 2
+	rd.ReuseRecord = true
+	idx := make(map[string]int)
+	var cs []Change
+	for {
+		rec, err := rd.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, &errs.ParseError{Offset: rd.InputOffset(), Err: err}
+		}
+		p, ts := strings.TrimSpace(rec[0]), strings.TrimSpace(rec[1])
+		// Timestamps in the same zone sort lexically.
+		if p == "" || ts < since {
+			continue
+		}
+		if i, ok := idx[p]; ok {
+			if ts > cs[i].Timestamp {
+				cs[i].Timestamp = ts
+			}
+			continue
+		}
+		idx[p] = len(cs)
+		cs = append(cs, Change{Path: p, Timestamp: ts})
+	}
+	sort.SliceStable(cs, func(i, j int) bool { return cs[i].Timestamp > cs[j].Timestamp })
+	return cs, nil
+}
+
+// ArchiveDate finds the date in an archive name like
+// "csaf_advisories_2024-01-07.tar.zst".
+var archiveDate = regexp.MustCompile(`[0-9]{4}-[0-9]{2}-[0-9]{2}`)
+
+// ArchiveDate returns the date in the archive name "name", like "2024-01-07",
+// or an empty string if there isn't one. Documents modified on or after the
+// date may not be in the archive.
+func ArchiveDate(name string) string {
+	return archiveDate.FindString(name)
+}
+
+// ReadArchiveName reads an "archive_latest.txt" file, returning the name of
+// the archive relative to the directory.
+func ReadArchiveName(r io.Reader) (string, error) {
+	s := bufio.NewScanner(r)
+	var name string
+	for s.Scan() && name == "" {
+		name = strings.TrimSpace(s.Text())
+	}
+	if err := s.Err(); err != nil {
+		return "", err
+	}
+	if name == "" || strings.Contains(name, "/") {
+		return "", fmt.Errorf("csaf: bad archive name %q", name)
+	}
+	return name, nil
+}
+
+// WalkArchive decompresses the archive read from "r" and calls "f" with the
+// path and contents of every JSON document in it. Paths are cleaned, so they
+// match the ones in "changes.csv".
+//
+// An error from "f" stops the walk and is returned.
+func WalkArchive(r io.Reader, f func(name string, r io.Reader) error) error {
+	zr, err := zreader.Reader(r)
+	if err != nil {
+		return fmt.Errorf("csaf: unable to decompress archive: %w", err)
+	}
+	defer zr.Close()
+	tr := tar.NewReader(zr)
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("csaf: unable to read archive: %w", err)
+		}
+		if h.Typeflag != tar.TypeReg || path.Ext(h.Name) != ".json" {
+			continue
+		}
+		if err := f(strings.TrimPrefix(path.Clean(h.Name), "/"), tr); err != nil {
+			return err
+		}
+	}
+}
+
+// Get issues a GET request with "c", returning an [errs.FetchError] for any
+// non-200 response.
+func Get(ctx context.Context, c *http.Client, u string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.Do(req)
+	if err != nil {
+		return nil, &errs.FetchError{URL: u, Err: err}
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, &errs.FetchError{URL: u, StatusCode: res.StatusCode}
+	}
+	return res, nil
+}
@@ -10,7 +10,16 @@ import (
 )
 
 // Matcher implements driver.Matcher.
-type Matcher struct{}
+type Matcher struct {
+	// FixStates, if not empty, restricts matches to vulnerabilities in one of
+	// the listed states, e.g. only [claircore.FixStateFixed] to suppress
+	// findings that can't be remediated.
+	//
+	// The FixState is stored with the vulnerability. Vulnerabilities written
+	// by older versions don't have one, so they're considered "fixed" if
+	// they have a fixed-in version and "affected" otherwise.
+	FixStates []claircore.FixState
+}
 
 var _ driver.Matcher = (*Matcher)(nil)
 
@@ -33,7 +42,27 @@ func (*Matcher) Query() []driver.MatchConstraint {
 }
 
 // Vulnerable implements driver.Matcher.
+//
+// Packages only match vulnerabilities for the same module stream: a
+// vulnerability with no module only applies to non-modular packages. The
+// datastore already constrains this via [driver.PackageModule], but it's
+// checked here as well so that the result doesn't depend on the query.
+//
+// If the Matcher has FixStates configured, vulnerabilities in other states
+// don't match.
+//
+// Vulnerabilities with a Range are for content other than RPMs, so they never
+// match.
 func (m *Matcher) Vulnerable(ctx context.Context, record *claircore.IndexRecord, vuln *claircore.Vulnerability) (bool, error) {
+	if vuln.Range != nil {
+		return false, nil
+	}
+	if vuln.Package != nil && record.Package.Module != vuln.Package.Module {
+		return false, nil
+	}
+	if !m.wantState(vuln) {
+		return false, nil
+	}
 	pkgVer := version.NewVersion(record.Package.Version)
 	var vulnVer version.Version
 	// Assume the vulnerability record we have is for the last known vulnerable
@@ -51,3 +80,24 @@ func (m *Matcher) Vulnerable(ctx context.Context, record *claircore.IndexRecord,
 	// compare version and architecture
 	return cmp(pkgVer.Compare(vulnVer)) && vuln.ArchOperation.Cmp(record.Package.Arch, vuln.Package.Arch), nil
 }
+
+// WantState reports whether the vulnerability's FixState is allowed by the
+// Matcher's configuration.
+func (m *Matcher) wantState(vuln *claircore.Vulnerability) bool {
+	if len(m.FixStates) == 0 {
+		return true
+	}
+	s := vuln.FixState
+	if s == "" {
+		s = claircore.FixStateAffected
+		if vuln.FixedInVersion != "" {
+			s = claircore.FixStateFixed
+		}
+	}
+	for _, w := range m.FixStates {
+		if s == w {
+			return true
+		}
+	}
+	return false
+}
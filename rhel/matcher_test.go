@@ -163,3 +163,181 @@ func TestVulnerable(t *testing.T) {
 		}
 	}
 }
+
+func TestVulnerableModule(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	u, err := NewUpdater(`rhel-8-updater`, 8, "file:///dev/null", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open("testdata/rhsa-module-synthetic.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs, err := u.Parse(ctx, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 5 packages, 2 cpes = 10 vulnerabilities
+	if got, want := len(vs), 10; got != want {
+		t.Fatalf("got: %d vulnerabilities, want: %d vulnerabilities", got, want)
+	}
+	byName := make(map[string]*claircore.Vulnerability)
+	for _, v := range vs {
+		byName[v.Package.Name] = v
+	}
+	for name, want := range map[string]string{
+		"php":        "php:7.3",
+		"php-cli":    "php:7.3",
+		"php-common": "php:7.3",
+		"curl":       "",
+		"libcurl":    "",
+	} {
+		v, ok := byName[name]
+		if !ok {
+			t.Errorf("missing vulnerability for %q", name)
+			continue
+		}
+		if got := v.Package.Module; got != want {
+			t.Errorf("%s: got module %q, want %q", name, got, want)
+		}
+	}
+
+	mkRecord := func(name, version, module string) *claircore.IndexRecord {
+		return &claircore.IndexRecord{
+			Package: &claircore.Package{
+				Name:    name,
+				Version: version,
+				Module:  module,
+				Arch:    "x86_64",
+			},
+		}
+	}
+	testCases := []vulnerableTestCase{
+		{
+			name: "same stream",
+			ir:   mkRecord("php", "7.3.5-5.module+el8.1.0+4560+e0eee7d6", "php:7.3"),
+			v:    byName["php"],
+			want: true,
+		},
+		{
+			name: "same stream, fixed",
+			ir:   mkRecord("php", "7.3.20-1.module+el8.2.0+7373+b272fdef", "php:7.3"),
+			v:    byName["php"],
+			want: false,
+		},
+		{
+			name: "other stream",
+			ir:   mkRecord("php", "7.2.24-1.module+el8.2.0+4601+7c76a223", "php:7.2"),
+			v:    byName["php"],
+			want: false,
+		},
+		{
+			name: "non-modular package, modular vulnerability",
+			ir:   mkRecord("php", "7.2.11-1.el8", ""),
+			v:    byName["php"],
+			want: false,
+		},
+		{
+			name: "non-modular",
+			ir:   mkRecord("curl", "7.61.1-11.el8", ""),
+			v:    byName["curl"],
+			want: true,
+		},
+		{
+			name: "modular package, non-modular vulnerability",
+			ir:   mkRecord("curl", "7.61.1-11.el8", "curl:7"),
+			v:    byName["curl"],
+			want: false,
+		},
+	}
+	m := &Matcher{}
+	for _, tc := range testCases {
+		got, err := m.Vulnerable(ctx, tc.ir, tc.v)
+		if err != nil {
+			t.Error(err)
+		}
+		if tc.want != got {
+			t.Errorf("%q failed: want %t, got %t", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestVulnerableFixState(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	u, err := NewUpdater(`rhel-8-updater`, 8, "file:///dev/null", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open("testdata/rhel-8-fixstate-synthetic.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs, err := u.Parse(ctx, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := make(map[string]*claircore.Vulnerability)
+	for _, v := range vs {
+		byName[v.Package.Name] = v
+	}
+	for name, want := range map[string]claircore.FixState{
+		"curl":    claircore.FixStateFixed,
+		"libfoo":  claircore.FixStateAffected,
+		"libbar":  claircore.FixStateWillNotFix,
+		"libbaz":  claircore.FixStateAffected,
+		"libqux":  claircore.FixStateUnderInvestigation,
+		"libquux": claircore.FixStateUnderInvestigation,
+	} {
+		v, ok := byName[name]
+		if !ok {
+			t.Errorf("missing vulnerability for %q", name)
+			continue
+		}
+		if got := v.FixState; got != want {
+			t.Errorf("%s: got fix state %q, want %q", name, got, want)
+		}
+	}
+
+	mkRecord := func(name, version string) *claircore.IndexRecord {
+		return &claircore.IndexRecord{
+			Package: &claircore.Package{
+				Name:    name,
+				Version: version,
+				Arch:    "x86_64",
+			},
+		}
+	}
+	// Vulnerabilities stored before the FixState was persisted don't have
+	// one, so make sure the fallback is exercised as well.
+	stripped := *byName["libfoo"]
+	stripped.FixState = ""
+	tcs := []struct {
+		Name   string
+		States []claircore.FixState
+		IR     *claircore.IndexRecord
+		V      *claircore.Vulnerability
+		Want   bool
+	}{
+		{Name: "NoFilter/Fixed", IR: mkRecord("curl", "7.61.1-22.el8"), V: byName["curl"], Want: true},
+		{Name: "NoFilter/WillNotFix", IR: mkRecord("libbar", "1.0-1.el8"), V: byName["libbar"], Want: true},
+		{Name: "Fixed/Fixed", States: []claircore.FixState{claircore.FixStateFixed}, IR: mkRecord("curl", "7.61.1-22.el8"), V: byName["curl"], Want: true},
+		{Name: "Fixed/Affected", States: []claircore.FixState{claircore.FixStateFixed}, IR: mkRecord("libfoo", "1.0-1.el8"), V: byName["libfoo"], Want: false},
+		{Name: "Fixed/WillNotFix", States: []claircore.FixState{claircore.FixStateFixed}, IR: mkRecord("libbar", "1.0-1.el8"), V: byName["libbar"], Want: false},
+		{Name: "Fixed/UnderInvestigation", States: []claircore.FixState{claircore.FixStateFixed}, IR: mkRecord("libqux", "1.0-1.el8"), V: byName["libqux"], Want: false},
+		{Name: "Affected/Stripped", States: []claircore.FixState{claircore.FixStateAffected}, IR: mkRecord("libfoo", "1.0-1.el8"), V: &stripped, Want: true},
+		{Name: "Several/WillNotFix", States: []claircore.FixState{claircore.FixStateFixed, claircore.FixStateWillNotFix}, IR: mkRecord("libbar", "1.0-1.el8"), V: byName["libbar"], Want: true},
+	}
+	for _, tc := range tcs {
+		m := &Matcher{FixStates: tc.States}
+		got, err := m.Vulnerable(ctx, tc.IR, tc.V)
+		if err != nil {
+			t.Error(err)
+		}
+		if got != tc.Want {
+			t.Errorf("%s: got %t, want %t", tc.Name, got, tc.Want)
+		}
+	}
+}
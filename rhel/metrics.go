@@ -0,0 +1,15 @@
+package rhel
+
+import (
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer trace.Tracer
+
+func init() {
+	tracer = otel.Tracer("github.com/quay/claircore/rhel",
+		trace.WithSchemaURL(semconv.SchemaURL),
+	)
+}
@@ -0,0 +1,198 @@
+package rhel
+
+import (
+	"context"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/quay/goval-parser/oval"
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/pkg/ovalutil"
+	"github.com/quay/claircore/pkg/pep440"
+)
+
+// These are the version formats of vulnerabilities for content other than
+// RPMs. The format is reported as the Kind of the Versions in the
+// vulnerability's Range.
+const (
+	formatDpkg    = "dpkg"
+	formatSemver  = "semver"
+	formatPEP440  = "pep440"
+	formatUnknown = "unknown"
+)
+
+// EarlierThan matches the comment of a criterion that checks for a version of
+// some content earlier than the fixed one, like
+// "Django is earlier than 3.1.14".
+//
+// Criteria that check the state of the host use other phrasing, like
+// "kernel earlier than 0:4.18.0-80.el8 is currently running".
+var earlierThan = regexp.MustCompile(`^(\S+) is earlier than (\S+)$`)
+
+// ObjectVulns returns vulnerabilities for the criteria of "def" that check
+// objects other than "rpminfo_object" objects, copying each of the prototype
+// vulnerabilities.
+//
+// The version extracted from each criterion is normalized by the kind of
+// object it checks:
+//
+//   - "dpkginfo_object" objects are reported with the EVR of their state, in
+//     the "dpkg" format.
+//   - "textfilecontent54_object" objects are reported in the format of the
+//     ecosystem the file belongs to: "semver" for Maven and npm, "pep440" for
+//     Python.
+//   - Other objects, and files of an unrecognized ecosystem, are reported in
+//     the "unknown" format.
+//
+// Criteria of objects other than "dpkginfo_object" objects are only used if
+// their comment names a fixed version, in the way Red Hat's OVAL comments
+// rpminfo criteria.
+func objectVulns(ctx context.Context, root *oval.Root, def *oval.Definition, protos []*claircore.Vulnerability) []*claircore.Vulnerability {
+	var vulns []*claircore.Vulnerability
+	for _, c := range criteria(&def.Criteria, nil) {
+		test, err := ovalutil.TestLookup(root, c.TestRef, func(kind string) bool {
+			// Handled by [ovalutil.RPMDefToVulns].
+			return kind != "rpminfo_test"
+		})
+		if err != nil {
+			continue
+		}
+		refs := test.ObjectRef()
+		if len(refs) == 0 {
+			continue
+		}
+		kind, i, err := root.Objects.Lookup(refs[0].ObjectRef)
+		if err != nil {
+			zlog.Debug(ctx).
+				Err(err).
+				Str("object_ref", refs[0].ObjectRef).
+				Msg("failed object lookup. moving to next criterion")
+			continue
+		}
+		var pkg *claircore.Package
+		var fixed string
+		var r *claircore.Range
+		switch kind {
+		case "dpkginfo_object":
+			pkg, fixed, r = dpkgVersion(root, &root.Objects.DpkgInfoObjects[i], test)
+		default:
+			m := earlierThan.FindStringSubmatch(c.Comment)
+			if m == nil {
+				continue
+			}
+			pkg = &claircore.Package{Name: m[1], Kind: claircore.BINARY}
+			fixed = m[2]
+			format := formatUnknown
+			if kind == "textfilecontent54_object" {
+				format = fileFormat(root.Objects.TextfileContent54Objects[i].Filepath)
+			}
+			r, err = versionRange(format, fixed)
+			if err != nil {
+				zlog.Debug(ctx).
+					Err(err).
+					Str("format", format).
+					Str("version", fixed).
+					Msg("unable to normalize version")
+				r, _ = versionRange(formatUnknown, fixed)
+			}
+		}
+		if pkg == nil {
+			continue
+		}
+		for _, p := range protos {
+			v := *p
+			pkg := *pkg
+			v.Package = &pkg
+			v.FixedInVersion = fixed
+			v.Range = r
+			vulns = append(vulns, &v)
+		}
+	}
+	return vulns
+}
+
+// Criteria appends all the criterions in "node" to "cs", depth first.
+func criteria(node *oval.Criteria, cs []*oval.Criterion) []*oval.Criterion {
+	for i := range node.Criterias {
+		cs = criteria(&node.Criterias[i], cs)
+	}
+	for i := range node.Criterions {
+		cs = append(cs, &node.Criterions[i])
+	}
+	return cs
+}
+
+// DpkgVersion returns the package and fixed version checked by a dpkginfo
+// test, or a nil Package if the test doesn't check a version.
+func dpkgVersion(root *oval.Root, obj *oval.DpkgInfoObject, test oval.Test) (*claircore.Package, string, *claircore.Range) {
+	states := test.StateRef()
+	if obj.Name == nil || obj.Name.Body == "" || len(states) == 0 {
+		return nil, "", nil
+	}
+	kind, i, err := root.States.Lookup(states[0].StateRef)
+	if err != nil || kind != "dpkginfo_state" {
+		return nil, "", nil
+	}
+	st := &root.States.DpkgInfoStates[i]
+	if st.EVR == nil || st.EVR.Body == "" {
+		return nil, "", nil
+	}
+	pkg := &claircore.Package{Name: obj.Name.Body, Kind: claircore.BINARY}
+	if st.Arch != nil {
+		pkg.Arch = st.Arch.Body
+	}
+	// Debian versions have no normalized form, so the Range only carries the
+	// format.
+	r, _ := versionRange(formatDpkg, st.EVR.Body)
+	return pkg, st.EVR.Body, r
+}
+
+// FileFormat reports the version format of the ecosystem that the file at
+// "p" belongs to. The path may be a pattern.
+func fileFormat(p string) string {
+	base := path.Base(p)
+	switch {
+	case base == "pom.properties", strings.Contains(p, "/META-INF/maven/"):
+		return formatSemver
+	case base == "package.json":
+		return formatSemver
+	case base == "METADATA", base == "PKG-INFO",
+		strings.Contains(p, "/site-packages/"), strings.Contains(p, "/dist-packages/"):
+		return formatPEP440
+	}
+	return formatUnknown
+}
+
+// VersionRange returns the Range of versions before "fixed", with the
+// versions normalized in "format".
+//
+// Formats without a normalized form get a Range with empty Versions of that
+// Kind.
+func versionRange(format, fixed string) (*claircore.Range, error) {
+	r := claircore.Range{
+		Lower: claircore.Version{Kind: format},
+		Upper: claircore.Version{Kind: format},
+	}
+	switch format {
+	case formatSemver:
+		v, err := semver.NewVersion(fixed)
+		if err != nil {
+			return nil, err
+		}
+		// Leave a leading epoch, like the other semver normalizations.
+		r.Upper.V[1] = int32(v.Major())
+		r.Upper.V[2] = int32(v.Minor())
+		r.Upper.V[3] = int32(v.Patch())
+	case formatPEP440:
+		v, err := pep440.Parse(fixed)
+		if err != nil {
+			return nil, err
+		}
+		r.Upper = v.Version()
+	}
+	return &r, nil
+}
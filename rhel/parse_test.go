@@ -1,16 +1,28 @@
 package rhel
 
 import (
+	"bytes"
 	"context"
 	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/quay/goval-parser/oval"
 	"github.com/quay/zlog"
 
+	"github.com/quay/claircore"
 	"github.com/quay/claircore/libvuln/driver"
+	"github.com/quay/claircore/pkg/errs"
+	"github.com/quay/claircore/pkg/pep440"
 )
 
 func TestCVEDefFromUnpatched(t *testing.T) {
@@ -72,31 +84,78 @@ func TestParse(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	f, err := os.Open("testdata/com.redhat.rhsa-20201980.xml")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	vs, err := u.Parse(ctx, f)
-	if err != nil {
-		t.Fatal(err)
+	type cvss struct {
+		V2, V3, V4                string
+		V2Score, V3Score, V4Score float64
 	}
-	t.Logf("found %d vulnerabilities", len(vs))
-	// 15 packages, 2 cpes = 30 vulnerabilities
-	if got, want := len(vs), 30; got != want {
-		t.Fatalf("got: %d vulnerabilities, want: %d vulnerabilities", got, want)
-	}
-	count := make(map[string]int)
-	for _, vuln := range vs {
-		count[vuln.Repo.Name]++
+	tcs := []struct {
+		File string
+		Want cvss
+	}{
+		{
+			File: "testdata/com.redhat.rhsa-20201980.xml",
+			Want: cvss{
+				V3:      "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N",
+				V3Score: 7.5,
+			},
+		},
+		{
+			// Same advisory, with base_metrics blocks added.
+			File: "testdata/rhsa-cvss4-synthetic.xml",
+			Want: cvss{
+				V2:      "AV:N/AC:L/Au:N/C:P/I:N/A:N",
+				V2Score: 5.0,
+				V3:      "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:N/A:N",
+				V3Score: 7.5,
+				V4:      "CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:H/VI:N/VA:N/SC:N/SI:N/SA:N",
+				V4Score: 8.7,
+			},
+		},
 	}
+	for _, tc := range tcs {
+		t.Run(filepath.Base(tc.File), func(t *testing.T) {
+			f, err := os.Open(tc.File)
+			if err != nil {
+				t.Fatal(err)
+			}
 
-	const (
-		base      = "cpe:/a:redhat:enterprise_linux:8"
-		appstream = "cpe:/a:redhat:enterprise_linux:8::appstream"
-	)
-	if count[base] != 15 || count[appstream] != 15 {
-		t.Fatalf("got: %v vulnerabilities with, want 15 of each", count)
+			vs, err := u.Parse(ctx, f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Logf("found %d vulnerabilities", len(vs))
+			// 15 packages, 2 cpes = 30 vulnerabilities
+			if got, want := len(vs), 30; got != want {
+				t.Fatalf("got: %d vulnerabilities, want: %d vulnerabilities", got, want)
+			}
+			count := make(map[string]int)
+			for _, vuln := range vs {
+				count[vuln.Repo.Name]++
+			}
+
+			const (
+				base      = "cpe:/a:redhat:enterprise_linux:8"
+				appstream = "cpe:/a:redhat:enterprise_linux:8::appstream"
+			)
+			if count[base] != 15 || count[appstream] != 15 {
+				t.Fatalf("got: %v vulnerabilities with, want 15 of each", count)
+			}
+			for _, v := range vs {
+				// Severity is the advisory's, with the scores reported
+				// separately.
+				if got, want := v.Severity, "Important"; got != want {
+					t.Errorf("got severity: %q, want: %q", got, want)
+				}
+				got := cvss{
+					V2: v.CVSSv2Vector, V2Score: v.CVSSv2Score,
+					V3: v.CVSSv3Vector, V3Score: v.CVSSv3Score,
+					V4: v.CVSSv4Vector, V4Score: v.CVSSv4Score,
+				}
+				if !cmp.Equal(got, tc.Want) {
+					t.Fatal(cmp.Diff(got, tc.Want))
+				}
+			}
+		})
 	}
 }
 
@@ -408,3 +467,535 @@ var ovalDef = oval.Definition{
 		},
 	},
 }
+
+func TestParseCPEFilter(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	const file = "testdata/rhsa-cpe-synthetic.xml"
+	tcs := []struct {
+		Name   string
+		Filter func(string) bool
+		// Want is the set of advisories expected.
+		Want []string
+	}{
+		{
+			Name: "None",
+			Want: []string{"RHSA-2020:3102", "RHSA-2020:3662"},
+		},
+		{
+			Name:   "BaseOS",
+			Filter: func(c string) bool { return c == "cpe:/o:redhat:enterprise_linux:8" },
+			Want:   []string{"RHSA-2020:3102"},
+		},
+		{
+			Name:   "AppStream",
+			Filter: func(c string) bool { return strings.HasSuffix(c, "::appstream") },
+			Want:   []string{"RHSA-2020:3662"},
+		},
+		{
+			Name:   "Unrelated",
+			Filter: func(c string) bool { return c == "cpe:/o:redhat:enterprise_linux:7" },
+			Want:   []string{},
+		},
+	}
+	for _, tc := range tcs {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			var opts []Option
+			if tc.Filter != nil {
+				opts = append(opts, WithCPEFilter(tc.Filter))
+			}
+			u, err := NewUpdater(`rhel-8-updater`, 8, "file:///dev/null", false, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f, err := os.Open(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			vs, err := u.Parse(ctx, f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			seen := make(map[string]struct{})
+			for _, v := range vs {
+				id, _, _ := strings.Cut(v.Name, ": ")
+				seen[id] = struct{}{}
+				// Every vulnerability carries the definition's full list.
+				if got, want := len(v.AffectedCPEs), 2; got != want {
+					t.Errorf("%s: got %d CPEs, want %d", v.Name, got, want)
+				}
+				found := false
+				for _, c := range v.AffectedCPEs {
+					found = found || c == v.Repo.Name
+				}
+				if !found {
+					t.Errorf("%s: repo %q missing from %v", v.Name, v.Repo.Name, v.AffectedCPEs)
+				}
+			}
+			got := make([]string, 0, len(seen))
+			for id := range seen {
+				got = append(got, id)
+			}
+			sort.Strings(got)
+			if !cmp.Equal(got, tc.Want) {
+				t.Error(cmp.Diff(got, tc.Want))
+			}
+		})
+	}
+
+	if _, err := NewUpdater(`rhel-8-updater`, 8, "file:///dev/null", false, WithCPEFilter(nil)); err == nil {
+		t.Error("expected error for nil filter")
+	}
+}
+
+func TestParseWorkers(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	fs, err := filepath.Glob("testdata/*.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parse := func(t *testing.T, file string, opts ...Option) []*claircore.Vulnerability {
+		t.Helper()
+		u, err := NewUpdater(`rhel-updater`, 8, "file:///dev/null", false, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vs, err := u.Parse(ctx, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return vs
+	}
+	for _, file := range fs {
+		file := file
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			t.Parallel()
+			if fi, err := os.Stat(file); err == nil && fi.Size() > 2<<20 && testing.Short() {
+				t.Skip("skipping large file in short mode")
+			}
+			want := parse(t, file, WithWorkers(1))
+			t.Logf("found %d vulnerabilities", len(want))
+			for _, n := range []int{2, 7} {
+				got := parse(t, file, WithWorkers(n))
+				// Cmp is much slower for this many values.
+				if !reflect.DeepEqual(got, want) {
+					t.Fatalf("%d workers: %s", n, cmp.Diff(got, want))
+				}
+			}
+		})
+	}
+
+	if _, err := NewUpdater(`rhel-updater`, 8, "file:///dev/null", false, WithWorkers(0)); err == nil {
+		t.Error("expected error for 0 workers")
+	}
+}
+
+func TestScanDefinitions(t *testing.T) {
+	t.Parallel()
+	tcs := []struct {
+		Name string
+		In   string
+		IDs  []string
+	}{
+		{
+			Name: "Simple",
+			In:   `<?xml version="1.0" encoding="UTF-8"?><oval_definitions><generator>x</generator><definitions><definition id="a" class="patch"><x/></definition>` + "<definition\n\tclass=\"patch\" id=\"b\"></definition></definitions><tests/></oval_definitions>",
+			IDs:  []string{"a", "b"},
+		},
+		{
+			Name: "Latin1",
+			In:   `<?xml version="1.0" encoding='ISO-8859-1'?><oval_definitions><definitions><definition id="a"></definition></definitions></oval_definitions>`,
+		},
+		{
+			Name: "Unterminated",
+			In:   `<oval_definitions><definitions><definition id="a"></definitions></oval_definitions>`,
+		},
+		{
+			Name: "NoID",
+			In:   `<oval_definitions><definitions><definition class="patch"></definition></definitions></oval_definitions>`,
+		},
+	}
+	for _, tc := range tcs {
+		defs, _ := scanDefinitions([]byte(tc.In))
+		var got []string
+		for _, d := range defs {
+			got = append(got, d.ID)
+			if s := tc.In[d.Start:d.End]; !strings.HasPrefix(s, "<definition") || !strings.HasSuffix(s, "</definition>") {
+				t.Errorf("%s: bad span: %q", tc.Name, s)
+			}
+		}
+		if !cmp.Equal(got, tc.IDs) {
+			t.Errorf("%s: %s", tc.Name, cmp.Diff(got, tc.IDs))
+		}
+	}
+}
+
+func TestMinSeverity(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	const file = "testdata/com.redhat.rhsa-RHEL8.xml"
+	parse := func(t *testing.T, opts ...Option) []*claircore.Vulnerability {
+		t.Helper()
+		u, err := NewUpdater(`rhel-8-updater`, 8, "file:///dev/null", false, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vs, err := u.Parse(ctx, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return vs
+	}
+	// Severity of a returned vulnerability, computed the same way as for the
+	// definition it came from.
+	sev := func(v *claircore.Vulnerability) Severity {
+		switch {
+		case v.CVSSv4Vector != "":
+			return scoreSeverity(v.CVSSv4Score)
+		case v.CVSSv3Vector != "":
+			return scoreSeverity(v.CVSSv3Score)
+		case v.CVSSv2Vector != "":
+			return scoreSeverity(v.CVSSv2Score)
+		}
+		return defSeverity(&oval.Definition{Advisory: oval.Advisory{Severity: v.Severity}}, &cvssScores{})
+	}
+
+	prev := len(parse(t))
+	t.Logf("unfiltered: %d vulnerabilities", prev)
+	for _, s := range []Severity{SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical} {
+		vs := parse(t, WithMinSeverity(s))
+		t.Logf("%v: %d vulnerabilities", s, len(vs))
+		if len(vs) > prev {
+			t.Errorf("%v: more vulnerabilities (%d) than a lower threshold (%d)", s, len(vs), prev)
+		}
+		prev = len(vs)
+		for _, v := range vs {
+			if got := sev(v); got != 0 && got < s {
+				t.Errorf("%v: %s: unexpectedly kept with severity %v", s, v.Name, got)
+			}
+		}
+	}
+	if len(parse(t, WithMinSeverity(SeverityHigh))) == len(parse(t, WithMinSeverity(SeverityLow))) {
+		t.Error("filter had no effect")
+	}
+
+	for _, s := range []Severity{0, SeverityCritical + 1} {
+		if _, err := NewUpdater(`rhel-8-updater`, 8, "file:///dev/null", false, WithMinSeverity(s)); err == nil {
+			t.Errorf("%v: expected error", s)
+		}
+	}
+}
+
+func TestUnpatchedStates(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	for _, tc := range []struct {
+		Name   string
+		States []claircore.FixState
+		Want   []string
+	}{
+		{
+			Name: "Default",
+			Want: []string{"curl", "libbar", "libbaz", "libfoo", "libquux", "libqux"},
+		},
+		{
+			Name:   "Affected",
+			States: []claircore.FixState{claircore.FixStateAffected},
+			Want:   []string{"curl", "libbaz", "libfoo"},
+		},
+		{
+			Name:   "WillNotFix",
+			States: []claircore.FixState{claircore.FixStateAffected, claircore.FixStateWillNotFix},
+			Want:   []string{"curl", "libbar", "libbaz", "libfoo"},
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			var opts []Option
+			if tc.States != nil {
+				opts = append(opts, WithUnpatchedStates(tc.States...))
+			}
+			u, err := NewUpdater(`rhel-8-updater`, 8, "file:///dev/null", false, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f, err := os.Open("testdata/rhel-8-fixstate-synthetic.xml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			vs, err := u.Parse(ctx, f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]string, 0, len(vs))
+			for _, v := range vs {
+				if v.FixedInVersion != "" && v.FixState != claircore.FixStateFixed {
+					t.Errorf("%s: unexpected state %q", v.Package.Name, v.FixState)
+				}
+				got = append(got, v.Package.Name)
+			}
+			sort.Strings(got)
+			if !cmp.Equal(got, tc.Want) {
+				t.Error(cmp.Diff(got, tc.Want))
+			}
+		})
+	}
+
+	for _, bad := range [][]claircore.FixState{
+		nil,
+		{claircore.FixStateFixed},
+		{"bogus"},
+	} {
+		if _, err := NewUpdater(`rhel-8-updater`, 8, "file:///dev/null", false, WithUnpatchedStates(bad...)); err == nil {
+			t.Errorf("%v: expected error", bad)
+		}
+	}
+}
+
+// TestNonRPMCriteria checks that criteria using tests other than rpminfo
+// tests, which the RHEL 8 fixture uses to check the kernel set to boot, don't
+// produce vulnerabilities.
+func TestNonRPMCriteria(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	b, err := os.ReadFile("testdata/com.redhat.rhsa-RHEL8.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var root oval.Root
+	if err := xml.Unmarshal(b, &root); err != nil {
+		t.Fatal(err)
+	}
+	if len(root.Objects.TextfileContent54Objects) == 0 {
+		t.Fatal("fixture has no textfilecontent54 objects")
+	}
+	names := make(map[string]struct{})
+	for _, o := range root.Objects.RPMInfoObjects {
+		names[o.Name] = struct{}{}
+	}
+
+	u, err := NewUpdater(`rhel-8-updater`, 8, "file:///dev/null", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs, err := u.Parse(ctx, io.NopCloser(bytes.NewReader(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range vs {
+		if _, ok := names[v.Package.Name]; !ok || v.Range != nil {
+			t.Errorf("%s: package %q is not from an rpminfo object", v.Name, v.Package.Name)
+		}
+	}
+}
+
+// TestObjectVulns checks the vulnerabilities produced from the criteria of
+// objects other than rpminfo objects, in an RHSCL advisory.
+func TestObjectVulns(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	u, err := NewUpdater(`rhel-7-updater`, 7, "file:///dev/null", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open("testdata/rhsa-rhscl-synthetic.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs, err := u.Parse(ctx, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	empty := func(kind string) *claircore.Range {
+		return &claircore.Range{
+			Lower: claircore.Version{Kind: kind},
+			Upper: claircore.Version{Kind: kind},
+		}
+	}
+	django, err := pep440.Parse("3.1.14")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pyRange := empty("pep440")
+	pyRange.Upper = django.Version()
+	semverRange := func(major, minor, patch int32) *claircore.Range {
+		r := empty("semver")
+		r.Upper.V[1], r.Upper.V[2], r.Upper.V[3] = major, minor, patch
+		return r
+	}
+	type found struct {
+		Fixed string
+		Range *claircore.Range
+	}
+	want := map[string]found{
+		"rh-python38-python-django":       {Fixed: "0:3.1.14-1.el7"},
+		"Django":                          {Fixed: "3.1.14", Range: pyRange},
+		"lodash":                          {Fixed: "4.17.21", Range: semverRange(4, 17, 21)},
+		"org.apache.commons:commons-text": {Fixed: "1.10.0", Range: semverRange(1, 10, 0)},
+		"com.fasterxml.jackson.core:jackson-databind": {Fixed: "2.13.4.2", Range: empty("unknown")},
+		"fips-provider": {Fixed: "3.0.7", Range: empty("unknown")},
+		"libfoo":        {Fixed: "0:1.2-3", Range: empty("dpkg")},
+	}
+	got := make(map[string]found, len(vs))
+	for _, v := range vs {
+		if v.FixState != claircore.FixStateFixed {
+			t.Errorf("%s: unexpected state %q", v.Package.Name, v.FixState)
+		}
+		got[v.Package.Name] = found{Fixed: v.FixedInVersion, Range: v.Range}
+	}
+	if !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(got, want))
+	}
+
+	// Only the RPM is matched by the Matcher.
+	var m Matcher
+	for _, v := range vs {
+		rec := &claircore.IndexRecord{Package: &claircore.Package{Name: v.Package.Name, Version: "0:0.1-1.el7", Arch: "noarch"}}
+		ok, err := m.Vulnerable(ctx, rec, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := v.Range == nil; ok != want {
+			t.Errorf("%s: got vulnerable %v, want %v", v.Package.Name, ok, want)
+		}
+	}
+}
+
+// BenchmarkMinSeverity compares parsing the RHEL 8 fixture with and without
+// a severity threshold.
+func BenchmarkMinSeverity(b *testing.B) {
+	ctx := zlog.Test(context.Background(), b)
+	doc, err := os.ReadFile("testdata/com.redhat.rhsa-RHEL8.xml")
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, tc := range []struct {
+		Name string
+		Opts []Option
+	}{
+		{Name: "All"},
+		{Name: "High", Opts: []Option{WithMinSeverity(SeverityHigh)}},
+	} {
+		b.Run(tc.Name, func(b *testing.B) {
+			u, err := NewUpdater(`rhel-8-updater`, 8, "file:///dev/null", false,
+				append([]Option{WithWorkers(1)}, tc.Opts...)...)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(int64(len(doc)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := u.Parse(ctx, io.NopCloser(bytes.NewReader(doc))); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestParseError(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	doc, err := os.ReadFile("testdata/com.redhat.rhsa-20201980.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Truncate the document in the middle of the definitions.
+	doc = doc[:bytes.Index(doc, []byte("</definitions>"))-100]
+	for _, n := range []int{1, 2} {
+		u, err := NewUpdater(`rhel-8-updater`, 8, "file:///dev/null", false, WithWorkers(n))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = u.Parse(ctx, io.NopCloser(bytes.NewReader(doc)))
+		var pe *errs.ParseError
+		if !errors.As(err, &pe) {
+			t.Errorf("%d workers: got error %#v, want %T", n, err, pe)
+			continue
+		}
+		t.Logf("%d workers: %v", n, err)
+		if pe.Offset <= 0 {
+			t.Errorf("%d workers: unexpected offset: %d", n, pe.Offset)
+		}
+		if errs.Transient(err) {
+			t.Errorf("%d workers: parse error reported as transient", n)
+		}
+	}
+}
+
+// EndlessDefs is an OVAL document that never ends, calling "cancel" once
+// "after" definitions have been read.
+type endlessDefs struct {
+	n, after int
+	cancel   context.CancelFunc
+	head     bool
+}
+
+func (r *endlessDefs) Read(b []byte) (int, error) {
+	if !r.head {
+		r.head = true
+		return copy(b, `<?xml version="1.0" encoding="UTF-8"?>`+
+			`<oval_definitions xmlns="http://oval.mitre.org/XMLSchema/oval-definitions-5"><definitions>`), nil
+	}
+	r.n++
+	if r.n == r.after {
+		r.cancel()
+	}
+	return copy(b, fmt.Sprintf(`<definition class="patch" id="oval:com.redhat.rhsa:def:%d" version="1">`+
+		`<metadata><title>RHSA-%[1]d</title></metadata></definition>`, r.n)), nil
+}
+
+func TestParseCancel(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+
+	t.Run("Stream", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		u, err := NewUpdater(`rhel-8-updater`, 8, "file:///dev/null", false, WithWorkers(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := &endlessDefs{after: 10, cancel: cancel}
+		done := make(chan error, 1)
+		go func() {
+			_, err := u.Parse(ctx, io.NopCloser(r))
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("got error %v, want %v", err, context.Canceled)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("parse not interrupted")
+		}
+		t.Logf("read %d definitions", r.n)
+	})
+	t.Run("Workers", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		u, err := NewUpdater(`rhel-8-updater`, 8, "file:///dev/null", false, WithWorkers(2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Open("testdata/com.redhat.rhsa-20201980.xml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := u.Parse(ctx, f); !errors.Is(err, context.Canceled) {
+			t.Errorf("got error %v, want %v", err, context.Canceled)
+		}
+	})
+}
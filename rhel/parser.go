@@ -3,14 +3,21 @@ package rhel
 import (
 	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
+	"strconv"
+	"strings"
 
 	"github.com/quay/goval-parser/oval"
 	"github.com/quay/zlog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/quay/claircore"
 	"github.com/quay/claircore/internal/xmlutil"
+	"github.com/quay/claircore/pkg/errs"
 	"github.com/quay/claircore/pkg/ovalutil"
 	"github.com/quay/claircore/rhel/internal/common"
 	"github.com/quay/claircore/toolkit/types/cpe"
@@ -22,17 +29,143 @@ import (
 // flavored OVAL XML. The distribution associated with vulnerabilities
 // is configured via the Updater. The repository associated with
 // vulnerabilies is based on the affected CPE list.
+//
+// Every distinct CVSS vector present in the definition is reported in the
+// CVSS list of the returned vulnerabilities, tagged with its exact version,
+// with the authoritative one marked; see [CVSSVectors]. The per-version CVSS
+// fields hold the vector of the newest minor version of each major version. Both the attributes on "cve" elements and "base_metrics"
+// elements (which are needed for CVSS v4) are understood. The Severity is the
+// advisory's severity.
+//
+// The non-empty entries of the definition's affected CPE list are reported in
+// the AffectedCPEs field of every vulnerability produced from it. If the
+// Updater was configured with WithCPEFilter, definitions without a matching
+// CPE are skipped.
+//
+// The FixState is "fixed" for any vulnerability with a fixed-in version. For
+// the rest, the per-component resolution state from the advisory is used if
+// present. Otherwise, patch definitions are "fixed" and CVE definitions are
+// "affected", or "under investigation" if the advisory has no severity yet.
+// If the Updater was configured with WithUnpatchedStates, vulnerabilities
+// without a fixed-in version in other states are skipped.
+//
+// If the Updater was configured with WithMinSeverity, less severe definitions
+// are skipped.
+//
+// Criteria with "rpminfo_test" tests produce vulnerabilities with the
+// package's fixed EVR. Criteria checking other objects, like the files of
+// RHSCL content that isn't packaged as RPMs, produce vulnerabilities with a
+// Range in the format of their ecosystem, or "unknown"; see objectVulns.
+// Criteria that check the host's state, like which kernel is set to boot,
+// don't describe a package and are skipped.
+//
+// The Context is checked after every definition is decoded or converted, so
+// cancelling it interrupts parsing a large document. In that case, the
+// Context's error is returned.
 func (u *Updater) Parse(ctx context.Context, r io.ReadCloser) ([]*claircore.Vulnerability, error) {
 	ctx = zlog.ContextWithValues(ctx, "component", "rhel/Updater.Parse")
+	ctx, span := u.getTracer().Start(ctx, "rhel.updater.parse",
+		trace.WithAttributes(attribute.String("updater", u.name)))
+	defer span.End()
 	zlog.Info(ctx).Msg("starting parse")
 	defer r.Close()
+	switch {
+	case u.incremental != nil:
+		return u.parseIncremental(ctx, r)
+	case u.numWorkers() > 1:
+		return u.parseParallel(ctx, r)
+	}
+	root, scores, err := u.decode(ctx, r)
+	if err != nil {
+		span.SetStatus(codes.Error, "decode error")
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("definitions", len(root.Definitions.Definitions)))
+	vulns, err := u.convert(ctx, root, scores)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "conversion error")
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("vulnerabilities", len(vulns)))
+	span.SetStatus(codes.Ok, "")
+	return vulns, nil
+}
+
+// Decode decodes the OVAL document in "r", returning it along with any CVSS
+// scores found in "base_metrics" elements, keyed by definition ID.
+func (u *Updater) decode(ctx context.Context, r io.Reader) (*oval.Root, map[string]*cvssScores, error) {
 	root := oval.Root{}
-	dec := xml.NewDecoder(r)
-	dec.CharsetReader = xmlutil.CharsetReader
-	if err := dec.Decode(&root); err != nil {
-		return nil, fmt.Errorf("rhel: unable to decode OVAL document: %w", err)
+	raw := xml.NewDecoder(r)
+	raw.CharsetReader = xmlutil.CharsetReader
+	// The OVAL types don't have a place for CVSS base_metrics blocks, so
+	// they're pulled out of the token stream as it's decoded.
+	metrics := metricsScanner{ctx: ctx, d: raw}
+	dec := xml.NewTokenDecoder(&metrics)
+	_, decSpan := u.getTracer().Start(ctx, "rhel.updater.parse.decode")
+	defer decSpan.End()
+	err := dec.Decode(&root)
+	decSpan.SetAttributes(attribute.Int64("bytes", raw.InputOffset()))
+	if err != nil {
+		decSpan.RecordError(err)
+		decSpan.SetStatus(codes.Error, "decode error")
+		if ctxErr := ctx.Err(); ctxErr != nil && errors.Is(err, ctxErr) {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("rhel: unable to decode OVAL document: %w", &errs.ParseError{Offset: raw.InputOffset(), Err: err})
 	}
+	decSpan.SetStatus(codes.Ok, "")
 	zlog.Debug(ctx).Msg("xml decoded")
+	return &root, metrics.Scores, nil
+}
+
+// Convert turns the definitions in "root" into vulnerabilities.
+//
+// The Context is checked after every definition, and its error is returned if
+// it's done.
+func (u *Updater) convert(ctx context.Context, root *oval.Root, metrics map[string]*cvssScores) ([]*claircore.Vulnerability, error) {
+	vulns := make([]*claircore.Vulnerability, 0, 10000)
+	for i := range root.Definitions.Definitions {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		def := &root.Definitions.Definitions[i]
+		vs, err := u.defVulns(ctx, root, def, metrics[def.ID])
+		if err != nil {
+			zlog.Debug(ctx).
+				Err(err).
+				Str("def_id", def.ID).
+				Msg("could not create prototype vulnerabilities")
+			continue
+		}
+		vulns = append(vulns, vs...)
+	}
+	return vulns, nil
+}
+
+// DefVulns turns a single definition into vulnerabilities. The "metrics" are
+// any scores found in "base_metrics" elements in the definition.
+//
+// The root is only read, so this can be called concurrently with the same
+// root.
+func (u *Updater) defVulns(ctx context.Context, root *oval.Root, def *oval.Definition, metrics *cvssScores) ([]*claircore.Vulnerability, error) {
+	var scores cvssScores
+	for _, c := range def.Advisory.Cves {
+		scores.AddOVAL(c.Cvss2, c.Cvss3)
+	}
+	if metrics != nil {
+		scores.Merge(metrics)
+	}
+	if u.minSeverity != 0 {
+		if s := defSeverity(def, &scores); s != 0 && s < u.minSeverity {
+			return []*claircore.Vulnerability{}, nil
+		}
+	}
+	// Resolution states are per-component, so they can only be applied once
+	// the packages are known.
+	var resolved map[string]claircore.FixState
+	// The prototypes are kept for the criteria of other objects.
+	var protos []*claircore.Vulnerability
 	protoVulns := func(def oval.Definition) ([]*claircore.Vulnerability, error) {
 		vs := []*claircore.Vulnerability{}
 
@@ -47,6 +180,8 @@ func (u *Updater) Parse(ctx context.Context, r io.ReadCloser) ([]*claircore.Vuln
 			return vs, nil
 		}
 
+		cpes := make([]string, 0, len(def.Advisory.AffectedCPEList))
+		keep := u.cpeFilter == nil
 		for _, affected := range def.Advisory.AffectedCPEList {
 			// Work around having empty entries. This seems to be some issue
 			// with the tool used to produce the database but only seems to
@@ -54,7 +189,19 @@ func (u *Updater) Parse(ctx context.Context, r io.ReadCloser) ([]*claircore.Vuln
 			if affected == "" {
 				continue
 			}
+			cpes = append(cpes, affected)
+			if !keep && u.cpeFilter(affected) {
+				keep = true
+			}
+		}
+		if !keep {
+			return vs, nil
+		}
+
+		state, components := fixStates(&def)
+		resolved = components
 
+		for _, affected := range cpes {
 			wfn, err := cpe.Unbind(affected)
 			if err != nil {
 				return nil, err
@@ -72,17 +219,37 @@ func (u *Updater) Parse(ctx context.Context, r io.ReadCloser) ([]*claircore.Vuln
 					CPE:  wfn,
 					Key:  repositoryKey,
 				},
-				Dist: u.dist,
+				Dist:         u.dist,
+				AffectedCPEs: cpes,
+				FixState:     state,
 			}
+			scores.Apply(v)
 			vs = append(vs, v)
 		}
+		protos = vs
 		return vs, nil
 	}
-	vulns, err := ovalutil.RPMDefsToVulns(ctx, &root, protoVulns)
+	vulns, err := ovalutil.RPMDefToVulns(ctx, root, def, protoVulns)
 	if err != nil {
 		return nil, err
 	}
-	return vulns, nil
+	vulns = append(vulns, objectVulns(ctx, root, def, protos)...)
+	out := vulns[:0]
+	for _, v := range vulns {
+		switch {
+		case v.FixedInVersion != "":
+			v.FixState = claircore.FixStateFixed
+		case resolved != nil:
+			if s, ok := resolved[v.Package.Name]; ok {
+				v.FixState = s
+			}
+		}
+		if v.FixedInVersion == "" && !u.keepUnpatched(v.FixState) {
+			continue
+		}
+		out = append(out, v)
+	}
+	return out, nil
 }
 
 func isSkippableDefinitionType(defType ovalutil.DefinitionType, ignoreUnpatched bool) bool {
@@ -90,3 +257,121 @@ func isSkippableDefinitionType(defType ovalutil.DefinitionType, ignoreUnpatched
 		defType == ovalutil.NoneDefinition ||
 		(ignoreUnpatched && defType == ovalutil.CVEDefinition)
 }
+
+// FixStates reports the FixState for a definition as a whole, and for the
+// components named in the advisory's resolutions, if any.
+func fixStates(def *oval.Definition) (claircore.FixState, map[string]claircore.FixState) {
+	state := claircore.FixStateAffected
+	switch {
+	case def.Class == "patch":
+		state = claircore.FixStateFixed
+	case def.Advisory.Severity == "":
+		state = claircore.FixStateUnderInvestigation
+	}
+	var components map[string]claircore.FixState
+	for _, r := range def.Advisory.Affected.Resolutions {
+		var s claircore.FixState
+		// See the documentation on [oval.Resolution] for the possible
+		// values.
+		switch strings.ToLower(r.State) {
+		case "affected", "fix deferred":
+			s = claircore.FixStateAffected
+		case "will not fix", "out of support scope":
+			s = claircore.FixStateWillNotFix
+		case "under investigation":
+			s = claircore.FixStateUnderInvestigation
+		default:
+			continue
+		}
+		if components == nil {
+			components = make(map[string]claircore.FixState, len(r.Components))
+		}
+		for _, c := range r.Components {
+			components[c] = s
+		}
+	}
+	return state, components
+}
+
+// MetricsScanner is an [xml.TokenReader] that removes CVSS "base_metrics"
+// elements from the token stream, recording the scores by the ID of the
+// containing definition.
+//
+// If "ctx" is set, it's checked at the end of every definition so that
+// decoding a large document can be interrupted.
+//
+// These look like:
+//
+//	<cvss:base_metrics version="4.0">
+//	  <cvss:vectorString>CVSS:4.0/AV:N/...</cvss:vectorString>
+//	  <cvss:baseScore>8.7</cvss:baseScore>
+//	</cvss:base_metrics>
+type metricsScanner struct {
+	ctx    context.Context
+	d      *xml.Decoder
+	def    string
+	Scores map[string]*cvssScores
+}
+
+// Token implements [xml.TokenReader].
+func (s *metricsScanner) Token() (xml.Token, error) {
+	for {
+		t, err := s.d.Token()
+		if err != nil {
+			return t, err
+		}
+		switch t := t.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "definition":
+				for _, a := range t.Attr {
+					if a.Name.Local == "id" {
+						s.def = a.Value
+					}
+				}
+			case "base_metrics":
+				var m struct {
+					Version string  `xml:"version,attr"`
+					Vector  string  `xml:"vectorString"`
+					Score   float64 `xml:"baseScore"`
+				}
+				if err := s.d.DecodeElement(&m, &t); err != nil {
+					return nil, err
+				}
+				s.add(m.Version, scoredVector{Vector: m.Vector, Score: m.Score})
+				continue
+			}
+		case xml.EndElement:
+			if t.Name.Local == "definition" {
+				s.def = ""
+				if s.ctx != nil {
+					if err := s.ctx.Err(); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+		return t, nil
+	}
+}
+
+// Add records the vector for the current definition.
+func (s *metricsScanner) add(version string, v scoredVector) {
+	if s.def == "" {
+		return
+	}
+	major, _, _ := strings.Cut(version, ".")
+	n, err := strconv.Atoi(major)
+	if err != nil {
+		return
+	}
+	if s.Scores == nil {
+		s.Scores = make(map[string]*cvssScores)
+	}
+	c, ok := s.Scores[s.def]
+	if !ok {
+		c = new(cvssScores)
+		s.Scores[s.def] = c
+	}
+	c.Add(n, v)
+}
@@ -0,0 +1,107 @@
+package rhel
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/quay/claircore"
+)
+
+// VulnerabilityReport wraps a [claircore.VulnerabilityReport] to provide
+// reporting helpers.
+type VulnerabilityReport struct {
+	*claircore.VulnerabilityReport
+}
+
+// ReportDesc describes the gauge exported by the collector returned from
+// ToPrometheus.
+func reportDesc(manifest string) *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName("claircore", "rhel", "vulnerabilities"),
+		"Number of vulnerabilities affecting packages in a vulnerability report.",
+		[]string{"severity", "package_type"},
+		prometheus.Labels{"manifest": manifest},
+	)
+}
+
+// ToPrometheus registers a collector for the report with the Registerer and
+// returns it. The collector exports a gauge named
+// "claircore_rhel_vulnerabilities" with a "manifest" label set to the
+// report's manifest digest, and "severity" and "package_type" labels, set to
+// the number of package-vulnerability pairs in the report.
+//
+// Each report has its own collector, so collectors for different manifests can
+// be registered with the same Registerer at the same time. Registering a
+// report for a manifest that's already registered fails with a
+// [prometheus.AlreadyRegisteredError]; callers should Unregister the returned
+// collector when the report is superseded.
+//
+// The "package_type" label is "rpm" for vulnerabilities from Red Hat's
+// security data. For other vulnerabilities it's the affected package's kind,
+// "binary" or "source", or "unknown".
+func (r VulnerabilityReport) ToPrometheus(reg prometheus.Registerer) (prometheus.Collector, error) {
+	c := newReportCollector(r.VulnerabilityReport)
+	if err := reg.Register(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ReportCollector is a prometheus.Collector reporting the counts computed
+// from a single VulnerabilityReport.
+type reportCollector struct {
+	desc   *prometheus.Desc
+	counts map[[2]string]float64
+}
+
+var _ prometheus.Collector = (*reportCollector)(nil)
+
+// NewReportCollector computes the counts for the report "r".
+func newReportCollector(r *claircore.VulnerabilityReport) *reportCollector {
+	c := &reportCollector{
+		desc:   reportDesc(r.Hash.String()),
+		counts: make(map[[2]string]float64),
+	}
+	for pkgID, ids := range r.PackageVulnerabilities {
+		pkg := r.Packages[pkgID]
+		for _, id := range ids {
+			v, ok := r.Vulnerabilities[id]
+			if !ok {
+				continue
+			}
+			sev := strings.ToLower(v.NormalizedSeverity.String())
+			c.counts[[2]string{sev, packageType(v, pkg)}]++
+		}
+	}
+	return c
+}
+
+// Describe implements prometheus.Collector.
+func (c *reportCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector.
+func (c *reportCollector) Collect(ch chan<- prometheus.Metric) {
+	for k, n := range c.counts {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, n, k[0], k[1])
+	}
+}
+
+// PackageType returns the value of the "package_type" label for the
+// vulnerability affecting "pkg", which may be nil. The value is always from a
+// small, fixed set.
+func packageType(v *claircore.Vulnerability, pkg *claircore.Package) string {
+	if v.Repo != nil && v.Repo.Key == repositoryKey {
+		return "rpm"
+	}
+	if pkg == nil {
+		return "unknown"
+	}
+	switch pkg.Kind {
+	case claircore.BINARY, claircore.SOURCE:
+		return pkg.Kind
+	}
+	return "unknown"
+}
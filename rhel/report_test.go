@@ -0,0 +1,82 @@
+package rhel
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/quay/claircore"
+)
+
+func TestToPrometheus(t *testing.T) {
+	t.Parallel()
+	rpmRepo := &claircore.Repository{Name: "cpe:/o:redhat:enterprise_linux:8::baseos", Key: repositoryKey}
+	mkReport := func(digest string) VulnerabilityReport {
+		return VulnerabilityReport{&claircore.VulnerabilityReport{
+			Hash: claircore.MustParseDigest("sha256:" + strings.Repeat(digest, 64)),
+			Packages: map[string]*claircore.Package{
+				"a": {Kind: claircore.BINARY},
+				"b": {Kind: claircore.BINARY},
+			},
+			Vulnerabilities: map[string]*claircore.Vulnerability{
+				"1": {NormalizedSeverity: claircore.Critical, Repo: rpmRepo},
+				"2": {NormalizedSeverity: claircore.Low, Repo: rpmRepo},
+				"3": {NormalizedSeverity: claircore.Critical, Repo: &claircore.Repository{Name: "pypi"}},
+			},
+			PackageVulnerabilities: map[string][]string{
+				"a": {"1", "2"},
+				"b": {"1", "3"},
+				// Dangling reference should be ignored.
+				"c": {"4"},
+			},
+		}}
+	}
+	const (
+		mA = "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+		mB = "sha256:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	)
+	const header = `
+# HELP claircore_rhel_vulnerabilities Number of vulnerabilities affecting packages in a vulnerability report.
+# TYPE claircore_rhel_vulnerabilities gauge
+`
+	reg := prometheus.NewPedanticRegistry()
+	a := mkReport("a")
+	ca, err := a.ToPrometheus(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := header + `claircore_rhel_vulnerabilities{manifest="` + mA + `",package_type="binary",severity="critical"} 1
+claircore_rhel_vulnerabilities{manifest="` + mA + `",package_type="rpm",severity="critical"} 2
+claircore_rhel_vulnerabilities{manifest="` + mA + `",package_type="rpm",severity="low"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+
+	// Another report is exported alongside the first.
+	b := mkReport("b")
+	delete(b.PackageVulnerabilities, "b")
+	if _, err := b.ToPrometheus(reg); err != nil {
+		t.Fatal(err)
+	}
+	want += `claircore_rhel_vulnerabilities{manifest="` + mB + `",package_type="rpm",severity="critical"} 1
+claircore_rhel_vulnerabilities{manifest="` + mB + `",package_type="rpm",severity="low"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+
+	// The same manifest can't be registered twice.
+	if _, err := mkReport("a").ToPrometheus(reg); err == nil {
+		t.Error("expected error")
+	}
+	// Until the previous collector is unregistered.
+	if !reg.Unregister(ca) {
+		t.Error("unable to unregister")
+	}
+	if _, err := mkReport("a").ToPrometheus(reg); err != nil {
+		t.Error(err)
+	}
+}
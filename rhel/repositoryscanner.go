@@ -3,9 +3,11 @@ package rhel
 import (
 	"bytes"
 	"context"
+	_ "embed"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"net/http"
 	"net/url"
@@ -19,6 +21,7 @@ import (
 
 	"github.com/quay/claircore"
 	"github.com/quay/claircore/indexer"
+	"github.com/quay/claircore/internal/zreader"
 	"github.com/quay/claircore/rhel/dockerfile"
 	"github.com/quay/claircore/rhel/internal/common"
 	"github.com/quay/claircore/rhel/internal/containerapi"
@@ -70,6 +73,12 @@ var (
 //   - If only the "File" is provided, it will be consulted exclusively.
 //   - If both the "URL" and "File" are provided, the file will be loaded
 //     initially and then updated periodically from the URL.
+//
+// For air-gapped deployments, the mapping file can be written out by a
+// connected RepositoryScanner with [RepositoryScanner.WriteMapping], shipped
+// alongside the other offline data, and loaded with "File" and "Offline". An
+// Offline RepositoryScanner without a "File" uses a snapshot embedded in this
+// package.
 type RepositoryScannerConfig struct {
 	// API is the URL to talk to the Red Hat Container API.
 	//
@@ -81,9 +90,16 @@ type RepositoryScannerConfig struct {
 	// See [DefaultRepo2CPEMappingURL] and [repo2cpe].
 	Repo2CPEMappingURL string `json:"repo2cpe_mapping_url" yaml:"repo2cpe_mapping_url"`
 	// Repo2CPEMappingFile, if specified, is consulted instead of the [Repo2CPEMappingURL].
+	// The file may be compressed with gzip, zstd, or bzip2.
 	//
 	// This should be provided to avoid any network traffic.
 	Repo2CPEMappingFile string `json:"repo2cpe_mapping_file" yaml:"repo2cpe_mapping_file"`
+	// Offline disables all network traffic: the mapping file is never
+	// updated and the Container API is never consulted, so layers without
+	// embedded content sets have no repositories. If the
+	// Repo2CPEMappingFile isn't provided, the snapshot returned by
+	// [EmbeddedMapping] is used.
+	Offline bool `json:"offline" yaml:"offline"`
 	// Timeout controls the timeout for any remote calls this package makes.
 	//
 	// The default is 10 seconds.
@@ -131,6 +147,13 @@ func (r *RepositoryScanner) Configure(ctx context.Context, f indexer.ConfigDeser
 
 	var mf *mappingFile
 	switch {
+	case r.cfg.Offline && r.cfg.Repo2CPEMappingFile == "":
+		var err error
+		mf, err = readMappingFile(bytes.NewReader(embeddedMapping))
+		if err != nil {
+			panic(fmt.Sprintf("programmer error: bad bundled data: %v", err))
+		}
+		zlog.Info(ctx).Msg("offline mode: using embedded mapping snapshot")
 	case r.cfg.Repo2CPEMappingURL == "" && r.cfg.Repo2CPEMappingFile == "":
 		// defaults
 		r.cfg.Repo2CPEMappingURL = DefaultRepo2CPEMappingURL
@@ -138,20 +161,26 @@ func (r *RepositoryScanner) Configure(ctx context.Context, f indexer.ConfigDeser
 		// remote only
 	case r.cfg.Repo2CPEMappingFile != "":
 		// seed from file
-		f, err := os.Open(r.cfg.Repo2CPEMappingFile)
+		var err error
+		mf, err = loadMappingFile(r.cfg.Repo2CPEMappingFile)
 		if err != nil {
 			return err
 		}
-		defer f.Close()
-		mf = &mappingFile{}
-		if err := json.NewDecoder(f).Decode(mf); err != nil {
-			return err
-		}
 	}
-	r.upd = common.NewUpdater(r.cfg.Repo2CPEMappingURL, mf)
+	u := r.cfg.Repo2CPEMappingURL
+	if r.cfg.Offline {
+		u = ""
+	}
+	r.upd = common.NewUpdater(u, mf)
 	tctx, done := context.WithTimeout(ctx, r.cfg.Timeout)
 	defer done()
 	r.upd.Get(tctx, c)
+	if r.cfg.Offline {
+		zlog.Info(ctx).
+			Str("file", r.cfg.Repo2CPEMappingFile).
+			Msg("offline mode: using mapping only")
+		return nil
+	}
 
 	// Additional setup
 	root, err := url.Parse(r.cfg.API)
@@ -228,6 +257,71 @@ func (r *RepositoryScanner) Scan(ctx context.Context, l *claircore.Layer) (repos
 	return repositories, nil
 }
 
+// EmbeddedMapping is a snapshot of the file at [DefaultRepo2CPEMappingURL].
+//
+//go:embed repository-to-cpe.json
+var embeddedMapping []byte
+
+// EmbeddedMapping returns a reader over the repository-to-CPE mapping bundled
+// with this package, in the format of the file at
+// [DefaultRepo2CPEMappingURL].
+//
+// The bundled mapping only covers the main repositories of recent RHEL
+// releases. Deployments that need more should supply a file written by
+// [RepositoryScanner.WriteMapping].
+func EmbeddedMapping() io.Reader {
+	return bytes.NewReader(embeddedMapping)
+}
+
+// LoadMappingFile reads the mapping file at "p", which may be compressed.
+func loadMappingFile(p string) (*mappingFile, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	mf, err := readMappingFile(f)
+	if err != nil {
+		return nil, fmt.Errorf("rhel: mapping file %q: %w", p, err)
+	}
+	return mf, nil
+}
+
+// ReadMappingFile decodes a mapping file, which may be compressed.
+func readMappingFile(r io.Reader) (*mappingFile, error) {
+	rd, err := zreader.Reader(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read: %w", err)
+	}
+	defer rd.Close()
+	var mf mappingFile
+	if err := json.NewDecoder(rd).Decode(&mf); err != nil {
+		return nil, fmt.Errorf("unable to decode: %w", err)
+	}
+	return &mf, nil
+}
+
+// WriteMapping writes the repository-to-CPE mapping currently in use to "w",
+// as uncompressed JSON in the format of the file at
+// [DefaultRepo2CPEMappingURL]. Only the CPEs for each repository are kept.
+//
+// The output can be used as the Repo2CPEMappingFile of another
+// RepositoryScanner, such as one configured to be Offline. An error is
+// reported if the RepositoryScanner hasn't been configured or has no mapping.
+func (r *RepositoryScanner) WriteMapping(w io.Writer) error {
+	if r.upd == nil {
+		return errors.New("rhel: RepositoryScanner not configured")
+	}
+	mf, ok := r.upd.Value().(*mappingFile)
+	if !ok || mf == nil {
+		return errors.New("rhel: no mapping file loaded")
+	}
+	if err := json.NewEncoder(w).Encode(mf); err != nil {
+		return fmt.Errorf("rhel: unable to write mapping file: %w", err)
+	}
+	return nil
+}
+
 // MapContentSets returns a slice of CPEs bound into strings, as discovered by
 // examining information contained within the container.
 func mapContentSets(ctx context.Context, sys fs.FS, cm *mappingFile) ([]string, error) {
@@ -2,6 +2,7 @@ package rhel
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"errors"
@@ -10,6 +11,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"path"
+	"path/filepath"
 	"sort"
 	"strings"
 	"testing"
@@ -169,3 +171,126 @@ func TestLabelError(t *testing.T) {
 		t.Errorf("%v != %v", got, want)
 	}
 }
+
+func TestRepositoryScannerOffline(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	const mappingData = `{"data":{"content-set-1":{"cpes":["cpe:/o:redhat:enterprise_linux:6::server","cpe:/o:redhat:enterprise_linux:7::server"]},"content-set-2":{"cpes":["cpe:/o:redhat:enterprise_linux:7::server","cpe:/o:redhat:enterprise_linux:8::server"]}}}`
+	mapping := filepath.Join(t.TempDir(), "repository-2-cpe.json.gz")
+	mf, err := os.Create(mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := gzip.NewWriter(mf)
+	if _, err := io.WriteString(zw, mappingData); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := mf.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Any request is a test failure.
+	c := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		t.Errorf("unexpected request: %v", r.URL)
+		return nil, errors.New("offline")
+	})}
+	configure := func(cfg *RepositoryScannerConfig) (*RepositoryScanner, error) {
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(cfg); err != nil {
+			t.Fatal(err)
+		}
+		s := new(RepositoryScanner)
+		return s, s.Configure(ctx, json.NewDecoder(&buf).Decode, c)
+	}
+
+	t.Run("Embedded", func(t *testing.T) {
+		s, err := configure(&RepositoryScannerConfig{Offline: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cpes, err := s.upd.Value().(*mappingFile).Get(ctx, []string{"rhel-8-for-x86_64-baseos-rpms"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := cpes, []string{"cpe:/o:redhat:enterprise_linux:8::baseos"}; !cmp.Equal(got, want) {
+			t.Error(cmp.Diff(got, want))
+		}
+	})
+
+	scanner, err := configure(&RepositoryScannerConfig{
+		Offline:             true,
+		Repo2CPEMappingURL:  "https://example.com/repository-2-cpe.json",
+		Repo2CPEMappingFile: mapping,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{
+		"testdata/layer-with-embedded-cs.tar",
+		"testdata/layer-with-cpe.tar",
+	} {
+		t.Run(path.Base(p), func(t *testing.T) {
+			ctx := zlog.Test(ctx, t)
+			f, err := os.Open(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			var l claircore.Layer
+			desc := claircore.LayerDescription{
+				Digest:    `sha256:` + strings.Repeat(`beef`, 16),
+				URI:       `file:///dev/null`,
+				MediaType: test.MediaType,
+				Headers:   make(map[string][]string),
+			}
+			if err := l.Init(ctx, &desc, f); err != nil {
+				t.Fatal(err)
+			}
+			defer l.Close()
+			got, err := scanner.Scan(ctx, &l)
+			if err != nil {
+				t.Error(err)
+			}
+			var want []*claircore.Repository
+			if p == "testdata/layer-with-embedded-cs.tar" {
+				for _, n := range []string{
+					"cpe:/o:redhat:enterprise_linux:6::server",
+					"cpe:/o:redhat:enterprise_linux:7::server",
+					"cpe:/o:redhat:enterprise_linux:8::server",
+				} {
+					want = append(want, &claircore.Repository{Name: n, Key: repositoryKey, CPE: cpe.MustUnbind(n)})
+				}
+			}
+			sort.Slice(got, func(i, j int) bool { return got[i].Name < got[j].Name })
+			if !cmp.Equal(got, want) {
+				t.Error(cmp.Diff(got, want))
+			}
+		})
+	}
+
+	t.Run("WriteMapping", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := scanner.WriteMapping(&buf); err != nil {
+			t.Fatal(err)
+		}
+		var got, want mappingFile
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(mappingData), &want); err != nil {
+			t.Fatal(err)
+		}
+		if !cmp.Equal(got, want) {
+			t.Error(cmp.Diff(got, want))
+		}
+		if err := new(RepositoryScanner).WriteMapping(io.Discard); err == nil {
+			t.Error("expected error for unconfigured scanner")
+		}
+	})
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
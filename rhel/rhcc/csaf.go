@@ -0,0 +1,345 @@
+package rhcc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/quay/zlog"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/libvuln/driver"
+	"github.com/quay/claircore/pkg/rhctag"
+	"github.com/quay/claircore/pkg/tmp"
+	"github.com/quay/claircore/rhel/internal/csaf"
+)
+
+// DefaultCSAFAdvisories is the directory of Red Hat's CSAF advisories.
+//
+// The directory is expected to contain an "archive_latest.txt" file naming a
+// compressed tar of every advisory, and a "changes.csv" file listing the
+// advisories by modification time.
+//
+//doc:url updater
+const DefaultCSAFAdvisories = `https://access.redhat.com/security/data/csaf/v2/advisories/`
+
+// FetchCSAF fetches the latest archive of advisories and any advisories
+// changed since it was made, and spools the container advisories for
+// [updater.parseCSAF].
+//
+// The fingerprint is the archive name and the newest change.
+func (u *updater) fetchCSAF(ctx context.Context, hint driver.Fingerprint) (io.ReadCloser, driver.Fingerprint, error) {
+	ctx = zlog.ContextWithValues(ctx, "component", "rhel/rhcc/Updater.fetchCSAF")
+
+	ref, err := u.csafDir.Parse("archive_latest.txt")
+	if err != nil {
+		return nil, hint, err
+	}
+	res, err := u.get(ctx, ref)
+	if err != nil {
+		return nil, hint, err
+	}
+	archive, err := csaf.ReadArchiveName(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, hint, fmt.Errorf("rhcc: unable to read %q: %w", ref, err)
+	}
+	changes, err := u.csafChanges(ctx, csaf.ArchiveDate(archive))
+	if err != nil {
+		return nil, hint, err
+	}
+	fp := archive
+	if len(changes) != 0 {
+		fp += "/" + changes[0].Timestamp
+	}
+	if driver.Fingerprint(fp) == hint {
+		zlog.Info(ctx).Msg("advisories unchanged since last fetch")
+		return nil, hint, driver.Unchanged
+	}
+
+	tf, err := tmp.NewFile("", updaterName+".")
+	if err != nil {
+		return nil, hint, fmt.Errorf("rhcc: unable to open tempfile: %w", err)
+	}
+	var success bool
+	defer func() {
+		if !success {
+			if err := tf.Close(); err != nil {
+				zlog.Warn(ctx).Err(err).Msg("unable to close spool")
+			}
+		}
+	}()
+	enc := json.NewEncoder(tf)
+	var docs int
+	write := func(name string, r io.Reader) error {
+		var doc csaf.Document
+		if err := json.NewDecoder(r).Decode(&doc); err != nil {
+			return fmt.Errorf("rhcc: unable to decode %q: %w", name, err)
+		}
+		if !hasContainers(&doc) {
+			return nil
+		}
+		docs++
+		return enc.Encode(&doc)
+	}
+
+	ref, err = u.csafDir.Parse(archive)
+	if err != nil {
+		return nil, hint, fmt.Errorf("rhcc: bad archive name %q: %w", archive, err)
+	}
+	res, err = u.get(ctx, ref)
+	if err != nil {
+		return nil, hint, err
+	}
+	err = csaf.WalkArchive(res.Body, write)
+	res.Body.Close()
+	if err != nil {
+		return nil, hint, fmt.Errorf("rhcc: %q: %w", ref, err)
+	}
+	// Changed advisories are written after the archive, so they replace the
+	// archived versions when parsed.
+	for _, c := range changes {
+		ref, err := u.csafDir.Parse(c.Path)
+		if err != nil {
+			return nil, hint, fmt.Errorf("rhcc: bad advisory name %q: %w", c.Path, err)
+		}
+		res, err := u.get(ctx, ref)
+		if err != nil {
+			return nil, hint, err
+		}
+		err = write(c.Path, res.Body)
+		res.Body.Close()
+		if err != nil {
+			return nil, hint, err
+		}
+	}
+	if _, err := tf.Seek(0, io.SeekStart); err != nil {
+		return nil, hint, fmt.Errorf("rhcc: unable to seek spool to start: %w", err)
+	}
+	zlog.Info(ctx).
+		Str("archive", archive).
+		Int("changes", len(changes)).
+		Int("documents", docs).
+		Msg("fetched container advisories")
+	success = true
+	return tf, driver.Fingerprint(fp), nil
+}
+
+// CsafChanges returns the newest version of every advisory changed on or
+// after "since", a date like "2024-01-07", newest first. If "since" is empty,
+// no changes are returned.
+func (u *updater) csafChanges(ctx context.Context, since string) ([]csaf.Change, error) {
+	if since == "" {
+		return nil, nil
+	}
+	ref, err := u.csafDir.Parse("changes.csv")
+	if err != nil {
+		return nil, err
+	}
+	res, err := u.get(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	cs, err := csaf.ParseChanges(res.Body, since)
+	if err != nil {
+		return nil, fmt.Errorf("rhcc: unable to read %q: %w", ref, err)
+	}
+	return cs, nil
+}
+
+// Get issues a GET request, returning an error for any non-200 response.
+func (u *updater) get(ctx context.Context, ref *url.URL) (*http.Response, error) {
+	res, err := csaf.Get(ctx, u.client, ref.String())
+	if err != nil {
+		return nil, fmt.Errorf("rhcc: %w", err)
+	}
+	return res, nil
+}
+
+// ParseCSAF reads the advisories spooled by [updater.fetchCSAF].
+//
+// The fixed container images in the advisories are grouped by CVE and
+// repository, and the vulnerabilities are built the same way as from the
+// "cvemap.xml" file. Only fixed images are reported; the advisories don't name
+// the tags of unfixed ones.
+func (u *updater) parseCSAF(ctx context.Context, r io.ReadCloser) ([]*claircore.Vulnerability, error) {
+	ctx = zlog.ContextWithValues(ctx, "component", "rhel/rhcc/Updater.parseCSAF")
+	zlog.Info(ctx).Msg("parse start")
+	defer r.Close()
+	defer zlog.Info(ctx).Msg("parse done")
+
+	// Later versions of an advisory replace earlier ones.
+	docs := make(map[string]*csaf.Document)
+	dec := json.NewDecoder(r)
+	for {
+		var doc csaf.Document
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("rhcc: unable to decode advisory: %w", err)
+		}
+		docs[doc.Document.Tracking.ID] = &doc
+	}
+	ids := make([]string, 0, len(docs))
+	for id := range docs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	type cveInfo struct {
+		description string
+		byContainer map[string]map[rhctag.Version]*consolidatedRelease
+	}
+	cves := make(map[string]*cveInfo)
+	titleCase := cases.Title(language.Und)
+	for _, id := range ids {
+		doc := docs[id]
+		images := fixedImages(doc)
+		if len(images) == 0 {
+			continue
+		}
+		link := doc.SelfLink()
+		for i := range doc.Vulnerabilities {
+			v := &doc.Vulnerabilities[i]
+			if v.CVE == "" {
+				continue
+			}
+			info, ok := cves[v.CVE]
+			if !ok {
+				info = &cveInfo{
+					byContainer: make(map[string]map[rhctag.Version]*consolidatedRelease),
+				}
+				cves[v.CVE] = info
+			}
+			if info.description == "" {
+				info.description = v.Description()
+			}
+			for _, pid := range v.ProductStatus.Fixed {
+				img, ok := images[pid]
+				if !ok {
+					continue
+				}
+				releases, ok := info.byContainer[img.Repository]
+				if !ok {
+					releases = make(map[rhctag.Version]*consolidatedRelease)
+					info.byContainer[img.Repository] = releases
+				}
+				minor := img.Tag.MinorStart()
+				rel, ok := releases[minor]
+				if !ok {
+					vs := make(rhctag.Versions, 0, 1)
+					rel = &consolidatedRelease{FixedInVersions: &vs}
+					releases[minor] = rel
+				}
+				// The vulnerability is named for the advisory of the first
+				// fixed version in the stream.
+				if first, err := rel.FixedInVersions.First(); err != nil || img.Tag.Compare(&first) < 0 {
+					rel.Issued = doc.Document.Tracking.InitialReleaseDate
+					rel.Severity = titleCase.String(doc.Document.AggregateSeverity.Text)
+					rel.AdvisoryLink = link
+					rel.AdvisoryName = doc.Document.Tracking.ID
+				}
+				vs := rel.FixedInVersions.Append(img.Tag)
+				rel.FixedInVersions = &vs
+			}
+		}
+	}
+
+	names := make([]string, 0, len(cves))
+	for n := range cves {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	var vs []*claircore.Vulnerability
+	for _, n := range names {
+		info := cves[n]
+		vs = append(vs, releaseVulns(n, info.description, info.byContainer)...)
+	}
+	zlog.Debug(ctx).
+		Int("advisories", len(docs)).
+		Int("count", len(vs)).
+		Msg("found vulnerabilities")
+	return vs, nil
+}
+
+// HasContainers reports whether the advisory has any container images.
+func hasContainers(d *csaf.Document) bool {
+	for _, p := range d.Products() {
+		if strings.HasPrefix(p.Helper.PURL, "pkg:oci/") {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainerImage is a container image named by an advisory.
+type containerImage struct {
+	Repository string
+	Tag        rhctag.Version
+}
+
+// FixedImages returns the container images in the advisory, keyed by the
+// product IDs of the relationships that place them on a platform.
+func fixedImages(d *csaf.Document) map[string]containerImage {
+	products := d.Products()
+	ret := make(map[string]containerImage)
+	for _, rel := range d.ProductTree.Relationships {
+		p, ok := products[rel.ProductReference]
+		if !ok {
+			continue
+		}
+		img, ok := parseOCIPURL(p.Helper.PURL)
+		if !ok {
+			continue
+		}
+		ret[rel.FullProductName.ProductID] = img
+	}
+	return ret
+}
+
+// ParseOCIPURL parses a package URL of the form:
+//
+//	pkg:oci/name@sha256:digest?arch=amd64&repository_url=registry.redhat.io/namespace/name&tag=v1.0.0-1
+//
+// The repository is the path of the "repository_url", which is the name the
+// container scanner reports. Images without a tag that rhctag understands are
+// rejected.
+func parseOCIPURL(s string) (containerImage, bool) {
+	var img containerImage
+	rest, ok := strings.CutPrefix(s, "pkg:oci/")
+	if !ok {
+		return img, false
+	}
+	_, qs, _ := strings.Cut(rest, "?")
+	qs, _, _ = strings.Cut(qs, "#")
+	q, err := url.ParseQuery(qs)
+	if err != nil {
+		return img, false
+	}
+	_, repo, ok := strings.Cut(q.Get("repository_url"), "/")
+	if !ok || repo == "" {
+		return img, false
+	}
+	tag := q.Get("tag")
+	if tag == "" {
+		return img, false
+	}
+	v, err := rhctag.Parse(tag)
+	if err != nil {
+		return img, false
+	}
+	img.Repository = repo
+	img.Tag = v
+	return img, true
+}
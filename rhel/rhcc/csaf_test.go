@@ -0,0 +1,198 @@
+package rhcc
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/klauspost/compress/zstd"
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/libvuln/driver"
+	"github.com/quay/claircore/pkg/rhctag"
+)
+
+// CsafAdvisory returns a trimmed CSAF advisory fixing "cve" in the
+// ose-metering-hive image at "tag".
+func csafAdvisory(id, cve, severity, tag string) string {
+	return fmt.Sprintf(`{
+"document":{"aggregate_severity":{"text":%[3]q},"tracking":{"id":%[1]q,"initial_release_date":"2021-12-14T00:00:00Z"},
+  "references":[{"category":"self","url":"https://access.redhat.com/errata/%[1]s"}]},
+"product_tree":{"branches":[{"branches":[
+  {"product":{"product_id":"8Base-RHOSE","product_identification_helper":{"cpe":"cpe:/a:redhat:openshift:4::el8"}}},
+  {"product":{"product_id":"openshift4/ose-metering-hive@sha256:abc_amd64","product_identification_helper":{"purl":"pkg:oci/ose-metering-hive@sha256:abc?arch=amd64&repository_url=registry.redhat.io/openshift4/ose-metering-hive&tag=%[4]s"}}}
+]}],
+"relationships":[{"full_product_name":{"product_id":"8Base-RHOSE:openshift4/ose-metering-hive@sha256:abc_amd64"},"product_reference":"openshift4/ose-metering-hive@sha256:abc_amd64","relates_to_product_reference":"8Base-RHOSE"}]},
+"vulnerabilities":[{"cve":%[2]q,"notes":[{"category":"description","text":"A flaw."}],"product_status":{"fixed":["8Base-RHOSE:openshift4/ose-metering-hive@sha256:abc_amd64"]}}]
+}`, id, cve, severity, tag)
+}
+
+const csafRPMAdvisory = `{
+"document":{"aggregate_severity":{"text":"Low"},"tracking":{"id":"RHSA-2021:0003"}},
+"product_tree":{"branches":[{"product":{"product_id":"foo-1-1.el8.x86_64","product_identification_helper":{"purl":"pkg:rpm/redhat/foo@1-1.el8?arch=x86_64"}}}],
+"relationships":[{"full_product_name":{"product_id":"BaseOS:foo-1-1.el8.x86_64"},"product_reference":"foo-1-1.el8.x86_64"}]},
+"vulnerabilities":[{"cve":"CVE-2021-0003","product_status":{"fixed":["BaseOS:foo-1-1.el8.x86_64"]}}]
+}`
+
+func TestCSAF(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	const archive = "csaf_advisories_2021-12-15.tar.zst"
+
+	var buf bytes.Buffer
+	zw, err := zstd.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := tar.NewWriter(zw)
+	for n, d := range map[string]string{
+		"2021/rhsa-2021_0001.json": csafAdvisory("RHSA-2021:0001", "CVE-2021-0001", "moderate", "v4.6.0-202112140546.p0.g8b9da97.assembly.stream"),
+		"2021/rhsa-2021_0002.json": csafAdvisory("RHSA-2021:0002", "CVE-2021-0001", "Moderate", "v4.7.0-202112140553.p0.g091bb99.assembly.stream"),
+		"2021/rhsa-2021_0003.json": csafRPMAdvisory,
+	} {
+		if err := tw.WriteHeader(&tar.Header{Name: n, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(d))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tw, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// RHSA-2021:0002 was updated after the archive was made, and now fixes
+	// the vulnerability in an earlier build.
+	updated := csafAdvisory("RHSA-2021:0002", "CVE-2021-0001", "Important", "v4.7.0-202112130000.p0.g091bb99.assembly.stream")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/csaf/archive_latest.txt", func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, archive+"\n")
+	})
+	mux.HandleFunc("/csaf/"+archive, func(w http.ResponseWriter, _ *http.Request) {
+		w.Write(buf.Bytes())
+	})
+	mux.HandleFunc("/csaf/changes.csv", func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, `"2021/rhsa-2021_0002.json","2021-12-16T01:02:03+00:00"`+"\n"+
+			`"2021/rhsa-2021_0001.json","2021-12-14T01:02:03+00:00"`+"\n")
+	})
+	mux.HandleFunc("/csaf/2021/rhsa-2021_0002.json", func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, updated)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	u := &updater{}
+	if err := u.Configure(ctx, func(v interface{}) error {
+		cfg := v.(*UpdaterConfig)
+		cfg.CSAF = true
+		cfg.CSAFURL = srv.URL + "/csaf/"
+		return nil
+	}, srv.Client()); err != nil {
+		t.Fatal(err)
+	}
+
+	rc, fp, err := u.Fetch(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fp, driver.Fingerprint(archive+"/2021-12-16T01:02:03+00:00"); got != want {
+		t.Errorf("got fingerprint %q, want %q", got, want)
+	}
+	got, err := u.Parse(ctx, rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := u.Fetch(ctx, fp); !errors.Is(err, driver.Unchanged) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	issued := time.Date(2021, 12, 14, 0, 0, 0, 0, time.UTC)
+	pkg := &claircore.Package{Name: "openshift4/ose-metering-hive", Kind: claircore.BINARY}
+	mkRange := func(lower, upper string) *claircore.Range {
+		var r claircore.Range
+		var v rhctag.Version
+		if lower != "" {
+			var err error
+			v, err = rhctag.Parse(lower)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		r.Lower = v.Version(true)
+		v, err := rhctag.Parse(upper)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Upper = v.Version(false)
+		return &r
+	}
+	want := []*claircore.Vulnerability{
+		{
+			Name:               "RHSA-2021:0001",
+			Description:        "A flaw.",
+			Package:            pkg,
+			Updater:            updaterName,
+			Issued:             issued,
+			Severity:           "Moderate",
+			NormalizedSeverity: claircore.Medium,
+			Links:              "https://access.redhat.com/errata/RHSA-2021:0001 https://access.redhat.com/security/cve/CVE-2021-0001",
+			FixedInVersion:     "v4.6.0-202112140546.p0.g8b9da97.assembly.stream",
+			Repo:               &goldRepo,
+			Range:              mkRange("", "v4.6.0-202112140546.p0.g8b9da97.assembly.stream"),
+		},
+		{
+			Name:               "RHSA-2021:0002",
+			Description:        "A flaw.",
+			Package:            pkg,
+			Updater:            updaterName,
+			Issued:             issued,
+			Severity:           "Important",
+			NormalizedSeverity: claircore.High,
+			Links:              "https://access.redhat.com/errata/RHSA-2021:0002 https://access.redhat.com/security/cve/CVE-2021-0001",
+			FixedInVersion:     "v4.7.0-202112130000.p0.g091bb99.assembly.stream",
+			Repo:               &goldRepo,
+			Range:              mkRange("v4.7", "v4.7.0-202112130000.p0.g091bb99.assembly.stream"),
+		},
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i].Name < got[j].Name })
+	if !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(got, want))
+	}
+}
+
+func TestParseOCIPURL(t *testing.T) {
+	t.Parallel()
+	tt := []struct {
+		In   string
+		Repo string
+		OK   bool
+	}{
+		{
+			In:   "pkg:oci/ose-metering-hive@sha256:abc?arch=amd64&repository_url=registry.redhat.io/openshift4/ose-metering-hive&tag=v4.6.0-202112140546.p0.g8b9da97.assembly.stream",
+			Repo: "openshift4/ose-metering-hive",
+			OK:   true,
+		},
+		{In: "pkg:oci/ose-metering-hive@sha256:abc?arch=amd64&repository_url=registry.redhat.io/openshift4/ose-metering-hive"},
+		{In: "pkg:oci/ose-metering-hive@sha256:abc?tag=v4.6.0"},
+		{In: "pkg:rpm/redhat/foo@1-1.el8?arch=x86_64"},
+	}
+	for _, tc := range tt {
+		img, ok := parseOCIPURL(tc.In)
+		if ok != tc.OK || img.Repository != tc.Repo {
+			t.Errorf("%s: got (%q, %v), want (%q, %v)", tc.In, img.Repository, ok, tc.Repo, tc.OK)
+		}
+	}
+}
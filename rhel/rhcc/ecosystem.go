@@ -9,6 +9,7 @@ import (
 // NewEcosystem returns an rhcc ecosystem.
 func NewEcosystem(_ context.Context) *indexer.Ecosystem {
 	return &indexer.Ecosystem{
+		Name: "rhcc",
 		PackageScanners: func(_ context.Context) ([]indexer.PackageScanner, error) {
 			return []indexer.PackageScanner{&scanner{}}, nil
 		},
@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"sort"
+	"strings"
 
 	"github.com/quay/zlog"
 	"golang.org/x/text/cases"
@@ -38,6 +40,8 @@ type updater struct {
 	client  *http.Client
 	url     string
 	bzipped bool
+	csaf    bool
+	csafDir *url.URL
 }
 
 // UpdaterConfig is the configuration for the container catalog's updater.
@@ -49,6 +53,16 @@ type UpdaterConfig struct {
 	// The Updater's configuration hook will check for a version with an
 	// additional ".bz2" extension.
 	URL string `json:"url" yaml:"url"`
+	// CSAF, if set, uses Red Hat's CSAF advisories instead of the
+	// "cvemap.xml" file.
+	//
+	// The advisories name the fixed container images by repository and tag,
+	// so images shipped only as containers are matched against the streams
+	// named in the advisories.
+	CSAF bool `json:"csaf" yaml:"csaf"`
+	// CSAFURL is the directory of CSAF advisories to use, with a trailing
+	// slash. See DefaultCSAFAdvisories for the expected layout.
+	CSAFURL string `json:"csaf_url" yaml:"csaf_url"`
 }
 
 const updaterName = "rhel-container-updater"
@@ -77,6 +91,22 @@ func (u *updater) Configure(ctx context.Context, f driver.ConfigUnmarshaler, c *
 	if cfg.URL != "" {
 		u.url = cfg.URL
 	}
+	u.csaf = cfg.CSAF
+	if u.csaf {
+		dir := DefaultCSAFAdvisories
+		if cfg.CSAFURL != "" {
+			dir = cfg.CSAFURL
+		}
+		if !strings.HasSuffix(dir, "/") {
+			return fmt.Errorf("rhcc: CSAF URL missing trailing slash: %q", dir)
+		}
+		var err error
+		u.csafDir, err = url.Parse(dir)
+		if err != nil {
+			return fmt.Errorf("rhcc: bad CSAF URL: %w", err)
+		}
+		return nil
+	}
 
 	// This could check the reported content type perhaps, but just relying on
 	// the extension is quicker and we have inside information that it's
@@ -100,6 +130,9 @@ func (u *updater) Configure(ctx context.Context, f driver.ConfigUnmarshaler, c *
 
 // Fetch implements [driver.Updater].
 func (u *updater) Fetch(ctx context.Context, hint driver.Fingerprint) (io.ReadCloser, driver.Fingerprint, error) {
+	if u.csaf {
+		return u.fetchCSAF(ctx, hint)
+	}
 	ctx = zlog.ContextWithValues(ctx, "component", "rhel/rhcc/Updater.Fetch")
 
 	zlog.Info(ctx).Str("database", u.url).Msg("starting fetch")
@@ -175,6 +208,9 @@ func (u *updater) Fetch(ctx context.Context, hint driver.Fingerprint) (io.ReadCl
 
 // Parse implements [driver.Updater].
 func (u *updater) Parse(ctx context.Context, r io.ReadCloser) ([]*claircore.Vulnerability, error) {
+	if u.csaf {
+		return u.parseCSAF(ctx, r)
+	}
 	ctx = zlog.ContextWithValues(ctx, "component", "rhel/rhcc/Updater.Parse")
 	zlog.Info(ctx).Msg("parse start")
 	defer r.Close()
@@ -250,55 +286,65 @@ func (u *updater) Parse(ctx context.Context, r io.ReadCloser) ([]*claircore.Vuln
 			versionsByContainer[packageName][minorKey].FixedInVersions = &newVersions
 		}
 
-		// Build the Vulnerability slice
-		for pkg, releasesByMinor := range versionsByContainer {
-			p := &claircore.Package{
-				Name: pkg,
-				Kind: claircore.BINARY,
-			}
-			// sort minor keys
-			minorKeys := make(rhctag.Versions, 0)
-			for k := range releasesByMinor {
-				minorKeys = append(minorKeys, k)
-			}
-			sort.Sort(minorKeys)
-			// iterate minor key map in order
-			for idx, minor := range minorKeys {
-				// sort the fixed in versions
-				sort.Sort(releasesByMinor[minor].FixedInVersions)
-				// The first minor version range should match all previous versions
-				start := minor
-				if idx == 0 {
-					start = rhctag.Version{}
-				}
-				// For containers such as openshift-logging/elasticsearch6-rhel8 we need to match
-				// the first Fixed in Version here.
-				// Most of the time this will return the only Fixed In Version for minor version
-				firstPatch, _ := releasesByMinor[minor].FixedInVersions.First()
-				r := &claircore.Range{
-					Lower: start.Version(true),
-					Upper: firstPatch.Version(false),
-				}
-				links := fmt.Sprintf("%s %s%s", releasesByMinor[minor].AdvisoryLink, cveURL, vuln.Name)
-				v := &claircore.Vulnerability{
-					Updater:            updaterName,
-					Name:               releasesByMinor[minor].AdvisoryName,
-					Description:        description,
-					Issued:             releasesByMinor[minor].Issued,
-					Severity:           releasesByMinor[minor].Severity,
-					NormalizedSeverity: common.NormalizeSeverity(releasesByMinor[minor].Severity),
-					Package:            p,
-					Repo:               &goldRepo,
-					Links:              links,
-					FixedInVersion:     firstPatch.Original,
-					Range:              r,
-				}
-				vs = append(vs, v)
-			}
-		}
+		vs = append(vs, releaseVulns(vuln.Name, description, versionsByContainer)...)
 	}
 	zlog.Debug(ctx).
 		Int("count", len(vs)).
 		Msg("found vulnerabilities")
 	return vs, nil
 }
+
+// ReleaseVulns builds the vulnerabilities for "cve" from the fixed releases of
+// each container, keyed by the start of their minor version stream.
+//
+// Each minor stream gets a vulnerability fixed by its first release; the
+// lowest stream also matches all previous versions.
+func releaseVulns(cve, description string, versionsByContainer map[string]map[rhctag.Version]*consolidatedRelease) []*claircore.Vulnerability {
+	var vs []*claircore.Vulnerability
+	for pkg, releasesByMinor := range versionsByContainer {
+		p := &claircore.Package{
+			Name: pkg,
+			Kind: claircore.BINARY,
+		}
+		// sort minor keys
+		minorKeys := make(rhctag.Versions, 0)
+		for k := range releasesByMinor {
+			minorKeys = append(minorKeys, k)
+		}
+		sort.Sort(minorKeys)
+		// iterate minor key map in order
+		for idx, minor := range minorKeys {
+			// sort the fixed in versions
+			sort.Sort(releasesByMinor[minor].FixedInVersions)
+			// The first minor version range should match all previous versions
+			start := minor
+			if idx == 0 {
+				start = rhctag.Version{}
+			}
+			// For containers such as openshift-logging/elasticsearch6-rhel8 we need to match
+			// the first Fixed in Version here.
+			// Most of the time this will return the only Fixed In Version for minor version
+			firstPatch, _ := releasesByMinor[minor].FixedInVersions.First()
+			r := &claircore.Range{
+				Lower: start.Version(true),
+				Upper: firstPatch.Version(false),
+			}
+			links := fmt.Sprintf("%s %s%s", releasesByMinor[minor].AdvisoryLink, cveURL, cve)
+			v := &claircore.Vulnerability{
+				Updater:            updaterName,
+				Name:               releasesByMinor[minor].AdvisoryName,
+				Description:        description,
+				Issued:             releasesByMinor[minor].Issued,
+				Severity:           releasesByMinor[minor].Severity,
+				NormalizedSeverity: common.NormalizeSeverity(releasesByMinor[minor].Severity),
+				Package:            p,
+				Repo:               &goldRepo,
+				Links:              links,
+				FixedInVersion:     firstPatch.Original,
+				Range:              r,
+			}
+			vs = append(vs, v)
+		}
+	}
+	return vs
+}
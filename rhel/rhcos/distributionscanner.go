@@ -0,0 +1,92 @@
+package rhcos
+
+import (
+	"context"
+	"fmt"
+	"runtime/trace"
+
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/indexer"
+	"github.com/quay/claircore/toolkit/types/cpe"
+)
+
+var (
+	_ indexer.VersionedScanner    = (*DistributionScanner)(nil)
+	_ indexer.DistributionScanner = (*DistributionScanner)(nil)
+)
+
+// DistributionScanner reports the distribution of an OSTree deployment, using
+// its "usr/lib/os-release" file.
+//
+// Only CoreOS variants are reported: RHCOS and any distribution with a
+// "VARIANT_ID" of "coreos", like Fedora CoreOS.
+//
+// The zero value is ready to use.
+type DistributionScanner struct{}
+
+// Name implements [indexer.VersionedScanner].
+func (*DistributionScanner) Name() string { return "rhcos" }
+
+// Version implements [indexer.VersionedScanner].
+func (*DistributionScanner) Version() string { return "1" }
+
+// Kind implements [indexer.VersionedScanner].
+func (*DistributionScanner) Kind() string { return "distribution" }
+
+// Scan implements [indexer.DistributionScanner].
+func (ds *DistributionScanner) Scan(ctx context.Context, l *claircore.Layer) ([]*claircore.Distribution, error) {
+	defer trace.StartRegion(ctx, "DistributionScanner.Scan").End()
+	ctx = zlog.ContextWithValues(ctx,
+		"component", "rhel/rhcos/DistributionScanner.Scan",
+		"version", ds.Version(),
+		"layer", l.Hash.String())
+	zlog.Debug(ctx).Msg("start")
+	defer zlog.Debug(ctx).Msg("done")
+
+	sys, err := l.FS()
+	if err != nil {
+		return nil, fmt.Errorf("rhcos: unable to open layer: %w", err)
+	}
+	dps, err := deployments(ctx, sys)
+	if err != nil {
+		return nil, err
+	}
+	var dists []*claircore.Distribution
+	for _, d := range dps {
+		m, err := osRelease(ctx, sys, d)
+		if err != nil {
+			return nil, err
+		}
+		if m == nil || !isCoreOS(m) {
+			continue
+		}
+		dists = append(dists, toDist(ctx, m))
+	}
+	return dists, nil
+}
+
+// ToDist builds a Distribution from os-release contents.
+func toDist(ctx context.Context, m map[string]string) *claircore.Distribution {
+	d := claircore.Distribution{
+		Name:            m["NAME"],
+		DID:             m["ID"],
+		Version:         m["VERSION"],
+		VersionID:       m["VERSION_ID"],
+		VersionCodeName: m["VERSION_CODENAME"],
+		PrettyName:      m["PRETTY_NAME"],
+	}
+	if v := m["CPE_NAME"]; v != "" {
+		wfn, err := cpe.Unbind(v)
+		if err != nil {
+			zlog.Warn(ctx).
+				Err(err).
+				Str("value", v).
+				Msg("failed to unbind the cpe")
+		} else {
+			d.CPE = wfn
+		}
+	}
+	return &d
+}
@@ -0,0 +1,28 @@
+package rhcos
+
+import (
+	"context"
+
+	"github.com/quay/claircore/indexer"
+	"github.com/quay/claircore/rhel"
+)
+
+// NewEcosystem provides the set of scanners and coalescer for OSTree-based
+// hosts.
+func NewEcosystem(_ context.Context) *indexer.Ecosystem {
+	return &indexer.Ecosystem{
+		Name: "rhcos",
+		PackageScanners: func(_ context.Context) ([]indexer.PackageScanner, error) {
+			return []indexer.PackageScanner{new(PackageScanner)}, nil
+		},
+		DistributionScanners: func(_ context.Context) ([]indexer.DistributionScanner, error) {
+			return []indexer.DistributionScanner{new(DistributionScanner)}, nil
+		},
+		RepositoryScanners: func(_ context.Context) ([]indexer.RepositoryScanner, error) {
+			return []indexer.RepositoryScanner{new(RepositoryScanner)}, nil
+		},
+		Coalescer: func(_ context.Context) (indexer.Coalescer, error) {
+			return new(rhel.Coalescer), nil
+		},
+	}
+}
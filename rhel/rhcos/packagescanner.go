@@ -0,0 +1,92 @@
+package rhcos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"runtime/trace"
+
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/indexer"
+	"github.com/quay/claircore/rpm"
+)
+
+var (
+	_ indexer.VersionedScanner = (*PackageScanner)(nil)
+	_ indexer.PackageScanner   = (*PackageScanner)(nil)
+)
+
+// PackageScanner reports the rpm packages of an OSTree deployment.
+//
+// The rpm database of a deployment is in "usr/share/rpm", or
+// "usr/lib/sysimage/rpm" on newer systems. Layers that aren't OSTree
+// filesystems are ignored.
+//
+// The zero value is ready to use.
+type PackageScanner struct{}
+
+// Name implements [indexer.VersionedScanner].
+func (*PackageScanner) Name() string { return "rhcos" }
+
+// Version implements [indexer.VersionedScanner].
+func (*PackageScanner) Version() string { return "1" }
+
+// Kind implements [indexer.VersionedScanner].
+func (*PackageScanner) Kind() string { return "package" }
+
+// Scan implements [indexer.PackageScanner].
+func (ps *PackageScanner) Scan(ctx context.Context, l *claircore.Layer) ([]*claircore.Package, error) {
+	defer trace.StartRegion(ctx, "PackageScanner.Scan").End()
+	ctx = zlog.ContextWithValues(ctx,
+		"component", "rhel/rhcos/PackageScanner.Scan",
+		"version", ps.Version(),
+		"layer", l.Hash.String())
+	zlog.Debug(ctx).Msg("start")
+	defer zlog.Debug(ctx).Msg("done")
+
+	sys, err := l.FS()
+	if err != nil {
+		return nil, fmt.Errorf("rhcos: unable to open layer: %w", err)
+	}
+	ds, err := deployments(ctx, sys)
+	if err != nil {
+		return nil, err
+	}
+	var pkgs []*claircore.Package
+	for _, d := range ds {
+		ctx := zlog.ContextWithValues(ctx, "deployment", d)
+		for _, dir := range []string{`usr/share/rpm`, `usr/lib/sysimage/rpm`} {
+			p := path.Join(d, dir)
+			fi, err := fs.Stat(sys, p)
+			switch {
+			case errors.Is(err, nil):
+			case errors.Is(err, fs.ErrNotExist):
+				continue
+			default:
+				return nil, fmt.Errorf("rhcos: unable to stat %q: %w", p, err)
+			}
+			if !fi.IsDir() {
+				continue
+			}
+			// One of these is usually a symlink to the other, so stop at the
+			// first one with packages.
+			ps, err := rpm.Packages(ctx, sys, p)
+			if err != nil {
+				return nil, fmt.Errorf("rhcos: %w", err)
+			}
+			if len(ps) != 0 {
+				zlog.Debug(ctx).
+					Str("path", p).
+					Int("count", len(ps)).
+					Msg("found packages")
+				pkgs = append(pkgs, ps...)
+				break
+			}
+		}
+	}
+	return pkgs, nil
+}
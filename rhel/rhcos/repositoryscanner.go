@@ -0,0 +1,151 @@
+package rhcos
+
+import (
+	"context"
+	"fmt"
+	"runtime/trace"
+	"strings"
+
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/indexer"
+	"github.com/quay/claircore/toolkit/types/cpe"
+)
+
+var (
+	_ indexer.VersionedScanner  = (*RepositoryScanner)(nil)
+	_ indexer.RepositoryScanner = (*RepositoryScanner)(nil)
+)
+
+// RepositoryScanner reports the Red Hat repositories an RHCOS deployment's
+// packages come from, in the same form as the rhel package's
+// RepositoryScanner.
+//
+// RHCOS is built from the RHEL BaseOS and AppStream repositories, both the
+// mainline and Extended Update Support streams of the RHEL release it's based
+// on, and the OpenShift repository of its OpenShift release. These are
+// determined from the "RHEL_VERSION" and "OPENSHIFT_VERSION" keys of the
+// os-release file, falling back to the "VERSION" and "VERSION_ID" keys.
+//
+// Other CoreOS variants have no repositories reported.
+//
+// The zero value is ready to use.
+type RepositoryScanner struct{}
+
+// Name implements [indexer.VersionedScanner].
+func (*RepositoryScanner) Name() string { return "rhcos" }
+
+// Version implements [indexer.VersionedScanner].
+func (*RepositoryScanner) Version() string { return "1" }
+
+// Kind implements [indexer.VersionedScanner].
+func (*RepositoryScanner) Kind() string { return "repository" }
+
+// Scan implements [indexer.RepositoryScanner].
+func (rs *RepositoryScanner) Scan(ctx context.Context, l *claircore.Layer) ([]*claircore.Repository, error) {
+	defer trace.StartRegion(ctx, "RepositoryScanner.Scan").End()
+	ctx = zlog.ContextWithValues(ctx,
+		"component", "rhel/rhcos/RepositoryScanner.Scan",
+		"version", rs.Version(),
+		"layer", l.Hash.String())
+	zlog.Debug(ctx).Msg("start")
+	defer zlog.Debug(ctx).Msg("done")
+
+	sys, err := l.FS()
+	if err != nil {
+		return nil, fmt.Errorf("rhcos: unable to open layer: %w", err)
+	}
+	ds, err := deployments(ctx, sys)
+	if err != nil {
+		return nil, err
+	}
+	var repos []*claircore.Repository
+	seen := make(map[string]struct{})
+	for _, d := range ds {
+		m, err := osRelease(ctx, sys, d)
+		if err != nil {
+			return nil, err
+		}
+		if m == nil || m["ID"] != "rhcos" {
+			continue
+		}
+		for _, c := range repositoryCPEs(m) {
+			if _, ok := seen[c]; ok {
+				continue
+			}
+			seen[c] = struct{}{}
+			wfn, err := cpe.Unbind(c)
+			if err != nil {
+				zlog.Warn(ctx).
+					Err(err).
+					Str("cpe", c).
+					Msg("invalid CPE")
+				continue
+			}
+			repos = append(repos, &claircore.Repository{
+				Name: c,
+				Key:  repositoryKey,
+				CPE:  wfn,
+			})
+		}
+	}
+	return repos, nil
+}
+
+// RepositoryCPEs reports the CPEs of the repositories for RHCOS os-release
+// contents.
+func repositoryCPEs(m map[string]string) []string {
+	major, minor := rhelVersion(m)
+	if major == "" {
+		return nil
+	}
+	cs := []string{
+		"cpe:/o:redhat:enterprise_linux:" + major + "::baseos",
+		"cpe:/a:redhat:enterprise_linux:" + major + "::appstream",
+	}
+	if minor != "" {
+		v := major + "." + minor
+		cs = append(cs,
+			"cpe:/o:redhat:rhel_eus:"+v+"::baseos",
+			"cpe:/a:redhat:rhel_eus:"+v+"::appstream",
+		)
+	}
+	ocp := m["OPENSHIFT_VERSION"]
+	if ocp == "" {
+		ocp = m["VERSION_ID"]
+	}
+	if ocp != "" {
+		cs = append(cs, "cpe:/a:redhat:openshift:"+ocp+"::el"+major)
+	}
+	return cs
+}
+
+// RhelVersion reports the RHEL major and minor versions for RHCOS os-release
+// contents.
+//
+// The "VERSION" of RHCOS is like "416.94.202405291527-0", where the second
+// field is the RHEL version without the dot. A minor version of "" is
+// reported if only the "PLATFORM_ID" is usable.
+func rhelVersion(m map[string]string) (major, minor string) {
+	if v := m["RHEL_VERSION"]; v != "" {
+		major, minor, _ = strings.Cut(v, ".")
+		return major, minor
+	}
+	if vs := strings.Split(m["VERSION"], "."); len(vs) > 2 && len(vs[1]) > 1 && isDigits(vs[1]) {
+		return vs[1][:1], vs[1][1:]
+	}
+	if v, ok := strings.CutPrefix(m["PLATFORM_ID"], "platform:el"); ok && isDigits(v) {
+		return v, ""
+	}
+	return "", ""
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
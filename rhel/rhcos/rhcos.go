@@ -0,0 +1,234 @@
+// Package rhcos implements an ecosystem for the filesystems of OSTree-based
+// hosts, like Red Hat Enterprise Linux CoreOS (RHCOS) and Fedora CoreOS.
+//
+// These don't have container layers: an OpenShift node's filesystem is one
+// OSTree deployment, with the rpm database in "/usr/share/rpm" and the system
+// configuration in "/usr/lib". Either a deployment, such as a node's "/"
+// mounted into a pod, or a physical root containing the "ostree" directory
+// can be indexed as a single layer.
+//
+// RHCOS packages are associated with the RHEL and OpenShift repositories
+// indicated by the os-release file, so they're matched by the
+// [github.com/quay/claircore/rhel] matcher.
+package rhcos
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore/osrelease"
+)
+
+// RepositoryKey is the key the rhel package uses for repositories with CPEs,
+// and must be the same.
+const repositoryKey = `rhel-cpe-repository`
+
+// Deployments reports the roots of the OSTree deployments in "sys" that should
+// be examined.
+//
+// If "sys" is a deployment, "." is reported. If it's a physical root, the
+// deployment booted by default is reported, or every deployment if that can't
+// be determined. If "sys" isn't an OSTree filesystem, nothing is reported.
+func deployments(ctx context.Context, sys fs.FS) ([]string, error) {
+	switch fi, err := fs.Stat(sys, `ostree/deploy`); {
+	case errors.Is(err, nil) && fi.IsDir():
+		return physicalDeployments(ctx, sys)
+	case errors.Is(err, nil), errors.Is(err, fs.ErrNotExist):
+	default:
+		return nil, fmt.Errorf("rhcos: unable to stat %q: %w", `ostree/deploy`, err)
+	}
+	for _, p := range []string{`sysroot/ostree`, `run/ostree-booted`} {
+		_, err := fs.Stat(sys, p)
+		switch {
+		case errors.Is(err, nil):
+			return []string{"."}, nil
+		case errors.Is(err, fs.ErrNotExist):
+		default:
+			return nil, fmt.Errorf("rhcos: unable to stat %q: %w", p, err)
+		}
+	}
+	return nil, nil
+}
+
+// PhysicalDeployments reports the deployments in a physical root.
+func physicalDeployments(ctx context.Context, sys fs.FS) ([]string, error) {
+	ms, err := fs.Glob(sys, `ostree/deploy/*/deploy/*`)
+	if err != nil {
+		panic("programmer error: " + err.Error())
+	}
+	var ds []string
+	for _, m := range ms {
+		fi, err := fs.Stat(sys, m)
+		if err != nil {
+			return nil, fmt.Errorf("rhcos: unable to stat %q: %w", m, err)
+		}
+		// Skip the ".origin" files next to the deployments.
+		if fi.IsDir() {
+			ds = append(ds, m)
+		}
+	}
+	if len(ds) < 2 {
+		return ds, nil
+	}
+	d, err := defaultDeployment(sys)
+	switch {
+	case err != nil:
+		zlog.Info(ctx).
+			Err(err).
+			Msg("unable to determine default deployment, using all")
+	case d != "":
+		for _, m := range ds {
+			if m == d {
+				return []string{d}, nil
+			}
+		}
+		zlog.Info(ctx).
+			Str("deployment", d).
+			Msg("default deployment not found, using all")
+	}
+	sort.Strings(ds)
+	return ds, nil
+}
+
+// DefaultDeployment reports the deployment booted by the highest-versioned
+// boot loader entry, or "" if there are no entries.
+//
+// The entry's "ostree=" kernel argument names a chain of symlinks to the
+// deployment, so "sys" must be able to read symlinks.
+func defaultDeployment(sys fs.FS) (string, error) {
+	rl, ok := sys.(readLinker)
+	if !ok {
+		return "", errors.New("rhcos: unable to read symlinks")
+	}
+	dir, err := resolve(rl, `boot/loader/entries`)
+	if err != nil {
+		return "", err
+	}
+	ms, err := fs.Glob(sys, path.Join(dir, `*.conf`))
+	if err != nil {
+		panic("programmer error: " + err.Error())
+	}
+	var arg string
+	best := -1
+	for _, m := range ms {
+		v, a, err := loaderEntry(sys, m)
+		if err != nil {
+			return "", err
+		}
+		if a != "" && v > best {
+			best, arg = v, a
+		}
+	}
+	if arg == "" {
+		return "", nil
+	}
+	return resolve(rl, strings.TrimPrefix(arg, "/"))
+}
+
+// LoaderEntry reports the "version" and "ostree=" kernel argument of the boot
+// loader entry at "p".
+func loaderEntry(sys fs.FS, p string) (version int, arg string, err error) {
+	f, err := sys.Open(p)
+	if err != nil {
+		return 0, "", fmt.Errorf("rhcos: unable to open %q: %w", p, err)
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		k, v, _ := strings.Cut(strings.TrimSpace(s.Text()), " ")
+		switch k {
+		case "version":
+			version, _ = strconv.Atoi(strings.TrimSpace(v))
+		case "options":
+			for _, o := range strings.Fields(v) {
+				if a, ok := strings.CutPrefix(o, "ostree="); ok {
+					arg = a
+				}
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return 0, "", fmt.Errorf("rhcos: unable to read %q: %w", p, err)
+	}
+	return version, arg, nil
+}
+
+// ReadLinker is implemented by the filesystems in the pkg/tarfs package.
+type readLinker interface {
+	fs.FS
+	ReadLink(name string) (string, error)
+}
+
+// Resolve follows any symlinks in "p", returning a path with none.
+func resolve(sys readLinker, p string) (string, error) {
+	const maxLinks = 40
+	links := 0
+	var done string
+	rest := path.Clean(p)
+	for rest != "" && rest != "." {
+		var elem string
+		elem, rest, _ = strings.Cut(rest, "/")
+		cur := path.Join(done, elem)
+		tgt, err := sys.ReadLink(cur)
+		if err != nil {
+			// Not a symlink, or doesn't exist: the caller will find out when
+			// it's used.
+			done = cur
+			continue
+		}
+		links++
+		if links > maxLinks {
+			return "", fmt.Errorf("rhcos: too many links resolving %q", p)
+		}
+		if path.IsAbs(tgt) {
+			done = ""
+			tgt = strings.TrimPrefix(tgt, "/")
+		}
+		rest = path.Join(done, tgt, rest)
+		done = ""
+		if strings.HasPrefix(rest, "../") || rest == ".." {
+			return "", fmt.Errorf("rhcos: link escapes filesystem resolving %q", p)
+		}
+	}
+	if done == "" {
+		done = "."
+	}
+	return done, nil
+}
+
+// OsRelease reads and parses the os-release file of the deployment at "root".
+//
+// A nil map is returned if there's no os-release file.
+func osRelease(ctx context.Context, sys fs.FS, root string) (map[string]string, error) {
+	// The "etc/os-release" file is a symlink to this one.
+	p := path.Join(root, `usr/lib/os-release`)
+	f, err := sys.Open(p)
+	switch {
+	case errors.Is(err, nil):
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("rhcos: unable to open %q: %w", p, err)
+	}
+	defer f.Close()
+	m, err := osrelease.Parse(ctx, f)
+	if err != nil {
+		return nil, fmt.Errorf("rhcos: unable to parse %q: %w", p, err)
+	}
+	return m, nil
+}
+
+// IsCoreOS reports whether the os-release contents describe RHCOS or another
+// CoreOS variant.
+func isCoreOS(m map[string]string) bool {
+	return m["ID"] == "rhcos" || m["VARIANT_ID"] == "coreos"
+}
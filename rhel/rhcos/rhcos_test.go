@@ -0,0 +1,286 @@
+package rhcos
+
+import (
+	"archive/tar"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/rpm"
+	"github.com/quay/claircore/test"
+	"github.com/quay/claircore/toolkit/types/cpe"
+)
+
+const osReleaseRHCOS = `NAME="Red Hat Enterprise Linux CoreOS"
+ID="rhcos"
+ID_LIKE="rhel fedora"
+VERSION="416.94.202405291527-0"
+VERSION_ID="4.16"
+VARIANT="CoreOS"
+VARIANT_ID=coreos
+PLATFORM_ID="platform:el9"
+PRETTY_NAME="Red Hat Enterprise Linux CoreOS 416.94.202405291527-0 (Plow)"
+CPE_NAME="cpe:/o:redhat:enterprise_linux:9::coreos"
+OPENSHIFT_VERSION="4.16"
+RHEL_VERSION="9.4"
+`
+
+// The sqlite rpm database from the rpm package's tests.
+const rpmdb = `../../rpm/sqlite/testdata/rpmdb.sqlite`
+
+// Entry is a file in a generated layer: a directory if Body and Link are
+// empty and the name ends in a slash, a symlink if Link is set, and a regular
+// file with the contents of Body or, if File is set, the named file.
+type entry struct {
+	Name, Body, Link, File string
+}
+
+// MkLayer writes a tar of the entries and returns it as an initialized Layer.
+func mkLayer(ctx context.Context, t *testing.T, ents []entry) *claircore.Layer {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "layer.tar")
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := tar.NewWriter(f)
+	for _, e := range ents {
+		h := tar.Header{Name: e.Name, Mode: 0o644}
+		var body []byte
+		switch {
+		case e.Link != "":
+			h.Typeflag = tar.TypeSymlink
+			h.Linkname = e.Link
+		case strings.HasSuffix(e.Name, "/"):
+			h.Typeflag = tar.TypeDir
+			h.Mode = 0o755
+		case e.File != "":
+			body, err = os.ReadFile(e.File)
+			if err != nil {
+				t.Fatal(err)
+			}
+		default:
+			body = []byte(e.Body)
+		}
+		if h.Typeflag == 0 {
+			h.Typeflag = tar.TypeReg
+			h.Size = int64(len(body))
+		}
+		if err := tw.WriteHeader(&h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	var l claircore.Layer
+	desc := claircore.LayerDescription{
+		Digest:    test.RandomSHA256Digest(t).String(),
+		URI:       "file:///dev/null",
+		MediaType: test.MediaType,
+		Headers:   make(map[string][]string),
+	}
+	if err := l.Init(ctx, &desc, f); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := l.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+	return &l
+}
+
+// PhysicalRoot is a physical root with two deployments, where "abc.0" is the
+// default.
+var physicalRoot = []entry{
+	{Name: "boot/"},
+	{Name: "boot/loader.1/"},
+	{Name: "boot/loader", Link: "loader.1"},
+	{Name: "boot/loader.1/entries/"},
+	{Name: "boot/loader.1/entries/ostree-1-rhcos.conf", Body: "title Red Hat Enterprise Linux CoreOS (rollback)\nversion 1\noptions root=UUID=x ostree=/ostree/boot.1/rhcos/b00t/1\n"},
+	{Name: "boot/loader.1/entries/ostree-2-rhcos.conf", Body: "title Red Hat Enterprise Linux CoreOS\nversion 2\noptions root=UUID=x ostree=/ostree/boot.1/rhcos/b00t/0\n"},
+	{Name: "ostree/"},
+	{Name: "ostree/repo/"},
+	{Name: "ostree/boot.1.1/"},
+	{Name: "ostree/boot.1", Link: "boot.1.1"},
+	{Name: "ostree/boot.1.1/rhcos/"},
+	{Name: "ostree/boot.1.1/rhcos/b00t/"},
+	{Name: "ostree/boot.1.1/rhcos/b00t/0", Link: "../../../deploy/rhcos/deploy/abc.0"},
+	{Name: "ostree/boot.1.1/rhcos/b00t/1", Link: "../../../deploy/rhcos/deploy/def.0"},
+	{Name: "ostree/deploy/"},
+	{Name: "ostree/deploy/rhcos/"},
+	{Name: "ostree/deploy/rhcos/deploy/"},
+	{Name: "ostree/deploy/rhcos/deploy/abc.0.origin", Body: "[origin]\n"},
+	{Name: "ostree/deploy/rhcos/deploy/abc.0/"},
+	{Name: "ostree/deploy/rhcos/deploy/abc.0/usr/"},
+	{Name: "ostree/deploy/rhcos/deploy/abc.0/usr/lib/"},
+	{Name: "ostree/deploy/rhcos/deploy/abc.0/usr/lib/os-release", Body: osReleaseRHCOS},
+	{Name: "ostree/deploy/rhcos/deploy/abc.0/usr/share/"},
+	{Name: "ostree/deploy/rhcos/deploy/abc.0/usr/share/rpm/"},
+	{Name: "ostree/deploy/rhcos/deploy/abc.0/usr/share/rpm/rpmdb.sqlite", File: rpmdb},
+	{Name: "ostree/deploy/rhcos/deploy/def.0.origin", Body: "[origin]\n"},
+	{Name: "ostree/deploy/rhcos/deploy/def.0/"},
+	{Name: "ostree/deploy/rhcos/deploy/def.0/usr/"},
+	{Name: "ostree/deploy/rhcos/deploy/def.0/usr/lib/"},
+	{Name: "ostree/deploy/rhcos/deploy/def.0/usr/lib/os-release", Body: strings.ReplaceAll(osReleaseRHCOS, "4.16", "4.15")},
+	{Name: "ostree/deploy/rhcos/deploy/def.0/usr/share/"},
+	{Name: "ostree/deploy/rhcos/deploy/def.0/usr/share/rpm/"},
+	{Name: "ostree/deploy/rhcos/deploy/def.0/usr/share/rpm/rpmdb.sqlite", File: rpmdb},
+}
+
+// DeploymentRoot is a booted deployment, as mounted on a running node.
+var deploymentRoot = []entry{
+	{Name: "etc/"},
+	{Name: "etc/os-release", Link: "../usr/lib/os-release"},
+	{Name: "ostree", Link: "sysroot/ostree"},
+	{Name: "sysroot/"},
+	{Name: "sysroot/ostree/"},
+	{Name: "sysroot/ostree/repo/"},
+	{Name: "usr/"},
+	{Name: "usr/lib/"},
+	{Name: "usr/lib/os-release", Body: osReleaseRHCOS},
+	{Name: "usr/lib/sysimage/"},
+	{Name: "usr/lib/sysimage/rpm/"},
+	{Name: "usr/lib/sysimage/rpm/rpmdb.sqlite", File: rpmdb},
+	{Name: "usr/share/"},
+	{Name: "usr/share/rpm", Link: "../lib/sysimage/rpm"},
+}
+
+func TestScanners(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	var wantRepos []*claircore.Repository
+	for _, c := range []string{
+		"cpe:/o:redhat:enterprise_linux:9::baseos",
+		"cpe:/a:redhat:enterprise_linux:9::appstream",
+		"cpe:/o:redhat:rhel_eus:9.4::baseos",
+		"cpe:/a:redhat:rhel_eus:9.4::appstream",
+		"cpe:/a:redhat:openshift:4.16::el9",
+	} {
+		wantRepos = append(wantRepos, &claircore.Repository{
+			Name: c,
+			Key:  repositoryKey,
+			CPE:  cpe.MustUnbind(c),
+		})
+	}
+	wantDist := []*claircore.Distribution{{
+		Name:       "Red Hat Enterprise Linux CoreOS",
+		DID:        "rhcos",
+		Version:    "416.94.202405291527-0",
+		VersionID:  "4.16",
+		PrettyName: "Red Hat Enterprise Linux CoreOS 416.94.202405291527-0 (Plow)",
+		CPE:        cpe.MustUnbind("cpe:/o:redhat:enterprise_linux:9::coreos"),
+	}}
+
+	tt := []struct {
+		Name   string
+		Layer  []entry
+		PkgDB  string
+		Nested bool
+	}{
+		{Name: "Physical", Layer: physicalRoot, PkgDB: "sqlite:ostree/deploy/rhcos/deploy/abc.0/usr/share/rpm", Nested: true},
+		// The database is reported at its real location, not through the
+		// "usr/share/rpm" symlink.
+		{Name: "Deployment", Layer: deploymentRoot, PkgDB: "sqlite:usr/lib/sysimage/rpm"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctx := zlog.Test(ctx, t)
+			l := mkLayer(ctx, t, tc.Layer)
+
+			pkgs, err := new(PackageScanner).Scan(ctx, l)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pkgs) == 0 {
+				t.Error("no packages found")
+			}
+			for _, p := range pkgs {
+				if p.PackageDB != tc.PkgDB {
+					t.Errorf("%s: unexpected package database: %q", p.Name, p.PackageDB)
+					break
+				}
+			}
+
+			dists, err := new(DistributionScanner).Scan(ctx, l)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(dists, wantDist) {
+				t.Error(cmp.Diff(dists, wantDist))
+			}
+
+			repos, err := new(RepositoryScanner).Scan(ctx, l)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(repos, wantRepos) {
+				t.Error(cmp.Diff(repos, wantRepos))
+			}
+
+			// The generic rpm scanner shouldn't report the deployments of a
+			// physical root.
+			if tc.Nested {
+				ps, err := new(rpm.Scanner).Scan(ctx, l)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(ps) != 0 {
+					t.Errorf("rpm scanner found %d packages in OSTree deployments", len(ps))
+				}
+			}
+		})
+	}
+
+	t.Run("NotOSTree", func(t *testing.T) {
+		ctx := zlog.Test(ctx, t)
+		l := mkLayer(ctx, t, []entry{
+			{Name: "etc/"},
+			{Name: "etc/os-release", Body: osReleaseRHCOS},
+			{Name: "usr/"},
+			{Name: "usr/lib/"},
+			{Name: "usr/lib/os-release", Body: osReleaseRHCOS},
+		})
+		pkgs, err := new(PackageScanner).Scan(ctx, l)
+		if err != nil || pkgs != nil {
+			t.Errorf("got (%v, %v), want (nil, nil)", pkgs, err)
+		}
+		dists, err := new(DistributionScanner).Scan(ctx, l)
+		if err != nil || dists != nil {
+			t.Errorf("got (%v, %v), want (nil, nil)", dists, err)
+		}
+	})
+}
+
+func TestRHELVersion(t *testing.T) {
+	t.Parallel()
+	tt := []struct {
+		In           map[string]string
+		Major, Minor string
+	}{
+		{In: map[string]string{"RHEL_VERSION": "9.4", "VERSION": "416.94.202405291527-0"}, Major: "9", Minor: "4"},
+		{In: map[string]string{"VERSION": "410.84.202205191234-0"}, Major: "8", Minor: "4"},
+		{In: map[string]string{"VERSION": "latest", "PLATFORM_ID": "platform:el8"}, Major: "8"},
+		{In: map[string]string{"VERSION": "4.16"}},
+	}
+	for _, tc := range tt {
+		major, minor := rhelVersion(tc.In)
+		if major != tc.Major || minor != tc.Minor {
+			t.Errorf("%v: got (%q, %q), want (%q, %q)", tc.In, major, minor, tc.Major, tc.Minor)
+		}
+	}
+}
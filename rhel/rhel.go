@@ -3,19 +3,36 @@
 //
 // See the various exported types for details on the heuristics employed.
 //
+// Security data is available from both the OVAL feeds, via [Updater], and the
+// CSAF advisories, via [CSAFUpdater]; [Factory] can be configured to use
+// either.
+//
 // In addition, containers themselves are recognized via the
-// [github.com/quay/claircore/rhel/rhcc] package.
+// [github.com/quay/claircore/rhel/rhcc] package, and OSTree-based hosts like
+// OpenShift nodes via the [github.com/quay/claircore/rhel/rhcos] package.
 package rhel // import "github.com/quay/claircore/rhel"
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 
 	"github.com/quay/zlog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/crypto/openpgp"
 
 	"github.com/quay/claircore"
 	"github.com/quay/claircore/libvuln/driver"
+	"github.com/quay/claircore/pkg/errs"
+	"github.com/quay/claircore/pkg/fetch"
 	"github.com/quay/claircore/pkg/ovalutil"
 )
 
@@ -30,44 +47,465 @@ type Updater struct {
 	dist             *claircore.Distribution
 	name             string
 	ignoreUnpatched  bool
+	tracer           trace.Tracer
+	// CacheDir is the directory to keep fetch fingerprints in. See WithCache.
+	cacheDir string
+	// CPEFilter, if set, selects definitions to keep. See WithCPEFilter.
+	cpeFilter func(string) bool
+	// Incremental and results are set by WithIncrementalStore.
+	incremental IncrementalStore
+	results     IncrementalResults
+	// Workers is the number of workers used by Parse. See WithWorkers.
+	workers int
+	// MinSeverity, if set, is the least severe definition kept. See
+	// WithMinSeverity.
+	minSeverity Severity
+	// CVSSSeverity reports CVSS vectors as the Severity. See
+	// WithCVSSSeverity.
+	cvssSeverity bool
+	// Unpatched, if set, is the states of unpatched vulnerabilities to keep.
+	// See WithUnpatchedStates.
+	unpatched map[claircore.FixState]struct{}
+	// Client configures the HTTP client passed to Configure. See
+	// UpdaterConfig.
+	client clientConfig
+}
+
+// Option configures the provided Updater.
+type Option func(*Updater) error
+
+// WithTelemetry configures the Updater to emit spans using the provided
+// tracer instead of the package default, which uses the global
+// TracerProvider.
+func WithTelemetry(tracer trace.Tracer) Option {
+	return func(u *Updater) error {
+		if tracer == nil {
+			return errors.New("rhel: nil tracer")
+		}
+		u.tracer = tracer
+		return nil
+	}
+}
+
+// WithCPEFilter configures the Updater to discard any OVAL definition that
+// doesn't list at least one CPE for which "f" reports true. The CPEs are
+// passed as they appear in the database, e.g.
+// "cpe:/o:redhat:enterprise_linux:8".
+//
+// This allows for an Updater scoped to some products, without needing to
+// filter the results of Parse.
+func WithCPEFilter(f func(cpe string) bool) Option {
+	return func(u *Updater) error {
+		if f == nil {
+			return errors.New("rhel: nil CPE filter")
+		}
+		u.cpeFilter = f
+		return nil
+	}
+}
+
+// WithStreaming configures the Updater to decompress the database as Parse
+// reads it, instead of spooling it to disk in Fetch. The reader returned by
+// Fetch must then be consumed before the Context passed to Fetch is canceled,
+// which is the case when the Updater is run by the updater manager.
+//
+// Streaming also makes a ".bz2", ".gz", or ".zst" extension on the database URL
+// determine the compression when the server doesn't report a specific
+// Content-Type. Streaming has no effect with WithSignatureVerification, so
+// that the database is verified before it's parsed.
+func WithStreaming() Option {
+	return func(u *Updater) error {
+		u.Fetcher.Stream = true
+		u.Fetcher.CompressionFromExtension = true
+		return nil
+	}
+}
+
+// ErrSignatureInvalid is reported when an Updater configured with
+// WithSignatureVerification fetches a database whose signature can't be
+// verified.
+//
+// Errors matching ErrSignatureInvalid also contain a
+// [github.com/quay/claircore/pkg/errs.ValidationError], or a
+// [github.com/quay/claircore/pkg/errs.FetchError] if the signature itself
+// couldn't be fetched.
+var ErrSignatureInvalid = ovalutil.ErrSignatureInvalid
+
+// WithSignatureVerification configures the Updater to check the detached
+// OpenPGP signature published alongside the OVAL database (at the database URL
+// with ".asc" appended) before returning it from Fetch.
+//
+// The keyring is read as one or more armored public key blocks. A signature by
+// any of the keys is accepted, so that both keys can be provided while Red Hat
+// rotates its signing key.
+func WithSignatureVerification(keyring io.Reader) Option {
+	return func(u *Updater) error {
+		b, err := io.ReadAll(keyring)
+		if err != nil {
+			return fmt.Errorf("rhel: unable to read keyring: %w", err)
+		}
+		const header = "-----BEGIN PGP PUBLIC KEY BLOCK-----"
+		var ks openpgp.EntityList
+		// ReadArmoredKeyRing only consumes the first armored block.
+		for _, blk := range strings.Split(string(b), header)[1:] {
+			l, err := openpgp.ReadArmoredKeyRing(strings.NewReader(header + blk))
+			if err != nil {
+				return fmt.Errorf("rhel: unable to read keyring: %w", err)
+			}
+			ks = append(ks, l...)
+		}
+		if len(ks) == 0 {
+			return errors.New("rhel: empty keyring")
+		}
+		u.Fetcher.Keyring = ks
+		return nil
+	}
 }
 
 // UpdaterConfig is the configuration expected for any given updater.
 //
 // See also [ovalutil.FetcherConfig].
 type UpdaterConfig struct {
-	ovalutil.FetcherConfig
-	Release int64 `json:"release" yaml:"release"`
+	ovalutil.FetcherConfig `yaml:",inline"`
+	Release                int64 `json:"release" yaml:"release"`
+	// CVSSSeverity, if set, overrides whether the Updater was configured
+	// with WithCVSSSeverity.
+	CVSSSeverity *bool `json:"cvss_severity" yaml:"cvss_severity"`
+	// UnpatchedStates, if set, overrides the states configured with
+	// WithUnpatchedStates.
+	UnpatchedStates []claircore.FixState `json:"unpatched_states" yaml:"unpatched_states"`
+	// MinSeverity, if set, overrides the severity configured with
+	// WithMinSeverity. It's one of "low", "medium", "high", or "critical".
+	MinSeverity Severity `json:"min_severity" yaml:"min_severity"`
+	// Workers, if set, overrides the number of workers configured with
+	// WithWorkers, which bounds how many definitions are parsed at once. It
+	// must be between 0 and MaxWorkers, where 0 leaves the configured number
+	// unchanged.
+	Workers int `json:"workers" yaml:"workers"`
+	// Retries, if set, is the number of times a failed request for the
+	// database is retried. By default, requests aren't retried. It must be
+	// between 0 and MaxRetries.
+	Retries *int `json:"retries" yaml:"retries"`
+	// Backoff and MaxBackoff, if set, are the delay before the first retry
+	// and the most it's allowed to grow to. See [fetch.WithBackoff].
+	Backoff    time.Duration `json:"backoff" yaml:"backoff"`
+	MaxBackoff time.Duration `json:"max_backoff" yaml:"max_backoff"`
+	// Timeout, if set, limits the time taken by each request, including
+	// reading the response body. See [http.Client.Timeout].
+	Timeout time.Duration `json:"timeout" yaml:"timeout"`
+	// CheckURL, if set, makes Configure issue a HEAD request for the database
+	// and report an error if it's unreachable.
+	CheckURL bool `json:"check_url" yaml:"check_url"`
+}
+
+// These are the bounds checked when validating an UpdaterConfig.
+const (
+	MaxWorkers = 256
+	MaxRetries = 10
+)
+
+// Validate reports an error if any of the configured values are out of
+// bounds.
+func (c *UpdaterConfig) validate() error {
+	if c.URL != "" {
+		if err := checkURL(c.URL); err != nil {
+			return err
+		}
+	}
+	if _, err := ovalutil.ParseCompressor(c.Compression); err != nil {
+		return fmt.Errorf("rhel: config: %w", err)
+	}
+	if c.Release < 0 {
+		return fmt.Errorf("rhel: config: invalid release: %d", c.Release)
+	}
+	if len(c.UnpatchedStates) != 0 {
+		if _, err := unpatchedSet(c.UnpatchedStates); err != nil {
+			return fmt.Errorf("rhel: config: %w", err)
+		}
+	}
+	if c.MinSeverity > SeverityCritical {
+		return fmt.Errorf("rhel: config: invalid min_severity: %v", c.MinSeverity)
+	}
+	if c.Workers < 0 || c.Workers > MaxWorkers {
+		return fmt.Errorf("rhel: config: workers must be between 0 and %d: %d", MaxWorkers, c.Workers)
+	}
+	if c.Retries != nil && (*c.Retries < 0 || *c.Retries > MaxRetries) {
+		return fmt.Errorf("rhel: config: retries must be between 0 and %d: %d", MaxRetries, *c.Retries)
+	}
+	switch {
+	case c.Backoff < 0:
+		return fmt.Errorf("rhel: config: invalid backoff: %v", c.Backoff)
+	case c.MaxBackoff < 0, c.MaxBackoff != 0 && c.MaxBackoff < c.Backoff:
+		return fmt.Errorf("rhel: config: invalid max_backoff: %v", c.MaxBackoff)
+	case c.Timeout < 0:
+		return fmt.Errorf("rhel: config: invalid timeout: %v", c.Timeout)
+	}
+	return nil
 }
 
-// NewUpdater returns an Updater.
-func NewUpdater(name string, release int, uri string, ignoreUnpatched bool) (*Updater, error) {
+// CheckURL reports an error if "raw" isn't a database URL the Updater can
+// fetch.
+func checkURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("rhel: config: invalid url: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		if u.Host == "" {
+			return fmt.Errorf("rhel: config: invalid url %q: missing host", raw)
+		}
+	case "file":
+	default:
+		return fmt.Errorf("rhel: config: invalid url %q: unsupported scheme %q", raw, u.Scheme)
+	}
+	return nil
+}
+
+// Apply sets the Updater's members from the validated configuration "cfg".
+// The URL and compression are handled by the embedded Fetcher.
+func (u *Updater) apply(cfg *UpdaterConfig) {
+	if cfg.Release != 0 {
+		u.dist = mkRelease(cfg.Release)
+	}
+	if cfg.CVSSSeverity != nil {
+		u.cvssSeverity = *cfg.CVSSSeverity
+	}
+	if len(cfg.UnpatchedStates) != 0 {
+		u.unpatched, _ = unpatchedSet(cfg.UnpatchedStates)
+	}
+	if cfg.MinSeverity != 0 {
+		u.minSeverity = cfg.MinSeverity
+	}
+	if cfg.Workers != 0 {
+		u.workers = cfg.Workers
+	}
+	if cfg.Retries != nil {
+		n := *cfg.Retries
+		u.client.retries = &n
+	}
+	if cfg.Backoff != 0 {
+		u.client.backoff = cfg.Backoff
+	}
+	if cfg.MaxBackoff != 0 {
+		u.client.maxBackoff = cfg.MaxBackoff
+	}
+	if cfg.Timeout != 0 {
+		u.client.timeout = cfg.Timeout
+	}
+	if cfg.CheckURL {
+		u.client.check = true
+	}
+}
+
+// ClientConfig is the configuration for the HTTP client, which isn't
+// available until Configure is called.
+type clientConfig struct {
+	retries             *int
+	backoff, maxBackoff time.Duration
+	timeout             time.Duration
+	check               bool
+}
+
+// SetClient configures the embedded Fetcher to use "c", as modified by the
+// Updater's configuration.
+func (u *Updater) setClient(ctx context.Context, c *http.Client) error {
+	cfg := &u.client
+	if cfg.timeout != 0 && c != nil {
+		cc := *c
+		cc.Timeout = cfg.timeout
+		c = &cc
+	}
+	u.Fetcher.Client = c
+	u.Fetcher.Retry = nil
+	if cfg.retries != nil || cfg.backoff != 0 || cfg.maxBackoff != 0 {
+		var opts []fetch.Option
+		if cfg.retries != nil {
+			opts = append(opts, fetch.WithRetries(*cfg.retries))
+		} else {
+			opts = append(opts, fetch.WithRetries(fetch.DefaultRetries))
+		}
+		if cfg.backoff != 0 || cfg.maxBackoff != 0 {
+			b, max := cfg.backoff, cfg.maxBackoff
+			if b == 0 {
+				b = fetch.DefaultBackoff
+			}
+			if max == 0 {
+				max = fetch.DefaultMaxBackoff
+			}
+			if max < b {
+				max = b
+			}
+			opts = append(opts, fetch.WithBackoff(b, max))
+		}
+		f, err := fetch.NewFetcher(c, opts...)
+		if err != nil {
+			return fmt.Errorf("rhel: %w", err)
+		}
+		u.Fetcher.Retry = f
+	}
+	if cfg.check && u.Fetcher.URL.Scheme != "file" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.Fetcher.URL.String(), nil)
+		if err != nil {
+			return fmt.Errorf("rhel: %w", err)
+		}
+		do := u.Fetcher.Client.Do
+		if u.Fetcher.Retry != nil {
+			do = u.Fetcher.Retry.Do
+		}
+		res, err := do(req)
+		if err != nil {
+			return fmt.Errorf("rhel: database unreachable: %w", err)
+		}
+		res.Body.Close()
+		if res.StatusCode >= 400 {
+			return fmt.Errorf("rhel: database unreachable: %w",
+				&errs.FetchError{URL: u.Fetcher.URL.String(), StatusCode: res.StatusCode})
+		}
+	}
+	return nil
+}
+
+// NewUpdater returns an Updater, configured according to the provided
+// Options.
+func NewUpdater(name string, release int, uri string, ignoreUnpatched bool, opts ...Option) (*Updater, error) {
 	u := &Updater{
 		name:            name,
 		dist:            mkRelease(int64(release)),
 		ignoreUnpatched: ignoreUnpatched,
+		tracer:          tracer,
 	}
 	var err error
 	u.Fetcher.URL, err = url.Parse(uri)
 	if err != nil {
 		return nil, err
 	}
+	for _, o := range opts {
+		if err := o(u); err != nil {
+			return nil, err
+		}
+	}
+	return u, nil
+}
+
+// RHELUpdaterConfig is a complete, standalone description of an Updater,
+// suitable for loading from a configuration file at startup.
+//
+// Unlike [UpdaterConfig], which only describes changes to an existing
+// Updater, every field needed to construct an Updater is present here. See
+// [NewFromConfig].
+type RHELUpdaterConfig struct {
+	// Name is the name reported by the Updater. It's used as the key for the
+	// Updater's data in the database, so it should be unique and stable.
+	Name string `json:"name" yaml:"name"`
+	// IgnoreUnpatched dictates whether to ingest unpatched advisory data.
+	IgnoreUnpatched bool `json:"ignore_unpatched" yaml:"ignore_unpatched"`
+	// UpdaterConfig holds the rest of the configuration. The URL, which
+	// accepts the "http", "https", and "file" schemes, and the Release are
+	// required.
+	UpdaterConfig `yaml:",inline"`
+}
+
+// Validate reports an error if the configuration cannot be used to construct
+// an Updater.
+//
+// Validate does not make any network requests; see [UpdaterConfig.CheckURL].
+func (c *RHELUpdaterConfig) Validate() error {
+	if c.Name == "" {
+		return errors.New("rhel: config: missing name")
+	}
+	if c.Release < 1 {
+		return fmt.Errorf("rhel: config: invalid release: %d", c.Release)
+	}
+	if c.URL == "" {
+		return errors.New("rhel: config: missing url")
+	}
+	return c.UpdaterConfig.validate()
+}
+
+// NewFromConfig validates the provided configuration and returns an Updater
+// built from it and the provided Options.
+//
+// The returned Updater still needs to have its Configure method called to
+// provide an HTTP client. Values passed to Configure take precedence.
+func NewFromConfig(cfg RHELUpdaterConfig, opts ...Option) (*Updater, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	u, err := NewUpdater(cfg.Name, int(cfg.Release), cfg.URL, cfg.IgnoreUnpatched, opts...)
+	if err != nil {
+		return nil, err
+	}
+	u.apply(&cfg.UpdaterConfig)
+	// Validated above.
+	u.Fetcher.Compression, _ = ovalutil.ParseCompressor(cfg.Compression)
 	return u, nil
 }
 
 // Configure implements [driver.Configurable].
+//
+// The configuration is validated before any of it is applied. See
+// [UpdaterConfig] for the accepted values.
 func (u *Updater) Configure(ctx context.Context, cf driver.ConfigUnmarshaler, c *http.Client) error {
 	ctx = zlog.ContextWithValues(ctx, "component", "rhel/Updater.Configure")
 	var cfg UpdaterConfig
 	if err := cf(&cfg); err != nil {
 		return err
 	}
-	if cfg.Release != 0 {
-		u.dist = mkRelease(cfg.Release)
+	if err := cfg.validate(); err != nil {
+		return err
 	}
-
-	return u.Fetcher.Configure(ctx, cf, c)
+	u.apply(&cfg)
+	if err := u.Fetcher.Configure(ctx, cf, c); err != nil {
+		return err
+	}
+	return u.setClient(ctx, c)
 }
 
 // Name implements [driver.Updater].
 func (u *Updater) Name() string { return u.name }
+
+// Fetch implements [driver.Updater].
+//
+// This wraps the embedded [ovalutil.Fetcher], which handles fetching and
+// decompressing the database, in a span. If the Updater was configured with
+// [WithStreaming], the returned reader may decompress the response as it's
+// read, so it must be consumed before "ctx" is canceled. If the Updater was
+// configured with [WithCache], the cache is consulted when "hint" is empty.
+func (u *Updater) Fetch(ctx context.Context, hint driver.Fingerprint) (io.ReadCloser, driver.Fingerprint, error) {
+	ctx, span := u.getTracer().Start(ctx, "rhel.updater.fetch",
+		trace.WithAttributes(attribute.String("updater", u.name)))
+	defer span.End()
+	if u.cacheDir != "" && hint == "" {
+		hint = u.cachedHint(ctx)
+	}
+	rc, fp, err := u.Fetcher.Fetch(ctx, hint)
+	switch {
+	case errors.Is(err, nil):
+		if u.cacheDir != "" {
+			u.storeHint(ctx, fp)
+		}
+		if s, ok := rc.(interface{ Stat() (fs.FileInfo, error) }); ok {
+			if fi, err := s.Stat(); err == nil {
+				span.SetAttributes(attribute.Int64("bytes", fi.Size()))
+			}
+		}
+		span.SetStatus(codes.Ok, "")
+	case errors.Is(err, driver.Unchanged):
+		span.SetAttributes(attribute.Bool("unchanged", true))
+		span.SetStatus(codes.Ok, "")
+	default:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "fetch error")
+	}
+	return rc, fp, err
+}
+
+// GetTracer returns the configured tracer, falling back to the package
+// default for Updaters constructed without NewUpdater.
+func (u *Updater) getTracer() trace.Tracer {
+	if u.tracer == nil {
+		return tracer
+	}
+	return u.tracer
+}
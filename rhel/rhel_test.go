@@ -0,0 +1,218 @@
+package rhel
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/quay/zlog"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/quay/claircore/libvuln/driver"
+	"github.com/quay/claircore/pkg/ovalutil"
+)
+
+func TestNewFromConfig(t *testing.T) {
+	t.Parallel()
+	const good = `{"name":"rhel-8","url":"https://example.com/rhel-8.oval.xml.bz2","compression":"bzip2","release":8,"ignore_unpatched":true,"workers":4,"min_severity":"important","retries":2,"timeout":60000000000}`
+	var cfg RHELUpdaterConfig
+	if err := json.Unmarshal([]byte(good), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	u, err := NewFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := u.Name(), "rhel-8"; got != want {
+		t.Errorf("name: got: %q, want: %q", got, want)
+	}
+	if got, want := u.dist.Version, "8"; got != want {
+		t.Errorf("release: got: %q, want: %q", got, want)
+	}
+	if got, want := u.Fetcher.Compression, ovalutil.CompressionBzip2; got != want {
+		t.Errorf("compression: got: %v, want: %v", got, want)
+	}
+	if !u.ignoreUnpatched {
+		t.Error("ignore_unpatched not propagated")
+	}
+	if got, want := u.workers, 4; got != want {
+		t.Errorf("workers: got: %d, want: %d", got, want)
+	}
+	if got, want := u.minSeverity, SeverityHigh; got != want {
+		t.Errorf("min_severity: got: %v, want: %v", got, want)
+	}
+	if got, want := u.client.timeout, time.Minute; got != want {
+		t.Errorf("timeout: got: %v, want: %v", got, want)
+	}
+	if u.client.retries == nil || *u.client.retries != 2 {
+		t.Errorf("retries: got: %v, want: 2", u.client.retries)
+	}
+
+	mk := func(name, uri string, release int64) RHELUpdaterConfig {
+		var c RHELUpdaterConfig
+		c.Name = name
+		c.URL = uri
+		c.Release = release
+		return c
+	}
+	neg := -1
+	bad := []RHELUpdaterConfig{
+		mk("", "https://example.com/", 8),
+		mk("rhel-8", "https://example.com/", 0),
+		mk("rhel-8", "", 8),
+		mk("rhel-8", "ftp://example.com/", 8),
+		mk("rhel-8", "https:///nohost", 8),
+		func() RHELUpdaterConfig {
+			c := mk("rhel-8", "https://example.com/", 8)
+			c.Compression = "lz4"
+			return c
+		}(),
+		func() RHELUpdaterConfig {
+			c := mk("rhel-8", "https://example.com/", 8)
+			c.Workers = MaxWorkers + 1
+			return c
+		}(),
+		func() RHELUpdaterConfig {
+			c := mk("rhel-8", "https://example.com/", 8)
+			c.Workers = -1
+			return c
+		}(),
+		func() RHELUpdaterConfig {
+			c := mk("rhel-8", "https://example.com/", 8)
+			c.Retries = &neg
+			return c
+		}(),
+		func() RHELUpdaterConfig {
+			c := mk("rhel-8", "https://example.com/", 8)
+			c.Backoff = time.Minute
+			c.MaxBackoff = time.Second
+			return c
+		}(),
+	}
+	for _, cfg := range bad {
+		if _, err := NewFromConfig(cfg); err == nil {
+			t.Errorf("%+v: expected error", cfg)
+		} else {
+			t.Logf("%+v: got expected error: %v", cfg, err)
+		}
+	}
+	var sev Severity
+	if err := json.Unmarshal([]byte(`"severe"`), &sev); err == nil {
+		t.Error("expected error for unknown severity")
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	var n int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&n, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		http.ServeFile(w, r, "testdata/Red_Hat_Enterprise_Linux_3.xml")
+	}))
+	defer srv.Close()
+	unmarshal := func(s string) driver.ConfigUnmarshaler {
+		return func(v interface{}) error { return json.Unmarshal([]byte(s), v) }
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		u, err := NewUpdater(`rhel-3-updater`, 3, srv.URL, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, cfg := range []string{
+			`{"workers":-1}`,
+			`{"retries":100}`,
+			`{"timeout":-1}`,
+			`{"min_severity":"severe"}`,
+			`{"url":"gopher://example.com/"}`,
+		} {
+			err := u.Configure(ctx, unmarshal(cfg), srv.Client())
+			t.Logf("%s: %v", cfg, err)
+			if err == nil {
+				t.Errorf("%s: expected error", cfg)
+			}
+		}
+	})
+	t.Run("Retry", func(t *testing.T) {
+		u, err := NewUpdater(`rhel-3-updater`, 3, srv.URL, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg := `{"retries":1,"backoff":1000000,"check_url":true}`
+		if err := u.Configure(ctx, unmarshal(cfg), srv.Client()); err != nil {
+			t.Fatal(err)
+		}
+		rc, _, err := u.Fetch(ctx, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		if _, err := u.Parse(ctx, rc); err != nil {
+			t.Fatal(err)
+		}
+		// The URL check fails once and is retried, then the database is fetched.
+		if got, want := atomic.LoadInt32(&n), int32(3); got != want {
+			t.Errorf("got %d requests, want %d", got, want)
+		}
+	})
+}
+
+// RecordingTracer is a trace.Tracer that records the names of started spans.
+type recordingTracer struct {
+	embedded.Tracer
+	mu    sync.Mutex
+	names []string
+}
+
+func (r *recordingTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	r.mu.Lock()
+	r.names = append(r.names, name)
+	r.mu.Unlock()
+	return noop.NewTracerProvider().Tracer("").Start(ctx, name, opts...)
+}
+
+func TestTelemetry(t *testing.T) {
+	t.Parallel()
+	ctx := zlog.Test(context.Background(), t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "testdata/Red_Hat_Enterprise_Linux_3.xml")
+	}))
+	defer srv.Close()
+
+	tr := &recordingTracer{}
+	u, err := NewUpdater(`rhel-3-updater`, 3, srv.URL, false, WithTelemetry(tr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Configure(ctx, func(_ interface{}) error { return nil }, srv.Client()); err != nil {
+		t.Fatal(err)
+	}
+	rc, _, err := u.Fetch(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.Parse(ctx, rc); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"rhel.updater.fetch", "rhel.updater.parse", "rhel.updater.parse.decode"}
+	if got := tr.names; len(got) != len(want) {
+		t.Fatalf("got: %v, want: %v", got, want)
+	}
+	for i := range want {
+		if got, want := tr.names[i], want[i]; got != want {
+			t.Errorf("span %d: got: %q, want: %q", i, got, want)
+		}
+	}
+}
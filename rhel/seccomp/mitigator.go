@@ -0,0 +1,141 @@
+package seccomp
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/quay/claircore"
+)
+
+// Syscalls maps CVE identifiers to the syscalls needed to exploit them.
+type Syscalls map[string][]string
+
+//go:embed syscalls.json
+var syscallsJSON []byte
+
+// DefaultSyscalls is the parsed bundled mapping. It's shared, so it must not
+// be modified.
+var defaultSyscalls = func() Syscalls {
+	m, err := ParseSyscalls(bytes.NewReader(syscallsJSON))
+	if err != nil {
+		panic(fmt.Sprintf("programmer error: bad bundled data: %v", err))
+	}
+	return m
+}()
+
+// DefaultSyscalls returns a copy of the mapping bundled with this package.
+//
+// The bundled mapping is a small, curated set of well-known kernel
+// vulnerabilities and is not exhaustive. Vulnerabilities not present in the
+// mapping are never reported as mitigated.
+func DefaultSyscalls() Syscalls {
+	m := make(Syscalls, len(defaultSyscalls))
+	for k, v := range defaultSyscalls {
+		m[k] = append([]string(nil), v...)
+	}
+	return m
+}
+
+// ParseSyscalls reads a JSON-encoded Syscalls mapping from the provided Reader.
+//
+// CVE identifiers are normalized to upper case.
+func ParseSyscalls(r io.Reader) (Syscalls, error) {
+	var in map[string][]string
+	if err := json.NewDecoder(r).Decode(&in); err != nil {
+		return nil, fmt.Errorf("seccomp: unable to decode syscall mapping: %w", err)
+	}
+	m := make(Syscalls, len(in))
+	for k, v := range in {
+		if len(v) == 0 {
+			return nil, fmt.Errorf("seccomp: %q: no syscalls listed", k)
+		}
+		m[strings.ToUpper(k)] = v
+	}
+	return m, nil
+}
+
+// Mitigator determines which vulnerabilities are mitigated by a Profile.
+type Mitigator struct {
+	Profile *Profile
+	// Syscalls is the mapping of CVEs to syscalls. If nil, the mapping
+	// returned by DefaultSyscalls is used.
+	Syscalls Syscalls
+}
+
+// UnmitigatedVulnerability is a vulnerability that the Profile does not
+// mitigate.
+type UnmitigatedVulnerability struct {
+	*claircore.Vulnerability
+	// Reachable is the sorted list of syscalls allowed by the Profile that
+	// the vulnerability can be exploited through. It's empty if there was no
+	// syscall information for the vulnerability.
+	Reachable []string
+}
+
+// FilterMitigated returns the vulnerabilities that are not mitigated by the
+// Profile.
+//
+// A vulnerability is mitigated if every CVE it references is known to need a
+// syscall and all of those syscalls are blocked by the Profile.
+// Vulnerabilities that reference no known CVEs are always returned.
+func (m *Mitigator) FilterMitigated(vs []*claircore.Vulnerability) []UnmitigatedVulnerability {
+	sc := m.Syscalls
+	if sc == nil {
+		sc = defaultSyscalls
+	}
+	out := make([]UnmitigatedVulnerability, 0, len(vs))
+	for _, v := range vs {
+		ids := cveIDs(v)
+		reach := make(map[string]struct{})
+		unknown := len(ids) == 0
+		for _, id := range ids {
+			calls, ok := sc[id]
+			if !ok {
+				unknown = true
+				continue
+			}
+			for _, c := range calls {
+				if m.Profile.Allowed(c) {
+					reach[c] = struct{}{}
+				}
+			}
+		}
+		if !unknown && len(reach) == 0 {
+			continue
+		}
+		u := UnmitigatedVulnerability{Vulnerability: v}
+		for c := range reach {
+			u.Reachable = append(u.Reachable, c)
+		}
+		sort.Strings(u.Reachable)
+		out = append(out, u)
+	}
+	return out
+}
+
+var cvePattern = regexp.MustCompile(`(?i)CVE-\d{4}-\d{4,}`)
+
+// CveIDs returns the deduplicated CVE identifiers mentioned in the
+// vulnerability's name or links. Red Hat advisories name the advisory, not the
+// CVE, so the links are needed.
+func cveIDs(v *claircore.Vulnerability) []string {
+	seen := make(map[string]struct{})
+	var ret []string
+	for _, s := range []string{v.Name, v.Links} {
+		for _, m := range cvePattern.FindAllString(s, -1) {
+			id := strings.ToUpper(m)
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			seen[id] = struct{}{}
+			ret = append(ret, id)
+		}
+	}
+	return ret
+}
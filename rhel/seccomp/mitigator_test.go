@@ -0,0 +1,74 @@
+package seccomp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/quay/claircore"
+)
+
+const testProfile = `{
+	"defaultAction": "SCMP_ACT_ALLOW",
+	"syscalls": [
+		{"names": ["keyctl", "ptrace"], "action": "SCMP_ACT_ERRNO"},
+		{"names": ["madvise"], "action": "SCMP_ACT_ERRNO", "args": [{"index": 2, "value": 4, "op": "SCMP_CMP_EQ"}]}
+	]
+}`
+
+func TestFilterMitigated(t *testing.T) {
+	p, err := ParseProfile(strings.NewReader(testProfile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs := []*claircore.Vulnerability{
+		{Name: "CVE-2016-0728"},
+		{Name: "RHSA-2019:2029: kernel security update (Important)", Links: "https://access.redhat.com/security/cve/CVE-2019-13272"},
+		{Name: "CVE-2016-5195"},
+		{Name: "RHSA-2022:0001", Links: "https://access.redhat.com/security/cve/CVE-2016-0728 https://access.redhat.com/security/cve/CVE-2022-0847"},
+		{Name: "CVE-2099-0001"},
+		{Name: "RHBA-2023:0001"},
+	}
+	m := Mitigator{Profile: p}
+	var got []string
+	for _, u := range m.FilterMitigated(vs) {
+		got = append(got, u.Name+"="+strings.Join(u.Reachable, ","))
+	}
+	want := []string{
+		// Args-filtered rules only block some calls.
+		"CVE-2016-5195=madvise",
+		"RHSA-2022:0001=splice",
+		// Unknown CVEs and no CVEs are never mitigated.
+		"CVE-2099-0001=",
+		"RHBA-2023:0001=",
+	}
+	if !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(got, want))
+	}
+}
+
+func TestDefaultSyscalls(t *testing.T) {
+	if len(DefaultSyscalls()) == 0 {
+		t.Error("empty bundled mapping")
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	p, err := ParseProfile(strings.NewReader(`{
+		"defaultAction": "SCMP_ACT_ERRNO",
+		"syscalls": [{"names": ["read", "write"], "action": "SCMP_ACT_ALLOW"}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{
+		"read":   true,
+		"write":  true,
+		"splice": false,
+	} {
+		if got := p.Allowed(name); got != want {
+			t.Errorf("%s: got: %v, want: %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Package seccomp cross-references vulnerabilities with seccomp(2) profiles to
+// determine which vulnerabilities a profile mitigates.
+//
+// Only the subset of the OCI runtime-spec seccomp profile format needed to
+// answer "is this syscall reachable" is implemented.
+package seccomp // import "github.com/quay/claircore/rhel/seccomp"
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Action is a seccomp action, like "SCMP_ACT_ALLOW".
+type Action string
+
+// These are the actions that seccomp profiles can specify.
+const (
+	ActAllow       Action = "SCMP_ACT_ALLOW"
+	ActLog         Action = "SCMP_ACT_LOG"
+	ActTrace       Action = "SCMP_ACT_TRACE"
+	ActNotify      Action = "SCMP_ACT_NOTIFY"
+	ActErrno       Action = "SCMP_ACT_ERRNO"
+	ActTrap        Action = "SCMP_ACT_TRAP"
+	ActKill        Action = "SCMP_ACT_KILL"
+	ActKillThread  Action = "SCMP_ACT_KILL_THREAD"
+	ActKillProcess Action = "SCMP_ACT_KILL_PROCESS"
+)
+
+// Blocks reports whether the action prevents the syscall from executing.
+//
+// Actions that hand the decision to another process (like "SCMP_ACT_TRACE")
+// are conservatively considered to not block the syscall.
+func (a Action) Blocks() bool {
+	switch a {
+	case ActErrno, ActTrap, ActKill, ActKillThread, ActKillProcess:
+		return true
+	}
+	return false
+}
+
+// Profile is a seccomp profile, as used by OCI runtimes.
+type Profile struct {
+	DefaultAction Action    `json:"defaultAction"`
+	Syscalls      []Syscall `json:"syscalls"`
+}
+
+// Syscall is a rule in a Profile.
+type Syscall struct {
+	Names  []string          `json:"names"`
+	Action Action            `json:"action"`
+	Args   []json.RawMessage `json:"args,omitempty"`
+}
+
+// ParseProfile reads a JSON-encoded Profile from the provided Reader.
+func ParseProfile(r io.Reader) (*Profile, error) {
+	var p Profile
+	if err := json.NewDecoder(r).Decode(&p); err != nil {
+		return nil, fmt.Errorf("seccomp: unable to decode profile: %w", err)
+	}
+	if p.DefaultAction == "" {
+		return nil, fmt.Errorf("seccomp: profile missing defaultAction")
+	}
+	return &p, nil
+}
+
+// Allowed reports whether the named syscall may be executed under the
+// Profile.
+//
+// Rules with argument filters only apply to some invocations of a syscall, so
+// a syscall is considered allowed if any rule could allow it.
+func (p *Profile) Allowed(name string) bool {
+	var seen, blocked bool
+	for _, s := range p.Syscalls {
+		for _, n := range s.Names {
+			if n != name {
+				continue
+			}
+			seen = true
+			switch {
+			case !s.Action.Blocks():
+				return true
+			case len(s.Args) == 0:
+				blocked = true
+			}
+		}
+	}
+	if seen && blocked {
+		return false
+	}
+	return !p.DefaultAction.Blocks()
+}
@@ -0,0 +1,144 @@
+package rhel
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/quay/goval-parser/oval"
+)
+
+// Severity is a qualitative severity rating, as used by WithMinSeverity.
+//
+// Severities are ordered, so they can be compared with the usual operators.
+type Severity uint8
+
+// These are the Severity ratings, from least to most severe. The score ranges
+// are the qualitative rating scale from the CVSS v3 and v4 specifications.
+const (
+	_ Severity = iota
+	// SeverityLow is a base score in the range [0.1, 4.0).
+	SeverityLow
+	// SeverityMedium is a base score in the range [4.0, 7.0).
+	SeverityMedium
+	// SeverityHigh is a base score in the range [7.0, 9.0).
+	SeverityHigh
+	// SeverityCritical is a base score of 9.0 or higher.
+	SeverityCritical
+)
+
+// String implements fmt.Stringer.
+func (s Severity) String() string {
+	switch s {
+	case SeverityLow:
+		return "Low"
+	case SeverityMedium:
+		return "Medium"
+	case SeverityHigh:
+		return "High"
+	case SeverityCritical:
+		return "Critical"
+	}
+	return fmt.Sprintf("Severity(%d)", uint8(s))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s Severity) MarshalText() ([]byte, error) {
+	if s < SeverityLow || s > SeverityCritical {
+		return nil, fmt.Errorf("rhel: invalid severity: %d", uint8(s))
+	}
+	return []byte(strings.ToLower(s.String())), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+//
+// The names of the Severity ratings are accepted in any case, as are Red
+// Hat's "Moderate" and "Important" ratings. An empty string is the zero
+// Severity.
+func (s *Severity) UnmarshalText(b []byte) error {
+	switch strings.ToLower(string(b)) {
+	case "":
+		*s = 0
+	case "low":
+		*s = SeverityLow
+	case "medium", "moderate":
+		*s = SeverityMedium
+	case "high", "important":
+		*s = SeverityHigh
+	case "critical":
+		*s = SeverityCritical
+	default:
+		return fmt.Errorf("rhel: unknown severity %q", string(b))
+	}
+	return nil
+}
+
+// WithMinSeverity configures Parse to skip any definition less severe than
+// "s". Skipped definitions are dropped before any vulnerabilities are created
+// for them.
+//
+// A definition's severity is computed from the highest base score of the
+// most recent CVSS version present in the definition. Definitions without
+// any CVSS scores use the advisory's severity, with Red Hat's "Moderate" and
+// "Important" ratings treated as SeverityMedium and SeverityHigh. Definitions
+// with neither are always kept.
+func WithMinSeverity(s Severity) Option {
+	return func(u *Updater) error {
+		if s < SeverityLow || s > SeverityCritical {
+			return fmt.Errorf("rhel: invalid minimum severity: %v", s)
+		}
+		u.minSeverity = s
+		return nil
+	}
+}
+
+// WithCVSSSeverity configures ParseCSAF to report the highest CVSS score and
+// vector in the document as the Severity, in the "score/vector" form used by
+// the OVAL feeds (see [CVSSVector]), instead of the aggregate severity. Newer
+// CVSS versions are preferred, and documents without scores still use the
+// aggregate severity.
+//
+// This is for consumers that relied on the CVSS vector being in the Severity;
+// the vectors are always available from [CVSSVectors], and from the
+// [github.com/quay/claircore/enricher/rhelcvss] enricher.
+func WithCVSSSeverity() Option {
+	return func(u *Updater) error {
+		u.cvssSeverity = true
+		return nil
+	}
+}
+
+// ScoreSeverity returns the Severity for a CVSS base score. A score of zero
+// has no Severity.
+func scoreSeverity(score float64) Severity {
+	switch {
+	case score <= 0:
+		return 0
+	case score < 4:
+		return SeverityLow
+	case score < 7:
+		return SeverityMedium
+	case score < 9:
+		return SeverityHigh
+	}
+	return SeverityCritical
+}
+
+// DefSeverity returns the Severity of the definition "def" with the CVSS
+// vectors "scores". A Severity of zero means the severity could not be
+// determined.
+func defSeverity(def *oval.Definition, scores *cvssScores) Severity {
+	if v, ok := scores.Preferred(); ok {
+		return scoreSeverity(v.Score)
+	}
+	switch strings.ToLower(def.Advisory.Severity) {
+	case "low":
+		return SeverityLow
+	case "moderate":
+		return SeverityMedium
+	case "important":
+		return SeverityHigh
+	case "critical":
+		return SeverityCritical
+	}
+	return 0
+}
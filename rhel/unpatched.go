@@ -0,0 +1,79 @@
+package rhel
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/quay/claircore"
+)
+
+// WithUnpatchedStates configures the Updater to only keep unpatched
+// vulnerabilities, the ones without a fixed-in version, in one of the listed
+// states. By default, every unpatched vulnerability is kept unless the Updater
+// was constructed to ignore unpatched advisories, which takes precedence.
+//
+// Red Hat's "Affected" and "Fix deferred" states are reported as
+// [claircore.FixStateAffected], and "Will not fix" and "Out of support scope"
+// as [claircore.FixStateWillNotFix]. Vulnerabilities kept this way have an
+// empty FixedInVersion, so they match every version of the package; the
+// FixState lets consumers decide how to present them.
+//
+// [claircore.FixStateFixed] isn't an unpatched state and is rejected.
+func WithUnpatchedStates(states ...claircore.FixState) Option {
+	return func(u *Updater) error {
+		m, err := unpatchedSet(states)
+		if err != nil {
+			return err
+		}
+		u.unpatched = m
+		return nil
+	}
+}
+
+// UnpatchedSet validates "states" and returns them as a set.
+func unpatchedSet(states []claircore.FixState) (map[claircore.FixState]struct{}, error) {
+	if len(states) == 0 {
+		return nil, errors.New("rhel: no unpatched states")
+	}
+	m := make(map[claircore.FixState]struct{}, len(states))
+	for _, s := range states {
+		switch s {
+		case claircore.FixStateAffected, claircore.FixStateWillNotFix, claircore.FixStateUnderInvestigation:
+		default:
+			return nil, fmt.Errorf("rhel: invalid unpatched state: %q", s)
+		}
+		m[s] = struct{}{}
+	}
+	return m, nil
+}
+
+// KeepUnpatched reports whether an unpatched vulnerability in state "s"
+// should be kept.
+func (u *Updater) keepUnpatched(s claircore.FixState) bool {
+	if u.ignoreUnpatched {
+		return false
+	}
+	if u.unpatched == nil {
+		return true
+	}
+	_, ok := u.unpatched[s]
+	return ok
+}
+
+// UnpatchedConfig describes the Updater's handling of unpatched
+// vulnerabilities, for use in checksums of its results.
+func (u *Updater) unpatchedConfig() string {
+	c := strconv.FormatBool(u.ignoreUnpatched)
+	if u.unpatched == nil {
+		return c
+	}
+	ss := make([]string, 0, len(u.unpatched))
+	for s := range u.unpatched {
+		ss = append(ss, string(s))
+	}
+	sort.Strings(ss)
+	return c + "/" + strings.Join(ss, ",")
+}
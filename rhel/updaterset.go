@@ -11,6 +11,7 @@ import (
 
 	"github.com/quay/zlog"
 
+	"github.com/quay/claircore"
 	"github.com/quay/claircore/libvuln/driver"
 	"github.com/quay/claircore/rhel/internal/pulp"
 )
@@ -39,6 +40,10 @@ type Factory struct {
 	client          *http.Client
 	manifestEtag    string
 	ignoreUnpatched bool
+	// Opts are passed to every Updater.
+	opts []Option
+	// CSAF, if set, is the CSAF directory to use instead of the manifest.
+	csaf *CSAFUpdater
 }
 
 // FactoryConfig is the configuration accepted by the rhel updaters.
@@ -49,6 +54,23 @@ type FactoryConfig struct {
 	// IgnoreUnpatched dictates whether to ingest unpatched advisory data
 	// from the RHEL security feeds.
 	IgnoreUnpatched bool `json:"ignore_unpatched" yaml:"ignore_unpatched"`
+	// CSAF configures the Factory to return a single CSAFUpdater instead of
+	// an Updater for every OVAL database in the manifest.
+	CSAF bool `json:"csaf" yaml:"csaf"`
+	// CSAFURL is the CSAF directory used if CSAF is set. If unset,
+	// DefaultCSAFAdvisories is used.
+	CSAFURL string `json:"csaf_url" yaml:"csaf_url"`
+	// CSAFStore, if set, is the path of a file used to keep the
+	// CSAFUpdater's state between processes. See OpenFileIncrementalStore.
+	// Otherwise, the state is kept in memory and every document is fetched
+	// again after a restart.
+	CSAFStore string `json:"csaf_store" yaml:"csaf_store"`
+	// CVSSSeverity configures the CSAFUpdater to report CVSS vectors as the
+	// Severity. See WithCVSSSeverity.
+	CVSSSeverity bool `json:"cvss_severity" yaml:"cvss_severity"`
+	// UnpatchedStates, if set, is the states of unpatched vulnerabilities
+	// the updaters keep. See WithUnpatchedStates.
+	UnpatchedStates []claircore.FixState `json:"unpatched_states" yaml:"unpatched_states"`
 }
 
 var _ driver.Configurable = (*Factory)(nil)
@@ -80,6 +102,36 @@ func (f *Factory) Configure(ctx context.Context, cfg driver.ConfigUnmarshaler, c
 		f.client = c
 	}
 	f.ignoreUnpatched = fc.IgnoreUnpatched
+	f.opts = nil
+	if len(fc.UnpatchedStates) != 0 {
+		if _, err := unpatchedSet(fc.UnpatchedStates); err != nil {
+			return err
+		}
+		f.opts = append(f.opts, WithUnpatchedStates(fc.UnpatchedStates...))
+	}
+	f.csaf = nil
+	if fc.CSAF {
+		opts := append([]Option(nil), f.opts...)
+		if fc.CVSSSeverity {
+			opts = append(opts, WithCVSSSeverity())
+		}
+		if fc.CSAFStore != "" {
+			st, err := OpenFileIncrementalStore(fc.CSAFStore)
+			if err != nil {
+				return err
+			}
+			opts = append(opts, WithIncrementalStore(st))
+		}
+		u, err := NewCSAFUpdater("rhel-csaf", fc.CSAFURL, fc.IgnoreUnpatched, opts...)
+		if err != nil {
+			return err
+		}
+		u.client = f.client
+		zlog.Info(ctx).
+			Stringer("url", u.dir).
+			Msg("configured CSAF updater")
+		f.csaf = u
+	}
 	return nil
 }
 
@@ -87,8 +139,17 @@ func (f *Factory) Configure(ctx context.Context, cfg driver.ConfigUnmarshaler, c
 //
 // The returned Updaters determine the [claircore.Distribution] it's associated
 // with based on the path in the Pulp manifest.
+//
+// If the Factory was configured to use CSAF, the manifest isn't fetched and
+// the set only contains the CSAFUpdater, which keeps its state between calls.
 func (f *Factory) UpdaterSet(ctx context.Context) (driver.UpdaterSet, error) {
 	s := driver.NewUpdaterSet()
+	if f.csaf != nil {
+		if err := s.Add(f.csaf); err != nil {
+			return s, err
+		}
+		return s, nil
+	}
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.url.String(), nil)
 	if err != nil {
@@ -152,7 +213,7 @@ func (f *Factory) UpdaterSet(ctx context.Context) (driver.UpdaterSet, error) {
 				Msg("unable to parse pattern into int")
 			continue
 		}
-		up, err := NewUpdater(name, r, uri.String(), f.ignoreUnpatched)
+		up, err := NewUpdater(name, r, uri.String(), f.ignoreUnpatched, f.opts...)
 		if err != nil {
 			return s, err
 		}
@@ -0,0 +1,178 @@
+package rhel
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+
+	"github.com/quay/goval-parser/oval"
+	"github.com/quay/zlog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/pkg/errs"
+)
+
+// WithWorkers configures the number of goroutines Parse uses to decode and
+// convert OVAL definitions. The default is [runtime.NumCPU].
+//
+// With more than one worker, the whole document is read into memory and
+// split into its "definition" elements, each of which is then decoded and
+// converted by a worker. The returned vulnerabilities are in document order
+// regardless of the number of workers. With one worker, the document is
+// decoded as a stream.
+func WithWorkers(n int) Option {
+	return func(u *Updater) error {
+		if n < 1 {
+			return fmt.Errorf("rhel: invalid number of workers: %d", n)
+		}
+		u.workers = n
+		return nil
+	}
+}
+
+// DefaultWorkers is the number of workers used if not configured with
+// WithWorkers.
+var defaultWorkers = runtime.NumCPU()
+
+// NumWorkers reports the number of workers to use.
+func (u *Updater) numWorkers() int {
+	if u.workers == 0 {
+		return defaultWorkers
+	}
+	return u.workers
+}
+
+// ParseParallel is the Parse implementation when multiple workers are
+// configured.
+func (u *Updater) parseParallel(ctx context.Context, r io.Reader) ([]*claircore.Vulnerability, error) {
+	span := trace.SpanFromContext(ctx)
+	b, err := io.ReadAll(r)
+	if err != nil {
+		span.SetStatus(codes.Error, "read error")
+		return nil, fmt.Errorf("rhel: unable to read OVAL document: %w", err)
+	}
+	defs, _ := scanDefinitions(b)
+	if len(defs) == 0 {
+		// Not laid out as expected, so use the sequential path.
+		root, scores, err := u.decode(ctx, bytes.NewReader(b))
+		if err != nil {
+			span.SetStatus(codes.Error, "decode error")
+			return nil, err
+		}
+		span.SetAttributes(attribute.Int("definitions", len(root.Definitions.Definitions)))
+		return u.convert(ctx, root, scores)
+	}
+	span.SetAttributes(attribute.Int("definitions", len(defs)))
+	res, err := u.parseSpans(ctx, b, defs, defs)
+	if err != nil {
+		span.SetStatus(codes.Error, "decode error")
+		return nil, err
+	}
+	var n int
+	for _, vs := range res {
+		n += len(vs)
+	}
+	out := make([]*claircore.Vulnerability, 0, n)
+	for _, vs := range res {
+		out = append(out, vs...)
+	}
+	span.SetAttributes(attribute.Int("vulnerabilities", len(out)))
+	span.SetStatus(codes.Ok, "")
+	return out, nil
+}
+
+// ParseSpans decodes and converts the definitions in "todo", which must be a
+// subset of "all", using the Updater's configured number of workers. Results
+// are returned in the same order as "todo".
+//
+// The rest of the document (everything but the definitions in "all") is
+// decoded first, so that the workers can share the tests, objects, and states.
+func (u *Updater) parseSpans(ctx context.Context, b []byte, all, todo []defSpan) ([][]*claircore.Vulnerability, error) {
+	skel := make([]byte, 0, len(b)-(all[len(all)-1].End-all[0].Start))
+	prev := 0
+	for _, d := range all {
+		skel = append(skel, b[prev:d.Start]...)
+		prev = d.End
+	}
+	skel = append(skel, b[prev:]...)
+	root, _, err := u.decode(ctx, bytes.NewReader(skel))
+	if err != nil {
+		return nil, err
+	}
+
+	n := u.numWorkers()
+	if n > len(todo) {
+		n = len(todo)
+	}
+	_, wSpan := u.getTracer().Start(ctx, "rhel.updater.parse.workers",
+		trace.WithAttributes(attribute.Int("workers", n)))
+	defer wSpan.End()
+	res := make([][]*claircore.Vulnerability, len(todo))
+	idx := make(chan int)
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.Go(func() error {
+		defer close(idx)
+		for i := range todo {
+			select {
+			case idx <- i:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+	for w := 0; w < n; w++ {
+		eg.Go(func() error {
+			for i := range idx {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				d := todo[i]
+				def, scores, err := decodeDefinition(b[d.Start:d.End])
+				if err != nil {
+					return fmt.Errorf("rhel: unable to decode OVAL definition: %w", &errs.ParseError{ID: d.ID, Offset: int64(d.Start), Err: err})
+				}
+				vs, err := u.defVulns(ctx, root, def, scores)
+				if err != nil {
+					zlog.Debug(ctx).
+						Err(err).
+						Str("def_id", def.ID).
+						Msg("could not create prototype vulnerabilities")
+					res[i] = []*claircore.Vulnerability{}
+					continue
+				}
+				res[i] = vs
+			}
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		wSpan.RecordError(err)
+		wSpan.SetStatus(codes.Error, "worker error")
+		return nil, err
+	}
+	wSpan.SetStatus(codes.Ok, "")
+	return res, nil
+}
+
+// DecodeDefinition decodes a single "definition" element, along with any
+// scores in "base_metrics" elements inside it.
+func decodeDefinition(b []byte) (*oval.Definition, *cvssScores, error) {
+	var def oval.Definition
+	metrics := metricsScanner{d: xml.NewDecoder(bytes.NewReader(b))}
+	if err := xml.NewTokenDecoder(&metrics).Decode(&def); err != nil {
+		return nil, nil, err
+	}
+	if def.ID == "" {
+		return nil, nil, errors.New("missing id")
+	}
+	return &def, metrics.Scores[def.ID], nil
+}
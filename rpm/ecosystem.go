@@ -14,6 +14,7 @@ import (
 // NewEcosystem provides the set of scanners and coalescers for the rpm ecosystem
 func NewEcosystem(ctx context.Context) *indexer.Ecosystem {
 	return &indexer.Ecosystem{
+		Name: "rpm",
 		PackageScanners: func(ctx context.Context) ([]indexer.PackageScanner, error) {
 			return []indexer.PackageScanner{&Scanner{}}, nil
 		},
@@ -75,6 +75,7 @@ func packagesFromDB(ctx context.Context, pkgdb string, db nativeDB) ([]*claircor
 			modStream = info.Module[:idx]
 		}
 		p.Module = modStream
+		p.Files = info.Filenames()
 		p.Version = constructEVR(&b, &info)
 		p.RepositoryHint = constructHint(&b, &info)
 
@@ -123,6 +124,37 @@ type Info struct {
 	Signature  []byte // This is a PGP signature packet.
 	DigestAlgo int
 	Epoch      int
+	// The file list is stored as an index into the directory names for each
+	// base name.
+	Dirnames   []string
+	Basenames  []string
+	Dirindexes []int32
+	FileModes  []int16
+}
+
+// Filenames returns the paths of the regular files, symlinks, and other
+// non-directory entries owned by the package, relative to the root.
+func (i *Info) Filenames() []string {
+	if len(i.Basenames) == 0 || len(i.Dirindexes) != len(i.Basenames) {
+		return nil
+	}
+	const (
+		typeMask = 0o170000
+		typeDir  = 0o040000
+	)
+	checkMode := len(i.FileModes) == len(i.Basenames)
+	out := make([]string, 0, len(i.Basenames))
+	for n, base := range i.Basenames {
+		if checkMode && uint16(i.FileModes[n])&typeMask == typeDir {
+			continue
+		}
+		idx := int(i.Dirindexes[n])
+		if idx < 0 || idx >= len(i.Dirnames) {
+			continue
+		}
+		out = append(out, strings.TrimPrefix(i.Dirnames[idx]+base, "/"))
+	}
+	return out
 }
 
 func (i *Info) Load(ctx context.Context, h *rpm.Header) error {
@@ -156,6 +188,14 @@ func (i *Info) Load(ctx context.Context, h *rpm.Header) error {
 			i.Digest = v.([]string)[0]
 		case rpm.TagSigPGP:
 			i.Signature = v.([]byte)
+		case rpm.TagDirnames:
+			i.Dirnames = v.([]string)
+		case rpm.TagBasenames:
+			i.Basenames = v.([]string)
+		case rpm.TagDirindexes:
+			i.Dirindexes = v.([]int32)
+		case rpm.TagFileModes:
+			i.FileModes = v.([]int16)
 		}
 	}
 	return nil
@@ -163,7 +203,11 @@ func (i *Info) Load(ctx context.Context, h *rpm.Header) error {
 
 var wantTags = map[rpm.Tag]struct{}{
 	rpm.TagArch:              {},
+	rpm.TagBasenames:         {},
+	rpm.TagDirindexes:        {},
+	rpm.TagDirnames:          {},
 	rpm.TagEpoch:             {},
+	rpm.TagFileModes:         {},
 	rpm.TagModularityLabel:   {},
 	rpm.TagName:              {},
 	rpm.TagPayloadDigest:     {},
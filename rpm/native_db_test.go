@@ -0,0 +1,28 @@
+package rpm
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestInfoFilenames(t *testing.T) {
+	// Modes are stored as signed 16-bit integers.
+	mode := func(m uint16) int16 { return int16(m) }
+	info := Info{
+		Dirnames:   []string{"/usr/bin/", "/usr/share/doc/", "/usr/share/doc/hello/"},
+		Basenames:  []string{"hello", "hello", "README"},
+		Dirindexes: []int32{0, 1, 2},
+		FileModes:  []int16{mode(0o100755), mode(0o40755), mode(0o100644)},
+	}
+	want := []string{"usr/bin/hello", "usr/share/doc/hello/README"}
+	if got := info.Filenames(); !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(got, want))
+	}
+
+	// Mismatched arrays are ignored.
+	info.Dirindexes = info.Dirindexes[:1]
+	if got := info.Filenames(); got != nil {
+		t.Errorf("got %q, want nil", got)
+	}
+}
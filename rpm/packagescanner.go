@@ -21,7 +21,7 @@ import (
 const (
 	pkgName    = "rpm"
 	pkgKind    = "package"
-	pkgVersion = "10"
+	pkgVersion = "11"
 )
 
 var (
@@ -32,7 +32,8 @@ var (
 // Scanner implements the scanner.PackageScanner interface.
 //
 // This looks for directories that look like rpm databases and examines the
-// files it finds there.
+// files it finds there. The object store and deployments of an OSTree
+// filesystem aren't examined; see [Packages].
 //
 // The zero value is ready to use.
 type Scanner struct{}
@@ -67,9 +68,21 @@ func (ps *Scanner) Scan(ctx context.Context, layer *claircore.Layer) ([]*clairco
 	if err != nil {
 		return nil, fmt.Errorf("rpm: unable to open layer: %w", err)
 	}
+	return Packages(ctx, sys, ".")
+}
 
+// Packages finds rpm databases at or below "root" in "sys" and enumerates the
+// packages there, in the same way as [Scanner.Scan].
+//
+// OSTree keeps its object store and every deployment's files in the "ostree"
+// directory, which is also mounted at "sysroot/ostree" on a running system.
+// These aren't walked unless they're the "root", so callers that know which
+// deployment is relevant can use this to examine only that one.
+//
+// A return of (nil, nil) is expected if there's no rpm database.
+func Packages(ctx context.Context, sys fs.FS, root string) ([]*claircore.Package, error) {
 	found := make([]foundDB, 0)
-	if err := fs.WalkDir(sys, ".", findDBs(ctx, &found, sys)); err != nil {
+	if err := fs.WalkDir(sys, root, findDBs(ctx, &found, sys)); err != nil {
 		return nil, fmt.Errorf("rpm: error walking fs: %w", err)
 	}
 	if len(found) == 0 {
@@ -177,6 +190,10 @@ func findDBs(ctx context.Context, out *[]foundDB, sys fs.FS) fs.WalkDirFunc {
 			return err
 		}
 		if d.IsDir() {
+			switch p {
+			case `ostree`, `sysroot/ostree`:
+				return fs.SkipDir
+			}
 			return nil
 		}
 
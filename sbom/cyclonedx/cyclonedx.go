@@ -0,0 +1,574 @@
+// Package cyclonedx converts IndexReports and VulnerabilityReports to
+// CycloneDX 1.5 BOMs.
+//
+// The BOM's metadata component is the indexed manifest, which depends on a
+// component for every distribution and package in the IndexReport. If a
+// VulnerabilityReport is provided, its vulnerabilities are embedded in the
+// BOM with a VEX analysis for the affected components, so the BOM can be
+// consumed directly by tools like Dependency-Track. The specification is at
+// https://cyclonedx.org/docs/1.5/json/.
+package cyclonedx
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/sbom"
+)
+
+// Version is the CycloneDX specification version produced.
+const Version = `1.5`
+
+// These are the component types used in BOMs.
+const (
+	TypeContainer       = `container`
+	TypeOperatingSystem = `operating-system`
+	TypeLibrary         = `library`
+	TypeApplication     = `application`
+)
+
+// These are the VEX analysis states used in BOMs.
+const (
+	StateExploitable = `exploitable`
+	StateInTriage    = `in_triage`
+)
+
+// These are the VEX analysis responses used in BOMs.
+const (
+	ResponseUpdate     = `update`
+	ResponseWillNotFix = `will_not_fix`
+)
+
+// PropertyPrefix is the namespace of the claircore-specific properties
+// attached to components.
+const PropertyPrefix = `claircore:`
+
+// BOM is a CycloneDX BOM.
+//
+// These types are the subset of the CycloneDX object model the Encoder
+// produces. Field names follow the specification's JSON serialization.
+type BOM struct {
+	BOMFormat       string          `json:"bomFormat"`
+	SpecVersion     string          `json:"specVersion"`
+	SerialNumber    string          `json:"serialNumber"`
+	Version         int             `json:"version"`
+	Metadata        Metadata        `json:"metadata"`
+	Components      []Component     `json:"components"`
+	Dependencies    []Dependency    `json:"dependencies"`
+	Vulnerabilities []Vulnerability `json:"vulnerabilities,omitempty"`
+}
+
+// Metadata describes the BOM and what it's about.
+type Metadata struct {
+	Timestamp string     `json:"timestamp"`
+	Tools     *Tools     `json:"tools,omitempty"`
+	Component *Component `json:"component,omitempty"`
+}
+
+// Tools are the tools that produced the BOM.
+type Tools struct {
+	Components []Component `json:"components"`
+}
+
+// UnmarshalJSON implements [json.Unmarshaler].
+//
+// It also accepts the array of tools used before CycloneDX 1.5, converting
+// each tool to an "application" component.
+func (t *Tools) UnmarshalJSON(b []byte) error {
+	if len(b) == 0 || b[0] != '[' {
+		type tools Tools
+		return json.Unmarshal(b, (*tools)(t))
+	}
+	var legacy []struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(b, &legacy); err != nil {
+		return err
+	}
+	t.Components = make([]Component, len(legacy))
+	for i, l := range legacy {
+		t.Components[i] = Component{
+			Type:    TypeApplication,
+			Name:    l.Name,
+			Version: l.Version,
+		}
+	}
+	return nil
+}
+
+// Component is a CycloneDX component.
+type Component struct {
+	BOMRef     string     `json:"bom-ref,omitempty"`
+	Type       string     `json:"type"`
+	Group      string     `json:"group,omitempty"`
+	Name       string     `json:"name"`
+	Version    string     `json:"version,omitempty"`
+	PURL       string     `json:"purl,omitempty"`
+	CPE        string     `json:"cpe,omitempty"`
+	Hashes     []Hash     `json:"hashes,omitempty"`
+	Pedigree   *Pedigree  `json:"pedigree,omitempty"`
+	Evidence   *Evidence  `json:"evidence,omitempty"`
+	Properties []Property `json:"properties,omitempty"`
+	// Components are the subcomponents of the component. The Encoder
+	// doesn't produce these, but they're searched when decoding.
+	Components []Component `json:"components,omitempty"`
+}
+
+// Hash is a digest of a Component.
+type Hash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+// Pedigree records the components a Component was derived from.
+type Pedigree struct {
+	Ancestors []Component `json:"ancestors,omitempty"`
+}
+
+// Evidence records where a Component was found.
+type Evidence struct {
+	Occurrences []Occurrence `json:"occurrences,omitempty"`
+}
+
+// Occurrence is a location a Component was found at.
+type Occurrence struct {
+	Location string `json:"location"`
+}
+
+// Property is a name-value pair.
+type Property struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Dependency lists the components a component depends on.
+type Dependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+// Vulnerability is a CycloneDX vulnerability.
+type Vulnerability struct {
+	BOMRef         string     `json:"bom-ref,omitempty"`
+	ID             string     `json:"id"`
+	Source         *Source    `json:"source,omitempty"`
+	Ratings        []Rating   `json:"ratings,omitempty"`
+	Description    string     `json:"description,omitempty"`
+	Recommendation string     `json:"recommendation,omitempty"`
+	Advisories     []Advisory `json:"advisories,omitempty"`
+	Published      string     `json:"published,omitempty"`
+	Analysis       *Analysis  `json:"analysis,omitempty"`
+	Affects        []Affect   `json:"affects"`
+	Properties     []Property `json:"properties,omitempty"`
+}
+
+// Source is the database a Vulnerability came from.
+type Source struct {
+	Name string `json:"name,omitempty"`
+	URL  string `json:"url,omitempty"`
+}
+
+// Rating is a severity rating of a Vulnerability.
+type Rating struct {
+	Source   *Source  `json:"source,omitempty"`
+	Score    *float64 `json:"score,omitempty"`
+	Severity string   `json:"severity,omitempty"`
+	Method   string   `json:"method,omitempty"`
+	Vector   string   `json:"vector,omitempty"`
+}
+
+// Advisory is a link with more information about a Vulnerability.
+type Advisory struct {
+	URL string `json:"url"`
+}
+
+// Analysis is the VEX assessment of a Vulnerability.
+type Analysis struct {
+	State    string   `json:"state"`
+	Response []string `json:"response,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+// Affect is a component affected by a Vulnerability.
+type Affect struct {
+	Ref      string          `json:"ref"`
+	Versions []AffectVersion `json:"versions,omitempty"`
+}
+
+// AffectVersion is a version of an affected component.
+type AffectVersion struct {
+	Version string `json:"version"`
+	Status  string `json:"status"`
+}
+
+// Encoder converts IndexReports and VulnerabilityReports to CycloneDX BOMs.
+//
+// The zero value is ready to use.
+type Encoder struct {
+	// Name is the name of the BOM's metadata component. The manifest digest
+	// is used if not set.
+	Name string
+	// SerialNumber is the BOM's serial number, which must be unique for every
+	// BOM. A random UUID URN is used if not set.
+	SerialNumber string
+	// ToolVersion is reported as the version of the "claircore" tool, if set.
+	ToolVersion string
+	// Created is reported as the BOM's timestamp. The current time is used if
+	// not set.
+	Created time.Time
+}
+
+// Encode writes the CycloneDX BOM for "ir" and, if not nil, "vr" to "w" as
+// JSON.
+func (e *Encoder) Encode(w io.Writer, ir *claircore.IndexReport, vr *claircore.VulnerabilityReport) error {
+	b, err := e.BOM(ir, vr)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(b); err != nil {
+		return fmt.Errorf("cyclonedx: unable to write bom: %w", err)
+	}
+	return nil
+}
+
+// BOM returns the CycloneDX BOM for "ir".
+//
+// If "vr" is not nil, its vulnerabilities are added to the BOM, affecting the
+// components for the packages they were found in. It must be the report for
+// the same manifest as "ir".
+//
+// Components, dependencies, and vulnerabilities are sorted, so the output is
+// stable for a given Encoder configuration.
+func (e *Encoder) BOM(ir *claircore.IndexReport, vr *claircore.VulnerabilityReport) (*BOM, error) {
+	hash := ir.Hash.String()
+	if hash == "" {
+		return nil, fmt.Errorf("cyclonedx: index report has no manifest digest")
+	}
+	if vr != nil && vr.Hash.String() != hash {
+		return nil, fmt.Errorf("cyclonedx: vulnerability report is for %q, not %q", vr.Hash.String(), hash)
+	}
+	name := e.Name
+	if name == "" {
+		name = hash
+	}
+	serial := e.SerialNumber
+	if serial == "" {
+		serial = uuid.New().URN()
+	}
+	created := e.Created
+	if created.IsZero() {
+		created = time.Now()
+	}
+
+	root := Component{
+		BOMRef: "manifest:" + hash,
+		Type:   TypeContainer,
+		Name:   name,
+	}
+	if algo := ir.Hash.Algorithm(); algo != "" {
+		root.Hashes = []Hash{{
+			Alg:     hashAlg(algo),
+			Content: fmt.Sprintf("%x", ir.Hash.Checksum()),
+		}}
+	}
+	bom := &BOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  Version,
+		SerialNumber: serial,
+		Version:      1,
+		Metadata: Metadata{
+			Timestamp: created.UTC().Format(time.RFC3339),
+			Tools: &Tools{Components: []Component{{
+				Type:    TypeApplication,
+				Name:    "claircore",
+				Version: e.ToolVersion,
+			}}},
+			Component: &root,
+		},
+		Components:   []Component{},
+		Dependencies: []Dependency{},
+	}
+
+	rootDep := Dependency{Ref: root.BOMRef}
+	for _, id := range sortedKeys(ir.Distributions) {
+		dist := ir.Distributions[id]
+		if dist == nil {
+			continue
+		}
+		c := Component{
+			BOMRef:  distributionRef(id),
+			Type:    TypeOperatingSystem,
+			Name:    dist.DID,
+			Version: dist.VersionID,
+		}
+		if c.Name == "" {
+			c.Name = dist.Name
+		}
+		if dist.CPE.Valid() == nil {
+			c.CPE = dist.CPE.String()
+		}
+		bom.Components = append(bom.Components, c)
+		rootDep.DependsOn = append(rootDep.DependsOn, c.BOMRef)
+	}
+	refs := make(map[string]*Component)
+	for _, id := range sortedKeys(ir.Packages) {
+		pkg := ir.Packages[id]
+		if pkg == nil {
+			continue
+		}
+		ctx := sbom.ContextFor(ir, id)
+		c := component(ctx, pkg)
+		c.BOMRef = packageRef(id)
+		c.Properties = properties(ctx, pkg)
+		if pkg.Filepath != "" {
+			c.Evidence = &Evidence{Occurrences: []Occurrence{{Location: pkg.Filepath}}}
+		}
+		// Source packages aren't dependencies of the binary packages built
+		// from them, so they're recorded as ancestors instead.
+		if src := pkg.Source; src != nil && src.Name != "" {
+			c.Pedigree = &Pedigree{Ancestors: []Component{component(ctx, src)}}
+		}
+		bom.Components = append(bom.Components, c)
+		rootDep.DependsOn = append(rootDep.DependsOn, c.BOMRef)
+	}
+	for i := range bom.Components {
+		refs[bom.Components[i].BOMRef] = &bom.Components[i]
+	}
+	sort.Strings(rootDep.DependsOn)
+	bom.Dependencies = append(bom.Dependencies, rootDep)
+
+	if vr != nil {
+		bom.Vulnerabilities = vulnerabilities(vr, refs)
+	}
+	return bom, nil
+}
+
+// Component returns the Component for the package "p", found in the Context
+// "c", without a bom-ref.
+func component(c *sbom.Context, p *claircore.Package) Component {
+	out := Component{
+		Type:    TypeLibrary,
+		Name:    p.Name,
+		Version: p.Version,
+	}
+	if u := sbom.PURL(c, p); u != nil {
+		out.PURL = u.String()
+	}
+	if p.CPE.Valid() == nil {
+		out.CPE = p.CPE.String()
+	}
+	return out
+}
+
+// Properties returns the claircore-specific properties for the package "p",
+// found in the Context "c".
+func properties(c *sbom.Context, p *claircore.Package) []Property {
+	var ps []Property
+	add := func(k, v string) {
+		if v != "" {
+			ps = append(ps, Property{Name: PropertyPrefix + k, Value: v})
+		}
+	}
+	dbs := c.PackageDBs
+	if len(dbs) == 0 {
+		dbs = []string{p.PackageDB}
+	}
+	for _, db := range dbs {
+		add("package_db", db)
+	}
+	for _, repo := range c.Repositories {
+		add("repository", repo.Name)
+	}
+	for _, l := range c.Layers {
+		add("introduced_in", l.String())
+	}
+	add("module", p.Module)
+	add("arch", p.Arch)
+	return ps
+}
+
+// Vulnerabilities returns a Vulnerability for every vulnerability in "vr"
+// that affects a component in "refs".
+func vulnerabilities(vr *claircore.VulnerabilityReport, refs map[string]*Component) []Vulnerability {
+	affects := make(map[string][]Affect)
+	for pkgID, ids := range vr.PackageVulnerabilities {
+		ref := packageRef(pkgID)
+		c, ok := refs[ref]
+		if !ok {
+			continue
+		}
+		for _, id := range ids {
+			a := Affect{Ref: ref}
+			if c.Version != "" {
+				a.Versions = []AffectVersion{{Version: c.Version, Status: "affected"}}
+			}
+			affects[id] = append(affects[id], a)
+		}
+	}
+	var out []Vulnerability
+	for _, id := range sortedKeys(affects) {
+		v, ok := vr.Vulnerabilities[id]
+		if !ok || v == nil {
+			continue
+		}
+		as := affects[id]
+		sort.Slice(as, func(i, j int) bool { return as[i].Ref < as[j].Ref })
+		out = append(out, vulnerability(v, as))
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].ID != out[j].ID {
+			return out[i].ID < out[j].ID
+		}
+		return out[i].BOMRef < out[j].BOMRef
+	})
+	return out
+}
+
+// Vulnerability returns the Vulnerability for "v", affecting "as".
+func vulnerability(v *claircore.Vulnerability, as []Affect) Vulnerability {
+	out := Vulnerability{
+		BOMRef:      "vulnerability:" + v.ID,
+		ID:          v.Name,
+		Description: v.Description,
+		Analysis:    analysis(v),
+		Affects:     as,
+	}
+	links := strings.Fields(v.Links)
+	if v.Updater != "" || len(links) != 0 {
+		out.Source = &Source{Name: v.Updater}
+		if len(links) != 0 {
+			out.Source.URL = links[0]
+		}
+	}
+	for _, l := range links {
+		out.Advisories = append(out.Advisories, Advisory{URL: l})
+	}
+	if v.FixedInVersion != "" {
+		out.Recommendation = "Upgrade to version " + v.FixedInVersion + " or later."
+	}
+	if !v.Issued.IsZero() {
+		out.Published = v.Issued.UTC().Format(time.RFC3339)
+	}
+	out.Ratings = ratings(v)
+	if v.Severity != "" {
+		out.Properties = append(out.Properties, Property{Name: PropertyPrefix + "severity", Value: v.Severity})
+	}
+	return out
+}
+
+// Ratings returns a Rating for every CVSS score of "v", and one for its
+// normalized severity.
+func ratings(v *claircore.Vulnerability) []Rating {
+	var src *Source
+	if v.Updater != "" {
+		src = &Source{Name: v.Updater}
+	}
+	var rs []Rating
+	for _, s := range []struct {
+		score  float64
+		vector string
+		method string
+	}{
+		{v.CVSSv4Score, v.CVSSv4Vector, "CVSSv4"},
+		{v.CVSSv3Score, v.CVSSv3Vector, cvss3Method(v.CVSSv3Vector)},
+		{v.CVSSv2Score, v.CVSSv2Vector, "CVSSv2"},
+	} {
+		if s.score == 0 && s.vector == "" {
+			continue
+		}
+		r := Rating{Source: src, Method: s.method, Vector: s.vector}
+		if s.score != 0 {
+			score := s.score
+			r.Score = &score
+		}
+		rs = append(rs, r)
+	}
+	rs = append(rs, Rating{
+		Source:   src,
+		Severity: severity(v.NormalizedSeverity),
+		Method:   "other",
+	})
+	return rs
+}
+
+// Cvss3Method returns the rating method for a CVSS v3 vector, which depends
+// on the minor version.
+func cvss3Method(vec string) string {
+	if strings.HasPrefix(vec, "CVSS:3.1/") {
+		return "CVSSv31"
+	}
+	return "CVSSv3"
+}
+
+// Severity maps a normalized severity to a CycloneDX severity.
+func severity(s claircore.Severity) string {
+	switch s {
+	case claircore.Critical:
+		return "critical"
+	case claircore.High:
+		return "high"
+	case claircore.Medium:
+		return "medium"
+	case claircore.Low:
+		return "low"
+	case claircore.Negligible:
+		return "info"
+	}
+	return "unknown"
+}
+
+// Analysis returns the VEX analysis for "v".
+//
+// Claircore only reports vulnerabilities for packages the vendor considers
+// affected, so the state is "exploitable" unless the vendor is still
+// investigating. The response reflects the vendor's fix state.
+func analysis(v *claircore.Vulnerability) *Analysis {
+	a := Analysis{State: StateExploitable}
+	switch {
+	case v.FixState == claircore.FixStateUnderInvestigation:
+		a.State = StateInTriage
+		a.Detail = "The vendor is investigating whether the package is affected."
+	case v.FixState == claircore.FixStateWillNotFix:
+		a.Response = []string{ResponseWillNotFix}
+		a.Detail = "The vendor does not plan to release an update."
+	case v.FixedInVersion != "":
+		a.Response = []string{ResponseUpdate}
+		a.Detail = "Fixed in version " + v.FixedInVersion + "."
+	default:
+		a.Detail = "No update is available."
+	}
+	return &a
+}
+
+// HashAlg maps a digest algorithm to a CycloneDX hash algorithm.
+func hashAlg(algo string) string {
+	switch algo {
+	case "sha256":
+		return "SHA-256"
+	case "sha512":
+		return "SHA-512"
+	}
+	return strings.ToUpper(algo)
+}
+
+func packageRef(id string) string      { return "package:" + id }
+func distributionRef(id string) string { return "distribution:" + id }
+
+func sortedKeys[V any](m map[string]V) []string {
+	ks := make([]string, 0, len(m))
+	for k := range m {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return ks
+}
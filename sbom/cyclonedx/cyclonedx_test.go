@@ -0,0 +1,197 @@
+package cyclonedx
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/quay/claircore"
+)
+
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func testReports() (*claircore.IndexReport, *claircore.VulnerabilityReport) {
+	src := &claircore.Package{ID: "10", Name: "curl", Version: "7.61.1-12.el8", Kind: claircore.SOURCE}
+	ir := &claircore.IndexReport{
+		Hash:  claircore.MustParseDigest(testDigest),
+		State: "IndexFinished",
+		Distributions: map[string]*claircore.Distribution{
+			"1": {ID: "1", DID: "rhel", Name: "Red Hat Enterprise Linux", VersionID: "8"},
+		},
+		Repositories: map[string]*claircore.Repository{
+			"3": {ID: "3", Name: "maven", URI: "https://repo1.maven.apache.org/maven2"},
+		},
+		Packages: map[string]*claircore.Package{
+			"4": {ID: "4", Name: "curl", Version: "7.61.1-12.el8", Kind: claircore.BINARY, Arch: "x86_64", Source: src, PackageDB: "sqlite:var/lib/rpm"},
+			"6": {ID: "6", Name: "org.apache.logging.log4j:log4j-core", Version: "2.14.1", Kind: claircore.BINARY, PackageDB: "maven:opt/app.jar", Filepath: "opt/app.jar"},
+		},
+		Environments: map[string][]*claircore.Environment{
+			"4": {{PackageDB: "sqlite:var/lib/rpm", DistributionID: "1"}},
+			"6": {{PackageDB: "maven:opt/app.jar", RepositoryIDs: []string{"3"}}},
+		},
+		Success: true,
+	}
+	vr := &claircore.VulnerabilityReport{
+		Hash:         ir.Hash,
+		Packages:     ir.Packages,
+		Environments: ir.Environments,
+		Vulnerabilities: map[string]*claircore.Vulnerability{
+			"a": {
+				ID:                 "a",
+				Updater:            "rhel-vex",
+				Name:               "CVE-2020-8177",
+				Links:              "https://access.redhat.com/security/cve/CVE-2020-8177",
+				NormalizedSeverity: claircore.Medium,
+				FixedInVersion:     "0:7.61.1-12.el8_2.1",
+				CVSSv3Score:        5.3,
+				CVSSv3Vector:       "CVSS:3.1/AV:N/AC:H/PR:N/UI:R/S:U/C:N/I:H/A:N",
+			},
+			"b": {
+				ID:                 "b",
+				Updater:            "osv/maven",
+				Name:               "CVE-2021-44228",
+				NormalizedSeverity: claircore.Critical,
+				FixedInVersion:     "2.15.0",
+			},
+			"c": {
+				ID:                 "c",
+				Updater:            "rhel-vex",
+				Name:               "CVE-2023-0001",
+				NormalizedSeverity: claircore.Low,
+				FixState:           claircore.FixStateUnderInvestigation,
+			},
+			"unused": {ID: "unused", Name: "CVE-2000-0001"},
+		},
+		PackageVulnerabilities: map[string][]string{
+			"4":       {"a", "c"},
+			"6":       {"b"},
+			"missing": {"unused"},
+		},
+	}
+	return ir, vr
+}
+
+func TestBOM(t *testing.T) {
+	e := Encoder{
+		SerialNumber: "urn:uuid:00000000-0000-0000-0000-000000000000",
+		Created:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	ir, vr := testReports()
+	b, err := e.BOM(ir, vr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type comp struct{ Ref, Type, PURL string }
+	var got []comp
+	for _, c := range b.Components {
+		got = append(got, comp{c.BOMRef, c.Type, c.PURL})
+	}
+	want := []comp{
+		{"distribution:1", TypeOperatingSystem, ""},
+		{"package:4", TypeLibrary, "pkg:rpm/redhat/curl@7.61.1-12.el8?arch=x86_64&distro=rhel-8"},
+		{"package:6", TypeLibrary, "pkg:maven/org.apache.logging.log4j/log4j-core@2.14.1"},
+	}
+	if !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(got, want))
+	}
+	if p := b.Components[1].Pedigree; p == nil || len(p.Ancestors) != 1 ||
+		p.Ancestors[0].PURL != "pkg:rpm/redhat/curl@7.61.1-12.el8?arch=src&distro=rhel-8" {
+		t.Errorf("unexpected pedigree: %+v", p)
+	}
+	if ev := b.Components[2].Evidence; ev == nil || ev.Occurrences[0].Location != "opt/app.jar" {
+		t.Errorf("unexpected evidence: %+v", ev)
+	}
+
+	wantDeps := []Dependency{
+		{Ref: "manifest:" + testDigest, DependsOn: []string{"distribution:1", "package:4", "package:6"}},
+	}
+	if !cmp.Equal(b.Dependencies, wantDeps) {
+		t.Error(cmp.Diff(b.Dependencies, wantDeps))
+	}
+
+	type vuln struct {
+		ID, State string
+		Response  []string
+		Refs      []string
+	}
+	var gotVulns []vuln
+	for _, v := range b.Vulnerabilities {
+		var refs []string
+		for _, a := range v.Affects {
+			refs = append(refs, a.Ref)
+		}
+		gotVulns = append(gotVulns, vuln{v.ID, v.Analysis.State, v.Analysis.Response, refs})
+	}
+	wantVulns := []vuln{
+		{"CVE-2020-8177", StateExploitable, []string{ResponseUpdate}, []string{"package:4"}},
+		{"CVE-2021-44228", StateExploitable, []string{ResponseUpdate}, []string{"package:6"}},
+		{"CVE-2023-0001", StateInTriage, nil, []string{"package:4"}},
+	}
+	if !cmp.Equal(gotVulns, wantVulns) {
+		t.Error(cmp.Diff(gotVulns, wantVulns))
+	}
+
+	score := 5.3
+	wantRatings := []Rating{
+		{Source: &Source{Name: "rhel-vex"}, Score: &score, Method: "CVSSv31", Vector: "CVSS:3.1/AV:N/AC:H/PR:N/UI:R/S:U/C:N/I:H/A:N"},
+		{Source: &Source{Name: "rhel-vex"}, Severity: "medium", Method: "other"},
+	}
+	if got := b.Vulnerabilities[0].Ratings; !cmp.Equal(got, wantRatings) {
+		t.Error(cmp.Diff(got, wantRatings))
+	}
+
+	t.Run("Mismatch", func(t *testing.T) {
+		ir, vr := testReports()
+		vr.Hash = claircore.MustParseDigest("sha256:" + "fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210")
+		if _, err := e.BOM(ir, vr); err == nil {
+			t.Error("expected error")
+		}
+	})
+	t.Run("NoVulnerabilities", func(t *testing.T) {
+		ir, _ := testReports()
+		b, err := e.BOM(ir, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b.Vulnerabilities) != 0 {
+			t.Errorf("unexpected vulnerabilities: %+v", b.Vulnerabilities)
+		}
+	})
+}
+
+func TestEncode(t *testing.T) {
+	e := Encoder{
+		SerialNumber: "urn:uuid:00000000-0000-0000-0000-000000000000",
+		Created:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	ir, vr := testReports()
+	var buf bytes.Buffer
+	if err := e.Encode(&buf, ir, vr); err != nil {
+		t.Fatal(err)
+	}
+	t.Log(buf.String())
+	var doc map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := doc["bomFormat"], "CycloneDX"; got != want {
+		t.Errorf("got bomFormat %v, want %v", got, want)
+	}
+	if got, want := doc["specVersion"], Version; got != want {
+		t.Errorf("got specVersion %v, want %v", got, want)
+	}
+
+	// Output should be stable.
+	var again bytes.Buffer
+	ir, vr = testReports()
+	if err := e.Encode(&again, ir, vr); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), again.Bytes()) {
+		t.Error("output differs between runs")
+	}
+}
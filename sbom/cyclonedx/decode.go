@@ -0,0 +1,111 @@
+package cyclonedx
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/pkg/cpe"
+	"github.com/quay/claircore/pkg/purl"
+	"github.com/quay/claircore/sbom"
+)
+
+// Decode reads a CycloneDX JSON BOM from "r" and returns an IndexReport for
+// the components in it, suitable for passing to the matchers.
+//
+// Components, including nested ones, are identified by their Package URLs,
+// and components without one are skipped. The first pedigree ancestor of a
+// component is used as its source package, the first evidence occurrence as
+// its location, and "claircore:repository" properties as its repositories.
+// An operating-system component, if present, supplies the distribution for
+// distribution packages whose Package URL doesn't name one.
+//
+// The manifest digest is the SHA-256 or SHA-512 hash of the BOM's metadata
+// component, if it has one, or else the SHA-256 digest of the BOM itself.
+func Decode(r io.Reader) (*claircore.IndexReport, error) {
+	ir, _, err := DecodeComponents(r)
+	return ir, err
+}
+
+// DecodeComponents is like Decode, but also returns the BOM component each
+// package in the report was constructed from, keyed by package ID.
+func DecodeComponents(r io.Reader) (*claircore.IndexReport, map[string]*Component, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cyclonedx: unable to read bom: %w", err)
+	}
+	var bom BOM
+	if err := json.Unmarshal(b, &bom); err != nil {
+		return nil, nil, fmt.Errorf("cyclonedx: unable to decode bom: %w", err)
+	}
+	if bom.BOMFormat != "CycloneDX" {
+		return nil, nil, fmt.Errorf("cyclonedx: unexpected bomFormat %q", bom.BOMFormat)
+	}
+
+	var algo, sum string
+	if c := bom.Metadata.Component; c != nil {
+		for _, h := range c.Hashes {
+			if h.Alg == "SHA-256" || h.Alg == "SHA-512" {
+				algo, sum = h.Alg, h.Content
+				break
+			}
+		}
+	}
+	bld := sbom.NewBuilder(sbom.Digest(b, algo, sum))
+	var cs []*Component
+	var walk func([]Component)
+	walk = func(in []Component) {
+		for i := range in {
+			cs = append(cs, &in[i])
+			walk(in[i].Components)
+		}
+	}
+	walk(bom.Components)
+	for _, c := range cs {
+		if c.Type == TypeOperatingSystem {
+			bld.OperatingSystem(c.Name, c.Version)
+			break
+		}
+	}
+	refs := make(map[string]*Component, len(cs))
+	for _, c := range cs {
+		if c.Type == TypeOperatingSystem {
+			continue
+		}
+		in := sbom.Component{
+			PURL: packageURL(c.PURL),
+		}
+		if w, err := cpe.Unbind(c.CPE); err == nil {
+			in.CPE = w
+		}
+		if c.Pedigree != nil && len(c.Pedigree.Ancestors) != 0 {
+			in.Source = packageURL(c.Pedigree.Ancestors[0].PURL)
+		}
+		if c.Evidence != nil && len(c.Evidence.Occurrences) != 0 {
+			in.Location = c.Evidence.Occurrences[0].Location
+		}
+		for _, p := range c.Properties {
+			if p.Name == PropertyPrefix+"repository" {
+				in.Repositories = append(in.Repositories, p.Value)
+			}
+		}
+		if id := bld.Add(&in); id != "" {
+			refs[id] = c
+		}
+	}
+	return bld.Report(), refs, nil
+}
+
+// PackageURL parses "s", returning nil if it's not a valid Package URL.
+func packageURL(s string) *purl.PURL {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	u, err := purl.Parse(s)
+	if err != nil {
+		return nil
+	}
+	return u
+}
@@ -0,0 +1,83 @@
+package cyclonedx
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDecode(t *testing.T) {
+	ir, _ := testReports()
+	var buf bytes.Buffer
+	if err := (&Encoder{}).Encode(&buf, ir, nil); err != nil {
+		t.Fatal(err)
+	}
+	r, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.Hash.String(), ir.Hash.String(); got != want {
+		t.Errorf("got hash %q, want %q", got, want)
+	}
+
+	var got []string
+	for _, rec := range r.IndexRecords() {
+		s := rec.Package.Name + "@" + rec.Package.Version
+		if src := rec.Package.Source; src != nil {
+			s += " from " + src.Name + "@" + src.Version
+		}
+		if d := rec.Distribution; d != nil {
+			s += " on " + d.DID + "-" + d.VersionID
+		}
+		if repo := rec.Repository; repo != nil {
+			s += " in " + repo.Name
+		}
+		if rec.Package.Filepath != "" {
+			s += " at " + rec.Package.Filepath
+		}
+		got = append(got, s)
+	}
+	sort.Strings(got)
+	want := []string{
+		"curl@7.61.1-12.el8 from curl@7.61.1-12.el8 on rhel-8",
+		"org.apache.logging.log4j:log4j-core@2.14.1 in maven at opt/app.jar",
+	}
+	if !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(got, want))
+	}
+
+	t.Run("Nested", func(t *testing.T) {
+		const doc = `{
+  "bomFormat": "CycloneDX",
+  "specVersion": "1.5",
+  "components": [
+    {"type": "operating-system", "name": "alpine", "version": "3.18.4"},
+    {"type": "application", "name": "app", "components": [
+      {"type": "library", "name": "musl", "version": "1.2.4-r1", "purl": "pkg:apk/alpine/musl@1.2.4-r1?arch=x86_64"}
+    ]}
+  ]
+}`
+		r, err := Decode(strings.NewReader(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		recs := r.IndexRecords()
+		if len(recs) != 1 {
+			t.Fatalf("got %d records, want 1", len(recs))
+		}
+		if d := recs[0].Distribution; d == nil || d.PrettyName != "Alpine Linux v3.18" {
+			t.Errorf("unexpected distribution: %+v", d)
+		}
+		if r.Hash.String() == "" {
+			t.Error("missing digest")
+		}
+	})
+	t.Run("Format", func(t *testing.T) {
+		if _, err := Decode(strings.NewReader(`{"bomFormat":"SPDX"}`)); err == nil {
+			t.Error("expected error")
+		}
+	})
+}
@@ -0,0 +1,156 @@
+package sbom
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/pkg/cpe"
+)
+
+// Debian and Ubuntu matching needs release code names, which SBOMs often
+// leave out, so these map between version IDs and code names.
+var (
+	debianReleases = map[string]string{
+		"8":  "jessie",
+		"9":  "stretch",
+		"10": "buster",
+		"11": "bullseye",
+		"12": "bookworm",
+		"13": "trixie",
+	}
+	ubuntuReleases = map[string]string{
+		"14.04": "trusty",
+		"16.04": "xenial",
+		"18.04": "bionic",
+		"20.04": "focal",
+		"22.04": "jammy",
+		"23.10": "mantic",
+		"24.04": "noble",
+		"24.10": "oracular",
+		"25.04": "plucky",
+		"25.10": "questing",
+	}
+)
+
+// Distribution returns the Distribution the distribution scanners report for
+// the os-release ID "id" and version "ver", which may be a version ID or,
+// for Debian and Ubuntu, a code name.
+//
+// The fields are filled in to match the ones the corresponding matchers
+// query on. Unknown distributions only have the ID and version ID set.
+func Distribution(id, ver string) *claircore.Distribution {
+	id = strings.ToLower(id)
+	d := claircore.Distribution{
+		DID:       id,
+		VersionID: ver,
+	}
+	major, _, _ := strings.Cut(ver, ".")
+	switch id {
+	case "debian":
+		ver, code := release(debianReleases, ver)
+		if ver == "" {
+			break
+		}
+		d.Name = "Debian GNU/Linux"
+		d.VersionID = ver
+		d.VersionCodeName = code
+		d.Version = fmt.Sprintf("%s (%s)", ver, code)
+		d.PrettyName = fmt.Sprintf("Debian GNU/Linux %s (%s)", ver, code)
+	case "ubuntu":
+		ver, code := release(ubuntuReleases, ver)
+		if ver == "" {
+			break
+		}
+		d.Name = "Ubuntu"
+		d.VersionID = ver
+		d.VersionCodeName = code
+		d.Version = fmt.Sprintf("%s (%s)", ver, strings.ToUpper(code[:1])+code[1:])
+		d.PrettyName = "Ubuntu " + ver
+	case "alpine":
+		// Alpine is matched on the minor release.
+		if parts := strings.SplitN(ver, ".", 3); len(parts) >= 2 {
+			d.VersionID = parts[0] + "." + parts[1]
+		}
+		d.Name = "Alpine Linux"
+		d.PrettyName = "Alpine Linux v" + d.VersionID
+	case "rhel":
+		d.Name = "Red Hat Enterprise Linux Server"
+		d.Version = major
+		d.VersionID = major
+		d.PrettyName = "Red Hat Enterprise Linux Server " + major
+		if major != "" {
+			d.CPE = cpe.MustUnbind("cpe:/o:redhat:enterprise_linux:" + major)
+		}
+	case "ol":
+		d.Name = "Oracle Linux Server"
+		d.Version = major
+		d.VersionID = major
+		d.PrettyName = "Oracle Linux Server " + major
+		d.VersionCodeName = "Oracle Linux " + major
+	case "amzn":
+		d.Name = "Amazon Linux"
+		d.Version = ver
+		if ver == "2018.03" {
+			d.Name = "Amazon Linux AMI"
+		}
+		d.PrettyName = d.Name + " " + ver
+	case "sles":
+		d.Name = "SLES"
+		d.Version = major
+		d.VersionID = major
+		d.PrettyName = "SUSE Linux Enterprise Server " + major
+	case "opensuse-leap":
+		d.Name = "openSUSE Leap"
+		d.Version = ver
+		d.PrettyName = "openSUSE Leap " + ver
+	case "photon":
+		if !strings.Contains(ver, ".") {
+			d.VersionID = ver + ".0"
+		}
+		d.Name = "VMware Photon OS"
+		d.Version = d.VersionID
+		d.PrettyName = "VMware Photon OS/Linux"
+	}
+	return &d
+}
+
+// Release looks up "v", which may be a version ID or a code name, in the
+// table "m", and reports both. Empty strings are returned if "v" isn't
+// known.
+func release(m map[string]string, v string) (ver, code string) {
+	if code, ok := m[v]; ok {
+		return v, code
+	}
+	v = strings.ToLower(v)
+	for ver, code := range m {
+		if code == v {
+			return ver, code
+		}
+	}
+	return "", ""
+}
+
+// ParseDistro splits a Package URL "distro" qualifier, like "rhel-9.2",
+// "debian-12", or "bookworm", into an os-release ID and version. The
+// namespace of the Package URL is used as the ID when the qualifier is just a
+// version or code name.
+func parseDistro(ns, q string) (id, ver string) {
+	if q == "" {
+		return "", ""
+	}
+	if i := strings.LastIndexByte(q, '-'); i > 0 {
+		return q[:i], q[i+1:]
+	}
+	switch ns {
+	case "redhat":
+		ns = "rhel"
+	case "oracle":
+		ns = "ol"
+	case "amazon":
+		ns = "amzn"
+	case "suse":
+		ns = "sles"
+	}
+	return ns, q
+}
@@ -0,0 +1,312 @@
+package sbom
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/gobin"
+	"github.com/quay/claircore/indexer/controller"
+	"github.com/quay/claircore/java"
+	"github.com/quay/claircore/pkg/cpe"
+	"github.com/quay/claircore/pkg/pep440"
+	"github.com/quay/claircore/pkg/purl"
+	"github.com/quay/claircore/python"
+	"github.com/quay/claircore/ruby"
+)
+
+// Component is a package listed in an SBOM.
+type Component struct {
+	// PURL is the package's Package URL. Components without one are ignored.
+	PURL *purl.PURL
+	// Source is the Package URL of the source package this package was built
+	// from, if known. For distribution packages, an "upstream" qualifier on
+	// PURL is used if this isn't set.
+	Source *purl.PURL
+	// CPE is the package's CPE, if any.
+	CPE cpe.WFN
+	// Location is the path the package was found at, if known.
+	Location string
+	// Repositories names the repositories the package came from, if known.
+	// For Red Hat rpm packages, repository CPEs are used for matching.
+	Repositories []string
+}
+
+// RepositoryKey is the key the rhel package uses for repositories based on
+// Red Hat CPEs.
+const repositoryKey = `rhel-cpe-repository`
+
+// These are the package databases reported for distribution packages, which
+// SBOMs don't record. They're the ones the package scanners report, so that
+// [PURL] identifies the same ecosystem.
+const (
+	rpmDB = `sqlite:var/lib/rpm`
+	debDB = `var/lib/dpkg/status`
+	apkDB = `lib/apk/db/installed`
+)
+
+// Builder constructs an IndexReport from the packages listed in an SBOM, so
+// that it can be used with the matchers.
+//
+// Builders must be created with NewBuilder.
+type Builder struct {
+	r     *claircore.IndexReport
+	os    *claircore.Distribution
+	dists map[string]string
+	repos map[string]string
+	pkgs  map[string]*claircore.Package
+	id    int
+}
+
+// NewBuilder returns a Builder for an IndexReport for the manifest "hash".
+func NewBuilder(hash claircore.Digest) *Builder {
+	return &Builder{
+		r: &claircore.IndexReport{
+			Hash:          hash,
+			State:         controller.IndexFinished.String(),
+			Packages:      make(map[string]*claircore.Package),
+			Distributions: make(map[string]*claircore.Distribution),
+			Repositories:  make(map[string]*claircore.Repository),
+			Environments:  make(map[string][]*claircore.Environment),
+			Success:       true,
+		},
+		dists: make(map[string]string),
+		repos: make(map[string]string),
+		pkgs:  make(map[string]*claircore.Package),
+	}
+}
+
+// OperatingSystem records the operating system the SBOM describes, by its
+// os-release ID and version. It's used for distribution packages whose
+// Package URL doesn't have a "distro" qualifier.
+func (b *Builder) OperatingSystem(id, ver string) {
+	if id == "" {
+		return
+	}
+	b.os = Distribution(id, ver)
+}
+
+// Add adds the package described by "c" to the report.
+//
+// Components with Package URLs of types claircore has no matchers for are
+// ignored. Add returns the ID of the package in the report, or an empty string
+// if the component was ignored.
+func (b *Builder) Add(c *Component) string {
+	if c.PURL == nil {
+		return ""
+	}
+	u := c.PURL
+	p := claircore.Package{
+		Name:     u.Name,
+		Version:  u.Version,
+		Kind:     claircore.BINARY,
+		Filepath: c.Location,
+		CPE:      c.CPE,
+	}
+	env := claircore.Environment{
+		IntroducedIn: b.r.Hash,
+	}
+	var repos []*claircore.Repository
+	switch u.Type {
+	case purl.TypeRPM:
+		p.Version = rpmVersion(u)
+		p.Arch = u.Qualifiers["arch"]
+		p.PackageDB = rpmDB
+		p.Source = b.source(c, u)
+		env.DistributionID = b.distribution(u)
+		if u.Namespace == "redhat" {
+			// Red Hat purls may name the repository CPE directly.
+			names := append([]string{u.Qualifiers["repository_cpe"]}, c.Repositories...)
+			for _, n := range names {
+				if w, err := cpe.Unbind(n); err == nil {
+					repos = append(repos, &claircore.Repository{
+						Name: n,
+						Key:  repositoryKey,
+						CPE:  w,
+					})
+				}
+			}
+		}
+	case purl.TypeDebian, typeAPK:
+		p.Arch = u.Qualifiers["arch"]
+		p.PackageDB = debDB
+		if u.Type == typeAPK {
+			p.PackageDB = apkDB
+		}
+		p.Source = b.source(c, u)
+		env.DistributionID = b.distribution(u)
+	case purl.TypePyPI:
+		v, err := pep440.Parse(u.Version)
+		if err == nil {
+			p.Version = v.String()
+			p.NormalizedVersion = v.Version()
+		}
+		p.PackageDB = "python:" + c.Location
+		repos = append(repos, &python.Repository)
+	case purl.TypeMaven:
+		if u.Namespace != "" {
+			p.Name = u.Namespace + ":" + u.Name
+		}
+		p.PackageDB = "maven:" + c.Location
+		repos = append(repos, &java.Repository)
+	case purl.TypeGo:
+		if u.Namespace != "" {
+			p.Name = u.Namespace + "/" + u.Name
+		}
+		if v, err := semver.NewVersion(u.Version); err == nil {
+			p.NormalizedVersion = fromSemver(v)
+		}
+		p.PackageDB = "go:" + c.Location
+		repos = append(repos, &gobin.Repository)
+	case typeGem:
+		p.PackageDB = c.Location
+		repos = append(repos, &ruby.Repository)
+	default:
+		return ""
+	}
+	env.PackageDB = p.PackageDB
+	seen := make(map[string]struct{}, len(repos))
+	for _, r := range repos {
+		id := b.repository(r)
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		env.RepositoryIDs = append(env.RepositoryIDs, id)
+	}
+	p.ID = b.next()
+	b.r.Packages[p.ID] = &p
+	b.r.Environments[p.ID] = []*claircore.Environment{&env}
+	return p.ID
+}
+
+// Report returns the constructed IndexReport.
+func (b *Builder) Report() *claircore.IndexReport {
+	return b.r
+}
+
+func (b *Builder) next() string {
+	b.id++
+	return strconv.Itoa(b.id)
+}
+
+// Distribution returns the ID of the distribution for the distribution
+// package "u", adding it to the report if needed. An empty string is
+// returned if the distribution isn't known.
+func (b *Builder) distribution(u *purl.PURL) string {
+	d := b.os
+	if id, ver := parseDistro(u.Namespace, u.Qualifiers["distro"]); id != "" {
+		d = Distribution(id, ver)
+	}
+	if d == nil {
+		return ""
+	}
+	k := d.DID + "\x00" + d.VersionID
+	if id, ok := b.dists[k]; ok {
+		return id
+	}
+	d.ID = b.next()
+	b.r.Distributions[d.ID] = d
+	b.dists[k] = d.ID
+	return d.ID
+}
+
+// Repository returns the ID of the repository "r", adding a copy of it to the
+// report if needed.
+func (b *Builder) repository(r *claircore.Repository) string {
+	k := r.Key + "\x00" + r.Name + "\x00" + r.URI
+	if id, ok := b.repos[k]; ok {
+		return id
+	}
+	repo := *r
+	repo.ID = b.next()
+	b.r.Repositories[repo.ID] = &repo
+	b.repos[k] = repo.ID
+	return repo.ID
+}
+
+// Source returns the source package for the distribution package "u", or nil
+// if it's not known. Source packages shared between binary packages are
+// only constructed once.
+func (b *Builder) source(c *Component, u *purl.PURL) *claircore.Package {
+	var name, ver string
+	switch {
+	case c.Source != nil:
+		name, ver = c.Source.Name, c.Source.Version
+		if c.Source.Type == purl.TypeRPM {
+			ver = rpmVersion(c.Source)
+		}
+	case u.Qualifiers["upstream"] != "":
+		up := u.Qualifiers["upstream"]
+		if u.Type == purl.TypeRPM {
+			// This is a source rpm filename, like "bash-5.1-2.el9.src.rpm".
+			up = strings.TrimSuffix(up, ".src.rpm")
+			pos := len(up)
+			for i := 0; i < 2 && pos != -1; i++ {
+				pos = strings.LastIndexByte(up[:pos], '-')
+			}
+			if pos == -1 {
+				return nil
+			}
+			name, ver = up[:pos], up[pos+1:]
+			break
+		}
+		name, ver, _ = strings.Cut(up, "@")
+	default:
+		return nil
+	}
+	k := u.Type + "\x00" + name + "\x00" + ver
+	if p, ok := b.pkgs[k]; ok {
+		return p
+	}
+	p := &claircore.Package{
+		ID:      b.next(),
+		Name:    name,
+		Version: ver,
+		Kind:    claircore.SOURCE,
+	}
+	b.pkgs[k] = p
+	return p
+}
+
+// RpmVersion returns the EVR for the rpm package "u", taking the epoch from
+// the "epoch" qualifier.
+func rpmVersion(u *purl.PURL) string {
+	if e := u.Qualifiers["epoch"]; e != "" && e != "0" {
+		return e + ":" + u.Version
+	}
+	return u.Version
+}
+
+// FromSemver is the SemVer to claircore.Version mapping used by the gobin
+// package.
+func fromSemver(v *semver.Version) (out claircore.Version) {
+	out.Kind = `semver`
+	// Leave a leading epoch, for good measure.
+	out.V[1] = int32(v.Major())
+	out.V[2] = int32(v.Minor())
+	out.V[3] = int32(v.Patch())
+	return out
+}
+
+// Digest returns the manifest digest for an SBOM: the checksum "sum", in hex,
+// using the algorithm "algo", if it's one claircore supports, or else the
+// SHA-256 digest of the document "doc" itself.
+func Digest(doc []byte, algo, sum string) claircore.Digest {
+	algo = strings.ToLower(strings.ReplaceAll(algo, "-", ""))
+	if b, err := hex.DecodeString(sum); err == nil {
+		if d, err := claircore.NewDigest(algo, b); err == nil {
+			return d
+		}
+	}
+	s := sha256.Sum256(doc)
+	d, err := claircore.NewDigest("sha256", s[:])
+	if err != nil {
+		panic("programmer error: " + err.Error())
+	}
+	return d
+}
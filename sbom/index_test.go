@@ -0,0 +1,142 @@
+package sbom
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/pkg/purl"
+)
+
+func mustPURL(t *testing.T, s string) *purl.PURL {
+	t.Helper()
+	u, err := purl.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestBuilder(t *testing.T) {
+	hash := Digest([]byte("test"), "", "")
+	b := NewBuilder(hash)
+	b.OperatingSystem("debian", "12")
+	for _, c := range []Component{
+		{
+			PURL:         mustPURL(t, "pkg:rpm/redhat/bash@4.4.20-4.el8?arch=x86_64&distro=rhel-8.6&epoch=1&upstream=bash-4.4.20-4.el8.src.rpm"),
+			Repositories: []string{"cpe:/o:redhat:enterprise_linux:8::baseos", "not a cpe"},
+		},
+		{PURL: mustPURL(t, "pkg:deb/debian/libc6@2.36-9?arch=amd64&upstream=glibc@2.36-9")},
+		{PURL: mustPURL(t, "pkg:pypi/flask@2.3.2"), Location: "usr/lib/python3/site-packages/Flask-2.3.2.dist-info/METADATA"},
+		{PURL: mustPURL(t, "pkg:golang/golang.org/x/net@v0.17.0"), Location: "usr/bin/app"},
+		{PURL: mustPURL(t, "pkg:npm/left-pad@1.3.0")},
+		{},
+	} {
+		c := c
+		b.Add(&c)
+	}
+	r := b.Report()
+	if got, want := r.Hash, hash; got.String() != want.String() {
+		t.Errorf("got hash %v, want %v", got, want)
+	}
+
+	type row struct {
+		Name, Version, Source, Dist string
+		Repos                       []string
+	}
+	var got []row
+	for _, rec := range r.IndexRecords() {
+		var x row
+		x.Name, x.Version = rec.Package.Name, rec.Package.Version
+		if rec.Package.Source != nil {
+			x.Source = rec.Package.Source.Name + "@" + rec.Package.Source.Version
+		}
+		if rec.Distribution != nil {
+			x.Dist = rec.Distribution.PrettyName
+		}
+		if rec.Repository != nil {
+			x.Repos = []string{rec.Repository.Name}
+		}
+		got = append(got, x)
+	}
+	want := []row{
+		{Name: "bash", Version: "1:4.4.20-4.el8", Source: "bash@4.4.20-4.el8", Dist: "Red Hat Enterprise Linux Server 8", Repos: []string{"cpe:/o:redhat:enterprise_linux:8::baseos"}},
+		{Name: "libc6", Version: "2.36-9", Source: "glibc@2.36-9", Dist: "Debian GNU/Linux 12 (bookworm)"},
+		{Name: "flask", Version: "2.3.2", Repos: []string{"pypi"}},
+		{Name: "golang.org/x/net", Version: "v0.17.0", Repos: []string{"go"}},
+	}
+	sortRows := cmpopts.SortSlices(func(a, b row) bool { return a.Name < b.Name })
+	if !cmp.Equal(got, want, sortRows) {
+		t.Error(cmp.Diff(got, want, sortRows))
+	}
+
+	for _, p := range r.Packages {
+		switch p.Name {
+		case "flask":
+			if got, want := p.NormalizedVersion.Kind, "pep440"; got != want {
+				t.Errorf("got version kind %q, want %q", got, want)
+			}
+		case "golang.org/x/net":
+			if got, want := p.NormalizedVersion.Kind, "semver"; got != want {
+				t.Errorf("got version kind %q, want %q", got, want)
+			}
+		}
+		// The scanners' package databases should round-trip through PURL.
+		if u := PURL(ContextFor(r, p.ID), p); u == nil {
+			t.Errorf("%s: no purl", p.Name)
+		}
+	}
+}
+
+func TestDistribution(t *testing.T) {
+	tt := []struct {
+		ID, Version string
+		Want        claircore.Distribution
+	}{
+		{
+			ID: "debian", Version: "bookworm",
+			Want: claircore.Distribution{
+				DID:             "debian",
+				Name:            "Debian GNU/Linux",
+				Version:         "12 (bookworm)",
+				VersionID:       "12",
+				VersionCodeName: "bookworm",
+				PrettyName:      "Debian GNU/Linux 12 (bookworm)",
+			},
+		},
+		{
+			ID: "ubuntu", Version: "22.04",
+			Want: claircore.Distribution{
+				DID:             "ubuntu",
+				Name:            "Ubuntu",
+				Version:         "22.04 (Jammy)",
+				VersionID:       "22.04",
+				VersionCodeName: "jammy",
+				PrettyName:      "Ubuntu 22.04",
+			},
+		},
+		{
+			ID: "alpine", Version: "3.18.4",
+			Want: claircore.Distribution{
+				DID:        "alpine",
+				Name:       "Alpine Linux",
+				VersionID:  "3.18",
+				PrettyName: "Alpine Linux v3.18",
+			},
+		},
+		{
+			ID: "fedora", Version: "39",
+			Want: claircore.Distribution{DID: "fedora", VersionID: "39"},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.ID, func(t *testing.T) {
+			got := Distribution(tc.ID, tc.Version)
+			if !cmp.Equal(*got, tc.Want) {
+				t.Error(cmp.Diff(*got, tc.Want))
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+// Package sbom holds the parts of the IndexReport mapping shared by the SBOM
+// formats in its subpackages.
+//
+// Claircore doesn't record which ecosystem a package came from directly, so
+// it's inferred from the repositories and package database the package was
+// found with. See [PURL] for the details.
+//
+// Going the other way, a [Builder] constructs an IndexReport from the Package
+// URLs in an SBOM, filling in the distributions and repositories the
+// matchers expect, so an SBOM produced at build time can be passed to
+// Libvuln's Scan method in place of an indexed manifest.
+package sbom
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/pkg/purl"
+)
+
+// These are package types not covered by the purl package's builders.
+const (
+	typeAPK = "apk"
+	typeGem = "gem"
+)
+
+// Context is the information about a package gathered from an IndexReport.
+type Context struct {
+	// Distribution is the distribution the package was found on, if any.
+	Distribution *claircore.Distribution
+	// Repositories are the repositories the package was found with, sorted by
+	// ID.
+	Repositories []*claircore.Repository
+	// PackageDBs are the package databases the package was found in, sorted.
+	PackageDBs []string
+	// Layers are the layers the package was introduced in, sorted.
+	Layers []claircore.Digest
+}
+
+// ContextFor gathers the Context for the package with the ID "id" from the
+// environments in "r".
+func ContextFor(r *claircore.IndexReport, id string) *Context {
+	var c Context
+	repos := make(map[string]*claircore.Repository)
+	dbs := make(map[string]struct{})
+	layers := make(map[string]claircore.Digest)
+	for _, env := range r.Environments[id] {
+		if env == nil {
+			continue
+		}
+		if c.Distribution == nil && env.DistributionID != "" {
+			c.Distribution = r.Distributions[env.DistributionID]
+		}
+		for _, rid := range env.RepositoryIDs {
+			if repo, ok := r.Repositories[rid]; ok {
+				repos[rid] = repo
+			}
+		}
+		if env.PackageDB != "" {
+			dbs[env.PackageDB] = struct{}{}
+		}
+		if l := env.IntroducedIn.String(); l != "" {
+			layers[l] = env.IntroducedIn
+		}
+	}
+	for _, repo := range repos {
+		c.Repositories = append(c.Repositories, repo)
+	}
+	sort.Slice(c.Repositories, func(i, j int) bool {
+		return c.Repositories[i].ID < c.Repositories[j].ID
+	})
+	for db := range dbs {
+		c.PackageDBs = append(c.PackageDBs, db)
+	}
+	sort.Strings(c.PackageDBs)
+	for _, l := range layers {
+		c.Layers = append(c.Layers, l)
+	}
+	sort.Slice(c.Layers, func(i, j int) bool {
+		return c.Layers[i].String() < c.Layers[j].String()
+	})
+	return &c
+}
+
+// PURL returns the Package URL for the package "p", found in the Context "c".
+// A nil PURL is returned if the package's ecosystem can't be determined.
+//
+// Language ecosystems are identified by the repositories claircore's
+// scanners attach, falling back to the prefix of the package database.
+// Distribution packages are identified by the package database, and use the
+// distribution to fill in the namespace and "distro" qualifier.
+//
+// Source packages use the name and version of the source package itself,
+// with the "arch" qualifier set to the value the ecosystem uses for source
+// packages, if any.
+func PURL(c *Context, p *claircore.Package) *purl.PURL {
+	if c == nil {
+		c = &Context{}
+	}
+	db := p.PackageDB
+	if db == "" && len(c.PackageDBs) != 0 {
+		db = c.PackageDBs[0]
+	}
+	for _, repo := range c.Repositories {
+		switch repo.Name {
+		case "pypi":
+			return purl.NewPyPI(p.Name, p.Version)
+		case "maven":
+			return maven(p)
+		case "go":
+			return purl.NewGo(p.Name, p.Version)
+		case "rubygems":
+			return &purl.PURL{Type: typeGem, Name: p.Name, Version: p.Version}
+		}
+	}
+	prefix, _, _ := strings.Cut(db, ":")
+	switch prefix {
+	case "python":
+		return purl.NewPyPI(p.Name, p.Version)
+	case "maven", "jar":
+		return maven(p)
+	case "go":
+		return purl.NewGo(p.Name, p.Version)
+	case "sqlite", "bdb", "ndb":
+		return rpm(c.Distribution, p)
+	}
+	switch {
+	case strings.Contains(db, "var/lib/dpkg"):
+		return deb(c.Distribution, p)
+	case strings.Contains(db, "lib/apk/db"):
+		return apk(c.Distribution, p)
+	case strings.Contains(db, "var/lib/rpm"):
+		return rpm(c.Distribution, p)
+	}
+	return nil
+}
+
+// Maven returns a PURL for a package named "group:artifact".
+func maven(p *claircore.Package) *purl.PURL {
+	g, a, ok := strings.Cut(p.Name, ":")
+	if !ok {
+		g, a = "", p.Name
+	}
+	return purl.NewMaven(g, a, p.Version)
+}
+
+// Rpm returns a PURL for an rpm package, moving any epoch in the version into
+// the "epoch" qualifier.
+func rpm(d *claircore.Distribution, p *claircore.Package) *purl.PURL {
+	arch := p.Arch
+	if p.Kind == claircore.SOURCE {
+		arch = "src"
+	}
+	v := p.Version
+	var epoch string
+	if e, rest, ok := strings.Cut(v, ":"); ok {
+		epoch, v = e, rest
+	}
+	u := purl.NewRPM(vendor(d), p.Name, v, arch)
+	if epoch != "" && epoch != "0" {
+		qualify(u, "epoch", epoch)
+	}
+	qualify(u, "distro", distro(d))
+	return u
+}
+
+// Deb returns a PURL for a dpkg package.
+func deb(d *claircore.Distribution, p *claircore.Package) *purl.PURL {
+	u := purl.NewDebian(p.Name, p.Version)
+	if d != nil && d.DID != "" {
+		u.Namespace = d.DID
+	}
+	arch := p.Arch
+	if p.Kind == claircore.SOURCE {
+		arch = "source"
+	}
+	qualify(u, "arch", arch)
+	if d != nil && d.VersionCodeName != "" {
+		qualify(u, "distro", d.VersionCodeName)
+	} else {
+		qualify(u, "distro", distro(d))
+	}
+	return u
+}
+
+// Apk returns a PURL for an apk package.
+func apk(d *claircore.Distribution, p *claircore.Package) *purl.PURL {
+	u := &purl.PURL{
+		Type:      typeAPK,
+		Namespace: "alpine",
+		Name:      strings.ToLower(p.Name),
+		Version:   p.Version,
+	}
+	if d != nil && d.DID != "" {
+		u.Namespace = d.DID
+	}
+	if p.Kind != claircore.SOURCE {
+		qualify(u, "arch", p.Arch)
+	}
+	qualify(u, "distro", distro(d))
+	return u
+}
+
+// Vendor returns the rpm namespace for a distribution.
+//
+// The names follow the ones used in the vendors' own SBOMs where they differ
+// from the os-release ID.
+func vendor(d *claircore.Distribution) string {
+	if d == nil {
+		return ""
+	}
+	switch d.DID {
+	case "rhel", "rhcos":
+		return "redhat"
+	case "ol":
+		return "oracle"
+	case "amzn":
+		return "amazon"
+	case "sles", "opensuse-leap", "opensuse-tumbleweed":
+		return "suse"
+	}
+	return d.DID
+}
+
+// Distro returns the "distro" qualifier for a distribution, like
+// "rhel-9.2".
+func distro(d *claircore.Distribution) string {
+	switch {
+	case d == nil || d.DID == "":
+		return ""
+	case d.VersionID == "":
+		return d.DID
+	}
+	return d.DID + "-" + d.VersionID
+}
+
+// Qualify sets the qualifier "k" on "u" if "v" is not empty.
+func qualify(u *purl.PURL, k, v string) {
+	if v == "" {
+		return
+	}
+	if u.Qualifiers == nil {
+		u.Qualifiers = make(map[string]string)
+	}
+	u.Qualifiers[k] = v
+}
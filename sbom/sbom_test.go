@@ -0,0 +1,111 @@
+package sbom
+
+import (
+	"testing"
+
+	"github.com/quay/claircore"
+)
+
+func TestPURL(t *testing.T) {
+	rhel := &claircore.Distribution{ID: "1", DID: "rhel", VersionID: "8"}
+	debian := &claircore.Distribution{ID: "2", DID: "debian", VersionID: "12", VersionCodeName: "bookworm"}
+	alpine := &claircore.Distribution{ID: "3", DID: "alpine", VersionID: "3.18"}
+	pypi := &claircore.Repository{ID: "10", Name: "pypi"}
+	maven := &claircore.Repository{ID: "11", Name: "maven"}
+
+	tt := []struct {
+		Name string
+		Ctx  *Context
+		Pkg  *claircore.Package
+		Want string
+	}{
+		{
+			Name: "RPM",
+			Ctx:  &Context{Distribution: rhel},
+			Pkg:  &claircore.Package{Name: "bash", Version: "1:4.4.20-4.el8", Arch: "x86_64", PackageDB: "sqlite:var/lib/rpm"},
+			Want: "pkg:rpm/redhat/bash@4.4.20-4.el8?arch=x86_64&distro=rhel-8&epoch=1",
+		},
+		{
+			Name: "RPMSource",
+			Ctx:  &Context{Distribution: rhel, PackageDBs: []string{"bdb:var/lib/rpm"}},
+			Pkg:  &claircore.Package{Name: "bash", Version: "4.4.20-4.el8", Kind: claircore.SOURCE},
+			Want: "pkg:rpm/redhat/bash@4.4.20-4.el8?arch=src&distro=rhel-8",
+		},
+		{
+			Name: "Debian",
+			Ctx:  &Context{Distribution: debian},
+			Pkg:  &claircore.Package{Name: "libc6", Version: "2.36-9", Arch: "amd64", PackageDB: "var/lib/dpkg/status"},
+			Want: "pkg:deb/debian/libc6@2.36-9?arch=amd64&distro=bookworm",
+		},
+		{
+			Name: "Alpine",
+			Ctx:  &Context{Distribution: alpine},
+			Pkg:  &claircore.Package{Name: "musl", Version: "1.2.4-r1", Arch: "x86_64", PackageDB: "lib/apk/db/installed"},
+			Want: "pkg:apk/alpine/musl@1.2.4-r1?arch=x86_64&distro=alpine-3.18",
+		},
+		{
+			Name: "PyPI",
+			Ctx:  &Context{Repositories: []*claircore.Repository{pypi}},
+			Pkg:  &claircore.Package{Name: "Flask_Cors", Version: "3.0.10", PackageDB: "python:usr/lib/python3/site-packages"},
+			Want: "pkg:pypi/flask-cors@3.0.10",
+		},
+		{
+			Name: "Maven",
+			Ctx:  &Context{Repositories: []*claircore.Repository{maven}},
+			Pkg:  &claircore.Package{Name: "org.apache.logging.log4j:log4j-core", Version: "2.14.1"},
+			Want: "pkg:maven/org.apache.logging.log4j/log4j-core@2.14.1",
+		},
+		{
+			Name: "Go",
+			Ctx:  &Context{PackageDBs: []string{"go:usr/bin/app"}},
+			Pkg:  &claircore.Package{Name: "golang.org/x/net", Version: "v0.17.0"},
+			Want: "pkg:golang/golang.org/x/net@v0.17.0",
+		},
+		{
+			Name: "Unknown",
+			Pkg:  &claircore.Package{Name: "mystery", Version: "1"},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			var got string
+			if u := PURL(tc.Ctx, tc.Pkg); u != nil {
+				got = u.String()
+			}
+			if got != tc.Want {
+				t.Errorf("got: %q, want: %q", got, tc.Want)
+			}
+		})
+	}
+}
+
+func TestContextFor(t *testing.T) {
+	r := &claircore.IndexReport{
+		Distributions: map[string]*claircore.Distribution{
+			"1": {ID: "1", DID: "rhel"},
+		},
+		Repositories: map[string]*claircore.Repository{
+			"b": {ID: "b", Name: "rhel-8-for-x86_64-appstream-rpms"},
+			"a": {ID: "a", Name: "rhel-8-for-x86_64-baseos-rpms"},
+		},
+		Environments: map[string][]*claircore.Environment{
+			"1": {
+				{PackageDB: "sqlite:var/lib/rpm", DistributionID: "1", RepositoryIDs: []string{"b", "a"}},
+				{PackageDB: "sqlite:var/lib/rpm", DistributionID: "1", RepositoryIDs: []string{"a", "missing"}},
+			},
+		},
+	}
+	c := ContextFor(r, "1")
+	if c.Distribution == nil || c.Distribution.DID != "rhel" {
+		t.Errorf("unexpected distribution: %+v", c.Distribution)
+	}
+	if got, want := len(c.Repositories), 2; got != want {
+		t.Fatalf("got %d repositories, want %d", got, want)
+	}
+	if got, want := c.Repositories[0].ID, "a"; got != want {
+		t.Errorf("got first repository %q, want %q", got, want)
+	}
+	if got, want := len(c.PackageDBs), 1; got != want {
+		t.Errorf("got %d package databases, want %d", got, want)
+	}
+}
@@ -0,0 +1,131 @@
+package spdx
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/pkg/cpe"
+	"github.com/quay/claircore/pkg/purl"
+	"github.com/quay/claircore/sbom"
+)
+
+// Decode reads an SPDX 2.x JSON document from "r" and returns an IndexReport
+// for the packages in it, suitable for passing to the matchers.
+//
+// Packages are identified by their "purl" external references, and packages
+// without one are skipped. Packages that other packages are GENERATED_FROM
+// are used as source packages rather than added on their own. An
+// OPERATING-SYSTEM package, if present, supplies the distribution for
+// distribution packages whose Package URL doesn't name one.
+//
+// The manifest digest is the SHA-256 or SHA-512 checksum of the package the
+// document describes, if it has one, or else the SHA-256 digest of the
+// document itself.
+func Decode(r io.Reader) (*claircore.IndexReport, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("spdx: unable to read document: %w", err)
+	}
+	var d Document
+	if err := json.Unmarshal(b, &d); err != nil {
+		return nil, fmt.Errorf("spdx: unable to decode document: %w", err)
+	}
+	if !strings.HasPrefix(d.SPDXVersion, "SPDX-2.") {
+		return nil, fmt.Errorf("spdx: unsupported version %q", d.SPDXVersion)
+	}
+
+	described := make(map[string]bool)
+	for _, id := range d.DocumentDescribes {
+		described[id] = true
+	}
+	// Maps binary packages to the source packages they were built from.
+	source := make(map[string]string)
+	isSource := make(map[string]bool)
+	for _, rel := range d.Relationships {
+		switch rel.RelationshipType {
+		case RelDescribes:
+			if rel.SPDXElementID == d.SPDXID {
+				described[rel.RelatedSPDXElement] = true
+			}
+		case "DESCRIBED_BY":
+			if rel.RelatedSPDXElement == d.SPDXID {
+				described[rel.SPDXElementID] = true
+			}
+		case RelGeneratedFrom:
+			source[rel.SPDXElementID] = rel.RelatedSPDXElement
+			isSource[rel.RelatedSPDXElement] = true
+		}
+	}
+
+	var algo, sum string
+	pkgs := make(map[string]*Package, len(d.Packages))
+	for i := range d.Packages {
+		p := &d.Packages[i]
+		pkgs[p.SPDXID] = p
+		if !described[p.SPDXID] || sum != "" {
+			continue
+		}
+		for _, c := range p.Checksums {
+			if c.Algorithm == "SHA256" || c.Algorithm == "SHA512" {
+				algo, sum = c.Algorithm, c.ChecksumValue
+				break
+			}
+		}
+	}
+	bld := sbom.NewBuilder(sbom.Digest(b, algo, sum))
+	for i := range d.Packages {
+		if p := &d.Packages[i]; p.PrimaryPackagePurpose == PurposeOperatingSystem {
+			bld.OperatingSystem(p.Name, p.VersionInfo)
+			break
+		}
+	}
+	for i := range d.Packages {
+		p := &d.Packages[i]
+		if described[p.SPDXID] || isSource[p.SPDXID] ||
+			p.PrimaryPackagePurpose == PurposeOperatingSystem {
+			continue
+		}
+		c := sbom.Component{
+			PURL: packageURL(p),
+			CPE:  packageCPE(p),
+		}
+		if src, ok := pkgs[source[p.SPDXID]]; ok {
+			c.Source = packageURL(src)
+		}
+		bld.Add(&c)
+	}
+	return bld.Report(), nil
+}
+
+// PackageURL returns the Package URL from the package's external
+// references, or nil if there isn't a valid one.
+func packageURL(p *Package) *purl.PURL {
+	for _, r := range p.ExternalRefs {
+		if r.ReferenceType != "purl" {
+			continue
+		}
+		if u, err := purl.Parse(r.ReferenceLocator); err == nil {
+			return u
+		}
+	}
+	return nil
+}
+
+// PackageCPE returns the CPE from the package's external references, or the
+// zero WFN if there isn't a valid one.
+func packageCPE(p *Package) cpe.WFN {
+	for _, r := range p.ExternalRefs {
+		switch r.ReferenceType {
+		case "cpe23Type", "cpe22Type":
+		default:
+			continue
+		}
+		if w, err := cpe.Unbind(r.ReferenceLocator); err == nil {
+			return w
+		}
+	}
+	return cpe.WFN{}
+}
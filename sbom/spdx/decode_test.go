@@ -0,0 +1,56 @@
+package spdx
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDecode(t *testing.T) {
+	in := testReport()
+	var buf bytes.Buffer
+	if err := (&Encoder{}).Encode(&buf, in); err != nil {
+		t.Fatal(err)
+	}
+	r, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.Hash.String(), in.Hash.String(); got != want {
+		t.Errorf("got hash %q, want %q", got, want)
+	}
+
+	var got []string
+	for _, rec := range r.IndexRecords() {
+		s := rec.Package.Name + "@" + rec.Package.Version
+		if src := rec.Package.Source; src != nil {
+			s += " from " + src.Name + "@" + src.Version
+		}
+		if d := rec.Distribution; d != nil {
+			s += " on " + d.DID + "-" + d.VersionID
+		}
+		if repo := rec.Repository; repo != nil {
+			s += " in " + repo.Name
+		}
+		got = append(got, s)
+	}
+	sort.Strings(got)
+	want := []string{
+		"curl@7.61.1-12.el8 from curl@7.61.1-12.el8 on rhel-8",
+		"libcurl@7.61.1-12.el8 from curl@7.61.1-12.el8 on rhel-8",
+		"org.apache.logging.log4j:log4j-core@2.14.1 in maven",
+	}
+	if !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(got, want))
+	}
+
+	t.Run("Version", func(t *testing.T) {
+		_, err := Decode(strings.NewReader(`{"spdxVersion":"SPDX-3.0"}`))
+		if err == nil {
+			t.Error("expected error")
+		}
+	})
+}
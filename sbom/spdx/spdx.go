@@ -0,0 +1,390 @@
+// Package spdx converts IndexReports to SPDX 2.3 documents.
+//
+// The document describes a single package for the indexed manifest, which
+// contains a package for every distribution and package in the report. Binary
+// packages are related to the source packages they were built from. The
+// specification is at https://spdx.github.io/spdx-spec/v2.3/.
+package spdx
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/sbom"
+)
+
+// Version is the SPDX version produced.
+const Version = `SPDX-2.3`
+
+// DefaultNamespace is the prefix used for document namespaces when the
+// Encoder isn't configured with one.
+const DefaultNamespace = `https://github.com/quay/claircore/spdx/`
+
+// NoAssertion is the value used for required fields with no information.
+const NoAssertion = `NOASSERTION`
+
+// These are the relationship types used in documents.
+const (
+	RelDescribes     = `DESCRIBES`
+	RelContains      = `CONTAINS`
+	RelGeneratedFrom = `GENERATED_FROM`
+)
+
+// These are the package purposes used in documents.
+const (
+	PurposeContainer       = `CONTAINER`
+	PurposeOperatingSystem = `OPERATING-SYSTEM`
+	PurposeLibrary         = `LIBRARY`
+	PurposeSource          = `SOURCE`
+)
+
+// Document is an SPDX document.
+//
+// These types are the subset of the SPDX model the Encoder produces. Field
+// names follow the specification's JSON serialization.
+type Document struct {
+	SPDXVersion       string         `json:"spdxVersion"`
+	DataLicense       string         `json:"dataLicense"`
+	SPDXID            string         `json:"SPDXID"`
+	Name              string         `json:"name"`
+	DocumentNamespace string         `json:"documentNamespace"`
+	CreationInfo      CreationInfo   `json:"creationInfo"`
+	DocumentDescribes []string       `json:"documentDescribes,omitempty"`
+	Packages          []Package      `json:"packages"`
+	Relationships     []Relationship `json:"relationships"`
+}
+
+// CreationInfo records how and when a Document was created.
+type CreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+// Package is an SPDX package.
+type Package struct {
+	SPDXID                string        `json:"SPDXID"`
+	Name                  string        `json:"name"`
+	VersionInfo           string        `json:"versionInfo,omitempty"`
+	DownloadLocation      string        `json:"downloadLocation"`
+	FilesAnalyzed         bool          `json:"filesAnalyzed"`
+	SourceInfo            string        `json:"sourceInfo,omitempty"`
+	PrimaryPackagePurpose string        `json:"primaryPackagePurpose,omitempty"`
+	Checksums             []Checksum    `json:"checksums,omitempty"`
+	ExternalRefs          []ExternalRef `json:"externalRefs,omitempty"`
+}
+
+// Checksum is a digest of a Package.
+type Checksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+// ExternalRef is an identifier for a Package in another system.
+type ExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+// Relationship relates two elements of a Document.
+type Relationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+// Encoder converts IndexReports to SPDX documents.
+//
+// The zero value is ready to use.
+type Encoder struct {
+	// Name is the document name. The manifest digest is used if not set.
+	Name string
+	// Namespace is the document namespace, which must be unique for every
+	// document. If not set, one is constructed from DefaultNamespace, the
+	// manifest digest, and a random UUID.
+	Namespace string
+	// Creators are reported as the document's creators. If not set, the
+	// document is reported as created by the "claircore" tool.
+	Creators []string
+	// Created is reported as the document's creation time. The current time
+	// is used if not set.
+	Created time.Time
+}
+
+// Encode writes the SPDX document for "r" to "w" as JSON.
+func (e *Encoder) Encode(w io.Writer, r *claircore.IndexReport) error {
+	d, err := e.Document(r)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(d); err != nil {
+		return fmt.Errorf("spdx: unable to write document: %w", err)
+	}
+	return nil
+}
+
+// Document returns the SPDX document for "r".
+//
+// Packages and relationships are sorted, so the output is stable for a given
+// Encoder configuration.
+func (e *Encoder) Document(r *claircore.IndexReport) (*Document, error) {
+	hash := r.Hash.String()
+	if hash == "" {
+		return nil, fmt.Errorf("spdx: index report has no manifest digest")
+	}
+	name := e.Name
+	if name == "" {
+		name = hash
+	}
+	ns := e.Namespace
+	if ns == "" {
+		ns = DefaultNamespace + hash + "-" + uuid.New().String()
+	}
+	creators := e.Creators
+	if len(creators) == 0 {
+		creators = []string{"Tool: claircore"}
+	}
+	created := e.Created
+	if created.IsZero() {
+		created = time.Now()
+	}
+
+	b := builder{
+		ids:  make(map[string]struct{}),
+		pkgs: make(map[string]string),
+		seen: make(map[Relationship]struct{}),
+	}
+	root := Package{
+		SPDXID:                b.id("SPDXRef-Manifest-", hash),
+		Name:                  name,
+		DownloadLocation:      NoAssertion,
+		PrimaryPackagePurpose: PurposeContainer,
+	}
+	if algo := r.Hash.Algorithm(); algo != "" {
+		root.Checksums = []Checksum{{
+			Algorithm:     strings.ToUpper(algo),
+			ChecksumValue: hex.EncodeToString(r.Hash.Checksum()),
+		}}
+	}
+	d := &Document{
+		SPDXVersion:       Version,
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              name,
+		DocumentNamespace: ns,
+		CreationInfo: CreationInfo{
+			Created:  created.UTC().Format(time.RFC3339),
+			Creators: creators,
+		},
+		Packages: []Package{root},
+	}
+	b.rel(d.SPDXID, RelDescribes, root.SPDXID)
+
+	for _, id := range sortedKeys(r.Distributions) {
+		dist := r.Distributions[id]
+		if dist == nil {
+			continue
+		}
+		p := Package{
+			SPDXID:                b.id("SPDXRef-Distribution-", id),
+			Name:                  dist.DID,
+			VersionInfo:           dist.VersionID,
+			DownloadLocation:      NoAssertion,
+			PrimaryPackagePurpose: PurposeOperatingSystem,
+		}
+		if p.Name == "" {
+			p.Name = dist.Name
+		}
+		if dist.CPE.Valid() == nil {
+			p.ExternalRefs = append(p.ExternalRefs, cpeRef(dist.CPE.String()))
+		}
+		b.packages = append(b.packages, p)
+		b.rel(root.SPDXID, RelContains, p.SPDXID)
+	}
+
+	for _, id := range sortedKeys(r.Packages) {
+		pkg := r.Packages[id]
+		if pkg == nil {
+			continue
+		}
+		c := sbom.ContextFor(r, id)
+		pid := b.pkg(c, pkg)
+		b.rel(root.SPDXID, RelContains, pid)
+		if src := pkg.Source; src != nil && src.Name != "" {
+			sid := b.pkg(c, src)
+			b.rel(pid, RelGeneratedFrom, sid)
+		}
+	}
+
+	sort.SliceStable(b.packages, func(i, j int) bool {
+		x, y := &b.packages[i], &b.packages[j]
+		switch {
+		case x.Name != y.Name:
+			return x.Name < y.Name
+		case x.VersionInfo != y.VersionInfo:
+			return x.VersionInfo < y.VersionInfo
+		default:
+			return x.SPDXID < y.SPDXID
+		}
+	})
+	d.Packages = append(d.Packages, b.packages...)
+	// The DESCRIBES relationship stays first.
+	rels := b.rels[1:]
+	sort.SliceStable(rels, func(i, j int) bool {
+		x, y := &rels[i], &rels[j]
+		switch {
+		case x.SPDXElementID != y.SPDXElementID:
+			return x.SPDXElementID < y.SPDXElementID
+		case x.RelationshipType != y.RelationshipType:
+			return x.RelationshipType < y.RelationshipType
+		default:
+			return x.RelatedSPDXElement < y.RelatedSPDXElement
+		}
+	})
+	d.Relationships = b.rels
+	return d, nil
+}
+
+// Builder accumulates the packages and relationships of a Document.
+type builder struct {
+	// Ids is the set of SPDX IDs in use.
+	ids map[string]struct{}
+	// Pkgs maps package keys to SPDX IDs, so source packages shared by
+	// multiple binary packages are only added once.
+	pkgs     map[string]string
+	packages []Package
+	rels     []Relationship
+	seen     map[Relationship]struct{}
+}
+
+// Id returns a new SPDX ID made of "prefix" and the sanitized "v".
+//
+// SPDX IDs may only contain letters, numbers, "." and "-", so other
+// characters are replaced with "-". A counter is appended if the result is
+// already in use.
+func (b *builder) id(prefix, v string) string {
+	id := prefix + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-':
+			return r
+		}
+		return '-'
+	}, v)
+	out := id
+	for i := 2; ; i++ {
+		if _, ok := b.ids[out]; !ok {
+			break
+		}
+		out = fmt.Sprintf("%s-%d", id, i)
+	}
+	b.ids[out] = struct{}{}
+	return out
+}
+
+// Rel adds a relationship, if it's not already present.
+func (b *builder) rel(from, typ, to string) {
+	r := Relationship{SPDXElementID: from, RelationshipType: typ, RelatedSPDXElement: to}
+	if _, ok := b.seen[r]; ok {
+		return
+	}
+	b.seen[r] = struct{}{}
+	b.rels = append(b.rels, r)
+}
+
+// Pkg adds the package "p", found in the Context "c", returning its SPDX ID.
+// A package that's already been added isn't added again.
+func (b *builder) pkg(c *sbom.Context, p *claircore.Package) string {
+	key := p.ID
+	if key == "" {
+		key = p.Kind + "/" + p.Name + "@" + p.Version
+	}
+	if id, ok := b.pkgs[key]; ok {
+		return id
+	}
+	idv := p.ID
+	if idv == "" {
+		idv = p.Name + "-" + p.Version
+	}
+	out := Package{
+		SPDXID:                b.id("SPDXRef-Package-", idv),
+		Name:                  p.Name,
+		VersionInfo:           p.Version,
+		DownloadLocation:      NoAssertion,
+		PrimaryPackagePurpose: PurposeLibrary,
+	}
+	if p.Kind == claircore.SOURCE {
+		out.PrimaryPackagePurpose = PurposeSource
+	} else {
+		out.SourceInfo = sourceInfo(c, p)
+	}
+	if u := sbom.PURL(c, p); u != nil {
+		out.ExternalRefs = append(out.ExternalRefs, ExternalRef{
+			ReferenceCategory: "PACKAGE-MANAGER",
+			ReferenceType:     "purl",
+			ReferenceLocator:  u.String(),
+		})
+	}
+	if p.CPE.Valid() == nil {
+		out.ExternalRefs = append(out.ExternalRefs, cpeRef(p.CPE.String()))
+	}
+	b.pkgs[key] = out.SPDXID
+	b.packages = append(b.packages, out)
+	return out.SPDXID
+}
+
+// SourceInfo describes where the package "p" was found: its package
+// database, file, repositories, and layers.
+//
+// SPDX files require checksums, which claircore doesn't record, so file
+// locations are reported here instead of as file elements.
+func sourceInfo(c *sbom.Context, p *claircore.Package) string {
+	var parts []string
+	dbs := c.PackageDBs
+	if len(dbs) == 0 && p.PackageDB != "" {
+		dbs = []string{p.PackageDB}
+	}
+	for _, db := range dbs {
+		parts = append(parts, "package database: "+db)
+	}
+	if p.Filepath != "" {
+		parts = append(parts, "file: "+p.Filepath)
+	}
+	for _, repo := range c.Repositories {
+		s := "repository: " + repo.Name
+		if repo.URI != "" {
+			s += " <" + repo.URI + ">"
+		}
+		parts = append(parts, s)
+	}
+	for _, l := range c.Layers {
+		parts = append(parts, "layer: "+l.String())
+	}
+	return strings.Join(parts, "; ")
+}
+
+func cpeRef(cpe string) ExternalRef {
+	return ExternalRef{
+		ReferenceCategory: "SECURITY",
+		ReferenceType:     "cpe23Type",
+		ReferenceLocator:  cpe,
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	ks := make([]string, 0, len(m))
+	for k := range m {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return ks
+}
@@ -0,0 +1,167 @@
+package spdx
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/pkg/cpe"
+)
+
+func testReport() *claircore.IndexReport {
+	src := &claircore.Package{ID: "10", Name: "curl", Version: "7.61.1-12.el8", Kind: claircore.SOURCE}
+	return &claircore.IndexReport{
+		Hash:  claircore.MustParseDigest("sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"),
+		State: "IndexFinished",
+		Distributions: map[string]*claircore.Distribution{
+			"1": {ID: "1", DID: "rhel", Name: "Red Hat Enterprise Linux", VersionID: "8", CPE: cpe.MustUnbind("cpe:/o:redhat:enterprise_linux:8::baseos")},
+		},
+		Repositories: map[string]*claircore.Repository{
+			"2": {ID: "2", Name: "cpe:/o:redhat:enterprise_linux:8::baseos", Key: "rhel-cpe-repository"},
+			"3": {ID: "3", Name: "maven", URI: "https://repo1.maven.apache.org/maven2"},
+		},
+		Packages: map[string]*claircore.Package{
+			"4": {ID: "4", Name: "curl", Version: "7.61.1-12.el8", Kind: claircore.BINARY, Arch: "x86_64", Source: src, PackageDB: "sqlite:var/lib/rpm"},
+			"5": {ID: "5", Name: "libcurl", Version: "7.61.1-12.el8", Kind: claircore.BINARY, Arch: "x86_64", Source: src, PackageDB: "sqlite:var/lib/rpm"},
+			"6": {ID: "6", Name: "org.apache.logging.log4j:log4j-core", Version: "2.14.1", Kind: claircore.BINARY, PackageDB: "maven:opt/app.jar", Filepath: "opt/app.jar"},
+		},
+		Environments: map[string][]*claircore.Environment{
+			"4": {{PackageDB: "sqlite:var/lib/rpm", DistributionID: "1", RepositoryIDs: []string{"2"}}},
+			"5": {{PackageDB: "sqlite:var/lib/rpm", DistributionID: "1", RepositoryIDs: []string{"2"}}},
+			"6": {{PackageDB: "maven:opt/app.jar", RepositoryIDs: []string{"3"}}},
+		},
+		Success: true,
+	}
+}
+
+func TestDocument(t *testing.T) {
+	e := Encoder{
+		Namespace: "https://example.com/spdx/test",
+		Created:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	d, err := e.Document(testReport())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := d.CreationInfo.Created, "2024-01-01T00:00:00Z"; got != want {
+		t.Errorf("got created %q, want %q", got, want)
+	}
+
+	type pkg struct{ ID, Name, Purpose, PURL string }
+	var got []pkg
+	for _, p := range d.Packages {
+		var u string
+		for _, r := range p.ExternalRefs {
+			if r.ReferenceType == "purl" {
+				u = r.ReferenceLocator
+			}
+		}
+		got = append(got, pkg{p.SPDXID, p.Name, p.PrimaryPackagePurpose, u})
+	}
+	want := []pkg{
+		{"SPDXRef-Manifest-sha256-0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", PurposeContainer, ""},
+		{"SPDXRef-Package-10", "curl", PurposeSource, "pkg:rpm/redhat/curl@7.61.1-12.el8?arch=src&distro=rhel-8"},
+		{"SPDXRef-Package-4", "curl", PurposeLibrary, "pkg:rpm/redhat/curl@7.61.1-12.el8?arch=x86_64&distro=rhel-8"},
+		{"SPDXRef-Package-5", "libcurl", PurposeLibrary, "pkg:rpm/redhat/libcurl@7.61.1-12.el8?arch=x86_64&distro=rhel-8"},
+		{"SPDXRef-Package-6", "org.apache.logging.log4j:log4j-core", PurposeLibrary, "pkg:maven/org.apache.logging.log4j/log4j-core@2.14.1"},
+		{"SPDXRef-Distribution-1", "rhel", PurposeOperatingSystem, ""},
+	}
+	if !cmp.Equal(got, want) {
+		t.Error(cmp.Diff(got, want))
+	}
+
+	root := want[0].ID
+	wantRels := []Relationship{
+		{"SPDXRef-DOCUMENT", RelDescribes, root},
+		{root, RelContains, "SPDXRef-Distribution-1"},
+		{root, RelContains, "SPDXRef-Package-4"},
+		{root, RelContains, "SPDXRef-Package-5"},
+		{root, RelContains, "SPDXRef-Package-6"},
+		{"SPDXRef-Package-4", RelGeneratedFrom, "SPDXRef-Package-10"},
+		{"SPDXRef-Package-5", RelGeneratedFrom, "SPDXRef-Package-10"},
+	}
+	if !cmp.Equal(d.Relationships, wantRels) {
+		t.Error(cmp.Diff(d.Relationships, wantRels))
+	}
+
+	for _, p := range d.Packages {
+		switch p.SPDXID {
+		case "SPDXRef-Package-6":
+			want := "package database: maven:opt/app.jar; file: opt/app.jar; repository: maven <https://repo1.maven.apache.org/maven2>"
+			if got := p.SourceInfo; got != want {
+				t.Errorf("got sourceInfo %q, want %q", got, want)
+			}
+		case "SPDXRef-Distribution-1":
+			if len(p.ExternalRefs) != 1 || p.ExternalRefs[0].ReferenceType != "cpe23Type" {
+				t.Errorf("unexpected external refs: %+v", p.ExternalRefs)
+			}
+		case root:
+			if len(p.Checksums) != 1 || p.Checksums[0].Algorithm != "SHA256" {
+				t.Errorf("unexpected checksums: %+v", p.Checksums)
+			}
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	e := Encoder{
+		Namespace: "https://example.com/spdx/test",
+		Created:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	var buf bytes.Buffer
+	if err := e.Encode(&buf, testReport()); err != nil {
+		t.Fatal(err)
+	}
+	t.Log(buf.String())
+	var doc map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"spdxVersion", "dataLicense", "SPDXID", "name", "documentNamespace", "creationInfo", "packages"} {
+		if _, ok := doc[k]; !ok {
+			t.Errorf("missing required field %q", k)
+		}
+	}
+	for _, p := range doc["packages"].([]any) {
+		p := p.(map[string]any)
+		if _, ok := p["downloadLocation"]; !ok {
+			t.Errorf("%v: missing downloadLocation", p["SPDXID"])
+		}
+		if fa, ok := p["filesAnalyzed"]; !ok || fa != false {
+			t.Errorf("%v: bad filesAnalyzed: %v", p["SPDXID"], fa)
+		}
+	}
+
+	// Output should be stable.
+	var again bytes.Buffer
+	if err := e.Encode(&again, testReport()); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), again.Bytes()) {
+		t.Error("output differs between runs")
+	}
+
+	t.Run("NoDigest", func(t *testing.T) {
+		if err := e.Encode(&bytes.Buffer{}, &claircore.IndexReport{}); err == nil {
+			t.Error("expected error")
+		}
+	})
+	t.Run("Namespace", func(t *testing.T) {
+		var e Encoder
+		a, err := e.Document(testReport())
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := e.Document(testReport())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.DocumentNamespace == b.DocumentNamespace {
+			t.Errorf("namespace reused: %q", a.DocumentNamespace)
+		}
+	})
+}
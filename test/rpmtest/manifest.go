@@ -74,6 +74,7 @@ var Options = cmp.Options{
 	HintCompare,
 	EpochCompare,
 	IgnorePackageDB,
+	IgnoreFiles,
 	SortPackages,
 	ModuleCompare,
 }
@@ -146,4 +147,6 @@ var (
 		return a.Name < b.Name
 	})
 	IgnorePackageDB = cmpopts.IgnoreFields(claircore.Package{}, ".PackageDB")
+	// RPM manifests don't list package files.
+	IgnoreFiles = cmpopts.IgnoreFields(claircore.Package{}, ".Files")
 )
@@ -24,7 +24,17 @@ func GenUniqueVulnerabilities(n int, updater string) []*claircore.Vulnerability
 			Links:              fmt.Sprintf("test-vuln-links-%d", i),
 			Severity:           fmt.Sprintf("test-severity-%d", i),
 			NormalizedSeverity: claircore.Unknown,
-			ArchOperation:      claircore.OpEquals,
+			CVSSv3Vector:       fmt.Sprintf("CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:%d", i),
+			CVSSv3Score:        float64(i%100) / 10,
+			CVSS: []claircore.CVSS{{
+				Version:       "3.1",
+				Vector:        fmt.Sprintf("CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:%d", i),
+				Score:         float64(i%100) / 10,
+				Authoritative: true,
+			}},
+			AffectedCPEs:  []string{fmt.Sprintf("cpe:/o:test:product:%d", i)},
+			FixState:      claircore.FixStateFixed,
+			ArchOperation: claircore.OpEquals,
 			Package: &claircore.Package{
 				ID:      strconv.Itoa(i),
 				Name:    fmt.Sprintf("package-%d", i),
@@ -1,2 +1,5 @@
 // Package cpe provides for handling Common Platform Enumeration (CPE) names.
+//
+// Names can be compared according to the CPE Name Matching specification with
+// Compare, IsSuperset, and Match.
 package cpe
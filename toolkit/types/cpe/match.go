@@ -0,0 +1,232 @@
+package cpe
+
+import "strings"
+
+// Relation is the result of comparing a pair of attribute values, as defined
+// by the CPE Name Matching specification:
+// https://nvlpubs.nist.gov/nistpubs/Legacy/IR/nistir7696.pdf
+type Relation uint
+
+// These are the possible Relations between a source and target value.
+const (
+	// Disjoint means the values have no overlap.
+	Disjoint Relation = iota
+	// Subset means the source value is a proper subset of the target value.
+	Subset
+	// Superset means the source value is a proper superset of the target
+	// value.
+	Superset
+	// Equal means the values are the same.
+	Equal
+	// Undefined means the values cannot be compared, such as when the target
+	// contains wildcards.
+	Undefined
+)
+
+// Relations are the Relations of every attribute of a pair of WFNs, indexed by
+// Attribute.
+type Relations [NumAttr]Relation
+
+// Compare compares every attribute of the "src" and "tgt" WFNs, as specified in
+// section 6.2 of NIST IR 7696.
+//
+// Unset attributes are treated as ANY. String values are compared
+// case-insensitively, and unquoted "*" and "?" characters in the source are
+// expanded as wildcards. If the target contains wildcards, the relation is
+// Undefined.
+func Compare(src, tgt WFN) Relations {
+	var r Relations
+	for i := 0; i < NumAttr; i++ {
+		r[i] = compareValues(&src.Attr[i], &tgt.Attr[i])
+	}
+	return r
+}
+
+// IsDisjoint reports whether the names are disjoint: whether any attribute is
+// Disjoint.
+func (r Relations) IsDisjoint() bool {
+	for _, a := range r {
+		if a == Disjoint {
+			return true
+		}
+	}
+	return false
+}
+
+// IsEqual reports whether the names are equal: whether every attribute is
+// Equal.
+func (r Relations) IsEqual() bool {
+	for _, a := range r {
+		if a != Equal {
+			return false
+		}
+	}
+	return true
+}
+
+// IsSubset reports whether the source name is a (non-proper) subset of the
+// target: whether every attribute is Subset or Equal.
+func (r Relations) IsSubset() bool {
+	for _, a := range r {
+		if a != Subset && a != Equal {
+			return false
+		}
+	}
+	return true
+}
+
+// IsSuperset reports whether the source name is a (non-proper) superset of the
+// target: whether every attribute is Superset or Equal.
+func (r Relations) IsSuperset() bool {
+	for _, a := range r {
+		if a != Superset && a != Equal {
+			return false
+		}
+	}
+	return true
+}
+
+// IsSuperset reports whether "a" matches every name that "b" does, as defined
+// by the CPE_SUPERSET function of NIST IR 7696.
+//
+// This is the usual way to check if a name with wildcards or ANY values, such
+// as one from an advisory, matches a concrete name.
+func IsSuperset(a, b WFN) bool {
+	return Compare(a, b).IsSuperset()
+}
+
+// Match returns the candidates that "w" is a superset of, in their original
+// order.
+func Match(w WFN, candidates []WFN) []WFN {
+	var out []WFN
+	for _, c := range candidates {
+		if IsSuperset(w, c) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// CompareValues implements the "compare" function from section 7.2 of NIST IR
+// 7696.
+func compareValues(src, tgt *Value) Relation {
+	sk, tk := src.Kind, tgt.Kind
+	// Missing attributes are ANY.
+	if sk == ValueUnset {
+		sk = ValueAny
+	}
+	if tk == ValueUnset {
+		tk = ValueAny
+	}
+	var s, t string
+	if sk == ValueSet {
+		s = strings.ToLower(src.V)
+	}
+	if tk == ValueSet {
+		t = strings.ToLower(tgt.V)
+		if hasWildcards(t) {
+			return Undefined
+		}
+	}
+	switch {
+	case sk == tk && s == t:
+		return Equal
+	case sk == ValueAny:
+		return Superset
+	case tk == ValueAny:
+		return Subset
+	case sk == ValueNA || tk == ValueNA:
+		return Disjoint
+	}
+	return compareStrings(s, t)
+}
+
+// CompareStrings implements the "compareStrings" function from section 7.2 of
+// NIST IR 7696. Both strings are quoted as in a WFN, and "tgt" must not contain
+// wildcards.
+//
+// The result is either Superset or Disjoint.
+func compareStrings(src, tgt string) Relation {
+	start, end := 0, len(src)
+	// The number of characters the source can match before and after the
+	// literal part, or -1 for any number.
+	begins, ends := 0, 0
+	if src[0] == '*' {
+		start = 1
+		begins = -1
+	} else {
+		for start < len(src) && src[start] == '?' {
+			start++
+			begins++
+		}
+	}
+	if end > start && src[end-1] == '*' && unquoted(src, end-1) {
+		end--
+		ends = -1
+	} else {
+		for end > start && src[end-1] == '?' && unquoted(src, end-1) {
+			end--
+			ends++
+		}
+	}
+	lit := src[start:end]
+
+	// The specification's version of this loop miscounts quoted characters
+	// in the literal, so this counts characters in the target directly.
+	for index := 0; index <= len(tgt); index++ {
+		i := strings.Index(tgt[index:], lit)
+		if i == -1 {
+			break
+		}
+		index += i
+		if !unquoted(tgt, index) {
+			// Matched in the middle of a quoted character.
+			continue
+		}
+		if before := index - countEscapes(tgt[:index]); begins != -1 && before > begins {
+			break
+		}
+		rest := tgt[index+len(lit):]
+		if after := len(rest) - countEscapes(rest); ends != -1 && after > ends {
+			continue
+		}
+		return Superset
+	}
+	return Disjoint
+}
+
+// HasWildcards reports whether the quoted string "s" contains an unquoted "*"
+// or "?".
+func hasWildcards(s string) bool {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '*', '?':
+			return true
+		}
+	}
+	return false
+}
+
+// Unquoted reports whether the character at index "i" of "s" is not quoted,
+// which is the case if it's preceded by an even number of backslashes.
+func unquoted(s string, i int) bool {
+	n := 0
+	for i--; i >= 0 && s[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 0
+}
+
+// CountEscapes returns the number of quoting backslashes in "s".
+func countEscapes(s string) int {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			n++
+			i++
+		}
+	}
+	return n
+}
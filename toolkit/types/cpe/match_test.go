@@ -0,0 +1,210 @@
+package cpe
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCompareValues(t *testing.T) {
+	t.Parallel()
+	var (
+		anyV = Value{Kind: ValueAny}
+		naV  = Value{Kind: ValueNA}
+		set  = func(s string) Value { return Value{Kind: ValueSet, V: s} }
+	)
+	// The first 16 cases are the rows of Table 6-2 of NIST IR 7696, in order.
+	tt := []struct {
+		Src, Tgt Value
+		Want     Relation
+	}{
+		{anyV, anyV, Equal},
+		{anyV, naV, Superset},
+		{anyV, set("i"), Superset},
+		{anyV, set("m*"), Undefined},
+		{naV, anyV, Subset},
+		{naV, naV, Equal},
+		{naV, set("i"), Disjoint},
+		{naV, set("m*"), Undefined},
+		{set("i"), anyV, Subset},
+		{set("i"), naV, Disjoint},
+		{set("i"), set("i"), Equal},
+		{set("i"), set("k"), Disjoint},
+		{set("m*"), set("m2"), Superset},
+		{set("m*"), anyV, Subset},
+		{set("m*"), naV, Disjoint},
+		{set("m*"), set("m2*"), Undefined},
+
+		// Unset is ANY.
+		{Value{}, set("i"), Superset},
+		{set("i"), Value{}, Subset},
+		// Case doesn't matter.
+		{set("Microsoft"), set("microsoft"), Equal},
+		// Wildcards.
+		{set(`8\.*`), set(`8\.0\.6001`), Superset},
+		{set(`8\.*`), set(`9\.0`), Disjoint},
+		{set(`*\.0`), set(`8\.0`), Superset},
+		{set(`*soft*`), set(`microsoft_office`), Superset},
+		{set(`*soft*`), set(`microsoft`), Superset},
+		{set(`*soft*`), set(`micro`), Disjoint},
+		{set(`sp?`), set(`sp1`), Superset},
+		{set(`sp?`), set(`sp`), Superset},
+		{set(`sp?`), set(`sp12`), Disjoint},
+		{set(`sp??`), set(`sp12`), Superset},
+		{set(`?p1`), set(`sp1`), Superset},
+		{set(`?p1`), set(`xsp1`), Disjoint},
+		{set(`??`), set(`ab`), Superset},
+		{set(`??`), set(`abc`), Disjoint},
+		{set(`8\.??`), set(`8\.0`), Superset},
+		{set(`8\.??`), set(`8\.0\.1`), Disjoint},
+		// Quoted characters count once.
+		{set(`?\.0`), set(`8\.0`), Superset},
+		{set(`1\.?`), set(`1\.\*`), Superset},
+		{set(`1\.?`), set(`1\.\*\*`), Disjoint},
+		// Quoted wildcards are literals.
+		{set(`a\*`), set(`a\*`), Equal},
+		{set(`a\*`), set(`ab`), Disjoint},
+		{set(`a\?`), set(`ab`), Disjoint},
+	}
+	for _, tc := range tt {
+		if got, want := compareValues(&tc.Src, &tc.Tgt), tc.Want; got != want {
+			t.Errorf("%#v, %#v: got: %v, want: %v", tc.Src, tc.Tgt, got, want)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	t.Parallel()
+	tt := []struct {
+		Name     string
+		Src, Tgt string
+		Want     Relations
+		Disjoint bool
+		Equal    bool
+		Subset   bool
+		Superset bool
+	}{
+		{
+			Name: "Wildcard",
+			Src:  `cpe:2.3:a:microsoft:internet_explorer:8.*:sp?:*:*:*:*:*:*`,
+			Tgt:  `cpe:2.3:a:microsoft:internet_explorer:8.0.6001:beta:*:*:*:*:*:*`,
+			Want: Relations{
+				Equal, Equal, Equal, Superset, Disjoint,
+				Equal, Equal, Equal, Equal, Equal, Equal,
+			},
+			Disjoint: true,
+		},
+		{
+			Name: "Superset",
+			Src:  `cpe:2.3:a:microsoft:internet_explorer:8.*:sp?:*:*:*:*:*:*`,
+			Tgt:  `cpe:2.3:a:microsoft:internet_explorer:8.0.6001:sp1:*:*:*:*:*:*`,
+			Want: Relations{
+				Equal, Equal, Equal, Superset, Superset,
+				Equal, Equal, Equal, Equal, Equal, Equal,
+			},
+			Superset: true,
+		},
+		{
+			Name: "Subset",
+			Src:  `cpe:2.3:a:microsoft:internet_explorer:8.0.6001:sp1:-:*:*:*:*:*`,
+			Tgt:  `cpe:2.3:a:microsoft:internet_explorer:*:*:*:*:*:*:*:*`,
+			Want: Relations{
+				Equal, Equal, Equal, Subset, Subset,
+				Subset, Equal, Equal, Equal, Equal, Equal,
+			},
+			Subset: true,
+		},
+		{
+			Name: "TargetWildcard",
+			Src:  `cpe:2.3:a:microsoft:internet_explorer:8.0.6001:*:*:*:*:*:*:*`,
+			Tgt:  `cpe:2.3:a:microsoft:internet_explorer:8.*:*:*:*:*:*:*:*`,
+			Want: Relations{
+				Equal, Equal, Equal, Undefined, Equal,
+				Equal, Equal, Equal, Equal, Equal, Equal,
+			},
+		},
+		{
+			Name: "Equal",
+			Src:  `cpe:/o:redhat:enterprise_linux:8::baseos`,
+			Tgt:  `cpe:2.3:o:redhat:enterprise_linux:8:*:baseos:*:*:*:*:*`,
+			Want: Relations{
+				Equal, Equal, Equal, Equal, Equal,
+				Equal, Equal, Equal, Equal, Equal, Equal,
+			},
+			Equal:    true,
+			Subset:   true,
+			Superset: true,
+		},
+		{
+			Name: "URIPrefix",
+			Src:  `cpe:/o:redhat:enterprise_linux`,
+			Tgt:  `cpe:/o:redhat:enterprise_linux:8::baseos`,
+			Want: Relations{
+				Equal, Equal, Equal, Superset, Equal,
+				Superset, Equal, Equal, Equal, Equal, Equal,
+			},
+			Superset: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			got := Compare(MustUnbind(tc.Src), MustUnbind(tc.Tgt))
+			if !cmp.Equal(got, tc.Want) {
+				t.Error(cmp.Diff(got, tc.Want))
+			}
+			for _, c := range []struct {
+				Name      string
+				Got, Want bool
+			}{
+				{"IsDisjoint", got.IsDisjoint(), tc.Disjoint},
+				{"IsEqual", got.IsEqual(), tc.Equal},
+				{"IsSubset", got.IsSubset(), tc.Subset},
+				{"IsSuperset", got.IsSuperset(), tc.Superset},
+			} {
+				if c.Got != c.Want {
+					t.Errorf("%s: got: %v, want: %v", c.Name, c.Got, c.Want)
+				}
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	t.Parallel()
+	cs := []WFN{
+		MustUnbind(`cpe:/o:redhat:enterprise_linux:8::baseos`),
+		MustUnbind(`cpe:/a:redhat:enterprise_linux:8::appstream`),
+		MustUnbind(`cpe:/o:redhat:enterprise_linux:9::baseos`),
+		MustUnbind(`cpe:/o:redhat:rhel_eus:8.6::baseos`),
+	}
+	tt := []struct {
+		In   string
+		Want []WFN
+	}{
+		{
+			In:   `cpe:2.3:o:redhat:enterprise_linux:8:*:*:*:*:*:*:*`,
+			Want: cs[:1],
+		},
+		{
+			In:   `cpe:/:redhat:enterprise_linux:8`,
+			Want: cs[:2],
+		},
+		{
+			In:   `cpe:2.3:o:redhat:*:*:*:baseos:*:*:*:*:*`,
+			Want: []WFN{cs[0], cs[2], cs[3]},
+		},
+		{
+			In:   `cpe:2.3:o:redhat:rhel_eus:8.?:*:*:*:*:*:*:*`,
+			Want: cs[3:],
+		},
+		{
+			In: `cpe:2.3:o:redhat:enterprise_linux:7:*:*:*:*:*:*:*`,
+		},
+	}
+	for _, tc := range tt {
+		got := Match(MustUnbind(tc.In), cs)
+		if !cmp.Equal(got, tc.Want) {
+			t.Errorf("%s: %s", tc.In, cmp.Diff(got, tc.Want))
+		}
+	}
+}
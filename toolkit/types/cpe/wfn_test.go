@@ -410,6 +410,7 @@ func TestDictionary(t *testing.T) {
 	if err := xml.NewDecoder(gz).Decode(&l); err != nil {
 		t.Error(err)
 	}
+	all := MustUnbind(`cpe:2.3:*:*:*:*:*:*:*:*:*:*:*`)
 	for _, i := range l.Items {
 		n := i.Name
 		wfn, err := UnbindURI(n)
@@ -434,6 +435,15 @@ func TestDictionary(t *testing.T) {
 			t.Logf("wfn: %#v", wfn)
 			t.FailNow()
 		}
+
+		// Every name should be equal to itself, and matched by a name of all
+		// ANY values.
+		if r := Compare(wfn, wfn); !r.IsEqual() {
+			t.Fatalf("%s: not equal to itself: %v", n, r)
+		}
+		if !IsSuperset(all, wfn) {
+			t.Fatalf("%s: not matched by %s", n, all)
+		}
 	}
 }
 
@@ -28,6 +28,7 @@ const (
 var (
 	_ indexer.DistributionScanner = (*DistributionScanner)(nil)
 	_ indexer.VersionedScanner    = (*DistributionScanner)(nil)
+	_ indexer.PathScanner         = (*DistributionScanner)(nil)
 )
 
 // DistributionScanner implements [indexer.DistributionScanner] looking for Ubuntu distributions.
@@ -42,6 +43,13 @@ func (*DistributionScanner) Version() string { return scannerVersion }
 // Kind implements [scanner.VersionedScanner].
 func (*DistributionScanner) Kind() string { return scannerKind }
 
+// Paths implements [indexer.PathScanner].
+func (*DistributionScanner) Paths() []string {
+	// The os-release file is commonly a symlink to the documented fallback
+	// location.
+	return []string{lsbReleasePath, osReleasePath, `usr/lib/os-release`}
+}
+
 // Scan implements [indexer.DistributionScanner].
 func (ds *DistributionScanner) Scan(ctx context.Context, l *claircore.Layer) ([]*claircore.Distribution, error) {
 	defer trace.StartRegion(ctx, "Scanner.Scan").End()
@@ -12,6 +12,11 @@ import (
 	"github.com/quay/claircore/aws"
 	"github.com/quay/claircore/debian"
 	"github.com/quay/claircore/enricher/cvss"
+	"github.com/quay/claircore/enricher/epss"
+	"github.com/quay/claircore/enricher/exploit"
+	"github.com/quay/claircore/enricher/ghsa"
+	"github.com/quay/claircore/enricher/kev"
+	"github.com/quay/claircore/enricher/rhelcvss"
 	"github.com/quay/claircore/libvuln/driver"
 	"github.com/quay/claircore/oracle"
 	"github.com/quay/claircore/photon"
@@ -73,5 +78,25 @@ func inner(ctx context.Context) error {
 	cvssSet.Add(&cvss.Enricher{})
 	updater.Register("clair.cvss", driver.StaticSet(cvssSet))
 
+	rhcvssSet := driver.NewUpdaterSet()
+	rhcvssSet.Add(&rhelcvss.Enricher{})
+	updater.Register("rhel.cvss", driver.StaticSet(rhcvssSet))
+
+	epssSet := driver.NewUpdaterSet()
+	epssSet.Add(&epss.Enricher{})
+	updater.Register("clair.epss", driver.StaticSet(epssSet))
+
+	kevSet := driver.NewUpdaterSet()
+	kevSet.Add(&kev.Enricher{})
+	updater.Register("cisa.kev", driver.StaticSet(kevSet))
+
+	exploitSet := driver.NewUpdaterSet()
+	exploitSet.Add(&exploit.Enricher{})
+	updater.Register("clair.exploit", driver.StaticSet(exploitSet))
+
+	ghsaSet := driver.NewUpdaterSet()
+	ghsaSet.Add(&ghsa.Enricher{})
+	updater.Register("github.ghsa", driver.StaticSet(ghsaSet))
+
 	return nil
 }
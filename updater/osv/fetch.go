@@ -0,0 +1,98 @@
+package osv
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/pkg/tmp"
+)
+
+// Fetch downloads the OSV data at "uri" and returns the vulnerabilities it
+// describes, using the same mapping as the updaters in this package.
+//
+// The data may be a bulk download zip file, like the "all.zip" files provided
+// by the OSV project, a single OSV record, or a JSON array of records.
+// The "ecosystem" argument is used to name the Repository of the returned
+// vulnerabilities, just like the ecosystem of an updater.
+func Fetch(ctx context.Context, c *http.Client, ecosystem, uri string) ([]*claircore.Vulnerability, error) {
+	ctx = zlog.ContextWithValues(ctx, "component", "updater/osv/Fetch")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, fmt.Errorf("osv: martian request: %w", err)
+	}
+	req.Header.Set(`accept`, `application/zip, application/json`)
+	res, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("osv: unexpected response from %q: %v", res.Request.URL.String(), res.Status)
+	}
+	tf, err := tmp.NewFile("", "osv.fetch.*")
+	if err != nil {
+		return nil, err
+	}
+	defer tf.Close()
+	sz, err := io.Copy(tf, res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("osv: unable to read response: %w", err)
+	}
+	if _, err := tf.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var magic [4]byte
+	n, _ := io.ReadFull(tf, magic[:])
+	if _, err := tf.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	name := strings.ToLower(ecosystem)
+	ecs := newECS("osv/" + name)
+	now := time.Now()
+	switch {
+	case bytes.HasPrefix(magic[:n], []byte("PK\x03\x04")), bytes.HasPrefix(magic[:n], []byte("PK\x05\x06")):
+		z, err := zip.NewReader(tf, sz)
+		if err != nil {
+			return nil, fmt.Errorf("osv: unable to open zip: %w", err)
+		}
+		if err := ecs.InsertZip(ctx, name, now, z); err != nil {
+			return nil, err
+		}
+	default:
+		var as []advisory
+		dec := json.NewDecoder(tf)
+		if bytes.HasPrefix(bytes.TrimLeft(magic[:n], " \t\r\n"), []byte("[")) {
+			err = dec.Decode(&as)
+		} else {
+			as = make([]advisory, 1)
+			err = dec.Decode(&as[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("osv: unable to decode records: %w", err)
+		}
+		var skipped stats
+		for i := range as {
+			a := &as[i]
+			ctx := zlog.ContextWithValues(ctx, "advisory", a.ID)
+			if err := ecs.InsertAdvisory(ctx, &skipped, name, now, a); err != nil {
+				return nil, err
+			}
+		}
+		skipped.Log(ctx)
+	}
+	zlog.Debug(ctx).
+		Int("count", ecs.Len()).
+		Msg("found vulnerabilities")
+	return ecs.Finalize(), nil
+}
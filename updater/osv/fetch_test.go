@@ -0,0 +1,125 @@
+package osv
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/quay/zlog"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/test/integration"
+)
+
+func TestFetchRecords(t *testing.T) {
+	ctx := zlog.Test(context.Background(), t)
+	recs := []string{"GO-2022-0969.json", "GHSA-jfh8-c2jp-5v3q.json"}
+	var zipBuf, arrBuf bytes.Buffer
+	z := zip.NewWriter(&zipBuf)
+	arrBuf.WriteByte('[')
+	for i, n := range recs {
+		b, err := os.ReadFile(filepath.Join("testdata", n))
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, err := z.Create(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(b); err != nil {
+			t.Fatal(err)
+		}
+		if i != 0 {
+			arrBuf.WriteByte(',')
+		}
+		arrBuf.Write(b)
+	}
+	arrBuf.WriteByte(']')
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/record/", http.StripPrefix("/record/", http.FileServer(http.Dir("testdata"))))
+	mux.HandleFunc("/all.zip", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write(zipBuf.Bytes())
+	})
+	mux.HandleFunc("/all.json", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write(arrBuf.Bytes())
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	tcs := []struct {
+		Name, Path string
+		Want       int
+	}{
+		{Name: "Go", Path: "/record/GO-2022-0969.json", Want: 2},
+		{Name: "GHSA", Path: "/record/GHSA-jfh8-c2jp-5v3q.json", Want: 2},
+		{Name: "Zip", Path: "/all.zip", Want: 4},
+		{Name: "Array", Path: "/all.json", Want: 4},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctx := zlog.Test(ctx, t)
+			vs, err := Fetch(ctx, srv.Client(), "Test", srv.URL+tc.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := len(vs), tc.Want; got != want {
+				t.Errorf("got: %d vulnerabilities, want: %d", got, want)
+			}
+			for _, v := range vs {
+				if got, want := v.Updater, "osv/test"; got != want {
+					t.Errorf("%s: got updater %q, want %q", v.Name, got, want)
+				}
+				if got, want := v.Repo.Name, "test"; got != want {
+					t.Errorf("%s: got repo %q, want %q", v.Name, got, want)
+				}
+				if v.Name != "GHSA-jfh8-c2jp-5v3q" {
+					continue
+				}
+				if got, want := v.NormalizedSeverity, claircore.Critical; got != want {
+					t.Errorf("%s: got severity %v, want %v", v.Name, got, want)
+				}
+				if got, want := v.CVSSv3Score, 10.0; got != want {
+					t.Errorf("%s: got CVSSv3 score %v, want %v", v.Name, got, want)
+				}
+			}
+		})
+	}
+
+	if _, err := Fetch(ctx, srv.Client(), "Test", srv.URL+"/nope"); err == nil {
+		t.Error("expected error for missing record")
+	}
+}
+
+func TestFetchLive(t *testing.T) {
+	integration.Skip(t)
+	ctx := zlog.Test(context.Background(), t)
+	tcs := []struct {
+		Name, Ecosystem, URL string
+	}{
+		{Name: "GoVulnDB", Ecosystem: "Go", URL: "https://vuln.go.dev/ID/GO-2022-0969.json"},
+		{Name: "GitHub", Ecosystem: "Maven", URL: "https://api.osv.dev/v1/vulns/GHSA-jfh8-c2jp-5v3q"},
+		{Name: "GoBulk", Ecosystem: "Go", URL: DefaultURL + "Go/all.zip"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctx := zlog.Test(ctx, t)
+			// Use of http.DefaultClient guarded by integration.Skip call.
+			vs, err := Fetch(ctx, http.DefaultClient, tc.Ecosystem, tc.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Logf("found %d vulnerabilities", len(vs))
+			if len(vs) == 0 {
+				t.Error("expected more than 0 vulnerabilities")
+			}
+		})
+	}
+}
@@ -395,45 +395,9 @@ func (u *updater) Parse(ctx context.Context, r io.ReadCloser) ([]*claircore.Vuln
 			return nil, err
 		}
 		name := strings.TrimSuffix(path.Base(zf.Name), ".zip")
-
-		var skipped stats
-		var ct int
-		for _, zf := range z.File {
-			ctx := zlog.ContextWithValues(ctx, "advisory", strings.TrimSuffix(path.Base(zf.Name), ".json"))
-			ct++
-			var a advisory
-			rc, err := zf.Open()
-			if err != nil {
-				return nil, err
-			}
-			err = json.NewDecoder(rc).Decode(&a)
-			rc.Close()
-			if err != nil {
-				return nil, err
-			}
-
-			switch {
-			case !a.Withdrawn.IsZero() && now.After(a.Withdrawn):
-				skipped.Withdrawn = append(skipped.Withdrawn, a.ID)
-				continue
-			case len(a.Affected) == 0:
-				skipped.Unaffected = append(skipped.Unaffected, a.ID)
-				continue
-			default:
-			}
-
-			if err := ecs.Insert(ctx, &skipped, name, &a); err != nil {
-				return nil, err
-			}
+		if err := ecs.InsertZip(ctx, name, now, z); err != nil {
+			return nil, err
 		}
-		zlog.Debug(ctx).
-			Int("count", ct).
-			Msg("processed advisories")
-		zlog.Debug(ctx).
-			Strs("withdrawn", skipped.Withdrawn).
-			Strs("unaffected", skipped.Unaffected).
-			Strs("ignored", skipped.Ignored).
-			Msg("skipped advisories")
 	}
 	zlog.Info(ctx).
 		Int("count", ecs.Len()).
@@ -453,6 +417,15 @@ type (
 	}
 )
 
+// Log reports the skipped advisories.
+func (s *stats) Log(ctx context.Context) {
+	zlog.Debug(ctx).
+		Strs("withdrawn", s.Withdrawn).
+		Strs("unaffected", s.Unaffected).
+		Strs("ignored", s.Ignored).
+		Msg("skipped advisories")
+}
+
 // Ecs is an entity-component system for vulnerabilities.
 //
 // This is organized this way to help consolidate allocations.
@@ -483,6 +456,50 @@ func newECS(u string) ecs {
 	}
 }
 
+// InsertZip adds all the advisories in the zip file "z", which is laid out like
+// the OSV project's "all.zip" files.
+func (e *ecs) InsertZip(ctx context.Context, name string, now time.Time, z *zip.Reader) error {
+	var skipped stats
+	var ct int
+	for _, zf := range z.File {
+		ctx := zlog.ContextWithValues(ctx, "advisory", strings.TrimSuffix(path.Base(zf.Name), ".json"))
+		ct++
+		var a advisory
+		rc, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		err = json.NewDecoder(rc).Decode(&a)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		if err := e.InsertAdvisory(ctx, &skipped, name, now, &a); err != nil {
+			return err
+		}
+	}
+	zlog.Debug(ctx).
+		Int("count", ct).
+		Msg("processed advisories")
+	skipped.Log(ctx)
+	return nil
+}
+
+// InsertAdvisory adds the advisory "a", unless it's been withdrawn as of "now"
+// or affects nothing.
+func (e *ecs) InsertAdvisory(ctx context.Context, skipped *stats, name string, now time.Time, a *advisory) error {
+	switch {
+	case !a.Withdrawn.IsZero() && now.After(a.Withdrawn):
+		skipped.Withdrawn = append(skipped.Withdrawn, a.ID)
+		return nil
+	case len(a.Affected) == 0:
+		skipped.Unaffected = append(skipped.Unaffected, a.ID)
+		return nil
+	default:
+	}
+	return e.Insert(ctx, skipped, name, a)
+}
+
 func (e *ecs) Insert(ctx context.Context, skipped *stats, name string, a *advisory) (err error) {
 	if a.GitOnly() {
 		return nil
@@ -503,9 +520,15 @@ func (e *ecs) Insert(ctx context.Context, skipped *stats, name string, a *adviso
 		case `CVSS_V2`:
 			proto.Severity = s.Score
 			proto.NormalizedSeverity, err = fromCVSS2(s.Score)
+		case `CVSS_V4`:
+			proto.Severity = s.Score
+			proto.NormalizedSeverity, err = fromCVSS4(s.Score)
 		default:
 			continue
 		}
+		if err == nil {
+			err = setCVSS(&proto, s.Score)
+		}
 		if err != nil {
 			zlog.Info(ctx).
 				Err(err).
@@ -30,6 +30,7 @@ type (
 		// Valid types:
 		// - CVSS_V2
 		// - CVSS_V3
+		// - CVSS_V4
 		Type  string `json:"type"`
 		Score string `json:"score"`
 	}
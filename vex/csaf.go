@@ -0,0 +1,255 @@
+package vex
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/quay/claircore/pkg/purl"
+)
+
+// Csaf is the subset of a CSAF 2.0 document that's used.
+type csaf struct {
+	Document struct {
+		Category    string `json:"category"`
+		CSAFVersion string `json:"csaf_version"`
+		Tracking    struct {
+			ID                 string     `json:"id"`
+			CurrentReleaseDate *time.Time `json:"current_release_date"`
+		} `json:"tracking"`
+	} `json:"document"`
+	ProductTree struct {
+		Branches         []csafBranch       `json:"branches"`
+		FullProductNames []csafProduct      `json:"full_product_names"`
+		ProductGroups    []csafProductGroup `json:"product_groups"`
+		Relationships    []csafRelationship `json:"relationships"`
+	} `json:"product_tree"`
+	Vulnerabilities []csafVulnerability `json:"vulnerabilities"`
+}
+
+type csafBranch struct {
+	Branches []csafBranch `json:"branches"`
+	Product  *csafProduct `json:"product"`
+}
+
+// Collect adds all the products in the branch to "m".
+func (b *csafBranch) collect(m map[string]*csafProduct) {
+	if b.Product != nil {
+		m[b.Product.ProductID] = b.Product
+	}
+	for i := range b.Branches {
+		b.Branches[i].collect(m)
+	}
+}
+
+type csafProduct struct {
+	ProductID string `json:"product_id"`
+	Helper    struct {
+		PURL   string `json:"purl"`
+		Hashes []struct {
+			FileHashes []struct {
+				Algorithm string `json:"algorithm"`
+				Value     string `json:"value"`
+			} `json:"file_hashes"`
+		} `json:"hashes"`
+	} `json:"product_identification_helper"`
+}
+
+// Product converts the CSAF product.
+func (p *csafProduct) product() Product {
+	out := Product{
+		ID: p.ProductID,
+	}
+	if s := p.Helper.PURL; s != "" {
+		if u, err := purl.Parse(s); err == nil {
+			out.PURL = u
+		}
+	}
+	for _, h := range p.Helper.Hashes {
+		for _, fh := range h.FileHashes {
+			if out.Hashes == nil {
+				out.Hashes = make(map[string]string)
+			}
+			out.Hashes[fh.Algorithm] = fh.Value
+		}
+	}
+	return out
+}
+
+type csafProductGroup struct {
+	GroupID    string   `json:"group_id"`
+	ProductIDs []string `json:"product_ids"`
+}
+
+type csafRelationship struct {
+	FullProductName  csafProduct `json:"full_product_name"`
+	ProductReference string      `json:"product_reference"`
+	RelatesTo        string      `json:"relates_to_product_reference"`
+}
+
+type csafVulnerability struct {
+	CVE string `json:"cve"`
+	IDs []struct {
+		Text string `json:"text"`
+	} `json:"ids"`
+	ProductStatus struct {
+		FirstFixed         []string `json:"first_fixed"`
+		Fixed              []string `json:"fixed"`
+		KnownAffected      []string `json:"known_affected"`
+		KnownNotAffected   []string `json:"known_not_affected"`
+		UnderInvestigation []string `json:"under_investigation"`
+	} `json:"product_status"`
+	Flags        []csafProductNote `json:"flags"`
+	Threats      []csafProductNote `json:"threats"`
+	Remediations []csafProductNote `json:"remediations"`
+}
+
+// CsafProductNote is the shape shared by flags, threats, and remediations:
+// some text about a set of products.
+type csafProductNote struct {
+	Category   string   `json:"category"`
+	Label      string   `json:"label"`
+	Details    string   `json:"details"`
+	GroupIDs   []string `json:"group_ids"`
+	ProductIDs []string `json:"product_ids"`
+}
+
+// ParseCSAF reads a CSAF 2.0 JSON document from "r". The product status of
+// each vulnerability is used, so CSAF security advisories work as well as
+// VEX documents.
+//
+// Products are resolved through the product tree. A product that's a
+// component of another product, through a relationship, applies to the
+// component within the other product. If the other product isn't described
+// by a "pkg:oci" Package URL, as with a product that's an operating system
+// platform, the statement also applies to the component on its own.
+//
+// Justifications come from the "flags", impact statements from "impact"
+// threats, and action statements from remediations.
+func ParseCSAF(r io.Reader) (*Document, error) {
+	var d csaf
+	if err := json.NewDecoder(r).Decode(&d); err != nil {
+		return nil, fmt.Errorf("vex: unable to decode CSAF document: %w", err)
+	}
+	if !strings.HasPrefix(d.Document.CSAFVersion, "2.") {
+		return nil, fmt.Errorf("vex: unsupported CSAF version %q", d.Document.CSAFVersion)
+	}
+	out := Document{
+		ID: d.Document.Tracking.ID,
+	}
+	if t := d.Document.Tracking.CurrentReleaseDate; t != nil {
+		out.Timestamp = *t
+	}
+
+	tree := make(map[string]*csafProduct)
+	for i := range d.ProductTree.Branches {
+		d.ProductTree.Branches[i].collect(tree)
+	}
+	for i := range d.ProductTree.FullProductNames {
+		p := &d.ProductTree.FullProductNames[i]
+		tree[p.ProductID] = p
+	}
+	products := make(map[string][]Product, len(tree))
+	for id, p := range tree {
+		products[id] = []Product{p.product()}
+	}
+	for _, rel := range d.ProductTree.Relationships {
+		c, ok := tree[rel.ProductReference]
+		if !ok {
+			continue
+		}
+		comp := c.product()
+		parent := Product{ID: rel.RelatesTo}
+		if p, ok := tree[rel.RelatesTo]; ok {
+			parent = p.product()
+		}
+		parent.Subcomponents = []Product{comp}
+		ps := []Product{parent}
+		if parent.PURL == nil || parent.PURL.Type != "oci" {
+			ps = append(ps, comp)
+		}
+		products[rel.FullProductName.ProductID] = ps
+	}
+	groups := make(map[string][]string)
+	for _, g := range d.ProductTree.ProductGroups {
+		groups[g.GroupID] = g.ProductIDs
+	}
+	// Notes returns a lookup of the first note with a matching category for
+	// each product.
+	notes := func(ns []csafProductNote, cat string, text func(*csafProductNote) string) map[string]string {
+		m := make(map[string]string)
+		for i := range ns {
+			n := &ns[i]
+			if cat != "" && n.Category != cat {
+				continue
+			}
+			ids := n.ProductIDs
+			for _, g := range n.GroupIDs {
+				ids = append(ids, groups[g]...)
+			}
+			for _, id := range ids {
+				if _, ok := m[id]; !ok {
+					m[id] = text(n)
+				}
+			}
+		}
+		return m
+	}
+	label := func(n *csafProductNote) string { return n.Label }
+	details := func(n *csafProductNote) string { return n.Details }
+
+	for _, v := range d.Vulnerabilities {
+		name := v.CVE
+		var aliases []string
+		for _, id := range v.IDs {
+			if name == "" {
+				name = id.Text
+				continue
+			}
+			aliases = append(aliases, id.Text)
+		}
+		if name == "" {
+			continue
+		}
+		justification := notes(v.Flags, "", label)
+		impact := notes(v.Threats, "impact", details)
+		action := notes(v.Remediations, "", details)
+		for _, set := range []struct {
+			Status Status
+			IDs    []string
+		}{
+			{Fixed, v.ProductStatus.FirstFixed},
+			{Fixed, v.ProductStatus.Fixed},
+			{Affected, v.ProductStatus.KnownAffected},
+			{NotAffected, v.ProductStatus.KnownNotAffected},
+			{UnderInvestigation, v.ProductStatus.UnderInvestigation},
+		} {
+			ids := append([]string(nil), set.IDs...)
+			sort.Strings(ids)
+			for _, id := range ids {
+				ps, ok := products[id]
+				if !ok {
+					continue
+				}
+				st := Statement{
+					Vulnerability: name,
+					Aliases:       aliases,
+					Products:      ps,
+					Status:        set.Status,
+				}
+				switch set.Status {
+				case NotAffected:
+					st.Justification = justification[id]
+					st.Impact = impact[id]
+				case Affected:
+					st.Action = action[id]
+				}
+				out.Statements = append(out.Statements, st)
+			}
+		}
+	}
+	return &out, nil
+}
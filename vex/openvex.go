@@ -0,0 +1,132 @@
+package vex
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/quay/claircore/pkg/purl"
+)
+
+// OpenVEXContext is the prefix of the "@context" of OpenVEX documents.
+const openVEXContext = `https://openvex.dev/ns`
+
+// OpenVEX is the subset of an OpenVEX document that's used. Both the v0.0.1
+// form, with strings for vulnerabilities and products, and the v0.2.0 form,
+// with objects, are accepted.
+type openVEX struct {
+	Context    string             `json:"@context"`
+	ID         string             `json:"@id"`
+	Timestamp  *time.Time         `json:"timestamp"`
+	Statements []openVEXStatement `json:"statements"`
+}
+
+type openVEXStatement struct {
+	Vulnerability openVEXVulnerability `json:"vulnerability"`
+	Products      []openVEXProduct     `json:"products"`
+	// Subcomponents are the v0.0.1 statement-level subcomponents.
+	Subcomponents   []openVEXProduct `json:"subcomponents"`
+	Status          Status           `json:"status"`
+	Justification   string           `json:"justification"`
+	ImpactStatement string           `json:"impact_statement"`
+	ActionStatement string           `json:"action_statement"`
+	Timestamp       *time.Time       `json:"timestamp"`
+}
+
+type openVEXVulnerability struct {
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases"`
+}
+
+func (v *openVEXVulnerability) UnmarshalJSON(b []byte) error {
+	if bytes.HasPrefix(b, []byte(`"`)) {
+		return json.Unmarshal(b, &v.Name)
+	}
+	type plain openVEXVulnerability
+	return json.Unmarshal(b, (*plain)(v))
+}
+
+type openVEXProduct struct {
+	ID          string `json:"@id"`
+	Identifiers struct {
+		PURL string `json:"purl"`
+	} `json:"identifiers"`
+	Hashes        map[string]string `json:"hashes"`
+	Subcomponents []openVEXProduct  `json:"subcomponents"`
+}
+
+func (p *openVEXProduct) UnmarshalJSON(b []byte) error {
+	if bytes.HasPrefix(b, []byte(`"`)) {
+		return json.Unmarshal(b, &p.ID)
+	}
+	type plain openVEXProduct
+	return json.Unmarshal(b, (*plain)(p))
+}
+
+// Product converts the OpenVEX product.
+func (p *openVEXProduct) product() Product {
+	out := Product{
+		ID:     p.ID,
+		Hashes: p.Hashes,
+	}
+	for _, s := range []string{p.Identifiers.PURL, p.ID} {
+		if !strings.HasPrefix(s, "pkg:") {
+			continue
+		}
+		if u, err := purl.Parse(s); err == nil {
+			out.PURL = u
+			break
+		}
+	}
+	for i := range p.Subcomponents {
+		out.Subcomponents = append(out.Subcomponents, p.Subcomponents[i].product())
+	}
+	return out
+}
+
+// ParseOpenVEX reads an OpenVEX JSON document from "r".
+func ParseOpenVEX(r io.Reader) (*Document, error) {
+	var d openVEX
+	if err := json.NewDecoder(r).Decode(&d); err != nil {
+		return nil, fmt.Errorf("vex: unable to decode OpenVEX document: %w", err)
+	}
+	if !strings.HasPrefix(d.Context, openVEXContext) {
+		return nil, fmt.Errorf("vex: unexpected OpenVEX context %q", d.Context)
+	}
+	out := Document{
+		ID: d.ID,
+	}
+	if d.Timestamp != nil {
+		out.Timestamp = *d.Timestamp
+	}
+	for _, s := range d.Statements {
+		st := Statement{
+			Vulnerability: s.Vulnerability.Name,
+			Aliases:       s.Vulnerability.Aliases,
+			Status:        s.Status,
+			Justification: s.Justification,
+			Impact:        s.ImpactStatement,
+			Action:        s.ActionStatement,
+		}
+		switch st.Status {
+		case NotAffected, Affected, Fixed, UnderInvestigation:
+		default:
+			return nil, fmt.Errorf("vex: unknown OpenVEX status %q", s.Status)
+		}
+		if s.Timestamp != nil {
+			st.Timestamp = *s.Timestamp
+		}
+		for i := range s.Products {
+			p := s.Products[i].product()
+			for j := range s.Subcomponents {
+				p.Subcomponents = append(p.Subcomponents, s.Subcomponents[j].product())
+			}
+			st.Products = append(st.Products, p)
+		}
+		out.Statements = append(out.Statements, st)
+	}
+	return &out, nil
+}
@@ -0,0 +1,450 @@
+// Package vex applies VEX (Vulnerability Exploitability eXchange) statements
+// to VulnerabilityReports.
+//
+// VEX documents are published by a product's supplier to say whether the
+// product is affected by a vulnerability, so that findings about components
+// that aren't exploitable in the product can be dropped. This package reads
+// OpenVEX and CSAF VEX documents into [Document]s, and [Apply] uses them to
+// filter or annotate the findings in a VulnerabilityReport. Annotations are
+// recorded as an enrichment, available through [Annotations].
+package vex
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/enricher"
+	"github.com/quay/claircore/pkg/purl"
+	"github.com/quay/claircore/sbom"
+)
+
+// Status is the status of a product with respect to a vulnerability.
+type Status string
+
+// These are the statuses VEX documents use.
+const (
+	NotAffected        Status = "not_affected"
+	Affected           Status = "affected"
+	Fixed              Status = "fixed"
+	UnderInvestigation Status = "under_investigation"
+)
+
+// Suppresses reports whether the status means findings should be dropped.
+func (s Status) suppresses() bool {
+	return s == NotAffected || s == Fixed
+}
+
+// Document is a VEX document, in a format-independent form.
+type Document struct {
+	// ID is the document's identifier.
+	ID string
+	// Timestamp is when the document was issued, if known.
+	Timestamp time.Time
+	// Statements are the statements in the document.
+	Statements []Statement
+}
+
+// Statement is a VEX statement about one vulnerability in some products.
+type Statement struct {
+	// Vulnerability is the name of the vulnerability, usually a CVE ID.
+	Vulnerability string
+	// Aliases are other names for the vulnerability.
+	Aliases []string
+	// Products are the products the statement applies to.
+	Products []Product
+	// Status is the status of the products.
+	Status Status
+	// Justification is the machine-readable reason for a "not_affected"
+	// status, like "vulnerable_code_not_present".
+	Justification string
+	// Impact is the human-readable explanation of a "not_affected" status.
+	Impact string
+	// Action is the human-readable remediation for an "affected" status.
+	Action string
+	// Timestamp is when the statement was made, if known. Documents'
+	// timestamps are used for statements without one.
+	Timestamp time.Time
+}
+
+// Product identifies a product or component a statement applies to.
+type Product struct {
+	// ID is the product's identifier, which may be a Package URL.
+	ID string
+	// PURL is the product's Package URL, if it has one.
+	PURL *purl.PURL
+	// Hashes are the product's hashes, keyed by algorithm.
+	Hashes map[string]string
+	// Subcomponents limit the statement to these components of the
+	// product.
+	Subcomponents []Product
+}
+
+// Parse reads an OpenVEX or CSAF JSON document from "r", detecting the format
+// from its contents.
+func Parse(r io.Reader) (*Document, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("vex: unable to read document: %w", err)
+	}
+	var probe struct {
+		Context  string          `json:"@context"`
+		Document json.RawMessage `json:"document"`
+	}
+	if err := json.Unmarshal(b, &probe); err != nil {
+		return nil, fmt.Errorf("vex: unable to decode document: %w", err)
+	}
+	switch {
+	case probe.Context != "":
+		return ParseOpenVEX(bytes.NewReader(b))
+	case len(probe.Document) != 0:
+		return ParseCSAF(bytes.NewReader(b))
+	}
+	return nil, errors.New("vex: unknown document format")
+}
+
+// Mode controls what Apply does with findings.
+type Mode uint
+
+// These are the modes Apply supports.
+const (
+	// Filter removes findings with a "not_affected" or "fixed" status and
+	// annotates the rest.
+	Filter Mode = iota
+	// Annotate only annotates findings.
+	Annotate
+)
+
+// Options are the options for Apply.
+type Options struct {
+	// Products are identifiers for the artifact the report is about, such as
+	// an "pkg:oci" Package URL or an image reference. Statements about these
+	// products apply to the packages in the report. The report's manifest
+	// digest is always used.
+	Products []string
+	// Mode is what to do with findings.
+	Mode Mode
+}
+
+// Type is the type of the enrichment Apply records.
+const Type = `message/vnd.clair.map.vulnerability; enricher=clair.vex schema=https://github.com/quay/claircore/vex#Annotation`
+
+// Annotation is the record Apply adds for a finding a statement applies to.
+type Annotation struct {
+	// Package is the ID of the package in the report.
+	Package string `json:"package"`
+	// Status is the status from the statement.
+	Status Status `json:"status"`
+	// Justification is the justification from the statement, if any.
+	Justification string `json:"justification,omitempty"`
+	// Impact is the impact statement from the statement, if any.
+	Impact string `json:"impact_statement,omitempty"`
+	// Action is the action statement from the statement, if any.
+	Action string `json:"action_statement,omitempty"`
+	// Document is the ID of the document the statement came from.
+	Document string `json:"document,omitempty"`
+}
+
+// Annotations provides typed access to the enrichments recorded by Apply.
+var Annotations = enricher.Register[Annotation](Type)
+
+// Finding is a package and vulnerability reported together.
+type Finding struct {
+	// Package is the ID of the package.
+	Package string
+	// Vulnerability is the ID of the vulnerability.
+	Vulnerability string
+	// Annotation is the annotation for the statement that applied.
+	Annotation Annotation
+}
+
+// Apply applies the statements in "docs" to the findings in "vr", modifying
+// it in place, and reports the findings that were removed.
+//
+// A statement applies to a finding when it names the vulnerability and
+// either has a product matching one of the package's Package URLs, or has a
+// product matching the artifact the report describes and no subcomponents,
+// or a subcomponent matching the package. A package's Package URLs are the
+// one [sbom.PURL] reports for it and the one for its source package.
+// Package URLs match if the type, namespace, and name are the same, along
+// with the version and any qualifiers the package also has, if the
+// statement's Package URL has them.
+//
+// Vulnerabilities match if their name, or the single CVE ID in their name,
+// is the statement's vulnerability or one of its aliases.
+//
+// When several statements apply to a finding, the latest one is used, with
+// statements without timestamps treated as older than those with and ties
+// going to the later statement in "docs".
+//
+// In [Filter] mode, findings with a "not_affected" or "fixed" status are
+// removed, along with vulnerabilities no longer reported for any package.
+// All other findings a statement applies to are annotated.
+func Apply(vr *claircore.VulnerabilityReport, opts *Options, docs ...*Document) ([]Finding, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	stmts := collect(docs)
+	if len(stmts) == 0 {
+		return nil, nil
+	}
+
+	want := make(map[string]bool, len(opts.Products)+1)
+	for _, p := range opts.Products {
+		want[p] = true
+	}
+	if vr.Hash.String() != "" {
+		want[vr.Hash.String()] = true
+	}
+
+	ir := &claircore.IndexReport{
+		Distributions: vr.Distributions,
+		Repositories:  vr.Repositories,
+		Environments:  vr.Environments,
+	}
+	var suppressed []Finding
+	annotations := make(map[string][]Annotation)
+	ids := make([]string, 0, len(vr.PackageVulnerabilities))
+	for id := range vr.PackageVulnerabilities {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, pkgID := range ids {
+		p, ok := vr.Packages[pkgID]
+		if !ok {
+			continue
+		}
+		ctx := sbom.ContextFor(ir, pkgID)
+		var us []*purl.PURL
+		if u := sbom.PURL(ctx, p); u != nil {
+			us = append(us, u)
+		}
+		if p.Source != nil && p.Source.Name != "" {
+			if u := sbom.PURL(ctx, p.Source); u != nil {
+				us = append(us, u)
+			}
+		}
+		if len(us) == 0 {
+			continue
+		}
+
+		vulnIDs := vr.PackageVulnerabilities[pkgID]
+		keep := vulnIDs[:0]
+		for _, vulnID := range vulnIDs {
+			v, ok := vr.Vulnerabilities[vulnID]
+			if !ok {
+				keep = append(keep, vulnID)
+				continue
+			}
+			var match *stmt
+			names := vulnNames(v)
+			for i := range stmts {
+				s := &stmts[i]
+				if s.names(names) && s.applies(want, us) {
+					match = s
+				}
+			}
+			if match == nil {
+				keep = append(keep, vulnID)
+				continue
+			}
+			a := Annotation{
+				Package:       pkgID,
+				Status:        match.Status,
+				Justification: match.Justification,
+				Impact:        match.Impact,
+				Action:        match.Action,
+				Document:      match.doc,
+			}
+			if opts.Mode == Filter && match.Status.suppresses() {
+				suppressed = append(suppressed, Finding{
+					Package:       pkgID,
+					Vulnerability: vulnID,
+					Annotation:    a,
+				})
+				continue
+			}
+			keep = append(keep, vulnID)
+			annotations[vulnID] = append(annotations[vulnID], a)
+		}
+		if len(keep) == 0 {
+			delete(vr.PackageVulnerabilities, pkgID)
+			continue
+		}
+		vr.PackageVulnerabilities[pkgID] = keep
+	}
+
+	if len(suppressed) != 0 {
+		used := make(map[string]bool)
+		for _, vs := range vr.PackageVulnerabilities {
+			for _, id := range vs {
+				used[id] = true
+			}
+		}
+		for _, f := range suppressed {
+			if !used[f.Vulnerability] {
+				delete(vr.Vulnerabilities, f.Vulnerability)
+			}
+		}
+	}
+	if len(annotations) != 0 {
+		b, err := json.Marshal(annotations)
+		if err != nil {
+			return nil, fmt.Errorf("vex: unable to encode annotations: %w", err)
+		}
+		if vr.Enrichments == nil {
+			vr.Enrichments = make(map[string][]json.RawMessage)
+		}
+		vr.Enrichments[Type] = append(vr.Enrichments[Type], b)
+	}
+	return suppressed, nil
+}
+
+// Stmt is a Statement with the information Apply needs precomputed.
+type stmt struct {
+	Statement
+	doc   string
+	vulns map[string]bool
+}
+
+// Collect flattens the statements in "docs", ordered so that later
+// statements take precedence.
+func collect(docs []*Document) []stmt {
+	var ret []stmt
+	for _, d := range docs {
+		if d == nil {
+			continue
+		}
+		for _, s := range d.Statements {
+			x := stmt{
+				Statement: s,
+				doc:       d.ID,
+				vulns:     make(map[string]bool, len(s.Aliases)+1),
+			}
+			if x.Timestamp.IsZero() {
+				x.Timestamp = d.Timestamp
+			}
+			for _, n := range append([]string{s.Vulnerability}, s.Aliases...) {
+				if n != "" {
+					x.vulns[strings.ToUpper(n)] = true
+				}
+			}
+			ret = append(ret, x)
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Timestamp.Before(ret[j].Timestamp)
+	})
+	return ret
+}
+
+// Names reports whether the statement is about a vulnerability with one of
+// the names in "ns".
+func (s *stmt) names(ns []string) bool {
+	for _, n := range ns {
+		if s.vulns[n] {
+			return true
+		}
+	}
+	return false
+}
+
+// Applies reports whether the statement applies to a package identified by
+// the Package URLs "us", in an artifact identified by "want".
+func (s *stmt) applies(want map[string]bool, us []*purl.PURL) bool {
+	for i := range s.Products {
+		p := &s.Products[i]
+		if p.matches(us) {
+			return true
+		}
+		if !p.identifies(want) {
+			continue
+		}
+		if len(p.Subcomponents) == 0 {
+			return true
+		}
+		for j := range p.Subcomponents {
+			if p.Subcomponents[j].matches(us) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Identifies reports whether the product is the artifact identified by
+// "want".
+func (p *Product) identifies(want map[string]bool) bool {
+	if want[p.ID] {
+		return true
+	}
+	if p.PURL != nil {
+		if want[p.PURL.String()] {
+			return true
+		}
+		// The version of an OCI Package URL is the manifest digest.
+		if p.PURL.Type == "oci" && want[p.PURL.Version] {
+			return true
+		}
+	}
+	for algo, sum := range p.Hashes {
+		algo = strings.ToLower(strings.ReplaceAll(algo, "-", ""))
+		if want[algo+":"+strings.ToLower(sum)] {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches reports whether the product's Package URL matches one of "us".
+func (p *Product) matches(us []*purl.PURL) bool {
+	if p.PURL == nil || p.PURL.Type == "oci" {
+		return false
+	}
+	for _, u := range us {
+		if purlMatch(p.PURL, u) {
+			return true
+		}
+	}
+	return false
+}
+
+// PurlMatch reports whether the statement's Package URL "s" covers the
+// package's Package URL "u".
+func purlMatch(s, u *purl.PURL) bool {
+	if s.Type != u.Type ||
+		!strings.EqualFold(s.Namespace, u.Namespace) ||
+		s.Name != u.Name {
+		return false
+	}
+	if s.Version != "" && s.Version != u.Version {
+		return false
+	}
+	for k, v := range s.Qualifiers {
+		if uv, ok := u.Qualifiers[k]; ok && uv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// CveRegexp finds CVE IDs.
+var cveRegexp = regexp.MustCompile(`CVE-\d{4}-\d{4,}`)
+
+// VulnNames returns the names a statement can refer to the vulnerability by,
+// uppercased.
+func vulnNames(v *claircore.Vulnerability) []string {
+	n := strings.ToUpper(v.Name)
+	ret := []string{n}
+	if cves := cveRegexp.FindAllString(n, 2); len(cves) == 1 && cves[0] != n {
+		ret = append(ret, cves[0])
+	}
+	return ret
+}
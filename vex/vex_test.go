@@ -0,0 +1,406 @@
+package vex
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/quay/claircore"
+	"github.com/quay/claircore/pkg/purl"
+)
+
+var testDigest = claircore.MustParseDigest(`sha256:` + strings.Repeat("a", 64))
+
+// TestReport returns a report with a Debian package, built from a source
+// package, and a Python package, each with two vulnerabilities.
+func testReport() *claircore.VulnerabilityReport {
+	src := &claircore.Package{ID: "2", Name: "glibc", Version: "2.36-9", Kind: claircore.SOURCE}
+	return &claircore.VulnerabilityReport{
+		Hash: testDigest,
+		Packages: map[string]*claircore.Package{
+			"1": {ID: "1", Name: "libc6", Version: "2.36-9", Kind: claircore.BINARY, Arch: "amd64", Source: src},
+			"3": {ID: "3", Name: "flask", Version: "2.3.2", Kind: claircore.BINARY},
+		},
+		Distributions: map[string]*claircore.Distribution{
+			"1": {ID: "1", DID: "debian", VersionID: "12", VersionCodeName: "bookworm"},
+		},
+		Repositories: map[string]*claircore.Repository{
+			"1": {ID: "1", Name: "pypi", URI: "https://pypi.org/simple"},
+		},
+		Environments: map[string][]*claircore.Environment{
+			"1": {{PackageDB: "var/lib/dpkg/status", DistributionID: "1", IntroducedIn: testDigest}},
+			"3": {{PackageDB: "python:usr/lib/python3/site-packages", RepositoryIDs: []string{"1"}, IntroducedIn: testDigest}},
+		},
+		Vulnerabilities: map[string]*claircore.Vulnerability{
+			"10": {ID: "10", Name: "CVE-2023-0001"},
+			"11": {ID: "11", Name: "DSA-5514-1 CVE-2023-0002"},
+			"12": {ID: "12", Name: "GHSA-m2qf-hxjv-5gpq"},
+			"13": {ID: "13", Name: "CVE-2023-0001"},
+		},
+		PackageVulnerabilities: map[string][]string{
+			"1": {"10", "11"},
+			"3": {"12", "13"},
+		},
+	}
+}
+
+func mustPURL(t *testing.T, s string) *purl.PURL {
+	t.Helper()
+	u, err := purl.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestApply(t *testing.T) {
+	tt := []struct {
+		Name string
+		Opts *Options
+		Docs func(*testing.T) []*Document
+		// Want is the expected PackageVulnerabilities.
+		Want map[string][]string
+		// Annotated is the expected set of annotated vulnerability IDs.
+		Annotated []string
+	}{
+		{
+			Name: "Package",
+			Docs: func(t *testing.T) []*Document {
+				return []*Document{{ID: "doc", Statements: []Statement{
+					{
+						Vulnerability: "CVE-2023-0001",
+						Products:      []Product{{PURL: mustPURL(t, "pkg:deb/debian/libc6@2.36-9?arch=amd64")}},
+						Status:        NotAffected,
+						Justification: "vulnerable_code_not_present",
+					},
+				}}}
+			},
+			Want: map[string][]string{
+				"1": {"11"},
+				"3": {"12", "13"},
+			},
+		},
+		{
+			Name: "SourcePackageAlias",
+			Docs: func(t *testing.T) []*Document {
+				return []*Document{{Statements: []Statement{
+					{
+						Vulnerability: "DSA-0000",
+						Aliases:       []string{"CVE-2023-0002"},
+						Products:      []Product{{PURL: mustPURL(t, "pkg:deb/debian/glibc")}},
+						Status:        Fixed,
+					},
+				}}}
+			},
+			Want: map[string][]string{
+				"1": {"10"},
+				"3": {"12", "13"},
+			},
+		},
+		{
+			Name: "QualifierMismatch",
+			Docs: func(t *testing.T) []*Document {
+				return []*Document{{Statements: []Statement{
+					{
+						Vulnerability: "CVE-2023-0001",
+						Products:      []Product{{PURL: mustPURL(t, "pkg:deb/debian/libc6?distro=bullseye")}},
+						Status:        NotAffected,
+					},
+				}}}
+			},
+			Want: map[string][]string{
+				"1": {"10", "11"},
+				"3": {"12", "13"},
+			},
+		},
+		{
+			Name: "Artifact",
+			Docs: func(t *testing.T) []*Document {
+				return []*Document{{Statements: []Statement{
+					{
+						Vulnerability: "CVE-2023-0001",
+						Products:      []Product{{ID: "pkg:oci/app@" + testDigest.String(), PURL: mustPURL(t, "pkg:oci/app@"+testDigest.String())}},
+						Status:        NotAffected,
+					},
+				}}}
+			},
+			Want: map[string][]string{
+				"1": {"11"},
+				"3": {"12"},
+			},
+		},
+		{
+			Name: "Subcomponent",
+			Opts: &Options{Products: []string{"quay.io/example/app:latest"}},
+			Docs: func(t *testing.T) []*Document {
+				return []*Document{{Statements: []Statement{
+					{
+						Vulnerability: "CVE-2023-0001",
+						Products: []Product{{
+							ID:            "quay.io/example/app:latest",
+							Subcomponents: []Product{{PURL: mustPURL(t, "pkg:pypi/flask")}},
+						}},
+						Status: NotAffected,
+					},
+					{
+						Vulnerability: "GHSA-m2qf-hxjv-5gpq",
+						Products: []Product{{
+							ID:            "quay.io/example/other:latest",
+							Subcomponents: []Product{{PURL: mustPURL(t, "pkg:golang/example.com/unrelated")}},
+						}},
+						Status: NotAffected,
+					},
+				}}}
+			},
+			Want: map[string][]string{
+				"1": {"10", "11"},
+				"3": {"12"},
+			},
+		},
+		{
+			Name: "Latest",
+			Docs: func(t *testing.T) []*Document {
+				p := []Product{{PURL: mustPURL(t, "pkg:pypi/flask@2.3.2")}}
+				older := &Document{
+					Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+					Statements: []Statement{
+						{Vulnerability: "CVE-2023-0001", Products: p, Status: NotAffected},
+					},
+				}
+				newer := &Document{
+					Timestamp: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+					Statements: []Statement{
+						{Vulnerability: "CVE-2023-0001", Products: p, Status: Affected, Action: "Upgrade."},
+					},
+				}
+				return []*Document{newer, older}
+			},
+			Want: map[string][]string{
+				"1": {"10", "11"},
+				"3": {"12", "13"},
+			},
+			Annotated: []string{"13"},
+		},
+		{
+			Name: "Annotate",
+			Opts: &Options{Mode: Annotate},
+			Docs: func(t *testing.T) []*Document {
+				return []*Document{{Statements: []Statement{
+					{
+						Vulnerability: "CVE-2023-0002",
+						Products:      []Product{{PURL: mustPURL(t, "pkg:deb/debian/libc6")}},
+						Status:        NotAffected,
+					},
+				}}}
+			},
+			Want: map[string][]string{
+				"1": {"10", "11"},
+				"3": {"12", "13"},
+			},
+			Annotated: []string{"11"},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			vr := testReport()
+			removed, err := Apply(vr, tc.Opts, tc.Docs(t)...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := vr.PackageVulnerabilities, tc.Want; !cmp.Equal(got, want) {
+				t.Error(cmp.Diff(got, want))
+			}
+			for _, f := range removed {
+				if f.Annotation.Package != f.Package {
+					t.Errorf("annotation for package %q, want %q", f.Annotation.Package, f.Package)
+				}
+				if !f.Annotation.Status.suppresses() {
+					t.Errorf("removed finding with status %q", f.Annotation.Status)
+				}
+			}
+			// Vulnerabilities should only be removed when they're unused.
+			used := make(map[string]bool)
+			for _, ids := range vr.PackageVulnerabilities {
+				for _, id := range ids {
+					used[id] = true
+				}
+			}
+			for id := range vr.Vulnerabilities {
+				if !used[id] {
+					t.Errorf("unused vulnerability %q left in report", id)
+				}
+			}
+
+			m, err := Annotations.Get(vr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for id := range m {
+				got = append(got, id)
+			}
+			sort.Strings(got)
+			if want := tc.Annotated; !cmp.Equal(got, want) {
+				t.Error(cmp.Diff(got, want))
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tt := []struct {
+		Name string
+		In   string
+		Want []string
+	}{
+		{
+			Name: "OpenVEX",
+			In: `{
+  "@context": "https://openvex.dev/ns/v0.2.0",
+  "@id": "https://example.com/vex/1",
+  "timestamp": "2023-12-01T00:00:00Z",
+  "statements": [
+    {
+      "vulnerability": {"name": "CVE-2023-0001", "aliases": ["GHSA-xxxx-xxxx-xxxx"]},
+      "products": [
+        {
+          "@id": "pkg:oci/app@sha256:aaaa",
+          "subcomponents": [{"@id": "pkg:pypi/flask@2.3.2"}]
+        }
+      ],
+      "status": "not_affected",
+      "justification": "vulnerable_code_not_in_execute_path"
+    },
+    {
+      "vulnerability": {"name": "CVE-2023-0002"},
+      "products": [{"@id": "app", "identifiers": {"purl": "pkg:deb/debian/libc6"}}],
+      "status": "affected",
+      "action_statement": "Upgrade."
+    }
+  ]
+}`,
+			Want: []string{
+				"CVE-2023-0001 not_affected vulnerable_code_not_in_execute_path pkg:oci/app@sha256:aaaa[pkg:pypi/flask@2.3.2]",
+				"CVE-2023-0002 affected Upgrade. pkg:deb/debian/libc6",
+			},
+		},
+		{
+			Name: "OpenVEXv0.0.1",
+			In: `{
+  "@context": "https://openvex.dev/ns",
+  "@id": "https://example.com/vex/2",
+  "statements": [
+    {
+      "vulnerability": "CVE-2023-0001",
+      "products": ["pkg:oci/app@sha256:aaaa"],
+      "subcomponents": ["pkg:pypi/flask"],
+      "status": "fixed"
+    }
+  ]
+}`,
+			Want: []string{
+				"CVE-2023-0001 fixed pkg:oci/app@sha256:aaaa[pkg:pypi/flask]",
+			},
+		},
+		{
+			Name: "CSAF",
+			In: `{
+  "document": {
+    "category": "csaf_vex",
+    "csaf_version": "2.0",
+    "tracking": {"id": "CVE-2023-0001", "current_release_date": "2023-12-01T00:00:00Z"}
+  },
+  "product_tree": {
+    "branches": [
+      {
+        "branches": [
+          {"product": {"product_id": "rhel-9", "product_identification_helper": {"cpe": "cpe:/o:redhat:enterprise_linux:9"}}},
+          {"product": {"product_id": "bash", "product_identification_helper": {"purl": "pkg:rpm/redhat/bash?arch=src"}}},
+          {"product": {"product_id": "openssl", "product_identification_helper": {"purl": "pkg:rpm/redhat/openssl@3.0.7-1.el9"}}}
+        ]
+      }
+    ],
+    "relationships": [
+      {
+        "category": "default_component_of",
+        "product_reference": "bash",
+        "relates_to_product_reference": "rhel-9",
+        "full_product_name": {"product_id": "rhel-9:bash"}
+      }
+    ]
+  },
+  "vulnerabilities": [
+    {
+      "cve": "CVE-2023-0001",
+      "product_status": {
+        "known_not_affected": ["rhel-9:bash"],
+        "fixed": ["openssl"]
+      },
+      "flags": [{"label": "component_not_present", "product_ids": ["rhel-9:bash"]}]
+    }
+  ]
+}`,
+			Want: []string{
+				"CVE-2023-0001 fixed pkg:rpm/redhat/openssl@3.0.7-1.el9",
+				"CVE-2023-0001 not_affected component_not_present rhel-9[pkg:rpm/redhat/bash?arch=src] pkg:rpm/redhat/bash?arch=src",
+			},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.Name, func(t *testing.T) {
+			d, err := Parse(strings.NewReader(tc.In))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, s := range d.Statements {
+				fs := []string{s.Vulnerability, string(s.Status)}
+				for _, f := range []string{s.Justification, s.Impact, s.Action} {
+					if f != "" {
+						fs = append(fs, f)
+					}
+				}
+				for _, p := range s.Products {
+					fs = append(fs, productString(&p))
+				}
+				got = append(got, strings.Join(fs, " "))
+			}
+			if want := tc.Want; !cmp.Equal(got, want) {
+				t.Error(cmp.Diff(got, want))
+			}
+		})
+	}
+}
+
+func TestParseError(t *testing.T) {
+	for _, in := range []string{
+		`{}`,
+		`{"@context": "https://example.com/ns"}`,
+		`{"@context": "https://openvex.dev/ns/v0.2.0", "statements": [{"status": "bogus"}]}`,
+		`{"document": {"csaf_version": "1.0"}}`,
+	} {
+		if _, err := Parse(strings.NewReader(in)); err == nil {
+			t.Errorf("%s: expected error", in)
+		}
+	}
+}
+
+// ProductString formats a Product for comparison, as the PURL or ID followed
+// by any subcomponents in brackets.
+func productString(p *Product) string {
+	s := p.ID
+	if p.PURL != nil {
+		s = p.PURL.String()
+	}
+	if len(p.Subcomponents) != 0 {
+		var subs []string
+		for i := range p.Subcomponents {
+			subs = append(subs, productString(&p.Subcomponents[i]))
+		}
+		s += "[" + strings.Join(subs, " ") + "]"
+	}
+	return s
+}
@@ -24,6 +24,25 @@ type Vulnerability struct {
 	Severity string `json:"severity"`
 	// a normalized Severity type providing client guaranteed severity information
 	NormalizedSeverity Severity `json:"normalized_severity"`
+	// CVSS vectors and base scores, as retrieved from the security database.
+	// Only some updaters populate these.
+	CVSSv2Vector string  `json:"cvss_v2_vector,omitempty"`
+	CVSSv2Score  float64 `json:"cvss_v2_score,omitempty"`
+	CVSSv3Vector string  `json:"cvss_v3_vector,omitempty"`
+	CVSSv3Score  float64 `json:"cvss_v3_score,omitempty"`
+	CVSSv4Vector string  `json:"cvss_v4_vector,omitempty"`
+	CVSSv4Score  float64 `json:"cvss_v4_score,omitempty"`
+	// CVSS lists every distinct CVSS vector retrieved from the security
+	// database, including ones the fields above have no room for, such as
+	// both a v3.0 and a v3.1 vector. Only some updaters populate this.
+	CVSS []CVSS `json:"cvss,omitempty"`
+	// AffectedCPEs is the list of product CPEs named by the advisory this
+	// vulnerability came from, as bound strings. Only some updaters populate
+	// this.
+	AffectedCPEs []string `json:"affected_cpes,omitempty"`
+	// FixState describes the vendor's remediation status for the affected
+	// package. Only some updaters populate this.
+	FixState FixState `json:"fix_state,omitempty"`
 	// the package information associated with the vulnerability. ideally these fields can be matched
 	// to packages discovered by libindex PackageScanner structs.
 	Package *Package `json:"package"`
@@ -40,6 +59,40 @@ type Vulnerability struct {
 	ArchOperation ArchOp `json:"arch_op,omitempty"`
 }
 
+// CVSS is a CVSS vector and its base score, as retrieved from a security
+// database.
+type CVSS struct {
+	// Version is the exact CVSS version, like "2.0", "3.0", "3.1", or "4.0".
+	Version string `json:"version"`
+	// Vector is the vector string, without a leading score.
+	Vector string `json:"vector"`
+	// Score is the base score.
+	Score float64 `json:"score"`
+	// Authoritative is set on the vector the security database considers
+	// authoritative for the vulnerability. At most one vector is.
+	Authoritative bool `json:"authoritative,omitempty"`
+}
+
+// FixState is the remediation status of a vulnerability, as reported by the
+// security database.
+type FixState string
+
+// These are the known FixStates.
+const (
+	// FixStateFixed means an update is available, as indicated by the
+	// FixedInVersion.
+	FixStateFixed FixState = "fixed"
+	// FixStateAffected means the package is affected and no update is
+	// available yet.
+	FixStateAffected FixState = "affected"
+	// FixStateWillNotFix means the package is affected and the vendor does
+	// not plan to release an update.
+	FixStateWillNotFix FixState = "will_not_fix"
+	// FixStateUnderInvestigation means the vendor hasn't yet determined if
+	// the package is affected.
+	FixStateUnderInvestigation FixState = "under_investigation"
+)
+
 // CheckVulnernableFunc takes a vulnerability and an indexRecord and checks if the record is
 // vulnerable to the vulnerability, it is by the Querier.AffectedManifests method and allows
 // a backdoor to introduce application filtering logic into the DB layer.
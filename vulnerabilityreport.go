@@ -20,5 +20,7 @@ type VulnerabilityReport struct {
 	// a lookup table associating package ids with 1 or more vulnerability ids. keyed by package id
 	PackageVulnerabilities map[string][]string `json:"package_vulnerabilities"`
 	// a map of enrichments keyed by a type.
+	//
+	// See the [github.com/quay/claircore/enricher] package for typed access.
 	Enrichments map[string][]json.RawMessage `json:"enrichments"`
 }
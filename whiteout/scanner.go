@@ -21,6 +21,7 @@ const (
 var (
 	_ indexer.FileScanner      = (*Scanner)(nil)
 	_ indexer.VersionedScanner = (*Scanner)(nil)
+	_ indexer.PathScanner      = (*Scanner)(nil)
 )
 
 type Scanner struct{}
@@ -31,6 +32,9 @@ func (*Scanner) Version() string { return scannerVersion }
 
 func (*Scanner) Kind() string { return scannerKind }
 
+// Paths implements [indexer.PathScanner].
+func (*Scanner) Paths() []string { return []string{`**/.wh.*`} }
+
 func (s *Scanner) Scan(ctx context.Context, l *claircore.Layer) ([]claircore.File, error) {
 	ctx = zlog.ContextWithValues(ctx,
 		"component", "whiteout/Scanner.Scan",